@@ -0,0 +1,63 @@
+package alert
+
+import (
+	"encoding/json"
+	"log/slog"
+	"text/template"
+	"time"
+)
+
+// DefaultTemplate renders the level and message of an alert followed by
+// its attributes, one per line, sorted by key.
+var DefaultTemplate = template.Must(template.New("alert").Parse(
+	"[{{.Level}}] {{.Message}}" +
+		"{{range $k, $v := .Attrs}}\n{{$k}}: {{$v}}{{end}}" +
+		"{{if .Suppressed}}\n({{.Suppressed}} more alerts suppressed){{end}}",
+))
+
+// Alert is the data an alert is rendered from. It is the data passed to the
+// template and the document posted by the JSON format.
+type Alert struct {
+	Time    time.Time      `json:"time"`
+	Level   slog.Level     `json:"level"`
+	Message string         `json:"message"`
+	Attrs   map[string]any `json:"attrs,omitempty"`
+	// Suppressed is the number of alerts dropped by rate limiting since the
+	// previous alert was sent.
+	Suppressed int `json:"suppressed,omitempty"`
+	// Text is the alert rendered with the template. It is empty while the
+	// template executes.
+	Text string `json:"text"`
+}
+
+// Format encodes an alert into the body of the webhook request.
+type Format func(a *Alert) ([]byte, error)
+
+// JSON posts the alert itself, including the rendered text.
+func JSON(a *Alert) ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// Slack posts the rendered text as a Slack incoming webhook message.
+func Slack(a *Alert) ([]byte, error) {
+	return json.Marshal(map[string]string{"text": a.Text})
+}
+
+// Teams posts the rendered text as a Microsoft Teams message card,
+// colored by level and summarized by the message.
+func Teams(a *Alert) ([]byte, error) {
+	color := "0078D7"
+	switch {
+	case a.Level >= slog.LevelError:
+		color = "D13438"
+	case a.Level >= slog.LevelWarn:
+		color = "FFB900"
+	}
+	return json.Marshal(map[string]string{
+		"@type":      "MessageCard",
+		"@context":   "https://schema.org/extensions",
+		"summary":    a.Message,
+		"themeColor": color,
+		"text":       a.Text,
+	})
+}
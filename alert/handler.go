@@ -0,0 +1,430 @@
+// Package alert provides a slog.Handler posting records at or above a level
+// to a webhook, such as a Slack or Microsoft Teams channel, as a cheap
+// alerting path for small services.
+package alert
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/internal/jsonvalue"
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/internal/stats"
+)
+
+const (
+	defaultQueueSize  = 100
+	defaultRateLimit  = 10
+	defaultRatePeriod = time.Minute
+	defaultMaxRetries = 3
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// ErrInvalidURL is returned by NewHandler for webhook URLs that are not
+// absolute http or https URLs.
+var ErrInvalidURL = errors.New("slogging: invalid webhook URL")
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	url    string
+	config handlerOptions
+
+	mutex       sync.Mutex
+	windowStart time.Time
+	sent        int
+	suppressed  int
+	queue       chan []byte
+	pending     int
+	idle        *sync.Cond
+	dropped     uint64
+	closed      bool
+	done        chan struct{}
+	now         func() time.Time
+	stats       stats.Recorder
+}
+
+// Handler is a slog.Handler posting records at or above its level,
+// slog.LevelError by default, to a webhook. Each record is rendered into
+// an Alert with the template and encoded into the request body with the
+// format, JSON by default. At most a limited number of alerts is sent in
+// every rate limiting period; the number of alerts suppressed in between
+// is carried by the next alert sent.
+//
+// Alerts are posted from a background goroutine, with retries on network
+// errors, 429 and 5xx responses; Close must be called to send the queued
+// alerts and stop it. The handler is meant to be combined with the
+// handlers writing the logs, e.g. with the multi package.
+type Handler struct {
+	scope scope.Scope
+	state *state
+}
+
+// NewHandler creates a handler posting alerts to the webhook at rawURL.
+func NewHandler(rawURL string, options ...Option) (*Handler, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidURL, rawURL)
+	}
+	config := handlerOptions{
+		client:     http.DefaultClient,
+		level:      slog.LevelError,
+		format:     JSON,
+		template:   DefaultTemplate,
+		rateLimit:  defaultRateLimit,
+		ratePeriod: defaultRatePeriod,
+		queueSize:  defaultQueueSize,
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+		header:     http.Header{},
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	s := &state{
+		url:    rawURL,
+		config: config,
+		queue:  make(chan []byte, config.queueSize),
+		done:   make(chan struct{}),
+		now:    time.Now,
+	}
+	s.idle = sync.NewCond(&s.mutex)
+	h := &Handler{state: s}
+	go h.run()
+	return h, nil
+}
+
+// Enabled reports whether the level is at or above the alert level.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.state.config.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// Handle renders the record into an alert and queues it, unless the
+// record is below the alert level or the rate limit is exhausted. Errors
+// rendering the alert are returned; errors sending it are reported to the
+// error handler.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	s := h.state
+	if r.Level < s.config.level.Level() {
+		return nil
+	}
+	suppressed, ok := s.admit()
+	if !ok {
+		return nil
+	}
+	attrs := h.attrs(r)
+	jsonvalue.Map(attrs)
+	a := &Alert{
+		Time:       r.Time,
+		Level:      r.Level,
+		Message:    r.Message,
+		Attrs:      attrs,
+		Suppressed: suppressed,
+	}
+	var text strings.Builder
+	if err := s.config.template.Execute(&text, a); err != nil {
+		return fmt.Errorf("error when rendering alert: %w", err)
+	}
+	a.Text = text.String()
+	body, err := s.config.format(a)
+	if err != nil {
+		return fmt.Errorf("error when encoding alert: %w", err)
+	}
+	s.enqueue(body)
+	return nil
+}
+
+// admit counts an alert against the rate limit of the current period and
+// reports whether it may be sent, along with the number of alerts
+// suppressed since the previous one that was.
+func (s *state) admit() (int, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	if now.Sub(s.windowStart) >= s.config.ratePeriod {
+		s.windowStart = now
+		s.sent = 0
+	}
+	if s.config.rateLimit > 0 && s.sent >= s.config.rateLimit {
+		s.suppressed++
+		return 0, false
+	}
+	s.sent++
+	n := s.suppressed
+	s.suppressed = 0
+	return n, true
+}
+
+// attrs returns the attributes of the record and of WithAttrs calls,
+// nested under the groups of WithGroup calls.
+func (h *Handler) attrs(r slog.Record) map[string]any {
+	top := make(map[string]any, r.NumAttrs())
+	slogging.PutAttrs(top, h.scope.Attrs(r)...)
+	return top
+}
+
+func (s *state) enqueue(body []byte) {
+	s.stats.Handled(1)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		s.dropped++
+		return
+	}
+	select {
+	case s.queue <- body:
+		s.pending++
+	default:
+		s.dropped++
+	}
+}
+
+func (h *Handler) run() {
+	s := h.state
+	defer close(s.done)
+	for body := range s.queue {
+		err := s.deliver(body)
+		s.stats.Error(err)
+		if err != nil && s.config.onError != nil {
+			s.config.onError(err)
+		}
+		s.mutex.Lock()
+		if err != nil {
+			s.dropped++
+		}
+		s.pending--
+		if s.pending == 0 {
+			s.idle.Broadcast()
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// deliver posts the alert, retrying with backoff on network errors, 429
+// and 5xx responses. A Retry-After header extends the backoff up to the
+// maximum.
+func (s *state) deliver(body []byte) error {
+	backoff := s.config.minBackoff
+	for attempt := 0; ; attempt++ {
+		wait, retry, err := s.send(body)
+		if err == nil || !retry || attempt >= s.config.maxRetries {
+			return err
+		}
+		time.Sleep(min(max(backoff, wait), s.config.maxBackoff))
+		backoff = min(2*backoff, s.config.maxBackoff)
+	}
+}
+
+func (s *state) send(body []byte) (time.Duration, bool, error) {
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, false, fmt.Errorf("error when creating webhook request: %w", err)
+	}
+	for k, v := range s.config.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.config.client.Do(req)
+	if err != nil {
+		return 0, true, fmt.Errorf("error when posting alert: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return 0, false, nil
+	}
+	var wait time.Duration
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		wait = time.Duration(secs) * time.Second
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("error when posting alert: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return wait, resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// Flush blocks until the queued alerts have been sent, or ctx is done.
+func (h *Handler) Flush(ctx context.Context) error {
+	s := h.state
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		for s.pending > 0 && ctx.Err() == nil {
+			s.idle.Wait()
+		}
+	}()
+	select {
+	case <-done:
+		return ctx.Err()
+	case <-ctx.Done():
+		s.mutex.Lock()
+		s.idle.Broadcast()
+		s.mutex.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting alerts, sends the queued ones and stops the
+// delivery goroutine, or gives up when ctx is done.
+func (h *Handler) Close(ctx context.Context) error {
+	s := h.state
+	s.mutex.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mutex.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Dropped returns the number of alerts dropped because the queue was full,
+// the handler was closed or sending failed. Alerts suppressed by the rate
+// limit are not counted.
+func (h *Handler) Dropped() uint64 {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	return h.state.dropped
+}
+
+// Stats returns the counters of the handler. Handled counts the alerts
+// queued for sending rather than the records passed to the handler, and
+// the queue depth is the number of alerts not sent yet.
+func (h *Handler) Stats() slogging.Stats {
+	s := h.state
+	s.mutex.Lock()
+	dropped, depth := s.dropped, s.pending
+	s.mutex.Unlock()
+	return s.stats.Stats(dropped, depth)
+}
+
+type handlerOptions struct {
+	client     *http.Client
+	level      slog.Leveler
+	format     Format
+	template   *template.Template
+	header     http.Header
+	rateLimit  int
+	ratePeriod time.Duration
+	queueSize  int
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	onError    func(error)
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the minimum level of records posted as alerts. Defaults
+// to slog.LevelError.
+func WithLevel(level slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = level
+	}
+}
+
+// WithFormat sets how alerts are encoded into request bodies, e.g. Slack or
+// Teams. Defaults to JSON.
+func WithFormat(f Format) Option {
+	return func(h *handlerOptions) {
+		h.format = f
+	}
+}
+
+// WithTemplate sets the template rendering the text of alerts from an
+// Alert. Defaults to DefaultTemplate.
+func WithTemplate(t *template.Template) Option {
+	return func(h *handlerOptions) {
+		h.template = t
+	}
+}
+
+// WithHeader adds a header to webhook requests, e.g. for authentication.
+func WithHeader(key, value string) Option {
+	return func(h *handlerOptions) {
+		h.header.Add(key, value)
+	}
+}
+
+// WithRateLimit sends at most n alerts in every period. Values of n below 1
+// disable rate limiting. Defaults to 10 alerts per minute.
+func WithRateLimit(n int, period time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.rateLimit = n
+		h.ratePeriod = period
+	}
+}
+
+// WithHTTPClient sets the client posting alerts. Defaults to
+// http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(h *handlerOptions) {
+		h.client = c
+	}
+}
+
+// WithQueueSize sets the number of alerts waiting to be sent beyond which
+// alerts are dropped. Values below 1 are treated as 1.
+func WithQueueSize(n int) Option {
+	return func(h *handlerOptions) {
+		h.queueSize = max(n, 1)
+	}
+}
+
+// WithRetry sets the number of retries of failed requests and the bounds
+// of the exponential backoff between them.
+func WithRetry(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.maxRetries = max(maxRetries, 0)
+		h.minBackoff = minBackoff
+		h.maxBackoff = max(maxBackoff, minBackoff)
+	}
+}
+
+// WithErrorHandler sets a function called with errors sending alerts, once
+// retries are exhausted. It is called from the delivery goroutine.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
@@ -0,0 +1,139 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+)
+
+type webhook struct {
+	mutex  sync.Mutex
+	bodies []string
+	header http.Header
+	fail   int // Number of requests answered with 429
+}
+
+func (w *webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.fail > 0 {
+		w.fail--
+		rw.Header().Set("Retry-After", "0")
+		rw.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	w.bodies = append(w.bodies, string(body))
+	w.header = r.Header
+}
+
+func setup(t *testing.T, options ...Option) (*webhook, *Handler) {
+	t.Helper()
+	w := &webhook{}
+	srv := httptest.NewServer(w)
+	t.Cleanup(srv.Close)
+	h, err := NewHandler(srv.URL, options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = h.Close(context.Background()) })
+	return w, h
+}
+
+type stringer string
+
+func (s stringer) String() string { return "stringer " + string(s) }
+
+func Test_Handler(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		w, h := setup(t, WithHeader("Authorization", "Bearer token"))
+		logger := slog.New(h).With("svc", "api").WithGroup("req")
+		logger.Warn("ignored")
+		logger.Error("payment failed", "err", errors.New("card declined"), "amount", 42, "card", stringer("visa"))
+		if err := h.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(w.bodies) != 1 {
+			t.Fatalf("expected one alert, got %d", len(w.bodies))
+		}
+		var a map[string]any
+		if err := json.Unmarshal([]byte(w.bodies[0]), &a); err != nil {
+			t.Fatal(err)
+		}
+		want := "[ERROR] payment failed\nreq: map[amount:42 card:stringer visa err:card declined]\nsvc: api"
+		if a["level"] != "ERROR" || a["message"] != "payment failed" || a["text"] != want {
+			t.Errorf("unexpected alert: %v", a)
+		}
+		if req := a["attrs"].(map[string]any)["req"].(map[string]any); req["err"] != "card declined" || req["amount"] != 42.0 || req["card"] != "stringer visa" {
+			t.Errorf("unexpected attributes: %v", a["attrs"])
+		}
+		if w.header.Get("Authorization") != "Bearer token" || w.header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected headers: %v", w.header)
+		}
+	})
+
+	t.Run("slack and teams", func(t *testing.T) {
+		tmpl := template.Must(template.New("").Parse("*{{.Message}}* on {{.Attrs.host}}"))
+		slack, hs := setup(t, WithFormat(Slack), WithTemplate(tmpl), WithLevel(slog.LevelWarn))
+		teams, ht := setup(t, WithFormat(Teams), WithTemplate(tmpl), WithLevel(slog.LevelWarn))
+		slog.New(hs).Warn("disk low", "host", "db1")
+		slog.New(ht).Warn("disk low", "host", "db1")
+		_ = hs.Flush(context.Background())
+		_ = ht.Flush(context.Background())
+		if slack.bodies[0] != `{"text":"*disk low* on db1"}` {
+			t.Errorf("unexpected slack body: %s", slack.bodies[0])
+		}
+		var card map[string]string
+		_ = json.Unmarshal([]byte(teams.bodies[0]), &card)
+		if card["@type"] != "MessageCard" || card["themeColor"] != "FFB900" || card["summary"] != "disk low" || card["text"] != "*disk low* on db1" {
+			t.Errorf("unexpected teams card: %v", card)
+		}
+	})
+
+	t.Run("rate limit", func(t *testing.T) {
+		w, h := setup(t, WithRateLimit(2, time.Minute), WithFormat(Slack))
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		h.state.now = func() time.Time { return now }
+		logger := slog.New(h)
+		for range 5 {
+			logger.Error("down")
+		}
+		now = now.Add(time.Minute)
+		logger.Error("still down")
+		_ = h.Flush(context.Background())
+		if len(w.bodies) != 3 || !strings.Contains(w.bodies[2], `still down\n(3 more alerts suppressed)`) {
+			t.Errorf("unexpected alerts: %q", w.bodies)
+		}
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		var errs []error
+		w, h := setup(t, WithRetry(1, time.Millisecond, time.Millisecond), WithErrorHandler(func(err error) { errs = append(errs, err) }))
+		w.fail = 1
+		slog.New(h).Error("first")
+		_ = h.Flush(context.Background())
+		w.fail = 2
+		slog.New(h).Error("second")
+		_ = h.Flush(context.Background())
+		if len(w.bodies) != 1 || h.Dropped() != 1 || len(errs) != 1 || !strings.Contains(errs[0].Error(), "429") {
+			t.Errorf("unexpected delivery: %d alerts, %d dropped, errors %v", len(w.bodies), h.Dropped(), errs)
+		}
+	})
+
+	t.Run("invalid URL", func(t *testing.T) {
+		for _, u := range []string{"", "hooks.slack.com/services/x", "ftp://example.com"} {
+			if _, err := NewHandler(u); !errors.Is(err, ErrInvalidURL) {
+				t.Errorf("%q: expected ErrInvalidURL, got %v", u, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,360 @@
+package async
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/internal/stats"
+)
+
+const defaultQueueSize = 1024
+
+// ErrClosed is returned by Handle after the handler has been closed.
+var ErrClosed = errors.New("slogging: async handler is closed")
+
+// Policy defines what happens to a record when the queue is full.
+type Policy int
+
+const (
+	// DropNewest discards the record being enqueued.
+	DropNewest Policy = iota
+	// DropOldest discards the oldest queued record to make room.
+	DropOldest
+	// Block waits until the queue has room.
+	Block
+)
+
+// Capture copies the parts of the logging context needed at delivery time
+// from src into dst. It is called at enqueue time, on the logging goroutine;
+// the returned context is passed to the wrapped handler on the delivery
+// goroutine.
+type Capture func(dst, src context.Context) context.Context
+
+// CaptureAll retains every value of the logging context, detached from its
+// cancellation. It keeps the whole context chain alive until delivery.
+func CaptureAll(_, src context.Context) context.Context {
+	return context.WithoutCancel(src)
+}
+
+// CaptureSpan retains only the span context of the logging context.
+func CaptureSpan(dst, src context.Context) context.Context {
+	sc := trace.SpanContextFromContext(src)
+	if !sc.IsValid() {
+		return dst
+	}
+	return trace.ContextWithSpanContext(dst, sc)
+}
+
+// CaptureKeys returns a Capture retaining the values stored under the given
+// context keys, e.g. ctxattr.ContextKey().
+func CaptureKeys(keys ...any) Capture {
+	return func(dst, src context.Context) context.Context {
+		for _, k := range keys {
+			if v := src.Value(k); v != nil {
+				dst = context.WithValue(dst, k, v)
+			}
+		}
+		return dst
+	}
+}
+
+type entry struct {
+	ctx     context.Context
+	handler slog.Handler
+	record  slog.Record
+}
+
+// queue is a bounded FIFO shared across WithAttrs/WithGroup derivations
+// and drained by a single delivery goroutine.
+type queue struct {
+	mutex    sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	idle     *sync.Cond
+
+	entries  []entry // Ring buffer
+	head     int
+	size     int
+	inflight int
+	closed   bool
+	dropped  uint64
+	policy   Policy
+	onError  func(error)
+	captures []Capture
+	done     chan struct{}
+	stats    stats.Recorder
+}
+
+func newQueue(size int, policy Policy, onError func(error), captures []Capture) *queue {
+	q := &queue{
+		entries:  make([]entry, size),
+		policy:   policy,
+		onError:  onError,
+		captures: captures,
+		done:     make(chan struct{}),
+	}
+	q.notEmpty = sync.NewCond(&q.mutex)
+	q.notFull = sync.NewCond(&q.mutex)
+	q.idle = sync.NewCond(&q.mutex)
+	return q
+}
+
+func (q *queue) push(e entry) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for !q.closed && q.size == len(q.entries) {
+		switch q.policy {
+		case DropNewest:
+			q.dropped++
+			return nil
+		case DropOldest:
+			q.entries[q.head] = entry{}
+			q.head = (q.head + 1) % len(q.entries)
+			q.size--
+			q.dropped++
+		default:
+			q.notFull.Wait()
+		}
+	}
+	if q.closed {
+		return ErrClosed
+	}
+	q.entries[(q.head+q.size)%len(q.entries)] = e
+	q.size++
+	q.notEmpty.Signal()
+	return nil
+}
+
+func (q *queue) run() {
+	defer close(q.done)
+	for {
+		q.mutex.Lock()
+		for q.size == 0 && !q.closed {
+			q.notEmpty.Wait()
+		}
+		if q.size == 0 {
+			q.mutex.Unlock()
+			return
+		}
+		e := q.entries[q.head]
+		q.entries[q.head] = entry{}
+		q.head = (q.head + 1) % len(q.entries)
+		q.size--
+		q.inflight++
+		q.notFull.Signal()
+		q.mutex.Unlock()
+
+		err := e.handler.Handle(e.ctx, e.record)
+		q.stats.Error(err)
+		if err != nil && q.onError != nil {
+			q.onError(err)
+		}
+
+		q.mutex.Lock()
+		q.inflight--
+		if q.size == 0 && q.inflight == 0 {
+			q.idle.Broadcast()
+		}
+		q.mutex.Unlock()
+	}
+}
+
+func (q *queue) flush(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		q.mutex.Lock()
+		defer q.mutex.Unlock()
+		for (q.size > 0 || q.inflight > 0) && ctx.Err() == nil {
+			q.idle.Wait()
+		}
+	}()
+	select {
+	case <-done:
+		return ctx.Err()
+	case <-ctx.Done():
+		// Wake the waiter up so it observes the cancellation and exits.
+		q.mutex.Lock()
+		q.idle.Broadcast()
+		q.mutex.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Handler is a slog.Handler that enqueues records into a bounded queue and
+// delivers them to the wrapped handler from a background goroutine, so that
+// Handle never blocks on the wrapped handler. Records are deep-copied with
+// slogging.CloneRecord before they are enqueued. Errors returned by the
+// wrapped handler are reported to the error handler configured with
+// WithErrorHandler.
+type Handler struct {
+	handler slog.Handler
+	queue   *queue
+}
+
+// Wrap creates an asynchronous handler delivering to handler and starts
+// its delivery goroutine. Close must be called to stop the goroutine.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{
+		queueSize: defaultQueueSize,
+		policy:    DropNewest,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	q := newQueue(config.queueSize, config.policy, config.onError, config.captures)
+	go q.run()
+	return &Handler{handler: handler, queue: q}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle enqueues a clone of the record for asynchronous delivery. The
+// delivery context is captured from ctx before Handle returns, so values
+// like the active span are those present when the record was logged.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	h.queue.stats.Handled(1)
+	return h.queue.push(entry{ctx: h.capture(ctx), handler: h.handler, record: slogging.CloneRecord(r)})
+}
+
+func (h *Handler) capture(ctx context.Context) context.Context {
+	if len(h.queue.captures) == 0 {
+		return CaptureAll(nil, ctx)
+	}
+	dst := context.Background()
+	for _, c := range h.queue.captures {
+		dst = c(dst, ctx)
+	}
+	return dst
+}
+
+// WithAttrs returns a new Handler sharing the queue whose wrapped handler
+// includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &Handler{handler: h.handler.WithAttrs(attrs), queue: h.queue}
+}
+
+// WithGroup returns a new Handler sharing the queue whose wrapped handler
+// starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{handler: h.handler.WithGroup(name), queue: h.queue}
+}
+
+// Flush blocks until every record enqueued before the call has been
+// delivered, or ctx is done.
+func (h *Handler) Flush(ctx context.Context) error {
+	return h.queue.flush(ctx)
+}
+
+// Close stops accepting records, delivers the queued ones and stops the
+// delivery goroutine. Records still queued when ctx is done are discarded.
+func (h *Handler) Close(ctx context.Context) error {
+	q := h.queue
+	q.mutex.Lock()
+	if !q.closed {
+		q.closed = true
+		q.notEmpty.Broadcast()
+		q.notFull.Broadcast()
+	}
+	q.mutex.Unlock()
+
+	select {
+	case <-q.done:
+		return nil
+	case <-ctx.Done():
+		q.mutex.Lock()
+		q.dropped += uint64(q.size)
+		for q.size > 0 {
+			q.entries[q.head] = entry{}
+			q.head = (q.head + 1) % len(q.entries)
+			q.size--
+		}
+		q.mutex.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Dropped returns the number of records discarded due to queue overflow
+// or shutdown.
+func (h *Handler) Dropped() uint64 {
+	h.queue.mutex.Lock()
+	defer h.queue.mutex.Unlock()
+	return h.queue.dropped
+}
+
+// Len returns the number of records waiting in the queue.
+func (h *Handler) Len() int {
+	h.queue.mutex.Lock()
+	defer h.queue.mutex.Unlock()
+	return h.queue.size
+}
+
+// Stats returns the counters of the handler. Errors are those returned by
+// the wrapped handler, and the queue depth includes the record being
+// delivered.
+func (h *Handler) Stats() slogging.Stats {
+	q := h.queue
+	q.mutex.Lock()
+	dropped, depth := q.dropped, q.size+q.inflight
+	q.mutex.Unlock()
+	return q.stats.Stats(dropped, depth)
+}
+
+type handlerOptions struct {
+	queueSize int
+	policy    Policy
+	onError   func(error)
+	captures  []Capture
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithQueueSize sets the capacity of the queue. Values below 1 are treated as 1.
+func WithQueueSize(n int) Option {
+	return func(h *handlerOptions) {
+		h.queueSize = max(n, 1)
+	}
+}
+
+// WithOverflowPolicy sets what happens when the queue is full.
+func WithOverflowPolicy(p Policy) Option {
+	return func(h *handlerOptions) {
+		h.policy = p
+	}
+}
+
+// WithErrorHandler sets a function called with errors returned by the
+// wrapped handler. It is called from the delivery goroutine.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
+
+// WithCapture sets what is retained from the logging context for delivery.
+// Captures are applied in order to an empty context. By default the whole
+// logging context is retained, detached from its cancellation (CaptureAll);
+// use CaptureSpan and CaptureKeys to bound the memory held by queued records.
+func WithCapture(captures ...Capture) Option {
+	return func(h *handlerOptions) {
+		h.captures = append(h.captures, captures...)
+	}
+}
@@ -0,0 +1,37 @@
+package async
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func BenchmarkHandler(b *testing.B) {
+	testMessage := "test log message"
+	testAttrs := []any{"key1", "value1", "key2", "value2", "key3", 123}
+
+	b.Run("BaselineTextHandler", func(b *testing.B) {
+		logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			logger.Info(testMessage, testAttrs...)
+		}
+	})
+
+	b.Run("AsyncHandler", func(b *testing.B) {
+		handler := Wrap(slog.NewTextHandler(io.Discard, nil), WithOverflowPolicy(Block))
+		defer handler.Close(context.Background())
+		logger := slog.New(handler)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			logger.Info(testMessage, testAttrs...)
+		}
+		b.StopTimer()
+		handler.Flush(context.Background())
+	})
+}
@@ -0,0 +1,252 @@
+package async
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging/otel"
+)
+
+// gatedHandler blocks in Handle until the gate is opened.
+type gatedHandler struct {
+	slog.Handler
+	gate    chan struct{}
+	started chan struct{}
+	once    *sync.Once
+}
+
+func newGatedHandler(buf *bytes.Buffer) gatedHandler {
+	return gatedHandler{
+		Handler: slog.NewTextHandler(buf, nil),
+		gate:    make(chan struct{}),
+		started: make(chan struct{}),
+		once:    &sync.Once{},
+	}
+}
+
+func (h gatedHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.once.Do(func() { close(h.started) })
+	<-h.gate
+	return h.Handler.Handle(ctx, r)
+}
+
+func Test_Async(t *testing.T) {
+	t.Run("records are delivered after flush", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil))
+		defer h.Close(context.Background())
+
+		slog.New(h).With("svc", "api").WithGroup("req").Info("hello", "id", 1)
+		if err := h.Flush(context.Background()); err != nil {
+			t.Fatalf("unexpected flush error: %v", err)
+		}
+		if !strings.Contains(buf.String(), "msg=hello svc=api req.id=1") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("drop newest discards incoming records", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		inner := newGatedHandler(buf)
+		h := Wrap(inner, WithQueueSize(2), WithOverflowPolicy(DropNewest))
+		logger := slog.New(h)
+
+		logger.Info("first")
+		<-inner.started
+		logger.Info("second")
+		logger.Info("third")
+		logger.Info("fourth")
+
+		if h.Dropped() != 1 {
+			t.Errorf("expected 1 dropped record, got %d", h.Dropped())
+		}
+		close(inner.gate)
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatalf("unexpected close error: %v", err)
+		}
+		if strings.Contains(buf.String(), "fourth") || !strings.Contains(buf.String(), "third") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("drop oldest discards queued records", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		inner := newGatedHandler(buf)
+		h := Wrap(inner, WithQueueSize(2), WithOverflowPolicy(DropOldest))
+		logger := slog.New(h)
+
+		logger.Info("first")
+		<-inner.started
+		logger.Info("second")
+		logger.Info("third")
+		logger.Info("fourth")
+
+		if h.Dropped() != 1 {
+			t.Errorf("expected 1 dropped record, got %d", h.Dropped())
+		}
+		close(inner.gate)
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatalf("unexpected close error: %v", err)
+		}
+		if strings.Contains(buf.String(), "second") || !strings.Contains(buf.String(), "fourth") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("block waits for room", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		inner := newGatedHandler(buf)
+		h := Wrap(inner, WithQueueSize(1), WithOverflowPolicy(Block))
+		logger := slog.New(h)
+
+		logger.Info("first")
+		<-inner.started
+		logger.Info("second")
+
+		done := make(chan struct{})
+		go func() {
+			logger.Info("third")
+			close(done)
+		}()
+		select {
+		case <-done:
+			t.Fatalf("expected Handle to block while the queue is full")
+		case <-time.After(20 * time.Millisecond):
+		}
+		close(inner.gate)
+		<-done
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatalf("unexpected close error: %v", err)
+		}
+		if h.Dropped() != 0 || strings.Count(buf.String(), "\n") != 3 {
+			t.Errorf("unexpected output (dropped %d): %s", h.Dropped(), buf.String())
+		}
+	})
+
+	t.Run("flush honors context", func(t *testing.T) {
+		inner := newGatedHandler(new(bytes.Buffer))
+		h := Wrap(inner)
+		slog.New(h).Info("stuck")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		if err := h.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		close(inner.gate)
+		h.Close(context.Background())
+	})
+
+	t.Run("handle fails after close", func(t *testing.T) {
+		h := Wrap(slog.NewTextHandler(new(bytes.Buffer), nil))
+		h.Close(context.Background())
+		err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "late", 0))
+		if !errors.Is(err, ErrClosed) {
+			t.Errorf("expected ErrClosed, got %v", err)
+		}
+	})
+
+	t.Run("inner errors are reported", func(t *testing.T) {
+		var mutex sync.Mutex
+		var errs []error
+		h := Wrap(errorHandler{slog.NewTextHandler(new(bytes.Buffer), nil)}, WithErrorHandler(func(err error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			errs = append(errs, err)
+		}))
+		slog.New(h).Info("boom")
+		h.Close(context.Background())
+		if len(errs) != 1 {
+			t.Errorf("expected 1 reported error, got %v", errs)
+		}
+	})
+}
+
+type errorHandler struct {
+	slog.Handler
+}
+
+func (errorHandler) Handle(context.Context, slog.Record) error {
+	return errors.New("boom")
+}
+
+type ctxKey string
+
+// contextHandler records the delivery context of every record.
+type contextHandler struct {
+	slog.Handler
+	ctxs chan context.Context
+}
+
+func (h contextHandler) Handle(ctx context.Context, _ slog.Record) error {
+	h.ctxs <- ctx
+	return nil
+}
+
+func Test_Capture(t *testing.T) {
+	tracer := sdktrace.NewTracerProvider().Tracer("test-tracer")
+
+	t.Run("default capture detaches cancellation and keeps values", func(t *testing.T) {
+		inner := contextHandler{slog.NewTextHandler(new(bytes.Buffer), nil), make(chan context.Context, 1)}
+		h := Wrap(inner)
+		defer h.Close(context.Background())
+
+		ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey("tenant"), "acme"))
+		cancel()
+		slog.New(h).InfoContext(ctx, "hello")
+
+		got := <-inner.ctxs
+		if got.Err() != nil {
+			t.Errorf("delivery context should not be cancelled: %v", got.Err())
+		}
+		if got.Value(ctxKey("tenant")) != "acme" {
+			t.Errorf("expected context value to be retained")
+		}
+	})
+
+	t.Run("span capture keeps only the span context", func(t *testing.T) {
+		inner := contextHandler{slog.NewTextHandler(new(bytes.Buffer), nil), make(chan context.Context, 1)}
+		h := Wrap(inner, WithCapture(CaptureSpan, CaptureKeys(ctxKey("tenant"))))
+		defer h.Close(context.Background())
+
+		ctx, span := tracer.Start(context.Background(), "test-span")
+		ctx = context.WithValue(ctx, ctxKey("tenant"), "acme")
+		ctx = context.WithValue(ctx, ctxKey("payload"), "large")
+		span.End()
+		slog.New(h).InfoContext(ctx, "hello")
+
+		got := <-inner.ctxs
+		if !trace.SpanContextFromContext(got).Equal(span.SpanContext()) {
+			t.Errorf("expected span context to be captured")
+		}
+		if got.Value(ctxKey("tenant")) != "acme" {
+			t.Errorf("expected listed key to be captured")
+		}
+		if got.Value(ctxKey("payload")) != nil {
+			t.Errorf("unexpected value for unlisted key")
+		}
+	})
+
+	t.Run("trace attributes are resolved by a wrapped otel handler", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(otel.Wrap(slog.NewTextHandler(buf, nil)), WithCapture(CaptureSpan))
+
+		ctx, span := tracer.Start(context.Background(), "test-span")
+		slog.New(h).InfoContext(ctx, "hello")
+		span.End()
+		h.Close(context.Background())
+
+		if !strings.Contains(buf.String(), "otel.trace_id="+span.SpanContext().TraceID().String()) {
+			t.Errorf("expected trace id in output: %s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,283 @@
+package bandwidth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultRate  = 32 << 10
+	defaultBurst = 128 << 10
+
+	// recordOverhead approximates the bytes spent on the timestamp, level
+	// and framing of an encoded record.
+	recordOverhead = 64
+	// attrOverhead approximates the bytes spent on quoting and separators
+	// of an encoded attribute.
+	attrOverhead = 6
+)
+
+// Size estimates the number of bytes a record occupies once encoded by the
+// wrapped handler.
+type Size func(r slog.Record) int
+
+// Estimate approximates the encoded size of a record from the length of
+// its message and attributes, without encoding it. Attributes added via
+// WithAttrs are accounted for by the Handler.
+func Estimate(r slog.Record) int {
+	n := recordOverhead + len(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		n += attrSize(a)
+		return true
+	})
+	return n
+}
+
+func attrSize(a slog.Attr) int {
+	return attrOverhead + len(a.Key) + valueSize(a.Value.Resolve())
+}
+
+func valueSize(v slog.Value) int {
+	switch v.Kind() {
+	case slog.KindString:
+		return len(v.String())
+	case slog.KindBool:
+		return 5
+	case slog.KindInt64, slog.KindUint64, slog.KindFloat64, slog.KindDuration:
+		return 12
+	case slog.KindTime:
+		return 32
+	case slog.KindGroup:
+		n := 2
+		for _, a := range v.Group() {
+			n += attrSize(a)
+		}
+		return n
+	default:
+		return len(fmt.Sprint(v.Any()))
+	}
+}
+
+// Limiter is a token bucket holding a byte budget refilled at a constant
+// rate. A Limiter may be shared by several handlers, so that all the sinks
+// of a process stay within a single egress budget.
+type Limiter struct {
+	mutex  sync.Mutex
+	rate   float64 // Bytes per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// NewLimiter returns a Limiter refilled with rate bytes per second, holding
+// at most burst bytes. The bucket starts full. Burst values below 1 are
+// treated as 1.
+func NewLimiter(rate, burst int) *Limiter {
+	l := &Limiter{
+		rate:  float64(max(rate, 0)),
+		burst: float64(max(burst, 1)),
+		now:   time.Now,
+	}
+	l.tokens = l.burst
+	return l
+}
+
+// take charges n bytes to the bucket if the budget allows it. Regular
+// records are admitted while the bucket holds n bytes, or is full for
+// records larger than the burst. Priority records are admitted as long as
+// the bucket is not in debt by a full burst; the bytes they borrow are
+// repaid before regular records are admitted again.
+func (l *Limiter) take(n int, priority bool) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.now()
+	if !l.last.IsZero() {
+		l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	}
+	l.last = now
+	cost := float64(n)
+	if priority {
+		if l.tokens <= -l.burst {
+			return false
+		}
+	} else if l.tokens < min(cost, l.burst) {
+		return false
+	}
+	l.tokens -= cost
+	return true
+}
+
+// Wait blocks until n bytes can be charged to the bucket as a regular
+// record, and charges them, or returns the error of ctx when it is done
+// first. It lets senders that can hold records back, such as forwarders
+// draining a spool, pace themselves instead of dropping records.
+func (l *Limiter) Wait(ctx context.Context, n int) error {
+	for {
+		if l.take(n, false) {
+			return nil
+		}
+		l.mutex.Lock()
+		wait := time.Second
+		if l.rate > 0 {
+			wait = time.Duration((min(float64(n), l.burst) - l.tokens) / l.rate * float64(time.Second))
+		}
+		l.mutex.Unlock()
+		t := time.NewTimer(max(wait, time.Millisecond))
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// Available returns the number of bytes that can currently be sent without
+// exceeding the budget. It is negative while priority records are in debt.
+func (l *Limiter) Available() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	tokens := l.tokens
+	if !l.last.IsZero() {
+		tokens = min(l.burst, tokens+l.now().Sub(l.last).Seconds()*l.rate)
+	}
+	return int(tokens)
+}
+
+// Handler is a slog.Handler that keeps the bytes delivered to the wrapped
+// handler within a budget, so that shipping logs cannot saturate a
+// constrained link. Records exceeding the budget are dropped; records at or
+// above the priority level may borrow up to one burst ahead of the budget,
+// so warnings and errors get through a flood of lower level records.
+type Handler struct {
+	handler  slog.Handler
+	limiter  *Limiter
+	size     Size
+	priority slog.Level
+	extra    int // Estimated size of the attributes and groups added via WithAttrs and WithGroup
+	dropped  *atomic.Uint64
+}
+
+// Wrap creates a handler delivering to handler within a byte budget.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{
+		rate:     defaultRate,
+		burst:    defaultBurst,
+		priority: slog.LevelWarn,
+		size:     Estimate,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	if config.size == nil {
+		config.size = Estimate
+	}
+	l := config.limiter
+	if l == nil {
+		l = NewLimiter(config.rate, config.burst)
+	}
+	return &Handler{
+		handler:  handler,
+		limiter:  l,
+		size:     config.size,
+		priority: config.priority,
+		dropped:  new(atomic.Uint64),
+	}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle delivers the record if its estimated size fits in the budget and
+// drops it otherwise.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.limiter.take(h.size(r)+h.extra, r.Level >= h.priority) {
+		h.dropped.Add(1)
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// Dropped returns the number of records dropped for exceeding the budget.
+func (h *Handler) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+// WithAttrs returns a new Handler sharing the budget whose wrapped handler
+// includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	for _, a := range attrs {
+		h2.extra += attrSize(a)
+	}
+	return &h2
+}
+
+// WithGroup returns a new Handler sharing the budget whose wrapped handler
+// starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	h2.extra += attrOverhead + len(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	rate     int
+	burst    int
+	limiter  *Limiter
+	priority slog.Level
+	size     Size
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithRate sets the budget to rate bytes per second with bursts of up to
+// burst bytes. Defaults to 32 KiB per second with bursts of 128 KiB.
+func WithRate(rate, burst int) Option {
+	return func(h *handlerOptions) {
+		h.rate = rate
+		h.burst = burst
+	}
+}
+
+// WithLimiter makes the handler draw from a Limiter shared with other
+// handlers. It takes precedence over WithRate.
+func WithLimiter(l *Limiter) Option {
+	return func(h *handlerOptions) {
+		h.limiter = l
+	}
+}
+
+// WithPriority sets the level at and above which records may borrow ahead
+// of the budget. Defaults to slog.LevelWarn.
+func WithPriority(level slog.Level) Option {
+	return func(h *handlerOptions) {
+		h.priority = level
+	}
+}
+
+// WithSize replaces the function estimating the encoded size of records.
+// Defaults to Estimate.
+func WithSize(fn Size) Option {
+	return func(h *handlerOptions) {
+		h.size = fn
+	}
+}
@@ -0,0 +1,115 @@
+package bandwidth
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func fixed(n int) Size {
+	return func(slog.Record) int { return n }
+}
+
+func Test_Handler(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("records beyond the budget are dropped", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		l := NewLimiter(100, 300)
+		l.now = clock
+		h := Wrap(slog.NewTextHandler(buf, nil), WithLimiter(l), WithSize(fixed(100)))
+		logger := slog.New(h)
+		for range 5 {
+			logger.Info("tick")
+		}
+		if n := strings.Count(buf.String(), "msg=tick"); n != 3 || h.Dropped() != 2 {
+			t.Errorf("expected 3 delivered and 2 dropped, got %d and %d", n, h.Dropped())
+		}
+		now = now.Add(time.Second)
+		logger.Info("tick")
+		logger.Info("tick")
+		if n := strings.Count(buf.String(), "msg=tick"); n != 4 || h.Dropped() != 3 {
+			t.Errorf("expected one more record after refill, got %d and %d", n, h.Dropped())
+		}
+	})
+
+	t.Run("priority records borrow ahead of the budget", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		l := NewLimiter(100, 200)
+		l.now = clock
+		h := Wrap(slog.NewTextHandler(buf, nil), WithLimiter(l), WithSize(fixed(100)))
+		logger := slog.New(h)
+		logger.Info("fill")
+		logger.Info("fill")
+		logger.Info("dropped")
+		for range 4 {
+			logger.Warn("urgent")
+		}
+		if n := strings.Count(buf.String(), "msg=urgent"); n != 2 || strings.Contains(buf.String(), "dropped") {
+			t.Errorf("expected 2 priority records, got %d: %s", n, buf.String())
+		}
+		if l.Available() != -200 {
+			t.Errorf("expected a debt of one burst, got %d", l.Available())
+		}
+		now = now.Add(time.Second)
+		logger.Info("after")
+		if strings.Contains(buf.String(), "after") {
+			t.Errorf("regular records must wait for the debt to be repaid")
+		}
+	})
+
+	t.Run("records larger than the burst pass when the bucket is full", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		l := NewLimiter(100, 100)
+		l.now = clock
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), WithLimiter(l), WithSize(fixed(250))))
+		logger.Info("big")
+		logger.Info("big")
+		if n := strings.Count(buf.String(), "msg=big"); n != 1 {
+			t.Errorf("expected 1 delivered record, got %d", n)
+		}
+	})
+
+	t.Run("estimate accounts for attributes", func(t *testing.T) {
+		var sizes []int
+		record := func(r slog.Record) int {
+			sizes = append(sizes, Estimate(r))
+			return 0
+		}
+		h := Wrap(slog.NewTextHandler(new(bytes.Buffer), nil), WithSize(record))
+		logger := slog.New(h)
+		logger.Info("msg")
+		logger.Info("msg", "key", strings.Repeat("x", 100), slog.Group("g", "n", 1))
+		if sizes[1]-sizes[0] != attrOverhead+3+100+attrOverhead+1+2+attrOverhead+1+12 {
+			t.Errorf("unexpected estimates: %v", sizes)
+		}
+		if h2 := h.WithAttrs([]slog.Attr{slog.String("k", "v")}).WithGroup("grp").(*Handler); h2.extra != attrOverhead+2+attrOverhead+3 {
+			t.Errorf("unexpected size of handler attributes: %d", h2.extra)
+		}
+	})
+}
+
+func Test_Limiter_Wait(t *testing.T) {
+	l := NewLimiter(10000, 100)
+	if err := l.Wait(context.Background(), 100); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := l.Wait(context.Background(), 100); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d < 5*time.Millisecond {
+		t.Errorf("expected to wait for the refill, waited %v", d)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	empty := NewLimiter(0, 1)
+	_ = empty.Wait(ctx, 10)
+	if err := empty.Wait(ctx, 10); err != context.DeadlineExceeded {
+		t.Errorf("expected the context error, got %v", err)
+	}
+}
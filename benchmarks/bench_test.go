@@ -0,0 +1,129 @@
+package benchmarks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func BenchmarkJSONFile(b *testing.B) {
+	run(b, JSONFile, func(tb testing.TB) io.Writer { return TempFile(tb) }, context.Background())
+}
+
+func BenchmarkBufferedShip(b *testing.B) {
+	run(b, BufferedShip, func(tb testing.TB) io.Writer { return Sink(tb) }, context.Background())
+}
+
+// BenchmarkTraced discards the output, to measure the cost of adding the
+// trace context rather than the cost of writing it.
+func BenchmarkTraced(b *testing.B) {
+	ctx, end := SpanContext()
+	defer end()
+	run(b, Traced, func(testing.TB) io.Writer { return io.Discard }, ctx)
+}
+
+// run benchmarks every logger of the scenario, from one goroutine and from
+// GOMAXPROCS goroutines, each with a scenario and writer of its own.
+func run(b *testing.B, scenario Scenario, writer func(testing.TB) io.Writer, ctx context.Context) {
+	loggers, done := scenario(b, io.Discard)
+	done()
+	for _, name := range slices.Sorted(maps.Keys(loggers)) {
+		b.Run(name+"/serial", func(b *testing.B) {
+			loggers, done := scenario(b, writer(b))
+			defer done()
+			log := loggers[name]
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				log(ctx)
+			}
+		})
+		b.Run(name+"/parallel", func(b *testing.B) {
+			loggers, done := scenario(b, writer(b))
+			defer done()
+			log := loggers[name]
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					log(ctx)
+				}
+			})
+		})
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use, as buffered
+// loggers write from their own goroutines.
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+// Test_Scenarios checks that the loggers compared write the same fields.
+func Test_Scenarios(t *testing.T) {
+	ctx, end := SpanContext()
+	defer end()
+	for name, scenario := range map[string]Scenario{"JSONFile": JSONFile, "BufferedShip": BufferedShip, "Traced": Traced} {
+		t.Run(name, func(t *testing.T) {
+			buf := new(syncBuffer)
+			loggers, done := scenario(t, buf)
+			for library, log := range loggers {
+				before := len(buf.String())
+				log(ctx)
+				if name == "BufferedShip" {
+					continue
+				}
+				check(t, name, library, buf.String()[before:])
+			}
+			done()
+			if name == "BufferedShip" {
+				lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+				if len(lines) != len(loggers) {
+					t.Fatalf("%d records shipped, want %d", len(lines), len(loggers))
+				}
+				for _, line := range lines {
+					check(t, name, "", line)
+				}
+			}
+		})
+	}
+}
+
+func check(t *testing.T, scenario, library, line string) {
+	t.Helper()
+	var record map[string]any
+	if err := json.Unmarshal([]byte(line), &record); err != nil {
+		t.Fatalf("%s: invalid JSON %q: %v", library, line, err)
+	}
+	keys := []string{"service", "region", "path", "status", "elapsed", "cached"}
+	if scenario == "Traced" {
+		keys = append(keys, "trace_id", "span_id")
+	}
+	for _, k := range keys {
+		if _, ok := record[k]; !ok {
+			t.Errorf("%s: no %s in %s", library, k, line)
+		}
+	}
+	if !strings.Contains(line, message) {
+		t.Errorf("%s: no message in %s", library, line)
+	}
+}
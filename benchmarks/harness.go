@@ -0,0 +1,221 @@
+// Package benchmarks compares the pipelines of slogging with zap and
+// zerolog in common production scenarios. It is a module of its own, so
+// that the loggers it compares are not dependencies of slogging.
+//
+// Every scenario logs the same record, a message with a string, an int, a
+// duration and a bool, from a logger carrying two fields, through each
+// library configured the way it is commonly used in production. See
+// README.md for running the benchmarks and comparing results.
+package benchmarks
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/diode"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/mikluko/slogging/async"
+	"github.com/mikluko/slogging/buffered"
+	"github.com/mikluko/slogging/otel"
+)
+
+const (
+	message    = "request served"
+	bufferSize = 256 << 10
+	flushEvery = time.Second
+)
+
+// Logger logs the record of the scenarios with a context, as one library
+// would be called from application code.
+type Logger func(ctx context.Context)
+
+// Scenario builds the loggers of one comparison, named by library, from
+// the writer records are written to. The returned function releases what
+// the loggers hold, flushing buffered records.
+type Scenario func(tb testing.TB, w io.Writer) (map[string]Logger, func())
+
+// Slog returns a logger using handler.
+func Slog(handler slog.Handler) Logger {
+	logger := slog.New(handler).With("service", "checkout", "region", "eu-west-1")
+	return func(ctx context.Context) {
+		logger.LogAttrs(ctx, slog.LevelInfo, message,
+			slog.String("path", "/api/v1/orders"),
+			slog.Int("status", 200),
+			slog.Duration("elapsed", 1532*time.Microsecond),
+			slog.Bool("cached", false))
+	}
+}
+
+// Zap returns a logger writing JSON with the production encoder of zap to
+// ws.
+func Zap(ws zapcore.WriteSyncer) Logger {
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), ws, zapcore.InfoLevel)
+	logger := zap.New(core).With(zap.String("service", "checkout"), zap.String("region", "eu-west-1"))
+	return func(context.Context) {
+		logger.Info(message,
+			zap.String("path", "/api/v1/orders"),
+			zap.Int("status", 200),
+			zap.Duration("elapsed", 1532*time.Microsecond),
+			zap.Bool("cached", false))
+	}
+}
+
+// ZapTraced is Zap adding the IDs of the span of the context, as done by
+// hand or by helpers with zap.
+func ZapTraced(ws zapcore.WriteSyncer) Logger {
+	core := zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), ws, zapcore.InfoLevel)
+	logger := zap.New(core).With(zap.String("service", "checkout"), zap.String("region", "eu-west-1"))
+	return func(ctx context.Context) {
+		sc := trace.SpanContextFromContext(ctx)
+		logger.Info(message,
+			zap.String("trace_id", sc.TraceID().String()),
+			zap.String("span_id", sc.SpanID().String()),
+			zap.String("path", "/api/v1/orders"),
+			zap.Int("status", 200),
+			zap.Duration("elapsed", 1532*time.Microsecond),
+			zap.Bool("cached", false))
+	}
+}
+
+// Zerolog returns a logger writing JSON with zerolog to w.
+func Zerolog(w io.Writer) Logger {
+	logger := zerolog.New(w).With().Timestamp().Str("service", "checkout").Str("region", "eu-west-1").Logger()
+	return func(context.Context) {
+		logger.Info().
+			Str("path", "/api/v1/orders").
+			Int("status", 200).
+			Dur("elapsed", 1532*time.Microsecond).
+			Bool("cached", false).
+			Msg(message)
+	}
+}
+
+// ZerologTraced is Zerolog adding the IDs of the span of the context.
+func ZerologTraced(w io.Writer) Logger {
+	logger := zerolog.New(w).With().Timestamp().Str("service", "checkout").Str("region", "eu-west-1").Logger()
+	return func(ctx context.Context) {
+		sc := trace.SpanContextFromContext(ctx)
+		logger.Info().
+			Str("trace_id", sc.TraceID().String()).
+			Str("span_id", sc.SpanID().String()).
+			Str("path", "/api/v1/orders").
+			Int("status", 200).
+			Dur("elapsed", 1532*time.Microsecond).
+			Bool("cached", false).
+			Msg(message)
+	}
+}
+
+// JSONFile writes every record to a file as it is logged, unbuffered.
+func JSONFile(_ testing.TB, w io.Writer) (map[string]Logger, func()) {
+	return map[string]Logger{
+		"slog":     Slog(slog.NewJSONHandler(w, nil)),
+		"slogging": Slog(otel.Wrap(slog.NewJSONHandler(w, nil))),
+		"zap":      Zap(zapcore.AddSync(w)),
+		"zerolog":  Zerolog(w),
+	}, func() {}
+}
+
+// BufferedShip writes records to a network connection through a buffer
+// flushed when full and every second: buffered.Writer for slogging, with
+// the handler called synchronously or from an async queue,
+// BufferedWriteSyncer for zap and the diode ring buffer for zerolog.
+func BufferedShip(tb testing.TB, w io.Writer) (map[string]Logger, func()) {
+	// The writers share w, which none of them may close.
+	w = struct{ io.Writer }{w}
+	sw := buffered.NewWriter(w, buffered.WithSize(bufferSize), buffered.WithInterval(flushEvery))
+	aw := buffered.NewWriter(w, buffered.WithSize(bufferSize), buffered.WithInterval(flushEvery))
+	ah := async.Wrap(slog.NewJSONHandler(aw, nil), async.WithOverflowPolicy(async.Block))
+	zw := &zapcore.BufferedWriteSyncer{WS: zapcore.AddSync(w), Size: bufferSize, FlushInterval: flushEvery}
+	dw := diode.NewWriter(w, 10000, 10*time.Millisecond, func(int) {})
+	return map[string]Logger{
+		"slogging":       Slog(buffered.Wrap(slog.NewJSONHandler(sw, nil), sw)),
+		"slogging_async": Slog(ah),
+		"zap":            Zap(zw),
+		"zerolog_diode":  Zerolog(dw),
+	}, func() {
+		// The async queue drains into its writer, closed after it.
+		if err := ah.Close(context.Background()); err != nil {
+			tb.Error(err)
+		}
+		for _, c := range []io.Closer{sw, aw, dw} {
+			if err := c.Close(); err != nil {
+				tb.Error(err)
+			}
+		}
+		if err := zw.Stop(); err != nil {
+			tb.Error(err)
+		}
+	}
+}
+
+// Traced writes every record to w with the IDs of the span of the logging
+// context: with otel.Wrap for slogging, by hand for zap and zerolog.
+func Traced(_ testing.TB, w io.Writer) (map[string]Logger, func()) {
+	return map[string]Logger{
+		"slogging": Slog(otel.Wrap(slog.NewJSONHandler(w, nil), otel.WithConvention(otel.Convention{TraceID: "trace_id", SpanID: "span_id"}))),
+		"zap":      ZapTraced(zapcore.AddSync(w)),
+		"zerolog":  ZerologTraced(w),
+	}, func() {}
+}
+
+// SpanContext returns a context with a sampled span.
+func SpanContext() (context.Context, func()) {
+	tp := sdktrace.NewTracerProvider()
+	ctx, span := tp.Tracer("benchmarks").Start(context.Background(), "request")
+	return ctx, func() {
+		span.End()
+		_ = tp.Shutdown(context.Background())
+	}
+}
+
+// TempFile returns a file in a temporary directory removed at the end of
+// the test.
+func TempFile(tb testing.TB) *os.File {
+	f, err := os.CreateTemp(tb.TempDir(), "bench-*.log")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = f.Close() })
+	return f
+}
+
+// Sink returns a connection to a local TCP server discarding what it
+// receives, closed at the end of the test.
+func Sink(tb testing.TB) net.Conn {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(io.Discard, c)
+				_ = c.Close()
+			}()
+		}
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() {
+		_ = conn.Close()
+		_ = ln.Close()
+	})
+	return conn
+}
@@ -0,0 +1,638 @@
+// Package bigquery provides a slog.Handler appending records as rows to a
+// BigQuery table with the Storage Write API.
+package bigquery
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/internal/jsonvalue"
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/internal/stats"
+)
+
+// ErrClosed is returned by Handle after the handler has been closed.
+var ErrClosed = errors.New("slogging: bigquery handler is closed")
+
+const (
+	defaultBatchSize  = 500
+	defaultBatchWait  = time.Second
+	defaultMaxRetries = 5
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+
+	// maxRequestBytes keeps AppendRows requests below the 10MB limit.
+	maxRequestBytes = 9 << 20
+)
+
+// Type is the BigQuery type of a column.
+type Type int
+
+const (
+	// String columns hold strings. Values of other kinds are rendered as
+	// JSON.
+	String Type = iota
+	// Int64 columns hold integers. Durations are stored as nanoseconds.
+	Int64
+	// Float64 columns hold FLOAT64 numbers.
+	Float64
+	// Bool columns hold booleans.
+	Bool
+	// Timestamp columns hold TIMESTAMP values.
+	Timestamp
+	// JSON columns hold any value, groups included, encoded as JSON.
+	JSON
+)
+
+func (t Type) proto() descriptorpb.FieldDescriptorProto_Type {
+	switch t {
+	case Int64, Timestamp:
+		return descriptorpb.FieldDescriptorProto_TYPE_INT64
+	case Float64:
+		return descriptorpb.FieldDescriptorProto_TYPE_DOUBLE
+	case Bool:
+		return descriptorpb.FieldDescriptorProto_TYPE_BOOL
+	}
+	return descriptorpb.FieldDescriptorProto_TYPE_STRING
+}
+
+// Column maps an attribute to a column of the table.
+type Column struct {
+	// Name is the name of the column.
+	Name string
+	// Key is the path of the attribute, with groups joined by ".", e.g.
+	// "http.status". slog.TimeKey, slog.LevelKey and slog.MessageKey select
+	// the time, level and message of the record. It defaults to Name.
+	Key  string
+	Type Type
+	// Required rejects records missing the attribute.
+	Required bool
+}
+
+// Columns returns the columns "time", "level" and "msg" holding the time,
+// level and message of records, followed by the given columns.
+func Columns(columns ...Column) []Column {
+	return append([]Column{
+		{Name: slog.TimeKey, Type: Timestamp, Required: true},
+		{Name: slog.LevelKey, Type: String, Required: true},
+		{Name: slog.MessageKey, Type: String},
+	}, columns...)
+}
+
+// SchemaError reports a record not matching the columns.
+type SchemaError struct {
+	Column string
+	Err    error
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("column %s: %s", e.Column, e.Err)
+}
+
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	errMissing  = errors.New("required value is missing")
+	errMismatch = errors.New("value does not match the column type")
+)
+
+// row is an encoded row, kept with its record for dead-lettering.
+type row struct {
+	data   []byte
+	record slog.Record
+}
+
+// batch accumulates rows. It is shared across WithAttrs and WithGroup
+// derivations and drained by a single delivery goroutine.
+type batch struct {
+	mutex   sync.Mutex
+	rows    []row
+	dropped uint64
+	closed  bool
+
+	sendLock chan struct{}      // Serializes appends, held while it holds a value
+	ctx      context.Context    // Context of the delivery goroutine
+	cancel   context.CancelFunc // Cancels ctx when Close gives up
+	kick     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stats    stats.Recorder
+}
+
+// Handler is a slog.Handler that batches records and appends them as rows
+// of a table with the AppendRows call of the BigQuery Storage Write API.
+// Attributes are mapped to columns as configured with WithColumns;
+// attributes not mapped to a column are dropped, or stored as JSON in the
+// column set with WithRest.
+//
+// Records not matching the columns, and rows rejected by BigQuery, are
+// passed to the dead-letter function set with WithDeadLetter, and the rest
+// of their batch is appended. Batches are appended when they reach the
+// batch size or after the batch wait, from a background goroutine. Failed
+// appends are retried with exponential backoff on transient gRPC errors;
+// batches still failing are dropped and reported to the error handler.
+// Close must be called to append the pending records and stop the
+// goroutine.
+type Handler struct {
+	scope  scope.Scope
+	conn   grpc.ClientConnInterface
+	stream string
+	schema []byte
+	config handlerOptions
+	batch  *batch
+}
+
+// NewHandler creates a handler appending to stream, e.g. the DefaultStream
+// of a table, through conn, and starts its delivery goroutine. conn must
+// be connected to Endpoint with transport and per-RPC credentials, such as
+// those of golang.org/x/oauth2/google.
+func NewHandler(conn grpc.ClientConnInterface, stream string, options ...Option) (*Handler, error) {
+	config := handlerOptions{
+		level:      slog.LevelInfo,
+		columns:    Columns(),
+		batchSize:  defaultBatchSize,
+		batchWait:  defaultBatchWait,
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	columns := config.columns
+	if config.rest != "" {
+		columns = append(columns[:len(columns):len(columns)], Column{Name: config.rest, Type: JSON})
+	}
+	schema, err := descriptor(columns)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &batch{
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		sendLock: make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	h := &Handler{conn: conn, stream: stream, schema: schema, config: config, batch: b}
+	go h.run()
+	return h, nil
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.config.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// Handle encodes the record as a row and adds it to the batch. Records not
+// matching the columns are passed to the dead-letter function, or reported
+// as a *SchemaError if there is none.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	h.batch.stats.Handled(1)
+	m := make(map[string]any, r.NumAttrs()+3)
+	slogging.PutAttrs(m, h.scope.Attrs(r)...)
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	m[slog.TimeKey] = t
+	m[slog.LevelKey] = r.Level
+	m[slog.MessageKey] = r.Message
+
+	data, err := h.encode(m)
+	if err != nil {
+		if h.config.deadLetter == nil {
+			return err
+		}
+		h.config.deadLetter(r, err)
+		return nil
+	}
+	return h.add(row{data: data, record: r.Clone()})
+}
+
+// encode encodes m, as returned by slogging.ToMap, as a row message.
+func (h *Handler) encode(m map[string]any) ([]byte, error) {
+	var b []byte
+	for i, c := range h.config.columns {
+		key := c.Key
+		if key == "" {
+			key = c.Name
+		}
+		v, ok := take(m, strings.Split(key, "."))
+		if !ok {
+			if c.Required {
+				return nil, &SchemaError{Column: c.Name, Err: errMissing}
+			}
+			continue
+		}
+		var err error
+		if b, err = appendValue(b, protowire.Number(i+1), c.Type, v); err != nil {
+			return nil, &SchemaError{Column: c.Name, Err: err}
+		}
+	}
+	if h.config.rest != "" {
+		if len(m) > 0 {
+			jsonvalue.Map(m)
+			v, err := json.Marshal(m)
+			if err != nil {
+				return nil, &SchemaError{Column: h.config.rest, Err: err}
+			}
+			b = protowire.AppendTag(b, protowire.Number(len(h.config.columns)+1), protowire.BytesType)
+			b = protowire.AppendBytes(b, v)
+		}
+	}
+	return b, nil
+}
+
+// take removes and returns the value at path, removing groups left empty.
+func take(m map[string]any, path []string) (any, bool) {
+	v, ok := m[path[0]]
+	if !ok {
+		return nil, false
+	}
+	if len(path) == 1 {
+		delete(m, path[0])
+		return v, true
+	}
+	inner, ok := v.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	v, ok = take(inner, path[1:])
+	if len(inner) == 0 {
+		delete(m, path[0])
+	}
+	return v, ok
+}
+
+func appendValue(b []byte, num protowire.Number, t Type, v any) ([]byte, error) {
+	switch t {
+	case Int64:
+		var n int64
+		switch v := v.(type) {
+		case int64:
+			n = v
+		case uint64:
+			if v > math.MaxInt64 {
+				return nil, errMismatch
+			}
+			n = int64(v)
+		case time.Duration:
+			n = int64(v)
+		default:
+			return nil, errMismatch
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(n)), nil
+	case Float64:
+		var f float64
+		switch v := v.(type) {
+		case float64:
+			f = v
+		case int64:
+			f = float64(v)
+		case uint64:
+			f = float64(v)
+		default:
+			return nil, errMismatch
+		}
+		b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+		return protowire.AppendFixed64(b, math.Float64bits(f)), nil
+	case Bool:
+		x, ok := v.(bool)
+		if !ok {
+			return nil, errMismatch
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, protowire.EncodeBool(x)), nil
+	case Timestamp:
+		x, ok := v.(time.Time)
+		if !ok {
+			return nil, errMismatch
+		}
+		b = protowire.AppendTag(b, num, protowire.VarintType)
+		return protowire.AppendVarint(b, uint64(x.UnixMicro())), nil
+	case JSON:
+		x, err := json.Marshal(jsonvalue.Convert(v))
+		if err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		return protowire.AppendBytes(b, x), nil
+	}
+	var s string
+	switch v := v.(type) {
+	case string:
+		s = v
+	case fmt.Stringer:
+		s = v.String()
+	case error:
+		s = v.Error()
+	default:
+		x, err := json.Marshal(jsonvalue.Convert(v))
+		if err != nil {
+			return nil, err
+		}
+		s = string(x)
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s), nil
+}
+
+func (h *Handler) add(r row) error {
+	b := h.batch
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return ErrClosed
+	}
+	b.rows = append(b.rows, r)
+	full := len(b.rows) >= h.config.batchSize
+	b.mutex.Unlock()
+	if full {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (h *Handler) run() {
+	b := h.batch
+	defer close(b.done)
+	ticker := time.NewTicker(h.config.batchWait)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.kick:
+		case <-b.stop:
+			return
+		}
+		h.push(b.ctx)
+	}
+}
+
+// take removes and returns the pending rows.
+func (h *Handler) take() []row {
+	b := h.batch
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	rows := b.rows
+	b.rows = nil
+	return rows
+}
+
+// push appends the pending rows, retrying with backoff until ctx is done.
+func (h *Handler) push(ctx context.Context) error {
+	b := h.batch
+	select {
+	case b.sendLock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-b.sendLock }()
+	rows := h.take()
+	var errs []error
+	for len(rows) > 0 {
+		n, size := 0, 0
+		for n < len(rows) && (n == 0 || size+len(rows[n].data) <= maxRequestBytes) {
+			size += len(rows[n].data)
+			n++
+		}
+		if err := h.send(ctx, rows[:n]); err != nil {
+			b.mutex.Lock()
+			b.dropped += uint64(n)
+			b.mutex.Unlock()
+			h.batch.stats.Error(err)
+			if h.config.onError != nil {
+				h.config.onError(err)
+			}
+			errs = append(errs, err)
+		}
+		rows = rows[n:]
+	}
+	return errors.Join(errs...)
+}
+
+// send appends rows. Rows rejected by BigQuery are dead-lettered and the
+// others appended again right away; transient errors are retried with
+// backoff.
+func (h *Handler) send(ctx context.Context, rows []row) error {
+	backoff := h.config.minBackoff
+	for attempt := 0; ; attempt++ {
+		resp, err := h.appendRows(ctx, rows)
+		if err == nil && len(resp.rowErrors) > 0 {
+			rows = h.reject(rows, resp.rowErrors)
+			if len(rows) == 0 {
+				return nil
+			}
+			continue
+		}
+		if err == nil && resp.status != nil && resp.status.Code() != 0 {
+			err = fmt.Errorf("error when appending rows: %w", resp.status.Err())
+		}
+		if err == nil {
+			return nil
+		}
+		if !retryable(err) || attempt >= h.config.maxRetries {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", err, ctx.Err())
+		}
+		backoff = min(2*backoff, h.config.maxBackoff)
+	}
+}
+
+// reject dead-letters the rows of rowErrors and returns the others.
+func (h *Handler) reject(rows []row, rowErrors []*RowError) []row {
+	rejected := make(map[int64]*RowError, len(rowErrors))
+	for _, re := range rowErrors {
+		rejected[re.Index] = re
+	}
+	kept := make([]row, 0, len(rows))
+	for i, r := range rows {
+		re, ok := rejected[int64(i)]
+		if !ok {
+			kept = append(kept, r)
+			continue
+		}
+		if h.config.deadLetter != nil {
+			h.config.deadLetter(r.record, re)
+		} else {
+			h.batch.mutex.Lock()
+			h.batch.dropped++
+			h.batch.mutex.Unlock()
+			h.batch.stats.Error(re)
+			if h.config.onError != nil {
+				h.config.onError(re)
+			}
+		}
+	}
+	return kept
+}
+
+// Flush appends the pending records, retrying until ctx is done.
+func (h *Handler) Flush(ctx context.Context) error {
+	return h.push(ctx)
+}
+
+// Close stops accepting records, appends the pending ones, retrying until
+// ctx is done, and stops the delivery goroutine. It does not close the
+// connection.
+// When ctx is done first, the delivery in progress is cancelled, the
+// pending records are abandoned and Close returns ctx.Err().
+func (h *Handler) Close(ctx context.Context) error {
+	b := h.batch
+	b.mutex.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.stop)
+	}
+	b.mutex.Unlock()
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+	return h.push(ctx)
+}
+
+// Dropped returns the number of records dropped after failed appends, and
+// rejected rows when there is no dead-letter function.
+func (h *Handler) Dropped() uint64 {
+	h.batch.mutex.Lock()
+	defer h.batch.mutex.Unlock()
+	return h.batch.dropped
+}
+
+// Stats returns the counters of the handler. The queue depth is the number
+// of records waiting for the next delivery.
+func (h *Handler) Stats() slogging.Stats {
+	b := h.batch
+	b.mutex.Lock()
+	dropped, depth := b.dropped, len(b.rows)
+	b.mutex.Unlock()
+	return b.stats.Stats(dropped, depth)
+}
+
+type handlerOptions struct {
+	level      slog.Leveler
+	columns    []Column
+	rest       string
+	batchSize  int
+	batchWait  time.Duration
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	deadLetter func(slog.Record, error)
+	onError    func(error)
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithColumns sets the columns of the table, Columns() by default. Their
+// names and types must match the schema of the table.
+func WithColumns(columns ...Column) Option {
+	return func(h *handlerOptions) {
+		h.columns = columns
+	}
+}
+
+// WithRest sets the JSON column holding the attributes not mapped to a
+// column. By default they are dropped.
+func WithRest(name string) Option {
+	return func(h *handlerOptions) {
+		h.rest = name
+	}
+}
+
+// WithDeadLetter sets a function called with records not matching the
+// columns, with a *SchemaError, and with records of rows rejected by
+// BigQuery, with a *RowError. It is called from Handle, or from the
+// delivery goroutine, Flush and Close.
+func WithDeadLetter(fn func(r slog.Record, err error)) Option {
+	return func(h *handlerOptions) {
+		h.deadLetter = fn
+	}
+}
+
+// WithBatch sets the number of records triggering an append, 500 by
+// default, and the maximum time records wait for an append, one second by
+// default.
+func WithBatch(size int, wait time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.batchSize = max(size, 1)
+		h.batchWait = wait
+	}
+}
+
+// WithRetry sets the number of retries of a failed append, 5 by default,
+// and the bounds of the exponential backoff between them, 500ms and 30s by
+// default.
+func WithRetry(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.maxRetries = maxRetries
+		h.minBackoff = minBackoff
+		h.maxBackoff = maxBackoff
+	}
+}
+
+// WithErrorHandler sets a function called with append errors, after
+// retries are exhausted. It is called from the delivery goroutine, or from
+// Flush and Close.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
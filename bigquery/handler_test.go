@@ -0,0 +1,235 @@
+package bigquery
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// request is a decoded AppendRowsRequest.
+type request struct {
+	stream string
+	params string
+	schema *descriptorpb.DescriptorProto
+	rows   []map[protowire.Number]any
+}
+
+type server struct {
+	mutex     sync.Mutex
+	requests  []request
+	responses []func(req request) ([]byte, error) // Scripted, then success
+}
+
+func (s *server) handle(_ any, stream grpc.ServerStream) error {
+	var b []byte
+	if err := stream.RecvMsg(&b); err != nil {
+		return err
+	}
+	req := request{}
+	if md, ok := metadata.FromIncomingContext(stream.Context()); ok {
+		req.params = md.Get("x-goog-request-params")[0]
+	}
+	_ = fields(b, func(num protowire.Number, v []byte, _ uint64) {
+		switch num {
+		case 1:
+			req.stream = string(v)
+		case 4:
+			_ = fields(v, func(num protowire.Number, v []byte, _ uint64) {
+				switch num {
+				case 1:
+					_ = fields(v, func(_ protowire.Number, v []byte, _ uint64) {
+						req.schema = new(descriptorpb.DescriptorProto)
+						_ = proto.Unmarshal(v, req.schema)
+					})
+				case 2:
+					_ = fields(v, func(_ protowire.Number, v []byte, _ uint64) {
+						row := map[protowire.Number]any{}
+						_ = fields(v, func(num protowire.Number, v []byte, n uint64) {
+							if v != nil {
+								row[num] = string(v)
+							} else {
+								row[num] = n
+							}
+						})
+						req.rows = append(req.rows, row)
+					})
+				}
+			})
+		}
+	})
+
+	s.mutex.Lock()
+	s.requests = append(s.requests, req)
+	var respond func(request) ([]byte, error)
+	if len(s.responses) > 0 {
+		respond, s.responses = s.responses[0], s.responses[1:]
+	}
+	s.mutex.Unlock()
+	resp := []byte{}
+	if respond != nil {
+		var err error
+		if resp, err = respond(req); err != nil {
+			return err
+		}
+	}
+	return stream.SendMsg(&resp)
+}
+
+func rowErrorResponse(index int64) []byte {
+	var re []byte
+	re = protowire.AppendTag(re, 1, protowire.VarintType)
+	re = protowire.AppendVarint(re, uint64(index))
+	re = protowire.AppendTag(re, 3, protowire.BytesType)
+	re = protowire.AppendString(re, "invalid value")
+	var b []byte
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	return protowire.AppendBytes(b, re)
+}
+
+func setup(t *testing.T, responses ...func(request) ([]byte, error)) (*server, *grpc.ClientConn) {
+	t.Helper()
+	s := &server{responses: responses}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(grpc.UnknownServiceHandler(s.handle), grpc.ForceServerCodec(rawCodec{}))
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return s, conn
+}
+
+func Test_Handler(t *testing.T) {
+	stream := DefaultStream("p", "d", "logs")
+	columns := Columns(Column{Name: "status", Key: "http.status", Type: Int64, Required: true}, Column{Name: "ok", Type: Bool})
+
+	t.Run("records are appended as rows", func(t *testing.T) {
+		s, conn := setup(t)
+		h, err := NewHandler(conn, stream, WithColumns(columns...), WithRest("attrs"), WithBatch(10, time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		slog.New(h).With("app", "x").Info("hello", slog.Group("http", "status", 200, "path", "/", "err", errors.New("boom")), "ok", true)
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(s.requests) != 1 {
+			t.Fatalf("expected one request, got %d", len(s.requests))
+		}
+		req := s.requests[0]
+		if req.stream != stream || req.params != "write_stream=projects%2Fp%2Fdatasets%2Fd%2Ftables%2Flogs%2Fstreams%2F_default" {
+			t.Errorf("unexpected stream: %s, %s", req.stream, req.params)
+		}
+		if f := req.schema.GetField(); len(f) != 6 || f[3].GetName() != "status" || f[3].GetType() != descriptorpb.FieldDescriptorProto_TYPE_INT64 || f[5].GetName() != "attrs" {
+			t.Errorf("unexpected schema: %v", req.schema)
+		}
+		row := req.rows[0]
+		if row[2] != "INFO" || row[3] != "hello" || row[4] != uint64(200) || row[5] != uint64(1) || row[6] != `{"app":"x","http":{"err":"boom","path":"/"}}` {
+			t.Errorf("unexpected row: %v", row)
+		}
+	})
+
+	t.Run("records not matching the columns are dead-lettered", func(t *testing.T) {
+		_, conn := setup(t)
+		var dead []error
+		h, _ := NewHandler(conn, stream, WithColumns(columns...), WithDeadLetter(func(r slog.Record, err error) {
+			dead = append(dead, err)
+		}))
+		defer h.Close(context.Background())
+		logger := slog.New(h)
+		logger.Info("missing")
+		logger.Info("mismatch", slog.Group("http", "status", "200"))
+		var se *SchemaError
+		if len(dead) != 2 || !errors.As(dead[0], &se) || se.Column != "status" || !errors.Is(dead[1], errMismatch) {
+			t.Errorf("unexpected dead letters: %v", dead)
+		}
+
+		h2, _ := NewHandler(conn, stream, WithColumns(columns...))
+		defer h2.Close(context.Background())
+		if err := h2.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "missing", 0)); !errors.As(err, &se) {
+			t.Errorf("expected a schema error, got %v", err)
+		}
+	})
+
+	t.Run("rejected rows are dead-lettered and the others appended", func(t *testing.T) {
+		s, conn := setup(t, func(request) ([]byte, error) { return rowErrorResponse(0), nil })
+		var dead []string
+		h, _ := NewHandler(conn, stream, WithBatch(10, time.Hour), WithDeadLetter(func(r slog.Record, err error) {
+			var re *RowError
+			if errors.As(err, &re) {
+				dead = append(dead, r.Message)
+			}
+		}))
+		logger := slog.New(h)
+		logger.Info("bad")
+		logger.Info("good")
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(dead) != 1 || dead[0] != "bad" || len(s.requests) != 2 || len(s.requests[1].rows) != 1 || s.requests[1].rows[0][3] != "good" {
+			t.Errorf("unexpected outcome: dead %v, requests %v", dead, s.requests)
+		}
+	})
+
+	t.Run("transient errors are retried", func(t *testing.T) {
+		s, conn := setup(t,
+			func(request) ([]byte, error) { return nil, status.Error(codes.Unavailable, "down") },
+			func(request) ([]byte, error) { return nil, status.Error(codes.InvalidArgument, "bad") },
+		)
+		var reported error
+		h, _ := NewHandler(conn, stream, WithBatch(10, time.Hour), WithRetry(3, time.Millisecond, time.Millisecond),
+			WithErrorHandler(func(err error) { reported = err }))
+		slog.New(h).Info("lost")
+		if err := h.Close(context.Background()); status.Code(err) != codes.InvalidArgument || reported == nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if len(s.requests) != 2 || h.Dropped() != 1 {
+			t.Errorf("unexpected outcome: %d requests, %d dropped", len(s.requests), h.Dropped())
+		}
+	})
+
+	t.Run("close gives up when the context is done", func(t *testing.T) {
+		unavailable := func(request) ([]byte, error) { return nil, status.Error(codes.Unavailable, "down") }
+		s, conn := setup(t, unavailable, unavailable, unavailable, unavailable, unavailable, unavailable)
+		h, _ := NewHandler(conn, stream, WithBatch(1, time.Hour), WithRetry(5, time.Second, 2*time.Second))
+		slog.New(h).Info("stuck")
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			s.mutex.Lock()
+			n := len(s.requests)
+			s.mutex.Unlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if err := h.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("close took %s", d)
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package bigquery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const appendRowsMethod = "/google.cloud.bigquery.storage.v1.BigQueryWrite/AppendRows"
+
+// Endpoint is the address of the BigQuery Storage Write API.
+const Endpoint = "bigquerystorage.googleapis.com:443"
+
+// DefaultStream returns the name of the default write stream of a table,
+// which commits appended rows immediately.
+func DefaultStream(project, dataset, table string) string {
+	return fmt.Sprintf("projects/%s/datasets/%s/tables/%s/streams/_default", project, dataset, table)
+}
+
+// rawCodec passes already encoded messages through, so that requests are
+// encoded without the generated BigQuery Storage types.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v any) ([]byte, error) {
+	return *v.(*[]byte), nil
+}
+
+func (rawCodec) Unmarshal(data []byte, v any) error {
+	*v.(*[]byte) = append([]byte(nil), data...)
+	return nil
+}
+
+func (rawCodec) Name() string {
+	return "proto"
+}
+
+// descriptor returns the encoded DescriptorProto of the row message.
+func descriptor(columns []Column) ([]byte, error) {
+	d := &descriptorpb.DescriptorProto{Name: proto.String("Row")}
+	for i, c := range columns {
+		d.Field = append(d.Field, &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(c.Name),
+			Number: proto.Int32(int32(i + 1)),
+			Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:   c.Type.proto().Enum(),
+		})
+	}
+	b, err := proto.Marshal(d)
+	if err != nil {
+		return nil, fmt.Errorf("error when marshaling row descriptor: %w", err)
+	}
+	return b, nil
+}
+
+// appendRequest encodes an AppendRowsRequest of rows to stream.
+func appendRequest(stream string, schema []byte, rows []row) []byte {
+	var data, serialized []byte
+	for _, r := range rows {
+		serialized = protowire.AppendTag(serialized, 1, protowire.BytesType)
+		serialized = protowire.AppendBytes(serialized, r.data)
+	}
+	var writerSchema []byte
+	writerSchema = protowire.AppendTag(writerSchema, 1, protowire.BytesType)
+	writerSchema = protowire.AppendBytes(writerSchema, schema)
+	data = protowire.AppendTag(data, 1, protowire.BytesType)
+	data = protowire.AppendBytes(data, writerSchema)
+	data = protowire.AppendTag(data, 2, protowire.BytesType)
+	data = protowire.AppendBytes(data, serialized)
+
+	var b []byte
+	b = protowire.AppendTag(b, 1, protowire.BytesType)
+	b = protowire.AppendString(b, stream)
+	b = protowire.AppendTag(b, 4, protowire.BytesType)
+	b = protowire.AppendBytes(b, data)
+	b = protowire.AppendTag(b, 6, protowire.BytesType)
+	b = protowire.AppendString(b, "slogging")
+	return b
+}
+
+// RowError reports a row rejected by BigQuery.
+type RowError struct {
+	Index   int64
+	Code    int32
+	Message string
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d rejected: %s", e.Index, e.Message)
+}
+
+// appendResponse holds the parts of an AppendRowsResponse the handler
+// acts on.
+type appendResponse struct {
+	status    *status.Status
+	rowErrors []*RowError
+}
+
+func parseResponse(b []byte) (appendResponse, error) {
+	var resp appendResponse
+	err := fields(b, func(num protowire.Number, v []byte, _ uint64) {
+		switch num {
+		case 2: // error
+			var code int32
+			var msg string
+			_ = fields(v, func(num protowire.Number, v []byte, n uint64) {
+				switch num {
+				case 1:
+					code = int32(n)
+				case 2:
+					msg = string(v)
+				}
+			})
+			resp.status = status.New(codes.Code(code), msg)
+		case 4: // row_errors
+			re := new(RowError)
+			_ = fields(v, func(num protowire.Number, v []byte, n uint64) {
+				switch num {
+				case 1:
+					re.Index = int64(n)
+				case 2:
+					re.Code = int32(n)
+				case 3:
+					re.Message = string(v)
+				}
+			})
+			resp.rowErrors = append(resp.rowErrors, re)
+		}
+	})
+	return resp, err
+}
+
+// fields calls fn with the number and the value of each varint and
+// length-delimited field of the message b, skipping other types.
+func fields(b []byte, fn func(num protowire.Number, v []byte, n uint64)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		switch typ {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, nil, v)
+			b = b[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			fn(num, v, 0)
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
+
+// appendRows sends rows in one AppendRows call on a dedicated stream.
+func (h *Handler) appendRows(ctx context.Context, rows []row) (appendResponse, error) {
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-goog-request-params", "write_stream="+url.QueryEscape(h.stream))
+	stream, err := h.conn.NewStream(ctx, &grpc.StreamDesc{StreamName: "AppendRows", ServerStreams: true, ClientStreams: true},
+		appendRowsMethod, grpc.ForceCodec(rawCodec{}))
+	if err != nil {
+		return appendResponse{}, fmt.Errorf("error when opening AppendRows stream: %w", err)
+	}
+	req := appendRequest(h.stream, h.schema, rows)
+	if err := stream.SendMsg(&req); err != nil {
+		return appendResponse{}, fmt.Errorf("error when sending AppendRows request: %w", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		return appendResponse{}, fmt.Errorf("error when closing AppendRows stream: %w", err)
+	}
+	var b []byte
+	if err := stream.RecvMsg(&b); err != nil {
+		return appendResponse{}, fmt.Errorf("error when receiving AppendRows response: %w", err)
+	}
+	resp, err := parseResponse(b)
+	if err != nil {
+		return appendResponse{}, fmt.Errorf("error when parsing AppendRows response: %w", err)
+	}
+	return resp, nil
+}
+
+// retryable reports whether a failed append may succeed later.
+func retryable(err error) bool {
+	var se interface{ GRPCStatus() *status.Status }
+	if !errors.As(err, &se) {
+		return true
+	}
+	switch se.GRPCStatus().Code() {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+// Package buffered provides an io.Writer buffering the output of handlers
+// to reduce the number of system calls in high-throughput programs, and a
+// slog.Handler wrapper writing records at or above a level through the
+// buffer immediately.
+package buffered
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSize     = 64 << 10
+	defaultInterval = 500 * time.Millisecond
+)
+
+// ErrClosed is returned by Write after the writer has been closed.
+var ErrClosed = errors.New("slogging: buffered writer is closed")
+
+// Writer is an io.Writer buffering writes to the underlying writer, which
+// receives them when the buffer is full, every flush interval, and on
+// Flush and Close. Writes larger than the buffer go to the underlying
+// writer directly. As with bufio.Writer, once writing to the underlying
+// writer fails, the error is returned by every later call. Writer is safe
+// for concurrent use.
+type Writer struct {
+	mutex   sync.Mutex
+	buf     *bufio.Writer
+	writer  io.Writer
+	closed  bool
+	onError func(error)
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// NewWriter returns a Writer buffering writes to w, and starts the
+// goroutine flushing it periodically. Close must be called to stop it.
+func NewWriter(w io.Writer, options ...Option) *Writer {
+	config := defaultOptions()
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	bw := &Writer{
+		buf:     bufio.NewWriterSize(w, config.size),
+		writer:  w,
+		onError: config.onError,
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if config.interval > 0 {
+		go bw.run(config.interval)
+	} else {
+		close(bw.done)
+	}
+	return bw
+}
+
+func (w *Writer) run(interval time.Duration) {
+	defer close(w.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if err := w.Flush(); err != nil && !errors.Is(err, ErrClosed) && w.onError != nil {
+				w.onError(err)
+			}
+		}
+	}
+}
+
+// Write buffers p.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	return w.buf.Write(p)
+}
+
+// Buffered returns the number of bytes waiting in the buffer.
+func (w *Writer) Buffered() int {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.buf.Buffered()
+}
+
+// Flush writes the buffered data to the underlying writer.
+func (w *Writer) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	return w.buf.Flush()
+}
+
+// Close stops the periodic flushes, flushes the buffer and closes the
+// underlying writer if it implements io.Closer.
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	err := w.buf.Flush()
+	if c, ok := w.writer.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	w.mutex.Unlock()
+	select {
+	case <-w.stop:
+	default:
+		close(w.stop)
+	}
+	<-w.done
+	return err
+}
+
+// Handler is a slog.Handler delivering records to a handler writing to a
+// Writer, and flushing the Writer after records at or above the bypass
+// level, slog.LevelError by default. Such records, and everything written
+// before them, reach the underlying writer before Handle returns, so that
+// errors are not lost if the program crashes before the next flush.
+type Handler struct {
+	handler slog.Handler
+	writer  *Writer
+	bypass  slog.Leveler
+}
+
+// Wrap creates a handler delivering to handler, which writes to w.
+func Wrap(handler slog.Handler, w *Writer, options ...Option) *Handler {
+	config := defaultOptions()
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{handler: handler, writer: w, bypass: config.bypass}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle delivers the record, then flushes the Writer if the record is at
+// or above the bypass level.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if err := h.handler.Handle(ctx, r); err != nil {
+		return err
+	}
+	if r.Level < h.bypass.Level() {
+		return nil
+	}
+	return h.writer.Flush()
+}
+
+// WithAttrs returns a new Handler sharing the Writer whose wrapped handler
+// includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler sharing the Writer whose wrapped handler
+// starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	return &h2
+}
+
+// Flush flushes the Writer.
+func (h *Handler) Flush() error {
+	return h.writer.Flush()
+}
+
+// Close closes the Writer.
+func (h *Handler) Close(context.Context) error {
+	return h.writer.Close()
+}
+
+type handlerOptions struct {
+	size     int
+	interval time.Duration
+	bypass   slog.Leveler
+	onError  func(error)
+}
+
+func defaultOptions() handlerOptions {
+	return handlerOptions{
+		size:     defaultSize,
+		interval: defaultInterval,
+		bypass:   slog.LevelError,
+	}
+}
+
+// Option is a function that configures a Writer or a Handler.
+type Option func(h *handlerOptions)
+
+// WithSize sets the size of the buffer of a Writer in bytes. Defaults to
+// 64 KiB.
+func WithSize(n int) Option {
+	return func(h *handlerOptions) {
+		h.size = n
+	}
+}
+
+// WithInterval sets how often a Writer is flushed. Values below 1 disable
+// periodic flushes. Defaults to 500 milliseconds.
+func WithInterval(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.interval = d
+	}
+}
+
+// WithBypassLevel sets the level at and above which a Handler flushes the
+// Writer after each record. Defaults to slog.LevelError.
+func WithBypassLevel(level slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.bypass = level
+	}
+}
+
+// WithErrorHandler sets a function called with the errors of periodic
+// flushes of a Writer. It is called from the flushing goroutine.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
@@ -0,0 +1,150 @@
+package buffered
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type sink struct {
+	mutex  sync.Mutex
+	buf    bytes.Buffer
+	writes int
+	closed bool
+}
+
+func (s *sink) Write(p []byte) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.writes++
+	return s.buf.Write(p)
+}
+
+func (s *sink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *sink) String() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.buf.String()
+}
+
+func TestWriter(t *testing.T) {
+	t.Run("buffers until flush", func(t *testing.T) {
+		s := &sink{}
+		w := NewWriter(s, WithInterval(0))
+		defer w.Close()
+		for range 10 {
+			if _, err := w.Write([]byte("line\n")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if s.String() != "" {
+			t.Fatalf("written before flush: %q", s.String())
+		}
+		if got := w.Buffered(); got != 50 {
+			t.Errorf("buffered = %d, want 50", got)
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if s.writes != 1 || s.String() != strings.Repeat("line\n", 10) {
+			t.Errorf("writes = %d, output = %q", s.writes, s.String())
+		}
+	})
+
+	t.Run("flushes when full", func(t *testing.T) {
+		s := &sink{}
+		w := NewWriter(s, WithSize(8), WithInterval(0))
+		defer w.Close()
+		w.Write([]byte("12345"))
+		w.Write([]byte("67890"))
+		if got := s.String(); got != "12345678" {
+			t.Errorf("output = %q, want %q", got, "12345678")
+		}
+	})
+
+	t.Run("flushes periodically", func(t *testing.T) {
+		s := &sink{}
+		w := NewWriter(s, WithInterval(10*time.Millisecond))
+		defer w.Close()
+		w.Write([]byte("tick\n"))
+		deadline := time.Now().Add(time.Second)
+		for s.String() == "" && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if s.String() != "tick\n" {
+			t.Errorf("output = %q", s.String())
+		}
+	})
+
+	t.Run("close flushes and closes", func(t *testing.T) {
+		s := &sink{}
+		w := NewWriter(s)
+		w.Write([]byte("last\n"))
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if s.String() != "last\n" || !s.closed {
+			t.Errorf("output = %q, closed = %v", s.String(), s.closed)
+		}
+		if _, err := w.Write([]byte("late\n")); !errors.Is(err, ErrClosed) {
+			t.Errorf("write after close: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Errorf("second close: %v", err)
+		}
+	})
+}
+
+func TestHandler(t *testing.T) {
+	t.Run("errors bypass the buffer", func(t *testing.T) {
+		s := &sink{}
+		w := NewWriter(s, WithInterval(0))
+		logger := slog.New(Wrap(slog.NewTextHandler(w, nil), w))
+		logger.Info("first")
+		if s.String() != "" {
+			t.Fatalf("info written through: %q", s.String())
+		}
+		logger.Error("failed")
+		out := s.String()
+		if !strings.Contains(out, "msg=first") || !strings.Contains(out, "msg=failed") {
+			t.Errorf("output = %q", out)
+		}
+		if strings.Index(out, "msg=first") > strings.Index(out, "msg=failed") {
+			t.Errorf("records out of order: %q", out)
+		}
+	})
+
+	t.Run("bypass level", func(t *testing.T) {
+		s := &sink{}
+		w := NewWriter(s, WithInterval(0))
+		logger := slog.New(Wrap(slog.NewTextHandler(w, nil), w, WithBypassLevel(slog.LevelWarn)))
+		logger.With("a", 1).WithGroup("g").Warn("careful", "b", 2)
+		if !strings.Contains(s.String(), "msg=careful a=1 g.b=2") {
+			t.Errorf("output = %q", s.String())
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		s := &sink{}
+		w := NewWriter(s)
+		h := Wrap(slog.NewTextHandler(w, nil), w)
+		slog.New(h).Info("pending")
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(s.String(), "msg=pending") || !s.closed {
+			t.Errorf("output = %q, closed = %v", s.String(), s.closed)
+		}
+	})
+}
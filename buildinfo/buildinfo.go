@@ -0,0 +1,172 @@
+// Package buildinfo stamps records with the build information of the
+// binary and a hash of the effective logging configuration, so that
+// instances running different builds or configurations can be told apart
+// in fleet-wide queries.
+package buildinfo
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+// Key is the key of the group holding the build information.
+const Key = "build"
+
+const defaultEvery = 1000
+
+// ConfigHash returns a short, stable hash of the JSON encoding of config.
+// Map keys are sorted by the encoding, so equal configurations hash equally
+// regardless of how they were assembled.
+func ConfigHash(config any) (string, error) {
+	b, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("error when encoding config: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
+// Attr returns the "build" group: the Go version, the main module path and
+// version, the VCS revision, commit time and modified flag when the binary
+// was built with VCS stamping, and the config hash set with WithConfig.
+func Attr(options ...Option) slog.Attr {
+	config := newOptions(options)
+	bi, _ := debug.ReadBuildInfo()
+	return attr(bi, config.configHash)
+}
+
+func attr(bi *debug.BuildInfo, configHash string) slog.Attr {
+	var attrs []slog.Attr
+	if bi != nil {
+		attrs = append(attrs, slog.String("go_version", bi.GoVersion))
+		if bi.Main.Path != "" {
+			attrs = append(attrs, slog.String("path", bi.Main.Path), slog.String("version", bi.Main.Version))
+		}
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				attrs = append(attrs, slog.String("revision", s.Value))
+			case "vcs.time":
+				attrs = append(attrs, slog.String("vcs_time", s.Value))
+			case "vcs.modified":
+				attrs = append(attrs, slog.Bool("modified", s.Value == "true"))
+			}
+		}
+	}
+	if configHash != "" {
+		attrs = append(attrs, slog.String("config_hash", configHash))
+	}
+	return slog.Attr{Key: Key, Value: slog.GroupValue(attrs...)}
+}
+
+// Startup logs an Info "logger started" record carrying the "build" group.
+// It is meant to be called once, right after the logger is set up.
+func Startup(ctx context.Context, logger *slog.Logger, options ...Option) {
+	logger.LogAttrs(ctx, slog.LevelInfo, "logger started", Attr(options...))
+}
+
+// Handler is a slog.Handler adding the "build" group to every Nth record,
+// counting across WithAttrs and WithGroup derivations, so that drift can be
+// detected without relying on startup records being retained.
+type Handler struct {
+	handler slog.Handler
+	attr    slog.Attr
+	every   uint64
+	count   *atomic.Uint64
+}
+
+// Wrap creates a handler adding the "build" group to the first record and
+// every Nth record after it, see WithEvery, before passing them to handler.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := newOptions(options)
+	bi, _ := debug.ReadBuildInfo()
+	return &Handler{
+		handler: handler,
+		attr:    attr(bi, config.configHash),
+		every:   config.every,
+		count:   new(atomic.Uint64),
+	}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle adds the "build" group to every Nth record and passes the record
+// to the wrapped handler. Like any record attribute, the group is nested in
+// the groups opened with WithGroup.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if (h.count.Add(1)-1)%h.every == 0 {
+		r = r.Clone()
+		r.AddAttrs(h.attr)
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &Handler{handler: h.handler.WithAttrs(attrs), attr: h.attr, every: h.every, count: h.count}
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{handler: h.handler.WithGroup(name), attr: h.attr, every: h.every, count: h.count}
+}
+
+type handlerOptions struct {
+	configHash string
+	every      uint64
+}
+
+func newOptions(options []Option) handlerOptions {
+	config := handlerOptions{every: defaultEvery}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return config
+}
+
+// Option is a function that configures Attr, Startup and Handler.
+type Option func(h *handlerOptions)
+
+// WithConfig adds the ConfigHash of the effective logging configuration as
+// "config_hash" to the "build" group. It panics if config cannot be
+// encoded as JSON.
+func WithConfig(config any) Option {
+	hash, err := ConfigHash(config)
+	if err != nil {
+		panic("slogging: " + err.Error())
+	}
+	return WithConfigHash(hash)
+}
+
+// WithConfigHash sets the "config_hash" of the "build" group to a hash
+// computed by other means.
+func WithConfigHash(hash string) Option {
+	return func(h *handlerOptions) {
+		h.configHash = hash
+	}
+}
+
+// WithEvery sets N, the interval at which Handler stamps records, 1000 by
+// default. Values below 1 are treated as 1.
+func WithEvery(n int) Option {
+	return func(h *handlerOptions) {
+		h.every = uint64(max(n, 1))
+	}
+}
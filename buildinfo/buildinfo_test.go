@@ -0,0 +1,71 @@
+package buildinfo
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func Test_ConfigHash(t *testing.T) {
+	a, err := ConfigHash(map[string]any{"level": "info", "sinks": []string{"stderr"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ConfigHash(map[string]any{"sinks": []string{"stderr"}, "level": "info"})
+	c, _ := ConfigHash(map[string]any{"level": "debug", "sinks": []string{"stderr"}})
+	if a != b || a == c || len(a) != 16 {
+		t.Errorf("unexpected hashes: %s, %s, %s", a, b, c)
+	}
+
+	if _, err := ConfigHash(func() {}); err == nil {
+		t.Errorf("expected error for unencodable config")
+	}
+}
+
+func Test_Attr(t *testing.T) {
+	bi := &debug.BuildInfo{
+		GoVersion: "go1.24.4",
+		Main:      debug.Module{Path: "example.com/app", Version: "v1.2.3"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2025-01-02T03:04:05Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+	buf := new(bytes.Buffer)
+	slog.New(slog.NewTextHandler(buf, nil)).Info("test", attr(bi, "0011"))
+
+	want := "build.go_version=go1.24.4 build.path=example.com/app build.version=v1.2.3 build.revision=abc123 build.vcs_time=2025-01-02T03:04:05Z build.modified=true build.config_hash=0011"
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("expected %s, got: %s", want, buf.String())
+	}
+}
+
+func Test_Startup(t *testing.T) {
+	buf := new(bytes.Buffer)
+	Startup(context.Background(), slog.New(slog.NewTextHandler(buf, nil)), WithConfigHash("0011"))
+
+	if !strings.Contains(buf.String(), `msg="logger started" build.go_version=`) || !strings.Contains(buf.String(), "build.config_hash=0011") {
+		t.Errorf("unexpected startup record: %s", buf.String())
+	}
+}
+
+func Test_Handler(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), WithConfig(map[string]string{"level": "info"}), WithEvery(2)))
+
+	logger.Info("first")
+	logger.With("k", "v").Info("second")
+	logger.WithGroup("g").Info("third")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 records, got: %s", buf.String())
+	}
+	if !strings.Contains(lines[0], "build.config_hash=") || strings.Contains(lines[1], "build.") || !strings.Contains(lines[2], "g.build.config_hash=") {
+		t.Errorf("expected every 2nd record to be stamped, got: %s", buf.String())
+	}
+}
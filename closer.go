@@ -0,0 +1,67 @@
+package slogging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Closer is implemented by handlers that buffer, batch or ship records
+// over the network, such as async, loki, cloudwatch, kafka, sentry and
+// alert handlers. Close delivers the pending records and releases the
+// resources of the handler, or gives up when ctx is done.
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// CloseAll closes targets one after another, in the given order, within
+// the deadline of ctx, and is meant to be deferred in main with a context
+// bounding the shutdown. Targets should be given outermost first, so that
+// a wrapper such as async.Handler delivers its queue to the handlers it
+// wraps before they are closed.
+//
+// Besides Closer, targets may implement Close() error, or only flush with
+// Flush(context.Context) error, Flush() error or Flush(), as rotate,
+// syslog, spool and dedup do; other values are ignored. Methods without a
+// context run in their own goroutine, and are abandoned when ctx is done.
+// Once ctx is done, the remaining targets are not closed. The errors are
+// joined with the type of the target that returned them.
+func CloseAll(ctx context.Context, targets ...any) error {
+	var errs []error
+	for i, t := range targets {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("error when closing %d remaining targets: %w", len(targets)-i, err))
+			break
+		}
+		if err := closeOne(ctx, t); err != nil {
+			errs = append(errs, fmt.Errorf("error when closing %T: %w", t, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func closeOne(ctx context.Context, t any) error {
+	var fn func() error
+	switch f := t.(type) {
+	case Closer:
+		return f.Close(ctx)
+	case interface{ Flush(context.Context) error }:
+		return f.Flush(ctx)
+	case interface{ Close() error }:
+		fn = f.Close
+	case interface{ Flush() error }:
+		fn = f.Flush
+	case interface{ Flush() }:
+		fn = func() error { f.Flush(); return nil }
+	default:
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() { done <- fn() }()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,79 @@
+package slogging
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+type closeRecorder struct {
+	name  string
+	order *[]string
+	err   error
+}
+
+func (c *closeRecorder) Close(context.Context) error {
+	*c.order = append(*c.order, c.name)
+	return c.err
+}
+
+type plainCloser struct{ closed bool }
+
+func (c *plainCloser) Close() error {
+	c.closed = true
+	return nil
+}
+
+type plainFlusher struct{ flushed bool }
+
+func (f *plainFlusher) Flush() { f.flushed = true }
+
+type stuckCloser struct{}
+
+func (stuckCloser) Close() error {
+	select {}
+}
+
+func TestCloseAll(t *testing.T) {
+	t.Run("targets are closed in order", func(t *testing.T) {
+		var order []string
+		pc, pf := &plainCloser{}, &plainFlusher{}
+		err := CloseAll(context.Background(),
+			&closeRecorder{name: "async", order: &order},
+			pc,
+			&closeRecorder{name: "loki", order: &order},
+			pf,
+			"ignored",
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(order, ",") != "async,loki" || !pc.closed || !pf.flushed {
+			t.Errorf("order = %v, closed = %v, flushed = %v", order, pc.closed, pf.flushed)
+		}
+	})
+
+	t.Run("errors are joined", func(t *testing.T) {
+		var order []string
+		boom := errors.New("boom")
+		err := CloseAll(context.Background(),
+			&closeRecorder{name: "a", order: &order, err: boom},
+			&closeRecorder{name: "b", order: &order},
+		)
+		if !errors.Is(err, boom) || len(order) != 2 {
+			t.Errorf("err = %v, order = %v", err, order)
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		var order []string
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		err := CloseAll(ctx, stuckCloser{}, &closeRecorder{name: "late", order: &order})
+		if !errors.Is(err, context.DeadlineExceeded) || len(order) != 0 {
+			t.Errorf("err = %v, order = %v", err, order)
+		}
+	})
+}
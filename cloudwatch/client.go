@@ -0,0 +1,310 @@
+package cloudwatch
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Event is a log event of a PutLogEvents request.
+type Event struct {
+	Timestamp int64  `json:"timestamp"` // Milliseconds since the epoch
+	Message   string `json:"message"`
+}
+
+// PutLogEventsInput is the input of PutLogEvents.
+type PutLogEventsInput struct {
+	LogGroupName  string  `json:"logGroupName"`
+	LogStreamName string  `json:"logStreamName"`
+	LogEvents     []Event `json:"logEvents"`
+	SequenceToken string  `json:"sequenceToken,omitempty"`
+}
+
+// RejectedLogEventsInfo reports the events of a request rejected by
+// CloudWatch Logs, as indices into the events of the request.
+type RejectedLogEventsInfo struct {
+	TooNewLogEventStartIndex *int `json:"tooNewLogEventStartIndex,omitempty"`
+	TooOldLogEventEndIndex   *int `json:"tooOldLogEventEndIndex,omitempty"`
+	ExpiredLogEventEndIndex  *int `json:"expiredLogEventEndIndex,omitempty"`
+}
+
+// PutLogEventsOutput is the output of PutLogEvents.
+type PutLogEventsOutput struct {
+	NextSequenceToken     string                 `json:"nextSequenceToken,omitempty"`
+	RejectedLogEventsInfo *RejectedLogEventsInfo `json:"rejectedLogEventsInfo,omitempty"`
+}
+
+// API is the subset of the CloudWatch Logs API used by the handler. It is
+// implemented by Client, and can be implemented on top of the AWS SDK.
+// Failures reported by the service should be returned as *APIError, so
+// that the handler can recover from them.
+type API interface {
+	PutLogEvents(ctx context.Context, in *PutLogEventsInput) (*PutLogEventsOutput, error)
+	CreateLogGroup(ctx context.Context, group string) error
+	CreateLogStream(ctx context.Context, group, stream string) error
+}
+
+// Error codes of the CloudWatch Logs API the handler recovers from.
+const (
+	CodeResourceNotFound      = "ResourceNotFoundException"
+	CodeResourceAlreadyExists = "ResourceAlreadyExistsException"
+	CodeInvalidSequenceToken  = "InvalidSequenceTokenException"
+	CodeDataAlreadyAccepted   = "DataAlreadyAcceptedException"
+	CodeThrottling            = "ThrottlingException"
+	CodeServiceUnavailable    = "ServiceUnavailableException"
+)
+
+// APIError is an error returned by the CloudWatch Logs API.
+type APIError struct {
+	StatusCode int
+	Code       string
+	Message    string
+	// ExpectedSequenceToken is set with CodeInvalidSequenceToken and
+	// CodeDataAlreadyAccepted.
+	ExpectedSequenceToken string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// retryable reports whether the request failing with the error may succeed
+// later.
+func (e *APIError) retryable() bool {
+	return e.Code == CodeThrottling || e.Code == CodeServiceUnavailable ||
+		e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Credentials are the AWS credentials signing the requests of Client.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// CredentialsFromEnv returns the credentials of the AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.
+func CredentialsFromEnv() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// RegionFromEnv returns the region of the AWS_REGION environment variable,
+// or of AWS_DEFAULT_REGION if unset.
+func RegionFromEnv() string {
+	if r := os.Getenv("AWS_REGION"); r != "" {
+		return r
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// Client is a minimal CloudWatch Logs client speaking the JSON protocol of
+// the service and signing requests with Signature Version 4.
+type Client struct {
+	// Endpoint is the URL of the service, "https://logs.REGION.amazonaws.com"
+	// by default.
+	Endpoint    string
+	Region      string
+	Credentials Credentials
+	// HTTPClient sends the requests, http.DefaultClient if nil.
+	HTTPClient *http.Client
+
+	now func() time.Time
+}
+
+// NewClient creates a client of the service in region.
+func NewClient(region string, creds Credentials) *Client {
+	return &Client{
+		Endpoint:    "https://logs." + region + ".amazonaws.com",
+		Region:      region,
+		Credentials: creds,
+		now:         time.Now,
+	}
+}
+
+func (c *Client) PutLogEvents(ctx context.Context, in *PutLogEventsInput) (*PutLogEventsOutput, error) {
+	out := new(PutLogEventsOutput)
+	if err := c.call(ctx, "PutLogEvents", in, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *Client) CreateLogGroup(ctx context.Context, group string) error {
+	return c.call(ctx, "CreateLogGroup", map[string]string{"logGroupName": group}, nil)
+}
+
+func (c *Client) CreateLogStream(ctx context.Context, group, stream string) error {
+	return c.call(ctx, "CreateLogStream", map[string]string{"logGroupName": group, "logStreamName": stream}, nil)
+}
+
+func (c *Client) call(ctx context.Context, action string, in, out any) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("error when marshaling %s request: %w", action, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Endpoint+"/", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error when creating %s request: %w", action, err)
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Logs_20140328."+action)
+	now := time.Now
+	if c.now != nil {
+		now = c.now
+	}
+	sign(req, body, c.Credentials, c.Region, "logs", now())
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error when calling %s: %w", action, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error when reading %s response: %w", action, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return decodeError(resp.StatusCode, b)
+	}
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("error when unmarshaling %s response: %w", action, err)
+	}
+	return nil
+}
+
+// decodeError decodes the error body of the JSON protocol, whose "__type"
+// holds the error code, optionally prefixed with a namespace and "#".
+func decodeError(status int, b []byte) error {
+	var body struct {
+		Type                  string `json:"__type"`
+		Message               string `json:"message"`
+		MessageUpper          string `json:"Message"`
+		ExpectedSequenceToken string `json:"expectedSequenceToken"`
+	}
+	_ = json.Unmarshal(b, &body)
+	e := &APIError{
+		StatusCode:            status,
+		Code:                  body.Type[strings.LastIndex(body.Type, "#")+1:],
+		Message:               body.Message,
+		ExpectedSequenceToken: body.ExpectedSequenceToken,
+	}
+	if e.Message == "" {
+		e.Message = body.MessageUpper
+	}
+	if e.Code == "" {
+		e.Code = http.StatusText(status)
+	}
+	return e
+}
+
+// isCode reports whether err is an *APIError with the given code.
+func isCode(err error, code string) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.Code == code
+}
+
+const sigTimeFormat = "20060102T150405Z"
+
+// sign adds the Signature Version 4 authorization of req, whose payload is
+// body, to its headers.
+func sign(req *http.Request, body []byte, creds Credentials, region, service string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format(sigTimeFormat)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.Join(v, ",")
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payload := sha256.Sum256(body)
+	canonical := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payload[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	hash := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes s, leaving only unreserved characters.
+func uriEncode(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
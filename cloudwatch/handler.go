@@ -0,0 +1,480 @@
+// Package cloudwatch provides a slog.Handler sending records to Amazon
+// CloudWatch Logs.
+package cloudwatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/internal/jsonvalue"
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/internal/stats"
+	"github.com/mikluko/slogging/severity"
+)
+
+var (
+	// ErrClosed is returned by Handle after the handler has been closed.
+	ErrClosed = errors.New("slogging: cloudwatch handler is closed")
+	// ErrTooLarge is returned by Handle for records whose message exceeds
+	// the maximum event size of CloudWatch Logs.
+	ErrTooLarge = errors.New("slogging: cloudwatch event is too large")
+)
+
+// Limits of PutLogEvents.
+const (
+	MaxBatchEvents = 10000
+	MaxBatchBytes  = 1048576
+	MaxEventBytes  = 262144 - eventOverhead
+	MaxBatchSpan   = 24 * time.Hour
+
+	eventOverhead = 26 // Bytes accounted per event
+)
+
+const (
+	defaultBatchWait  = 5 * time.Second
+	defaultMaxRetries = 5
+	defaultMinBackoff = 200 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// batch accumulates the events of the stream. It is shared across
+// WithAttrs and WithGroup derivations and drained by a single delivery
+// goroutine.
+type batch struct {
+	mutex   sync.Mutex
+	events  []Event
+	bytes   int
+	dropped uint64
+	closed  bool
+
+	sendLock chan struct{}      // Serializes PutLogEvents and guards token, held while it holds a value
+	ctx      context.Context    // Context of the delivery goroutine
+	cancel   context.CancelFunc // Cancels ctx when Close gives up
+	token    string
+	kick     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stats    stats.Recorder
+}
+
+// Handler is a slog.Handler that batches records and sends them to a log
+// stream of CloudWatch Logs with PutLogEvents. The message of an event is
+// a JSON object of the attributes, as added by slogging.PutAttrs, with the
+// "level" and "msg" keys taking precedence and errors, text marshalers and
+// stringers encoded as strings, as by slog.JSONHandler; the time of the
+// record is the timestamp of the event.
+//
+// Batches are sent when they reach the configured number of events or
+// bytes, or after the batch wait, from a background goroutine, split to
+// fit the limits of PutLogEvents. The sequence token returned by the
+// service is passed to the next request, and replaced by the expected one
+// when the service rejects it. Missing log groups and streams are created,
+// unless disabled with WithCreate. Throttled and failed requests are
+// retried with exponential backoff; batches still failing are dropped and
+// reported to the error handler. Close must be called to send the pending
+// records and stop the goroutine.
+type Handler struct {
+	scope  scope.Scope
+	config handlerOptions
+	batch  *batch
+}
+
+// NewHandler creates a handler sending to the given log group and stream
+// and starts its delivery goroutine. Unless set with WithAPI, requests are
+// sent by a Client of the region and credentials of the environment.
+func NewHandler(group, stream string, options ...Option) *Handler {
+	config := handlerOptions{
+		group:       group,
+		stream:      stream,
+		level:       slog.LevelInfo,
+		create:      true,
+		batchEvents: MaxBatchEvents,
+		batchBytes:  MaxBatchBytes,
+		batchWait:   defaultBatchWait,
+		maxRetries:  defaultMaxRetries,
+		minBackoff:  defaultMinBackoff,
+		maxBackoff:  defaultMaxBackoff,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	if config.api == nil {
+		config.api = NewClient(RegionFromEnv(), CredentialsFromEnv())
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &batch{
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		sendLock: make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	h := &Handler{config: config, batch: b}
+	go h.run()
+	return h
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.config.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// Handle adds the record to the batch.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	h.batch.stats.Handled(1)
+	msg := make(map[string]any, r.NumAttrs()+2)
+	slogging.PutAttrs(msg, h.scope.Attrs(r)...)
+	jsonvalue.Map(msg)
+	table := h.config.table
+	if table == nil {
+		table = severity.Default()
+	}
+	msg[slog.LevelKey] = table.Lookup(severity.CloudWatch, r.Level).Name
+	msg[slog.MessageKey] = r.Message
+
+	b, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error when marshaling CloudWatch event: %w", err)
+	}
+	if len(b) > MaxEventBytes {
+		return ErrTooLarge
+	}
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return h.add(Event{Timestamp: t.UnixMilli(), Message: string(b)})
+}
+
+func (h *Handler) add(e Event) error {
+	b := h.batch
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return ErrClosed
+	}
+	b.events = append(b.events, e)
+	b.bytes += len(e.Message) + eventOverhead
+	full := len(b.events) >= h.config.batchEvents || b.bytes >= h.config.batchBytes
+	b.mutex.Unlock()
+	if full {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (h *Handler) run() {
+	b := h.batch
+	defer close(b.done)
+	ticker := time.NewTicker(h.config.batchWait)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.kick:
+		case <-b.stop:
+			return
+		}
+		h.push(b.ctx)
+	}
+}
+
+// take removes and returns the pending events.
+func (h *Handler) take() []Event {
+	b := h.batch
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	events := b.events
+	b.events = nil
+	b.bytes = 0
+	return events
+}
+
+// push sends the pending events, retrying with backoff until ctx is done.
+func (h *Handler) push(ctx context.Context) error {
+	b := h.batch
+	select {
+	case b.sendLock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-b.sendLock }()
+	events := h.take()
+	if len(events) == 0 {
+		return nil
+	}
+	// PutLogEvents requires events in chronological order.
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp < events[j].Timestamp
+	})
+	var errs []error
+	for _, chunk := range split(events) {
+		if err := h.send(ctx, chunk); err != nil {
+			b.mutex.Lock()
+			b.dropped += uint64(len(chunk))
+			b.mutex.Unlock()
+			h.batch.stats.Error(err)
+			if h.config.onError != nil {
+				h.config.onError(err)
+			}
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// split splits sorted events into batches within the limits of
+// PutLogEvents.
+func split(events []Event) [][]Event {
+	var chunks [][]Event
+	start, size := 0, 0
+	for i, e := range events {
+		n := len(e.Message) + eventOverhead
+		if i > start && (i-start >= MaxBatchEvents || size+n > MaxBatchBytes ||
+			time.Duration(e.Timestamp-events[start].Timestamp)*time.Millisecond >= MaxBatchSpan) {
+			chunks = append(chunks, events[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	return append(chunks, events[start:])
+}
+
+// send sends one batch. Sequence token mismatches and missing resources
+// are fixed and retried right away; throttling and server errors are
+// retried with backoff.
+func (h *Handler) send(ctx context.Context, events []Event) error {
+	b := h.batch
+	backoff := h.config.minBackoff
+	created := false
+	for attempt := 0; ; attempt++ {
+		out, err := h.config.api.PutLogEvents(ctx, &PutLogEventsInput{
+			LogGroupName:  h.config.group,
+			LogStreamName: h.config.stream,
+			LogEvents:     events,
+			SequenceToken: b.token,
+		})
+		if err == nil {
+			b.token = out.NextSequenceToken
+			if info := out.RejectedLogEventsInfo; info != nil {
+				return fmt.Errorf("error when sending to CloudWatch: events rejected: %s", rejected(info))
+			}
+			return nil
+		}
+		err = fmt.Errorf("error when sending to CloudWatch: %w", err)
+		var apiErr *APIError
+		isAPI := errors.As(err, &apiErr)
+		switch {
+		case isAPI && apiErr.Code == CodeDataAlreadyAccepted:
+			b.token = apiErr.ExpectedSequenceToken
+			return nil
+		case isAPI && apiErr.Code == CodeInvalidSequenceToken && attempt < h.config.maxRetries:
+			b.token = apiErr.ExpectedSequenceToken
+			continue
+		case isAPI && apiErr.Code == CodeResourceNotFound && h.config.create && !created:
+			created = true
+			if err := h.create(ctx); err != nil {
+				return err
+			}
+			b.token = ""
+			continue
+		case isAPI && !apiErr.retryable() || attempt >= h.config.maxRetries:
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", err, ctx.Err())
+		}
+		backoff = min(2*backoff, h.config.maxBackoff)
+	}
+}
+
+// create creates the log group and stream, tolerating existing ones.
+func (h *Handler) create(ctx context.Context) error {
+	err := h.config.api.CreateLogGroup(ctx, h.config.group)
+	if err != nil && !isCode(err, CodeResourceAlreadyExists) {
+		return fmt.Errorf("error when creating CloudWatch log group: %w", err)
+	}
+	err = h.config.api.CreateLogStream(ctx, h.config.group, h.config.stream)
+	if err != nil && !isCode(err, CodeResourceAlreadyExists) {
+		return fmt.Errorf("error when creating CloudWatch log stream: %w", err)
+	}
+	return nil
+}
+
+func rejected(info *RejectedLogEventsInfo) string {
+	s := ""
+	add := func(name string, i *int) {
+		if i != nil {
+			if s != "" {
+				s += ", "
+			}
+			s += fmt.Sprintf("%s %d", name, *i)
+		}
+	}
+	add("too new from", info.TooNewLogEventStartIndex)
+	add("too old up to", info.TooOldLogEventEndIndex)
+	add("expired up to", info.ExpiredLogEventEndIndex)
+	return s
+}
+
+// Flush sends the pending records, retrying until ctx is done.
+func (h *Handler) Flush(ctx context.Context) error {
+	return h.push(ctx)
+}
+
+// Close stops accepting records, sends the pending ones, retrying until
+// ctx is done, and stops the delivery goroutine.
+// When ctx is done first, the delivery in progress is cancelled, the
+// pending records are abandoned and Close returns ctx.Err().
+func (h *Handler) Close(ctx context.Context) error {
+	b := h.batch
+	b.mutex.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.stop)
+	}
+	b.mutex.Unlock()
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+	return h.push(ctx)
+}
+
+// Dropped returns the number of records dropped after failed requests.
+func (h *Handler) Dropped() uint64 {
+	h.batch.mutex.Lock()
+	defer h.batch.mutex.Unlock()
+	return h.batch.dropped
+}
+
+// Stats returns the counters of the handler. The queue depth is the number
+// of records waiting for the next delivery.
+func (h *Handler) Stats() slogging.Stats {
+	b := h.batch
+	b.mutex.Lock()
+	dropped, depth := b.dropped, len(b.events)
+	b.mutex.Unlock()
+	return b.stats.Stats(dropped, depth)
+}
+
+type handlerOptions struct {
+	group       string
+	stream      string
+	api         API
+	level       slog.Leveler
+	table       *severity.Table
+	create      bool
+	batchEvents int
+	batchBytes  int
+	batchWait   time.Duration
+	maxRetries  int
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	onError     func(error)
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithSeverityTable sets the table used to map levels to the "level" key
+// of events. The default table from the severity package is used otherwise.
+func WithSeverityTable(t *severity.Table) Option {
+	return func(h *handlerOptions) {
+		h.table = t
+	}
+}
+
+// WithAPI sets the implementation of the CloudWatch Logs API, e.g. a
+// Client with custom credentials or an adapter of the AWS SDK.
+func WithAPI(api API) Option {
+	return func(h *handlerOptions) {
+		h.api = api
+	}
+}
+
+// WithCreate sets whether missing log groups and streams are created. It
+// is enabled by default.
+func WithCreate(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.create = true
+		for i := range x {
+			h.create = x[i]
+		}
+	}
+}
+
+// WithBatch sets the number of events and of bytes triggering a send, by
+// default the limits of PutLogEvents, and the maximum time records wait
+// for a send, five seconds by default. Values above the limits of
+// PutLogEvents are capped.
+func WithBatch(events, bytes int, wait time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.batchEvents = min(max(events, 1), MaxBatchEvents)
+		h.batchBytes = min(max(bytes, 1), MaxBatchBytes)
+		h.batchWait = wait
+	}
+}
+
+// WithRetry sets the number of retries of a failed request, 5 by default,
+// and the bounds of the exponential backoff between them, 200ms and 30s
+// by default.
+func WithRetry(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.maxRetries = maxRetries
+		h.minBackoff = minBackoff
+		h.maxBackoff = maxBackoff
+	}
+}
+
+// WithErrorHandler sets a function called with send errors, after retries
+// are exhausted, and with events rejected by the service. It is called
+// from the delivery goroutine, or from Flush and Close.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
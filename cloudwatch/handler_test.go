@@ -0,0 +1,288 @@
+package cloudwatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mikluko/slogging/severity"
+)
+
+type fakeAPI struct {
+	mutex    sync.Mutex
+	exists   bool
+	token    string
+	fail     []error // Errors returned by the next PutLogEvents calls
+	events   []Event
+	tokens   []string
+	creates  []string
+	requests int
+}
+
+func (f *fakeAPI) PutLogEvents(_ context.Context, in *PutLogEventsInput) (*PutLogEventsOutput, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.requests++
+	f.tokens = append(f.tokens, in.SequenceToken)
+	if len(f.fail) > 0 {
+		err := f.fail[0]
+		f.fail = f.fail[1:]
+		return nil, err
+	}
+	if !f.exists {
+		return nil, &APIError{StatusCode: 400, Code: CodeResourceNotFound}
+	}
+	if in.SequenceToken != f.token {
+		return nil, &APIError{StatusCode: 400, Code: CodeInvalidSequenceToken, ExpectedSequenceToken: f.token}
+	}
+	f.events = append(f.events, in.LogEvents...)
+	f.token = in.SequenceToken + "x"
+	return &PutLogEventsOutput{NextSequenceToken: f.token}, nil
+}
+
+func (f *fakeAPI) CreateLogGroup(_ context.Context, group string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.creates = append(f.creates, group)
+	return &APIError{Code: CodeResourceAlreadyExists}
+}
+
+func (f *fakeAPI) CreateLogStream(_ context.Context, group, stream string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.creates = append(f.creates, group+"/"+stream)
+	f.exists = true
+	return nil
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("records are sent as JSON events", func(t *testing.T) {
+		api := &fakeAPI{exists: true}
+		h := NewHandler("g", "s", WithAPI(api), WithBatch(100, MaxBatchBytes, time.Hour))
+		logger := slog.New(h).With("app", "x").WithGroup("req")
+		logger.Info("hello", "id", 1)
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(api.events) != 1 {
+			t.Fatalf("expected one event, got %v", api.events)
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(api.events[0].Message), &m); err != nil {
+			t.Fatal(err)
+		}
+		if m["msg"] != "hello" || m["level"] != "INFO" || m["app"] != "x" || m["req"].(map[string]any)["id"] != 1.0 {
+			t.Errorf("unexpected message: %v", m)
+		}
+		if err := h.Handle(context.Background(), slog.Record{}); !errors.Is(err, ErrClosed) {
+			t.Errorf("expected ErrClosed, got %v", err)
+		}
+	})
+
+	t.Run("errors are encoded as messages", func(t *testing.T) {
+		api := &fakeAPI{exists: true}
+		h := NewHandler("g", "s", WithAPI(api), WithBatch(100, MaxBatchBytes, time.Hour))
+		slog.New(h).WithGroup("req").Error("failed", "err", errors.New("boom"))
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		want := `{"level":"ERROR","msg":"failed","req":{"err":"boom"}}`
+		if len(api.events) != 1 || api.events[0].Message != want {
+			t.Errorf("expected %s, got %v", want, api.events)
+		}
+	})
+
+	t.Run("levels are mapped by the severity table", func(t *testing.T) {
+		table := severity.NewTable()
+		table.Set(severity.CloudWatch, slog.LevelError+8, severity.Severity{Code: 5, Name: "CRITICAL"})
+		api := &fakeAPI{exists: true}
+		h := NewHandler("g", "s", WithAPI(api), WithSeverityTable(table), WithBatch(100, MaxBatchBytes, time.Hour))
+		logger := slog.New(h)
+		logger.Log(context.Background(), slog.LevelError+4, "fatal")
+		logger.Log(context.Background(), slog.LevelError+8, "critical")
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(api.events) != 2 ||
+			!strings.Contains(api.events[0].Message, `"level":"FATAL"`) ||
+			!strings.Contains(api.events[1].Message, `"level":"CRITICAL"`) {
+			t.Errorf("unexpected events: %v", api.events)
+		}
+	})
+
+	t.Run("group and stream are created and tokens are followed", func(t *testing.T) {
+		api := &fakeAPI{token: "t0"}
+		h := NewHandler("g", "s", WithAPI(api), WithBatch(100, MaxBatchBytes, time.Hour))
+		logger := slog.New(h)
+		logger.Info("one")
+		if err := h.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		logger.Info("two")
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(api.creates, ",") != "g,g/s" || len(api.events) != 2 {
+			t.Errorf("unexpected state: creates %v, events %v", api.creates, api.events)
+		}
+		// Missing stream, rejected empty token, accepted, then the returned token.
+		if strings.Join(api.tokens, ",") != ",,t0,t0x" {
+			t.Errorf("unexpected tokens: %q", api.tokens)
+		}
+	})
+
+	t.Run("creation can be disabled", func(t *testing.T) {
+		api := &fakeAPI{}
+		var reported error
+		h := NewHandler("g", "s", WithAPI(api), WithCreate(false), WithErrorHandler(func(err error) { reported = err }))
+		slog.New(h).Info("lost")
+		if err := h.Close(context.Background()); err == nil || reported == nil || h.Dropped() != 1 || len(api.creates) != 0 {
+			t.Errorf("unexpected outcome: %v, %v, dropped %d", err, reported, h.Dropped())
+		}
+	})
+
+	t.Run("throttling is retried with backoff", func(t *testing.T) {
+		api := &fakeAPI{exists: true, fail: []error{
+			&APIError{StatusCode: 400, Code: CodeThrottling},
+			errors.New("connection reset"),
+		}}
+		h := NewHandler("g", "s", WithAPI(api), WithRetry(3, time.Millisecond, time.Millisecond))
+		slog.New(h).Info("retried")
+		if err := h.Close(context.Background()); err != nil || api.requests != 3 || len(api.events) != 1 {
+			t.Errorf("unexpected outcome: %v, %d requests", err, api.requests)
+		}
+
+		api = &fakeAPI{exists: true, fail: []error{&APIError{StatusCode: 400, Code: "InvalidParameterException"}}}
+		h = NewHandler("g", "s", WithAPI(api), WithRetry(3, time.Millisecond, time.Millisecond))
+		slog.New(h).Info("rejected")
+		if err := h.Close(context.Background()); err == nil || api.requests != 1 {
+			t.Errorf("expected no retry, got %v, %d requests", err, api.requests)
+		}
+	})
+
+	t.Run("close gives up when the context is done", func(t *testing.T) {
+		throttled := &APIError{StatusCode: 400, Code: CodeThrottling}
+		api := &fakeAPI{exists: true, fail: []error{throttled, throttled, throttled, throttled, throttled, throttled}}
+		h := NewHandler("g", "s", WithAPI(api), WithBatch(1, MaxBatchBytes, time.Hour), WithRetry(5, time.Second, 2*time.Second))
+		slog.New(h).Info("throttled")
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			api.mutex.Lock()
+			n := api.requests
+			api.mutex.Unlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if err := h.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("close took %s", d)
+		}
+	})
+
+	t.Run("size triggers a send", func(t *testing.T) {
+		api := &fakeAPI{exists: true}
+		h := NewHandler("g", "s", WithAPI(api), WithBatch(2, MaxBatchBytes, time.Hour))
+		defer h.Close(context.Background())
+		logger := slog.New(h)
+		logger.Info("a")
+		logger.Info("b")
+		deadline := time.Now().Add(time.Second)
+		for {
+			api.mutex.Lock()
+			n := len(api.events)
+			api.mutex.Unlock()
+			if n == 2 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("batch not sent")
+			}
+			time.Sleep(time.Millisecond)
+		}
+	})
+
+	t.Run("oversized events are refused", func(t *testing.T) {
+		h := NewHandler("g", "s", WithAPI(&fakeAPI{exists: true}))
+		defer h.Close(context.Background())
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, strings.Repeat("x", MaxEventBytes), 0)
+		if err := h.Handle(context.Background(), r); !errors.Is(err, ErrTooLarge) {
+			t.Errorf("expected ErrTooLarge, got %v", err)
+		}
+	})
+}
+
+func Test_split(t *testing.T) {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	events := make([]Event, MaxBatchEvents+1)
+	for i := range events {
+		events[i] = Event{Timestamp: base}
+	}
+	events[len(events)-1].Timestamp = base + MaxBatchSpan.Milliseconds()
+	if chunks := split(events); len(chunks) != 2 || len(chunks[0]) != MaxBatchEvents {
+		t.Errorf("unexpected split by count: %d chunks", len(chunks))
+	}
+	events = []Event{{Timestamp: base}, {Timestamp: base + MaxBatchSpan.Milliseconds()}}
+	if chunks := split(events); len(chunks) != 2 {
+		t.Errorf("unexpected split by span: %d chunks", len(chunks))
+	}
+	big := strings.Repeat("x", MaxEventBytes)
+	events = []Event{{Message: big}, {Message: big}, {Message: big}, {Message: big}, {Message: big}}
+	if chunks := split(events); len(chunks) != 2 || len(chunks[0]) != 4 {
+		t.Errorf("unexpected split by size: %d chunks", len(chunks))
+	}
+}
+
+func Test_Client(t *testing.T) {
+	t.Run("requests are signed and errors decoded", func(t *testing.T) {
+		var target, auth string
+		var body map[string]any
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			target, auth = r.Header.Get("X-Amz-Target"), r.Header.Get("Authorization")
+			b, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(b, &body)
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = io.WriteString(w, `{"__type":"com.amazonaws.logs#InvalidSequenceTokenException","message":"bad","expectedSequenceToken":"42"}`)
+		}))
+		defer srv.Close()
+
+		c := NewClient("eu-west-1", Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"})
+		c.Endpoint = srv.URL
+		_, err := c.PutLogEvents(context.Background(), &PutLogEventsInput{LogGroupName: "g", LogStreamName: "s", LogEvents: []Event{{Timestamp: 1, Message: "m"}}})
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.Code != CodeInvalidSequenceToken || apiErr.ExpectedSequenceToken != "42" {
+			t.Errorf("unexpected error: %v", err)
+		}
+		if target != "Logs_20140328.PutLogEvents" || body["logGroupName"] != "g" {
+			t.Errorf("unexpected request: %s %v", target, body)
+		}
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/") || !strings.Contains(auth, "/eu-west-1/logs/aws4_request") {
+			t.Errorf("unexpected authorization: %s", auth)
+		}
+	})
+
+	t.Run("signature matches the AWS example", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+		creds := Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+		sign(req, nil, creds, "us-east-1", "iam", time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC))
+		want := "Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7"
+		if auth := req.Header.Get("Authorization"); !strings.HasSuffix(auth, want) {
+			t.Errorf("unexpected authorization: %s", auth)
+		}
+	})
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/async"
+	"github.com/mikluko/slogging/console"
+	"github.com/mikluko/slogging/ecs"
+	"github.com/mikluko/slogging/gcp"
+	"github.com/mikluko/slogging/levels"
+	"github.com/mikluko/slogging/logfmt"
+	"github.com/mikluko/slogging/multi"
+	"github.com/mikluko/slogging/otel"
+	"github.com/mikluko/slogging/pretty"
+	"github.com/mikluko/slogging/redact"
+	"github.com/mikluko/slogging/rotate"
+	"github.com/mikluko/slogging/sample"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+// Build validates the configuration and builds its pipeline. The returned
+// closer closes the wrappers that buffer records, then the files, within
+// ten seconds; it is meant to be deferred in main.
+//
+// Logger levels are applied by a levels.Registry wrapping the pipeline,
+// matching logger names exactly, so the outputs only filter records by
+// their own level.
+func (c *Config) Build() (slog.Handler, io.Closer, error) {
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	lvl, _ := level(c.Level, slog.LevelInfo)
+	floor := lvl
+	for _, text := range c.Loggers {
+		l, _ := level(text, lvl)
+		floor = min(floor, l)
+	}
+
+	var (
+		closers []any // Closed in order
+		files   []any
+	)
+	fail := func(err error) (slog.Handler, io.Closer, error) {
+		_ = slogging.CloseAll(context.Background(), files...)
+		return nil, nil, err
+	}
+
+	outputs := c.Outputs
+	if len(outputs) == 0 {
+		outputs = []Output{{Type: "stderr"}}
+	}
+	handlers := make([]slog.Handler, 0, len(outputs))
+	for _, o := range outputs {
+		w, err := o.writer()
+		if err != nil {
+			return fail(err)
+		}
+		if w != os.Stdout && w != os.Stderr {
+			files = append(files, w)
+		}
+		format := o.Format
+		if format == "" {
+			format = c.Format
+		}
+		olvl, _ := level(o.Level, floor)
+		handlers = append(handlers, newFormat(format, w, olvl, c.Source))
+	}
+	handler := handlers[0]
+	if len(handlers) > 1 {
+		handler = multi.New(handlers...)
+	}
+
+	for i := len(c.Wrappers) - 1; i >= 0; i-- {
+		handler = c.Wrappers[i].wrap(handler)
+		if h, ok := handler.(*async.Handler); ok {
+			closers = append([]any{h}, closers...)
+		}
+	}
+
+	if len(c.Loggers) > 0 {
+		registry := levels.NewRegistry(lvl)
+		for name, text := range c.Loggers {
+			l, _ := level(text, lvl)
+			registry.Set(name, l)
+		}
+		handler = registry.Named(handler)
+	}
+	return handler, pipelineCloser(append(closers, files...)), nil
+}
+
+type pipelineCloser []any
+
+func (p pipelineCloser) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return slogging.CloseAll(ctx, p...)
+}
+
+func (o Output) writer() (io.Writer, error) {
+	switch o.Type {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	}
+	if r := o.Rotate; r != nil {
+		options := []rotate.Option{rotate.WithMaxBackups(r.MaxBackups), rotate.WithCompress(r.Compress)}
+		if r.MaxSize > 0 {
+			options = append(options, rotate.WithMaxSize(r.MaxSize))
+		}
+		if r.Interval > 0 {
+			options = append(options, rotate.WithInterval(r.Interval))
+		}
+		w, err := rotate.Open(o.Path, options...)
+		if err != nil {
+			return nil, fmt.Errorf("error when opening log file: %w", err)
+		}
+		return w, nil
+	}
+	f, err := os.OpenFile(o.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error when opening log file: %w", err)
+	}
+	return f, nil
+}
+
+func newFormat(format string, w io.Writer, lvl slog.Level, source bool) slog.Handler {
+	switch format {
+	case "text":
+		return slog.NewTextHandler(w, &slog.HandlerOptions{Level: lvl, AddSource: source})
+	case "logfmt":
+		return logfmt.NewHandler(w, logfmt.WithLevel(lvl), logfmt.WithSource(source))
+	case "console":
+		return console.NewHandler(w, console.WithLevel(lvl), console.WithSource(source))
+	case "pretty":
+		return pretty.NewHandler(pretty.WithWriter(w), pretty.WithLevel(lvl))
+	case "ecs":
+		return ecs.NewHandler(ecs.WithWriter(w), ecs.WithLevel(lvl), ecs.WithSource(source))
+	case "gcp":
+		return gcp.NewHandler(gcp.WithWriter(w), gcp.WithLevel(lvl), gcp.WithSource(source))
+	}
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl, AddSource: source})
+}
+
+func (w Wrapper) wrap(handler slog.Handler) slog.Handler {
+	switch w.Type {
+	case "otel":
+		conv, _ := w.convention()
+		return otel.Wrap(handler, otel.WithConvention(conv))
+	case "redact":
+		patterns, _ := w.patterns()
+		return redact.Wrap(handler,
+			redact.WithKeys(w.Keys...),
+			redact.WithRemoveKeys(w.RemoveKeys...),
+			redact.WithValuePatterns(patterns...))
+	case "sample":
+		var s sample.Sampler
+		if w.Probability != nil {
+			s = sample.Probability(*w.Probability)
+		} else {
+			s = sample.EveryNth(w.EveryNth)
+		}
+		above, _ := level(w.AlwaysAbove, slog.LevelWarn)
+		return sample.Wrap(handler, s, sample.WithAlwaysAbove(above))
+	case "async":
+		overflow, _ := w.overflow()
+		options := []async.Option{async.WithOverflowPolicy(overflow)}
+		if w.QueueSize > 0 {
+			options = append(options, async.WithQueueSize(w.QueueSize))
+		}
+		return async.Wrap(handler, options...)
+	}
+	return handler
+}
+
+func (w Wrapper) convention() (otel.Convention, error) {
+	switch w.Convention {
+	case "", "otel":
+		return otel.ConventionOTel, nil
+	case "ecs":
+		return otel.ConventionECS, nil
+	case "datadog":
+		return otel.ConventionDatadog, nil
+	case "gcp":
+		return otel.ConventionGCP(w.ProjectID), nil
+	}
+	return otel.Convention{}, fmt.Errorf("unknown convention %q", w.Convention)
+}
+
+func (w Wrapper) overflow() (async.Policy, error) {
+	switch w.Overflow {
+	case "", "drop_newest":
+		return async.DropNewest, nil
+	case "drop_oldest":
+		return async.DropOldest, nil
+	case "block":
+		return async.Block, nil
+	}
+	return 0, fmt.Errorf("unknown overflow policy %q", w.Overflow)
+}
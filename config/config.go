@@ -0,0 +1,310 @@
+// Package config builds complete handler pipelines, with their format,
+// outputs, wrappers and per-logger levels, from a YAML or JSON document or
+// from environment variables:
+//
+//	level: info
+//	format: json
+//	outputs:
+//	  - type: stderr
+//	  - type: file
+//	    path: /var/log/app.log
+//	    format: logfmt
+//	    rotate: {max_size: 104857600, max_backups: 5, compress: true}
+//	wrappers:
+//	  - type: redact
+//	    keys: [password, "*.token"]
+//	    value_patterns: [credit_card]
+//	  - type: otel
+//	    convention: ecs
+//	  - type: async
+//	    queue_size: 4096
+//	loggers:
+//	  app.db: debug
+//
+// Watch keeps the pipeline of a file up to date as the file changes or the
+// process receives SIGHUP, behind a handler that stays the same.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mikluko/slogging/redact"
+)
+
+// ErrInvalid is returned when a configuration fails validation.
+var ErrInvalid = errors.New("slogging: invalid logging configuration")
+
+// Formats are the names of the supported output formats.
+var Formats = []string{"json", "text", "logfmt", "console", "pretty", "ecs", "gcp"}
+
+// Config describes a handler pipeline. The zero Config is valid: it writes
+// JSON records at or above Info to standard error.
+type Config struct {
+	// Level is the minimum level of records, "info" by default.
+	Level string `yaml:"level" json:"level"`
+
+	// Format is the format of the outputs not setting their own, one of
+	// Formats, "json" by default.
+	Format string `yaml:"format" json:"format"`
+
+	// Source adds the source location of the logging call to records.
+	Source bool `yaml:"source" json:"source"`
+
+	// Outputs are the destinations of records, standard error if empty.
+	Outputs []Output `yaml:"outputs" json:"outputs"`
+
+	// Wrappers are applied to the outputs, the first one being the
+	// outermost.
+	Wrappers []Wrapper `yaml:"wrappers" json:"wrappers"`
+
+	// Loggers maps logger names, as set by slogging.Named, to their
+	// minimum level, overriding Level. Names are matched exactly.
+	Loggers map[string]string `yaml:"loggers" json:"loggers"`
+}
+
+// Output is a destination of records.
+type Output struct {
+	// Type is "stderr", "stdout" or "file".
+	Type string `yaml:"type" json:"type"`
+
+	// Path is the path of the file outputs.
+	Path string `yaml:"path" json:"path"`
+
+	// Format overrides the format of the configuration.
+	Format string `yaml:"format" json:"format"`
+
+	// Level is the minimum level of the records of the output, in
+	// addition to the levels of the configuration.
+	Level string `yaml:"level" json:"level"`
+
+	// Rotate rotates file outputs.
+	Rotate *Rotate `yaml:"rotate" json:"rotate"`
+}
+
+// Rotate configures the rotation of a file output, see the rotate package.
+type Rotate struct {
+	MaxSize    int64         `yaml:"max_size" json:"max_size"` // Bytes
+	Interval   time.Duration `yaml:"interval" json:"interval"` // Such as "24h" in YAML, nanoseconds in JSON
+	MaxBackups int           `yaml:"max_backups" json:"max_backups"`
+	Compress   bool          `yaml:"compress" json:"compress"`
+}
+
+// Wrapper is a handler wrapping the outputs. The fields used depend on its
+// type:
+//
+//   - "otel": Convention, one of "otel" (default), "ecs", "datadog" and
+//     "gcp", and ProjectID for the latter;
+//   - "redact": Keys, RemoveKeys and ValuePatterns, regular expressions or
+//     the names "credit_card" and "email";
+//   - "sample": Probability or EveryNth, and AlwaysAbove, "warn" by
+//     default;
+//   - "async": QueueSize and Overflow, one of "drop_newest" (default),
+//     "drop_oldest" and "block".
+type Wrapper struct {
+	Type string `yaml:"type" json:"type"`
+
+	Convention string `yaml:"convention" json:"convention"`
+	ProjectID  string `yaml:"project_id" json:"project_id"`
+
+	Keys          []string `yaml:"keys" json:"keys"`
+	RemoveKeys    []string `yaml:"remove_keys" json:"remove_keys"`
+	ValuePatterns []string `yaml:"value_patterns" json:"value_patterns"`
+
+	Probability *float64 `yaml:"probability" json:"probability"`
+	EveryNth    uint64   `yaml:"every_nth" json:"every_nth"`
+	AlwaysAbove string   `yaml:"always_above" json:"always_above"`
+
+	QueueSize int    `yaml:"queue_size" json:"queue_size"`
+	Overflow  string `yaml:"overflow" json:"overflow"`
+}
+
+// Load decodes a YAML or JSON document, rejecting unknown fields, and
+// validates it.
+func Load(r io.Reader) (*Config, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	c := new(Config)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("error when decoding logging configuration: %w", err)
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadFile loads the document at path.
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading logging configuration: %w", err)
+	}
+	return Load(bytes.NewReader(data))
+}
+
+// ApplyEnv overrides the configuration with the environment variables of
+// the given prefix, such as "LOG":
+//
+//   - LOG_LEVEL sets Level;
+//   - LOG_FORMAT sets Format;
+//   - LOG_OUTPUT replaces the outputs with "stdout", "stderr" or the path
+//     of a file;
+//   - LOG_LOGGERS adds logger levels as "app.db=debug,app.http=warn".
+func (c *Config) ApplyEnv(prefix string) error {
+	if v, ok := os.LookupEnv(prefix + "_LEVEL"); ok {
+		c.Level = v
+	}
+	if v, ok := os.LookupEnv(prefix + "_FORMAT"); ok {
+		c.Format = v
+	}
+	if v, ok := os.LookupEnv(prefix + "_OUTPUT"); ok {
+		switch v {
+		case "stdout", "stderr":
+			c.Outputs = []Output{{Type: v}}
+		default:
+			c.Outputs = []Output{{Type: "file", Path: v}}
+		}
+	}
+	if v, ok := os.LookupEnv(prefix + "_LOGGERS"); ok {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			name, lvl, ok := strings.Cut(item, "=")
+			if !ok {
+				return fmt.Errorf("%w: %s_LOGGERS: %q is not name=level", ErrInvalid, prefix, item)
+			}
+			if c.Loggers == nil {
+				c.Loggers = make(map[string]string)
+			}
+			c.Loggers[name] = lvl
+		}
+	}
+	return c.Validate()
+}
+
+// Validate reports the first problem of the configuration, wrapping
+// ErrInvalid.
+func (c *Config) Validate() error {
+	if _, err := level(c.Level, slog.LevelInfo); err != nil {
+		return fmt.Errorf("%w: level: %w", ErrInvalid, err)
+	}
+	if err := checkFormat(c.Format); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	for name, text := range c.Loggers {
+		if _, err := level(text, slog.LevelInfo); err != nil {
+			return fmt.Errorf("%w: logger %q: %w", ErrInvalid, name, err)
+		}
+	}
+	for i, o := range c.Outputs {
+		if err := o.validate(); err != nil {
+			return fmt.Errorf("%w: output %d: %w", ErrInvalid, i, err)
+		}
+	}
+	for i, w := range c.Wrappers {
+		if err := w.validate(); err != nil {
+			return fmt.Errorf("%w: wrapper %d: %w", ErrInvalid, i, err)
+		}
+	}
+	return nil
+}
+
+func (o Output) validate() error {
+	switch o.Type {
+	case "stdout", "stderr":
+	case "file":
+		if o.Path == "" {
+			return errors.New("file output without path")
+		}
+	default:
+		return fmt.Errorf("unknown output type %q", o.Type)
+	}
+	if o.Rotate != nil && o.Type != "file" {
+		return fmt.Errorf("rotation of %s", o.Type)
+	}
+	if _, err := level(o.Level, slog.LevelInfo); err != nil {
+		return err
+	}
+	return checkFormat(o.Format)
+}
+
+func (w Wrapper) validate() error {
+	switch w.Type {
+	case "otel":
+		if _, err := w.convention(); err != nil {
+			return err
+		}
+	case "redact":
+		if _, err := w.patterns(); err != nil {
+			return err
+		}
+	case "sample":
+		if w.Probability == nil && w.EveryNth == 0 {
+			return errors.New("sample wrapper without probability or every_nth")
+		}
+		if p := w.Probability; p != nil && (*p < 0 || *p > 1) {
+			return fmt.Errorf("probability %v out of [0, 1]", *p)
+		}
+		if _, err := level(w.AlwaysAbove, slog.LevelWarn); err != nil {
+			return err
+		}
+	case "async":
+		if _, err := w.overflow(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown wrapper type %q", w.Type)
+	}
+	return nil
+}
+
+func checkFormat(f string) error {
+	if f != "" && !slices.Contains(Formats, f) {
+		return fmt.Errorf("unknown format %q", f)
+	}
+	return nil
+}
+
+// level parses a level, def if empty. Names are case-insensitive.
+func level(text string, def slog.Level) (slog.Level, error) {
+	if text == "" {
+		return def, nil
+	}
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(text)); err != nil {
+		return 0, err
+	}
+	return lvl, nil
+}
+
+// patterns compiles the value patterns of a redact wrapper.
+func (w Wrapper) patterns() ([]*regexp.Regexp, error) {
+	var out []*regexp.Regexp
+	for _, p := range w.ValuePatterns {
+		switch p {
+		case "credit_card":
+			out = append(out, redact.CreditCard)
+		case "email":
+			out = append(out, redact.Email)
+		default:
+			re, err := regexp.Compile(p)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, re)
+		}
+	}
+	return out, nil
+}
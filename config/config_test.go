@@ -0,0 +1,196 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/mikluko/slogging"
+)
+
+func Test_Config(t *testing.T) {
+	t.Run("build from YAML", func(t *testing.T) {
+		dir := t.TempDir()
+		doc := `
+level: info
+format: json
+outputs:
+  - type: file
+    path: ` + filepath.Join(dir, "app.log") + `
+  - type: file
+    path: ` + filepath.Join(dir, "errors.log") + `
+    format: logfmt
+    level: error
+    rotate: {max_size: 1048576, interval: 24h, compress: true}
+wrappers:
+  - type: redact
+    keys: [password]
+    value_patterns: [email]
+  - type: async
+    queue_size: 16
+loggers:
+  app.db: debug
+`
+		c, err := Load(strings.NewReader(doc))
+		if err != nil {
+			t.Fatal(err)
+		}
+		handler, closer, err := c.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger := slog.New(handler)
+		logger.Debug("hidden")
+		slogging.Named(logger, "app.db").Debug("query", "password", "x")
+		logger.Error("failed", "user", "bob@example.com")
+		if err := closer.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		app, _ := os.ReadFile(filepath.Join(dir, "app.log"))
+		for _, want := range []string{`"msg":"query","logger.name":"app.db","password":"[REDACTED]"`, `"user":"[REDACTED]"`} {
+			if !bytes.Contains(app, []byte(want)) {
+				t.Errorf("app.log lacks %s:\n%s", want, app)
+			}
+		}
+		if bytes.Contains(app, []byte("hidden")) {
+			t.Errorf("app.log has debug record of unnamed logger:\n%s", app)
+		}
+		errs, _ := os.ReadFile(filepath.Join(dir, "errors.log"))
+		if got := strings.TrimSpace(string(errs)); strings.Count(got, "\n") != 0 || !strings.Contains(got, "msg=failed") {
+			t.Errorf("errors.log:\n%s", errs)
+		}
+	})
+
+	t.Run("JSON documents", func(t *testing.T) {
+		c, err := Load(strings.NewReader(`{"level": "warn", "wrappers": [{"type": "sample", "probability": 0.5}]}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.Level != "warn" || *c.Wrappers[0].Probability != 0.5 {
+			t.Errorf("Load() = %+v", c)
+		}
+	})
+
+	t.Run("environment", func(t *testing.T) {
+		t.Setenv("APP_LOG_LEVEL", "debug")
+		t.Setenv("APP_LOG_FORMAT", "text")
+		t.Setenv("APP_LOG_OUTPUT", "stdout")
+		t.Setenv("APP_LOG_LOGGERS", "app.db=warn, app.http=error")
+		var c Config
+		if err := c.ApplyEnv("APP_LOG"); err != nil {
+			t.Fatal(err)
+		}
+		if c.Level != "debug" || c.Format != "text" || c.Outputs[0].Type != "stdout" ||
+			c.Loggers["app.db"] != "warn" || c.Loggers["app.http"] != "error" {
+			t.Errorf("ApplyEnv() = %+v", c)
+		}
+		t.Setenv("APP_LOG_LOGGERS", "app.db")
+		if err := c.ApplyEnv("APP_LOG"); !errors.Is(err, ErrInvalid) {
+			t.Errorf("ApplyEnv() = %v, want ErrInvalid", err)
+		}
+	})
+
+	t.Run("zero config", func(t *testing.T) {
+		handler, closer, err := new(Config).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := handler.(*slog.JSONHandler); !ok {
+			t.Errorf("Build() = %T, want *slog.JSONHandler", handler)
+		}
+		if err := closer.Close(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("validation", func(t *testing.T) {
+		for _, doc := range []string{
+			`level: loud`,
+			`format: xml`,
+			`outputs: [{type: kafka}]`,
+			`outputs: [{type: file}]`,
+			`outputs: [{type: stdout, rotate: {max_size: 1}}]`,
+			`wrappers: [{type: magic}]`,
+			`wrappers: [{type: otel, convention: zipkin}]`,
+			`wrappers: [{type: redact, value_patterns: ["("]}]`,
+			`wrappers: [{type: sample}]`,
+			`wrappers: [{type: sample, probability: 2}]`,
+			`wrappers: [{type: async, overflow: spill}]`,
+			`loggers: {app: loud}`,
+		} {
+			if _, err := Load(strings.NewReader(doc)); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Load(%s) = %v, want ErrInvalid", doc, err)
+			}
+		}
+		if _, err := Load(strings.NewReader(`levle: info`)); err == nil {
+			t.Error("unknown field accepted")
+		}
+	})
+
+	t.Run("watch", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "logging.yaml")
+		logPath := filepath.Join(dir, "app.log")
+		write := func(level string) {
+			doc := "level: " + level + "\noutputs: [{type: file, path: " + logPath + ", format: logfmt}]\n"
+			if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		write("info")
+		reloads := make(chan *Config, 4)
+		var errs []error
+		w, err := Watch(path,
+			WithInterval(10*time.Millisecond),
+			WithReloadHandler(func(c *Config) { reloads <- c }),
+			WithErrorHandler(func(err error) { errs = append(errs, err) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-reloads
+		logger := slog.New(w.Handler()).With("a", 1)
+		logger.Debug("hidden")
+
+		write("debug")
+		select {
+		case c := <-reloads:
+			if c.Level != "debug" {
+				t.Errorf("reloaded level %q", c.Level)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("file change not picked up")
+		}
+		logger.Debug("shown")
+
+		if err := os.WriteFile(path, []byte("level: loud\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(50 * time.Millisecond)
+		logger.Debug("kept")
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if len(errs) == 0 || !errors.Is(errs[0], ErrInvalid) {
+			t.Errorf("errors = %v, want ErrInvalid", errs)
+		}
+		if err := w.Reload(); !errors.Is(err, ErrClosed) {
+			t.Errorf("Reload() = %v, want ErrClosed", err)
+		}
+
+		out, _ := os.ReadFile(logPath)
+		got := string(out)
+		if strings.Contains(got, "hidden") || !strings.Contains(got, "msg=shown a=1") || !strings.Contains(got, "msg=kept a=1") {
+			t.Errorf("app.log:\n%s", got)
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/mikluko/slogging"
+)
+
+// ErrClosed is returned when reloading a closed Watcher.
+var ErrClosed = errors.New("slogging: config watcher closed")
+
+// Option configures a Watcher.
+type Option func(h *watchOptions)
+
+type watchOptions struct {
+	interval time.Duration
+	signals  []os.Signal
+	env      string
+	onError  func(error)
+	onReload func(*Config)
+}
+
+// pipeline is a built configuration.
+type pipeline struct {
+	config *Config
+	closer io.Closer
+}
+
+// Watcher keeps the pipeline of a configuration file up to date, rebuilding
+// it when the file changes or the process receives SIGHUP. Its Handler is
+// stable: loggers created from it, and loggers derived from those, deliver
+// their records to the latest pipeline. A file failing to load or build
+// leaves the current pipeline in place.
+type Watcher struct {
+	path   string
+	config watchOptions
+
+	mutex   sync.Mutex // Serializes reloads
+	current atomic.Pointer[pipeline]
+	handler *slogging.Swappable
+	modTime time.Time
+	size    int64
+	closed  bool
+	stop    chan struct{}
+	signals chan os.Signal
+	done    sync.WaitGroup
+}
+
+// Watch loads and builds the configuration file at path and starts the
+// goroutine watching it. Close must be called to stop it and close the
+// pipeline.
+func Watch(path string, options ...Option) (*Watcher, error) {
+	config := watchOptions{
+		interval: 2 * time.Second,
+		signals:  []os.Signal{syscall.SIGHUP},
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	w := &Watcher{path: path, config: config, stop: make(chan struct{})}
+	if err := w.Reload(); err != nil {
+		return nil, err
+	}
+	if len(config.signals) > 0 {
+		w.signals = make(chan os.Signal, 1)
+		signal.Notify(w.signals, config.signals...)
+	}
+	w.done.Add(1)
+	go w.run()
+	return w, nil
+}
+
+// Handler returns the handler delivering records to the current pipeline.
+func (w *Watcher) Handler() slog.Handler {
+	return w.handler
+}
+
+// Config returns the configuration of the current pipeline.
+func (w *Watcher) Config() *Config {
+	return w.current.Load().config
+}
+
+// Reload loads and builds the file, swaps the new pipeline in and closes
+// the previous one. Records being handled by the previous pipeline while it
+// is closed may be lost.
+func (w *Watcher) Reload() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return fmt.Errorf("error when reading logging configuration: %w", err)
+	}
+	w.modTime, w.size = info.ModTime(), info.Size() // Failures are reported once per change
+	c, err := LoadFile(w.path)
+	if err != nil {
+		return err
+	}
+	if w.config.env != "" {
+		if err := c.ApplyEnv(w.config.env); err != nil {
+			return err
+		}
+	}
+	handler, closer, err := c.Build()
+	if err != nil {
+		return err
+	}
+	if w.handler == nil {
+		w.handler = slogging.NewSwappable(handler)
+	} else {
+		w.handler.Store(handler)
+	}
+	old := w.current.Swap(&pipeline{config: c, closer: closer})
+	if old != nil {
+		if err := old.closer.Close(); err != nil {
+			w.report(fmt.Errorf("error when closing previous logging pipeline: %w", err))
+		}
+	}
+	if w.config.onReload != nil {
+		w.config.onReload(c)
+	}
+	return nil
+}
+
+// Close stops watching and closes the current pipeline.
+func (w *Watcher) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mutex.Unlock()
+	if w.signals != nil {
+		signal.Stop(w.signals)
+	}
+	close(w.stop)
+	w.done.Wait()
+	return w.current.Load().closer.Close()
+}
+
+func (w *Watcher) run() {
+	defer w.done.Done()
+	var tick <-chan time.Time
+	if w.config.interval > 0 {
+		ticker := time.NewTicker(w.config.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-w.signals:
+			w.reload()
+		case <-tick:
+			if w.changed() {
+				w.reload()
+			}
+		}
+	}
+}
+
+func (w *Watcher) reload() {
+	if err := w.Reload(); err != nil {
+		w.report(err)
+	}
+}
+
+// changed reports whether the modification time or size of the file
+// differs from those of the last reload, successful or not.
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		return false
+	}
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return !info.ModTime().Equal(w.modTime) || info.Size() != w.size
+}
+
+func (w *Watcher) report(err error) {
+	if w.config.onError != nil {
+		w.config.onError(err)
+	}
+}
+
+// WithInterval sets how often the file is checked for changes. Defaults
+// to two seconds; zero or less disables the checks, leaving signals and
+// Reload.
+func WithInterval(d time.Duration) Option {
+	return func(h *watchOptions) {
+		h.interval = d
+	}
+}
+
+// WithSignals sets the signals reloading the file. Defaults to SIGHUP;
+// none disables reloading on signals.
+func WithSignals(signals ...os.Signal) Option {
+	return func(h *watchOptions) {
+		h.signals = signals
+	}
+}
+
+// WithEnv applies the environment variables of the given prefix to every
+// loaded configuration, see Config.ApplyEnv.
+func WithEnv(prefix string) Option {
+	return func(h *watchOptions) {
+		h.env = prefix
+	}
+}
+
+// WithErrorHandler sets a function called with the errors of reloads
+// triggered by changes and signals, and of closing replaced pipelines. It
+// is called from the watching goroutine.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *watchOptions) {
+		h.onError = fn
+	}
+}
+
+// WithReloadHandler sets a function called with every configuration
+// swapped in, including the first one.
+func WithReloadHandler(fn func(*Config)) Option {
+	return func(h *watchOptions) {
+		h.onReload = fn
+	}
+}
@@ -0,0 +1,161 @@
+// Package connlog logs the lifecycle of long-lived connections such as
+// WebSockets and server-sent event streams.
+package connlog
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultHeartbeat = 30 * time.Second
+
+// Conn tracks a long-lived connection. It logs a record when the connection
+// is opened, periodic heartbeat records while it is open, and a record when
+// it is closed. Every record carries the correlation attributes given to
+// Open; heartbeat and close records add the connection "duration", the
+// number of messages "received" and "sent" and the "last_error", if any.
+// Conn is safe for concurrent use.
+type Conn struct {
+	ctx      context.Context
+	logger   *slog.Logger
+	start    time.Time
+	received atomic.Int64
+	sent     atomic.Int64
+	now      func() time.Time
+
+	mutex   sync.Mutex
+	lastErr error
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// Open logs the opening of a connection and starts its heartbeat. The
+// attributes identify the connection, e.g. a connection ID, the client
+// address or the subscribed topic, and are added to every record. Records
+// are logged with ctx, detached from its cancellation, so otel.Wrap in the
+// handler chain adds the trace context of the request that opened the
+// connection. Close must be called to stop the heartbeat.
+func Open(ctx context.Context, logger *slog.Logger, attrs []slog.Attr, options ...Option) *Conn {
+	config := handlerOptions{heartbeat: defaultHeartbeat}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	args := make([]any, len(attrs))
+	for i, a := range attrs {
+		args[i] = a
+	}
+	c := &Conn{
+		ctx:    context.WithoutCancel(ctx),
+		logger: logger.With(args...),
+		start:  time.Now(),
+		now:    time.Now,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	c.logger.InfoContext(c.ctx, "connection opened")
+	if config.heartbeat > 0 {
+		go c.heartbeat(config.heartbeat)
+	} else {
+		close(c.done)
+	}
+	return c
+}
+
+func (c *Conn) heartbeat(interval time.Duration) {
+	defer close(c.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.logger.LogAttrs(c.ctx, slog.LevelInfo, "connection alive", c.stats()...)
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+func (c *Conn) stats() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.Duration("duration", c.now().Sub(c.start)),
+		slog.Int64("received", c.received.Load()),
+		slog.Int64("sent", c.sent.Load()),
+	}
+	c.mutex.Lock()
+	if c.lastErr != nil {
+		attrs = append(attrs, slog.String("last_error", c.lastErr.Error()))
+	}
+	c.mutex.Unlock()
+	return attrs
+}
+
+// Received counts n messages received from the peer.
+func (c *Conn) Received(n int) {
+	c.received.Add(int64(n))
+}
+
+// Sent counts n messages sent to the peer.
+func (c *Conn) Sent(n int) {
+	c.sent.Add(int64(n))
+}
+
+// Error records err as the last error of the connection without closing it.
+// Nil errors are ignored.
+func (c *Conn) Error(err error) {
+	if err == nil {
+		return
+	}
+	c.mutex.Lock()
+	c.lastErr = err
+	c.mutex.Unlock()
+}
+
+// Logger returns the logger carrying the correlation attributes of the
+// connection, for records about individual messages.
+func (c *Conn) Logger() *slog.Logger {
+	return c.logger
+}
+
+// Close stops the heartbeat and logs the closing of the connection. A
+// non-nil err is the reason the connection was closed; it becomes the last
+// error and the record is logged at Warn instead of Info. Subsequent calls
+// do nothing.
+func (c *Conn) Close(err error) {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return
+	}
+	c.closed = true
+	close(c.stop)
+	c.mutex.Unlock()
+	<-c.done
+
+	c.Error(err)
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+	}
+	c.logger.LogAttrs(c.ctx, level, "connection closed", c.stats()...)
+}
+
+type handlerOptions struct {
+	heartbeat time.Duration
+}
+
+// Option is a function that configures a Conn.
+type Option func(h *handlerOptions)
+
+// WithHeartbeat sets the interval between heartbeat records, 30 seconds by
+// default. A non-positive interval disables them.
+func WithHeartbeat(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.heartbeat = d
+	}
+}
@@ -0,0 +1,89 @@
+package connlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func Test_Conn(t *testing.T) {
+	t.Run("lifecycle records", func(t *testing.T) {
+		buf := &syncBuffer{}
+		logger := slog.New(slog.NewTextHandler(buf, nil))
+
+		c := Open(context.Background(), logger, []slog.Attr{slog.String("conn_id", "c1")}, WithHeartbeat(0))
+		start := c.start
+		c.now = func() time.Time { return start.Add(90 * time.Second) }
+		c.Received(3)
+		c.Sent(2)
+		c.Sent(1)
+		c.Error(errors.New("slow consumer"))
+		c.Close(nil)
+		c.Close(errors.New("ignored"))
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 records, got: %s", buf.String())
+		}
+		if !strings.HasSuffix(lines[0], `msg="connection opened" conn_id=c1`) {
+			t.Errorf("unexpected open record: %s", lines[0])
+		}
+		if !strings.HasSuffix(lines[1], `level=INFO msg="connection closed" conn_id=c1 duration=1m30s received=3 sent=3 last_error="slow consumer"`) {
+			t.Errorf("unexpected close record: %s", lines[1])
+		}
+	})
+
+	t.Run("close with error is a warning", func(t *testing.T) {
+		buf := &syncBuffer{}
+		c := Open(context.Background(), slog.New(slog.NewTextHandler(buf, nil)), nil, WithHeartbeat(0))
+		c.Close(errors.New("reset by peer"))
+
+		if !strings.Contains(buf.String(), `level=WARN msg="connection closed"`) || !strings.Contains(buf.String(), `last_error="reset by peer"`) {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("heartbeat until closed", func(t *testing.T) {
+		buf := &syncBuffer{}
+		ctx, cancel := context.WithCancel(context.Background())
+		c := Open(ctx, slog.New(slog.NewTextHandler(buf, nil)), []slog.Attr{slog.String("topic", "prices")}, WithHeartbeat(5*time.Millisecond))
+		cancel()
+
+		deadline := time.Now().Add(time.Second)
+		for !strings.Contains(buf.String(), "connection alive") && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		c.Close(nil)
+		n := strings.Count(buf.String(), "connection alive")
+		time.Sleep(20 * time.Millisecond)
+
+		if n == 0 || !strings.Contains(buf.String(), `msg="connection alive" topic=prices duration=`) {
+			t.Errorf("expected heartbeat records, got: %s", buf.String())
+		}
+		if strings.Count(buf.String(), "connection alive") != n {
+			t.Errorf("heartbeat continued after close: %s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,363 @@
+// Package console provides a slog.Handler writing human-friendly, colored
+// lines for development, as an alternative to slog.TextHandler.
+package console
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mikluko/slogging/errattr"
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/stack"
+)
+
+const (
+	defaultTimeFormat   = "15:04:05.000"
+	defaultMessageWidth = 40
+	indent              = "  "
+
+	reset = "\033[0m"
+	dim   = "\033[2m"
+	bold  = "\033[1m"
+	red   = "\033[31m"
+	cyan  = "\033[36m"
+)
+
+// badges holds the label and color of the levels, highest first.
+var badges = []struct {
+	level slog.Level
+	label string
+	color string
+}{
+	{slog.LevelError, "ERR", "\033[97;41m"},
+	{slog.LevelWarn, "WRN", "\033[30;43m"},
+	{slog.LevelInfo, "INF", "\033[30;46m"},
+	{slog.LevelDebug, "DBG", "\033[97;100m"},
+}
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	config handlerOptions
+}
+
+// Handler is a slog.Handler writing each record as a line holding the
+// dimmed time, a colored level badge, the message and the top-level
+// attributes as key=value pairs, the messages padded so that attributes of
+// consecutive lines start in the same column. Groups, multi-line values
+// and errors carrying a stack trace, as implemented by
+// errattr.StackTracer, follow on indented lines, keys aligned within each
+// group. Colors are used when the writer is a terminal and the NO_COLOR
+// environment variable is not set, unless set with WithColor.
+type Handler struct {
+	scope scope.Scope
+	state *state
+}
+
+// NewHandler creates a handler writing to w.
+func NewHandler(w io.Writer, options ...Option) *Handler {
+	config := handlerOptions{
+		level:        slog.LevelInfo,
+		color:        isTerminal(w) && os.Getenv("NO_COLOR") == "",
+		timeFormat:   defaultTimeFormat,
+		messageWidth: defaultMessageWidth,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{state: &state{writer: w, config: config}}
+}
+
+// isTerminal reports whether w is a character device, such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Enabled reports whether the level is at or above the minimum level.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.state.config.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// Handle writes the record.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	config := h.state.config
+	p := printer{color: config.color}
+
+	if config.timeFormat != "" && !r.Time.IsZero() {
+		p.style(dim, r.Time.Format(config.timeFormat))
+		p.buf.WriteByte(' ')
+	}
+	p.badge(r.Level)
+	p.buf.WriteByte(' ')
+	p.style(bold, r.Message)
+
+	var inline, block []slog.Attr
+	for _, a := range flatten(h.scope.Attrs(r)) {
+		if a.Value.Kind() == slog.KindGroup || len(lines(a.Value)) > 1 {
+			block = append(block, a)
+		} else {
+			inline = append(inline, a)
+		}
+	}
+	if len(inline) > 0 {
+		if pad := config.messageWidth - len(r.Message); pad > 0 {
+			p.buf.WriteString(strings.Repeat(" ", pad))
+		}
+		for _, a := range inline {
+			p.buf.WriteByte(' ')
+			p.inline(a)
+		}
+	}
+	if config.source && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		p.buf.WriteByte(' ')
+		p.style(dim, "<"+filepath.Base(frame.File)+":"+strconv.Itoa(frame.Line)+">")
+	}
+	p.buf.WriteByte('\n')
+	p.block(block, 1)
+
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	_, err := h.state.writer.Write(p.buf.Bytes())
+	return err
+}
+
+// flatten resolves the attributes, inlines groups with an empty key and
+// drops empty attributes and groups.
+func flatten(attrs []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		if a.Value.Kind() != slog.KindGroup {
+			out = append(out, a)
+			continue
+		}
+		group := flatten(a.Value.Group())
+		if len(group) == 0 {
+			continue
+		}
+		if a.Key == "" {
+			out = append(out, group...)
+			continue
+		}
+		out = append(out, slog.Attr{Key: a.Key, Value: slog.GroupValue(group...)})
+	}
+	return out
+}
+
+// lines renders a value, errors followed by their stack trace.
+func lines(v slog.Value) []string {
+	var s string
+	switch v.Kind() {
+	case slog.KindString:
+		s = v.String()
+	case slog.KindTime:
+		s = v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			s = err.Error()
+			if st, ok := err.(errattr.StackTracer); ok {
+				s += "\n" + stack.Value(st.StackTrace(), stack.String).String()
+			}
+			break
+		}
+		s = fmt.Sprint(v.Any())
+	default:
+		s = v.String()
+	}
+	return strings.Split(strings.TrimRight(s, "\n"), "\n")
+}
+
+func isError(v slog.Value) bool {
+	_, ok := v.Any().(error)
+	return v.Kind() == slog.KindAny && ok
+}
+
+// quote quotes s if it would be ambiguous as an inline value.
+func quote(s string) string {
+	if s == "" || strings.ContainsFunc(s, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '"' || r == '=' || !unicode.IsPrint(r)
+	}) {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+type printer struct {
+	buf   bytes.Buffer
+	color bool
+}
+
+func (p *printer) style(code, s string) {
+	if p.color {
+		p.buf.WriteString(code)
+		p.buf.WriteString(s)
+		p.buf.WriteString(reset)
+		return
+	}
+	p.buf.WriteString(s)
+}
+
+func (p *printer) badge(level slog.Level) {
+	b := badges[len(badges)-1]
+	for _, c := range badges {
+		if level >= c.level {
+			b = c
+			break
+		}
+	}
+	label := b.label
+	if d := level - b.level; d != 0 {
+		label += fmt.Sprintf("%+d", d)
+	}
+	if p.color {
+		p.style(b.color, " "+label+" ")
+		return
+	}
+	p.buf.WriteString(label)
+}
+
+func (p *printer) inline(a slog.Attr) {
+	p.style(cyan, a.Key)
+	p.style(dim, "=")
+	value := quote(lines(a.Value)[0])
+	if isError(a.Value) {
+		p.style(red, value)
+		return
+	}
+	p.buf.WriteString(value)
+}
+
+// block writes the attributes one per line at the given depth, keys padded
+// to the longest one.
+func (p *printer) block(attrs []slog.Attr, depth int) {
+	width := 0
+	for _, a := range attrs {
+		if a.Value.Kind() != slog.KindGroup {
+			width = max(width, len(a.Key))
+		}
+	}
+	prefix := strings.Repeat(indent, depth)
+	for _, a := range attrs {
+		p.buf.WriteString(prefix)
+		if a.Value.Kind() == slog.KindGroup {
+			p.style(cyan, a.Key+":")
+			p.buf.WriteByte('\n')
+			p.block(a.Value.Group(), depth+1)
+			continue
+		}
+		p.style(cyan, a.Key)
+		p.buf.WriteString(strings.Repeat(" ", width-len(a.Key)+2))
+		code := ""
+		if isError(a.Value) {
+			code = red
+		}
+		for i, line := range lines(a.Value) {
+			if i > 0 {
+				p.buf.WriteString(prefix + strings.Repeat(" ", width+2))
+			}
+			if code != "" {
+				p.style(code, line)
+			} else {
+				p.buf.WriteString(line)
+			}
+			p.buf.WriteByte('\n')
+		}
+	}
+}
+
+type handlerOptions struct {
+	level        slog.Leveler
+	color        bool
+	timeFormat   string
+	messageWidth int
+	source       bool
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithColor sets whether ANSI colors are used, overriding the detection
+// of terminals and of the NO_COLOR environment variable.
+func WithColor(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.color = true
+		for i := range x {
+			h.color = x[i]
+		}
+	}
+}
+
+// WithTimeFormat sets the layout of timestamps. An empty layout omits
+// them. Defaults to "15:04:05.000".
+func WithTimeFormat(layout string) Option {
+	return func(h *handlerOptions) {
+		h.timeFormat = layout
+	}
+}
+
+// WithMessageWidth sets the width messages are padded to, aligning the
+// attributes following them. Defaults to 40.
+func WithMessageWidth(n int) Option {
+	return func(h *handlerOptions) {
+		h.messageWidth = n
+	}
+}
+
+// WithSource sets whether the file and line of the logging call are
+// written at the end of the line. It is disabled by default.
+func WithSource(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.source = true
+		for i := range x {
+			h.source = x[i]
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package console
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging/otel"
+)
+
+type tracedError struct {
+	pcs []uintptr
+}
+
+func (e *tracedError) Error() string         { return "disk full" }
+func (e *tracedError) StackTrace() []uintptr { return e.pcs }
+
+func record(level slog.Level, msg string, attrs ...any) slog.Record {
+	r := slog.NewRecord(time.Date(2024, 1, 2, 15, 4, 5, 123e6, time.UTC), level, msg, 0)
+	r.Add(attrs...)
+	return r
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("plain", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := NewHandler(buf, WithLevel(slog.LevelDebug), WithMessageWidth(12))
+		logger := slog.New(h).With("svc", "api")
+		_ = logger.Handler().Handle(context.Background(), record(slog.LevelInfo, "started", "port", 8080, "mode", "dev server"))
+		_ = h.Handle(context.Background(), record(slog.LevelWarn+2, "slow"))
+		_ = h.Handle(context.Background(), record(slog.LevelDebug-4, "noisy", "err", errors.New("boom")))
+		want := "15:04:05.123 INF started      svc=api port=8080 mode=\"dev server\"\n" +
+			"15:04:05.123 WRN+2 slow\n" +
+			"15:04:05.123 DBG-4 noisy        err=boom\n"
+		if buf.String() != want {
+			t.Errorf("unexpected output:\n%s\nwant:\n%s", buf.String(), want)
+		}
+	})
+
+	t.Run("groups and multi-line values", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := NewHandler(buf, WithTimeFormat(""), WithMessageWidth(0))
+		pcs := make([]uintptr, 1)
+		pcs = pcs[:runtime.Callers(1, pcs)]
+		slog.New(h).WithGroup("req").Error("failed",
+			"method", "GET",
+			slog.Group("headers", "accept", "*/*", "user-agent", "curl"),
+			"error", &tracedError{pcs: pcs},
+			"empty", slog.GroupValue(),
+		)
+		got := buf.String()
+		want := "ERR failed\n" +
+			"  req:\n" +
+			"    method  GET\n" +
+			"    headers:\n" +
+			"      accept      */*\n" +
+			"      user-agent  curl\n" +
+			"    error   disk full\n" +
+			"            github.com/mikluko/slogging/console.Test_Handler.func2\n" +
+			"            \t"
+		if !strings.HasPrefix(got, want) {
+			t.Errorf("unexpected output:\n%s\nwant prefix:\n%s", got, want)
+		}
+	})
+
+	t.Run("colors", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := NewHandler(buf, WithColor(), WithMessageWidth(0))
+		_ = h.Handle(context.Background(), record(slog.LevelError, "failed", "err", errors.New("boom")))
+		want := "\033[2m15:04:05.123\033[0m \033[97;41m ERR \033[0m \033[1mfailed\033[0m \033[36merr\033[0m\033[2m=\033[0m\033[31mboom\033[0m\n"
+		if buf.String() != want {
+			t.Errorf("unexpected output: %q", buf.String())
+		}
+		if NewHandler(buf).state.config.color {
+			t.Errorf("colors must be disabled for writers other than terminals")
+		}
+	})
+
+	t.Run("otel", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID: trace.TraceID{1},
+			SpanID:  trace.SpanID{2},
+		}))
+		slog.New(otel.Wrap(NewHandler(buf, WithTimeFormat(""), WithMessageWidth(0)))).InfoContext(ctx, "traced")
+		want := "INF traced\n  otel:\n    trace_id  01000000000000000000000000000000\n    span_id   0200000000000000\n"
+		if buf.String() != want {
+			t.Errorf("unexpected output: %q", buf.String())
+		}
+	})
+}
@@ -0,0 +1,159 @@
+// Package contract pins the output format of handlers. It renders a
+// canonical corpus of records with a handler and compares the output with
+// golden files recorded by a previous version, so that format changes
+// which would break downstream parsers are flagged by tests instead of
+// being discovered in production.
+package contract
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// UpdateEnv is the environment variable which, when set to a non-empty
+// value, makes Check record the current output as the golden files
+// instead of comparing with them.
+const UpdateEnv = "SLOGGING_UPDATE_GOLDEN"
+
+// Time is the timestamp of every record of the corpus.
+var Time = time.Date(2025, time.January, 2, 3, 4, 5, 6000000, time.UTC)
+
+// Formatter describes a handler under contract.
+type Formatter struct {
+	// Name identifies the formatter; golden files are stored in a
+	// directory of that name.
+	Name string
+	// New creates a handler writing to w.
+	New func(w io.Writer) slog.Handler
+	// Normalize, if set, rewrites output parts that legitimately vary
+	// between runs, such as observation timestamps or host names.
+	Normalize func([]byte) []byte
+}
+
+// Case is a record of the corpus along with the derivations of the
+// handler it is logged with and the logging context.
+type Case struct {
+	Name    string
+	Prepare func(slog.Handler) slog.Handler // Optional
+	Context context.Context                 // Optional
+	Record  func() slog.Record
+}
+
+// Corpus returns the canonical corpus: a plain message, every level, every
+// value kind, nested groups derived with WithAttrs and WithGroup, a record
+// logged with a span context, and strings needing escaping.
+func Corpus() []Case {
+	record := func(level slog.Level, msg string, attrs ...slog.Attr) func() slog.Record {
+		return func() slog.Record {
+			r := slog.NewRecord(Time, level, msg, 0)
+			r.AddAttrs(attrs...)
+			return r
+		}
+	}
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	traced := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	return []Case{
+		{Name: "plain", Record: record(slog.LevelInfo, "hello")},
+		{Name: "debug", Record: record(slog.LevelDebug, "debug message")},
+		{Name: "warn", Record: record(slog.LevelWarn, "warn message")},
+		{Name: "error", Record: record(slog.LevelError, "error message", slog.Any("error", errors.New("boom")))},
+		{Name: "custom_level", Record: record(slog.LevelError+4, "critical message")},
+		{Name: "kinds", Record: record(slog.LevelInfo, "kinds",
+			slog.String("string", "value"),
+			slog.Int64("int", -42),
+			slog.Uint64("uint", 42),
+			slog.Float64("float", 1.5),
+			slog.Bool("bool", true),
+			slog.Duration("duration", 1500*time.Millisecond),
+			slog.Time("time", Time.Add(time.Hour)),
+			slog.Any("any", struct{ A int }{A: 1}),
+		)},
+		{
+			Name: "groups",
+			Prepare: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.String("top", "1")}).WithGroup("outer").WithAttrs([]slog.Attr{slog.String("mid", "2")}).WithGroup("inner")
+			},
+			Record: record(slog.LevelInfo, "groups",
+				slog.String("leaf", "3"),
+				slog.Group("inline", slog.Int("a", 1)),
+				slog.Group("", slog.Int("flattened", 2)),
+				slog.Group("empty"),
+			),
+		},
+		{Name: "trace", Context: traced, Record: record(slog.LevelInfo, "traced")},
+		{Name: "escaping", Record: record(slog.LevelInfo, "quote \" newline \n tab \t unicode é ✓",
+			slog.String("key with space", "value \"quoted\""),
+			slog.String("html", "<a href='x'>&</a>"),
+		)},
+	}
+}
+
+// Render logs c with a handler created by f and returns the normalized
+// output.
+func Render(f Formatter, c Case) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	h := f.New(buf)
+	if c.Prepare != nil {
+		h = c.Prepare(h)
+	}
+	ctx := c.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := h.Handle(ctx, c.Record()); err != nil {
+		return nil, fmt.Errorf("error when rendering %s/%s: %w", f.Name, c.Name, err)
+	}
+	out := buf.Bytes()
+	if f.Normalize != nil {
+		out = f.Normalize(out)
+	}
+	return out, nil
+}
+
+// Check renders every case of the corpus with f and compares the output
+// with the golden files dir/NAME/CASE.golden, reporting differences as
+// test errors. When UpdateEnv is set, the golden files are written instead.
+func Check(t testing.TB, dir string, f Formatter) {
+	t.Helper()
+	update := os.Getenv(UpdateEnv) != ""
+	for _, c := range Corpus() {
+		got, err := Render(f, c)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		path := filepath.Join(dir, f.Name, c.Name+".golden")
+		if update {
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, got, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("%s: %v (set %s=1 to record)", path, err, UpdateEnv)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: output changed\n got: %q\nwant: %q", path, got, want)
+		}
+	}
+}
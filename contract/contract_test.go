@@ -0,0 +1,61 @@
+package contract
+
+import (
+	"io"
+	"log/slog"
+	"regexp"
+	"testing"
+
+	"github.com/mikluko/slogging/ecs"
+	"github.com/mikluko/slogging/gcp"
+	"github.com/mikluko/slogging/gelf"
+	"github.com/mikluko/slogging/otlpjson"
+	"github.com/mikluko/slogging/pretty"
+)
+
+var observedTime = regexp.MustCompile(`"observedTimeUnixNano":"\d+"`)
+
+// Test_Formatters pins the output of the formatters of this module. Run
+// with SLOGGING_UPDATE_GOLDEN=1 to record intentional format changes.
+func Test_Formatters(t *testing.T) {
+	formatters := []Formatter{
+		{
+			Name: "pretty",
+			New: func(w io.Writer) slog.Handler {
+				return pretty.NewHandler(pretty.WithWriter(w), pretty.WithLevel(slog.LevelDebug))
+			},
+		},
+		{
+			Name: "otlpjson",
+			New: func(w io.Writer) slog.Handler {
+				return otlpjson.NewHandler(otlpjson.WithWriter(w), otlpjson.WithLevel(slog.LevelDebug))
+			},
+			Normalize: func(b []byte) []byte {
+				return observedTime.ReplaceAll(b, []byte(`"observedTimeUnixNano":"0"`))
+			},
+		},
+		{
+			Name: "gcp",
+			New: func(w io.Writer) slog.Handler {
+				return gcp.NewHandler(gcp.WithWriter(w), gcp.WithProjectID("project"))
+			},
+		},
+		{
+			Name: "ecs",
+			New: func(w io.Writer) slog.Handler {
+				return ecs.NewHandler(ecs.WithWriter(w))
+			},
+		},
+		{
+			Name: "gelf",
+			New: func(w io.Writer) slog.Handler {
+				return gelf.NewHandler(gelf.WithWriter(w), gelf.WithHost("host"))
+			},
+		},
+	}
+	for _, f := range formatters {
+		t.Run(f.Name, func(t *testing.T) {
+			Check(t, "testdata", f)
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package slogging
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"time"
+)
+
+// ToMap converts r into a map, for exporters writing records to stores
+// with their own encoding, such as SQL and ClickHouse tables. The time,
+// level and message are stored under slog.TimeKey, slog.LevelKey and
+// slog.MessageKey as a time.Time, a slog.Level and a string; the time is
+// omitted if zero. Attributes are added as described by PutAttrs.
+//
+// Collisions are resolved as follows:
+//   - the built-in keys win over top-level attributes with the same key,
+//     even when the time is omitted;
+//   - of two attributes with the same key at the same depth, the last one
+//     wins, unless both are groups, which are merged recursively.
+func ToMap(r slog.Record) map[string]any {
+	m := make(map[string]any, r.NumAttrs()+3)
+	r.Attrs(func(a slog.Attr) bool {
+		PutAttrs(m, a)
+		return true
+	})
+	if !r.Time.IsZero() {
+		m[slog.TimeKey] = r.Time
+	} else {
+		delete(m, slog.TimeKey)
+	}
+	m[slog.LevelKey] = r.Level
+	m[slog.MessageKey] = r.Message
+	return m
+}
+
+// PutAttrs adds attrs to m, following the collision rules of ToMap. Values
+// are resolved and stored as the Go type of their kind: string, int64,
+// uint64, float64, bool, time.Duration, time.Time or the value of
+// slog.KindAny attributes. Groups become nested map[string]any, groups with
+// an empty key are inlined, and empty attributes and empty groups are
+// dropped. It is meant for exporters adding the attributes of WithAttrs
+// calls, nested under the groups of WithGroup calls, to the map returned
+// by ToMap.
+func PutAttrs(m map[string]any, attrs ...slog.Attr) {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		if a.Value.Kind() != slog.KindGroup {
+			m[a.Key] = a.Value.Any()
+			continue
+		}
+		group := a.Value.Group()
+		if len(group) == 0 {
+			continue
+		}
+		if a.Key == "" {
+			PutAttrs(m, group...)
+			continue
+		}
+		inner, ok := m[a.Key].(map[string]any)
+		if !ok {
+			inner = make(map[string]any, len(group))
+		}
+		PutAttrs(inner, group...)
+		if len(inner) > 0 {
+			m[a.Key] = inner
+		}
+	}
+}
+
+// FromMap converts m, as returned by ToMap, back into a record. The time
+// is read from slog.TimeKey as a time.Time or an RFC 3339 string, the level
+// from slog.LevelKey as a slog.Level, an integer or a level name such as
+// "WARN" or "ERROR+2", and the message from slog.MessageKey as a string;
+// values of other types are kept as attributes. The remaining entries
+// become attributes sorted by key, nested map[string]any becoming groups.
+// The PC of the record is zero.
+func FromMap(m map[string]any) slog.Record {
+	rest := maps.Clone(m)
+	var (
+		t     time.Time
+		level slog.Level
+		msg   string
+	)
+	switch v := m[slog.TimeKey].(type) {
+	case time.Time:
+		t = v
+		delete(rest, slog.TimeKey)
+	case string:
+		if parsed, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			t = parsed
+			delete(rest, slog.TimeKey)
+		}
+	}
+	switch v := m[slog.LevelKey].(type) {
+	case slog.Level:
+		level = v
+		delete(rest, slog.LevelKey)
+	case int:
+		level = slog.Level(v)
+		delete(rest, slog.LevelKey)
+	case string:
+		if level.UnmarshalText([]byte(v)) == nil {
+			delete(rest, slog.LevelKey)
+		}
+	}
+	if v, ok := m[slog.MessageKey].(string); ok {
+		msg = v
+		delete(rest, slog.MessageKey)
+	}
+	r := slog.NewRecord(t, level, msg, 0)
+	r.AddAttrs(mapAttrs(rest)...)
+	return r
+}
+
+func mapAttrs(m map[string]any) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(m))
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		if inner, ok := m[k].(map[string]any); ok {
+			attrs = append(attrs, slog.Attr{Key: k, Value: slog.GroupValue(mapAttrs(inner)...)})
+			continue
+		}
+		attrs = append(attrs, slog.Any(k, m[k]))
+	}
+	return attrs
+}
@@ -0,0 +1,68 @@
+package slogging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_ToMap(t *testing.T) {
+	now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	r := slog.NewRecord(now, slog.LevelWarn, "test message", 0)
+	r.AddAttrs(
+		slog.String("msg", "shadowed"),
+		slog.Int("n", 1),
+		slog.Group("g", slog.String("a", "1")),
+		slog.Group("g", slog.String("b", "2")),
+		slog.Group("", slog.Bool("inlined", true)),
+		slog.Group("empty"),
+		slog.String("k", "first"),
+		slog.Duration("k", time.Second),
+	)
+
+	want := map[string]any{
+		"time":    now,
+		"level":   slog.LevelWarn,
+		"msg":     "test message",
+		"n":       int64(1),
+		"g":       map[string]any{"a": "1", "b": "2"},
+		"inlined": true,
+		"k":       time.Second,
+	}
+	if got := ToMap(r); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func Test_FromMap(t *testing.T) {
+	t.Run("round trip", func(t *testing.T) {
+		now := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+		r := slog.NewRecord(now, slog.LevelError, "test message", 0)
+		r.AddAttrs(slog.Group("g", slog.Int("b", 2), slog.Int("a", 1)), slog.String("k", "v"))
+
+		buf := new(bytes.Buffer)
+		if err := slog.NewTextHandler(buf, nil).Handle(context.Background(), FromMap(ToMap(r))); err != nil {
+			t.Fatal(err)
+		}
+		want := `time=2025-01-02T03:04:05.000Z level=ERROR msg="test message" g.a=1 g.b=2 k=v`
+		if strings.TrimSpace(buf.String()) != want {
+			t.Errorf("expected %s, got: %s", want, buf.String())
+		}
+	})
+
+	t.Run("decoded values", func(t *testing.T) {
+		r := FromMap(map[string]any{"time": "2025-01-02T03:04:05Z", "level": "WARN+2", "msg": "m", "time_taken": 1.5})
+		if r.Time.IsZero() || r.Level != slog.LevelWarn+2 || r.Message != "m" || r.NumAttrs() != 1 {
+			t.Errorf("unexpected record: %v", r)
+		}
+
+		r = FromMap(map[string]any{"level": "unknown"})
+		if r.Level != slog.LevelInfo || r.NumAttrs() != 1 {
+			t.Errorf("expected unparsable level to be kept as attribute: %v", r)
+		}
+	})
+}
@@ -0,0 +1,233 @@
+// Package counting provides a slog.Handler that does not persist records
+// but only counts them by level and fingerprint, for pipeline branches
+// keeping full-fidelity counts while the records themselves are sampled.
+package counting
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/mikluko/slogging/internal/fingerprint"
+)
+
+const defaultMaxFingerprints = 10000
+
+// Other is the fingerprint records are counted under once the number of
+// distinct fingerprints reaches the limit set with WithMaxFingerprints.
+const Other = "(other)"
+
+// Fingerprint computes the identity of a record for counting purposes.
+type Fingerprint func(r slog.Record) string
+
+// Fields returns a Fingerprint made of the record message followed by the
+// values of the given top-level attribute keys, as in
+// "payment failed provider=stripe". When similar is set, digit sequences
+// in the message are replaced with '#', so "retry 1 of 5" and "retry 2 of
+// 5" share a fingerprint.
+func Fields(similar bool, keys ...string) Fingerprint {
+	return func(r slog.Record) string {
+		return fingerprint.Fields(r, similar, keys...)
+	}
+}
+
+// Count is the number of records of a level and fingerprint.
+type Count struct {
+	Level       slog.Level
+	Fingerprint string
+	Count       uint64
+	First       time.Time
+	Last        time.Time
+}
+
+type countKey struct {
+	level       slog.Level
+	fingerprint string
+}
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	mutex   sync.Mutex
+	counts  map[countKey]*Count
+	byLevel map[slog.Level]uint64
+	total   uint64
+}
+
+// Handler is a slog.Handler counting the records at or above a level,
+// slog.LevelDebug by default, by level and fingerprint without writing
+// them anywhere. It is meant to be one branch of a multi handler whose
+// other branch samples the records actually stored:
+//
+//	multi.New(sample.Wrap(json, sample.Probability(0.01)), counting.NewHandler())
+//
+// The number of distinct fingerprints is capped to bound memory; records
+// of fingerprints seen after the cap is reached are counted under Other.
+type Handler struct {
+	state   *state
+	config  handlerOptions
+	attrs   []slog.Attr // Top-level attributes added via WithAttrs
+	grouped bool
+}
+
+// NewHandler creates a counting handler.
+func NewHandler(options ...Option) *Handler {
+	config := handlerOptions{
+		level:           slog.LevelDebug,
+		fingerprint:     Fields(false),
+		maxFingerprints: defaultMaxFingerprints,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{
+		state: &state{
+			counts:  make(map[countKey]*Count),
+			byLevel: make(map[slog.Level]uint64),
+		},
+		config: config,
+	}
+}
+
+// Enabled reports whether records at the given level are counted.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.config.level.Level()
+}
+
+// Handle counts the record. The top-level attributes added via WithAttrs
+// are visible to the fingerprint function as attributes of the record.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	if len(h.attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(h.attrs...)
+	}
+	key := countKey{level: r.Level, fingerprint: h.config.fingerprint(r)}
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	s := h.state
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.total++
+	s.byLevel[r.Level]++
+	c, ok := s.counts[key]
+	if !ok {
+		if len(s.counts) >= h.config.maxFingerprints {
+			key.fingerprint = Other
+			c, ok = s.counts[key]
+		}
+		if !ok {
+			c = &Count{Level: key.level, Fingerprint: key.fingerprint, First: t}
+			s.counts[key] = c
+		}
+	}
+	c.Count++
+	c.Last = t
+	return nil
+}
+
+// WithAttrs returns a new Handler sharing the counts, whose fingerprints
+// see the given attributes if no group was opened.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 || h.grouped {
+		return h
+	}
+	h2 := *h
+	h2.attrs = append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...)
+	return &h2
+}
+
+// WithGroup returns a new Handler sharing the counts. Attributes added
+// after a group is opened are not seen by fingerprints.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" || h.grouped {
+		return h
+	}
+	h2 := *h
+	h2.grouped = true
+	return &h2
+}
+
+// Total returns the number of records counted.
+func (h *Handler) Total() uint64 {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	return h.state.total
+}
+
+// ByLevel returns the number of records counted per level.
+func (h *Handler) ByLevel() map[slog.Level]uint64 {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	out := make(map[slog.Level]uint64, len(h.state.byLevel))
+	for l, n := range h.state.byLevel {
+		out[l] = n
+	}
+	return out
+}
+
+// Counts returns the counts per level and fingerprint, the highest first.
+func (h *Handler) Counts() []Count {
+	h.state.mutex.Lock()
+	out := make([]Count, 0, len(h.state.counts))
+	for _, c := range h.state.counts {
+		out = append(out, *c)
+	}
+	h.state.mutex.Unlock()
+	slices.SortFunc(out, func(a, b Count) int {
+		return cmp.Or(
+			cmp.Compare(b.Count, a.Count),
+			cmp.Compare(b.Level, a.Level),
+			cmp.Compare(a.Fingerprint, b.Fingerprint),
+		)
+	})
+	return out
+}
+
+// Reset discards the counts.
+func (h *Handler) Reset() {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	clear(h.state.counts)
+	clear(h.state.byLevel)
+	h.state.total = 0
+}
+
+type handlerOptions struct {
+	level           slog.Leveler
+	fingerprint     Fingerprint
+	maxFingerprints int
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the minimum level of counted records. Defaults to
+// slog.LevelDebug.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithFingerprint sets the function computing the fingerprint of records.
+// Defaults to Fields(false), the message alone.
+func WithFingerprint(fn Fingerprint) Option {
+	return func(h *handlerOptions) {
+		h.fingerprint = fn
+	}
+}
+
+// WithMaxFingerprints sets the number of distinct fingerprints counted
+// before records of new fingerprints are counted under Other. Defaults to
+// 10000.
+func WithMaxFingerprints(n int) Option {
+	return func(h *handlerOptions) {
+		h.maxFingerprints = max(n, 1)
+	}
+}
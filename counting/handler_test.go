@@ -0,0 +1,85 @@
+package counting
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/slogging/multi"
+	"github.com/mikluko/slogging/sample"
+)
+
+func Test_Handler(t *testing.T) {
+	t.Run("records are counted by level and fingerprint", func(t *testing.T) {
+		h := NewHandler(WithLevel(slog.LevelInfo), WithFingerprint(Fields(true, "provider")))
+		logger := slog.New(h).With("provider", "stripe")
+		for i := range 3 {
+			logger.Error("payment failed", "attempt", i)
+		}
+		logger.Error("retry 1 of 5")
+		logger.Error("retry 2 of 5")
+		logger.WithGroup("g").Info("grouped", "provider", "ignored")
+		logger.Debug("not counted")
+
+		if h.Total() != 6 {
+			t.Errorf("total = %d, want 6", h.Total())
+		}
+		if by := h.ByLevel(); by[slog.LevelError] != 5 || by[slog.LevelInfo] != 1 {
+			t.Errorf("by level = %v", by)
+		}
+		counts := h.Counts()
+		want := []struct {
+			fp string
+			n  uint64
+		}{
+			{"payment failed provider=stripe", 3},
+			{"retry # of # provider=stripe", 2},
+			{"grouped provider=stripe", 1},
+		}
+		if len(counts) != len(want) {
+			t.Fatalf("counts = %+v", counts)
+		}
+		for i, w := range want {
+			if counts[i].Fingerprint != w.fp || counts[i].Count != w.n {
+				t.Errorf("count %d = %+v, want %+v", i, counts[i], w)
+			}
+		}
+	})
+
+	t.Run("fingerprints are capped", func(t *testing.T) {
+		h := NewHandler(WithMaxFingerprints(2))
+		logger := slog.New(h)
+		for _, msg := range []string{"a", "b", "c", "d", "a"} {
+			logger.Info(msg)
+		}
+		counts := h.Counts()
+		if len(counts) != 3 || counts[0].Fingerprint != "a" && counts[0].Fingerprint != Other {
+			t.Fatalf("counts = %+v", counts)
+		}
+		for _, c := range counts {
+			if c.Fingerprint == Other && c.Count != 2 {
+				t.Errorf("other = %d, want 2", c.Count)
+			}
+		}
+		h.Reset()
+		if h.Total() != 0 || len(h.Counts()) != 0 {
+			t.Error("counts not reset")
+		}
+	})
+
+	t.Run("counts every record while persisting a sample", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		counter := NewHandler()
+		logger := slog.New(multi.New(sample.Wrap(slog.NewTextHandler(buf, nil), sample.EveryNth(10)), counter))
+		for range 100 {
+			logger.Info("request served")
+		}
+		if counter.Total() != 100 {
+			t.Errorf("total = %d, want 100", counter.Total())
+		}
+		if n := strings.Count(buf.String(), "\n"); n != 10 {
+			t.Errorf("persisted %d records, want 10", n)
+		}
+	})
+}
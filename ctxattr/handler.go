@@ -0,0 +1,105 @@
+package ctxattr
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+type contextKey struct{}
+
+// ContextKey returns the key under which attributes are stored in the
+// context, e.g. for async.CaptureKeys.
+func ContextKey() any {
+	return contextKey{}
+}
+
+// With returns a copy of ctx carrying attrs in addition to the attributes
+// already stored in ctx.
+func With(ctx context.Context, attrs ...slog.Attr) context.Context {
+	if len(attrs) == 0 {
+		return ctx
+	}
+	prev := Attrs(ctx)
+	merged := make([]slog.Attr, len(prev)+len(attrs))
+	copy(merged, prev)
+	copy(merged[len(prev):], attrs)
+	return context.WithValue(ctx, contextKey{}, merged)
+}
+
+// Attrs returns the attributes stored in ctx. The returned slice must not
+// be modified.
+func Attrs(ctx context.Context) []slog.Attr {
+	attrs, _ := ctx.Value(contextKey{}).([]slog.Attr)
+	return attrs
+}
+
+// Handler is a slog.Handler that adds the attributes stored in the logging
+// context with With to every record. They are added at the top level,
+// after the attributes added via WithAttrs before any group was opened and
+// before the attributes of the record, regardless of the groups opened
+// with WithGroup, so request-scoped fields like user_id or tenant keep
+// their keys whatever logger they are logged with.
+type Handler struct {
+	handler slog.Handler // Wrapped handler with derivations applied
+	root    slog.Handler // Wrapped handler without derivations
+	scope   scope.Scope
+}
+
+// Wrap creates a handler adding context attributes to records delivered
+// to handler.
+func Wrap(handler slog.Handler) *Handler {
+	return &Handler{handler: handler, root: handler}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle adds the context attributes to the record and delivers it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := Attrs(ctx)
+	if len(attrs) == 0 {
+		return h.handler.Handle(ctx, r)
+	}
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	groups := h.scope.Groups()
+	if len(groups) == 0 {
+		nr.AddAttrs(attrs...)
+		r.Attrs(func(a slog.Attr) bool {
+			nr.AddAttrs(a)
+			return true
+		})
+		return h.handler.Handle(ctx, nr)
+	}
+	// Groups are open on the derived handler, so the record is rebuilt on
+	// the root handler to keep the context attributes at the top level,
+	// after the top-level attributes added via WithAttrs.
+	nested := h.scope.Attrs(r)
+	n := len(nested)
+	if n > 0 && nested[n-1].Key == groups[0] && nested[n-1].Value.Kind() == slog.KindGroup {
+		n--
+	}
+	nr.AddAttrs(nested[:n]...)
+	nr.AddAttrs(attrs...)
+	nr.AddAttrs(nested[n:]...)
+	return h.root.Handle(ctx, nr)
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &Handler{handler: h.handler.WithAttrs(attrs), root: h.root, scope: h.scope.WithAttrs(attrs)}
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{handler: h.handler.WithGroup(name), root: h.root, scope: h.scope.WithGroup(name)}
+}
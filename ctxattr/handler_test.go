@@ -0,0 +1,58 @@
+package ctxattr
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_Handler(t *testing.T) {
+	t.Run("context attributes are added to records", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil))).With("svc", "api")
+
+		ctx := With(context.Background(), slog.String("tenant", "acme"))
+		ctx = With(ctx, slog.Int("user_id", 42))
+		logger.InfoContext(ctx, "test message", "k", "v")
+		logger.Info("no context")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if !strings.HasSuffix(lines[0], `msg="test message" svc=api tenant=acme user_id=42 k=v`) {
+			t.Errorf("unexpected output: %s", lines[0])
+		}
+		if strings.Contains(lines[1], "tenant") {
+			t.Errorf("unexpected context attributes: %s", lines[1])
+		}
+	})
+
+	t.Run("context attributes stay at top level", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil))).With("svc", "api").WithGroup("req").With("id", 1)
+
+		ctx := With(context.Background(), slog.String("tenant", "acme"))
+		logger.InfoContext(ctx, "test message", "k", "v")
+		logger.Info("no context", "k", "v")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if !strings.HasSuffix(lines[0], `msg="test message" svc=api tenant=acme req.id=1 req.k=v`) {
+			t.Errorf("unexpected output: %s", lines[0])
+		}
+		if !strings.HasSuffix(lines[1], `msg="no context" svc=api req.id=1 req.k=v`) {
+			t.Errorf("unexpected output: %s", lines[1])
+		}
+	})
+
+	t.Run("derived contexts do not share attributes", func(t *testing.T) {
+		base := With(context.Background(), slog.Int("a", 1))
+		c1 := With(base, slog.Int("b", 2))
+		c2 := With(base, slog.Int("c", 3))
+		if len(Attrs(base)) != 1 || Attrs(c1)[1].Key != "b" || Attrs(c2)[1].Key != "c" {
+			t.Errorf("unexpected attributes: %v %v %v", Attrs(base), Attrs(c1), Attrs(c2))
+		}
+		if With(base) != base {
+			t.Errorf("expected context unchanged without attributes")
+		}
+	})
+}
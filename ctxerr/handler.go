@@ -0,0 +1,80 @@
+package ctxerr
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Key is the group holding the cancellation details of the logging context.
+const Key = "ctx"
+
+// Attr returns the cancellation details of ctx as a "ctx" group holding
+// "err" (ctx.Err()), "cause" (context.Cause(ctx)) and, if ctx has a
+// deadline, "remaining", the time left until it, negative once it has
+// passed. It returns an empty attribute, which handlers ignore, if ctx is
+// not done.
+func Attr(ctx context.Context) slog.Attr {
+	return attr(ctx, time.Now)
+}
+
+func attr(ctx context.Context, now func() time.Time) slog.Attr {
+	err := ctx.Err()
+	if err == nil {
+		return slog.Attr{}
+	}
+	attrs := []slog.Attr{slog.String("err", err.Error())}
+	if cause := context.Cause(ctx); cause != nil {
+		attrs = append(attrs, slog.String("cause", cause.Error()))
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		attrs = append(attrs, slog.Duration("remaining", deadline.Sub(now())))
+	}
+	return slog.Attr{Key: Key, Value: slog.GroupValue(attrs...)}
+}
+
+// Handler is a slog.Handler that adds the cancellation details of the
+// logging context, as returned by Attr, to records logged with a context
+// that is already done. It helps tracing timeout cascades back to their
+// origin.
+type Handler struct {
+	handler slog.Handler
+	now     func() time.Time
+}
+
+// Wrap creates a handler adding cancellation details to records delivered
+// to handler.
+func Wrap(handler slog.Handler) *Handler {
+	return &Handler{handler: handler, now: time.Now}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle adds the cancellation details of ctx to the record if ctx is done
+// and delivers it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx.Err() != nil {
+		r = r.Clone()
+		r.AddAttrs(attr(ctx, h.now))
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &Handler{handler: h.handler.WithAttrs(attrs), now: h.now}
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{handler: h.handler.WithGroup(name), now: h.now}
+}
@@ -0,0 +1,60 @@
+package ctxerr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Handler(t *testing.T) {
+	t.Run("cancelled context adds err and cause", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil)))
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+		logger.InfoContext(ctx, "live")
+		cancel(errors.New("client went away"))
+		logger.InfoContext(ctx, "cancelled")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if strings.Contains(lines[0], "ctx.") {
+			t.Errorf("unexpected details on live context: %s", lines[0])
+		}
+		if !strings.HasSuffix(lines[1], `msg=cancelled ctx.err="context canceled" ctx.cause="client went away"`) {
+			t.Errorf("unexpected output: %s", lines[1])
+		}
+	})
+
+	t.Run("deadline reports remaining time", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil))
+		deadline := time.Now().Add(time.Hour)
+		h.now = func() time.Time { return deadline.Add(150 * time.Millisecond) }
+		logger := slog.New(h)
+
+		ctx, cancel := context.WithDeadlineCause(context.Background(), deadline, errors.New("upstream budget"))
+		cancel()
+		logger.WarnContext(ctx, "slow")
+
+		if !strings.Contains(buf.String(), `ctx.err="context canceled" ctx.cause="context canceled" ctx.remaining=-150ms`) {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("attr of live context is empty", func(t *testing.T) {
+		if a := Attr(context.Background()); !a.Equal(slog.Attr{}) {
+			t.Errorf("expected empty attribute, got %v", a)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 0)
+		defer cancel()
+		<-ctx.Done()
+		a := Attr(ctx)
+		if a.Key != Key || !strings.Contains(a.Value.String(), "err=context deadline exceeded cause=context deadline exceeded remaining=-") {
+			t.Errorf("unexpected attribute: %v", a)
+		}
+	})
+}
@@ -0,0 +1,211 @@
+package dedup
+
+import (
+	"context"
+	"hash/maphash"
+	"log/slog"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mikluko/slogging"
+)
+
+const (
+	// RepeatCountKey is the attribute reporting how many identical records
+	// were collapsed into the repeat record.
+	RepeatCountKey = "repeat_count"
+
+	defaultQuiet = time.Second
+)
+
+var (
+	seed   = maphash.MakeSeed()
+	nextID atomic.Uint64
+)
+
+// state is shared across WithAttrs/WithGroup derivations, so that
+// consecutive records are compared regardless of the logger they were
+// logged with.
+type state struct {
+	mutex   sync.Mutex
+	hash    uint64
+	count   int // Suppressed repetitions of the last record
+	ctx     context.Context
+	handler slog.Handler
+	record  slog.Record
+	timer   *time.Timer
+	quiet   time.Duration
+	onError func(error)
+}
+
+// Handler is a slog.Handler that collapses consecutive identical records.
+// The first record is delivered immediately; identical records following it
+// are suppressed until a different record arrives or no identical record
+// was logged for the quiet period. A copy of the record with a
+// "repeat_count" attribute holding the number of suppressed repetitions is
+// then delivered, similar to syslog's "last message repeated N times".
+// Records are identical when level, message, attributes and the logger
+// they were logged with are equal.
+type Handler struct {
+	handler slog.Handler
+	state   *state
+	id      uint64 // Identifies the logger derivation
+}
+
+// Wrap creates a deduplicating handler delivering to handler.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{quiet: defaultQuiet}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{
+		handler: handler,
+		id:      nextID.Add(1),
+		state: &state{
+			quiet:   config.quiet,
+			onError: config.onError,
+		},
+	}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle delivers the record unless it repeats the previous one.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	sum := h.hash(r)
+	s := h.state
+
+	s.mutex.Lock()
+	if s.handler != nil && s.hash == sum {
+		s.count++
+		if s.timer == nil {
+			s.timer = time.AfterFunc(s.quiet, s.flushAsync)
+		} else {
+			s.timer.Reset(s.quiet)
+		}
+		s.mutex.Unlock()
+		return nil
+	}
+	pending := s.take()
+	s.hash = sum
+	s.ctx = context.WithoutCancel(ctx)
+	s.handler = h.handler
+	s.record = slogging.CloneRecord(r)
+	s.mutex.Unlock()
+
+	if pending != nil {
+		if err := pending(); err != nil && s.onError != nil {
+			s.onError(err)
+		}
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *Handler) hash(r slog.Record) uint64 {
+	var mh maphash.Hash
+	mh.SetSeed(seed)
+	_, _ = mh.WriteString(strconv.FormatUint(h.id, 10))
+	_ = mh.WriteByte(0)
+	_, _ = mh.WriteString(r.Level.String())
+	_ = mh.WriteByte(0)
+	_, _ = mh.WriteString(r.Message)
+	r.Attrs(func(a slog.Attr) bool {
+		_ = mh.WriteByte(0)
+		_, _ = mh.WriteString(a.Key)
+		_ = mh.WriteByte('=')
+		_, _ = mh.WriteString(a.Value.Resolve().String())
+		return true
+	})
+	return mh.Sum64()
+}
+
+// take returns a function delivering the repeat record of the last record,
+// or nil if it was not repeated, and resets the repetition count. Must be
+// called with the mutex held.
+func (s *state) take() func() error {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	if s.count == 0 {
+		return nil
+	}
+	n := s.count
+	s.count = 0
+	ctx, handler, record := s.ctx, s.handler, s.record.Clone()
+	return func() error {
+		record.AddAttrs(slog.Int(RepeatCountKey, n))
+		return handler.Handle(ctx, record)
+	}
+}
+
+func (s *state) flushAsync() {
+	s.mutex.Lock()
+	s.timer = nil
+	pending := s.take()
+	s.mutex.Unlock()
+	if pending != nil {
+		if err := pending(); err != nil && s.onError != nil {
+			s.onError(err)
+		}
+	}
+}
+
+// Flush delivers the repeat record of the last record immediately if it
+// was repeated.
+func (h *Handler) Flush() error {
+	h.state.mutex.Lock()
+	pending := h.state.take()
+	h.state.mutex.Unlock()
+	if pending == nil {
+		return nil
+	}
+	return pending()
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &Handler{handler: h.handler.WithAttrs(attrs), state: h.state, id: nextID.Add(1)}
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{handler: h.handler.WithGroup(name), state: h.state, id: nextID.Add(1)}
+}
+
+type handlerOptions struct {
+	quiet   time.Duration
+	onError func(error)
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithQuietPeriod sets how long no identical record must be logged before
+// the repeat record is delivered.
+func WithQuietPeriod(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.quiet = d
+	}
+}
+
+// WithErrorHandler sets a function called with errors returned by the
+// wrapped handler when delivering repeat records in the background.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
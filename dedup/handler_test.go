@@ -0,0 +1,108 @@
+package dedup
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func Test_Dedup(t *testing.T) {
+	t.Run("repeats are collapsed after quiet period", func(t *testing.T) {
+		buf := &syncBuffer{}
+		h := Wrap(slog.NewTextHandler(buf, nil), WithQuietPeriod(20*time.Millisecond))
+		logger := slog.New(h)
+
+		for i := 0; i < 5; i++ {
+			logger.Warn("disk full", "dev", "sda")
+		}
+		if n := strings.Count(buf.String(), "disk full"); n != 1 {
+			t.Errorf("expected 1 delivered record, got %d: %s", n, buf.String())
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for !strings.Contains(buf.String(), RepeatCountKey) && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if !strings.Contains(buf.String(), `msg="disk full" dev=sda repeat_count=4`) {
+			t.Errorf("expected repeat record, got: %s", buf.String())
+		}
+	})
+
+	t.Run("different record flushes repeats", func(t *testing.T) {
+		buf := &syncBuffer{}
+		h := Wrap(slog.NewTextHandler(buf, nil), WithQuietPeriod(time.Minute))
+		logger := slog.New(h)
+
+		logger.Info("a")
+		logger.Info("a")
+		logger.Info("b")
+		logger.Info("a")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 4 {
+			t.Fatalf("expected 4 lines, got: %s", buf.String())
+		}
+		if !strings.Contains(lines[1], "msg=a repeat_count=1") || !strings.Contains(lines[2], "msg=b") {
+			t.Errorf("unexpected order: %s", buf.String())
+		}
+	})
+
+	t.Run("attributes and loggers distinguish records", func(t *testing.T) {
+		buf := &syncBuffer{}
+		h := Wrap(slog.NewTextHandler(buf, nil), WithQuietPeriod(time.Minute))
+		logger := slog.New(h)
+
+		logger.Info("a", "n", 1)
+		logger.Info("a", "n", 2)
+		logger.With("x", 1).Info("a", "n", 2)
+
+		if n := strings.Count(buf.String(), "msg=a"); n != 3 {
+			t.Errorf("expected 3 records, got: %s", buf.String())
+		}
+		if strings.Contains(buf.String(), RepeatCountKey) {
+			t.Errorf("unexpected repeat record: %s", buf.String())
+		}
+	})
+
+	t.Run("flush delivers pending repeats", func(t *testing.T) {
+		buf := &syncBuffer{}
+		h := Wrap(slog.NewTextHandler(buf, nil), WithQuietPeriod(time.Minute))
+		logger := slog.New(h).WithGroup("g")
+
+		logger.Info("a", "k", "v")
+		logger.Info("a", "k", "v")
+		logger.Info("a", "k", "v")
+		if err := h.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "g.k=v g.repeat_count=2") {
+			t.Errorf("expected repeat record, got: %s", buf.String())
+		}
+		if err := h.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(buf.String(), RepeatCountKey); n != 1 {
+			t.Errorf("expected single repeat record, got: %s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package slogging
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// earlyCapacity is the number of records kept until a handler is set, the
+// oldest being dropped beyond it.
+const earlyCapacity = 1024
+
+// pipeline is the process-wide handler of the package-level functions. It
+// is set up when the package is initialized, before the packages importing
+// it, so that they can log from their own initialization.
+var pipeline = NewSwappable(&earlyHandler{state: new(earlyState)})
+
+// earlyRecord is a record logged before a handler was set.
+type earlyRecord struct {
+	ctx         context.Context
+	derivations []derivation
+	record      slog.Record
+}
+
+// earlyState is shared by an earlyHandler and the handlers derived from it.
+type earlyState struct {
+	mutex   sync.Mutex
+	records []earlyRecord
+	dropped int
+	target  slog.Handler // Set once the records are replayed
+}
+
+// earlyHandler keeps the records of the package-level functions until
+// SetHandler replays them to the handler set, forwarding the records
+// handled afterwards by loggers still holding it.
+type earlyHandler struct {
+	state       *earlyState
+	derivations []derivation
+}
+
+func (h *earlyHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *earlyHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.state.mutex.Lock()
+	if target := h.state.target; target != nil {
+		h.state.mutex.Unlock()
+		return replay(target, earlyRecord{ctx: ctx, derivations: h.derivations, record: r})
+	}
+	if len(h.state.records) == earlyCapacity {
+		h.state.records = h.state.records[1:]
+		h.state.dropped++
+	}
+	h.state.records = append(h.state.records, earlyRecord{ctx: ctx, derivations: h.derivations, record: CloneRecord(r)})
+	h.state.mutex.Unlock()
+	return nil
+}
+
+func (h *earlyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.derive(derivation{attrs: attrs})
+}
+
+func (h *earlyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.derive(derivation{group: name})
+}
+
+func (h *earlyHandler) derive(d derivation) *earlyHandler {
+	derivations := make([]derivation, len(h.derivations)+1)
+	copy(derivations, h.derivations)
+	derivations[len(h.derivations)] = d
+	return &earlyHandler{state: h.state, derivations: derivations}
+}
+
+// flush replays the kept records to handler, reporting the records
+// dropped first, and forwards the later ones to it. The records are
+// replayed without the mutex held, so that handler may log itself.
+func (h *earlyHandler) flush(handler slog.Handler) {
+	h.state.mutex.Lock()
+	if h.state.target != nil {
+		h.state.mutex.Unlock()
+		return
+	}
+	h.state.target = handler
+	records, dropped := h.state.records, h.state.dropped
+	h.state.records, h.state.dropped = nil, 0
+	h.state.mutex.Unlock()
+
+	if dropped > 0 {
+		r := slog.NewRecord(time.Now(), slog.LevelWarn, "slogging: dropped records logged before configuration", 0)
+		r.AddAttrs(slog.Int("count", dropped))
+		_ = replay(handler, earlyRecord{ctx: context.Background(), record: r})
+	}
+	for _, er := range records {
+		_ = replay(handler, er)
+	}
+}
+
+func replay(handler slog.Handler, er earlyRecord) error {
+	handler = applyDerivations(handler, er.derivations)
+	if !handler.Enabled(er.ctx, er.record.Level) {
+		return nil
+	}
+	return handler.Handle(er.ctx, er.record)
+}
+
+// SetHandler sets the handler of the package-level functions. The records
+// they logged before the first call, up to 1024 of them, are delivered to
+// it first. Install calls it with the installed handler.
+func SetHandler(handler slog.Handler) {
+	if early, ok := pipeline.Load().(*earlyHandler); ok {
+		early.flush(handler)
+	}
+	pipeline.Store(handler)
+}
+
+// Default returns a logger using the handler of the package-level
+// functions, following the changes made by SetHandler.
+func Default() *slog.Logger {
+	return slog.New(pipeline)
+}
+
+// Debug logs at Debug level with the handler set by SetHandler.
+func Debug(ctx context.Context, msg string, args ...any) {
+	logAt(ctx, slog.LevelDebug, msg, args, nil)
+}
+
+// Info logs at Info level with the handler set by SetHandler.
+func Info(ctx context.Context, msg string, args ...any) {
+	logAt(ctx, slog.LevelInfo, msg, args, nil)
+}
+
+// Warn logs at Warn level with the handler set by SetHandler.
+func Warn(ctx context.Context, msg string, args ...any) {
+	logAt(ctx, slog.LevelWarn, msg, args, nil)
+}
+
+// Error logs at Error level with the handler set by SetHandler.
+func Error(ctx context.Context, msg string, args ...any) {
+	logAt(ctx, slog.LevelError, msg, args, nil)
+}
+
+// Log logs at the given level with the handler set by SetHandler.
+func Log(ctx context.Context, level slog.Level, msg string, args ...any) {
+	logAt(ctx, level, msg, args, nil)
+}
+
+// LogAttrs is a more efficient version of Log accepting only attributes.
+func LogAttrs(ctx context.Context, level slog.Level, msg string, attrs ...slog.Attr) {
+	logAt(ctx, level, msg, nil, attrs)
+}
+
+// logAt must be called directly by the exported functions, so that the
+// records carry the location of their callers.
+func logAt(ctx context.Context, level slog.Level, msg string, args []any, attrs []slog.Attr) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !pipeline.Enabled(ctx, level) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // Skip Callers, logAt and the exported function
+	r := slog.NewRecord(time.Now(), level, msg, pcs[0])
+	r.Add(args...)
+	r.AddAttrs(attrs...)
+	_ = pipeline.Handle(ctx, r)
+}
@@ -0,0 +1,112 @@
+package slogging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_PackageLevel(t *testing.T) {
+	saved := pipeline
+	defer func() { pipeline = saved }()
+	ctx := context.Background()
+
+	t.Run("records before configuration are replayed", func(t *testing.T) {
+		pipeline = NewSwappable(&earlyHandler{state: new(earlyState)})
+		logger := Default().WithGroup("db").With("pool", 2)
+		Info(ctx, "starting", "version", "1.2.3")
+		Debug(ctx, "hidden")
+		logger.Warn("slow")
+
+		buf := new(bytes.Buffer)
+		SetHandler(slog.NewTextHandler(buf, &slog.HandlerOptions{AddSource: true, ReplaceAttr: dropTime}))
+		LogAttrs(ctx, slog.LevelError, "after", slog.Int("n", 1))
+		logger.Info("derived")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		want := []string{
+			`msg=starting version=1.2.3`,
+			`msg=slow db.pool=2`,
+			`msg=after n=1`,
+			`msg=derived db.pool=2`,
+		}
+		if len(lines) != len(want) {
+			t.Fatalf("got %d lines:\n%s", len(lines), buf.String())
+		}
+		for i, w := range want {
+			if !strings.HasSuffix(lines[i], w) {
+				t.Errorf("line %d: want suffix %s, got %s", i, w, lines[i])
+			}
+		}
+		if !strings.Contains(lines[0], "default_test.go") || !strings.Contains(lines[2], "default_test.go") {
+			t.Errorf("source is not the caller:\n%s", buf.String())
+		}
+	})
+
+	t.Run("oldest records are dropped beyond capacity", func(t *testing.T) {
+		pipeline = NewSwappable(&earlyHandler{state: new(earlyState)})
+		for i := range earlyCapacity + 6 {
+			Info(ctx, "early", "i", i)
+		}
+		buf := new(bytes.Buffer)
+		SetHandler(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}))
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != earlyCapacity+1 ||
+			lines[0] != `level=WARN msg="slogging: dropped records logged before configuration" count=6` ||
+			lines[1] != "level=INFO msg=early i=6" {
+			t.Errorf("got %d lines, starting with:\n%s\n%s", len(lines), lines[0], lines[1])
+		}
+	})
+
+	t.Run("later handlers replace earlier ones", func(t *testing.T) {
+		pipeline = NewSwappable(&earlyHandler{state: new(earlyState)})
+		first, second := new(bytes.Buffer), new(bytes.Buffer)
+		SetHandler(slog.NewTextHandler(first, nil))
+		SetHandler(slog.NewTextHandler(second, nil))
+		Log(ctx, slog.LevelInfo, "m")
+		if first.Len() != 0 || !strings.Contains(second.String(), "msg=m") {
+			t.Errorf("first: %q, second: %q", first, second)
+		}
+	})
+
+	t.Run("values are resolved when logged and handlers may log", func(t *testing.T) {
+		pipeline = NewSwappable(&earlyHandler{state: new(earlyState)})
+		v := &counterValuer{}
+		Info(ctx, "early", "v", v)
+		v.n = 2
+
+		buf := new(bytes.Buffer)
+		logged := false
+		SetHandler(&hookHandler{
+			Handler: slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}),
+			hook: func() {
+				if !logged {
+					logged = true
+					Info(ctx, "from handler")
+				}
+			},
+		})
+		// The hook logs before the replayed record is written.
+		want := "level=INFO msg=\"from handler\"\nlevel=INFO msg=early v=0\n"
+		if got := buf.String(); got != want {
+			t.Errorf("output: %q", got)
+		}
+	})
+}
+
+type counterValuer struct{ n int }
+
+func (v *counterValuer) LogValue() slog.Value { return slog.IntValue(v.n) }
+
+// hookHandler calls hook before handling every record.
+type hookHandler struct {
+	slog.Handler
+	hook func()
+}
+
+func (h *hookHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.hook()
+	return h.Handler.Handle(ctx, r)
+}
@@ -0,0 +1,88 @@
+package slogging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// Nop is a slog.Handler discarding every record. It reports every level
+// as disabled, so loggers using it return before building records.
+type Nop struct{}
+
+// Enabled returns false.
+func (Nop) Enabled(context.Context, slog.Level) bool { return false }
+
+// Handle does nothing.
+func (Nop) Handle(context.Context, slog.Record) error { return nil }
+
+// WithAttrs returns the handler itself.
+func (h Nop) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+// WithGroup returns the handler itself.
+func (h Nop) WithGroup(string) slog.Handler { return h }
+
+var discard = slog.New(Nop{})
+
+// Discard returns a logger discarding every record at no cost, the
+// default of libraries which were given no logger.
+func Discard() *slog.Logger {
+	return discard
+}
+
+// DiscardCounter is a slog.Handler discarding every record while counting
+// them by level, to quantify what a silent library would have logged.
+// Unlike Nop, it reports every level as enabled, so records are built.
+// The counters are shared by the handlers derived with WithAttrs and
+// WithGroup.
+type DiscardCounter struct {
+	total  atomic.Uint64
+	levels sync.Map // slog.Level to *atomic.Uint64
+}
+
+// NewDiscardCounter returns a DiscardCounter with zero counts.
+func NewDiscardCounter() *DiscardCounter {
+	return new(DiscardCounter)
+}
+
+// Enabled returns true.
+func (h *DiscardCounter) Enabled(context.Context, slog.Level) bool { return true }
+
+// Handle counts the record.
+func (h *DiscardCounter) Handle(_ context.Context, r slog.Record) error {
+	h.total.Add(1)
+	c, ok := h.levels.Load(r.Level)
+	if !ok {
+		c, _ = h.levels.LoadOrStore(r.Level, new(atomic.Uint64))
+	}
+	c.(*atomic.Uint64).Add(1)
+	return nil
+}
+
+// WithAttrs returns the handler itself.
+func (h *DiscardCounter) WithAttrs([]slog.Attr) slog.Handler { return h }
+
+// WithGroup returns the handler itself.
+func (h *DiscardCounter) WithGroup(string) slog.Handler { return h }
+
+// Total returns the number of records discarded.
+func (h *DiscardCounter) Total() uint64 {
+	return h.total.Load()
+}
+
+// Counts returns the number of records discarded by level.
+func (h *DiscardCounter) Counts() map[slog.Level]uint64 {
+	out := make(map[slog.Level]uint64)
+	h.levels.Range(func(k, v any) bool {
+		out[k.(slog.Level)] = v.(*atomic.Uint64).Load()
+		return true
+	})
+	return out
+}
+
+// Stats reports every record as handled and dropped.
+func (h *DiscardCounter) Stats() Stats {
+	n := h.total.Load()
+	return Stats{Handled: n, Dropped: n}
+}
@@ -0,0 +1,36 @@
+package slogging
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"testing"
+)
+
+func Test_Discard(t *testing.T) {
+	t.Run("nop does not build records", func(t *testing.T) {
+		logger := Discard().With("a", 1).WithGroup("g")
+		allocs := testing.AllocsPerRun(100, func() {
+			logger.Info("m", "k", "v")
+		})
+		if allocs != 0 {
+			t.Errorf("%v allocations per record", allocs)
+		}
+	})
+
+	t.Run("counter counts by level", func(t *testing.T) {
+		h := NewDiscardCounter()
+		logger := slog.New(h).With("a", 1)
+		logger.Debug("m")
+		logger.Info("m")
+		logger.WithGroup("g").Info("m")
+		logger.Log(context.Background(), slog.LevelError+2, "m")
+		want := map[slog.Level]uint64{slog.LevelDebug: 1, slog.LevelInfo: 2, slog.LevelError + 2: 1}
+		if got := h.Counts(); !maps.Equal(got, want) {
+			t.Errorf("Counts() = %v, want %v", got, want)
+		}
+		if h.Total() != 4 || h.Stats().Dropped != 4 {
+			t.Errorf("Total() = %d, Stats() = %+v", h.Total(), h.Stats())
+		}
+	})
+}
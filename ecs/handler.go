@@ -0,0 +1,251 @@
+// Package ecs provides a slog.Handler writing Elastic Common Schema JSON,
+// ready for ingestion by Elasticsearch and Filebeat without ingest
+// pipelines.
+package ecs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging/errattr"
+	"github.com/mikluko/slogging/internal/jsonvalue"
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/stack"
+)
+
+// Version is the ECS version the output conforms to, reported as
+// "ecs.version".
+const Version = "8.11.0"
+
+// Handler is a slog.Handler that writes every record as a single-line ECS
+// JSON document: "@timestamp", "log.level", "message", "ecs.version",
+// "log.origin" when enabled, "trace.id" and "span.id" from the logging
+// context, and "error.message", "error.type" and "error.stack_trace" for
+// errors logged at the top level under the "error" or "err" key. Fields
+// are nested objects: groups become objects, and dotted keys such as
+// "http.request.method" are expanded, so attributes named after ECS fields
+// land in their ECS location.
+type Handler struct {
+	scope scope.Scope
+
+	// Shared state across WithAttrs/WithGroup instances.
+	mutex *sync.Mutex
+
+	writer io.Writer
+	level  slog.Leveler
+	source bool
+}
+
+// NewHandler creates a new Handler with the given options.
+func NewHandler(options ...Option) *Handler {
+	config := handlerOptions{
+		writer: io.Discard,
+		level:  slog.LevelInfo,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{
+		mutex:  &sync.Mutex{},
+		writer: config.writer,
+		level:  config.level,
+		source: config.source,
+	}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	doc := make(map[string]any)
+
+	for _, a := range h.scope.Attrs(r) {
+		addAttr(doc, a, true)
+	}
+
+	if !r.Time.IsZero() {
+		doc["@timestamp"] = r.Time.UTC().Format(time.RFC3339Nano)
+	}
+	put(doc, "message", r.Message)
+	put(doc, "log.level", strings.ToLower(r.Level.String()))
+	put(doc, "ecs.version", Version)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		put(doc, "trace.id", sc.TraceID().String())
+		put(doc, "span.id", sc.SpanID().String())
+	}
+	if h.source && r.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := frames.Next()
+		put(doc, "log.origin.file.name", f.File)
+		put(doc, "log.origin.file.line", f.Line)
+		put(doc, "log.origin.function", f.Function)
+	}
+
+	buf, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("error when marshaling ECS document: %w", err)
+	}
+	buf = append(buf, '\n')
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, err = h.writer.Write(buf)
+	return err
+}
+
+// addAttr converts a and adds it to obj. Empty attributes and empty groups
+// are dropped, groups with an empty key are inlined.
+func addAttr(obj map[string]any, a slog.Attr, top bool) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		if err, ok := a.Value.Any().(error); ok && top && (a.Key == "error" || a.Key == "err") {
+			addError(obj, err)
+			return
+		}
+		put(obj, a.Key, convertValue(a.Value))
+		return
+	}
+	attrs := a.Value.Group()
+	if len(attrs) == 0 {
+		return
+	}
+	if a.Key == "" {
+		for _, ga := range attrs {
+			addAttr(obj, ga, top)
+		}
+		return
+	}
+	inner := make(map[string]any, len(attrs))
+	for _, ga := range attrs {
+		addAttr(inner, ga, false)
+	}
+	if len(inner) > 0 {
+		put(obj, a.Key, inner)
+	}
+}
+
+// addError sets the ECS error fields. The stack trace is taken from the
+// innermost error of the chain implementing errattr.StackTracer.
+func addError(obj map[string]any, err error) {
+	put(obj, "error.message", err.Error())
+	put(obj, "error.type", fmt.Sprintf("%T", err))
+	var pcs []uintptr
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if st, ok := e.(errattr.StackTracer); ok {
+			pcs = st.StackTrace()
+		}
+	}
+	if len(pcs) > 0 {
+		put(obj, "error.stack_trace", stack.Value(pcs, stack.String).String())
+	}
+}
+
+// put stores v in obj at the dotted path key, creating intermediate
+// objects. Objects are merged into an object already present at key;
+// anything else found on the path is replaced.
+func put(obj map[string]any, key string, v any) {
+	parts := strings.Split(key, ".")
+	for _, p := range parts[:len(parts)-1] {
+		next, ok := obj[p].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			obj[p] = next
+		}
+		obj = next
+	}
+	last := parts[len(parts)-1]
+	existing, ok := obj[last].(map[string]any)
+	inner, isObj := v.(map[string]any)
+	if !ok || !isObj {
+		obj[last] = v
+		return
+	}
+	for k, x := range inner {
+		put(existing, k, x)
+	}
+}
+
+func convertValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindDuration:
+		// ECS durations, e.g. event.duration, are in nanoseconds.
+		return v.Duration().Nanoseconds()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		return jsonvalue.Convert(v.Any())
+	}
+	return v.Any()
+}
+
+type handlerOptions struct {
+	writer io.Writer
+	level  slog.Leveler
+	source bool
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithWriter sets the writer where records will be written.
+// If writer is nil, output will be discarded.
+func WithWriter(writer io.Writer) Option {
+	return func(h *handlerOptions) {
+		if writer == nil {
+			writer = io.Discard
+		}
+		h.writer = writer
+	}
+}
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithSource adds the "log.origin" fields with the file name, line and
+// function of the logging call.
+func WithSource(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.source = true
+		for i := range x {
+			h.source = x[i]
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package ecs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type tracedError struct{ pcs []uintptr }
+
+func (e *tracedError) Error() string         { return "traced" }
+func (e *tracedError) StackTrace() []uintptr { return e.pcs }
+
+type stringer string
+
+func (s stringer) String() string { return "stringer " + string(s) }
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	return doc
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("base fields", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+		slog.New(NewHandler(WithWriter(buf))).WarnContext(ctx, "test message")
+
+		want := `"ecs":{"version":"8.11.0"},"log":{"level":"warn"},"message":"test message","span":{"id":"00f067aa0ba902b7"},"trace":{"id":"4bf92f3577b34da6a3ce929d0e0e4736"}}`
+		if !strings.HasPrefix(buf.String(), `{"@timestamp":"`) || !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %s, got: %s", want, buf.String())
+		}
+		if _, err := time.Parse(time.RFC3339Nano, decode(t, buf)["@timestamp"].(string)); err != nil {
+			t.Errorf("unexpected timestamp: %v", err)
+		}
+	})
+
+	t.Run("groups and dotted keys nest", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf)))
+
+		logger.With("http.request.method", "GET").WithGroup("http").Info("test message",
+			slog.Group("response", "status_code", 200), "event.duration", time.Millisecond)
+
+		want := `"http":{"event":{"duration":1000000},"request":{"method":"GET"},"response":{"status_code":200}}`
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %s, got: %s", want, buf.String())
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf)))
+		pcs := make([]uintptr, 8)
+		pcs = pcs[:runtime.Callers(1, pcs)]
+
+		logger.Error("test message", "error", fmt.Errorf("wrapped: %w", &tracedError{pcs: pcs}))
+
+		e, _ := decode(t, buf)["error"].(map[string]any)
+		if e["message"] != "wrapped: traced" || e["type"] != "*fmt.wrapError" || !strings.Contains(e["stack_trace"].(string), "Test_Handler") {
+			t.Errorf("unexpected error fields: %v", e)
+		}
+
+		buf.Reset()
+		logger.WithGroup("g").Error("test message", "err", errors.New("boom"), "s", stringer("x"))
+		if !strings.Contains(buf.String(), `"g":{"err":"boom","s":"stringer x"}`) {
+			t.Errorf("expected nested error and stringer as strings, got: %s", buf.String())
+		}
+	})
+
+	t.Run("log origin", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		slog.New(NewHandler(WithWriter(buf), WithSource())).Info("test message")
+
+		origin := decode(t, buf)["log"].(map[string]any)["origin"].(map[string]any)
+		file := origin["file"].(map[string]any)
+		if !strings.HasSuffix(file["name"].(string), "handler_test.go") || file["line"].(float64) == 0 || !strings.Contains(origin["function"].(string), "Test_Handler") {
+			t.Errorf("unexpected origin: %v", origin)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package edge
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// SkewKey is the attribute holding the offset of the local clock from
+	// the reference clock when the record was logged, in nanoseconds,
+	// positive when the local clock is ahead.
+	SkewKey = "clock.skew"
+	// SyncedKey is the attribute reporting whether the offset of the local
+	// clock was known when the record was logged.
+	SyncedKey = "clock.synced"
+)
+
+// Clock tracks the offset of the local clock from a reference clock, such
+// as the clock of the log destination, so that the times of records logged
+// by devices with drifting or reset clocks can be corrected on ingestion.
+// A Clock is safe for concurrent use.
+type Clock struct {
+	mutex  sync.Mutex
+	skew   time.Duration
+	synced bool
+	now    func() time.Time
+}
+
+// NewClock returns a Clock with an unknown offset.
+func NewClock() *Clock {
+	return &Clock{now: time.Now}
+}
+
+// Observe records the current time of the reference clock.
+func (c *Clock) Observe(reference time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.skew = c.now().Sub(reference)
+	c.synced = true
+}
+
+// ObserveResponse records the time of the reference clock from the Date
+// header of an HTTP response, with a precision of one second. Responses
+// without a valid Date header are ignored.
+func (c *Clock) ObserveResponse(resp *http.Response) {
+	t, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+	c.Observe(t)
+}
+
+// Skew returns the offset of the local clock from the reference clock, and
+// whether it is known.
+func (c *Clock) Skew() (time.Duration, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.skew, c.synced
+}
@@ -0,0 +1,320 @@
+// Package edge provides a preset for devices that ship their logs
+// opportunistically over constrained or intermittent links: records are
+// spooled on disk, annotated with the skew of the local clock, and
+// forwarded to the destination by priority within a bandwidth budget
+// whenever it can be reached.
+package edge
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mikluko/slogging/bandwidth"
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/spool"
+)
+
+const (
+	defaultSyncInterval = 30 * time.Second
+	defaultSyncTimeout  = 5 * time.Minute
+	defaultRate         = 16 << 10
+	defaultBurst        = 64 << 10
+	defaultCompaction   = 0.8
+)
+
+// DefaultRetention is the retention of unsent records by band applied by
+// New, on top of the capacity of the spool: debug records are kept for an
+// hour, info records for a day and warnings for a week. Errors are only
+// bounded by the capacity, and are the last to be discarded.
+var DefaultRetention = map[slog.Level]spool.Retention{
+	slog.LevelDebug: {MaxAge: time.Hour},
+	slog.LevelInfo:  {MaxAge: 24 * time.Hour},
+	slog.LevelWarn:  {MaxAge: 7 * 24 * time.Hour},
+}
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	spool  *spool.Handler
+	target slog.Handler
+	clock  *Clock
+	config handlerOptions
+	kick   chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// Handler is a slog.Handler spooling records on disk, as described in the
+// spool package, and forwarding them to a target handler from a background
+// goroutine every sync interval, or when Sync is called. Records are
+// annotated with SyncedKey and, once the clock offset is known, SkewKey,
+// from the Clock fed with reference times, e.g. by ObserveResponse with
+// the responses of the destination. Forwarding draws from a bandwidth
+// budget, waiting for it to refill rather than dropping records, and stops
+// at the first error of the target, to resume at the next sync. Once the
+// spool is 80% full, debug and info records are compacted into counts per
+// message while warnings and errors are kept verbatim; see
+// spool.WithCompaction.
+//
+// Close must be called to stop the background goroutine.
+type Handler struct {
+	scope scope.Scope
+	state *state
+}
+
+// New opens the spool in dir and starts forwarding its records to target.
+func New(dir string, target slog.Handler, options ...Option) (*Handler, error) {
+	config := handlerOptions{
+		rate:         defaultRate,
+		burst:        defaultBurst,
+		syncInterval: defaultSyncInterval,
+		syncTimeout:  defaultSyncTimeout,
+	}
+	config.spoolOptions = append(config.spoolOptions, spool.WithCompaction(defaultCompaction, slog.LevelWarn))
+	for level, r := range DefaultRetention {
+		config.spoolOptions = append(config.spoolOptions, spool.WithRetention(level, r))
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	if config.onError != nil {
+		config.spoolOptions = append(config.spoolOptions, spool.WithErrorHandler(config.onError))
+	}
+	if config.limiter == nil {
+		config.limiter = bandwidth.NewLimiter(config.rate, config.burst)
+	}
+	if config.clock == nil {
+		config.clock = NewClock()
+	}
+	sp, err := spool.Open(dir, config.spoolOptions...)
+	if err != nil {
+		return nil, err
+	}
+	s := &state{
+		spool:  sp,
+		target: &paced{handler: target, limiter: config.limiter},
+		clock:  config.clock,
+		config: config,
+		kick:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	go s.run()
+	return &Handler{state: s}, nil
+}
+
+// Enabled reports whether records at the level are spooled.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.state.spool.Enabled(ctx, level)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// Handle annotates the record with the clock offset and spools it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(h.scope.Attrs(r)...)
+	skew, synced := h.state.clock.Skew()
+	nr.AddAttrs(slog.Bool(SyncedKey, synced))
+	if synced {
+		nr.AddAttrs(slog.Int64(SkewKey, int64(skew)))
+	}
+	return h.state.spool.Handle(ctx, nr)
+}
+
+func (s *state) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.config.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		case <-s.kick:
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.syncTimeout)
+		err := s.spool.Forward(ctx, s.target)
+		cancel()
+		if err != nil && s.config.onError != nil {
+			s.config.onError(err)
+		}
+	}
+}
+
+// Sync forwards the spooled records now and returns the error that
+// stopped forwarding, if any.
+func (h *Handler) Sync(ctx context.Context) error {
+	return h.state.spool.Forward(ctx, h.state.target)
+}
+
+// Kick makes the background goroutine forward the spooled records without
+// waiting for the sync interval, e.g. when the device detects that the
+// link came up.
+func (h *Handler) Kick() {
+	select {
+	case h.state.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Clock returns the clock annotating the records.
+func (h *Handler) Clock() *Clock {
+	return h.state.clock
+}
+
+// Spool returns the spool holding the records, e.g. to report Pending and
+// Dropped.
+func (h *Handler) Spool() *spool.Handler {
+	return h.state.spool
+}
+
+// Close stops the background goroutine, makes a last attempt to forward
+// the spooled records until ctx is done, and closes the spool. Records
+// left in the spool are forwarded after the next New with the same
+// directory.
+func (h *Handler) Close(ctx context.Context) error {
+	s := h.state
+	s.once.Do(func() { close(s.stop) })
+	<-s.done
+	err := s.spool.Forward(ctx, s.target)
+	if errors.Is(err, spool.ErrClosed) {
+		return nil
+	}
+	return errors.Join(err, s.spool.Close())
+}
+
+// paced delivers records to the wrapped handler within a bandwidth budget.
+type paced struct {
+	handler slog.Handler
+	limiter *bandwidth.Limiter
+}
+
+func (p *paced) Enabled(ctx context.Context, level slog.Level) bool {
+	return p.handler.Enabled(ctx, level)
+}
+
+func (p *paced) Handle(ctx context.Context, r slog.Record) error {
+	if err := p.limiter.Wait(ctx, bandwidth.Estimate(r)); err != nil {
+		return err
+	}
+	return p.handler.Handle(ctx, r)
+}
+
+func (p *paced) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &paced{handler: p.handler.WithAttrs(attrs), limiter: p.limiter}
+}
+
+func (p *paced) WithGroup(name string) slog.Handler {
+	return &paced{handler: p.handler.WithGroup(name), limiter: p.limiter}
+}
+
+type handlerOptions struct {
+	spoolOptions []spool.Option
+	rate         int
+	burst        int
+	limiter      *bandwidth.Limiter
+	clock        *Clock
+	syncInterval time.Duration
+	syncTimeout  time.Duration
+	onError      func(error)
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the minimum level of spooled records. Defaults to
+// slog.LevelInfo.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.spoolOptions = append(h.spoolOptions, spool.WithLevel(lvl))
+	}
+}
+
+// WithCapacity bounds the bytes the spool occupies on disk. Defaults to
+// 64 MiB.
+func WithCapacity(n int64) Option {
+	return func(h *handlerOptions) {
+		h.spoolOptions = append(h.spoolOptions, spool.WithCapacity(n))
+	}
+}
+
+// WithRetention sets the retention of unsent records of the band starting
+// at level, replacing DefaultRetention for that band.
+func WithRetention(level slog.Level, r spool.Retention) Option {
+	return func(h *handlerOptions) {
+		h.spoolOptions = append(h.spoolOptions, spool.WithRetention(level, r))
+	}
+}
+
+// WithSpoolOptions passes options to the spool.
+func WithSpoolOptions(options ...spool.Option) Option {
+	return func(h *handlerOptions) {
+		h.spoolOptions = append(h.spoolOptions, options...)
+	}
+}
+
+// WithRate sets the bandwidth budget of forwarding to rate bytes per second
+// with bursts of up to burst bytes, as estimated by bandwidth.Estimate.
+// Defaults to 16 KiB per second with bursts of 64 KiB.
+func WithRate(rate, burst int) Option {
+	return func(h *handlerOptions) {
+		h.rate = rate
+		h.burst = burst
+	}
+}
+
+// WithLimiter makes forwarding draw from a bandwidth budget shared with
+// other senders. It takes precedence over WithRate.
+func WithLimiter(l *bandwidth.Limiter) Option {
+	return func(h *handlerOptions) {
+		h.limiter = l
+	}
+}
+
+// WithClock sets the clock annotating records, e.g. to share it with the
+// code talking to the destination.
+func WithClock(c *Clock) Option {
+	return func(h *handlerOptions) {
+		h.clock = c
+	}
+}
+
+// WithSync sets how often the background goroutine forwards the spooled
+// records, and how long a forwarding attempt may take. Defaults to every
+// 30 seconds, for at most 5 minutes.
+func WithSync(interval, timeout time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.syncInterval = interval
+		h.syncTimeout = timeout
+	}
+}
+
+// WithErrorHandler sets a function called with the errors stopping
+// background forwarding, and the errors reported by the spool.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
@@ -0,0 +1,126 @@
+package edge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// link is a destination that can be taken offline.
+type link struct {
+	mutex   sync.Mutex
+	offline bool
+	buf     bytes.Buffer
+	handler slog.Handler
+}
+
+func newLink() *link {
+	l := &link{}
+	l.handler = slog.NewTextHandler(&l.buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return l
+}
+
+func (l *link) Enabled(context.Context, slog.Level) bool { return true }
+func (l *link) WithAttrs([]slog.Attr) slog.Handler       { return l }
+func (l *link) WithGroup(string) slog.Handler            { return l }
+
+func (l *link) Handle(ctx context.Context, r slog.Record) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.offline {
+		return errors.New("link down")
+	}
+	return l.handler.Handle(ctx, r)
+}
+
+func (l *link) String() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.buf.String()
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("records are spooled while offline", func(t *testing.T) {
+		dst := newLink()
+		dst.offline = true
+		var errs []error
+		h, err := New(t.TempDir(), dst, WithSync(time.Hour, time.Minute), WithErrorHandler(func(err error) { errs = append(errs, err) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger := slog.New(h).With("device", "d1").WithGroup("sensor")
+		logger.Info("reading", "temp", 21)
+		h.Clock().Observe(time.Now().Add(-90 * time.Second))
+		logger.Error("overheat", "temp", 95)
+
+		if err := h.Sync(context.Background()); err == nil {
+			t.Fatalf("expected the sync to fail while offline")
+		}
+		if h.Spool().Pending() != 2 {
+			t.Fatalf("expected 2 pending records, got %d", h.Spool().Pending())
+		}
+		dst.mutex.Lock()
+		dst.offline = false
+		dst.mutex.Unlock()
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(dst.String()), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 records, got %q", lines)
+		}
+		if !strings.HasPrefix(lines[0], "level=ERROR msg=overheat clock.skew=900") || !strings.Contains(lines[0], "clock.synced=true device=d1 sensor.temp=95") {
+			t.Errorf("unexpected first record: %s", lines[0])
+		}
+		if lines[1] != "level=INFO msg=reading clock.synced=false device=d1 sensor.temp=21" {
+			t.Errorf("unexpected second record: %s", lines[1])
+		}
+		if len(errs) != 0 {
+			t.Errorf("unexpected errors: %v", errs)
+		}
+	})
+
+	t.Run("background sync", func(t *testing.T) {
+		dst := newLink()
+		h, err := New(t.TempDir(), dst, WithSync(time.Hour, time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer h.Close(context.Background())
+		slog.New(h).Warn("kicked")
+		h.Kick()
+		deadline := time.Now().Add(time.Second)
+		for !strings.Contains(dst.String(), "kicked") && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if !strings.Contains(dst.String(), "msg=kicked") {
+			t.Errorf("expected the record to be forwarded")
+		}
+	})
+}
+
+func Test_Clock(t *testing.T) {
+	c := NewClock()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	if _, ok := c.Skew(); ok {
+		t.Errorf("expected an unknown skew")
+	}
+	c.ObserveResponse(&http.Response{Header: http.Header{"Date": {"Mon, 01 Jan 2024 12:00:30 GMT"}}})
+	if skew, ok := c.Skew(); !ok || skew != -30*time.Second {
+		t.Errorf("unexpected skew: %v", skew)
+	}
+}
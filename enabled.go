@@ -0,0 +1,108 @@
+package slogging
+
+import (
+	"context"
+	"log/slog"
+)
+
+type contextLevelKey struct{}
+
+// WithContextLevel returns a context in which the records at or above
+// level are enabled by the level-filtering wrappers, levels.Handler and
+// the loggers of a Registry, in place of their configured levels. It
+// turns on verbose logging for one request, or quiets it, without
+// changing the levels of other requests. The handlers they wrap still
+// filter records by their own levels.
+func WithContextLevel(ctx context.Context, level slog.Leveler) context.Context {
+	return context.WithValue(ctx, contextLevelKey{}, level)
+}
+
+// WithVerbose returns a context in which Debug records are enabled, see
+// WithContextLevel.
+func WithVerbose(ctx context.Context) context.Context {
+	return WithContextLevel(ctx, slog.LevelDebug)
+}
+
+// ContextLevel returns the level set with WithContextLevel, and whether
+// one was set.
+func ContextLevel(ctx context.Context) (slog.Level, bool) {
+	if ctx == nil {
+		return 0, false
+	}
+	l, ok := ctx.Value(contextLevelKey{}).(slog.Leveler)
+	if !ok {
+		return 0, false
+	}
+	return l.Level(), true
+}
+
+// Gate reports whether records at the given level are enabled in ctx,
+// from signals of the context such as a per-request flag or a tenant
+// quota.
+//
+// slog.Logger calls Enabled before building a record, so a record
+// rejected there costs neither the evaluation of its arguments nor the
+// building of the record. Wrappers in this module follow these rules,
+// and custom wrappers should too:
+//
+//   - Enabled returns false whenever Handle would drop every record at the
+//     level for the context, consulting the signals of the context: the
+//     level of ContextLevel, the sampling decision of the span (see
+//     otel.WithTraceSampling) or gates;
+//   - otherwise Enabled delegates to the wrapped handler, or to each of the
+//     wrapped handlers for those fanning records out;
+//   - Handle checks again what Enabled checks when the check is cheap and
+//     free of side effects, since Handle may be called without Enabled.
+type Gate func(ctx context.Context, level slog.Level) bool
+
+// Gated returns a handler rejecting in Enabled the records for which any
+// of the gates returns false. Records passed to Handle directly are not
+// checked again, so gates may keep state such as quotas, consumed once
+// per record logged through a slog.Logger. Nil gates are skipped.
+func Gated(handler slog.Handler, gates ...Gate) slog.Handler {
+	var gs []Gate
+	for _, g := range gates {
+		if g != nil {
+			gs = append(gs, g)
+		}
+	}
+	if len(gs) == 0 {
+		return handler
+	}
+	return &gatedHandler{handler: handler, gates: gs}
+}
+
+type gatedHandler struct {
+	handler slog.Handler
+	gates   []Gate
+}
+
+func (h *gatedHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if !h.handler.Enabled(ctx, level) {
+		return false
+	}
+	for _, g := range h.gates {
+		if !g(ctx, level) {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *gatedHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *gatedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &gatedHandler{handler: h.handler.WithAttrs(attrs), gates: h.gates}
+}
+
+func (h *gatedHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &gatedHandler{handler: h.handler.WithGroup(name), gates: h.gates}
+}
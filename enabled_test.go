@@ -0,0 +1,56 @@
+package slogging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// expensive counts how many times it is resolved.
+type expensive struct{ n *int }
+
+func (e expensive) LogValue() slog.Value {
+	*e.n++
+	return slog.StringValue("computed")
+}
+
+type tenantKey struct{}
+
+func Test_Gated(t *testing.T) {
+	buf := new(bytes.Buffer)
+	muted := func(ctx context.Context, _ slog.Level) bool { return ctx.Value(tenantKey{}) != "noisy" }
+	logger := slog.New(Gated(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}), nil, muted)).With("a", 1)
+
+	var resolved int
+	logger.InfoContext(context.WithValue(context.Background(), tenantKey{}, "noisy"), "hidden", "v", expensive{&resolved})
+	logger.WithGroup("g").InfoContext(context.WithValue(context.Background(), tenantKey{}, "quiet"), "shown", "v", expensive{&resolved})
+
+	if got := strings.TrimSpace(buf.String()); got != "level=INFO msg=shown a=1 g.v=computed" {
+		t.Errorf("got %s", got)
+	}
+	if resolved != 1 {
+		t.Errorf("arguments of rejected records evaluated: %d resolutions", resolved)
+	}
+	if h := slog.NewTextHandler(buf, nil); Gated(h, nil) != h {
+		t.Error("Gated() without gates wrapped the handler")
+	}
+}
+
+func Test_ContextLevel(t *testing.T) {
+	ctx := context.Background()
+	if _, ok := ContextLevel(ctx); ok {
+		t.Error("ContextLevel() set in background context")
+	}
+
+	buf := new(bytes.Buffer)
+	r := NewRegistry(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: dropTime}))
+	logger := r.Get("app")
+	logger.DebugContext(ctx, "hidden")
+	logger.DebugContext(WithVerbose(ctx), "verbose")
+	logger.InfoContext(WithContextLevel(ctx, slog.LevelWarn), "quiet")
+	if got := strings.TrimSpace(buf.String()); got != "level=DEBUG msg=verbose logger.name=app" {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,201 @@
+package errattr
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"github.com/mikluko/slogging/stack"
+)
+
+// StackTracer is implemented by errors carrying the stack trace of the
+// place where they were created, as program counters returned by
+// runtime.Callers.
+type StackTracer interface {
+	StackTrace() []uintptr
+}
+
+// Attr returns err expanded into a group under key, as rendered by Value
+// with the default options.
+func Attr(key string, err error) slog.Attr {
+	return slog.Attr{Key: key, Value: Value(err)}
+}
+
+// Value expands err into a group value holding its "message", its "type"
+// name, the "chain" of errors it wraps, outermost first, and the "stack"
+// trace of the innermost error in the chain implementing StackTracer. The
+// chain follows both Unwrap() error and Unwrap() []error, depth first. Each
+// chain entry is a group keyed by its index holding "message" and "type".
+// A nil error renders as an empty group.
+func Value(err error) slog.Value {
+	return expand(err, defaultOptions())
+}
+
+func defaultOptions() handlerOptions {
+	return handlerOptions{chain: true, stack: true}
+}
+
+func expand(err error, config handlerOptions) slog.Value {
+	if err == nil {
+		return slog.GroupValue()
+	}
+	attrs := []slog.Attr{
+		slog.String("message", err.Error()),
+		slog.String("type", typeName(err)),
+	}
+	chain := unwrap(err, nil)
+	if config.chain && len(chain) > 0 {
+		entries := make([]slog.Attr, len(chain))
+		for i, e := range chain {
+			entries[i] = slog.Group(strconv.Itoa(i),
+				slog.String("message", e.Error()),
+				slog.String("type", typeName(e)),
+			)
+		}
+		attrs = append(attrs, slog.Attr{Key: "chain", Value: slog.GroupValue(entries...)})
+	}
+	if config.stack {
+		var pcs []uintptr
+		for _, e := range append([]error{err}, chain...) {
+			if st, ok := e.(StackTracer); ok {
+				pcs = st.StackTrace()
+			}
+		}
+		if len(pcs) > 0 {
+			attrs = append(attrs, slog.Attr{Key: stack.Key, Value: stack.Value(pcs, config.format)})
+		}
+	}
+	return slog.GroupValue(attrs...)
+}
+
+func typeName(err error) string {
+	return fmt.Sprintf("%T", err)
+}
+
+func unwrap(err error, chain []error) []error {
+	switch u := err.(type) {
+	case interface{ Unwrap() error }:
+		if inner := u.Unwrap(); inner != nil {
+			chain = unwrap(inner, append(chain, inner))
+		}
+	case interface{ Unwrap() []error }:
+		for _, inner := range u.Unwrap() {
+			if inner != nil {
+				chain = unwrap(inner, append(chain, inner))
+			}
+		}
+	}
+	return chain
+}
+
+// Handler is a slog.Handler that expands attributes holding error values
+// into structured groups, as described by Value, at any depth of the
+// record, including attributes added via WithAttrs.
+type Handler struct {
+	handler slog.Handler
+	config  handlerOptions
+}
+
+// Wrap creates a handler expanding errors before delivering records to handler.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := defaultOptions()
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{handler: handler, config: config}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle expands the errors held by the record attributes and delivers it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		nr.AddAttrs(h.replace(a))
+		return true
+	})
+	return h.handler.Handle(ctx, nr)
+}
+
+func (h *Handler) replace(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok && err != nil {
+			return slog.Attr{Key: a.Key, Value: expand(err, h.config)}
+		}
+	case slog.KindGroup:
+		group := v.Group()
+		attrs := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			attrs[i] = h.replace(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}
+	}
+	return a
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given
+// attributes, with errors expanded.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	replaced := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		replaced[i] = h.replace(a)
+	}
+	return &Handler{handler: h.handler.WithAttrs(replaced), config: h.config}
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{handler: h.handler.WithGroup(name), config: h.config}
+}
+
+type handlerOptions struct {
+	chain  bool
+	stack  bool
+	format stack.Format
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithChain sets whether the chain of wrapped errors is included. It is
+// enabled by default.
+func WithChain(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.chain = true
+		for i := range x {
+			h.chain = x[i]
+		}
+	}
+}
+
+// WithStack sets whether the stack trace of errors implementing StackTracer
+// is included. It is enabled by default.
+func WithStack(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.stack = true
+		for i := range x {
+			h.stack = x[i]
+		}
+	}
+}
+
+// WithStackFormat sets how stack traces are rendered.
+func WithStackFormat(f stack.Format) Option {
+	return func(h *handlerOptions) {
+		h.format = f
+	}
+}
@@ -0,0 +1,108 @@
+package errattr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/slogging/stack"
+)
+
+type tracedError struct {
+	msg string
+	pcs []uintptr
+}
+
+func newTracedError(msg string) *tracedError {
+	pcs := make([]uintptr, 8)
+	return &tracedError{msg: msg, pcs: pcs[:runtime.Callers(2, pcs)]}
+}
+
+func (e *tracedError) Error() string         { return e.msg }
+func (e *tracedError) StackTrace() []uintptr { return e.pcs }
+
+func Test_Value(t *testing.T) {
+	t.Run("message type and chain", func(t *testing.T) {
+		err := fmt.Errorf("load config: %w", &fs.PathError{Op: "open", Path: "/etc/app", Err: fs.ErrNotExist})
+
+		want := "[message=load config: open /etc/app: file does not exist type=*fmt.wrapError " +
+			"chain=[0=[message=open /etc/app: file does not exist type=*fs.PathError] 1=[message=file does not exist type=*errors.errorString]]]"
+		if got := Value(err).String(); got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	})
+
+	t.Run("joined errors", func(t *testing.T) {
+		err := errors.Join(errors.New("a"), fmt.Errorf("b: %w", errors.New("c")))
+
+		got := Value(err).String()
+		if !strings.Contains(got, "chain=[0=[message=a type=*errors.errorString] 1=[message=b: c type=*fmt.wrapError] 2=[message=c") {
+			t.Errorf("unexpected value: %s", got)
+		}
+	})
+
+	t.Run("stack of innermost stack tracer", func(t *testing.T) {
+		err := fmt.Errorf("wrapped: %w", newTracedError("inner"))
+
+		attr := Attr("error", err)
+		var s string
+		for _, a := range attr.Value.Group() {
+			if a.Key == stack.Key {
+				s = a.Value.String()
+			}
+		}
+		if !strings.HasPrefix(s, "github.com/mikluko/slogging/errattr.Test_Value.func3") {
+			t.Errorf("unexpected stack: %s", s)
+		}
+	})
+
+	t.Run("nil error", func(t *testing.T) {
+		if v := Value(nil); v.Kind() != slog.KindGroup || len(v.Group()) != 0 {
+			t.Errorf("expected empty group, got %s", v)
+		}
+	})
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("errors are expanded at any depth", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewJSONHandler(buf, nil), WithChain(false))).With("cause", errors.New("boot"))
+
+		logger.Error("failed", "error", fmt.Errorf("x: %w", newTracedError("y")), slog.Group("g", "err", errors.New("z")), "n", 1)
+
+		var rec map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		e := rec["error"].(map[string]any)
+		if e["message"] != "x: y" || e["type"] != "*fmt.wrapError" || e["chain"] != nil || e["stack"] == nil {
+			t.Errorf("unexpected error group: %v", e)
+		}
+		if g := rec["g"].(map[string]any)["err"].(map[string]any); g["message"] != "z" {
+			t.Errorf("unexpected nested error: %v", g)
+		}
+		if c := rec["cause"].(map[string]any); c["message"] != "boot" {
+			t.Errorf("unexpected error from WithAttrs: %v", c)
+		}
+		if rec["n"] != 1.0 {
+			t.Errorf("expected other attributes unchanged: %v", rec)
+		}
+	})
+
+	t.Run("stack can be disabled", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), WithStack(false)))
+
+		logger.Error("failed", "error", newTracedError("y"))
+
+		if strings.Contains(buf.String(), "stack") || !strings.Contains(buf.String(), "error.message=y error.type=*errattr.tracedError") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+}
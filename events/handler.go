@@ -0,0 +1,173 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// Key is the reserved attribute marking a record as a domain event. Its
+// value is the typed event payload.
+const Key = "log.event"
+
+// Named is implemented by event payloads that report their event type.
+// Payloads that do not implement it are named after their Go type.
+type Named interface {
+	EventName() string
+}
+
+// Attr marks a record as a domain event carrying the given payload:
+//
+//	logger.Info("order placed", events.Attr(OrderPlaced{ID: id}))
+func Attr(payload any) slog.Attr {
+	return slog.Any(Key, payload)
+}
+
+// Event is a domain event extracted from a record.
+type Event struct {
+	Name    string
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	Payload any
+	// Attrs holds the remaining attributes of the record, including those
+	// added via WithAttrs, nested in the groups of the logger.
+	Attrs []slog.Attr
+}
+
+// Sink receives domain events. Implementations publish them to an
+// event store or message broker.
+type Sink interface {
+	Publish(ctx context.Context, e Event) error
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(ctx context.Context, e Event) error
+
+// Publish calls f(ctx, e).
+func (f SinkFunc) Publish(ctx context.Context, e Event) error {
+	return f(ctx, e)
+}
+
+// Handler is a slog.Handler separating domain events from operational logs:
+// records carrying the reserved event attribute are converted to Events and
+// published to the sink, all other records are delivered to the wrapped
+// handler.
+type Handler struct {
+	handler slog.Handler
+	sink    Sink
+	scope   scope.Scope
+	tee     bool
+}
+
+// Wrap creates a handler publishing domain events to sink and delivering
+// other records to handler.
+func Wrap(handler slog.Handler, sink Sink, options ...Option) *Handler {
+	var config handlerOptions
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{handler: handler, sink: sink, tee: config.tee}
+}
+
+// Enabled reports true, as events are published regardless of their
+// level, unless there is no sink. The level of the wrapped handler applies
+// to operational records only.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.sink != nil || h.handler.Enabled(ctx, level)
+}
+
+// Handle publishes domain events and delivers everything else.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	var payload any
+	found := false
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == Key && !found {
+			payload = a.Value.Any()
+			found = true
+			return true
+		}
+		attrs = append(attrs, a)
+		return true
+	})
+	if !found || h.sink == nil {
+		return h.forward(ctx, r)
+	}
+
+	e := Event{
+		Name:    name(payload),
+		Time:    r.Time,
+		Level:   r.Level,
+		Message: r.Message,
+		Payload: payload,
+		Attrs:   h.scope.Nest(attrs),
+	}
+	if err := h.sink.Publish(ctx, e); err != nil {
+		return fmt.Errorf("error when publishing event %q: %w", e.Name, err)
+	}
+	if h.tee {
+		return h.forward(ctx, r)
+	}
+	return nil
+}
+
+// forward delivers r to the wrapped handler if it handles its level.
+func (h *Handler) forward(ctx context.Context, r slog.Record) error {
+	if !h.handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+func name(payload any) string {
+	if n, ok := payload.(Named); ok {
+		return n.EventName()
+	}
+	return fmt.Sprintf("%T", payload)
+}
+
+// WithAttrs returns a new Handler including the given attributes in both
+// operational records and events.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler that starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	tee bool
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithTee additionally delivers event records to the wrapped handler.
+func WithTee(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.tee = true
+		for i := range x {
+			h.tee = x[i]
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type OrderPlaced struct {
+	ID string
+}
+
+func (OrderPlaced) EventName() string {
+	return "order.placed"
+}
+
+type UserDeleted struct {
+	ID int
+}
+
+func Test_Events(t *testing.T) {
+	setup := func(options ...Option) (*bytes.Buffer, *[]Event, *slog.Logger) {
+		buf := new(bytes.Buffer)
+		var published []Event
+		sink := SinkFunc(func(_ context.Context, e Event) error {
+			published = append(published, e)
+			return nil
+		})
+		return buf, &published, slog.New(Wrap(slog.NewTextHandler(buf, nil), sink, options...))
+	}
+
+	t.Run("events are separated from operational logs", func(t *testing.T) {
+		buf, published, logger := setup()
+		logger = logger.With("svc", "shop").WithGroup("ctx")
+
+		logger.Info("request served")
+		logger.Info("order placed", Attr(OrderPlaced{ID: "o-1"}), "total", 42)
+
+		if !strings.Contains(buf.String(), "request served") || strings.Contains(buf.String(), "order placed") {
+			t.Errorf("unexpected operational output: %s", buf.String())
+		}
+		if len(*published) != 1 {
+			t.Fatalf("expected 1 event, got %d", len(*published))
+		}
+		e := (*published)[0]
+		if e.Name != "order.placed" || e.Payload.(OrderPlaced).ID != "o-1" || e.Message != "order placed" {
+			t.Errorf("unexpected event: %+v", e)
+		}
+		if got := slog.GroupValue(e.Attrs...).String(); got != "[svc=shop ctx=[total=42]]" {
+			t.Errorf("unexpected event attrs: %s", got)
+		}
+	})
+
+	t.Run("events below the operational level are published", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		var published []Event
+		sink := SinkFunc(func(_ context.Context, e Event) error {
+			published = append(published, e)
+			return nil
+		})
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelWarn}), sink, WithTee()))
+		logger.Debug("cart viewed", Attr(OrderPlaced{ID: "o-2"}))
+		logger.Info("request served")
+		if len(published) != 1 || published[0].Level != slog.LevelDebug {
+			t.Errorf("unexpected events: %+v", published)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("unexpected operational output: %s", buf.String())
+		}
+	})
+
+	t.Run("payload without name uses go type", func(t *testing.T) {
+		_, published, logger := setup()
+		logger.Info("user deleted", Attr(UserDeleted{ID: 7}))
+		if (*published)[0].Name != "events.UserDeleted" {
+			t.Errorf("unexpected name: %s", (*published)[0].Name)
+		}
+	})
+
+	t.Run("tee delivers events to operational handler", func(t *testing.T) {
+		buf, published, logger := setup(WithTee())
+		logger.Info("order placed", Attr(OrderPlaced{ID: "o-2"}))
+		if len(*published) != 1 || !strings.Contains(buf.String(), "order placed") {
+			t.Errorf("expected event in both outputs, got %d events and %q", len(*published), buf.String())
+		}
+	})
+
+	t.Run("sink errors are returned", func(t *testing.T) {
+		errSink := SinkFunc(func(context.Context, Event) error { return errors.New("broker down") })
+		h := Wrap(slog.NewTextHandler(new(bytes.Buffer), nil), errSink)
+		r := slog.NewRecord(testTime, slog.LevelInfo, "msg", 0)
+		r.AddAttrs(Attr(OrderPlaced{}))
+		if err := h.Handle(context.Background(), r); err == nil || !strings.Contains(err.Error(), "broker down") {
+			t.Errorf("expected sink error, got %v", err)
+		}
+	})
+}
+
+var testTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
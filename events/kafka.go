@@ -0,0 +1,28 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mikluko/slogging/kafka"
+)
+
+// KafkaSink returns a Sink publishing events to the topic of a Kafka
+// handler, which batches and retries them. Each event becomes a record
+// with an "event" group holding its name and payload, followed by its
+// attributes, encoded by the handler: as JSON by default, or in the Avro
+// or Protobuf format of a schema registry with kafka.WithSerializer and
+// the serializers of the schemaregistry package. Payloads implementing
+// slog.LogValuer are expanded into attributes. Events are keyed as
+// configured on the handler, e.g. with kafka.WithKey("event.name").
+//
+// The handler is owned by the caller, who closes it after the last event
+// is published.
+func KafkaSink(h *kafka.Handler) Sink {
+	return SinkFunc(func(ctx context.Context, e Event) error {
+		r := slog.NewRecord(e.Time, e.Level, e.Message, 0)
+		r.AddAttrs(slog.Group("event", slog.String("name", e.Name), slog.Any("payload", e.Payload)))
+		r.AddAttrs(e.Attrs...)
+		return h.Handle(ctx, r)
+	})
+}
@@ -0,0 +1,45 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/mikluko/slogging/kafka"
+)
+
+type fakeProducer struct {
+	mutex sync.Mutex
+	msgs  []kafka.Message
+}
+
+func (p *fakeProducer) Produce(_ context.Context, _ string, msgs []kafka.Message) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+func Test_KafkaSink(t *testing.T) {
+	p := &fakeProducer{}
+	h := kafka.NewHandler(nil, "events", kafka.WithProducer(p), kafka.WithKey("event.name"))
+	logger := slog.New(Wrap(slog.NewTextHandler(io.Discard, nil), KafkaSink(h)))
+	logger.With("svc", "shop").Info("order placed", Attr(OrderPlaced{ID: "o-1"}))
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(p.msgs) != 1 || string(p.msgs[0].Key) != "order.placed" {
+		t.Fatalf("unexpected messages: %v", p.msgs)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(p.msgs[0].Value, &m); err != nil {
+		t.Fatal(err)
+	}
+	event := m["event"].(map[string]any)
+	if m["msg"] != "order placed" || m["svc"] != "shop" || event["payload"].(map[string]any)["ID"] != "o-1" {
+		t.Errorf("unexpected value: %v", m)
+	}
+}
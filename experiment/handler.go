@@ -0,0 +1,213 @@
+// Package experiment provides a slog.Handler sending every record to a
+// stable pipeline and mirroring a share of them to an experimental one,
+// such as a new format or sink, with metrics comparing both, to try out
+// logging infrastructure changes on production traffic.
+package experiment
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/mikluko/slogging/sample"
+)
+
+const defaultPercent = 10
+
+// Branch holds the counters of one pipeline branch.
+type Branch struct {
+	Records  uint64        // Records handled
+	Errors   uint64        // Records whose handling failed or panicked
+	Duration time.Duration // Total time spent handling records
+}
+
+// Mean returns the mean time spent handling a record.
+func (b Branch) Mean() time.Duration {
+	if b.Records == 0 {
+		return 0
+	}
+	return b.Duration / time.Duration(b.Records)
+}
+
+// ErrorRate returns the share of records whose handling failed.
+func (b Branch) ErrorRate() float64 {
+	if b.Records == 0 {
+		return 0
+	}
+	return float64(b.Errors) / float64(b.Records)
+}
+
+// Results compares the branches of an experiment. Baseline counts the
+// stable branch on the mirrored records only, so that it can be compared
+// with Experiment on the same records.
+type Results struct {
+	Stable     Branch
+	Baseline   Branch
+	Experiment Branch
+}
+
+type counters struct {
+	records  atomic.Uint64
+	errors   atomic.Uint64
+	duration atomic.Int64
+}
+
+func (c *counters) add(d time.Duration, failed bool) {
+	c.records.Add(1)
+	c.duration.Add(int64(d))
+	if failed {
+		c.errors.Add(1)
+	}
+}
+
+func (c *counters) load() Branch {
+	return Branch{
+		Records:  c.records.Load(),
+		Errors:   c.errors.Load(),
+		Duration: time.Duration(c.duration.Load()),
+	}
+}
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	config     handlerOptions
+	stable     counters
+	baseline   counters
+	experiment counters
+}
+
+// Handler is a slog.Handler delivering every record to the stable handler
+// and, for the records selected by the sampler, 10% of them by default,
+// to the experimental handler too. The experimental branch cannot affect
+// the stable one: its errors and panics are counted and reported to the
+// function set with WithErrorHandler, never returned.
+type Handler struct {
+	stable     slog.Handler
+	experiment slog.Handler
+	state      *state
+}
+
+// Wrap creates a handler delivering records to stable and mirroring some
+// of them to experiment.
+func Wrap(stable, experiment slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{sampler: sample.Probability(defaultPercent / 100.0)}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{stable: stable, experiment: experiment, state: &state{config: config}}
+}
+
+// Enabled reports whether either branch handles records at the given
+// level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.stable.Enabled(ctx, level) || h.experiment.Enabled(ctx, level)
+}
+
+// Handle delivers the record to the stable branch and, if selected, to the
+// experimental one. Only the error of the stable branch is returned.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	s := h.state
+	mirror := h.experiment.Enabled(ctx, r.Level) && s.config.sampler.Sample(ctx, r)
+	var err error
+	if h.stable.Enabled(ctx, r.Level) {
+		start := time.Now()
+		if mirror {
+			err = h.stable.Handle(ctx, r.Clone())
+		} else {
+			err = h.stable.Handle(ctx, r)
+		}
+		d := time.Since(start)
+		s.stable.add(d, err != nil)
+		if mirror {
+			s.baseline.add(d, err != nil)
+		}
+	}
+	if mirror {
+		start := time.Now()
+		xerr := h.try(ctx, r)
+		s.experiment.add(time.Since(start), xerr != nil)
+		if xerr != nil && s.config.onError != nil {
+			s.config.onError(xerr)
+		}
+	}
+	return err
+}
+
+// try delivers the record to the experimental branch, turning panics into
+// errors.
+func (h *Handler) try(ctx context.Context, r slog.Record) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("experimental handler panicked: %v", v)
+		}
+	}()
+	return h.experiment.Handle(ctx, r)
+}
+
+// Results returns the counters of the branches across all handlers
+// derived from the same Wrap call.
+func (h *Handler) Results() Results {
+	return Results{
+		Stable:     h.state.stable.load(),
+		Baseline:   h.state.baseline.load(),
+		Experiment: h.state.experiment.load(),
+	}
+}
+
+// WithAttrs returns a new Handler whose branches include the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.stable = h.stable.WithAttrs(attrs)
+	h2.experiment = h.experiment.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler whose branches start the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.stable = h.stable.WithGroup(name)
+	h2.experiment = h.experiment.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	sampler sample.Sampler
+	onError func(error)
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithPercent sets the percentage of records mirrored to the experimental
+// branch, selected at random. Defaults to 10.
+func WithPercent(p float64) Option {
+	return func(h *handlerOptions) {
+		h.sampler = sample.Probability(p / 100)
+	}
+}
+
+// WithSampler sets the sampler selecting the records mirrored to the
+// experimental branch, e.g. one keeping every record of some requests.
+func WithSampler(s sample.Sampler) Option {
+	return func(h *handlerOptions) {
+		h.sampler = s
+	}
+}
+
+// WithErrorHandler sets a function called with the errors and panics of
+// the experimental branch.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
@@ -0,0 +1,89 @@
+package experiment
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/slogging/sample"
+)
+
+type failing struct {
+	slog.Handler
+	panic bool
+}
+
+func (h failing) Handle(context.Context, slog.Record) error {
+	if h.panic {
+		panic("boom")
+	}
+	return errors.New("broken sink")
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("mirrors the selected records", func(t *testing.T) {
+		var stable, candidate bytes.Buffer
+		h := Wrap(slog.NewTextHandler(&stable, nil), slog.NewJSONHandler(&candidate, nil),
+			WithSampler(sample.EveryNth(2)))
+		logger := slog.New(h).With("svc", "api")
+		for range 4 {
+			logger.Info("m")
+		}
+		if n := strings.Count(stable.String(), "\n"); n != 4 {
+			t.Errorf("stable branch got %d records, want 4", n)
+		}
+		if n := strings.Count(candidate.String(), `"svc":"api"`); n != 2 {
+			t.Errorf("experimental branch got %d records, want 2", n)
+		}
+		res := h.Results()
+		if res.Stable.Records != 4 || res.Baseline.Records != 2 || res.Experiment.Records != 2 {
+			t.Errorf("Results() = %+v", res)
+		}
+	})
+
+	t.Run("percent", func(t *testing.T) {
+		var stable bytes.Buffer
+		h := Wrap(slog.NewTextHandler(&stable, nil), slog.NewTextHandler(io.Discard, nil), WithPercent(0))
+		slog.New(h).Info("m")
+		if res := h.Results(); res.Experiment.Records != 0 || res.Stable.Records != 1 {
+			t.Errorf("Results() = %+v", res)
+		}
+	})
+
+	t.Run("experimental failures are isolated", func(t *testing.T) {
+		var stable bytes.Buffer
+		var reported []error
+		for _, panics := range []bool{false, true} {
+			h := Wrap(slog.NewTextHandler(&stable, nil), failing{Handler: slog.NewTextHandler(io.Discard, nil), panic: panics},
+				WithPercent(100), WithErrorHandler(func(err error) { reported = append(reported, err) }))
+			if err := h.Handle(context.Background(), slog.NewRecord(time.Time{}, slog.LevelInfo, "m", 0)); err != nil {
+				t.Errorf("Handle() = %v", err)
+			}
+			res := h.Results()
+			if res.Experiment.Errors != 1 || res.Experiment.ErrorRate() != 1 || res.Stable.Errors != 0 {
+				t.Errorf("Results() = %+v", res)
+			}
+		}
+		if len(reported) != 2 || !strings.Contains(reported[1].Error(), "panicked: boom") {
+			t.Errorf("reported %v", reported)
+		}
+	})
+
+	t.Run("records enabled for the experimental branch only", func(t *testing.T) {
+		var stable, candidate bytes.Buffer
+		h := Wrap(slog.NewTextHandler(&stable, nil),
+			slog.NewTextHandler(&candidate, &slog.HandlerOptions{Level: slog.LevelDebug}), WithPercent(100))
+		slog.New(h).Debug("m")
+		if stable.Len() != 0 || candidate.Len() == 0 {
+			t.Errorf("stable %q, experimental %q", stable.String(), candidate.String())
+		}
+		if res := h.Results(); res.Stable.Records != 0 || res.Baseline.Records != 0 || res.Experiment.Records != 1 {
+			t.Errorf("Results() = %+v", res)
+		}
+	})
+}
@@ -0,0 +1,235 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultThreshold = 3
+	defaultCooldown  = 30 * time.Second
+)
+
+// state is shared across WithAttrs/WithGroup derivations so that every
+// logger derived from the same Handler fails over together.
+type state struct {
+	mutex     sync.Mutex
+	failures  int       // Consecutive primary failures
+	failed    bool      // Whether the secondary is active
+	retryAt   time.Time // When the primary is tried again
+	threshold int
+	cooldown  time.Duration
+	notify    func(primaryActive bool, err error)
+	now       func() time.Time
+}
+
+// usePrimary reports whether the next record should be sent to the primary.
+func (s *state) usePrimary() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return !s.failed || !s.now().Before(s.retryAt)
+}
+
+func (s *state) success() {
+	s.mutex.Lock()
+	recovered := s.failed
+	s.failures = 0
+	s.failed = false
+	notify := s.notify
+	s.mutex.Unlock()
+	if recovered && notify != nil {
+		notify(true, nil)
+	}
+}
+
+func (s *state) failure(err error) {
+	s.mutex.Lock()
+	s.failures++
+	switched := false
+	if s.failures >= s.threshold {
+		switched = !s.failed
+		s.failed = true
+		s.retryAt = s.now().Add(s.cooldown)
+	}
+	notify := s.notify
+	s.mutex.Unlock()
+	if switched && notify != nil {
+		notify(false, err)
+	}
+}
+
+// Handler is a slog.Handler that forwards records to a primary handler and
+// switches to a secondary handler once the primary returns errors for a
+// number of consecutive records. While failed over, the primary is retried
+// after every cool-down period; a successful retry switches back.
+// Records the primary fails to handle are delivered to the secondary, so
+// no record is lost while the failure threshold is being reached.
+type Handler struct {
+	primary   slog.Handler
+	secondary slog.Handler
+	state     *state
+}
+
+// New creates a failover handler with the given primary and secondary handlers.
+func New(primary, secondary slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{
+		threshold: defaultThreshold,
+		cooldown:  defaultCooldown,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{
+		primary:   primary,
+		secondary: secondary,
+		state: &state{
+			threshold: config.threshold,
+			cooldown:  config.cooldown,
+			notify:    config.notify,
+			now:       time.Now,
+		},
+	}
+}
+
+// Active reports whether the primary handler is currently in use.
+func (h *Handler) Active() bool {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	return !h.state.failed
+}
+
+// Enabled reports whether either handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.primary.Enabled(ctx, level) || h.secondary.Enabled(ctx, level)
+}
+
+// Handle delivers the record to the active handler. While the primary is
+// active, records it does not handle at their level are dropped rather than
+// delivered to the secondary.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.state.usePrimary() {
+		return h.handleSecondary(ctx, r)
+	}
+	if !h.primary.Enabled(ctx, r.Level) {
+		return nil
+	}
+	err := h.primary.Handle(ctx, r.Clone())
+	if err == nil {
+		h.state.success()
+		return nil
+	}
+	h.state.failure(err)
+	if err2 := h.handleSecondary(ctx, r); err2 != nil {
+		return errors.Join(err, err2)
+	}
+	return nil
+}
+
+func (h *Handler) handleSecondary(ctx context.Context, r slog.Record) error {
+	if !h.secondary.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.secondary.Handle(ctx, r)
+}
+
+// WithAttrs returns a new Handler whose handlers include the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &Handler{
+		primary:   h.primary.WithAttrs(attrs),
+		secondary: h.secondary.WithAttrs(attrs),
+		state:     h.state,
+	}
+}
+
+// WithGroup returns a new Handler whose handlers start the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{
+		primary:   h.primary.WithGroup(name),
+		secondary: h.secondary.WithGroup(name),
+		state:     h.state,
+	}
+}
+
+type handlerOptions struct {
+	threshold      int
+	cooldown       time.Duration
+	notify         func(primaryActive bool, err error)
+	notifyEndpoint func(name string, healthy bool, err error)
+	checkInterval  time.Duration
+	checkTimeout   time.Duration
+	dualWrite      bool
+}
+
+// Option is a function that configures a Handler or a Pool.
+type Option func(h *handlerOptions)
+
+// WithThreshold sets the number of consecutive primary errors that trigger
+// the switch to the secondary handler, or make an endpoint of a Pool
+// unhealthy. Values below 1 are treated as 1.
+func WithThreshold(n int) Option {
+	return func(h *handlerOptions) {
+		h.threshold = max(n, 1)
+	}
+}
+
+// WithCooldown sets how long the secondary handler stays active before the
+// primary is retried, and how long unhealthy endpoints of a Pool without
+// check are skipped.
+func WithCooldown(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.cooldown = d
+	}
+}
+
+// WithNotify sets a function called whenever the active handler changes.
+// err is the primary error that caused the switch to the secondary.
+func WithNotify(fn func(primaryActive bool, err error)) Option {
+	return func(h *handlerOptions) {
+		h.notify = fn
+	}
+}
+
+// WithEndpointNotify sets a function called whenever an endpoint of a Pool
+// becomes healthy or unhealthy. err is the error that made it unhealthy.
+func WithEndpointNotify(fn func(name string, healthy bool, err error)) Option {
+	return func(h *handlerOptions) {
+		h.notifyEndpoint = fn
+	}
+}
+
+// WithHealthCheck sets how often the endpoints of a Pool are checked, ten
+// seconds by default, and the timeout of each check, five seconds by
+// default. Values below 1 keep the defaults.
+func WithHealthCheck(interval, timeout time.Duration) Option {
+	return func(h *handlerOptions) {
+		if interval > 0 {
+			h.checkInterval = interval
+		}
+		if timeout > 0 {
+			h.checkTimeout = timeout
+		}
+	}
+}
+
+// WithDualWrite makes a Pool deliver every record to the first two
+// healthy endpoints, so that records already delivered survive the loss
+// of one backend.
+func WithDualWrite(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.dualWrite = true
+		for i := range x {
+			h.dualWrite = x[i]
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package failover
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type flakyHandler struct {
+	slog.Handler
+	fail *bool
+}
+
+func (h flakyHandler) Handle(ctx context.Context, r slog.Record) error {
+	if *h.fail {
+		return errors.New("primary down")
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h flakyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return flakyHandler{h.Handler.WithAttrs(attrs), h.fail}
+}
+
+func Test_Failover(t *testing.T) {
+	setup := func() (*bytes.Buffer, *bytes.Buffer, *bool, *Handler, *time.Time) {
+		pbuf := new(bytes.Buffer)
+		sbuf := new(bytes.Buffer)
+		fail := new(bool)
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		h := New(
+			flakyHandler{slog.NewTextHandler(pbuf, nil), fail},
+			slog.NewTextHandler(sbuf, nil),
+			WithThreshold(2),
+			WithCooldown(time.Minute),
+		)
+		h.state.now = func() time.Time { return now }
+		return pbuf, sbuf, fail, h, &now
+	}
+
+	t.Run("primary is used while healthy", func(t *testing.T) {
+		pbuf, sbuf, _, h, _ := setup()
+		slog.New(h).Info("hello")
+		if !strings.Contains(pbuf.String(), "msg=hello") || sbuf.Len() != 0 {
+			t.Errorf("unexpected output: primary=%q secondary=%q", pbuf.String(), sbuf.String())
+		}
+	})
+
+	t.Run("records disabled by a healthy primary are dropped", func(t *testing.T) {
+		pbuf, sbuf := new(bytes.Buffer), new(bytes.Buffer)
+		h := New(
+			slog.NewTextHandler(pbuf, &slog.HandlerOptions{Level: slog.LevelWarn}),
+			slog.NewTextHandler(sbuf, &slog.HandlerOptions{Level: slog.LevelDebug}),
+		)
+		slog.New(h).Info("hidden")
+		if pbuf.Len() != 0 || sbuf.Len() != 0 {
+			t.Errorf("unexpected output: primary=%q secondary=%q", pbuf.String(), sbuf.String())
+		}
+	})
+
+	t.Run("failed records are delivered to secondary", func(t *testing.T) {
+		_, sbuf, fail, h, _ := setup()
+		*fail = true
+		slog.New(h).Info("rescued")
+		if !strings.Contains(sbuf.String(), "msg=rescued") {
+			t.Errorf("expected record in secondary, got %q", sbuf.String())
+		}
+		if !h.Active() {
+			t.Errorf("should not switch before reaching the threshold")
+		}
+	})
+
+	t.Run("switches after threshold and retries after cooldown", func(t *testing.T) {
+		pbuf, sbuf, fail, h, now := setup()
+		var events []bool
+		h.state.notify = func(primaryActive bool, _ error) { events = append(events, primaryActive) }
+		logger := slog.New(h).With("k", "v")
+
+		*fail = true
+		logger.Info("one")
+		logger.Info("two")
+		if h.Active() {
+			t.Fatalf("expected failover after threshold")
+		}
+
+		*fail = false
+		logger.Info("three")
+		if strings.Contains(pbuf.String(), "three") {
+			t.Errorf("primary should not be retried before cooldown")
+		}
+		if !strings.Contains(sbuf.String(), "msg=three k=v") {
+			t.Errorf("expected record in secondary, got %q", sbuf.String())
+		}
+
+		*now = now.Add(time.Minute)
+		logger.Info("four")
+		if !strings.Contains(pbuf.String(), "msg=four k=v") {
+			t.Errorf("expected primary to be retried, got %q", pbuf.String())
+		}
+		if !h.Active() {
+			t.Errorf("expected primary to be active after successful retry")
+		}
+		if len(events) != 2 || events[0] || !events[1] {
+			t.Errorf("unexpected notifications: %v", events)
+		}
+	})
+
+	t.Run("errors from both handlers are returned", func(t *testing.T) {
+		fail := true
+		h := New(
+			flakyHandler{slog.NewTextHandler(new(bytes.Buffer), nil), &fail},
+			flakyHandler{slog.NewTextHandler(new(bytes.Buffer), nil), &fail},
+		)
+		err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0))
+		if err == nil {
+			t.Errorf("expected error when both handlers fail")
+		}
+	})
+}
@@ -0,0 +1,326 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCheckInterval = 10 * time.Second
+	defaultCheckTimeout  = 5 * time.Second
+)
+
+// Endpoint is a handler delivering to one endpoint of a logging backend,
+// e.g. a loki.Handler of one region.
+type Endpoint struct {
+	Name    string
+	Handler slog.Handler
+	// Check probes the health of the endpoint, e.g. with HTTPCheck or
+	// DialCheck. It is needed for handlers that deliver in the background
+	// and never fail Handle. If nil, the health of the endpoint follows
+	// the errors of Handle only.
+	Check func(ctx context.Context) error
+}
+
+// HTTPCheck returns a check succeeding when a GET request to url, such as
+// the readiness endpoint of the backend, answers with a 2xx status. A nil
+// client uses http.DefaultClient.
+func HTTPCheck(client *http.Client, url string) func(ctx context.Context) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return fmt.Errorf("error when creating health check request: %w", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return fmt.Errorf("error when checking %s: %w", url, err)
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("error when checking %s: %s", url, resp.Status)
+		}
+		return nil
+	}
+}
+
+// DialCheck returns a check succeeding when a connection to address can be
+// opened, e.g. to the TCP port of a syslog or GELF server.
+func DialCheck(network, address string) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, network, address)
+		if err != nil {
+			return fmt.Errorf("error when checking %s: %w", address, err)
+		}
+		return conn.Close()
+	}
+}
+
+type endpointState struct {
+	name     string
+	check    func(ctx context.Context) error
+	healthy  bool
+	failures int       // Consecutive Handle failures
+	retryAt  time.Time // When an unhealthy endpoint without check is tried again
+	err      error     // Last error
+}
+
+// pool is shared across WithAttrs/WithGroup derivations so that every
+// logger derived from the same Pool sees the same health.
+type pool struct {
+	mutex     sync.Mutex
+	endpoints []*endpointState
+	config    handlerOptions
+	now       func() time.Time
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// targets returns the indices of the endpoints to deliver to, in priority
+// order: healthy endpoints, and endpoints without check whose cool-down is
+// over. If there are none, every endpoint is a target, so that records are
+// not dropped while all endpoints are unhealthy.
+func (p *pool) targets() []int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	now := p.now()
+	var out []int
+	for i, e := range p.endpoints {
+		if e.healthy || e.check == nil && !now.Before(e.retryAt) {
+			out = append(out, i)
+		}
+	}
+	if len(out) == 0 {
+		for i := range p.endpoints {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+func (p *pool) success(i int) {
+	p.mutex.Lock()
+	e := p.endpoints[i]
+	e.failures = 0
+	recovered := !e.healthy
+	e.healthy = true
+	e.err = nil
+	p.mutex.Unlock()
+	if recovered {
+		p.notify(e.name, true, nil)
+	}
+}
+
+func (p *pool) failure(i int, err error, threshold int) {
+	p.mutex.Lock()
+	e := p.endpoints[i]
+	e.failures++
+	e.err = err
+	failed := false
+	if e.failures >= threshold {
+		failed = e.healthy
+		e.healthy = false
+		e.retryAt = p.now().Add(p.config.cooldown)
+	}
+	p.mutex.Unlock()
+	if failed {
+		p.notify(e.name, false, err)
+	}
+}
+
+func (p *pool) notify(name string, healthy bool, err error) {
+	if p.config.notifyEndpoint != nil {
+		p.config.notifyEndpoint(name, healthy, err)
+	}
+}
+
+// run probes the endpoints having a check at every check interval.
+func (p *pool) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.config.checkInterval)
+	defer ticker.Stop()
+	for {
+		p.checkAll()
+		select {
+		case <-ticker.C:
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+func (p *pool) checkAll() {
+	var wg sync.WaitGroup
+	for i, e := range p.endpoints {
+		if e.check == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), p.config.checkTimeout)
+			defer cancel()
+			if err := e.check(ctx); err != nil {
+				p.failure(i, err, 1)
+			} else {
+				p.success(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Pool is a slog.Handler delivering records to the first healthy of
+// several endpoints in priority order, or to the first two with
+// WithDualWrite, so that delivery survives the outage of a backend region.
+//
+// An endpoint becomes unhealthy when its check fails, or when Handle fails
+// for a number of consecutive records, as set with WithThreshold. Records
+// an endpoint fails to handle are delivered to the next one. Unhealthy
+// endpoints with a check become healthy again when the check succeeds;
+// those without are retried after the cool-down period set with
+// WithCooldown. Close must be called to stop the health checks.
+type Pool struct {
+	handlers []slog.Handler
+	pool     *pool
+}
+
+// NewPool creates a pool of the given endpoints, highest priority first,
+// all initially healthy, and starts the health checks of the endpoints
+// having one.
+func NewPool(endpoints []Endpoint, options ...Option) *Pool {
+	config := handlerOptions{
+		threshold:     defaultThreshold,
+		cooldown:      defaultCooldown,
+		checkInterval: defaultCheckInterval,
+		checkTimeout:  defaultCheckTimeout,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	p := &pool{
+		config: config,
+		now:    time.Now,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	handlers := make([]slog.Handler, len(endpoints))
+	checked := false
+	for i, e := range endpoints {
+		handlers[i] = e.Handler
+		p.endpoints = append(p.endpoints, &endpointState{name: e.Name, check: e.Check, healthy: true})
+		checked = checked || e.Check != nil
+	}
+	if checked {
+		go p.run()
+	} else {
+		close(p.done)
+	}
+	return &Pool{handlers: handlers, pool: p}
+}
+
+// Enabled reports whether any endpoint handles records at the given level.
+func (h *Pool) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle delivers the record to the first target endpoints handling it,
+// falling through to the next ones on failure. It fails only when no
+// endpoint handled the record.
+func (h *Pool) Handle(ctx context.Context, r slog.Record) error {
+	want := 1
+	if h.pool.config.dualWrite {
+		want = 2
+	}
+	delivered, enabled := 0, false
+	var errs []error
+	for _, i := range h.pool.targets() {
+		if delivered == want {
+			break
+		}
+		handler := h.handlers[i]
+		if !handler.Enabled(ctx, r.Level) {
+			continue
+		}
+		enabled = true
+		if err := handler.Handle(ctx, r.Clone()); err != nil {
+			h.pool.failure(i, err, h.pool.config.threshold)
+			errs = append(errs, fmt.Errorf("%s: %w", h.pool.endpoints[i].name, err))
+			continue
+		}
+		h.pool.success(i)
+		delivered++
+	}
+	if delivered > 0 || !enabled {
+		return nil
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs returns a new Pool sharing the health state whose handlers
+// include the given attributes.
+func (h *Pool) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return &Pool{handlers: handlers, pool: h.pool}
+}
+
+// WithGroup returns a new Pool sharing the health state whose handlers
+// start the given group.
+func (h *Pool) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return &Pool{handlers: handlers, pool: h.pool}
+}
+
+// Healthy returns the names of the healthy endpoints in priority order.
+func (h *Pool) Healthy() []string {
+	h.pool.mutex.Lock()
+	defer h.pool.mutex.Unlock()
+	var names []string
+	for _, e := range h.pool.endpoints {
+		if e.healthy {
+			names = append(names, e.name)
+		}
+	}
+	return names
+}
+
+// Close stops the health checks. It does not close the endpoint handlers.
+func (h *Pool) Close() {
+	p := h.pool
+	p.mutex.Lock()
+	select {
+	case <-p.stop:
+	default:
+		close(p.stop)
+	}
+	p.mutex.Unlock()
+	<-p.done
+}
@@ -0,0 +1,140 @@
+package failover
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func Test_Pool(t *testing.T) {
+	endpoint := func(name string, fail *bool) (Endpoint, *bytes.Buffer) {
+		buf := new(bytes.Buffer)
+		return Endpoint{Name: name, Handler: flakyHandler{slog.NewTextHandler(buf, nil), fail}}, buf
+	}
+
+	t.Run("first healthy endpoint is used", func(t *testing.T) {
+		failA, failB := new(bool), new(bool)
+		a, abuf := endpoint("a", failA)
+		b, bbuf := endpoint("b", failB)
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		var events []string
+		p := NewPool([]Endpoint{a, b}, WithThreshold(2), WithCooldown(time.Minute),
+			WithEndpointNotify(func(name string, healthy bool, _ error) {
+				events = append(events, name+"="+map[bool]string{true: "up", false: "down"}[healthy])
+			}))
+		defer p.Close()
+		p.pool.now = func() time.Time { return now }
+		logger := slog.New(p).With("k", "v")
+
+		logger.Info("one")
+		*failA = true
+		logger.Info("two")
+		logger.Info("three")
+		if !strings.Contains(abuf.String(), "one") || !strings.Contains(bbuf.String(), "msg=two k=v") || !strings.Contains(bbuf.String(), "three") {
+			t.Errorf("unexpected output: a=%q b=%q", abuf.String(), bbuf.String())
+		}
+		if names := p.Healthy(); len(names) != 1 || names[0] != "b" {
+			t.Errorf("unexpected healthy endpoints: %v", names)
+		}
+
+		*failA = false
+		logger.Info("four")
+		now = now.Add(time.Minute)
+		logger.Info("five")
+		if strings.Contains(abuf.String(), "four") || !strings.Contains(abuf.String(), "five") {
+			t.Errorf("expected a retried after cooldown only: %q", abuf.String())
+		}
+		if strings.Join(events, ",") != "a=down,a=up" {
+			t.Errorf("unexpected notifications: %v", events)
+		}
+
+		*failA, *failB = true, true
+		if err := logger.Handler().Handle(context.Background(), slog.NewRecord(now, slog.LevelInfo, "lost", 0)); err == nil {
+			t.Errorf("expected an error when every endpoint fails")
+		}
+	})
+
+	t.Run("dual write delivers to two endpoints", func(t *testing.T) {
+		ok := new(bool)
+		a, abuf := endpoint("a", ok)
+		b, bbuf := endpoint("b", ok)
+		c, cbuf := endpoint("c", ok)
+		p := NewPool([]Endpoint{a, b, c}, WithDualWrite())
+		defer p.Close()
+		slog.New(p).Info("twice")
+		if !strings.Contains(abuf.String(), "twice") || !strings.Contains(bbuf.String(), "twice") || cbuf.Len() != 0 {
+			t.Errorf("unexpected output: a=%q b=%q c=%q", abuf.String(), bbuf.String(), cbuf.String())
+		}
+	})
+
+	t.Run("health checks drive failover", func(t *testing.T) {
+		var down atomic.Bool
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			if down.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer srv.Close()
+		ok := new(bool)
+		a, _ := endpoint("a", ok)
+		a.Check = HTTPCheck(nil, srv.URL+"/ready")
+		b, _ := endpoint("b", ok)
+		b.Check = func(context.Context) error { return nil }
+		p := NewPool([]Endpoint{a, b}, WithHealthCheck(time.Millisecond, time.Second))
+		defer p.Close()
+
+		wait := func(want string) {
+			t.Helper()
+			deadline := time.Now().Add(time.Second)
+			for strings.Join(p.Healthy(), ",") != want {
+				if time.Now().After(deadline) {
+					t.Fatalf("expected healthy %q, got %v", want, p.Healthy())
+				}
+				time.Sleep(time.Millisecond)
+			}
+		}
+		down.Store(true)
+		wait("b")
+		down.Store(false)
+		wait("a,b")
+	})
+
+	t.Run("invalid health check settings keep the defaults", func(t *testing.T) {
+		a, _ := endpoint("a", new(bool))
+		a.Check = func(context.Context) error { return nil }
+		p := NewPool([]Endpoint{a}, WithHealthCheck(0, -time.Second))
+		defer p.Close()
+		if c := p.pool.config; c.checkInterval != defaultCheckInterval || c.checkTimeout != defaultCheckTimeout {
+			t.Errorf("unexpected health check settings: %s, %s", c.checkInterval, c.checkTimeout)
+		}
+	})
+
+	t.Run("dial check", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		addr := srv.Listener.Addr().String()
+		if err := DialCheck("tcp", addr)(context.Background()); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		srv.Close()
+		if err := DialCheck("tcp", addr)(context.Background()); err == nil {
+			t.Errorf("expected an error after close")
+		}
+	})
+
+	t.Run("disabled endpoints are skipped", func(t *testing.T) {
+		ok := new(bool)
+		quiet := Endpoint{Name: "quiet", Handler: slog.NewTextHandler(new(bytes.Buffer), &slog.HandlerOptions{Level: slog.LevelError})}
+		b, bbuf := endpoint("b", ok)
+		p := NewPool([]Endpoint{quiet, b})
+		defer p.Close()
+		if err := p.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "info", 0)); err != nil || !strings.Contains(bbuf.String(), "info") {
+			t.Errorf("unexpected outcome: %v, %q", err, bbuf.String())
+		}
+	})
+}
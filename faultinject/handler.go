@@ -0,0 +1,164 @@
+// Package faultinject degrades a logging pipeline on purpose, for tests and
+// game days validating that applications tolerate a slow, failing or lossy
+// logging backend.
+package faultinject
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInjected is the error returned by Handle for injected failures unless
+// another error is set with WithError.
+var ErrInjected = errors.New("slogging: injected fault")
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	disabled atomic.Bool
+	delayed  atomic.Uint64
+	failed   atomic.Uint64
+	dropped  atomic.Uint64
+}
+
+// Handler is a slog.Handler injecting faults before records reach the
+// wrapped handler. For each record, independently and in this order, it
+// waits for the configured latency, fails with the configured error or
+// silently drops the record, each with its own probability. Faults can be
+// switched off and on at runtime with SetEnabled.
+type Handler struct {
+	handler slog.Handler
+	config  handlerOptions
+	state   *state
+	rand    func() float64
+}
+
+// Wrap creates a handler injecting the faults configured by options into
+// the records delivered to handler. Without options no fault is injected.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{err: ErrInjected}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{handler: handler, config: config, state: new(state), rand: rand.Float64}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle injects faults and passes the record to the wrapped handler if
+// it was neither failed nor dropped. Injected latency is cut short when ctx
+// is done.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if h.state.disabled.Load() {
+		return h.handler.Handle(ctx, r)
+	}
+	if h.config.latency > 0 && h.hit(h.config.latencyP) {
+		h.state.delayed.Add(1)
+		timer := time.NewTimer(h.config.latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	if h.hit(h.config.errorP) {
+		h.state.failed.Add(1)
+		return h.config.err
+	}
+	if h.hit(h.config.dropP) {
+		h.state.dropped.Add(1)
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *Handler) hit(p float64) bool {
+	return p > 0 && (p >= 1 || h.rand() < p)
+}
+
+// SetEnabled switches fault injection on or off for all handlers derived
+// from the same Wrap call. It is on after Wrap.
+func (h *Handler) SetEnabled(on bool) {
+	h.state.disabled.Store(!on)
+}
+
+// Delayed returns the number of records delayed by injected latency.
+func (h *Handler) Delayed() uint64 {
+	return h.state.delayed.Load()
+}
+
+// Failed returns the number of records failed with an injected error.
+func (h *Handler) Failed() uint64 {
+	return h.state.failed.Load()
+}
+
+// Dropped returns the number of records silently dropped.
+func (h *Handler) Dropped() uint64 {
+	return h.state.dropped.Load()
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	latency  time.Duration
+	latencyP float64
+	errorP   float64
+	err      error
+	dropP    float64
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLatency delays records by d with probability p.
+func WithLatency(p float64, d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.latencyP = p
+		h.latency = d
+	}
+}
+
+// WithError fails records with probability p, returning err from Handle
+// instead of delivering them. A nil err returns ErrInjected.
+func WithError(p float64, err error) Option {
+	return func(h *handlerOptions) {
+		h.errorP = p
+		if err != nil {
+			h.err = err
+		}
+	}
+}
+
+// WithDrop silently discards records with probability p, as a lossy
+// transport would.
+func WithDrop(p float64) Option {
+	return func(h *handlerOptions) {
+		h.dropP = p
+	}
+}
@@ -0,0 +1,76 @@
+package faultinject
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Handler(t *testing.T) {
+	t.Run("no faults by default", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil))
+		slog.New(h).Info("test message")
+		if !strings.Contains(buf.String(), "test message") || h.Delayed()+h.Failed()+h.Dropped() != 0 {
+			t.Errorf("unexpected result: %s", buf.String())
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		errBackend := errors.New("backend down")
+		h := Wrap(slog.NewTextHandler(buf, nil), WithError(1, errBackend))
+
+		err := h.WithAttrs([]slog.Attr{slog.String("k", "v")}).Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "test message", 0))
+		if !errors.Is(err, errBackend) || buf.Len() != 0 || h.Failed() != 1 {
+			t.Errorf("unexpected result: %v, %s", err, buf.String())
+		}
+	})
+
+	t.Run("probabilistic drops", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil), WithDrop(0.5))
+		rolls := []float64{0.1, 0.9, 0.4, 0.6}
+		h.rand = func() float64 {
+			r := rolls[0]
+			rolls = rolls[1:]
+			return r
+		}
+		logger := slog.New(h)
+		for _, msg := range []string{"one", "two", "three", "four"} {
+			logger.Info(msg)
+		}
+		if out := buf.String(); strings.Contains(out, "one") || !strings.Contains(out, "two") || strings.Contains(out, "three") || !strings.Contains(out, "four") || h.Dropped() != 2 {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("latency honors context", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil), WithLatency(1, time.Hour))
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		slog.New(h).InfoContext(ctx, "test message")
+		if !strings.Contains(buf.String(), "test message") || h.Delayed() != 1 {
+			t.Errorf("expected delayed delivery, got: %s", buf.String())
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil), WithDrop(1))
+		logger := slog.New(h.WithGroup("g"))
+		h.SetEnabled(false)
+		logger.Info("delivered")
+		h.SetEnabled(true)
+		logger.Info("dropped")
+		if out := buf.String(); !strings.Contains(out, "delivered") || strings.Contains(out, "dropped") {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+}
@@ -0,0 +1,311 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrSyntax is returned when a filter expression cannot be parsed.
+var ErrSyntax = errors.New("slogging: invalid filter expression")
+
+// Parse compiles an expression into a rule builder, to be completed with
+// Drop or Pass:
+//
+//	filter.Parse(`level < WARN && env == "dev" && msg ~ "^health"`)
+//
+// The grammar is:
+//
+//	expr       = and { "||" and }
+//	and        = unary { "&&" unary }
+//	unary      = "!" unary | "(" expr ")" | comparison
+//	comparison = operand [ op literal ]
+//	op         = "==" | "!=" | "<" | "<=" | ">" | ">=" | "~" | "!~"
+//
+// The operand "level" is the level of the record, compared with level
+// names such as WARN or ERROR+2, quoted or not, or numbers; "msg" is the
+// message, compared with strings. Any other operand is the path of an
+// attribute, such as env or http.status, compared with strings, numbers,
+// true or false; a path alone matches records having the attribute.
+// Attributes named "level" or "msg" cannot be referred to. The operators ~
+// and !~ match regular expressions. Comparisons with missing attributes
+// are false, whatever the operator.
+func Parse(expr string) (*Builder, error) {
+	p := &parser{lexer: lexer{src: expr}}
+	p.next()
+	c, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", p.tok.text)
+	}
+	return Where(c), nil
+}
+
+// MustParse is like Parse but panics if the expression cannot be parsed.
+func MustParse(expr string) *Builder {
+	b, err := Parse(expr)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	for l.pos < len(l.src) && strings.ContainsRune(" \t\r\n", rune(l.src[l.pos])) {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case c == '"' || c == '\'':
+		var b strings.Builder
+		for l.pos++; l.pos < len(l.src); l.pos++ {
+			switch l.src[l.pos] {
+			case c:
+				l.pos++
+				return token{kind: tokString, text: b.String(), pos: start}, nil
+			case '\\':
+				if l.pos+1 < len(l.src) {
+					l.pos++
+				}
+			}
+			b.WriteByte(l.src[l.pos])
+		}
+		return token{}, fmt.Errorf("%w at offset %d: unterminated string", ErrSyntax, start)
+	case c >= '0' && c <= '9' || c == '-' && l.pos+1 < len(l.src) && l.src[l.pos+1] >= '0' && l.src[l.pos+1] <= '9':
+		l.pos++
+		for l.pos < len(l.src) && (l.src[l.pos] >= '0' && l.src[l.pos] <= '9' || l.src[l.pos] == '.') {
+			l.pos++
+		}
+		return token{kind: tokNumber, text: l.src[start:l.pos], pos: start}, nil
+	case isIdent(c, true):
+		for l.pos < len(l.src) && isIdent(l.src[l.pos], false) {
+			l.pos++
+		}
+		return token{kind: tokIdent, text: l.src[start:l.pos], pos: start}, nil
+	}
+	for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "!~", "<", ">", "~", "!", "(", ")"} {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{kind: tokOp, text: op, pos: start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("%w at offset %d: unexpected %q", ErrSyntax, start, c)
+}
+
+func isIdent(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		!first && (c >= '0' && c <= '9' || c == '.' || c == '+' || c == '-')
+}
+
+type parser struct {
+	lexer lexer
+	tok   token
+	err   error
+}
+
+func (p *parser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lexer.next()
+	if p.err != nil {
+		p.tok = token{kind: tokEOF, pos: p.lexer.pos}
+	}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf("%w at offset %d: %s", ErrSyntax, p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+func (p *parser) or() (Condition, error) {
+	c, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("||") {
+		p.next()
+		d, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		c = func(l, r Condition) Condition {
+			return func(rec *Record) bool { return l(rec) || r(rec) }
+		}(c, d)
+	}
+	return c, nil
+}
+
+func (p *parser) and() (Condition, error) {
+	c, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("&&") {
+		p.next()
+		d, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		c = func(l, r Condition) Condition {
+			return func(rec *Record) bool { return l(rec) && r(rec) }
+		}(c, d)
+	}
+	return c, nil
+}
+
+func (p *parser) unary() (Condition, error) {
+	switch {
+	case p.isOp("!"):
+		p.next()
+		c, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(r *Record) bool { return !c(r) }, nil
+	case p.isOp("("):
+		p.next()
+		c, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if !p.isOp(")") {
+			return nil, p.errorf("expected ')'")
+		}
+		p.next()
+		return c, nil
+	}
+	return p.comparison()
+}
+
+var comparisons = map[string]func(int) bool{
+	"==": func(c int) bool { return c == 0 },
+	"!=": func(c int) bool { return c != 0 },
+	"<":  func(c int) bool { return c < 0 },
+	"<=": func(c int) bool { return c <= 0 },
+	">":  func(c int) bool { return c > 0 },
+	">=": func(c int) bool { return c >= 0 },
+}
+
+func (p *parser) comparison() (Condition, error) {
+	if p.tok.kind != tokIdent {
+		return nil, p.errorf("expected level, msg or attribute path")
+	}
+	operand := p.tok.text
+	p.next()
+	if p.tok.kind != tokOp || (comparisons[p.tok.text] == nil && p.tok.text != "~" && p.tok.text != "!~") {
+		if operand == "level" || operand == "msg" {
+			return nil, p.errorf("expected operator after %s", operand)
+		}
+		return func(r *Record) bool {
+			_, ok := r.Lookup(operand)
+			return ok
+		}, nil
+	}
+	op := p.tok.text
+	p.next()
+	lit := p.tok
+	if lit.kind != tokString && lit.kind != tokNumber && lit.kind != tokIdent {
+		return nil, p.errorf("expected literal after %s", op)
+	}
+	p.next()
+
+	if op == "~" || op == "!~" {
+		if lit.kind != tokString {
+			return nil, p.errorf("expected quoted regular expression after %s", op)
+		}
+		re, err := regexp.Compile(lit.text)
+		if err != nil {
+			return nil, fmt.Errorf("%w at offset %d: %w", ErrSyntax, lit.pos, err)
+		}
+		negate := op == "!~"
+		if operand == "msg" {
+			return func(r *Record) bool { return re.MatchString(r.Message) != negate }, nil
+		}
+		return func(r *Record) bool {
+			v, ok := r.Lookup(operand)
+			return ok && re.MatchString(v.String()) != negate
+		}, nil
+	}
+
+	cmp := comparisons[op]
+	switch operand {
+	case "level":
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(lit.text)); err != nil {
+			n, err := strconv.Atoi(lit.text)
+			if err != nil || lit.kind == tokString {
+				return nil, fmt.Errorf("%w at offset %d: invalid level %q", ErrSyntax, lit.pos, lit.text)
+			}
+			level = slog.Level(n)
+		}
+		return func(r *Record) bool { return cmp(int(r.Level - level)) }, nil
+	case "msg":
+		if lit.kind != tokString {
+			return nil, fmt.Errorf("%w at offset %d: expected string to compare msg with", ErrSyntax, lit.pos)
+		}
+		return func(r *Record) bool { return cmp(strings.Compare(r.Message, lit.text)) }, nil
+	}
+	var want slog.Value
+	switch {
+	case lit.kind == tokString:
+		want = slog.StringValue(lit.text)
+	case lit.kind == tokNumber:
+		f, err := strconv.ParseFloat(lit.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w at offset %d: invalid number %q", ErrSyntax, lit.pos, lit.text)
+		}
+		want = slog.Float64Value(f)
+	case lit.text == "true" || lit.text == "false":
+		want = slog.BoolValue(lit.text == "true")
+	default:
+		return nil, fmt.Errorf("%w at offset %d: unquoted string %q", ErrSyntax, lit.pos, lit.text)
+	}
+	return func(r *Record) bool {
+		v, ok := r.Lookup(operand)
+		if !ok {
+			return false
+		}
+		c, ok := compare(v, want)
+		return ok && cmp(c)
+	}, nil
+}
@@ -0,0 +1,122 @@
+// Package filter provides a slog.Handler wrapper dropping or passing
+// records according to rules over their level, message, attribute values
+// and group paths, built with a small builder API or compiled from
+// expressions.
+package filter
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// Handler is a slog.Handler applying the action of the first rule matching
+// a record, or the default action, Pass by default, when none matches.
+// Passed records are delivered to the wrapped handler unchanged.
+type Handler struct {
+	handler slog.Handler
+	scope   scope.Scope
+	rules   []Rule
+	action  Action
+	dropped *atomic.Uint64
+}
+
+// Wrap creates a filtering handler delivering the passed records to
+// handler.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{action: Pass}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{
+		handler: handler,
+		rules:   config.rules,
+		action:  config.action,
+		dropped: new(atomic.Uint64),
+	}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle delivers the record unless it is dropped.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if h.Decide(r) == Drop {
+		h.dropped.Add(1)
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// Decide returns the action applied to r by the handler.
+func (h *Handler) Decide(r slog.Record) Action {
+	rec := &Record{Level: r.Level, Message: r.Message, record: r, scope: h.scope}
+	for _, rl := range h.rules {
+		if rl.Match(rec) {
+			return rl.Action
+		}
+	}
+	return h.action
+}
+
+// Dropped returns the number of records dropped across all handlers derived
+// from the same Wrap call.
+func (h *Handler) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	rules  []Rule
+	action Action
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithRules adds rules, evaluated in the order they were added.
+func WithRules(rules ...Rule) Option {
+	return func(h *handlerOptions) {
+		h.rules = append(h.rules, rules...)
+	}
+}
+
+// WithDefault sets the action applied to records matching no rule.
+// Defaults to Pass.
+func WithDefault(a Action) Option {
+	return func(h *handlerOptions) {
+		switch a {
+		case Pass, Drop:
+			h.action = a
+		default:
+			panic("slogging: unsupported filter action")
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package filter
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func decide(t *testing.T, rule Rule, logger func(*slog.Logger)) bool {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	h := Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}), WithRules(rule))
+	logger(slog.New(h))
+	return buf.Len() == 0
+}
+
+func Test_Builder(t *testing.T) {
+	for name, tc := range map[string]struct {
+		rule    Rule
+		log     func(*slog.Logger)
+		dropped bool
+	}{
+		"level and attribute": {
+			rule:    Level(slog.LevelDebug).AttrEquals("env", "dev").Drop(),
+			log:     func(l *slog.Logger) { l.With("env", "dev").Debug("m") },
+			dropped: true,
+		},
+		"other level": {
+			rule: Level(slog.LevelDebug).AttrEquals("env", "dev").Drop(),
+			log:  func(l *slog.Logger) { l.With("env", "dev").Info("m") },
+		},
+		"numbers across kinds": {
+			rule:    AttrEquals("http.status", 200).Drop(),
+			log:     func(l *slog.Logger) { l.Info("m", slog.Group("http", slog.Uint64("status", 200))) },
+			dropped: true,
+		},
+		"group path of derived logger": {
+			rule:    Attr("req.id").Drop(),
+			log:     func(l *slog.Logger) { l.WithGroup("req").Info("m", "id", 1) },
+			dropped: true,
+		},
+		"message": {
+			rule:    LevelBelow(slog.LevelWarn).Message("health").Drop(),
+			log:     func(l *slog.Logger) { l.Info("GET /health") },
+			dropped: true,
+		},
+		"message regexp": {
+			rule: MessageMatches(regexp.MustCompile(`^GET /health$`)).Drop(),
+			log:  func(l *slog.Logger) { l.Info("GET /healthz") },
+		},
+		"attribute regexp": {
+			rule:    AttrMatches("path", regexp.MustCompile(`^/internal/`)).Drop(),
+			log:     func(l *slog.Logger) { l.Info("m", "path", "/internal/metrics") },
+			dropped: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := decide(t, tc.rule, tc.log); got != tc.dropped {
+				t.Errorf("dropped = %v, want %v", got, tc.dropped)
+			}
+		})
+	}
+}
+
+func Test_Parse(t *testing.T) {
+	for name, tc := range map[string]struct {
+		expr    string
+		log     func(*slog.Logger)
+		dropped bool
+	}{
+		"level name": {
+			expr:    `level < WARN && env == "dev"`,
+			log:     func(l *slog.Logger) { l.Info("m", "env", "dev") },
+			dropped: true,
+		},
+		"level offset": {
+			expr: `level >= ERROR+2`,
+			log:  func(l *slog.Logger) { l.Error("m") },
+		},
+		"or and parentheses": {
+			expr:    `!(level >= WARN) && (msg ~ "^GET /health" || path == '/ready')`,
+			log:     func(l *slog.Logger) { l.Info("probe", "path", "/ready") },
+			dropped: true,
+		},
+		"numbers": {
+			expr:    `http.status >= 200 && http.status < 300 && slow == false`,
+			log:     func(l *slog.Logger) { l.Info("m", slog.Group("http", "status", 204), "slow", false) },
+			dropped: true,
+		},
+		"durations": {
+			expr:    `elapsed > 1000000`,
+			log:     func(l *slog.Logger) { l.Info("m", "elapsed", 2*time.Millisecond) },
+			dropped: true,
+		},
+		"missing attribute": {
+			expr: `env != "prod"`,
+			log:  func(l *slog.Logger) { l.Info("m") },
+		},
+		"presence": {
+			expr:    `user && !admin`,
+			log:     func(l *slog.Logger) { l.Info("m", slog.Group("user", "id", 1)) },
+			dropped: true,
+		},
+		"negated regexp": {
+			expr:    `msg !~ "keep"`,
+			log:     func(l *slog.Logger) { l.Info("discard me") },
+			dropped: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := decide(t, MustParse(tc.expr).Drop(), tc.log); got != tc.dropped {
+				t.Errorf("dropped = %v, want %v", got, tc.dropped)
+			}
+		})
+	}
+
+	for _, expr := range []string{
+		``, `level`, `level >= LOUD`, `env == dev`, `msg ~ "("`, `(a && b`, `a == "x`, `a # b`, `msg == 1`,
+	} {
+		t.Run("invalid "+expr, func(t *testing.T) {
+			if _, err := Parse(expr); !errors.Is(err, ErrSyntax) {
+				t.Errorf("Parse(%q) = %v, want ErrSyntax", expr, err)
+			}
+		})
+	}
+}
+
+func Test_Handler(t *testing.T) {
+	buf := new(bytes.Buffer)
+	h := Wrap(slog.NewTextHandler(buf, nil),
+		WithRules(
+			LevelAtLeast(slog.LevelError).Pass(),
+			AttrEquals("tenant", "noisy").Drop(),
+		),
+		WithDefault(Pass),
+	)
+	logger := slog.New(h).With("tenant", "noisy")
+	logger.Info("dropped")
+	logger.Error("kept")
+	slog.New(h).Info("passed")
+	out := buf.String()
+	if strings.Contains(out, "msg=dropped") || !strings.Contains(out, "msg=kept") || !strings.Contains(out, "msg=passed") {
+		t.Errorf("unexpected output: %s", out)
+	}
+	if h.Dropped() != 1 {
+		t.Errorf("dropped = %d, want 1", h.Dropped())
+	}
+}
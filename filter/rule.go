@@ -0,0 +1,281 @@
+package filter
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// Record is the view of a record conditions are evaluated on. Its
+// attributes include those added via WithAttrs, nested under the groups
+// opened via WithGroup, so that paths are the same whatever logger the
+// record was logged with.
+type Record struct {
+	Level   slog.Level
+	Message string
+
+	record slog.Record
+	scope  scope.Scope
+	attrs  []slog.Attr
+	ready  bool
+}
+
+// NewRecord returns the view of r for evaluating rules outside of a
+// Handler. Only the attributes of r itself are visible.
+func NewRecord(r slog.Record) *Record {
+	return &Record{Level: r.Level, Message: r.Message, record: r}
+}
+
+// Attrs returns the attributes of the record. The returned slice must not
+// be modified.
+func (r *Record) Attrs() []slog.Attr {
+	if !r.ready {
+		r.attrs = r.scope.Attrs(r.record)
+		r.ready = true
+	}
+	return r.attrs
+}
+
+// Lookup returns the resolved value at path, group names and key joined
+// with dots such as "http.status". Groups are values too. Of attributes
+// sharing a key, the last one wins.
+func (r *Record) Lookup(path string) (slog.Value, bool) {
+	return lookup(r.Attrs(), strings.Split(path, "."))
+}
+
+func lookup(attrs []slog.Attr, path []string) (v slog.Value, ok bool) {
+	for _, a := range attrs {
+		av := a.Value.Resolve()
+		if a.Key == "" && av.Kind() == slog.KindGroup {
+			if iv, iok := lookup(av.Group(), path); iok {
+				v, ok = iv, true
+			}
+			continue
+		}
+		if a.Key != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			v, ok = av, true
+			continue
+		}
+		if av.Kind() == slog.KindGroup {
+			if iv, iok := lookup(av.Group(), path[1:]); iok {
+				v, ok = iv, true
+			}
+		}
+	}
+	return v, ok
+}
+
+// Condition reports whether a record matches.
+type Condition func(r *Record) bool
+
+// Action is what happens to a record matched by a rule.
+type Action int
+
+const (
+	// Pass delivers the record.
+	Pass Action = iota
+	// Drop discards the record.
+	Drop
+)
+
+// String returns "pass" or "drop".
+func (a Action) String() string {
+	switch a {
+	case Pass:
+		return "pass"
+	case Drop:
+		return "drop"
+	}
+	return fmt.Sprintf("Action(%d)", int(a))
+}
+
+// Rule applies an action to the records matching all of its conditions.
+type Rule struct {
+	Action     Action
+	Conditions []Condition
+}
+
+// Match reports whether r matches every condition of the rule.
+func (rl Rule) Match(r *Record) bool {
+	for _, c := range rl.Conditions {
+		if !c(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Builder builds a rule from conditions which must all hold, as in
+//
+//	filter.Level(slog.LevelDebug).AttrEquals("env", "dev").Drop()
+type Builder struct {
+	conditions []Condition
+}
+
+// Where starts a rule with the given conditions.
+func Where(conditions ...Condition) *Builder {
+	return &Builder{conditions: conditions}
+}
+
+// Level starts a rule matching records at any of the given levels.
+func Level(levels ...slog.Level) *Builder { return new(Builder).Level(levels...) }
+
+// LevelAtLeast starts a rule matching records at or above level.
+func LevelAtLeast(level slog.Leveler) *Builder { return new(Builder).LevelAtLeast(level) }
+
+// LevelBelow starts a rule matching records below level.
+func LevelBelow(level slog.Leveler) *Builder { return new(Builder).LevelBelow(level) }
+
+// Message starts a rule matching records whose message contains substr.
+func Message(substr string) *Builder { return new(Builder).Message(substr) }
+
+// MessageMatches starts a rule matching records whose message matches re.
+func MessageMatches(re *regexp.Regexp) *Builder { return new(Builder).MessageMatches(re) }
+
+// Attr starts a rule matching records with an attribute or group at path.
+func Attr(path string) *Builder { return new(Builder).Attr(path) }
+
+// AttrEquals starts a rule matching records whose attribute at path equals
+// value.
+func AttrEquals(path string, value any) *Builder { return new(Builder).AttrEquals(path, value) }
+
+// AttrMatches starts a rule matching records whose attribute at path,
+// formatted as a string, matches re.
+func AttrMatches(path string, re *regexp.Regexp) *Builder { return new(Builder).AttrMatches(path, re) }
+
+// Where adds conditions to the rule.
+func (b *Builder) Where(conditions ...Condition) *Builder {
+	b.conditions = append(b.conditions, conditions...)
+	return b
+}
+
+// Level adds a condition matching records at any of the given levels.
+func (b *Builder) Level(levels ...slog.Level) *Builder {
+	return b.Where(func(r *Record) bool {
+		for _, l := range levels {
+			if r.Level == l {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// LevelAtLeast adds a condition matching records at or above level.
+func (b *Builder) LevelAtLeast(level slog.Leveler) *Builder {
+	return b.Where(func(r *Record) bool { return r.Level >= level.Level() })
+}
+
+// LevelBelow adds a condition matching records below level.
+func (b *Builder) LevelBelow(level slog.Leveler) *Builder {
+	return b.Where(func(r *Record) bool { return r.Level < level.Level() })
+}
+
+// Message adds a condition matching records whose message contains substr.
+func (b *Builder) Message(substr string) *Builder {
+	return b.Where(func(r *Record) bool { return strings.Contains(r.Message, substr) })
+}
+
+// MessageMatches adds a condition matching records whose message matches
+// re.
+func (b *Builder) MessageMatches(re *regexp.Regexp) *Builder {
+	return b.Where(func(r *Record) bool { return re.MatchString(r.Message) })
+}
+
+// Attr adds a condition matching records with an attribute or group at
+// path.
+func (b *Builder) Attr(path string) *Builder {
+	return b.Where(func(r *Record) bool {
+		_, ok := r.Lookup(path)
+		return ok
+	})
+}
+
+// AttrEquals adds a condition matching records whose attribute at path
+// equals value. Numbers of different types are compared by value.
+func (b *Builder) AttrEquals(path string, value any) *Builder {
+	want := slog.AnyValue(value)
+	return b.Where(func(r *Record) bool {
+		v, ok := r.Lookup(path)
+		if !ok {
+			return false
+		}
+		c, ok := compare(v, want)
+		return ok && c == 0
+	})
+}
+
+// AttrMatches adds a condition matching records whose attribute at path,
+// formatted as a string, matches re.
+func (b *Builder) AttrMatches(path string, re *regexp.Regexp) *Builder {
+	return b.Where(func(r *Record) bool {
+		v, ok := r.Lookup(path)
+		return ok && re.MatchString(v.String())
+	})
+}
+
+// Drop returns a rule dropping the matched records.
+func (b *Builder) Drop() Rule {
+	return Rule{Action: Drop, Conditions: b.conditions}
+}
+
+// Pass returns a rule passing the matched records.
+func (b *Builder) Pass() Rule {
+	return Rule{Action: Pass, Conditions: b.conditions}
+}
+
+// compare compares two values, numbers by value across kinds, and reports
+// whether they are comparable.
+func compare(a, b slog.Value) (int, bool) {
+	if x, ok := number(a); ok {
+		if y, ok := number(b); ok {
+			switch {
+			case x < y:
+				return -1, true
+			case x > y:
+				return 1, true
+			}
+			return 0, true
+		}
+	}
+	switch {
+	case a.Kind() == slog.KindBool && b.Kind() == slog.KindBool:
+		if a.Bool() == b.Bool() {
+			return 0, true
+		}
+		return 1, true
+	case a.Kind() == slog.KindTime && b.Kind() == slog.KindTime:
+		return a.Time().Compare(b.Time()), true
+	case a.Kind() == slog.KindGroup || b.Kind() == slog.KindGroup:
+		return 0, false
+	}
+	return strings.Compare(a.String(), b.String()), true
+}
+
+func number(v slog.Value) (float64, bool) {
+	switch v.Kind() {
+	case slog.KindInt64:
+		return float64(v.Int64()), true
+	case slog.KindUint64:
+		return float64(v.Uint64()), true
+	case slog.KindFloat64:
+		return v.Float64(), true
+	case slog.KindDuration:
+		return float64(v.Duration()), true
+	case slog.KindAny:
+		if l, ok := v.Any().(slog.Level); ok {
+			return float64(l), true
+		}
+		if d, ok := v.Any().(time.Duration); ok {
+			return float64(d), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,273 @@
+// Package gcp provides a slog.Handler writing the structured JSON format
+// parsed natively by Google Cloud Logging from the stdout of GKE, Cloud Run
+// and Cloud Functions workloads.
+package gcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging/internal/jsonvalue"
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/severity"
+)
+
+// LabelsKey is the key of the labels special field. A top-level group with
+// this key is merged into the labels of the entry, its values rendered as
+// strings.
+const LabelsKey = "logging.googleapis.com/labels"
+
+const (
+	traceKey     = "logging.googleapis.com/trace"
+	spanIDKey    = "logging.googleapis.com/spanId"
+	sampledKey   = "logging.googleapis.com/trace_sampled"
+	sourceKey    = "logging.googleapis.com/sourceLocation"
+	severityKey  = "severity"
+	messageKey   = "message"
+	timestampKey = "time"
+)
+
+// Handler is a slog.Handler that writes every record as a single-line JSON
+// object in the Cloud Logging structured logging format: the level as
+// "severity", the message as "message", the trace context of the logging
+// context as logging.googleapis.com/trace, spanId and trace_sampled, the
+// source location when enabled, and labels and httpRequest special fields.
+// Other attributes become fields of the jsonPayload, groups nested objects.
+type Handler struct {
+	scope scope.Scope
+
+	// Shared state across WithAttrs/WithGroup instances.
+	mutex *sync.Mutex
+
+	writer    io.Writer
+	level     slog.Leveler
+	projectID string
+	labels    map[string]string
+	source    bool
+	table     *severity.Table
+}
+
+// NewHandler creates a new Handler with the given options.
+func NewHandler(options ...Option) *Handler {
+	config := handlerOptions{
+		writer: io.Discard,
+		level:  slog.LevelInfo,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{
+		mutex:     &sync.Mutex{},
+		writer:    config.writer,
+		level:     config.level,
+		projectID: config.projectID,
+		labels:    config.labels,
+		source:    config.source,
+		table:     config.table,
+	}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	table := h.table
+	if table == nil {
+		table = severity.Default()
+	}
+
+	entry := make(map[string]any)
+	labels := make(map[string]string, len(h.labels))
+	for k, v := range h.labels {
+		labels[k] = v
+	}
+
+	for _, a := range h.scope.Attrs(r) {
+		addAttr(entry, labels, a, true)
+	}
+
+	entry[severityKey] = table.Lookup(severity.GCP, r.Level).Name
+	entry[messageKey] = r.Message
+	if !r.Time.IsZero() {
+		entry[timestampKey] = r.Time.Format(time.RFC3339Nano)
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		if h.projectID != "" {
+			entry[traceKey] = fmt.Sprintf("projects/%s/traces/%s", h.projectID, sc.TraceID())
+		} else {
+			entry[traceKey] = sc.TraceID().String()
+		}
+		entry[spanIDKey] = sc.SpanID().String()
+		entry[sampledKey] = sc.IsSampled()
+	}
+	if h.source && r.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := frames.Next()
+		entry[sourceKey] = sourceLocation{File: f.File, Line: strconv.Itoa(f.Line), Function: f.Function}
+	}
+	if len(labels) > 0 {
+		entry[LabelsKey] = labels
+	}
+
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error when marshaling GCP entry: %w", err)
+	}
+	buf = append(buf, '\n')
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, err = h.writer.Write(buf)
+	return err
+}
+
+type sourceLocation struct {
+	File     string `json:"file,omitempty"`
+	Line     string `json:"line,omitempty"`
+	Function string `json:"function,omitempty"`
+}
+
+// addAttr converts a and adds it to fields. Empty attributes and empty
+// groups are dropped, groups with an empty key are inlined.
+func addAttr(fields map[string]any, labels map[string]string, a slog.Attr, top bool) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		fields[a.Key] = convertValue(a.Value)
+		return
+	}
+	attrs := a.Value.Group()
+	if top && a.Key == LabelsKey {
+		for _, ga := range attrs {
+			ga.Value = ga.Value.Resolve()
+			labels[ga.Key] = ga.Value.String()
+		}
+		return
+	}
+	if len(attrs) == 0 {
+		return
+	}
+	if a.Key == "" {
+		for _, ga := range attrs {
+			addAttr(fields, labels, ga, top)
+		}
+		return
+	}
+	inner := make(map[string]any, len(attrs))
+	for _, ga := range attrs {
+		addAttr(inner, labels, ga, false)
+	}
+	if len(inner) > 0 {
+		fields[a.Key] = inner
+	}
+}
+
+func convertValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindDuration:
+		return v.Duration().String()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	case slog.KindAny:
+		return jsonvalue.Convert(v.Any())
+	}
+	return v.Any()
+}
+
+type handlerOptions struct {
+	writer    io.Writer
+	level     slog.Leveler
+	projectID string
+	labels    map[string]string
+	source    bool
+	table     *severity.Table
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithWriter sets the writer where records will be written.
+// If writer is nil, output will be discarded.
+func WithWriter(writer io.Writer) Option {
+	return func(h *handlerOptions) {
+		if writer == nil {
+			writer = io.Discard
+		}
+		h.writer = writer
+	}
+}
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithProjectID sets the project the trace IDs are qualified with, as
+// "projects/PROJECT_ID/traces/TRACE_ID". Cloud Logging only correlates
+// entries with Cloud Trace when the trace is qualified.
+func WithProjectID(id string) Option {
+	return func(h *handlerOptions) {
+		h.projectID = id
+	}
+}
+
+// WithLabels sets labels added to every entry. Labels from LabelsKey
+// groups of the record take precedence.
+func WithLabels(labels map[string]string) Option {
+	return func(h *handlerOptions) {
+		h.labels = labels
+	}
+}
+
+// WithSource adds the logging.googleapis.com/sourceLocation field with the
+// file, line and function of the logging call.
+func WithSource(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.source = true
+		for i := range x {
+			h.source = x[i]
+		}
+	}
+}
+
+// WithSeverityTable sets the table used to map levels to GCP severities.
+// The default table from the severity package is used otherwise.
+func WithSeverityTable(t *severity.Table) Option {
+	return func(h *handlerOptions) {
+		h.table = t
+	}
+}
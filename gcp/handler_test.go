@@ -0,0 +1,131 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type stringer string
+
+func (s stringer) String() string { return "stringer " + string(s) }
+
+func decode(t *testing.T, buf *bytes.Buffer) map[string]any {
+	t.Helper()
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	return entry
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("severity and payload", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf), WithLevel(slog.LevelDebug)))
+
+		logger.With("a", 1).WithGroup("g").Warn("test message", "err", errors.New("boom"), "d", time.Second, "s", stringer("x"))
+
+		entry := decode(t, buf)
+		if entry["severity"] != "WARNING" || entry["message"] != "test message" || entry["a"] != 1.0 {
+			t.Errorf("unexpected entry: %v", entry)
+		}
+		g, _ := entry["g"].(map[string]any)
+		if g["err"] != "boom" || g["d"] != "1s" || g["s"] != "stringer x" {
+			t.Errorf("unexpected group: %v", entry["g"])
+		}
+		if _, err := time.Parse(time.RFC3339Nano, entry["time"].(string)); err != nil {
+			t.Errorf("unexpected time: %v", entry["time"])
+		}
+	})
+
+	t.Run("trace", func(t *testing.T) {
+		traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+		spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+		}))
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf), WithProjectID("my-project")))
+
+		logger.InfoContext(ctx, "test message")
+
+		entry := decode(t, buf)
+		if entry["logging.googleapis.com/trace"] != "projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736" ||
+			entry["logging.googleapis.com/spanId"] != "00f067aa0ba902b7" || entry["logging.googleapis.com/trace_sampled"] != true {
+			t.Errorf("unexpected trace fields: %v", entry)
+		}
+	})
+
+	t.Run("source location", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf), WithSource()))
+
+		logger.Info("test message")
+
+		loc, _ := decode(t, buf)["logging.googleapis.com/sourceLocation"].(map[string]any)
+		if !strings.HasSuffix(loc["file"].(string), "handler_test.go") || loc["line"] == "" || !strings.HasSuffix(loc["function"].(string), "Test_Handler.func3") {
+			t.Errorf("unexpected source location: %v", loc)
+		}
+	})
+
+	t.Run("labels", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf), WithLabels(map[string]string{"env": "prod", "team": "core"})))
+
+		logger.WithGroup("g").Info("test message", slog.Group(LabelsKey, "team", "payments", "shard", 3))
+
+		entry := decode(t, buf)
+		labels, _ := entry[LabelsKey].(map[string]any)
+		if labels["env"] != "prod" || labels["team"] != "core" {
+			t.Errorf("unexpected labels: %v", entry)
+		}
+		g, _ := entry["g"].(map[string]any)
+		if _, ok := g[LabelsKey]; !ok {
+			t.Errorf("expected labels group below the top level to stay in the payload: %v", entry)
+		}
+
+		buf.Reset()
+		logger.Info("test message", slog.Group(LabelsKey, "team", "payments", "shard", 3))
+		labels, _ = decode(t, buf)[LabelsKey].(map[string]any)
+		if labels["env"] != "prod" || labels["team"] != "payments" || labels["shard"] != "3" {
+			t.Errorf("unexpected labels: %v", labels)
+		}
+	})
+
+	t.Run("http request", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf)))
+		r := httptest.NewRequest("GET", "/path?q=1", nil)
+		r.Header.Set("User-Agent", "test-agent")
+
+		logger.Info("request finished", Request(r, 404, 128, 250*time.Millisecond).Attr())
+
+		req, _ := decode(t, buf)["httpRequest"].(map[string]any)
+		want := map[string]any{
+			"requestMethod": "GET",
+			"requestUrl":    "/path?q=1",
+			"status":        404.0,
+			"responseSize":  "128",
+			"userAgent":     "test-agent",
+			"remoteIp":      "192.0.2.1",
+			"latency":       "0.25s",
+			"protocol":      "HTTP/1.1",
+		}
+		for k, v := range want {
+			if req[k] != v {
+				t.Errorf("expected %s=%v, got: %v", k, v, req)
+			}
+		}
+	})
+}
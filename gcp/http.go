@@ -0,0 +1,71 @@
+package gcp
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPRequestKey is the key of the httpRequest special field. A top-level
+// attribute with this key holding an HTTPRequest is emitted as the field,
+// so Cloud Logging renders the request in the log entry summary.
+const HTTPRequestKey = "httpRequest"
+
+// HTTPRequest is the HttpRequest structure of Cloud Logging.
+type HTTPRequest struct {
+	RequestMethod string        `json:"requestMethod,omitempty"`
+	RequestURL    string        `json:"requestUrl,omitempty"`
+	RequestSize   int64         `json:"requestSize,omitempty,string"`
+	Status        int           `json:"status,omitempty"`
+	ResponseSize  int64         `json:"responseSize,omitempty,string"`
+	UserAgent     string        `json:"userAgent,omitempty"`
+	RemoteIP      string        `json:"remoteIp,omitempty"`
+	ServerIP      string        `json:"serverIp,omitempty"`
+	Referer       string        `json:"referer,omitempty"`
+	Latency       time.Duration `json:"-"`
+	CacheHit      bool          `json:"cacheHit,omitempty"`
+	Protocol      string        `json:"protocol,omitempty"`
+}
+
+// Request returns the HTTPRequest of a served request with the given
+// response status, response size and latency.
+func Request(r *http.Request, status int, responseSize int64, latency time.Duration) HTTPRequest {
+	remoteIP := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remoteIP); err == nil {
+		remoteIP = host
+	}
+	return HTTPRequest{
+		RequestMethod: r.Method,
+		RequestURL:    r.URL.String(),
+		RequestSize:   max(r.ContentLength, 0),
+		Status:        status,
+		ResponseSize:  responseSize,
+		UserAgent:     r.UserAgent(),
+		RemoteIP:      remoteIP,
+		Referer:       r.Referer(),
+		Latency:       latency,
+		Protocol:      r.Proto,
+	}
+}
+
+// Attr returns the request as an attribute with HTTPRequestKey.
+func (r HTTPRequest) Attr() slog.Attr {
+	return slog.Any(HTTPRequestKey, r)
+}
+
+// MarshalJSON encodes the request with its latency in the duration format
+// of Cloud Logging, e.g. "0.25s".
+func (r HTTPRequest) MarshalJSON() ([]byte, error) {
+	type plain HTTPRequest
+	v := struct {
+		plain
+		Latency string `json:"latency,omitempty"`
+	}{plain: plain(r)}
+	if r.Latency > 0 {
+		v.Latency = strconv.FormatFloat(r.Latency.Seconds(), 'f', -1, 64) + "s"
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,258 @@
+// Package gelf provides a slog.Handler shipping records to Graylog in the
+// GELF 1.1 format over UDP or TCP.
+package gelf
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/severity"
+)
+
+// Version is the GELF version of the messages.
+const Version = "1.1"
+
+var invalidKey = regexp.MustCompile(`[^\w.\-]`)
+
+// Handler is a slog.Handler that encodes every record as a GELF 1.1
+// message: the message as "short_message", the level mapped to a syslog
+// severity, and attributes as additional fields prefixed with "_". Group
+// keys are joined with "." and characters not allowed in GELF field names
+// are replaced with "_". Additional field values are numbers or strings.
+//
+// Messages are sent over UDP, compressed and chunked as needed, or over a
+// TCP connection which is redialed when a write fails, see WithUDP and
+// WithTCP. Close must be called to release the connection.
+type Handler struct {
+	scope scope.Scope
+
+	// Shared state across WithAttrs/WithGroup instances.
+	transport transport
+
+	level slog.Leveler
+	host  string
+	table *severity.Table
+}
+
+// NewHandler creates a new Handler with the given options. Connections are
+// established on first use, so dial errors are returned by Handle.
+func NewHandler(options ...Option) *Handler {
+	config := handlerOptions{
+		writer:       io.Discard,
+		level:        slog.LevelInfo,
+		chunkSize:    defaultChunkSize,
+		writeTimeout: writeTimeout,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	if config.host == "" {
+		config.host, _ = os.Hostname()
+	}
+	var t transport
+	switch config.network {
+	case "udp":
+		t = &udpTransport{addr: config.addr, compression: config.compression, chunkSize: config.chunkSize}
+	case "tcp":
+		t = &tcpTransport{addr: config.addr, timeout: config.writeTimeout}
+	default:
+		t = &writerTransport{writer: config.writer}
+	}
+	return &Handler{
+		transport: t,
+		level:     config.level,
+		host:      config.host,
+		table:     config.table,
+	}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	table := h.table
+	if table == nil {
+		table = severity.Default()
+	}
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	msg := map[string]any{
+		"version":       Version,
+		"host":          h.host,
+		"short_message": r.Message,
+		"timestamp":     json.Number(strconv.FormatFloat(float64(t.UnixMicro())/1e6, 'f', -1, 64)),
+		"level":         table.Lookup(severity.GELF, r.Level).Code,
+	}
+
+	for _, a := range h.scope.Attrs(r) {
+		addField(msg, "", a)
+	}
+
+	buf, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("error when marshaling GELF message: %w", err)
+	}
+	return h.transport.send(buf)
+}
+
+// Close closes the connection to the GELF endpoint, or the writer set with
+// WithWriter if it is an io.Closer.
+func (h *Handler) Close() error {
+	return h.transport.close()
+}
+
+// addField flattens a into additional fields of msg. Empty attributes are
+// dropped, groups with an empty key are inlined.
+func addField(msg map[string]any, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range a.Value.Group() {
+			addField(msg, prefix, ga)
+		}
+		return
+	}
+	key := "_" + invalidKey.ReplaceAllString(prefix+a.Key, "_")
+	if key == "_id" {
+		// Reserved by GELF.
+		key = "_id_"
+	}
+	msg[key] = convertValue(a.Value)
+}
+
+func convertValue(v slog.Value) any {
+	switch v.Kind() {
+	case slog.KindInt64, slog.KindUint64, slog.KindFloat64:
+		return v.Any()
+	case slog.KindTime:
+		return v.Time().Format(time.RFC3339Nano)
+	}
+	return v.String()
+}
+
+type handlerOptions struct {
+	writer       io.Writer
+	network      string
+	addr         string
+	compression  Compression
+	chunkSize    int
+	writeTimeout time.Duration
+	level        slog.Leveler
+	host         string
+	table        *severity.Table
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithUDP sends messages as UDP datagrams to addr, e.g. "graylog:12201".
+// Messages larger than the chunk size are split into GELF chunks.
+func WithUDP(addr string) Option {
+	return func(h *handlerOptions) {
+		h.network, h.addr = "udp", addr
+	}
+}
+
+// WithTCP sends null-byte delimited messages over a TCP connection to addr.
+// The connection is redialed and the message resent once when a write
+// fails, unless the write timed out, see WithWriteTimeout.
+func WithTCP(addr string) Option {
+	return func(h *handlerOptions) {
+		h.network, h.addr = "tcp", addr
+	}
+}
+
+// WithWriteTimeout sets how long a TCP write may block, 5 seconds by
+// default. A message whose write times out is dropped and the connection
+// redialed on the next message. Zero disables the timeout.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.writeTimeout = d
+	}
+}
+
+// WithWriter writes newline-delimited messages to writer instead of a
+// network endpoint, e.g. for tests or a sidecar reading a file. If writer
+// is nil, output will be discarded.
+func WithWriter(writer io.Writer) Option {
+	return func(h *handlerOptions) {
+		if writer == nil {
+			writer = io.Discard
+		}
+		h.network, h.writer = "", writer
+	}
+}
+
+// WithCompression sets the compression of UDP messages. Graylog does not
+// accept compressed messages over TCP, so it has no effect there.
+func WithCompression(c Compression) Option {
+	return func(h *handlerOptions) {
+		h.compression = c
+	}
+}
+
+// WithChunkSize sets the maximum size of a UDP datagram, 1420 bytes by
+// default, which fits common WAN MTUs. Values below 13 are treated as 13.
+func WithChunkSize(n int) Option {
+	return func(h *handlerOptions) {
+		h.chunkSize = max(n, chunkHeaderSize+1)
+	}
+}
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithHost sets the "host" field of messages. It defaults to the hostname.
+func WithHost(host string) Option {
+	return func(h *handlerOptions) {
+		h.host = host
+	}
+}
+
+// WithSeverityTable sets the table used to map levels to GELF levels.
+// The default table from the severity package is used otherwise.
+func WithSeverityTable(t *severity.Table) Option {
+	return func(h *handlerOptions) {
+		h.table = t
+	}
+}
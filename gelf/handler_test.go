@@ -0,0 +1,201 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Handler(t *testing.T) {
+	t.Run("message format", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf), WithHost("web-1")))
+
+		logger.With("id", 7, "user name", "bob").WithGroup("http").Warn("test message",
+			"status", 503, "ok", false, slog.Group("req", "method", "GET"))
+
+		var msg map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &msg); err != nil {
+			t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+		}
+		want := map[string]any{
+			"version":          "1.1",
+			"host":             "web-1",
+			"short_message":    "test message",
+			"level":            4.0,
+			"_id_":             7.0,
+			"_user_name":       "bob",
+			"_http.status":     503.0,
+			"_http.ok":         "false",
+			"_http.req.method": "GET",
+		}
+		for k, v := range want {
+			if msg[k] != v {
+				t.Errorf("expected %s=%v, got: %s", k, v, buf.String())
+			}
+		}
+		if ts, _ := msg["timestamp"].(float64); time.Since(time.Unix(int64(ts), 0)) > time.Minute {
+			t.Errorf("unexpected timestamp: %v", msg["timestamp"])
+		}
+	})
+
+	t.Run("udp chunking and compression", func(t *testing.T) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pc.Close()
+		h := NewHandler(WithUDP(pc.LocalAddr().String()), WithCompression(Gzip), WithChunkSize(64))
+		defer h.Close()
+
+		long := strings.Repeat("x", 50) + randomText(500)
+		slog.New(h).Info(long)
+
+		var (
+			chunks [][]byte
+			count  = -1
+		)
+		_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		for count < 0 || len(chunks) < count {
+			b := make([]byte, 128)
+			n, _, err := pc.ReadFrom(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n > 64 || b[0] != 0x1e || b[1] != 0x0f {
+				t.Fatalf("unexpected chunk: %x", b[:n])
+			}
+			if count < 0 {
+				count = int(b[11])
+				chunks = make([][]byte, 0, count)
+			}
+			if int(b[10]) != len(chunks) {
+				t.Fatalf("unexpected chunk sequence %d", b[10])
+			}
+			chunks = append(chunks, b[12:n])
+		}
+		if count < 2 {
+			t.Fatalf("expected a chunked message, got %d chunks", count)
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(bytes.Join(chunks, nil)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(zr)
+		if !strings.Contains(string(data), `"short_message":"`+long+`"`) {
+			t.Errorf("unexpected message: %s", data)
+		}
+	})
+
+	t.Run("tcp reconnect", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		received := make(chan string, 2)
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					r := bufio.NewReader(conn)
+					for {
+						msg, err := r.ReadString(0)
+						if err != nil {
+							return
+						}
+						received <- msg
+					}
+				}()
+			}
+		}()
+
+		h := NewHandler(WithTCP(ln.Addr().String()))
+		defer h.Close()
+		logger := slog.New(h)
+		logger.Info("first")
+		// Break the connection from the client side; the next write fails
+		// and the handler redials.
+		_ = h.transport.(*tcpTransport).conn.Close()
+		logger.Info("second")
+
+		var got []string
+		for len(got) < 2 {
+			select {
+			case msg := <-received:
+				if !strings.HasSuffix(msg, "\x00") {
+					t.Errorf("expected null-byte delimiter: %q", msg)
+				}
+				got = append(got, msg)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("timed out waiting for messages, got: %q", got)
+			}
+		}
+		all := strings.Join(got, "")
+		if !strings.Contains(all, `"short_message":"first"`) || !strings.Contains(all, `"short_message":"second"`) {
+			t.Errorf("unexpected messages: %q", got)
+		}
+	})
+
+	t.Run("tcp write timeout", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		// Accept connections but never read from them, so writes block once
+		// the socket buffers are full.
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err == nil {
+				accepted <- conn
+			}
+		}()
+
+		h := NewHandler(WithTCP(ln.Addr().String()), WithWriteTimeout(50*time.Millisecond))
+		defer h.Close()
+
+		start := time.Now()
+		err = h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, strings.Repeat("x", 32<<20), 0))
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got: %v", err)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("write blocked for %s", d)
+		}
+		if h.transport.(*tcpTransport).conn != nil {
+			t.Error("expected the connection to be closed")
+		}
+		select {
+		case conn := <-accepted:
+			_ = conn.Close()
+		default:
+		}
+	})
+
+}
+
+// randomText returns text that compresses poorly.
+func randomText(n int) string {
+	var b strings.Builder
+	x := uint32(1)
+	for i := 0; i < n; i++ {
+		x = x*1664525 + 1013904223
+		b.WriteByte('a' + byte(x>>24)%26)
+	}
+	return b.String()
+}
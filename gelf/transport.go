@@ -0,0 +1,208 @@
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Compression is the compression applied to UDP messages.
+type Compression int
+
+const (
+	// None sends messages uncompressed.
+	None Compression = iota
+	// Gzip compresses messages with gzip.
+	Gzip
+	// Zlib compresses messages with zlib.
+	Zlib
+)
+
+const (
+	defaultChunkSize = 1420
+	chunkHeaderSize  = 12
+	maxChunks        = 128
+	dialTimeout      = 5 * time.Second
+	writeTimeout     = 5 * time.Second
+)
+
+// ErrTooLarge is returned when a UDP message needs more than the 128
+// chunks allowed by GELF.
+var ErrTooLarge = errors.New("slogging: GELF message too large")
+
+type transport interface {
+	send(msg []byte) error
+	close() error
+}
+
+// writerTransport writes newline-delimited messages to an io.Writer.
+type writerTransport struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func (t *writerTransport) send(msg []byte) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	_, err := t.writer.Write(append(msg, '\n'))
+	return err
+}
+
+func (t *writerTransport) close() error {
+	if c, ok := t.writer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// udpTransport sends messages as datagrams, compressed and split into GELF
+// chunks when they exceed the chunk size.
+type udpTransport struct {
+	mutex       sync.Mutex
+	addr        string
+	conn        net.Conn
+	compression Compression
+	chunkSize   int
+}
+
+func (t *udpTransport) send(msg []byte) error {
+	msg, err := compress(msg, t.compression)
+	if err != nil {
+		return err
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.conn == nil {
+		if t.conn, err = net.DialTimeout("udp", t.addr, dialTimeout); err != nil {
+			return fmt.Errorf("error when dialing GELF endpoint: %w", err)
+		}
+	}
+	if len(msg) <= t.chunkSize {
+		_, err = t.conn.Write(msg)
+		return err
+	}
+	return t.sendChunked(msg)
+}
+
+func (t *udpTransport) sendChunked(msg []byte) error {
+	size := t.chunkSize - chunkHeaderSize
+	count := (len(msg) + size - 1) / size
+	if count > maxChunks {
+		return ErrTooLarge
+	}
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	chunk := make([]byte, 0, t.chunkSize)
+	for i := 0; i < count; i++ {
+		data := msg[i*size : min((i+1)*size, len(msg))]
+		chunk = append(chunk[:0], 0x1e, 0x0f)
+		chunk = append(chunk, id[:]...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, data...)
+		if _, err := t.conn.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *udpTransport) close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+func compress(msg []byte, c Compression) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		w   io.WriteCloser
+	)
+	switch c {
+	case Gzip:
+		w = gzip.NewWriter(&buf)
+	case Zlib:
+		w = zlib.NewWriter(&buf)
+	default:
+		return msg, nil
+	}
+	if _, err := w.Write(msg); err != nil {
+		return nil, fmt.Errorf("error when compressing GELF message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("error when compressing GELF message: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// tcpTransport sends null-byte delimited messages over a TCP connection,
+// dialed on first use and redialed when a write fails. A write that times
+// out drops the message and closes the connection, as the endpoint may
+// have received part of it.
+type tcpTransport struct {
+	mutex   sync.Mutex
+	addr    string
+	timeout time.Duration
+	conn    net.Conn
+}
+
+func (t *tcpTransport) send(msg []byte) error {
+	msg = append(msg, 0)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.conn != nil {
+		err := t.write(t.conn, msg)
+		if err == nil {
+			return nil
+		}
+		_ = t.conn.Close()
+		t.conn = nil
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("error when writing GELF message: %w", err)
+		}
+	}
+	conn, err := net.DialTimeout("tcp", t.addr, dialTimeout)
+	if err != nil {
+		return fmt.Errorf("error when dialing GELF endpoint: %w", err)
+	}
+	if err := t.write(conn, msg); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("error when writing GELF message: %w", err)
+	}
+	t.conn = conn
+	return nil
+}
+
+// write writes msg to conn within the write timeout, if any.
+func (t *tcpTransport) write(conn net.Conn, msg []byte) error {
+	if t.timeout > 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(t.timeout)); err != nil {
+			return err
+		}
+	}
+	_, err := conn.Write(msg)
+	return err
+}
+
+func (t *tcpTransport) close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
@@ -0,0 +1,205 @@
+// Package goroutines reports goroutine growth through the logging
+// pipeline, as an early signal of goroutine leaks.
+package goroutines
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultInterval = time.Minute
+	defaultGrowth   = 5
+	defaultTopSites = 5
+)
+
+// Site is a goroutine creation site and the number of live goroutines
+// created there.
+type Site struct {
+	Function string // Function containing the go statement
+	Location string // file:line of the go statement
+	Count    int
+}
+
+// Watcher periodically samples the number of goroutines and logs a warning
+// when it has grown over a number of consecutive samples, listing the
+// creation sites with the most live goroutines.
+type Watcher struct {
+	logger *slog.Logger
+	config handlerOptions
+	count  func() int
+	stack  func() []byte
+
+	sampled bool
+	last    int // Count at the last sample
+	base    int // Count before the current growth streak
+	streak  int // Consecutive increases
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Watch starts a Watcher logging to logger. Stop must be called to stop
+// sampling.
+func Watch(logger *slog.Logger, options ...Option) *Watcher {
+	w := newWatcher(logger, options)
+	go w.run()
+	return w
+}
+
+func newWatcher(logger *slog.Logger, options []Option) *Watcher {
+	config := handlerOptions{
+		interval: defaultInterval,
+		growth:   defaultGrowth,
+		topSites: defaultTopSites,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Watcher{
+		logger: logger,
+		config: config,
+		count:  runtime.NumGoroutine,
+		stack:  allStacks,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.config.interval)
+	defer ticker.Stop()
+	w.sample()
+	for {
+		select {
+		case <-ticker.C:
+			w.sample()
+		case <-w.stop:
+			return
+		}
+	}
+}
+
+// Stop stops sampling and waits for the sampling goroutine to exit.
+func (w *Watcher) Stop() {
+	w.once.Do(func() { close(w.stop) })
+	<-w.done
+}
+
+func (w *Watcher) sample() {
+	n := w.count()
+	if !w.sampled || n <= w.last {
+		w.base, w.streak = n, 0
+	} else {
+		w.streak++
+	}
+	w.sampled, w.last = true, n
+	if w.streak < w.config.growth {
+		return
+	}
+	growth := n - w.base
+	w.base, w.streak = n, 0
+
+	sites := Sites(w.stack())
+	top := make([]slog.Attr, 0, min(len(sites), w.config.topSites))
+	for i, s := range sites[:cap(top)] {
+		top = append(top, slog.Attr{Key: strconv.Itoa(i), Value: slog.GroupValue(
+			slog.String("function", s.Function),
+			slog.String("location", s.Location),
+			slog.Int("count", s.Count),
+		)})
+	}
+	w.logger.LogAttrs(context.Background(), slog.LevelWarn, "goroutine count growing",
+		slog.Int("goroutines", n),
+		slog.Int("growth", growth),
+		slog.Attr{Key: "sites", Value: slog.GroupValue(top...)},
+	)
+}
+
+func allStacks() []byte {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return buf[:n]
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
+
+// Sites parses the output of runtime.Stack for all goroutines and returns
+// the creation sites, most common first. Goroutines without a creation
+// site, such as the main goroutine, are skipped.
+func Sites(stacks []byte) []Site {
+	counts := make(map[Site]int)
+	scanner := bufio.NewScanner(bytes.NewReader(stacks))
+	scanner.Buffer(nil, len(stacks)+1)
+	for scanner.Scan() {
+		fn, ok := strings.CutPrefix(scanner.Text(), "created by ")
+		if !ok || !scanner.Scan() {
+			continue
+		}
+		fn, _, _ = strings.Cut(fn, " in goroutine ")
+		loc := strings.TrimSpace(scanner.Text())
+		if i := strings.LastIndex(loc, " +0x"); i >= 0 {
+			loc = loc[:i]
+		}
+		counts[Site{Function: fn, Location: loc}]++
+	}
+	sites := make([]Site, 0, len(counts))
+	for s, n := range counts {
+		s.Count = n
+		sites = append(sites, s)
+	}
+	slices.SortFunc(sites, func(a, b Site) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Function, b.Function), cmp.Compare(a.Location, b.Location))
+	})
+	return sites
+}
+
+type handlerOptions struct {
+	interval time.Duration
+	growth   int
+	topSites int
+}
+
+// Option is a function that configures a Watcher.
+type Option func(h *handlerOptions)
+
+// WithInterval sets the sampling interval, one minute by default.
+func WithInterval(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.interval = d
+	}
+}
+
+// WithGrowth sets the number of consecutive increases of the goroutine
+// count that trigger a warning, 5 by default. Values below 1 are treated
+// as 1. After a warning, the count must grow as many times again to
+// trigger the next one.
+func WithGrowth(n int) Option {
+	return func(h *handlerOptions) {
+		h.growth = max(n, 1)
+	}
+}
+
+// WithTopSites sets the number of creation sites listed in warnings, 5 by
+// default.
+func WithTopSites(n int) Option {
+	return func(h *handlerOptions) {
+		h.topSites = max(n, 0)
+	}
+}
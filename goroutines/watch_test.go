@@ -0,0 +1,106 @@
+package goroutines
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const stacks = `goroutine 1 [running]:
+main.main()
+	/app/main.go:10 +0x1d
+
+goroutine 7 [chan receive]:
+main.worker()
+	/app/worker.go:5 +0x25
+created by main.start in goroutine 1
+	/app/main.go:20 +0x3f
+
+goroutine 8 [chan receive]:
+main.worker()
+	/app/worker.go:5 +0x25
+created by main.start in goroutine 1
+	/app/main.go:20 +0x3f
+
+goroutine 9 [select]:
+net/http.(*persistConn).readLoop(0xc000)
+	/go/src/net/http/transport.go:2200 +0x1b
+created by net/http.(*Transport).dialConn
+	/go/src/net/http/transport.go:1800 +0x2a
+`
+
+func Test_Sites(t *testing.T) {
+	t.Run("parses creation sites", func(t *testing.T) {
+		sites := Sites([]byte(stacks))
+		want := []Site{
+			{Function: "main.start", Location: "/app/main.go:20", Count: 2},
+			{Function: "net/http.(*Transport).dialConn", Location: "/go/src/net/http/transport.go:1800", Count: 1},
+		}
+		if len(sites) != len(want) || sites[0] != want[0] || sites[1] != want[1] {
+			t.Errorf("unexpected sites: %+v", sites)
+		}
+	})
+
+	t.Run("live goroutines", func(t *testing.T) {
+		stop := make(chan struct{})
+		var wg sync.WaitGroup
+		for range 3 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-stop
+			}()
+		}
+		defer wg.Wait()
+		defer close(stop)
+
+		sites := Sites(allStacks())
+		if len(sites) == 0 || !strings.Contains(sites[0].Function, "Test_Sites") || sites[0].Count < 3 || !strings.Contains(sites[0].Location, "watch_test.go:") {
+			t.Errorf("unexpected sites: %+v", sites)
+		}
+	})
+}
+
+func Test_Watcher(t *testing.T) {
+	t.Run("warns on monotonic growth", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		w := newWatcher(slog.New(slog.NewTextHandler(buf, nil)), []Option{WithGrowth(3), WithTopSites(1)})
+		counts := []int{10, 12, 11, 12, 13, 15, 16, 17}
+		w.count = func() int {
+			n := counts[0]
+			counts = counts[1:]
+			return n
+		}
+		w.stack = func() []byte { return []byte(stacks) }
+
+		for range 5 {
+			w.sample()
+		}
+		if buf.Len() != 0 {
+			t.Fatalf("unexpected warning: %s", buf.String())
+		}
+		w.sample()
+		want := `level=WARN msg="goroutine count growing" goroutines=15 growth=4 sites.0.function=main.start sites.0.location=/app/main.go:20 sites.0.count=2`
+		if !strings.Contains(buf.String(), want) || strings.Contains(buf.String(), "sites.1") {
+			t.Errorf("expected %s, got: %s", want, buf.String())
+		}
+
+		buf.Reset()
+		w.sample()
+		w.sample()
+		if buf.Len() != 0 {
+			t.Errorf("expected streak to restart after warning, got: %s", buf.String())
+		}
+	})
+
+	t.Run("stop", func(t *testing.T) {
+		w := Watch(slog.New(slog.NewTextHandler(io.Discard, nil)), WithInterval(time.Millisecond))
+		time.Sleep(5 * time.Millisecond)
+		w.Stop()
+		w.Stop()
+	})
+}
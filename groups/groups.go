@@ -0,0 +1,238 @@
+package groups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// ErrDuplicate is returned when a group name appears more than once at the
+// same depth of a record and the Error policy is in effect.
+var ErrDuplicate = errors.New("slogging: duplicate group")
+
+// Policy defines how groups sharing a name at the same depth are resolved.
+type Policy int
+
+const (
+	// Merge combines the children of every group sharing a name into the
+	// first of them, recursively.
+	Merge Policy = iota
+	// Rename keeps the groups apart by suffixing later ones with "_2",
+	// "_3" and so on.
+	Rename
+	// Error rejects the record with ErrDuplicate.
+	Error
+	// Keep emits the groups unchanged, duplicates included.
+	Keep
+)
+
+// Resolve returns attrs with the duplicate groups at every depth resolved
+// according to p. Groups with an empty key are inlined first, as
+// slog.Handler implementations do. Attributes that are not groups are
+// never affected, nor are groups clashing with them.
+func Resolve(attrs []slog.Attr, p Policy) ([]slog.Attr, error) {
+	if p == Keep {
+		return attrs, nil
+	}
+	return resolve(attrs, p, "")
+}
+
+func resolve(attrs []slog.Attr, p Policy, path string) ([]slog.Attr, error) {
+	out := make([]slog.Attr, 0, len(attrs))
+	seen := map[string]int{} // Group name to index in out
+	for _, a := range inline(attrs) {
+		v := a.Value.Resolve()
+		if v.Kind() != slog.KindGroup {
+			out = append(out, a)
+			continue
+		}
+		i, dup := seen[a.Key]
+		if !dup {
+			seen[a.Key] = len(out)
+			out = append(out, slog.Attr{Key: a.Key, Value: v})
+			continue
+		}
+		switch p {
+		case Merge:
+			merged := append(append([]slog.Attr(nil), out[i].Value.Group()...), v.Group()...)
+			out[i].Value = slog.GroupValue(merged...)
+		case Rename:
+			key := a.Key
+			for n := 2; dup; n++ {
+				key = a.Key + "_" + strconv.Itoa(n)
+				_, dup = seen[key]
+			}
+			seen[key] = len(out)
+			out = append(out, slog.Attr{Key: key, Value: v})
+		default:
+			return nil, fmt.Errorf("%w %q", ErrDuplicate, path+a.Key)
+		}
+	}
+	for i, a := range out {
+		if a.Value.Kind() != slog.KindGroup {
+			continue
+		}
+		group, err := resolve(a.Value.Group(), p, path+a.Key+".")
+		if err != nil {
+			return nil, err
+		}
+		out[i].Value = slog.GroupValue(group...)
+	}
+	return out, nil
+}
+
+// Flatten returns attrs with the groups at every depth simplified, for
+// cleaner keys than those of nested LogValuers:
+//   - values are resolved, and groups left empty are removed;
+//   - a group holding only a group of the same name, such as "req.req.id"
+//     produced by a LogValuer returning its own group, is collapsed into
+//     one, giving "req.id";
+//   - a group holding a single attribute is replaced by that attribute,
+//     its key prefixed with the group name and a dot, so that JSON output
+//     holds "req.id": 1 instead of "req": {"id": 1}.
+//
+// Groups with an empty key are inlined first.
+func Flatten(attrs []slog.Attr) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range inline(attrs) {
+		a.Value = a.Value.Resolve()
+		if a.Value.Kind() != slog.KindGroup {
+			out = append(out, a)
+			continue
+		}
+		group := a.Value.Group()
+		for len(group) == 1 && group[0].Key == a.Key {
+			v := group[0].Value.Resolve()
+			if v.Kind() != slog.KindGroup {
+				break
+			}
+			group = v.Group()
+		}
+		group = Flatten(group)
+		switch len(group) {
+		case 0:
+		case 1:
+			out = append(out, slog.Attr{Key: a.Key + "." + group[0].Key, Value: group[0].Value})
+		default:
+			out = append(out, slog.Attr{Key: a.Key, Value: slog.GroupValue(group...)})
+		}
+	}
+	return out
+}
+
+func inline(attrs []slog.Attr) []slog.Attr {
+	if !slices.ContainsFunc(attrs, isInline) {
+		return attrs
+	}
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if isInline(a) {
+			out = append(out, inline(a.Value.Group())...)
+			continue
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+func isInline(a slog.Attr) bool {
+	return a.Key == "" && a.Value.Kind() == slog.KindGroup
+}
+
+// Handler is a slog.Handler that resolves duplicate group names in records,
+// such as a user-provided "otel" group next to the one injected by the otel
+// handler. Groups and attributes added via WithAttrs and WithGroup are
+// materialized into every record, so that they take part in the resolution,
+// before the record is passed to the wrapped handler. With WithFlatten,
+// the groups are then simplified with Flatten, whatever the format written
+// by the wrapped handler.
+type Handler struct {
+	handler slog.Handler
+	scope   scope.Scope
+	policy  Policy
+	flatten bool
+}
+
+// Wrap creates a handler resolving duplicate groups according to the
+// configured policy, Merge by default, before delivering to handler.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{policy: Merge}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{handler: handler, policy: config.policy, flatten: config.flatten}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle resolves the duplicate groups of the record, flattens them if
+// configured and delivers it. With the Error policy, records containing
+// duplicates are not delivered and the error is returned.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	attrs, err := Resolve(h.scope.Attrs(r), h.policy)
+	if err != nil {
+		return err
+	}
+	if h.flatten {
+		attrs = Flatten(attrs)
+	}
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(attrs...)
+	return h.handler.Handle(ctx, nr)
+}
+
+// WithAttrs returns a new Handler with the attributes added to its scope.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler with the group opened in its scope.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	policy  Policy
+	flatten bool
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithPolicy sets how duplicate groups are resolved.
+func WithPolicy(p Policy) Option {
+	return func(h *handlerOptions) {
+		h.policy = p
+	}
+}
+
+// WithFlatten enables the simplification of groups with Flatten after
+// duplicates are resolved.
+func WithFlatten(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.flatten = true
+		for i := range x {
+			h.flatten = x[i]
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package groups
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Resolve(t *testing.T) {
+	attrs := []slog.Attr{
+		slog.Group("otel", "trace_id", "abc"),
+		slog.String("msg_id", "1"),
+		slog.Group("otel", "custom", "x", slog.Group("nested", "a", 1)),
+		slog.Group("", slog.Group("otel", slog.Group("nested", "b", 2))),
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		got, err := Resolve(attrs, Merge)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "[otel=[trace_id=abc custom=x nested=[a=1 b=2]] msg_id=1]"
+		if s := slog.GroupValue(got...).String(); s != want {
+			t.Errorf("expected %s, got %s", want, s)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		got, err := Resolve(attrs, Rename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := "[otel=[trace_id=abc] msg_id=1 otel_2=[custom=x nested=[a=1]] otel_3=[nested=[b=2]]]"
+		if s := slog.GroupValue(got...).String(); s != want {
+			t.Errorf("expected %s, got %s", want, s)
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		_, err := Resolve(attrs, Error)
+		if !errors.Is(err, ErrDuplicate) || !strings.Contains(err.Error(), `"otel"`) {
+			t.Errorf("expected ErrDuplicate for otel, got: %v", err)
+		}
+	})
+
+	t.Run("error reports nested path", func(t *testing.T) {
+		_, err := Resolve([]slog.Attr{slog.Group("a", slog.Group("b", "x", 1), slog.Group("b", "y", 2))}, Error)
+		if !errors.Is(err, ErrDuplicate) || !strings.Contains(err.Error(), `"a.b"`) {
+			t.Errorf("expected ErrDuplicate for a.b, got: %v", err)
+		}
+	})
+
+	t.Run("keep", func(t *testing.T) {
+		got, _ := Resolve(attrs, Keep)
+		if len(got) != len(attrs) {
+			t.Errorf("expected attributes unchanged, got: %v", got)
+		}
+	})
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("groups from WithAttrs take part in resolution", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil))).
+			With(slog.Group("req", "id", 1)).
+			WithGroup("svc").With("name", "api")
+
+		logger.Info("test message", "k", "v")
+		logger.With(slog.Group("inner", "a", 1)).Info("again", slog.Group("inner", "b", 2))
+
+		out := buf.String()
+		if !strings.Contains(out, `msg="test message" req.id=1 svc.name=api svc.k=v`) {
+			t.Errorf("unexpected output: %s", out)
+		}
+		if !strings.Contains(out, "svc.inner.a=1 svc.inner.b=2") {
+			t.Errorf("expected merged inner group, got: %s", out)
+		}
+	})
+
+	t.Run("error policy drops the record", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil), WithPolicy(Error)).WithAttrs([]slog.Attr{slog.Group("g", "a", 1)})
+
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "test message", 0)
+		r.AddAttrs(slog.Group("g", "b", 2))
+		if err := h.Handle(context.Background(), r); !errors.Is(err, ErrDuplicate) {
+			t.Errorf("expected ErrDuplicate, got: %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+}
+
+type request struct{ id int }
+
+func (r request) LogValue() slog.Value {
+	return slog.GroupValue(slog.Group("req", "id", r.id))
+}
+
+func Test_Flatten(t *testing.T) {
+	attrs := []slog.Attr{
+		slog.Any("req", request{id: 7}),
+		slog.Group("empty"),
+		slog.Group("outer", slog.Group("inner")),
+		slog.Group("http", "method", "GET", "status", 200),
+		slog.Group("db", slog.Group("pool", "size", 4, "idle", 1)),
+		slog.Group("", "inlined", true),
+	}
+	want := "[req.id=7 http=[method=GET status=200] db.pool=[size=4 idle=1] inlined=true]"
+	if s := slog.GroupValue(Flatten(attrs)...).String(); s != want {
+		t.Errorf("expected %s, got %s", want, s)
+	}
+
+	t.Run("handler", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewJSONHandler(buf, nil), WithFlatten())).WithGroup("svc")
+		logger.Info("test message", "req", request{id: 7})
+		if !strings.Contains(buf.String(), `"svc.req.id":7`) {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,300 @@
+// Package grpclog logs gRPC calls through server and client interceptors.
+package grpclog
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+type contextKey struct{}
+
+// FromContext returns the per-call logger injected by the server
+// interceptors, or slog.Default() if there is none. Log with the *Context
+// methods and the call context, so that a handler chain including
+// otel.Wrap adds the trace context of the call to the records.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// NewContext returns a copy of ctx carrying logger, as returned by FromContext.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// UnaryServerInterceptor returns an interceptor logging every unary call
+// with the full "method", the status "code", the "duration", the "peer"
+// address and the "request_size" and "response_size" of protobuf messages.
+// The record is logged at a level chosen from the status code, see
+// WithCodeLevel. A per-call logger carrying the method is injected into
+// the call context, see FromContext. Records are logged with the call
+// context, so otel.Wrap in the handler chain adds the trace context.
+func UnaryServerInterceptor(logger *slog.Logger, options ...Option) grpc.UnaryServerInterceptor {
+	config := newOptions(options)
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		scoped := logger.With(slog.String("method", info.FullMethod))
+		ctx = NewContext(ctx, scoped)
+
+		resp, err := handler(ctx, req)
+
+		attrs := finishAttrs(ctx, err, start)
+		attrs = appendSize(attrs, "request_size", req)
+		if err == nil {
+			attrs = appendSize(attrs, "response_size", resp)
+		}
+		scoped.LogAttrs(ctx, config.codeLevel(status.Code(err)), "rpc finished", attrs...)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor returns an interceptor logging every streaming
+// call like UnaryServerInterceptor, with the number of messages
+// "received" and "sent" and their total sizes instead of the message sizes.
+func StreamServerInterceptor(logger *slog.Logger, options ...Option) grpc.StreamServerInterceptor {
+	config := newOptions(options)
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		scoped := logger.With(slog.String("method", info.FullMethod))
+		ctx := NewContext(ss.Context(), scoped)
+		ws := &serverStream{ServerStream: ss, ctx: ctx}
+
+		err := handler(srv, ws)
+
+		attrs := append(finishAttrs(ctx, err, start), ws.counts.attrs()...)
+		scoped.LogAttrs(ctx, config.codeLevel(status.Code(err)), "rpc finished", attrs...)
+		return err
+	}
+}
+
+// UnaryClientInterceptor returns an interceptor logging every unary call
+// made by the client, with the same attributes as UnaryServerInterceptor.
+func UnaryClientInterceptor(logger *slog.Logger, options ...Option) grpc.UnaryClientInterceptor {
+	config := newOptions(options)
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		var p peer.Peer
+		err := invoker(ctx, method, req, reply, cc, append(opts, grpc.Peer(&p))...)
+
+		attrs := []slog.Attr{
+			slog.String("method", method),
+			slog.String("code", status.Code(err).String()),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if p.Addr != nil {
+			attrs = append(attrs, slog.String("peer", p.Addr.String()))
+		}
+		attrs = appendSize(attrs, "request_size", req)
+		if err == nil {
+			attrs = appendSize(attrs, "response_size", reply)
+		}
+		logger.LogAttrs(ctx, config.codeLevel(status.Code(err)), "client rpc finished", attrs...)
+		return err
+	}
+}
+
+// StreamClientInterceptor returns an interceptor logging every streaming
+// call made by the client once the stream ends, with the same attributes
+// as StreamServerInterceptor. A stream ends when a receive fails, with
+// io.EOF for a successful call, or when its context is done.
+func StreamClientInterceptor(logger *slog.Logger, options ...Option) grpc.StreamClientInterceptor {
+	config := newOptions(options)
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		cs, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			logger.LogAttrs(ctx, config.codeLevel(status.Code(err)), "client rpc finished",
+				slog.String("method", method),
+				slog.String("code", status.Code(err).String()),
+				slog.Duration("duration", time.Since(start)),
+			)
+			return nil, err
+		}
+		ws := &clientStream{ClientStream: cs, finish: func(err error, c *counts) {
+			attrs := []slog.Attr{
+				slog.String("method", method),
+				slog.String("code", status.Code(err).String()),
+				slog.Duration("duration", time.Since(start)),
+			}
+			attrs = append(attrs, c.attrs()...)
+			logger.LogAttrs(ctx, config.codeLevel(status.Code(err)), "client rpc finished", attrs...)
+		}}
+		stop := context.AfterFunc(ctx, func() {
+			ws.done(status.FromContextError(ctx.Err()).Err())
+		})
+		ws.mutex.Lock()
+		ws.stop = stop
+		ws.mutex.Unlock()
+		return ws, nil
+	}
+}
+
+func finishAttrs(ctx context.Context, err error, start time.Time) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("code", status.Code(err).String()),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		attrs = append(attrs, slog.String("peer", p.Addr.String()))
+	}
+	return attrs
+}
+
+func appendSize(attrs []slog.Attr, key string, msg any) []slog.Attr {
+	if m, ok := msg.(proto.Message); ok {
+		return append(attrs, slog.Int(key, proto.Size(m)))
+	}
+	return attrs
+}
+
+// counts tracks the messages of a stream.
+type counts struct {
+	received, sent         int64
+	receivedSize, sentSize int64
+}
+
+func (c *counts) recv(msg any) {
+	c.received++
+	if m, ok := msg.(proto.Message); ok {
+		c.receivedSize += int64(proto.Size(m))
+	}
+}
+
+func (c *counts) send(msg any) {
+	c.sent++
+	if m, ok := msg.(proto.Message); ok {
+		c.sentSize += int64(proto.Size(m))
+	}
+}
+
+func (c *counts) attrs() []slog.Attr {
+	return []slog.Attr{
+		slog.Int64("received", c.received),
+		slog.Int64("received_size", c.receivedSize),
+		slog.Int64("sent", c.sent),
+		slog.Int64("sent_size", c.sentSize),
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	counts counts
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) RecvMsg(m any) error {
+	err := s.ServerStream.RecvMsg(m)
+	if err == nil {
+		s.counts.recv(m)
+	}
+	return err
+}
+
+func (s *serverStream) SendMsg(m any) error {
+	err := s.ServerStream.SendMsg(m)
+	if err == nil {
+		s.counts.send(m)
+	}
+	return err
+}
+
+type clientStream struct {
+	grpc.ClientStream
+	mutex  sync.Mutex // Guards counts and stop against the context watcher
+	counts counts
+	once   sync.Once
+	stop   func() bool
+	finish func(err error, c *counts)
+}
+
+func (s *clientStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err == nil {
+		s.mutex.Lock()
+		s.counts.recv(m)
+		s.mutex.Unlock()
+		return nil
+	}
+	s.done(err)
+	return err
+}
+
+func (s *clientStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err == nil {
+		s.mutex.Lock()
+		s.counts.send(m)
+		s.mutex.Unlock()
+	}
+	return err
+}
+
+func (s *clientStream) done(err error) {
+	s.once.Do(func() {
+		s.mutex.Lock()
+		c, stop := s.counts, s.stop
+		s.mutex.Unlock()
+		if stop != nil {
+			stop()
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		s.finish(err, &c)
+	})
+}
+
+type handlerOptions struct {
+	codeLevel func(code codes.Code) slog.Level
+}
+
+func newOptions(options []Option) *handlerOptions {
+	config := &handlerOptions{codeLevel: DefaultCodeLevel}
+	for _, opt := range options {
+		if opt != nil {
+			opt(config)
+		}
+	}
+	return config
+}
+
+// DefaultCodeLevel logs successful calls at Info, calls failing with codes
+// caused by the client, such as InvalidArgument or NotFound, at Warn, and
+// other failures at Error.
+func DefaultCodeLevel(code codes.Code) slog.Level {
+	switch code {
+	case codes.OK:
+		return slog.LevelInfo
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition, codes.OutOfRange:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+// Option is a function that configures the interceptors.
+type Option func(h *handlerOptions)
+
+// WithCodeLevel sets the function choosing the level of the record from
+// the status code of the call.
+func WithCodeLevel(fn func(code codes.Code) slog.Level) Option {
+	return func(h *handlerOptions) {
+		h.codeLevel = fn
+	}
+}
@@ -0,0 +1,171 @@
+package grpclog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/mikluko/slogging/otel"
+)
+
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) records(t *testing.T) []map[string]any {
+	t.Helper()
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(b.buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid record %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+type checker struct {
+	*health.Server
+}
+
+func (c *checker) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	FromContext(ctx).InfoContext(ctx, "checking")
+	return c.Server.Check(ctx, req)
+}
+
+func setup(t *testing.T, serverLog, clientLog *slog.Logger) healthpb.HealthClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(serverLog)),
+		grpc.StreamInterceptor(StreamServerInterceptor(serverLog)),
+	)
+	hs := health.NewServer()
+	hs.SetServingStatus("ok", healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, &checker{Server: hs})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(clientLog)),
+		grpc.WithStreamInterceptor(StreamClientInterceptor(clientLog)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
+
+func Test_Unary(t *testing.T) {
+	serverBuf, clientBuf := &syncBuffer{}, &syncBuffer{}
+	client := setup(t,
+		slog.New(slog.NewJSONHandler(serverBuf, nil)),
+		slog.New(otel.Wrap(slog.NewJSONHandler(clientBuf, nil))),
+	)
+
+	ctx, span := sdktrace.NewTracerProvider().Tracer("test-tracer").Start(context.Background(), "test-span")
+	defer span.End()
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("expected NotFound, got %v", err)
+	}
+
+	server := serverBuf.records(t)
+	if len(server) != 4 {
+		t.Fatalf("expected 4 server records, got %v", server)
+	}
+	if server[0]["msg"] != "checking" || server[0]["method"] != "/grpc.health.v1.Health/Check" {
+		t.Errorf("expected per-call logger, got %v", server[0])
+	}
+	fin := server[1]
+	if fin["msg"] != "rpc finished" || fin["code"] != "OK" || fin["level"] != "INFO" || fin["request_size"] != 4.0 ||
+		fin["response_size"] != 2.0 || fin["peer"] == nil || fin["duration"] == nil {
+		t.Errorf("unexpected finish record: %v", fin)
+	}
+	if server[3]["code"] != "NotFound" || server[3]["level"] != "WARN" {
+		t.Errorf("unexpected failure record: %v", server[3])
+	}
+
+	client0 := clientBuf.records(t)
+	if len(client0) != 2 || client0[0]["msg"] != "client rpc finished" || client0[0]["code"] != "OK" || client0[1]["code"] != "NotFound" {
+		t.Fatalf("unexpected client records: %v", client0)
+	}
+	if o, _ := client0[0]["otel"].(map[string]any); o["trace_id"] != span.SpanContext().TraceID().String() {
+		t.Errorf("expected trace context, got %v", client0[0])
+	}
+}
+
+func Test_Stream(t *testing.T) {
+	serverBuf, clientBuf := &syncBuffer{}, &syncBuffer{}
+	client := setup(t, slog.New(slog.NewJSONHandler(serverBuf, nil)), slog.New(slog.NewJSONHandler(clientBuf, nil)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for (len(serverBuf.records(t)) == 0 || len(clientBuf.records(t)) == 0) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	server, client0 := serverBuf.records(t), clientBuf.records(t)
+	if len(server) != 1 || server[0]["method"] != "/grpc.health.v1.Health/Watch" || server[0]["received"] != 1.0 || server[0]["sent"] != 1.0 || server[0]["code"] != "Canceled" {
+		t.Errorf("unexpected server records: %v", server)
+	}
+	if len(client0) != 1 || client0[0]["received"] != 1.0 || client0[0]["sent"] != 1.0 || client0[0]["code"] != "Canceled" {
+		t.Errorf("unexpected client records: %v", client0)
+	}
+}
+
+func Test_DefaultCodeLevel(t *testing.T) {
+	for code, want := range map[codes.Code]slog.Level{
+		codes.OK:               slog.LevelInfo,
+		codes.PermissionDenied: slog.LevelWarn,
+		codes.Internal:         slog.LevelError,
+		codes.Unavailable:      slog.LevelError,
+	} {
+		if got := DefaultCodeLevel(code); got != want {
+			t.Errorf("%s: expected %s, got %s", code, want, got)
+		}
+	}
+}
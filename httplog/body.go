@@ -0,0 +1,110 @@
+// Package httplog logs HTTP traffic.
+package httplog
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"mime"
+	"strings"
+)
+
+const defaultBodyBytes = 1024
+
+// DefaultBodyContentTypes are the media types whose bodies are captured
+// unless configured otherwise. Entries ending with a slash match every
+// subtype, and "application/json" also matches structured syntax suffixes
+// like "application/problem+json".
+var DefaultBodyContentTypes = []string{
+	"application/json",
+	"application/x-www-form-urlencoded",
+	"application/xml",
+	"text/",
+}
+
+// bodyCapture configures which bodies are captured and how much of them.
+type bodyCapture struct {
+	maxBytes     int
+	contentTypes []string
+}
+
+// allowed reports whether bodies of the given Content-Type are captured.
+func (c *bodyCapture) allowed(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range c.contentTypes {
+		switch {
+		case strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t):
+			return true
+		case mediaType == t:
+			return true
+		case t == "application/json" && strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"):
+			return true
+		}
+	}
+	return false
+}
+
+// bodyBuffer retains the first bytes of a body stream, up to a limit, and
+// counts the total, so that large bodies are never buffered entirely.
+type bodyBuffer struct {
+	buf   []byte
+	limit int
+	total int64
+}
+
+func (b *bodyBuffer) write(p []byte) {
+	b.total += int64(len(p))
+	if room := b.limit - len(b.buf); room > 0 {
+		b.buf = append(b.buf, p[:min(room, len(p))]...)
+	}
+}
+
+func (b *bodyBuffer) truncated() bool {
+	return b.total > int64(len(b.buf))
+}
+
+// attr renders the captured body as a group holding the "content", the
+// total "size" in bytes and whether the content was "truncated". Complete
+// bodies holding valid JSON are attached as json.RawMessage, which JSON
+// handlers embed as structured values; other bodies are attached as
+// strings.
+func (b *bodyBuffer) attr(key string) slog.Attr {
+	var content slog.Value
+	if !b.truncated() && json.Valid(b.buf) {
+		content = slog.AnyValue(json.RawMessage(b.buf))
+	} else {
+		content = slog.StringValue(string(b.buf))
+	}
+	return slog.Group(key,
+		slog.Attr{Key: "content", Value: content},
+		slog.Int64("size", b.total),
+		slog.Bool("truncated", b.truncated()),
+	)
+}
+
+// captureReader passes a body through while capturing its first bytes.
+type captureReader struct {
+	io.ReadCloser
+	body bodyBuffer
+}
+
+func newCaptureReader(rc io.ReadCloser, limit int) *captureReader {
+	return &captureReader{ReadCloser: rc, body: bodyBuffer{limit: limit}}
+}
+
+func (r *captureReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.body.write(p[:n])
+	return n, err
+}
+
+// buffer returns the captured bytes, or nil for a nil reader.
+func (r *captureReader) buffer() *bodyBuffer {
+	if r == nil {
+		return nil
+	}
+	return &r.body
+}
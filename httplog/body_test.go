@@ -0,0 +1,61 @@
+package httplog
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_BodyCapture(t *testing.T) {
+	t.Run("content types", func(t *testing.T) {
+		c := &bodyCapture{contentTypes: DefaultBodyContentTypes}
+		for contentType, want := range map[string]bool{
+			"application/json; charset=utf-8": true,
+			"application/problem+json":        true,
+			"text/plain":                      true,
+			"application/octet-stream":        false,
+			"image/png":                       false,
+			"":                                false,
+		} {
+			if got := c.allowed(contentType); got != want {
+				t.Errorf("%q: expected %v, got %v", contentType, want, got)
+			}
+		}
+	})
+
+	t.Run("reader keeps first bytes only", func(t *testing.T) {
+		body := strings.Repeat("x", 10000)
+		r := newCaptureReader(io.NopCloser(strings.NewReader(body)), 16)
+
+		n, err := io.Copy(io.Discard, r)
+		if err != nil || n != 10000 {
+			t.Fatalf("expected body to pass through, got %d, %v", n, err)
+		}
+		if len(r.body.buf) != 16 || cap(r.body.buf) > 64 || r.body.total != 10000 || !r.body.truncated() {
+			t.Errorf("unexpected capture: %d bytes of %d", len(r.body.buf), r.body.total)
+		}
+	})
+
+	t.Run("json bodies are embedded", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		b := &bodyBuffer{limit: 64}
+		b.write([]byte(`{"a": 1}`))
+
+		slog.New(slog.NewJSONHandler(buf, nil)).Info("test message", b.attr("body"))
+
+		if !strings.Contains(buf.String(), `"body":{"content":{"a":1},"size":8,"truncated":false}`) {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("truncated json is a string", func(t *testing.T) {
+		b := &bodyBuffer{limit: 4}
+		b.write([]byte(`{"a": 1}`))
+
+		if got := b.attr("body").Value.String(); got != `[content={"a" size=8 truncated=true]` {
+			t.Errorf("unexpected attribute: %s", got)
+		}
+	})
+}
@@ -0,0 +1,386 @@
+package httplog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// DefaultRequestIDHeader is the header carrying the request ID.
+const DefaultRequestIDHeader = "X-Request-ID"
+
+type contextKey struct{}
+
+// FromContext returns the request-scoped logger injected by Middleware, or
+// slog.Default() if there is none. Log with the *Context methods and the
+// request context, so that a handler chain including otel.Wrap adds the
+// trace context of the request to the records.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return slog.Default()
+}
+
+// NewContext returns a copy of ctx carrying logger, as returned by FromContext.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// Middleware returns HTTP middleware logging every request with logger: a
+// debug record when the request starts and a record when it finishes,
+// holding the method, path, status, duration, bytes written and remote
+// address. The finish record is logged at Error for 5xx statuses, at Warn
+// for 4xx and at Info otherwise, unless configured with WithStatusLevel.
+//
+// The request ID is taken from the request ID header or generated, echoed
+// in the response header, and added as "request_id" to a request-scoped
+// logger injected into the request context, see FromContext. All records
+// are logged with the request context, so otel.Wrap in the handler chain
+// adds the trace context of the request.
+func Middleware(logger *slog.Logger, options ...Option) func(http.Handler) http.Handler {
+	config := newOptions(options)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			id := r.Header.Get(config.requestIDHeader)
+			if id == "" {
+				id = config.requestID()
+			}
+			w.Header().Set(config.requestIDHeader, id)
+
+			scoped := logger.With("request_id", id)
+			if config.extract != nil {
+				for _, a := range config.extract(r) {
+					scoped = scoped.With(a)
+				}
+			}
+			ctx := NewContext(r.Context(), scoped)
+			r = r.WithContext(ctx)
+
+			var reqBody *captureReader
+			if config.body != nil && r.Body != nil && r.Body != http.NoBody && config.body.allowed(r.Header.Get("Content-Type")) {
+				reqBody = newCaptureReader(r.Body, config.body.maxBytes)
+				r.Body = reqBody
+			}
+
+			scoped.DebugContext(ctx, "request started",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("remote_addr", r.RemoteAddr),
+			)
+
+			rw := &responseWriter{ResponseWriter: w, status: http.StatusOK, body: config.body}
+			next.ServeHTTP(rw, r)
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rw.status),
+				slog.Duration("duration", time.Since(start)),
+				slog.Int64("bytes", rw.bytes),
+				slog.String("remote_addr", r.RemoteAddr),
+			}
+			attrs = appendBodies(ctx, scoped, config, attrs, reqBody.buffer(), rw.captured)
+			scoped.LogAttrs(ctx, config.statusLevel(rw.status), "request finished", attrs...)
+		})
+	}
+}
+
+// appendBodies adds the captured bodies to attrs, or logs them as separate
+// debug records if configured so.
+func appendBodies(ctx context.Context, logger *slog.Logger, config *handlerOptions, attrs []slog.Attr, req, resp *bodyBuffer) []slog.Attr {
+	bodies := make([]slog.Attr, 0, 2)
+	if req != nil {
+		bodies = append(bodies, req.attr("request_body"))
+	}
+	if resp != nil {
+		bodies = append(bodies, resp.attr("response_body"))
+	}
+	if !config.bodyRecords {
+		return append(attrs, bodies...)
+	}
+	for _, b := range bodies {
+		logger.LogAttrs(ctx, slog.LevelDebug, "body captured", b)
+	}
+	return attrs
+}
+
+// responseWriter records the status and size of a response and captures
+// the first bytes of its body. It implements Unwrap, so that
+// http.ResponseController reaches the optional interfaces of the wrapped
+// writer.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool
+	body        *bodyCapture
+	captured    *bodyBuffer
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = status
+		if w.body != nil && w.body.allowed(w.Header().Get("Content-Type")) {
+			w.captured = &bodyBuffer{limit: w.body.maxBytes}
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	if w.captured != nil {
+		w.captured.write(p[:n])
+	}
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Transport returns an http.RoundTripper logging every request sent through
+// base, http.DefaultTransport if nil, with the method, URL, status and
+// duration until the response headers arrived. With body capture enabled,
+// the record is logged when the response body is closed, so that the
+// captured response body and the bytes read can be attached; otherwise it
+// is logged when the response arrives. Failed requests are logged at Error
+// with the error.
+func Transport(base http.RoundTripper, logger *slog.Logger, options ...Option) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base, logger: logger, config: newOptions(options)}
+}
+
+type transport struct {
+	base   http.RoundTripper
+	logger *slog.Logger
+	config *handlerOptions
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	start := time.Now()
+
+	var reqBody *captureReader
+	if t.config.body != nil && req.Body != nil && req.Body != http.NoBody && t.config.body.allowed(req.Header.Get("Content-Type")) {
+		req = req.Clone(ctx)
+		reqBody = newCaptureReader(req.Body, t.config.body.maxBytes)
+		req.Body = reqBody
+	}
+	var tm *timings
+	if t.config.traceTimings {
+		tm = &timings{}
+		req = req.WithContext(httptrace.WithClientTrace(ctx, tm.trace()))
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start)
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", req.URL.Redacted()),
+	}
+	if tm != nil && elapsed >= t.config.slowThreshold {
+		attrs = append(attrs, tm.attr(start))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Duration("duration", elapsed), slog.Any("error", err))
+		attrs = appendBodies(ctx, t.logger, t.config, attrs, reqBody.buffer(), nil)
+		t.logger.LogAttrs(ctx, slog.LevelError, "client request failed", attrs...)
+		return resp, err
+	}
+	attrs = append(attrs,
+		slog.Int("status", resp.StatusCode),
+		slog.Duration("duration", elapsed),
+	)
+	level := t.config.statusLevel(resp.StatusCode)
+	if t.config.body == nil {
+		t.logger.LogAttrs(ctx, level, "client request finished", attrs...)
+		return resp, nil
+	}
+	var respBody *captureReader
+	if t.config.body.allowed(resp.Header.Get("Content-Type")) {
+		respBody = newCaptureReader(resp.Body, t.config.body.maxBytes)
+	}
+	resp.Body = &closeHook{ReadCloser: resp.Body, reader: respBody, close: func(read int64) {
+		attrs := append(attrs, slog.Int64("bytes", read))
+		attrs = appendBodies(ctx, t.logger, t.config, attrs, reqBody.buffer(), respBody.buffer())
+		t.logger.LogAttrs(ctx, level, "client request finished", attrs...)
+	}}
+	return resp, nil
+}
+
+// closeHook reads a response body through an optional capture reader and
+// calls close with the number of bytes read once the body is closed.
+type closeHook struct {
+	io.ReadCloser
+	reader *captureReader
+	read   int64
+	closed bool
+	close  func(read int64)
+}
+
+func (c *closeHook) Read(p []byte) (int, error) {
+	var (
+		n   int
+		err error
+	)
+	if c.reader != nil {
+		n, err = c.reader.Read(p)
+	} else {
+		n, err = c.ReadCloser.Read(p)
+	}
+	c.read += int64(n)
+	return n, err
+}
+
+func (c *closeHook) Close() error {
+	err := c.ReadCloser.Close()
+	if !c.closed {
+		c.closed = true
+		c.close(c.read)
+	}
+	return err
+}
+
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+type handlerOptions struct {
+	requestIDHeader string
+	requestID       func() string
+	extract         func(r *http.Request) []slog.Attr
+	statusLevel     func(status int) slog.Level
+	body            *bodyCapture
+	bodyRecords     bool
+	traceTimings    bool
+	slowThreshold   time.Duration
+}
+
+func newOptions(options []Option) *handlerOptions {
+	config := &handlerOptions{
+		requestIDHeader: DefaultRequestIDHeader,
+		requestID:       newRequestID,
+		statusLevel:     defaultStatusLevel,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(config)
+		}
+	}
+	return config
+}
+
+func defaultStatusLevel(status int) slog.Level {
+	switch {
+	case status >= 500:
+		return slog.LevelError
+	case status >= 400:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Option is a function that configures Middleware and Transport.
+type Option func(h *handlerOptions)
+
+// WithRequestIDHeader sets the header carrying the request ID. Middleware only.
+func WithRequestIDHeader(name string) Option {
+	return func(h *handlerOptions) {
+		h.requestIDHeader = name
+	}
+}
+
+// WithRequestIDGenerator sets the function generating request IDs for
+// requests without one. Middleware only.
+func WithRequestIDGenerator(fn func() string) Option {
+	return func(h *handlerOptions) {
+		h.requestID = fn
+	}
+}
+
+// WithRequestAttrs sets a function extracting attributes from the request,
+// e.g. the user agent or a tenant header, added to the request-scoped
+// logger. Middleware only.
+func WithRequestAttrs(fn func(r *http.Request) []slog.Attr) Option {
+	return func(h *handlerOptions) {
+		h.extract = fn
+	}
+}
+
+// WithStatusLevel sets the function choosing the level of the finish
+// record from the response status.
+func WithStatusLevel(fn func(status int) slog.Level) Option {
+	return func(h *handlerOptions) {
+		h.statusLevel = fn
+	}
+}
+
+// WithBodyCapture enables capturing the first maxBytes bytes, 1024 if not
+// positive, of request and response bodies whose Content-Type is in
+// contentTypes, DefaultBodyContentTypes if none are given. Bodies are
+// captured as they are read or written, never buffered beyond maxBytes,
+// and attached to the finish record as "request_body" and "response_body".
+func WithBodyCapture(maxBytes int, contentTypes ...string) Option {
+	return func(h *handlerOptions) {
+		if maxBytes <= 0 {
+			maxBytes = defaultBodyBytes
+		}
+		if len(contentTypes) == 0 {
+			contentTypes = DefaultBodyContentTypes
+		}
+		h.body = &bodyCapture{maxBytes: maxBytes, contentTypes: contentTypes}
+	}
+}
+
+// WithBodyRecords sets whether captured bodies are logged as separate debug
+// records instead of attributes of the finish record.
+func WithBodyRecords(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.bodyRecords = true
+		for i := range x {
+			h.bodyRecords = x[i]
+		}
+	}
+}
+
+// WithTraceTimings enables recording the DNS lookup, connection, TLS
+// handshake and time to first byte of client requests with httptrace. The
+// timings are attached as a "timings" group to the records of requests
+// taking threshold or longer until the response headers arrived, so that
+// only slow requests carry them. Transport only.
+func WithTraceTimings(threshold time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.traceTimings = true
+		h.slowThreshold = threshold
+	}
+}
@@ -0,0 +1,263 @@
+package httplog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/mikluko/slogging/otel"
+)
+
+func decode(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid record %q: %v", line, err)
+		}
+		records = append(records, rec)
+	}
+	return records
+}
+
+func Test_Middleware(t *testing.T) {
+	t.Run("logs start and finish with scoped logger", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		mw := Middleware(logger,
+			WithRequestIDGenerator(func() string { return "req-1" }),
+			WithRequestAttrs(func(r *http.Request) []slog.Attr {
+				return []slog.Attr{slog.String("tenant", r.Header.Get("X-Tenant"))}
+			}),
+		)
+		h := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			FromContext(r.Context()).InfoContext(r.Context(), "inside")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("not here"))
+		}))
+
+		req := httptest.NewRequest(http.MethodGet, "/items/1", nil)
+		req.Header.Set("X-Tenant", "acme")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Header().Get(DefaultRequestIDHeader) != "req-1" {
+			t.Errorf("expected request ID header, got %q", rec.Header().Get(DefaultRequestIDHeader))
+		}
+		records := decode(t, buf)
+		if len(records) != 3 {
+			t.Fatalf("expected 3 records, got: %s", buf.String())
+		}
+		if records[0]["msg"] != "request started" || records[0]["level"] != "DEBUG" {
+			t.Errorf("unexpected start record: %v", records[0])
+		}
+		if records[1]["msg"] != "inside" || records[1]["request_id"] != "req-1" || records[1]["tenant"] != "acme" {
+			t.Errorf("unexpected scoped record: %v", records[1])
+		}
+		fin := records[2]
+		if fin["msg"] != "request finished" || fin["level"] != "WARN" || fin["status"] != 404.0 || fin["bytes"] != 8.0 ||
+			fin["method"] != "GET" || fin["path"] != "/items/1" || fin["remote_addr"] != "192.0.2.1:1234" || fin["duration"] == nil {
+			t.Errorf("unexpected finish record: %v", fin)
+		}
+	})
+
+	t.Run("incoming request ID is kept", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Middleware(slog.New(slog.NewJSONHandler(buf, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(DefaultRequestIDHeader, "abc")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if records := decode(t, buf); records[0]["request_id"] != "abc" || records[0]["status"] != 200.0 {
+			t.Errorf("unexpected record: %v", records[0])
+		}
+	})
+
+	t.Run("composes with otel handler", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(otel.Wrap(slog.NewJSONHandler(buf, nil)))
+		h := Middleware(logger)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		ctx, span := sdktrace.NewTracerProvider().Tracer("test-tracer").Start(context.Background(), "test-span")
+		defer span.End()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+
+		records := decode(t, buf)
+		o, _ := records[0]["otel"].(map[string]any)
+		if o["trace_id"] != span.SpanContext().TraceID().String() {
+			t.Errorf("expected trace context, got: %v", records[0])
+		}
+	})
+
+	t.Run("captures bodies", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Middleware(slog.New(slog.NewJSONHandler(buf, nil)), WithBodyCapture(8))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.Copy(io.Discard, r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"ok":1}`))
+		}))
+
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello world"))
+		req.Header.Set("Content-Type", "text/plain")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		fin := decode(t, buf)[0]
+		reqBody, _ := fin["request_body"].(map[string]any)
+		if reqBody["content"] != "hello wo" || reqBody["size"] != 11.0 || reqBody["truncated"] != true {
+			t.Errorf("unexpected request body: %v", fin)
+		}
+		respBody, _ := fin["response_body"].(map[string]any)
+		if content, _ := respBody["content"].(map[string]any); content["ok"] != 1.0 {
+			t.Errorf("unexpected response body: %v", fin)
+		}
+	})
+
+	t.Run("captures bodies as separate records", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		h := Middleware(logger, WithBodyCapture(0), WithBodyRecords())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte("plain"))
+		}))
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		records := decode(t, buf)
+		if len(records) != 3 || records[1]["msg"] != "body captured" || records[2]["response_body"] != nil {
+			t.Errorf("unexpected records: %s", buf.String())
+		}
+	})
+
+	t.Run("binary bodies are not captured", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Middleware(slog.New(slog.NewJSONHandler(buf, nil)), WithBodyCapture(0))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte{0, 1, 2})
+		}))
+
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+		if strings.Contains(buf.String(), "response_body") {
+			t.Errorf("unexpected body: %s", buf.String())
+		}
+	})
+
+	t.Run("response controller reaches the wrapped writer", func(t *testing.T) {
+		h := Middleware(slog.New(slog.NewJSONHandler(io.Discard, nil)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				t.Errorf("flush failed: %v", err)
+			}
+		}))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if !rec.Flushed {
+			t.Errorf("expected recorder to be flushed")
+		}
+	})
+}
+
+func Test_Transport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		_, _ = w.Write([]byte("pong"))
+	}))
+	defer srv.Close()
+
+	t.Run("logs on response", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		client := &http.Client{Transport: Transport(nil, slog.New(slog.NewJSONHandler(buf, nil)))}
+
+		resp, err := client.Get(srv.URL + "/fail")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+
+		rec := decode(t, buf)[0]
+		if rec["msg"] != "client request finished" || rec["level"] != "ERROR" || rec["status"] != 502.0 || rec["url"] != srv.URL+"/fail" {
+			t.Errorf("unexpected record: %v", rec)
+		}
+	})
+
+	t.Run("logs on body close with capture", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		client := &http.Client{Transport: Transport(nil, slog.New(slog.NewJSONHandler(buf, nil)), WithBodyCapture(0))}
+
+		resp, err := client.Post(srv.URL, "application/json", strings.NewReader(`{"ping":true}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("unexpected record before close: %s", buf.String())
+		}
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		_ = resp.Body.Close()
+
+		records := decode(t, buf)
+		if string(body) != "pong" || len(records) != 1 {
+			t.Fatalf("unexpected records: %s", buf.String())
+		}
+		rec := records[0]
+		reqBody, _ := rec["request_body"].(map[string]any)
+		respBody, _ := rec["response_body"].(map[string]any)
+		if reqBody["content"].(map[string]any)["ping"] != true || respBody["content"] != "pong" || rec["bytes"] != 4.0 {
+			t.Errorf("unexpected record: %v", rec)
+		}
+	})
+
+	t.Run("timings of slow requests", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(slog.NewJSONHandler(buf, nil))
+		client := &http.Client{Transport: Transport(&http.Transport{}, logger, WithTraceTimings(0))}
+		fast := &http.Client{Transport: Transport(nil, logger, WithTraceTimings(time.Hour))}
+
+		for _, c := range []*http.Client{client, client, fast} {
+			resp, err := c.Get(srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		records := decode(t, buf)
+		first, _ := records[0]["timings"].(map[string]any)
+		if first["connect"] == nil || first["ttfb"] == nil || first["reused"] != false || first["tls"] != nil {
+			t.Errorf("unexpected timings: %v", records[0])
+		}
+		second, _ := records[1]["timings"].(map[string]any)
+		if second["connect"] != nil || second["reused"] != true {
+			t.Errorf("unexpected timings of reused connection: %v", records[1])
+		}
+		if records[2]["timings"] != nil {
+			t.Errorf("unexpected timings of fast request: %v", records[2])
+		}
+	})
+
+	t.Run("failed request", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		client := &http.Client{Transport: Transport(nil, slog.New(slog.NewJSONHandler(buf, nil)))}
+
+		_, err := client.Get("http://127.0.0.1:1/")
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		if rec := decode(t, buf)[0]; rec["msg"] != "client request failed" || rec["error"] == nil {
+			t.Errorf("unexpected record: %v", rec)
+		}
+	})
+}
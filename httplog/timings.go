@@ -0,0 +1,72 @@
+package httplog
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// timings collects the phases of a client request from httptrace hooks,
+// which may be called from several goroutines.
+type timings struct {
+	mutex        sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+func (t *timings) trace() *httptrace.ClientTrace {
+	record := func(dst *time.Time) {
+		t.mutex.Lock()
+		if dst.IsZero() {
+			*dst = time.Now()
+		}
+		t.mutex.Unlock()
+	}
+	return &httptrace.ClientTrace{
+		DNSStart:     func(httptrace.DNSStartInfo) { record(&t.dnsStart) },
+		DNSDone:      func(httptrace.DNSDoneInfo) { record(&t.dnsDone) },
+		ConnectStart: func(string, string) { record(&t.connectStart) },
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				record(&t.connectDone)
+			}
+		},
+		TLSHandshakeStart: func() { record(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { record(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mutex.Lock()
+			t.reused = info.Reused
+			t.mutex.Unlock()
+		},
+		GotFirstResponseByte: func() { record(&t.firstByte) },
+	}
+}
+
+// attr renders the collected phases as a "timings" group holding the "dns",
+// "connect", "tls" and "ttfb" durations, the latter measured from start,
+// and whether the connection was "reused". Phases that did not happen,
+// e.g. on reused connections, are omitted.
+func (t *timings) attr(start time.Time) slog.Attr {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	attrs := make([]slog.Attr, 0, 5)
+	phase := func(key string, from, to time.Time) {
+		if !from.IsZero() && !to.IsZero() {
+			attrs = append(attrs, slog.Duration(key, to.Sub(from)))
+		}
+	}
+	phase("dns", t.dnsStart, t.dnsDone)
+	phase("connect", t.connectStart, t.connectDone)
+	phase("tls", t.tlsStart, t.tlsDone)
+	phase("ttfb", start, t.firstByte)
+	attrs = append(attrs, slog.Bool("reused", t.reused))
+	return slog.Attr{Key: "timings", Value: slog.GroupValue(attrs...)}
+}
@@ -0,0 +1,140 @@
+package slogging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+
+	gootel "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging/otel"
+)
+
+// Install sets up the default logger for production use in one call:
+//
+//   - the handler, by default the handler of the current default logger or
+//     a JSON handler writing to stderr if none was set, is wrapped with
+//     otel.Wrap when a global TracerProvider has been registered;
+//   - the result becomes the default logger, which also redirects the
+//     output of the standard log package to it, and the handler of the
+//     package-level functions, see SetHandler;
+//   - the returned function flushes the TracerProvider and the handler,
+//     for handlers with a Flush or Close method such as async.Handler, and
+//     is meant to be deferred in main.
+//
+// Install is meant for small services; larger ones should build their
+// handler chain explicitly.
+func Install(options ...InstallOption) (shutdown func(context.Context) error) {
+	config := installOptions{logLevel: slog.LevelInfo}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+
+	handler := config.handler
+	if handler == nil {
+		handler = slog.Default().Handler()
+		if isBuiltinHandler(handler) {
+			// Wrapping the builtin handler would loop: it writes through the
+			// standard log package, which SetDefault redirects to the handler.
+			handler = slog.NewJSONHandler(os.Stderr, nil)
+		}
+	}
+
+	tp := gootel.GetTracerProvider()
+	wrapOtel := isActiveTracerProvider(tp)
+	if config.wrapOtel != nil {
+		wrapOtel = *config.wrapOtel
+	}
+	if wrapOtel {
+		handler = otel.Wrap(handler, config.otelOptions...)
+	}
+
+	SetHandler(handler)
+	slog.SetDefault(slog.New(handler))
+	slog.SetLogLoggerLevel(config.logLevel)
+
+	base := config.handler
+	return func(ctx context.Context) error {
+		var errs []error
+		if f, ok := tp.(interface{ ForceFlush(context.Context) error }); ok && isActiveTracerProvider(tp) {
+			if err := f.ForceFlush(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("error when flushing tracer provider: %w", err))
+			}
+		}
+		if base != nil {
+			if err := closeOne(ctx, base); err != nil {
+				errs = append(errs, fmt.Errorf("error when flushing handler: %w", err))
+			}
+		}
+		for _, c := range config.closers {
+			if err := closeOne(ctx, c); err != nil {
+				errs = append(errs, fmt.Errorf("error when closing %T: %w", c, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+}
+
+// isBuiltinHandler reports whether h is the handler slog uses until a
+// default logger is set.
+func isBuiltinHandler(h slog.Handler) bool {
+	t := reflect.TypeOf(h)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.PkgPath() == "log/slog" && t.Name() == "defaultHandler"
+}
+
+// isActiveTracerProvider reports whether tp was registered with
+// otel.SetTracerProvider, as opposed to the delegating placeholder the
+// global registry returns until then.
+func isActiveTracerProvider(tp trace.TracerProvider) bool {
+	if tp == nil {
+		return false
+	}
+	t := reflect.TypeOf(tp)
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t.PkgPath() != "go.opentelemetry.io/otel/internal/global"
+}
+
+type installOptions struct {
+	handler     slog.Handler
+	otelOptions []otel.Option
+	logLevel    slog.Level
+	wrapOtel    *bool // Whether to wrap with otel.Wrap, if not decided by the tracer provider
+	closers     []any // Closed by the shutdown function after the handler
+}
+
+// InstallOption is a function that configures Install.
+type InstallOption func(o *installOptions)
+
+// WithHandler sets the handler to install. It is closed or flushed by the
+// shutdown function returned by Install if it has a Close or Flush method.
+func WithHandler(h slog.Handler) InstallOption {
+	return func(o *installOptions) {
+		o.handler = h
+	}
+}
+
+// WithOtelOptions sets the options passed to otel.Wrap.
+func WithOtelOptions(options ...otel.Option) InstallOption {
+	return func(o *installOptions) {
+		o.otelOptions = append(o.otelOptions, options...)
+	}
+}
+
+// WithLogLevel sets the level of records produced by the standard log
+// package. It is Info by default.
+func WithLogLevel(lvl slog.Level) InstallOption {
+	return func(o *installOptions) {
+		o.logLevel = lvl
+	}
+}
@@ -0,0 +1,81 @@
+package slogging
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+
+	gootel "go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/mikluko/slogging/otel"
+)
+
+type flushingHandler struct {
+	slog.Handler
+	flushed bool
+}
+
+func (h *flushingHandler) Flush(context.Context) error {
+	h.flushed = true
+	return nil
+}
+
+func Test_Install(t *testing.T) {
+	defaultLogger := slog.Default()
+	defer slog.SetDefault(defaultLogger)
+	defer slog.SetLogLoggerLevel(slog.LevelInfo)
+
+	t.Run("without tracer provider", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := &flushingHandler{Handler: slog.NewTextHandler(buf, nil)}
+
+		shutdown := Install(WithHandler(h), WithLogLevel(slog.LevelWarn))
+
+		if slog.Default().Handler() != h {
+			t.Errorf("expected handler to be installed unwrapped, got %T", slog.Default().Handler())
+		}
+		log.Print("from stdlib")
+		if !strings.Contains(buf.String(), `level=WARN msg="from stdlib"`) {
+			t.Errorf("expected stdlib log to be redirected, got: %s", buf.String())
+		}
+		if err := shutdown(context.Background()); err != nil || !h.flushed {
+			t.Errorf("expected handler to be flushed, got: %v", err)
+		}
+	})
+
+	t.Run("with tracer provider", func(t *testing.T) {
+		defaultTP := gootel.GetTracerProvider()
+		defer gootel.SetTracerProvider(defaultTP)
+		tp := sdktrace.NewTracerProvider()
+		gootel.SetTracerProvider(tp)
+
+		buf := new(bytes.Buffer)
+		shutdown := Install(WithHandler(slog.NewTextHandler(buf, nil)), WithOtelOptions(otel.WithSampled()))
+
+		ctx, span := tp.Tracer("test-tracer").Start(context.Background(), "test-span")
+		slog.InfoContext(ctx, "test message")
+		span.End()
+
+		if !strings.Contains(buf.String(), "otel.trace_id="+span.SpanContext().TraceID().String()) || !strings.Contains(buf.String(), "otel.sampled=true") {
+			t.Errorf("expected trace context, got: %s", buf.String())
+		}
+		if err := shutdown(context.Background()); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("builtin default handler is replaced", func(t *testing.T) {
+		if !isBuiltinHandler(defaultLogger.Handler()) {
+			t.Skip("default logger was changed")
+		}
+		slog.SetDefault(defaultLogger)
+		Install()
+		if _, ok := slog.Default().Handler().(*slog.JSONHandler); !ok {
+			t.Errorf("expected JSON handler, got %T", slog.Default().Handler())
+		}
+	})
+}
@@ -0,0 +1,66 @@
+// Package fingerprint computes the fingerprints grouping similar records
+// in the ratelimit, counting and suppress packages.
+package fingerprint
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Message returns msg with every digit sequence replaced by a single '#',
+// so "retry 1 of 5" and "retry 2 of 5" share a fingerprint.
+func Message(msg string) string {
+	var b strings.Builder
+	writeMessage(&b, msg)
+	return b.String()
+}
+
+func writeMessage(b *strings.Builder, msg string) {
+	b.Grow(len(msg))
+	digits := false
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= '0' && c <= '9' {
+			if !digits {
+				b.WriteByte('#')
+			}
+			digits = true
+			continue
+		}
+		digits = false
+		b.WriteByte(c)
+	}
+}
+
+// Fields returns the message of r, passed through Message when similar is
+// set, followed by the values of the given top-level attribute keys, as in
+// "payment failed provider=stripe". Keys follow the order of keys; of
+// several attributes with the same key, the last one wins.
+func Fields(r slog.Record, similar bool, keys ...string) string {
+	var b strings.Builder
+	if similar {
+		writeMessage(&b, r.Message)
+	} else {
+		b.WriteString(r.Message)
+	}
+	if len(keys) == 0 {
+		return b.String()
+	}
+	values := make(map[string]string, len(keys))
+	r.Attrs(func(a slog.Attr) bool {
+		if slices.Contains(keys, a.Key) {
+			values[a.Key] = a.Value.Resolve().String()
+		}
+		return true
+	})
+	for _, k := range keys {
+		if v, ok := values[k]; ok {
+			b.WriteByte(' ')
+			b.WriteString(k)
+			b.WriteByte('=')
+			b.WriteString(v)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,33 @@
+package fingerprint
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func Test_Message(t *testing.T) {
+	cases := map[string]string{
+		"retry 1 of 5":         "retry # of #",
+		"timeout after 1500ms": "timeout after #ms",
+		"no digits":            "no digits",
+		"":                     "",
+	}
+	for msg, want := range cases {
+		if got := Message(msg); got != want {
+			t.Errorf("Message(%q) = %q, want %q", msg, got, want)
+		}
+	}
+}
+
+func Test_Fields(t *testing.T) {
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "retry 2 of 5", 0)
+	r.AddAttrs(slog.String("provider", "stripe"), slog.Int("attempt", 2), slog.String("provider", "adyen"))
+
+	if got := Fields(r, true, "provider", "missing"); got != "retry # of # provider=adyen" {
+		t.Errorf("unexpected fingerprint: %q", got)
+	}
+	if got := Fields(r, false); got != "retry 2 of 5" {
+		t.Errorf("unexpected fingerprint: %q", got)
+	}
+}
@@ -0,0 +1,45 @@
+// Package jsonvalue prepares attribute values for encoding/json the way
+// slog.JSONHandler renders them, for handlers marshaling the maps filled
+// by slogging.PutAttrs.
+package jsonvalue
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Convert returns v in the form it should be marshaled in: values
+// implementing json.Marshaler are kept as is, errors are replaced with
+// their message and encoding.TextMarshaler and fmt.Stringer values with
+// their text, except time.Duration values, kept as nanoseconds. Nested map[string]any values, such as the groups added by
+// slogging.PutAttrs, are converted in place by Map. Other values are kept
+// as is.
+func Convert(v any) any {
+	if _, ok := v.(json.Marshaler); ok {
+		return v
+	}
+	switch x := v.(type) {
+	case map[string]any:
+		Map(x)
+	case time.Duration:
+		return v
+	case error:
+		return x.Error()
+	case encoding.TextMarshaler:
+		if b, err := x.MarshalText(); err == nil {
+			return string(b)
+		}
+	case fmt.Stringer:
+		return x.String()
+	}
+	return v
+}
+
+// Map applies Convert to the values of m in place.
+func Map(m map[string]any) {
+	for k, v := range m {
+		m[k] = Convert(v)
+	}
+}
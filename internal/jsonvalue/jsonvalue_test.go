@@ -0,0 +1,37 @@
+package jsonvalue
+
+import (
+	"encoding/json"
+	"errors"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+type stringer struct{ name string }
+
+func (s stringer) String() string { return "stringer " + s.name }
+
+func Test_Map(t *testing.T) {
+	m := map[string]any{
+		"err":  errors.New("boom"),
+		"addr": netip.MustParseAddr("10.0.0.1"),
+		"name": stringer{"x"},
+		"time": time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		"n":    1,
+		"took": time.Millisecond,
+		"group": map[string]any{
+			"err": errors.New("nested"),
+		},
+	}
+	Map(m)
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"addr":"10.0.0.1","err":"boom","group":{"err":"nested"},"n":1,"name":"stringer x","time":"2024-01-02T03:04:05Z","took":1000000}`
+	if string(b) != want {
+		t.Errorf("expected %s, got %s", want, b)
+	}
+}
@@ -0,0 +1,98 @@
+package scope
+
+import (
+	"log/slog"
+)
+
+// groupOrAttrs holds either a group name or a list of attributes added
+// via WithGroup and WithAttrs respectively.
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+// Scope accumulates the attributes and groups of a handler's WithAttrs and
+// WithGroup calls so that handlers formatting records themselves can
+// materialize the complete attribute tree of a record. The zero value is
+// an empty scope. Scope values are immutable.
+type Scope struct {
+	goas []groupOrAttrs
+}
+
+// WithAttrs returns a scope with attrs added to the innermost group.
+func (s Scope) WithAttrs(attrs []slog.Attr) Scope {
+	if len(attrs) == 0 {
+		return s
+	}
+	return s.with(groupOrAttrs{attrs: attrs})
+}
+
+// WithGroup returns a scope with the group opened.
+func (s Scope) WithGroup(name string) Scope {
+	if name == "" {
+		return s
+	}
+	return s.with(groupOrAttrs{group: name})
+}
+
+func (s Scope) with(goa groupOrAttrs) Scope {
+	goas := make([]groupOrAttrs, len(s.goas)+1)
+	copy(goas, s.goas)
+	goas[len(s.goas)] = goa
+	return Scope{goas: goas}
+}
+
+// Groups returns the names of the open groups, outermost first.
+func (s Scope) Groups() []string {
+	var groups []string
+	for _, goa := range s.goas {
+		if goa.group != "" {
+			groups = append(groups, goa.group)
+		}
+	}
+	return groups
+}
+
+// Empty reports whether no attributes or groups were added.
+func (s Scope) Empty() bool {
+	return len(s.goas) == 0
+}
+
+// Attrs returns the attributes of r nested in the open groups, preceded at
+// every depth by the attributes added via WithAttrs. Groups that would end
+// up empty are omitted, following slog.Handler conventions.
+func (s Scope) Attrs(r slog.Record) []slog.Attr {
+	recordAttrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		recordAttrs = append(recordAttrs, a)
+		return true
+	})
+	return s.Nest(recordAttrs)
+}
+
+// Nest returns attrs nested in the open groups, preceded at every depth by
+// the attributes added via WithAttrs.
+func (s Scope) Nest(attrs []slog.Attr) []slog.Attr {
+	goas := s.goas
+	if len(attrs) == 0 {
+		for len(goas) > 0 && goas[len(goas)-1].group != "" {
+			goas = goas[:len(goas)-1]
+		}
+	}
+	return nest(goas, attrs)
+}
+
+func nest(goas []groupOrAttrs, attrs []slog.Attr) []slog.Attr {
+	var out []slog.Attr
+	for i, goa := range goas {
+		if goa.group != "" {
+			inner := nest(goas[i+1:], attrs)
+			if len(inner) == 0 {
+				return out
+			}
+			return append(out, slog.Attr{Key: goa.group, Value: slog.GroupValue(inner...)})
+		}
+		out = append(out, goa.attrs...)
+	}
+	return append(out, attrs...)
+}
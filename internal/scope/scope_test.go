@@ -0,0 +1,59 @@
+package scope
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func Test_Scope(t *testing.T) {
+	t.Run("empty scope returns record attrs", func(t *testing.T) {
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0)
+		r.AddAttrs(slog.String("a", "1"))
+		attrs := Scope{}.Attrs(r)
+		if len(attrs) != 1 || attrs[0].Key != "a" {
+			t.Errorf("unexpected attrs: %v", attrs)
+		}
+	})
+
+	t.Run("attrs are nested in groups", func(t *testing.T) {
+		s := Scope{}.
+			WithAttrs([]slog.Attr{slog.String("svc", "api")}).
+			WithGroup("req").
+			WithAttrs([]slog.Attr{slog.Int("id", 1)})
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0)
+		r.AddAttrs(slog.String("path", "/"))
+
+		got := slog.GroupValue(s.Attrs(r)...).String()
+		want := slog.GroupValue(
+			slog.String("svc", "api"),
+			slog.Group("req", slog.Int("id", 1), slog.String("path", "/")),
+		).String()
+		if got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+		if groups := s.Groups(); len(groups) != 1 || groups[0] != "req" {
+			t.Errorf("unexpected groups: %v", groups)
+		}
+	})
+
+	t.Run("empty trailing groups are omitted", func(t *testing.T) {
+		s := Scope{}.WithAttrs([]slog.Attr{slog.String("svc", "api")}).WithGroup("a").WithGroup("b")
+		attrs := s.Nest(nil)
+		if len(attrs) != 1 || attrs[0].Key != "svc" {
+			t.Errorf("unexpected attrs: %v", attrs)
+		}
+	})
+
+	t.Run("scopes are immutable", func(t *testing.T) {
+		base := Scope{}.WithGroup("g")
+		a := base.WithAttrs([]slog.Attr{slog.String("a", "1")})
+		b := base.WithAttrs([]slog.Attr{slog.String("b", "2")})
+		if got := slog.GroupValue(a.Nest(nil)...).String(); got != "[g=[a=1]]" {
+			t.Errorf("unexpected a: %s", got)
+		}
+		if got := slog.GroupValue(b.Nest(nil)...).String(); got != "[g=[b=2]]" {
+			t.Errorf("unexpected b: %s", got)
+		}
+	})
+}
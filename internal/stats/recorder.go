@@ -0,0 +1,54 @@
+// Package stats records the counters reported by the Stats methods of
+// handlers.
+package stats
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mikluko/slogging"
+)
+
+// Recorder counts the records passed to a handler and its failed
+// deliveries. The zero value is ready to use.
+type Recorder struct {
+	handled atomic.Uint64
+	errors  atomic.Uint64
+
+	mutex         sync.Mutex
+	lastError     error
+	lastErrorTime time.Time
+}
+
+// Handled counts n records passed to the handler.
+func (r *Recorder) Handled(n int) {
+	r.handled.Add(uint64(n))
+}
+
+// Error counts a failed delivery, unless err is nil.
+func (r *Recorder) Error(err error) {
+	if err == nil {
+		return
+	}
+	r.errors.Add(1)
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastError = err
+	r.lastErrorTime = time.Now()
+}
+
+// Stats returns the counters together with the dropped records and the
+// queue depth tracked by the handler.
+func (r *Recorder) Stats(dropped uint64, depth int) slogging.Stats {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return slogging.Stats{
+		Handled:       r.handled.Load(),
+		Dropped:       dropped,
+		Errors:        r.errors.Load(),
+		QueueDepth:    depth,
+		LastError:     r.lastError,
+		LastErrorTime: r.lastErrorTime,
+	}
+}
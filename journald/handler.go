@@ -0,0 +1,314 @@
+// Package journald provides a slog.Handler writing to the systemd journal
+// with its native protocol, so that attributes become journal fields
+// usable with journalctl filters.
+package journald
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/severity"
+)
+
+// DefaultSocket is the path of the native protocol socket of journald.
+const DefaultSocket = "/run/systemd/journal/socket"
+
+const maxFieldName = 64
+
+// Handler is a slog.Handler that sends every record to journald as a
+// datagram of the native protocol: the message as MESSAGE, the level
+// mapped to a syslog severity as PRIORITY, SYSLOG_IDENTIFIER, the source
+// location as CODE_FILE, CODE_LINE and CODE_FUNC when enabled, and each
+// attribute as a field. Field names are the attribute keys, with group
+// keys joined with "_", uppercased and stripped of characters journald
+// does not accept. Entries too large for a datagram are passed through a
+// sealed memfd, as journald expects.
+type Handler struct {
+	scope scope.Scope
+
+	// Shared state across WithAttrs/WithGroup instances.
+	transport transport
+
+	level      slog.Leveler
+	identifier string
+	source     bool
+	table      *severity.Table
+}
+
+// NewHandler creates a new Handler with the given options. The socket is
+// connected on first use, so errors are returned by Handle.
+func NewHandler(options ...Option) *Handler {
+	config := handlerOptions{
+		socket:     DefaultSocket,
+		level:      slog.LevelInfo,
+		identifier: filepath.Base(os.Args[0]),
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	var t transport = &socketTransport{path: config.socket}
+	if config.writer != nil {
+		t = &writerTransport{writer: config.writer}
+	}
+	return &Handler{
+		transport:  t,
+		level:      config.level,
+		identifier: config.identifier,
+		source:     config.source,
+		table:      config.table,
+	}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	table := h.table
+	if table == nil {
+		table = severity.Default()
+	}
+	buf := new(bytes.Buffer)
+	appendField(buf, "MESSAGE", r.Message)
+	appendField(buf, "PRIORITY", strconv.Itoa(table.Lookup(severity.Syslog, r.Level).Code))
+	if h.identifier != "" {
+		appendField(buf, "SYSLOG_IDENTIFIER", h.identifier)
+	}
+	if h.source && r.PC != 0 {
+		frames := runtime.CallersFrames([]uintptr{r.PC})
+		f, _ := frames.Next()
+		appendField(buf, "CODE_FILE", f.File)
+		appendField(buf, "CODE_LINE", strconv.Itoa(f.Line))
+		appendField(buf, "CODE_FUNC", f.Function)
+	}
+
+	for _, a := range h.scope.Attrs(r) {
+		appendAttr(buf, "", a)
+	}
+	return h.transport.send(buf.Bytes())
+}
+
+// Close closes the journal socket, or the writer set with WithWriter if it
+// is an io.Closer.
+func (h *Handler) Close() error {
+	return h.transport.close()
+}
+
+// appendAttr flattens a into fields. Empty attributes are dropped, groups
+// with an empty key are inlined.
+func appendAttr(buf *bytes.Buffer, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			prefix += a.Key + "_"
+		}
+		for _, ga := range a.Value.Group() {
+			appendAttr(buf, prefix, ga)
+		}
+		return
+	}
+	if name := fieldName(prefix + a.Key); name != "" {
+		appendField(buf, name, a.Value.String())
+	}
+}
+
+// fieldName converts key to a journal field name: uppercase letters,
+// digits and underscores, not starting with an underscore, which is
+// reserved for trusted fields, or a digit, and at most 64 bytes long.
+func fieldName(key string) string {
+	b := []byte(strings.ToUpper(key))
+	for i, c := range b {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			b[i] = '_'
+		}
+	}
+	name := strings.TrimLeft(string(b), "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "X" + name
+	}
+	if len(name) > maxFieldName {
+		name = name[:maxFieldName]
+	}
+	return name
+}
+
+// appendField serializes a field. Values containing newlines are written
+// with their length as a little-endian 64-bit integer.
+func appendField(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+type transport interface {
+	send(entry []byte) error
+	close() error
+}
+
+// writerTransport writes serialized entries, separated by an empty line.
+type writerTransport struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func (t *writerTransport) send(entry []byte) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	_, err := t.writer.Write(append(entry, '\n'))
+	return err
+}
+
+func (t *writerTransport) close() error {
+	if c, ok := t.writer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// socketTransport sends entries as datagrams to the journal socket, dialed
+// on first use and redialed after a failed write.
+type socketTransport struct {
+	mutex sync.Mutex
+	path  string
+	conn  *net.UnixConn
+}
+
+func (t *socketTransport) send(entry []byte) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.conn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: t.path, Net: "unixgram"})
+		if err != nil {
+			return fmt.Errorf("error when connecting to journal: %w", err)
+		}
+		t.conn = conn
+	}
+	_, err := t.conn.Write(entry)
+	if err != nil && isTooLarge(err) {
+		err = sendMemfd(t.conn, entry)
+	} else if err != nil {
+		// The journal may have been restarted; redial for the next entry.
+		_ = t.conn.Close()
+		t.conn = nil
+	}
+	if err != nil {
+		return fmt.Errorf("error when writing to journal: %w", err)
+	}
+	return nil
+}
+
+func (t *socketTransport) close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
+
+type handlerOptions struct {
+	socket     string
+	writer     io.Writer
+	level      slog.Leveler
+	identifier string
+	source     bool
+	table      *severity.Table
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithSocket sets the path of the journal socket, DefaultSocket by default.
+func WithSocket(path string) Option {
+	return func(h *handlerOptions) {
+		h.socket = path
+	}
+}
+
+// WithWriter writes entries to writer instead of the journal socket, in
+// the journal export format: serialized fields, with entries separated by
+// an empty line. It is meant for tests and for systemd-journal-remote.
+func WithWriter(writer io.Writer) Option {
+	return func(h *handlerOptions) {
+		h.writer = writer
+	}
+}
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithSyslogIdentifier sets SYSLOG_IDENTIFIER, the base name of the
+// executable by default. An empty identifier omits the field.
+func WithSyslogIdentifier(id string) Option {
+	return func(h *handlerOptions) {
+		h.identifier = id
+	}
+}
+
+// WithSource adds CODE_FILE, CODE_LINE and CODE_FUNC with the location of
+// the logging call.
+func WithSource(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.source = true
+		for i := range x {
+			h.source = x[i]
+		}
+	}
+}
+
+// WithSeverityTable sets the table used to map levels to syslog severities.
+// The default table from the severity package is used otherwise.
+func WithSeverityTable(t *severity.Table) Option {
+	return func(h *handlerOptions) {
+		h.table = t
+	}
+}
@@ -0,0 +1,102 @@
+package journald
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Handler(t *testing.T) {
+	t.Run("fields", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf), WithSyslogIdentifier("app")))
+
+		logger.With("user-id", 7).WithGroup("http").Warn("request failed", "status", 503, "_trusted", "x", "1st", "y")
+
+		want := "MESSAGE=request failed\nPRIORITY=4\nSYSLOG_IDENTIFIER=app\nUSER_ID=7\nHTTP_STATUS=503\nHTTP__TRUSTED=x\nHTTP_1ST=y\n\n"
+		if buf.String() != want {
+			t.Errorf("expected %q, got %q", want, buf.String())
+		}
+	})
+
+	t.Run("binary fields", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf), WithSyslogIdentifier("")))
+
+		logger.Error("line1\nline2", "_", "dropped")
+
+		want := "MESSAGE\n\x0b\x00\x00\x00\x00\x00\x00\x00line1\nline2\nPRIORITY=3\n\n"
+		if buf.String() != want {
+			t.Errorf("expected %q, got %q", want, buf.String())
+		}
+	})
+
+	t.Run("source", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		slog.New(NewHandler(WithWriter(buf), WithSource())).Info("test message")
+
+		if !strings.Contains(buf.String(), "handler_test.go\nCODE_LINE=") || !strings.Contains(buf.String(), "CODE_FUNC=github.com/mikluko/slogging/journald.Test_Handler") {
+			t.Errorf("unexpected source fields: %q", buf.String())
+		}
+	})
+
+	t.Run("socket", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "journal.sock")
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		h := NewHandler(WithSocket(path), WithSyslogIdentifier("app"))
+		defer h.Close()
+
+		slog.New(h).Info("test message")
+
+		b := make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(b)
+		if err != nil || string(b[:n]) != "MESSAGE=test message\nPRIORITY=6\nSYSLOG_IDENTIFIER=app\n" {
+			t.Errorf("unexpected datagram %q: %v", b[:n], err)
+		}
+	})
+
+	t.Run("socket redial", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "journal.sock")
+		listen := func() *net.UnixConn {
+			conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return conn
+		}
+		conn := listen()
+		h := NewHandler(WithSocket(path))
+		defer h.Close()
+		logger := slog.New(h)
+		logger.Info("first")
+
+		// Restart the journal: the write to the old socket fails and the
+		// handler redials for the next entry.
+		_ = conn.Close()
+		_ = os.Remove(path)
+		conn = listen()
+		defer conn.Close()
+		if err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "lost", 0)); err == nil {
+			t.Error("expected an error writing to the old socket")
+		}
+		logger.Info("second")
+
+		b := make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(b)
+		if err != nil || !strings.HasPrefix(string(b[:n]), "MESSAGE=second\n") {
+			t.Errorf("unexpected datagram %q: %v", b[:n], err)
+		}
+	})
+}
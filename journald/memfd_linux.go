@@ -0,0 +1,45 @@
+package journald
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func isTooLarge(err error) bool {
+	return errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)
+}
+
+// sendMemfd writes entry to a sealed memfd and passes its descriptor to
+// journald, the protocol for entries exceeding the datagram size limit.
+func sendMemfd(conn *net.UnixConn, entry []byte) error {
+	fd, err := unix.MemfdCreate("journal-entry", unix.MFD_CLOEXEC|unix.MFD_ALLOW_SEALING)
+	if err != nil {
+		return fmt.Errorf("error when creating memfd: %w", err)
+	}
+	defer unix.Close(fd)
+	for data := entry; len(data) > 0; {
+		n, err := unix.Write(fd, data)
+		if err != nil {
+			return fmt.Errorf("error when writing memfd: %w", err)
+		}
+		data = data[n:]
+	}
+	if _, err := unix.FcntlInt(uintptr(fd), unix.F_ADD_SEALS, unix.F_SEAL_SHRINK|unix.F_SEAL_GROW|unix.F_SEAL_WRITE|unix.F_SEAL_SEAL); err != nil {
+		return fmt.Errorf("error when sealing memfd: %w", err)
+	}
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	// WriteMsgUnix refuses connected datagram sockets, send directly.
+	var sendErr error
+	err = rc.Write(func(s uintptr) bool {
+		sendErr = unix.Sendmsg(int(s), nil, unix.UnixRights(fd), nil, 0)
+		return sendErr != unix.EAGAIN
+	})
+	return errors.Join(err, sendErr)
+}
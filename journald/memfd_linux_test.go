@@ -0,0 +1,56 @@
+package journald
+
+import (
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func Test_Memfd(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	server, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	client, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	entry := "MESSAGE=" + strings.Repeat("x", 1<<20) + "\n"
+	if err := sendMemfd(client, []byte(entry)); err != nil {
+		t.Fatal(err)
+	}
+
+	oob := make([]byte, unix.CmsgSpace(4))
+	_ = server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, oobn, _, _, err := server.ReadMsgUnix(nil, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(msgs) != 1 {
+		t.Fatalf("expected one control message: %v", err)
+	}
+	fds, err := unix.ParseUnixRights(&msgs[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("expected one descriptor: %v", err)
+	}
+	defer unix.Close(fds[0])
+
+	seals, err := unix.FcntlInt(uintptr(fds[0]), unix.F_GET_SEALS, 0)
+	if err != nil || seals&unix.F_SEAL_WRITE == 0 {
+		t.Errorf("expected sealed memfd, got seals %x: %v", seals, err)
+	}
+	b := make([]byte, len(entry)+1)
+	n, err := unix.Pread(fds[0], b, 0)
+	if err != nil || string(b[:n]) != entry {
+		t.Errorf("unexpected memfd content (%d bytes): %v", n, err)
+	}
+}
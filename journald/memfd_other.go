@@ -0,0 +1,16 @@
+//go:build !linux
+
+package journald
+
+import (
+	"errors"
+	"net"
+)
+
+func isTooLarge(error) bool {
+	return false
+}
+
+func sendMemfd(*net.UnixConn, []byte) error {
+	return errors.New("slogging: journal memfd transfer requires Linux")
+}
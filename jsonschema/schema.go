@@ -0,0 +1,302 @@
+// Package jsonschema generates a JSON Schema describing the records written
+// by slog.JSONHandler through the enrichments of this module, so that
+// downstream teams can validate records and generate typed parsers.
+package jsonschema
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/mikluko/slogging/otel"
+	"github.com/mikluko/slogging/stack"
+)
+
+// Draft is the JSON Schema dialect of generated schemas.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSON Schema types of properties.
+const (
+	String  = "string"
+	Integer = "integer"
+	Number  = "number"
+	Boolean = "boolean"
+	Object  = "object"
+)
+
+// Property describes a field of the record. Keys are literal: slog does
+// not split dotted keys, so "trace.id" is a single property.
+type Property struct {
+	Key         string
+	Type        string // Any type if empty
+	Format      string // e.g. "date-time"
+	Pattern     string
+	Description string
+	Required    bool
+	// Properties are the fields of objects, e.g. groups.
+	Properties []Property
+	// Items, if set, describes the values of an object keyed by index, as
+	// used by errattr chains and stack frames.
+	Items *Property
+}
+
+// Generate returns the JSON Schema of records written by slog.JSONHandler
+// with its default keys, extended with the properties of the enabled
+// enrichments. Properties sharing a key are merged.
+func Generate(options ...Option) ([]byte, error) {
+	config := handlerOptions{title: "Log record"}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	props := []Property{
+		{Key: slog.TimeKey, Type: String, Format: "date-time", Required: true},
+		{Key: slog.LevelKey, Type: String, Pattern: `^(DEBUG|INFO|WARN|ERROR)([+-][0-9]+)?$`, Required: true},
+		{Key: slog.MessageKey, Type: String, Required: true},
+	}
+	props = append(props, config.properties...)
+
+	schema := object(merge(props), !config.strict)
+	schema["$schema"] = Draft
+	schema["title"] = config.title
+	if config.id != "" {
+		schema["$id"] = config.id
+	}
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error when marshaling schema: %w", err)
+	}
+	return b, nil
+}
+
+// merge merges properties sharing a key, keeping the first position.
+func merge(props []Property) []Property {
+	var out []Property
+	index := map[string]int{}
+	for _, p := range props {
+		i, ok := index[p.Key]
+		if !ok {
+			index[p.Key] = len(out)
+			out = append(out, p)
+			continue
+		}
+		if out[i].Type == Object && p.Type == Object {
+			out[i].Properties = merge(append(append([]Property(nil), out[i].Properties...), p.Properties...))
+			out[i].Required = out[i].Required || p.Required
+			continue
+		}
+		out[i] = p
+	}
+	return out
+}
+
+func object(props []Property, additional bool) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	for _, p := range props {
+		properties[p.Key] = property(p, additional)
+		if p.Required {
+			required = append(required, p.Key)
+		}
+	}
+	o := map[string]any{
+		"type":                 Object,
+		"properties":           properties,
+		"additionalProperties": additional,
+	}
+	if len(required) > 0 {
+		o["required"] = required
+	}
+	return o
+}
+
+func property(p Property, additional bool) map[string]any {
+	var s map[string]any
+	switch {
+	case p.Items != nil:
+		s = map[string]any{
+			"type":                 Object,
+			"patternProperties":    map[string]any{"^[0-9]+$": property(*p.Items, additional)},
+			"additionalProperties": false,
+		}
+	case p.Type == Object:
+		s = object(p.Properties, additional)
+	case p.Type != "":
+		s = map[string]any{"type": p.Type}
+	default:
+		s = map[string]any{}
+	}
+	if p.Format != "" {
+		s["format"] = p.Format
+	}
+	if p.Pattern != "" {
+		s["pattern"] = p.Pattern
+	}
+	if p.Description != "" {
+		s["description"] = p.Description
+	}
+	return s
+}
+
+// OtelProperties returns the properties added by otel.Wrap with convention
+// c, including the trace flags and sampled attributes.
+func OtelProperties(c otel.Convention) []Property {
+	hex := func(n int) string {
+		return fmt.Sprintf("^[0-9a-f]{%d}$", n)
+	}
+	id := func(key string, custom bool, n int, desc string) Property {
+		p := Property{Key: key, Type: String, Description: desc}
+		if !custom {
+			p.Pattern = hex(n)
+		}
+		return p
+	}
+	var props []Property
+	if c.TraceID != "" {
+		props = append(props, id(c.TraceID, c.FormatTraceID != nil, 32, "Trace ID of the span in the logging context."))
+	}
+	if c.SpanID != "" {
+		props = append(props, id(c.SpanID, c.FormatSpanID != nil, 16, "Span ID of the span in the logging context."))
+	}
+	if c.ServiceName != "" {
+		props = append(props, Property{Key: c.ServiceName, Type: String, Description: "Service name of the tracer provider resource."})
+	}
+	if c.TraceFlags != "" {
+		props = append(props, Property{Key: c.TraceFlags, Type: String, Pattern: hex(2), Description: "W3C trace flags."})
+	}
+	if c.Sampled != "" {
+		props = append(props, Property{Key: c.Sampled, Type: Boolean, Description: "Whether the span is sampled."})
+	}
+	if c.Group == "" {
+		return props
+	}
+	return []Property{{Key: c.Group, Type: Object, Properties: props}}
+}
+
+// ErrorProperty returns the property of an error expanded by errattr under
+// key, with the stack rendered in the given format.
+func ErrorProperty(key string, f stack.Format) Property {
+	entry := Property{Type: Object, Properties: []Property{
+		{Key: "message", Type: String, Required: true},
+		{Key: "type", Type: String, Required: true},
+	}}
+	return Property{Key: key, Type: Object, Description: "Error expanded by errattr.", Properties: []Property{
+		{Key: "message", Type: String, Required: true},
+		{Key: "type", Type: String, Required: true},
+		{Key: "chain", Items: &entry, Description: "Wrapped errors, outermost first."},
+		StackProperty(stack.Key, f),
+	}}
+}
+
+// StackProperty returns the property of a stack trace rendered in the given
+// format under key.
+func StackProperty(key string, f stack.Format) Property {
+	if f == stack.String {
+		return Property{Key: key, Type: String, Description: "Stack trace."}
+	}
+	frame := Property{Type: Object, Properties: []Property{
+		{Key: "function", Type: String},
+		{Key: "file", Type: String},
+		{Key: "line", Type: Integer},
+	}}
+	return Property{Key: key, Items: &frame, Description: "Stack frames, innermost first."}
+}
+
+// HTTPProperties returns the properties of the records of the httplog
+// middleware and transport, including the optional captured bodies and
+// client timings.
+func HTTPProperties() []Property {
+	ns := func(key string) Property {
+		return Property{Key: key, Type: Integer, Description: "Duration in nanoseconds."}
+	}
+	body := func(key string) Property {
+		return Property{Key: key, Type: Object, Properties: []Property{
+			{Key: "content", Description: "Embedded JSON of complete JSON bodies, a string otherwise."},
+			{Key: "size", Type: Integer},
+			{Key: "truncated", Type: Boolean},
+		}}
+	}
+	return []Property{
+		{Key: "request_id", Type: String},
+		{Key: "method", Type: String},
+		{Key: "path", Type: String},
+		{Key: "url", Type: String},
+		{Key: "status", Type: Integer},
+		ns("duration"),
+		{Key: "bytes", Type: Integer},
+		{Key: "remote_addr", Type: String},
+		body("request_body"),
+		body("response_body"),
+		{Key: "timings", Type: Object, Properties: []Property{
+			ns("dns"), ns("connect"), ns("tls"), ns("ttfb"),
+			{Key: "reused", Type: Boolean},
+		}},
+	}
+}
+
+type handlerOptions struct {
+	title      string
+	id         string
+	strict     bool
+	properties []Property
+}
+
+// Option is a function that configures Generate.
+type Option func(h *handlerOptions)
+
+// WithTitle sets the title of the schema, "Log record" by default.
+func WithTitle(title string) Option {
+	return func(h *handlerOptions) {
+		h.title = title
+	}
+}
+
+// WithID sets the $id of the schema.
+func WithID(id string) Option {
+	return func(h *handlerOptions) {
+		h.id = id
+	}
+}
+
+// WithStrict disallows properties not described by the schema. By default
+// records may carry any additional attribute.
+func WithStrict(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.strict = true
+		for i := range x {
+			h.strict = x[i]
+		}
+	}
+}
+
+// WithOtel describes the trace attributes added by otel.Wrap with
+// convention c, e.g. otel.ConventionOTel.
+func WithOtel(c otel.Convention) Option {
+	return WithProperties(OtelProperties(c)...)
+}
+
+// WithErrors describes errors expanded by errattr under the given keys,
+// "error" if none, with stacks rendered as strings.
+func WithErrors(keys ...string) Option {
+	if len(keys) == 0 {
+		keys = []string{"error"}
+	}
+	props := make([]Property, len(keys))
+	for i, k := range keys {
+		props[i] = ErrorProperty(k, stack.String)
+	}
+	return WithProperties(props...)
+}
+
+// WithHTTP describes the attributes of the httplog middleware and transport.
+func WithHTTP() Option {
+	return WithProperties(HTTPProperties()...)
+}
+
+// WithProperties adds properties, e.g. the application attributes.
+func WithProperties(props ...Property) Option {
+	return func(h *handlerOptions) {
+		h.properties = append(h.properties, props...)
+	}
+}
@@ -0,0 +1,87 @@
+package jsonschema
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mikluko/slogging/otel"
+	"github.com/mikluko/slogging/stack"
+)
+
+func decode(t *testing.T, options ...Option) map[string]any {
+	t.Helper()
+	b, err := Generate(options...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func prop(t *testing.T, m map[string]any, keys ...string) map[string]any {
+	t.Helper()
+	for _, k := range keys {
+		props, _ := m["properties"].(map[string]any)
+		next, ok := props[k].(map[string]any)
+		if !ok {
+			t.Fatalf("no property %q in %v", k, m)
+		}
+		m = next
+	}
+	return m
+}
+
+func Test_Generate(t *testing.T) {
+	t.Run("base record", func(t *testing.T) {
+		m := decode(t, WithID("https://example.com/log.json"))
+		if m["$schema"] != Draft || m["$id"] != "https://example.com/log.json" || m["additionalProperties"] != true {
+			t.Errorf("unexpected schema: %v", m)
+		}
+		if prop(t, m, "time")["format"] != "date-time" || prop(t, m, "msg")["type"] != String {
+			t.Errorf("unexpected properties: %v", m["properties"])
+		}
+		if req, _ := m["required"].([]any); len(req) != 3 {
+			t.Errorf("unexpected required: %v", m["required"])
+		}
+	})
+
+	t.Run("otel conventions", func(t *testing.T) {
+		m := decode(t, WithOtel(otel.ConventionOTel))
+		if prop(t, m, "otel", "trace_id")["pattern"] != "^[0-9a-f]{32}$" || prop(t, m, "otel", "sampled")["type"] != Boolean {
+			t.Errorf("unexpected otel group: %v", prop(t, m, "otel"))
+		}
+		m = decode(t, WithOtel(otel.ConventionDatadog))
+		if p := prop(t, m, "dd.trace_id"); p["type"] != String || p["pattern"] != nil {
+			t.Errorf("unexpected datadog trace id: %v", p)
+		}
+	})
+
+	t.Run("errors and stacks", func(t *testing.T) {
+		m := decode(t, WithErrors("err"), WithProperties(StackProperty("trace", stack.Frames)))
+		chain := prop(t, m, "err", "chain")
+		entry := chain["patternProperties"].(map[string]any)["^[0-9]+$"].(map[string]any)
+		if prop(t, entry, "type")["type"] != String {
+			t.Errorf("unexpected chain: %v", chain)
+		}
+		frame := prop(t, m, "trace")["patternProperties"].(map[string]any)["^[0-9]+$"].(map[string]any)
+		if prop(t, frame, "line")["type"] != Integer {
+			t.Errorf("unexpected frame: %v", frame)
+		}
+	})
+
+	t.Run("http and merged groups", func(t *testing.T) {
+		m := decode(t, WithHTTP(), WithStrict(),
+			WithProperties(Property{Key: "app", Type: Object, Properties: []Property{{Key: "a", Type: String}}}),
+			WithProperties(Property{Key: "app", Type: Object, Properties: []Property{{Key: "b", Type: Integer}}}),
+		)
+		if prop(t, m, "status")["type"] != Integer || m["additionalProperties"] != false {
+			t.Errorf("unexpected schema: %v", m)
+		}
+		if prop(t, m, "app", "a")["type"] != String || prop(t, m, "app", "b")["type"] != Integer {
+			t.Errorf("groups not merged: %v", prop(t, m, "app"))
+		}
+	})
+}
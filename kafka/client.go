@@ -0,0 +1,366 @@
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Message is a message published to a topic.
+type Message struct {
+	Key   []byte // Nil for no key
+	Value []byte
+	Time  time.Time
+}
+
+// Producer publishes messages to a topic. It is implemented by Client, and
+// can be implemented on top of other Kafka clients.
+type Producer interface {
+	// Produce publishes msgs. Messages that could not be published are
+	// reported with a *DeliveryError.
+	Produce(ctx context.Context, topic string, msgs []Message) error
+}
+
+// DeliveryError reports the messages of a Produce call that were not
+// published.
+type DeliveryError struct {
+	Messages []Message
+	Err      error
+}
+
+func (e *DeliveryError) Error() string {
+	return fmt.Sprintf("%d messages not delivered: %s", len(e.Messages), e.Err)
+}
+
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// Client is a minimal Kafka producer. It looks up the leaders of the
+// partitions of topics, partitions messages by key with the hash of the
+// Java client, spreading messages without key over partitions in turn, and
+// sends one produce request per leader. Metadata is refreshed after
+// failures. Client is safe for concurrent use.
+type Client struct {
+	config handlerOptions
+
+	mutex  sync.Mutex
+	conns  map[string]*brokerConn
+	topics map[string][]string // Leader address per partition
+	next   int                 // Partition of the next messages without key
+}
+
+type brokerConn struct {
+	mutex       sync.Mutex
+	conn        net.Conn
+	correlation int32
+}
+
+// NewClient creates a client bootstrapping from brokers, given as
+// "host:port". Connections are opened on first use.
+func NewClient(brokers []string, options ...Option) *Client {
+	config := defaultOptions()
+	config.brokers = brokers
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return newClient(config)
+}
+
+func newClient(config handlerOptions) *Client {
+	return &Client{
+		config: config,
+		conns:  make(map[string]*brokerConn),
+		topics: make(map[string][]string),
+	}
+}
+
+// Produce publishes msgs to topic.
+func (c *Client) Produce(ctx context.Context, topic string, msgs []Message) error {
+	leaders, err := c.leaders(ctx, topic)
+	if err != nil {
+		return &DeliveryError{Messages: msgs, Err: err}
+	}
+	partitions := make(map[int][]Message)
+	c.mutex.Lock()
+	sticky := c.next % len(leaders)
+	c.next++
+	c.mutex.Unlock()
+	for _, m := range msgs {
+		p := sticky
+		if m.Key != nil {
+			p = partitionOf(m.Key, len(leaders))
+		}
+		partitions[p] = append(partitions[p], m)
+	}
+	brokers := make(map[string]map[int][]Message)
+	var failed []Message
+	var errs []error
+	for p, ms := range partitions {
+		addr := leaders[p]
+		if addr == "" {
+			failed = append(failed, ms...)
+			errs = append(errs, fmt.Errorf("partition %d: %w", p, Error(5)))
+			continue
+		}
+		if brokers[addr] == nil {
+			brokers[addr] = make(map[int][]Message)
+		}
+		brokers[addr][p] = ms
+	}
+	for addr, ps := range brokers {
+		for p, err := range c.produce(ctx, addr, topic, ps) {
+			failed = append(failed, ps[p]...)
+			errs = append(errs, fmt.Errorf("partition %d: %w", p, err))
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	c.mutex.Lock()
+	delete(c.topics, topic)
+	c.mutex.Unlock()
+	return &DeliveryError{Messages: failed, Err: errors.Join(errs...)}
+}
+
+// produce sends the messages of partitions to their leader at addr and
+// returns the errors of the partitions that failed.
+func (c *Client) produce(ctx context.Context, addr, topic string, partitions map[int][]Message) map[int]error {
+	failures := make(map[int]error)
+	var w writer
+	w.nullString() // Transactional ID
+	w.int16(int16(c.config.acks))
+	w.int32(int32(c.config.timeout.Milliseconds()))
+	w.int32(1)
+	w.string(topic)
+	w.int32(int32(len(partitions)))
+	for p, ms := range partitions {
+		batch, err := recordBatch(ms, c.config.compression)
+		if err != nil {
+			failures[p] = err
+			batch = nil
+		}
+		w.int32(int32(p))
+		w.int32(int32(len(batch)))
+		w.buf = append(w.buf, batch...)
+	}
+	if len(failures) > 0 {
+		return failures
+	}
+	resp, err := c.roundTrip(ctx, addr, apiProduce, produceVersion, w.buf, c.config.acks != AcksNone)
+	if err != nil {
+		for p := range partitions {
+			failures[p] = err
+		}
+		return failures
+	}
+	if c.config.acks == AcksNone {
+		return nil
+	}
+	r := reader{buf: resp}
+	seen := make(map[int]bool)
+	r.array(func() {
+		r.string()
+		r.array(func() {
+			p := int(r.int32())
+			code := r.int16()
+			r.int64() // Base offset
+			r.int64() // Log append time
+			seen[p] = true
+			if code != 0 {
+				failures[p] = Error(code)
+			}
+		})
+	})
+	for p := range partitions {
+		if r.err != nil {
+			failures[p] = fmt.Errorf("error when decoding produce response: %w", r.err)
+		} else if !seen[p] {
+			failures[p] = errors.New("partition missing from produce response")
+		}
+	}
+	return failures
+}
+
+// leaders returns the leader address of each partition of topic, looking
+// it up if unknown.
+func (c *Client) leaders(ctx context.Context, topic string) ([]string, error) {
+	c.mutex.Lock()
+	leaders, ok := c.topics[topic]
+	c.mutex.Unlock()
+	if ok {
+		return leaders, nil
+	}
+	var w writer
+	w.int32(1)
+	w.string(topic)
+	var errs []error
+	for _, addr := range c.config.brokers {
+		resp, err := c.roundTrip(ctx, addr, apiMetadata, metadataVersion, w.buf, true)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		leaders, err := parseMetadata(resp, topic)
+		if err != nil {
+			return nil, err
+		}
+		c.mutex.Lock()
+		c.topics[topic] = leaders
+		c.mutex.Unlock()
+		return leaders, nil
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("kafka: no brokers")
+	}
+	return nil, fmt.Errorf("error when fetching metadata: %w", errors.Join(errs...))
+}
+
+func parseMetadata(resp []byte, topic string) ([]string, error) {
+	r := reader{buf: resp}
+	brokers := make(map[int32]string)
+	r.array(func() {
+		id := r.int32()
+		host := r.string()
+		port := r.int32()
+		r.string() // Rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	})
+	r.int32() // Controller ID
+	var leaders []string
+	var topicErr error
+	r.array(func() {
+		code := r.int16()
+		name := r.string()
+		r.int8() // Is internal
+		var ls []string
+		r.array(func() {
+			r.int16() // Partition error, reflected by the leader
+			p := int(r.int32())
+			leader := r.int32()
+			r.array(func() { r.int32() }) // Replicas
+			r.array(func() { r.int32() }) // In-sync replicas
+			for len(ls) <= p {
+				ls = append(ls, "")
+			}
+			ls[p] = brokers[leader]
+		})
+		if name != topic {
+			return
+		}
+		if code != 0 {
+			topicErr = Error(code)
+		}
+		leaders = ls
+	})
+	switch {
+	case r.err != nil:
+		return nil, fmt.Errorf("error when decoding metadata response: %w", r.err)
+	case topicErr != nil:
+		return nil, fmt.Errorf("error when fetching metadata of %s: %w", topic, topicErr)
+	case len(leaders) == 0:
+		return nil, fmt.Errorf("error when fetching metadata of %s: %w", topic, Error(3))
+	}
+	return leaders, nil
+}
+
+// roundTrip sends a request to the broker at addr and returns the body of
+// its response, if one is expected.
+func (c *Client) roundTrip(ctx context.Context, addr string, key, version int16, body []byte, response bool) ([]byte, error) {
+	c.mutex.Lock()
+	bc, ok := c.conns[addr]
+	if !ok {
+		bc = &brokerConn{}
+		c.conns[addr] = bc
+	}
+	c.mutex.Unlock()
+
+	bc.mutex.Lock()
+	defer bc.mutex.Unlock()
+	if bc.conn == nil {
+		conn, err := c.dial(ctx, addr)
+		if err != nil {
+			return nil, fmt.Errorf("error when connecting to %s: %w", addr, err)
+		}
+		bc.conn = conn
+	}
+	resp, err := bc.exchange(ctx, c.config, key, version, body, response)
+	if err != nil {
+		_ = bc.conn.Close()
+		bc.conn = nil
+		return nil, fmt.Errorf("error when calling %s: %w", addr, err)
+	}
+	return resp, nil
+}
+
+func (c *Client) dial(ctx context.Context, addr string) (net.Conn, error) {
+	d := &net.Dialer{Timeout: c.config.timeout}
+	if c.config.tls != nil {
+		td := &tls.Dialer{NetDialer: d, Config: c.config.tls}
+		return td.DialContext(ctx, "tcp", addr)
+	}
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+func (bc *brokerConn) exchange(ctx context.Context, config handlerOptions, key, version int16, body []byte, response bool) ([]byte, error) {
+	deadline := time.Now().Add(config.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := bc.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	bc.correlation++
+	var w writer
+	w.int32(0) // Size, set below
+	w.int16(key)
+	w.int16(version)
+	w.int32(bc.correlation)
+	w.string(config.clientID)
+	w.buf = append(w.buf, body...)
+	binary.BigEndian.PutUint32(w.buf, uint32(len(w.buf)-4))
+	if _, err := bc.conn.Write(w.buf); err != nil {
+		return nil, err
+	}
+	if !response {
+		return nil, nil
+	}
+	var size [4]byte
+	if _, err := io.ReadFull(bc.conn, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint32(size[:]))
+	if _, err := io.ReadFull(bc.conn, resp); err != nil {
+		return nil, err
+	}
+	if len(resp) < 4 || int32(binary.BigEndian.Uint32(resp)) != bc.correlation {
+		return nil, errors.New("kafka: unexpected correlation ID")
+	}
+	return resp[4:], nil
+}
+
+// Close closes the connections to the brokers.
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var errs []error
+	for addr, bc := range c.conns {
+		bc.mutex.Lock()
+		if bc.conn != nil {
+			errs = append(errs, bc.conn.Close())
+			bc.conn = nil
+		}
+		bc.mutex.Unlock()
+		delete(c.conns, addr)
+	}
+	return errors.Join(errs...)
+}
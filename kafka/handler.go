@@ -0,0 +1,493 @@
+// Package kafka provides a slog.Handler publishing records to a Kafka
+// topic.
+package kafka
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/internal/jsonvalue"
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/internal/stats"
+)
+
+// ErrClosed is returned by Handle after the handler has been closed.
+var ErrClosed = errors.New("slogging: kafka handler is closed")
+
+const (
+	defaultClientID   = "slogging"
+	defaultTimeout    = 10 * time.Second
+	defaultBatchSize  = 1000
+	defaultBatchWait  = time.Second
+	defaultMaxRetries = 5
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 10 * time.Second
+)
+
+// batch accumulates messages. It is shared across WithAttrs and WithGroup
+// derivations and drained by a single delivery goroutine.
+type batch struct {
+	mutex    sync.Mutex
+	messages []Message
+	dropped  uint64
+	closed   bool
+
+	sendLock chan struct{}      // Serializes produce calls, held while it holds a value
+	ctx      context.Context    // Context of the delivery goroutine
+	cancel   context.CancelFunc // Cancels ctx when Close gives up
+	kick     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stats    stats.Recorder
+}
+
+// Serializer encodes records as message values, such as the Avro and
+// Protobuf serializers of the schemaregistry package. The records it is
+// passed carry the attributes added via WithAttrs, nested under the groups
+// opened via WithGroup, and the time the message is published with.
+type Serializer interface {
+	Serialize(r slog.Record) ([]byte, error)
+}
+
+// Handler is a slog.Handler that batches records and publishes them to a
+// Kafka topic. The value of a message is a JSON object of the attributes,
+// as added by slogging.PutAttrs, with the "time", "level" and "msg" keys
+// taking precedence and errors, text marshalers and stringers encoded as
+// strings, as by slog.JSONHandler, unless a serializer is set with
+// WithSerializer; its key is extracted as configured with WithKey or
+// WithKeyFunc, e.g. the trace ID or the tenant, so that related records
+// land on the same partition. Records without key are spread over
+// partitions.
+//
+// Batches are published when they reach the batch size or after the batch
+// wait, from a background goroutine. Messages that could not be delivered
+// are retried with exponential backoff; messages still failing are
+// dropped and passed to the function set with WithDeliveryFailure. Close
+// must be called to publish the pending records and stop the goroutine.
+type Handler struct {
+	scope    scope.Scope
+	topic    string
+	config   handlerOptions
+	producer Producer
+	client   *Client // Owned client, closed by Close
+	batch    *batch
+}
+
+func defaultOptions() handlerOptions {
+	return handlerOptions{
+		clientID:   defaultClientID,
+		acks:       AcksAll,
+		timeout:    defaultTimeout,
+		level:      slog.LevelInfo,
+		batchSize:  defaultBatchSize,
+		batchWait:  defaultBatchWait,
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+	}
+}
+
+// NewHandler creates a handler publishing to topic through a Client
+// bootstrapping from brokers, unless a producer is set with WithProducer,
+// and starts its delivery goroutine.
+func NewHandler(brokers []string, topic string, options ...Option) *Handler {
+	config := defaultOptions()
+	config.brokers = brokers
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	h := &Handler{
+		topic:    topic,
+		config:   config,
+		producer: config.producer,
+		batch: &batch{
+			kick:     make(chan struct{}, 1),
+			stop:     make(chan struct{}),
+			done:     make(chan struct{}),
+			sendLock: make(chan struct{}, 1),
+			ctx:      ctx,
+			cancel:   cancel,
+		},
+	}
+	if h.producer == nil {
+		h.client = newClient(config)
+		h.producer = h.client
+	}
+	go h.run()
+	return h
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.config.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// Handle encodes the record as a message and adds it to the batch.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	h.batch.stats.Handled(1)
+	attrs := h.scope.Attrs(r)
+	value := make(map[string]any, len(attrs)+3)
+	slogging.PutAttrs(value, attrs...)
+	jsonvalue.Map(value)
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	value[slog.TimeKey] = t
+	value[slog.LevelKey] = r.Level
+	value[slog.MessageKey] = r.Message
+
+	var key []byte
+	switch {
+	case h.config.keyFunc != nil:
+		key = h.config.keyFunc(ctx, r)
+	case h.config.keyPath != nil:
+		key = lookup(value, h.config.keyPath)
+	}
+	var b []byte
+	var err error
+	if h.config.serializer != nil {
+		sr := slog.NewRecord(t, r.Level, r.Message, r.PC)
+		sr.AddAttrs(attrs...)
+		b, err = h.config.serializer.Serialize(sr)
+	} else {
+		b, err = json.Marshal(value)
+	}
+	if err != nil {
+		return fmt.Errorf("error when encoding Kafka message: %w", err)
+	}
+	return h.add(Message{Key: key, Value: b, Time: t})
+}
+
+// lookup returns the value at path rendered as a key, or nil if missing.
+func lookup(m map[string]any, path []string) []byte {
+	var v any = m
+	for _, k := range path {
+		g, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		if v, ok = g[k]; !ok {
+			return nil
+		}
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		return nil
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+func (h *Handler) add(msg Message) error {
+	b := h.batch
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return ErrClosed
+	}
+	b.messages = append(b.messages, msg)
+	full := len(b.messages) >= h.config.batchSize
+	b.mutex.Unlock()
+	if full {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (h *Handler) run() {
+	b := h.batch
+	defer close(b.done)
+	ticker := time.NewTicker(h.config.batchWait)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.kick:
+		case <-b.stop:
+			return
+		}
+		h.push(b.ctx)
+	}
+}
+
+// take removes and returns the pending messages.
+func (h *Handler) take() []Message {
+	b := h.batch
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	msgs := b.messages
+	b.messages = nil
+	return msgs
+}
+
+// push publishes the pending messages, retrying the undelivered ones with
+// backoff until ctx is done.
+func (h *Handler) push(ctx context.Context) error {
+	b := h.batch
+	select {
+	case b.sendLock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-b.sendLock }()
+	msgs := h.take()
+	if len(msgs) == 0 {
+		return nil
+	}
+	backoff := h.config.minBackoff
+	for attempt := 0; ; attempt++ {
+		err := h.producer.Produce(ctx, h.topic, msgs)
+		if err == nil {
+			return nil
+		}
+		var de *DeliveryError
+		if errors.As(err, &de) {
+			msgs = de.Messages
+		}
+		err = fmt.Errorf("error when publishing to Kafka: %w", err)
+		if !retriable(err) || attempt >= h.config.maxRetries {
+			return h.fail(msgs, err)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return h.fail(msgs, fmt.Errorf("%w: %w", err, ctx.Err()))
+		}
+		backoff = min(2*backoff, h.config.maxBackoff)
+	}
+}
+
+func (h *Handler) fail(msgs []Message, err error) error {
+	h.batch.mutex.Lock()
+	h.batch.dropped += uint64(len(msgs))
+	h.batch.mutex.Unlock()
+	h.batch.stats.Error(err)
+	if h.config.onFailure != nil {
+		h.config.onFailure(msgs, err)
+	}
+	return err
+}
+
+// retriable reports whether err may go away on retry: any error but the
+// non-retriable error codes of brokers.
+func retriable(err error) bool {
+	var e Error
+	return !errors.As(err, &e) || e.Retriable()
+}
+
+// Flush publishes the pending records, retrying until ctx is done.
+func (h *Handler) Flush(ctx context.Context) error {
+	return h.push(ctx)
+}
+
+// Close stops accepting records, publishes the pending ones, retrying
+// until ctx is done, and stops the delivery goroutine. The client created
+// by NewHandler is closed; producers set with WithProducer are not.
+// When ctx is done first, the delivery in progress is cancelled, the
+// pending records are abandoned and Close returns ctx.Err().
+func (h *Handler) Close(ctx context.Context) error {
+	b := h.batch
+	b.mutex.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.stop)
+	}
+	b.mutex.Unlock()
+	var err error
+	select {
+	case <-b.done:
+		err = h.push(ctx)
+	case <-ctx.Done():
+		b.cancel()
+		err = ctx.Err()
+	}
+	if h.client != nil {
+		err = errors.Join(err, h.client.Close())
+	}
+	return err
+}
+
+// Dropped returns the number of records dropped after failed deliveries.
+func (h *Handler) Dropped() uint64 {
+	h.batch.mutex.Lock()
+	defer h.batch.mutex.Unlock()
+	return h.batch.dropped
+}
+
+// Stats returns the counters of the handler. The queue depth is the number
+// of records waiting for the next delivery.
+func (h *Handler) Stats() slogging.Stats {
+	b := h.batch
+	b.mutex.Lock()
+	dropped, depth := b.dropped, len(b.messages)
+	b.mutex.Unlock()
+	return b.stats.Stats(dropped, depth)
+}
+
+type handlerOptions struct {
+	brokers     []string
+	clientID    string
+	tls         *tls.Config
+	acks        Acks
+	timeout     time.Duration
+	compression Compression
+
+	producer   Producer
+	level      slog.Leveler
+	keyPath    []string
+	keyFunc    func(ctx context.Context, r slog.Record) []byte
+	serializer Serializer
+	batchSize  int
+	batchWait  time.Duration
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	onFailure  func(msgs []Message, err error)
+}
+
+// Option is a function that configures a Handler or a Client.
+type Option func(h *handlerOptions)
+
+// WithClientID sets the client ID sent to brokers, "slogging" by default.
+func WithClientID(id string) Option {
+	return func(h *handlerOptions) {
+		h.clientID = id
+	}
+}
+
+// WithTLS connects to brokers over TLS with the given configuration.
+func WithTLS(config *tls.Config) Option {
+	return func(h *handlerOptions) {
+		h.tls = config
+	}
+}
+
+// WithAcks sets the acknowledgments awaited by produce requests, AcksAll
+// by default.
+func WithAcks(acks Acks) Option {
+	return func(h *handlerOptions) {
+		h.acks = acks
+	}
+}
+
+// WithTimeout sets the timeout of connections and requests to brokers, ten
+// seconds by default.
+func WithTimeout(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.timeout = d
+	}
+}
+
+// WithCompression sets the compression codec of record batches, None by
+// default.
+func WithCompression(c Compression) Option {
+	return func(h *handlerOptions) {
+		h.compression = c
+	}
+}
+
+// WithProducer sets the producer publishing messages instead of a Client.
+func WithProducer(p Producer) Option {
+	return func(h *handlerOptions) {
+		h.producer = p
+	}
+}
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithKey sets the path of the attribute whose value is the message key,
+// with groups joined by ".", e.g. "otel.trace_id" or "tenant". Records
+// without the attribute have no key.
+func WithKey(path string) Option {
+	return func(h *handlerOptions) {
+		h.keyPath = strings.Split(path, ".")
+	}
+}
+
+// WithKeyFunc sets a function returning the message key of records, e.g.
+// read from the logging context. It takes precedence over WithKey.
+func WithKeyFunc(fn func(ctx context.Context, r slog.Record) []byte) Option {
+	return func(h *handlerOptions) {
+		h.keyFunc = fn
+	}
+}
+
+// WithSerializer sets the serializer encoding message values instead of
+// JSON, e.g. a schemaregistry.AvroSerializer. Keys set with WithKey are
+// still looked up in the attributes.
+func WithSerializer(s Serializer) Option {
+	return func(h *handlerOptions) {
+		h.serializer = s
+	}
+}
+
+// WithBatch sets the number of records triggering a publish, 1000 by
+// default, and the maximum time records wait for a publish, one second by
+// default.
+func WithBatch(size int, wait time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.batchSize = max(size, 1)
+		h.batchWait = wait
+	}
+}
+
+// WithRetry sets the number of retries of undelivered messages, 5 by
+// default, and the bounds of the exponential backoff between them, 100ms
+// and 10s by default.
+func WithRetry(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.maxRetries = maxRetries
+		h.minBackoff = minBackoff
+		h.maxBackoff = maxBackoff
+	}
+}
+
+// WithDeliveryFailure sets a function called with the messages dropped
+// after retries are exhausted and the last error. It is called from the
+// delivery goroutine, or from Flush and Close.
+func WithDeliveryFailure(fn func(msgs []Message, err error)) Option {
+	return func(h *handlerOptions) {
+		h.onFailure = fn
+	}
+}
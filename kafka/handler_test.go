@@ -0,0 +1,406 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+func Test_murmur2(t *testing.T) {
+	// Vectors of the Java client.
+	for s, want := range map[string]int32{
+		"21":                         -973932308,
+		"foobar":                     -790332482,
+		"a-little-bit-long-string":   -985981536,
+		"a-little-bit-longer-string": -1486304829,
+		"lkjh234lh9fiuh90y23oiuhsafujhadof229phr9h19h89h8": -58897971,
+		"abc": 479470107,
+	} {
+		if got := murmur2([]byte(s)); got != want {
+			t.Errorf("murmur2(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+// decodeBatch decodes a record batch into its messages, checking its CRC.
+func decodeBatch(t *testing.T, b []byte) []Message {
+	t.Helper()
+	r := reader{buf: b}
+	r.int64()
+	if n := int(r.int32()); n != len(r.buf) {
+		t.Fatalf("batch length %d, want %d", n, len(r.buf))
+	}
+	r.int32()
+	if magic := r.int8(); magic != 2 {
+		t.Fatalf("unexpected magic %d", magic)
+	}
+	crc := uint32(r.int32())
+	if crc32.Checksum(r.buf, castagnoli) != crc {
+		t.Fatalf("bad CRC")
+	}
+	attrs := r.int16()
+	r.int32()
+	base := r.int64()
+	r.int64()
+	r.int64()
+	r.int16()
+	r.int32()
+	count := int(r.int32())
+	data := r.buf
+	switch Compression(attrs & 7) {
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ = io.ReadAll(zr)
+	case Snappy:
+		if !bytes.HasPrefix(data, xerialHeader) {
+			t.Fatalf("missing xerial header")
+		}
+		var out []byte
+		for data = data[len(xerialHeader):]; len(data) > 0; {
+			n := binary.BigEndian.Uint32(data)
+			block, err := snappy.Decode(nil, data[4:4+n])
+			if err != nil {
+				t.Fatal(err)
+			}
+			out = append(out, block...)
+			data = data[4+n:]
+		}
+		data = out
+	}
+	varint := func() int64 {
+		v, n := binary.Varint(data)
+		data = data[n:]
+		return v
+	}
+	bytesOf := func() []byte {
+		n := varint()
+		if n < 0 {
+			return nil
+		}
+		b := data[:n]
+		data = data[n:]
+		return b
+	}
+	var msgs []Message
+	for range count {
+		varint() // Length
+		data = data[1:]
+		ts := varint()
+		varint()
+		key := bytesOf()
+		value := bytesOf()
+		varint()
+		msgs = append(msgs, Message{Key: key, Value: value, Time: time.UnixMilli(base + ts)})
+	}
+	return msgs
+}
+
+func Test_recordBatch(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	msgs := []Message{
+		{Key: []byte("k"), Value: []byte("one"), Time: now},
+		{Value: bytes.Repeat([]byte("two"), 20000), Time: now.Add(time.Second)},
+	}
+	for _, c := range []Compression{None, Gzip, Snappy} {
+		b, err := recordBatch(msgs, c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := decodeBatch(t, b)
+		if len(got) != 2 || string(got[0].Key) != "k" || got[1].Key != nil || !bytes.Equal(got[1].Value, msgs[1].Value) || !got[1].Time.Equal(msgs[1].Time) {
+			t.Errorf("compression %d: unexpected messages %v", c, got)
+		}
+	}
+}
+
+type fakeProducer struct {
+	mutex  sync.Mutex
+	msgs   []Message
+	errors []error
+	calls  int
+}
+
+func (p *fakeProducer) Produce(_ context.Context, _ string, msgs []Message) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.calls++
+	if len(p.errors) > 0 {
+		err := p.errors[0]
+		p.errors = p.errors[1:]
+		return &DeliveryError{Messages: msgs[:1], Err: err}
+	}
+	p.msgs = append(p.msgs, msgs...)
+	return nil
+}
+
+type serializerFunc func(r slog.Record) ([]byte, error)
+
+func (f serializerFunc) Serialize(r slog.Record) ([]byte, error) { return f(r) }
+
+func Test_Handler(t *testing.T) {
+	t.Run("records are published as JSON with keys", func(t *testing.T) {
+		p := &fakeProducer{}
+		h := NewHandler(nil, "logs", WithProducer(p), WithKey("otel.trace_id"))
+		logger := slog.New(h)
+		logger.Info("traced", slog.Group("otel", "trace_id", "abc"))
+		logger.With("app", "x").WithGroup("g").Warn("untraced", "n", 1)
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(p.msgs) != 2 || string(p.msgs[0].Key) != "abc" || p.msgs[1].Key != nil {
+			t.Fatalf("unexpected messages: %v", p.msgs)
+		}
+		var m map[string]any
+		if err := json.Unmarshal(p.msgs[1].Value, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m["msg"] != "untraced" || m["level"] != "WARN" || m["app"] != "x" || m["g"].(map[string]any)["n"] != 1.0 || m["time"] == nil {
+			t.Errorf("unexpected value: %v", m)
+		}
+	})
+
+	t.Run("errors are encoded as messages", func(t *testing.T) {
+		p := &fakeProducer{}
+		h := NewHandler(nil, "logs", WithProducer(p), WithKey("g.err"))
+		slog.New(h).WithGroup("g").Error("failed", "err", errors.New("boom"))
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(p.msgs) != 1 || string(p.msgs[0].Key) != "boom" {
+			t.Fatalf("unexpected messages: %v", p.msgs)
+		}
+		if v := string(p.msgs[0].Value); !strings.Contains(v, `"g":{"err":"boom"}`) {
+			t.Errorf("unexpected value: %s", v)
+		}
+	})
+
+	t.Run("serializer receives scoped records", func(t *testing.T) {
+		p := &fakeProducer{}
+		var got string
+		s := serializerFunc(func(r slog.Record) ([]byte, error) {
+			var attrs []slog.Attr
+			r.Attrs(func(a slog.Attr) bool {
+				attrs = append(attrs, a)
+				return true
+			})
+			got = r.Message + slog.GroupValue(attrs...).String()
+			return []byte("encoded"), nil
+		})
+		h := NewHandler(nil, "logs", WithProducer(p), WithSerializer(s), WithKey("g.id"))
+		slog.New(h).With("app", "x").WithGroup("g").Info("hello", "id", "k1")
+		_ = h.Close(context.Background())
+		if got != "hello[app=x g=[id=k1]]" {
+			t.Errorf("unexpected record: %s", got)
+		}
+		if len(p.msgs) != 1 || string(p.msgs[0].Value) != "encoded" || string(p.msgs[0].Key) != "k1" {
+			t.Errorf("unexpected messages: %v", p.msgs)
+		}
+	})
+
+	t.Run("key function", func(t *testing.T) {
+		type tenantKey struct{}
+		p := &fakeProducer{}
+		h := NewHandler(nil, "logs", WithProducer(p), WithKeyFunc(func(ctx context.Context, _ slog.Record) []byte {
+			tenant, _ := ctx.Value(tenantKey{}).(string)
+			return []byte(tenant)
+		}))
+		slog.New(h).InfoContext(context.WithValue(context.Background(), tenantKey{}, "acme"), "hello")
+		_ = h.Close(context.Background())
+		if string(p.msgs[0].Key) != "acme" {
+			t.Errorf("unexpected key: %q", p.msgs[0].Key)
+		}
+	})
+
+	t.Run("undelivered messages are retried then reported", func(t *testing.T) {
+		p := &fakeProducer{errors: []error{Error(6)}}
+		h := NewHandler(nil, "logs", WithProducer(p), WithRetry(2, time.Millisecond, time.Millisecond))
+		logger := slog.New(h)
+		logger.Info("a")
+		logger.Info("b")
+		if err := h.Close(context.Background()); err != nil || p.calls != 2 || len(p.msgs) != 1 {
+			t.Errorf("unexpected outcome: %v, %d calls, %d messages", err, p.calls, len(p.msgs))
+		}
+
+		var failed []Message
+		p = &fakeProducer{errors: []error{Error(10)}}
+		h = NewHandler(nil, "logs", WithProducer(p), WithDeliveryFailure(func(msgs []Message, err error) {
+			failed = msgs
+		}))
+		slog.New(h).Info("too large")
+		if err := h.Close(context.Background()); !errors.Is(err, Error(10)) || len(failed) != 1 || h.Dropped() != 1 || p.calls != 1 {
+			t.Errorf("unexpected outcome: %v, %v", err, failed)
+		}
+	})
+
+	t.Run("close gives up when the context is done", func(t *testing.T) {
+		p := &fakeProducer{errors: []error{Error(6), Error(6), Error(6), Error(6), Error(6), Error(6)}}
+		h := NewHandler(nil, "logs", WithProducer(p), WithBatch(1, time.Hour), WithRetry(5, time.Second, 2*time.Second))
+		slog.New(h).Info("stuck")
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			p.mutex.Lock()
+			n := p.calls
+			p.mutex.Unlock()
+			if n > 0 {
+				break
+			}
+			time.Sleep(time.Millisecond)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if err := h.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("close took %s", d)
+		}
+	})
+}
+
+// broker is a fake Kafka broker with a two-partition topic.
+type broker struct {
+	lis   net.Listener
+	mutex sync.Mutex
+	acked map[int32][]Message
+	fail  int16 // Error code of the next produce response
+}
+
+func newBroker(t *testing.T) *broker {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &broker{lis: lis, acked: make(map[int32][]Message)}
+	t.Cleanup(func() { _ = lis.Close() })
+	go func() {
+		for {
+			conn, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(t, conn)
+		}
+	}()
+	return b
+}
+
+func (b *broker) serve(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		r := reader{buf: req}
+		key, _ := r.int16(), r.int16()
+		corr := r.int32()
+		r.string()
+		var w writer
+		w.int32(0)
+		w.int32(corr)
+		switch key {
+		case apiMetadata:
+			host, port, _ := net.SplitHostPort(b.lis.Addr().String())
+			p, _ := strconv.Atoi(port)
+			w.int32(1)
+			w.int32(7)
+			w.string(host)
+			w.int32(int32(p))
+			w.nullString()
+			w.int32(7)
+			w.int32(1)
+			w.int16(0)
+			w.string("logs")
+			w.int8(0)
+			w.int32(2)
+			for i := int32(0); i < 2; i++ {
+				w.int16(0)
+				w.int32(i)
+				w.int32(7)
+				w.int32(0)
+				w.int32(0)
+			}
+		case apiProduce:
+			r.string()
+			r.int16()
+			r.int32()
+			r.int32()
+			r.string()
+			b.mutex.Lock()
+			code := b.fail
+			b.fail = 0
+			w.int32(1)
+			w.string("logs")
+			n := r.int32()
+			w.int32(n)
+			for i := int32(0); i < n; i++ {
+				p := r.int32()
+				batch := r.take(int(r.int32()))
+				if code == 0 {
+					b.acked[p] = append(b.acked[p], decodeBatch(t, batch)...)
+				}
+				w.int32(p)
+				w.int16(code)
+				w.int64(0)
+				w.int64(-1)
+			}
+			b.mutex.Unlock()
+			w.int32(0)
+		}
+		binary.BigEndian.PutUint32(w.buf, uint32(len(w.buf)-4))
+		if _, err := conn.Write(w.buf); err != nil {
+			return
+		}
+	}
+}
+
+func Test_Client(t *testing.T) {
+	b := newBroker(t)
+	c := NewClient([]string{b.lis.Addr().String()}, WithCompression(Gzip))
+	defer c.Close()
+	ctx := context.Background()
+	now := time.Now()
+
+	msgs := []Message{{Key: []byte("foobar"), Value: []byte("1"), Time: now}, {Key: []byte("foobar"), Value: []byte("2"), Time: now}, {Value: []byte("3"), Time: now}}
+	if err := c.Produce(ctx, "logs", msgs); err != nil {
+		t.Fatal(err)
+	}
+	p := int32(partitionOf([]byte("foobar"), 2))
+	if got := b.acked[p]; len(got) < 2 || string(got[0].Value) != "1" || string(got[1].Value) != "2" {
+		t.Errorf("keyed messages not on partition %d: %v", p, b.acked)
+	}
+
+	b.mutex.Lock()
+	b.fail = 6
+	b.mutex.Unlock()
+	err := c.Produce(ctx, "logs", msgs[:1])
+	var de *DeliveryError
+	if !errors.As(err, &de) || len(de.Messages) != 1 || !errors.Is(err, Error(6)) || !retriable(err) {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := c.Produce(ctx, "unknown", msgs[:1]); err == nil {
+		t.Errorf("expected an error for an unknown topic")
+	}
+}
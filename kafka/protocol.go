@@ -0,0 +1,268 @@
+package kafka
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/golang/snappy"
+)
+
+// API keys and versions of the requests used by Client.
+const (
+	apiProduce  = 0
+	apiMetadata = 3
+
+	produceVersion  = 3 // First version carrying record batches
+	metadataVersion = 1
+)
+
+// Compression is the compression codec of record batches.
+type Compression int
+
+const (
+	None   Compression = 0
+	Gzip   Compression = 1
+	Snappy Compression = 2
+)
+
+// Acks is the number of acknowledgments the leader waits for before
+// answering a produce request.
+type Acks int16
+
+const (
+	// AcksNone does not wait for any acknowledgment: the broker sends no
+	// response and delivery failures go unnoticed.
+	AcksNone Acks = 0
+	// AcksLeader waits for the leader to write the records.
+	AcksLeader Acks = 1
+	// AcksAll waits for the full set of in-sync replicas.
+	AcksAll Acks = -1
+)
+
+// Error is an error code returned by a broker.
+type Error int16
+
+func (e Error) Error() string {
+	if name, ok := errorNames[e]; ok {
+		return fmt.Sprintf("kafka error %d: %s", int16(e), name)
+	}
+	return fmt.Sprintf("kafka error %d", int16(e))
+}
+
+var errorNames = map[Error]string{
+	1:  "OFFSET_OUT_OF_RANGE",
+	2:  "CORRUPT_MESSAGE",
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	13: "NETWORK_EXCEPTION",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+	87: "INVALID_RECORD",
+}
+
+// Retriable reports whether the request failing with the error may
+// succeed later, possibly after refreshing the metadata.
+func (e Error) Retriable() bool {
+	switch e {
+	case 3, 5, 6, 7, 13, 19, 20:
+		return true
+	}
+	return false
+}
+
+var errShort = errors.New("kafka: short response")
+
+// writer encodes the primitive types of the Kafka protocol.
+type writer struct {
+	buf []byte
+}
+
+func (w *writer) int8(v int8)   { w.buf = append(w.buf, byte(v)) }
+func (w *writer) int16(v int16) { w.buf = binary.BigEndian.AppendUint16(w.buf, uint16(v)) }
+func (w *writer) int32(v int32) { w.buf = binary.BigEndian.AppendUint32(w.buf, uint32(v)) }
+func (w *writer) int64(v int64) { w.buf = binary.BigEndian.AppendUint64(w.buf, uint64(v)) }
+
+func (w *writer) varint(v int64) { w.buf = binary.AppendVarint(w.buf, v) }
+
+func (w *writer) string(s string) {
+	w.int16(int16(len(s)))
+	w.buf = append(w.buf, s...)
+}
+
+func (w *writer) nullString() { w.int16(-1) }
+
+func (w *writer) varBytes(b []byte) {
+	if b == nil {
+		w.varint(-1)
+		return
+	}
+	w.varint(int64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// reader decodes the primitive types of the Kafka protocol. Errors are
+// sticky: after a short read every value is zero.
+type reader struct {
+	buf []byte
+	err error
+}
+
+func (r *reader) take(n int) []byte {
+	if r.err != nil || len(r.buf) < n || n < 0 {
+		r.err = errShort
+		return make([]byte, max(n, 0))
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *reader) int8() int8   { return int8(r.take(1)[0]) }
+func (r *reader) int16() int16 { return int16(binary.BigEndian.Uint16(r.take(2))) }
+func (r *reader) int32() int32 { return int32(binary.BigEndian.Uint32(r.take(4))) }
+func (r *reader) int64() int64 { return int64(binary.BigEndian.Uint64(r.take(8))) }
+
+func (r *reader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.take(int(n)))
+}
+
+// array calls fn for each element of an array.
+func (r *reader) array(fn func()) {
+	n := r.int32()
+	for i := int32(0); i < n && r.err == nil; i++ {
+		fn()
+	}
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// recordBatch encodes msgs as a record batch of magic 2.
+func recordBatch(msgs []Message, c Compression) ([]byte, error) {
+	base := msgs[0].Time
+	maxTime := base
+	var records writer
+	for i, m := range msgs {
+		if m.Time.After(maxTime) {
+			maxTime = m.Time
+		}
+		var rec writer
+		rec.int8(0) // Attributes
+		rec.varint(m.Time.Sub(base).Milliseconds())
+		rec.varint(int64(i))
+		rec.varBytes(m.Key)
+		rec.varBytes(m.Value)
+		rec.varint(0) // Headers
+		records.varint(int64(len(rec.buf)))
+		records.buf = append(records.buf, rec.buf...)
+	}
+	data, err := compress(records.buf, c)
+	if err != nil {
+		return nil, err
+	}
+
+	var tail writer // From attributes to the end, covered by the CRC
+	tail.int16(int16(c))
+	tail.int32(int32(len(msgs) - 1))
+	tail.int64(base.UnixMilli())
+	tail.int64(maxTime.UnixMilli())
+	tail.int64(-1) // Producer ID
+	tail.int16(-1) // Producer epoch
+	tail.int32(-1) // Base sequence
+	tail.int32(int32(len(msgs)))
+	tail.buf = append(tail.buf, data...)
+
+	var w writer
+	w.int64(0)                                // Base offset
+	w.int32(int32(4 + 1 + 4 + len(tail.buf))) // Length after this field
+	w.int32(-1)                               // Partition leader epoch
+	w.int8(2)                                 // Magic
+	w.buf = binary.BigEndian.AppendUint32(w.buf, crc32.Checksum(tail.buf, castagnoli))
+	w.buf = append(w.buf, tail.buf...)
+	return w.buf, nil
+}
+
+// xerialHeader starts the snappy framing of the Java client, expected by
+// brokers in snappy-compressed batches.
+var xerialHeader = []byte{0x82, 'S', 'N', 'A', 'P', 'P', 'Y', 0, 0, 0, 0, 1, 0, 0, 0, 1}
+
+const xerialBlockSize = 32 << 10
+
+func compress(b []byte, c Compression) ([]byte, error) {
+	switch c {
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(b); err != nil {
+			return nil, fmt.Errorf("error when compressing record batch: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("error when compressing record batch: %w", err)
+		}
+		return buf.Bytes(), nil
+	case Snappy:
+		out := append([]byte(nil), xerialHeader...)
+		for len(b) > 0 {
+			n := min(len(b), xerialBlockSize)
+			block := snappy.Encode(nil, b[:n])
+			out = binary.BigEndian.AppendUint32(out, uint32(len(block)))
+			out = append(out, block...)
+			b = b[n:]
+		}
+		return out, nil
+	}
+	return b, nil
+}
+
+// murmur2 is the hash of the default partitioner of the Java client, so
+// that keyed records land on the same partitions as with other producers.
+func murmur2(data []byte) int32 {
+	const (
+		seed = 0x9747b28c
+		m    = 0x5bd1e995
+		r    = 24
+	)
+	length := len(data)
+	h := uint32(seed ^ length)
+	for i := 0; i+4 <= length; i += 4 {
+		k := binary.LittleEndian.Uint32(data[i:])
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+	tail := data[length&^3:]
+	switch len(tail) {
+	case 3:
+		h ^= uint32(tail[2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(tail[1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(tail[0])
+		h *= m
+	}
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return int32(h)
+}
+
+// partitionOf returns the partition of key among n partitions, as chosen
+// by the Java client.
+func partitionOf(key []byte, n int) int {
+	return int(murmur2(key)&0x7fffffff) % n
+}
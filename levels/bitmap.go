@@ -0,0 +1,162 @@
+package levels
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/mikluko/slogging"
+)
+
+const (
+	// MinLevel and MaxLevel bound the levels represented exactly by a Bitmap.
+	// Levels outside of the range share the bit of the nearest bound.
+	MinLevel = slog.Level(-32)
+	MaxLevel = slog.Level(31)
+)
+
+// Bitmap is a set of enabled levels, one bit per level between MinLevel
+// and MaxLevel. Testing a level is a shift and a mask, so it can be
+// consulted on the hot path without allocations, map lookups or locks.
+type Bitmap uint64
+
+func bit(level slog.Level) Bitmap {
+	level = min(max(level, MinLevel), MaxLevel)
+	return 1 << uint(level-MinLevel)
+}
+
+// Threshold returns a Bitmap enabling every level at or above lvl.
+func Threshold(lvl slog.Level) Bitmap {
+	lvl = min(max(lvl, MinLevel), MaxLevel)
+	return ^Bitmap(0) << uint(lvl-MinLevel)
+}
+
+// Enabled reports whether level is in the set.
+func (b Bitmap) Enabled(level slog.Level) bool {
+	return b&bit(level) != 0
+}
+
+// With returns a copy of the set with level enabled.
+func (b Bitmap) With(level slog.Level) Bitmap {
+	return b | bit(level)
+}
+
+// Without returns a copy of the set with level disabled.
+func (b Bitmap) Without(level slog.Level) Bitmap {
+	return b &^ bit(level)
+}
+
+// Level returns the lowest enabled level, or MaxLevel+1 if none is enabled.
+func (b Bitmap) Level() slog.Level {
+	for l := MinLevel; l <= MaxLevel; l++ {
+		if b.Enabled(l) {
+			return l
+		}
+	}
+	return MaxLevel + 1
+}
+
+// Component holds the enabled levels of a named part of the application,
+// e.g. "http" or "db". The set can be replaced at any time and is read
+// atomically. Component implements slog.Leveler, reporting the lowest
+// enabled level.
+type Component struct {
+	name string
+	bits atomic.Uint64
+}
+
+// NewComponent creates a component with every level at or above lvl enabled.
+func NewComponent(name string, lvl slog.Leveler) *Component {
+	c := &Component{name: name}
+	c.Store(Threshold(lvl.Level()))
+	return c
+}
+
+// Name returns the component name.
+func (c *Component) Name() string {
+	return c.name
+}
+
+// Load returns the enabled levels.
+func (c *Component) Load() Bitmap {
+	return Bitmap(c.bits.Load())
+}
+
+// Store replaces the enabled levels.
+func (c *Component) Store(b Bitmap) {
+	c.bits.Store(uint64(b))
+}
+
+// Enabled reports whether level is enabled for the component.
+func (c *Component) Enabled(level slog.Level) bool {
+	return Bitmap(c.bits.Load()).Enabled(level)
+}
+
+// Level returns the lowest enabled level of the component.
+func (c *Component) Level() slog.Level {
+	return c.Load().Level()
+}
+
+// Handler is a slog.Handler that filters records by the enabled levels of
+// a Component before delegating to the wrapped handler.
+type Handler struct {
+	handler   slog.Handler
+	component *Component
+	registry  *Registry // Set for handlers selecting the component by logger name
+	grouped   bool
+}
+
+// Wrap creates a handler filtering records by the levels enabled for c.
+func Wrap(handler slog.Handler, c *Component) *Handler {
+	return &Handler{handler: handler, component: c}
+}
+
+// Enabled reports whether the level is enabled for the component, or by
+// the level of the context set with slogging.WithContextLevel, and the
+// wrapped handler.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.enabled(ctx, level) && h.handler.Enabled(ctx, level)
+}
+
+// Handle delegates records enabled for the component to the wrapped handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if !h.enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *Handler) enabled(ctx context.Context, level slog.Level) bool {
+	if l, ok := slogging.ContextLevel(ctx); ok {
+		return level >= l
+	}
+	return h.component.Enabled(level)
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	if h.registry != nil && !h.grouped {
+		for _, a := range attrs {
+			if a.Key == NameKey {
+				h2.component = h.registry.Get(a.Value.String())
+			}
+		}
+	}
+	return &h2
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	h2.grouped = true
+	return &h2
+}
@@ -0,0 +1,77 @@
+package levels
+
+import (
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+// mapLevels is the straightforward approach the bitmap is compared against:
+// a mutex-protected map from component name to minimal level.
+type mapLevels struct {
+	mutex  sync.RWMutex
+	levels map[string]slog.Level
+}
+
+func (m *mapLevels) enabled(name string, level slog.Level) bool {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return level >= m.levels[name]
+}
+
+func BenchmarkEnabled(b *testing.B) {
+	b.Run("MapWithMutex", func(b *testing.B) {
+		m := &mapLevels{levels: map[string]slog.Level{"http": slog.LevelInfo, "db": slog.LevelWarn}}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			m.enabled("db", slog.LevelInfo)
+		}
+	})
+
+	b.Run("LevelVar", func(b *testing.B) {
+		var lv slog.LevelVar
+		lv.Set(slog.LevelWarn)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_ = slog.LevelInfo >= lv.Level()
+		}
+	})
+
+	b.Run("ComponentBitmap", func(b *testing.B) {
+		c := NewComponent("db", slog.LevelWarn)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			c.Enabled(slog.LevelInfo)
+		}
+	})
+
+	b.Run("ComponentBitmapParallel", func(b *testing.B) {
+		c := NewComponent("db", slog.LevelWarn)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c.Enabled(slog.LevelInfo)
+			}
+		})
+	})
+
+	b.Run("MapWithMutexParallel", func(b *testing.B) {
+		m := &mapLevels{levels: map[string]slog.Level{"http": slog.LevelInfo, "db": slog.LevelWarn}}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				m.enabled("db", slog.LevelInfo)
+			}
+		})
+	})
+}
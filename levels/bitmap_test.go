@@ -0,0 +1,71 @@
+package levels
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/slogging"
+)
+
+func Test_Bitmap(t *testing.T) {
+	t.Run("threshold enables levels at and above", func(t *testing.T) {
+		b := Threshold(slog.LevelInfo)
+		if b.Enabled(slog.LevelDebug) || !b.Enabled(slog.LevelInfo) || !b.Enabled(slog.LevelError) {
+			t.Errorf("unexpected bitmap %b", b)
+		}
+		if b.Level() != slog.LevelInfo {
+			t.Errorf("expected lowest level INFO, got %s", b.Level())
+		}
+	})
+
+	t.Run("individual levels can be toggled", func(t *testing.T) {
+		b := Threshold(slog.LevelWarn).With(slog.LevelDebug).Without(slog.LevelError)
+		if !b.Enabled(slog.LevelDebug) || b.Enabled(slog.LevelInfo) || b.Enabled(slog.LevelError) || !b.Enabled(slog.LevelWarn) {
+			t.Errorf("unexpected bitmap %b", b)
+		}
+	})
+
+	t.Run("out of range levels clamp to bounds", func(t *testing.T) {
+		b := Threshold(slog.Level(100))
+		if !b.Enabled(slog.Level(200)) || b.Enabled(slog.LevelError) {
+			t.Errorf("unexpected bitmap %b", b)
+		}
+		if !Threshold(slog.Level(-100)).Enabled(slog.Level(-50)) {
+			t.Errorf("expected all levels to be enabled")
+		}
+	})
+
+	t.Run("empty bitmap", func(t *testing.T) {
+		if Bitmap(0).Level() != MaxLevel+1 {
+			t.Errorf("unexpected level for empty bitmap")
+		}
+	})
+}
+
+func Test_Handler(t *testing.T) {
+	buf := new(bytes.Buffer)
+	c := NewComponent("db", slog.LevelWarn)
+	logger := slog.New(Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}), c))
+
+	logger.Info("hidden")
+	c.Store(Threshold(slog.LevelDebug))
+	logger.Debug("visible")
+
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "visible") {
+		t.Errorf("unexpected output: %s", buf.String())
+	}
+	if c.Level() != slog.LevelDebug {
+		t.Errorf("expected component level DEBUG, got %s", c.Level())
+	}
+
+	buf.Reset()
+	c.Store(Threshold(slog.LevelError))
+	logger.DebugContext(slogging.WithVerbose(context.Background()), "verbose")
+	logger.WarnContext(context.Background(), "hidden")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "verbose") {
+		t.Errorf("context level not honored: %s", buf.String())
+	}
+}
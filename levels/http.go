@@ -0,0 +1,72 @@
+package levels
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// AdminHandler returns an http.Handler exposing the levels of the registry.
+//
+// GET responds with a JSON object mapping component names to levels.
+// PUT and POST accept the same JSON object and change the listed
+// components; alternatively the "name" and "level" query parameters
+// change a single component. Levels use the slog.Level text format,
+// e.g. "DEBUG" or "INFO+2".
+//
+// The handler performs no authentication; mount it behind appropriate
+// access control.
+func AdminHandler(r *Registry) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			changes, err := parseChanges(req)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for name, lvl := range changes {
+				r.Set(name, lvl)
+			}
+		default:
+			w.Header().Set("Allow", "GET, PUT, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		levels := r.Levels()
+		out := make(map[string]string, len(levels))
+		for name, lvl := range levels {
+			out[name] = lvl.String()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	})
+}
+
+func parseChanges(req *http.Request) (map[string]slog.Level, error) {
+	changes := make(map[string]slog.Level)
+	if name := req.URL.Query().Get("name"); name != "" {
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(req.URL.Query().Get("level"))); err != nil {
+			return nil, fmt.Errorf("invalid level for %q: %w", name, err)
+		}
+		changes[name] = lvl
+		return changes, nil
+	}
+
+	var body map[string]string
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("error when decoding request body: %w", err)
+	}
+	for name, text := range body {
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(text)); err != nil {
+			return nil, fmt.Errorf("invalid level for %q: %w", name, err)
+		}
+		changes[name] = lvl
+	}
+	return changes, nil
+}
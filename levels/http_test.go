@@ -0,0 +1,63 @@
+package levels
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_AdminHandler(t *testing.T) {
+	reg := NewRegistry(slog.LevelInfo)
+	reg.Get("http")
+	handler := AdminHandler(reg)
+
+	t.Run("get lists levels", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		var out map[string]string
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		if out["http"] != "INFO" {
+			t.Errorf("unexpected levels: %v", out)
+		}
+	})
+
+	t.Run("put changes levels from body", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"db":"DEBUG","http":"WARN"}`)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+		}
+		if reg.Get("db").Level() != slog.LevelDebug || reg.Get("http").Level() != slog.LevelWarn {
+			t.Errorf("levels were not changed: %v", reg.Levels())
+		}
+	})
+
+	t.Run("post changes level from query", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/?name=db&level=ERROR", nil))
+		if rec.Code != http.StatusOK || reg.Get("db").Level() != slog.LevelError {
+			t.Errorf("level was not changed: %d %v", rec.Code, reg.Levels())
+		}
+	})
+
+	t.Run("invalid level is rejected", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/?name=db&level=LOUD", nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("expected bad request, got %d", rec.Code)
+		}
+	})
+
+	t.Run("unsupported method", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/", nil))
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected method not allowed, got %d", rec.Code)
+		}
+	})
+}
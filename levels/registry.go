@@ -0,0 +1,125 @@
+package levels
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/mikluko/slogging"
+)
+
+// Registry holds named components whose levels can be changed at runtime.
+// Components are created on first use with the default level of the
+// registry. Registry is safe for concurrent use; the lookup by name happens
+// only when a handler is registered, never when a record is logged.
+type Registry struct {
+	mutex      sync.RWMutex
+	components map[string]*Component
+	level      slog.Level
+}
+
+// NewRegistry creates a registry whose components start at lvl.
+func NewRegistry(lvl slog.Leveler) *Registry {
+	return &Registry{
+		components: make(map[string]*Component),
+		level:      lvl.Level(),
+	}
+}
+
+// Get returns the component registered under name, creating it if needed.
+// The component implements slog.Leveler and follows later level changes.
+func (r *Registry) Get(name string) *Component {
+	r.mutex.RLock()
+	c, ok := r.components[name]
+	r.mutex.RUnlock()
+	if ok {
+		return c
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if c, ok = r.components[name]; !ok {
+		c = NewComponent(name, r.level)
+		r.components[name] = c
+	}
+	return c
+}
+
+// Set enables every level at or above lvl for the named component.
+func (r *Registry) Set(name string, lvl slog.Level) {
+	r.Get(name).Store(Threshold(lvl))
+}
+
+// Register wraps handler so that records are filtered by the level of the
+// named component.
+func (r *Registry) Register(name string, handler slog.Handler) *Handler {
+	return Wrap(handler, r.Get(name))
+}
+
+// NameKey is the attribute naming the logger, as set by slogging.Named.
+const NameKey = slogging.NameKey
+
+// Root is the name of the component filtering the records of unnamed
+// loggers in handlers created with Named.
+const Root = "root"
+
+// Named wraps handler so that records are filtered by the component named
+// after the logger they are logged with, as set by slogging.Named through
+// the NameKey attribute. Records of unnamed loggers are filtered by
+// the Root component. The name is taken from attributes added before any
+// group is opened, so the lookup happens when the logger is derived, not
+// when a record is logged.
+func (r *Registry) Named(handler slog.Handler) *Handler {
+	return &Handler{handler: handler, component: r.Get(Root), registry: r}
+}
+
+// Names returns the names of all components in lexical order.
+func (r *Registry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	names := make([]string, 0, len(r.components))
+	for name := range r.components {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Levels returns the current level of every component.
+func (r *Registry) Levels() map[string]slog.Level {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	levels := make(map[string]slog.Level, len(r.components))
+	for name, c := range r.components {
+		levels[name] = c.Level()
+	}
+	return levels
+}
+
+var defaultRegistry = NewRegistry(slog.LevelInfo)
+
+// Default returns the process-wide registry used by the package-level functions.
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Get returns the named component of the default registry.
+func Get(name string) *Component {
+	return defaultRegistry.Get(name)
+}
+
+// Set changes the level of the named component of the default registry.
+func Set(name string, lvl slog.Level) {
+	defaultRegistry.Set(name, lvl)
+}
+
+// Named wraps handler with components of the default registry selected by
+// logger name.
+func Named(handler slog.Handler) *Handler {
+	return defaultRegistry.Named(handler)
+}
+
+// Register wraps handler with the named component of the default registry.
+func Register(name string, handler slog.Handler) *Handler {
+	return defaultRegistry.Register(name, handler)
+}
@@ -0,0 +1,93 @@
+package levels
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_Registry(t *testing.T) {
+	t.Run("levels change at runtime per name", func(t *testing.T) {
+		reg := NewRegistry(slog.LevelInfo)
+		buf := new(bytes.Buffer)
+		base := slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})
+		httpLogger := slog.New(reg.Register("http", base))
+		dbLogger := slog.New(reg.Register("db", base))
+
+		dbLogger.Debug("db hidden")
+		reg.Set("db", slog.LevelDebug)
+		dbLogger.Debug("db visible")
+		httpLogger.Debug("http hidden")
+
+		out := buf.String()
+		if strings.Contains(out, "db hidden") || !strings.Contains(out, "db visible") || strings.Contains(out, "http hidden") {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("components are usable as slog.Leveler", func(t *testing.T) {
+		reg := NewRegistry(slog.LevelWarn)
+		var leveler slog.Leveler = reg.Get("cache")
+		reg.Set("cache", slog.LevelDebug)
+		if leveler.Level() != slog.LevelDebug {
+			t.Errorf("expected DEBUG, got %s", leveler.Level())
+		}
+	})
+
+	t.Run("names and levels are reported", func(t *testing.T) {
+		reg := NewRegistry(slog.LevelInfo)
+		reg.Set("b", slog.LevelError)
+		reg.Get("a")
+		if names := reg.Names(); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+			t.Errorf("unexpected names: %v", names)
+		}
+		if lv := reg.Levels(); lv["a"] != slog.LevelInfo || lv["b"] != slog.LevelError {
+			t.Errorf("unexpected levels: %v", lv)
+		}
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		reg := NewRegistry(slog.LevelInfo)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 100; j++ {
+					reg.Set("x", slog.Level(j%8))
+					reg.Get("x").Enabled(slog.LevelInfo)
+				}
+			}(i)
+		}
+		wg.Wait()
+	})
+
+	t.Run("named loggers select components", func(t *testing.T) {
+		reg := NewRegistry(slog.LevelInfo)
+		buf := new(bytes.Buffer)
+		logger := slog.New(reg.Named(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+		httpLogger := logger.With(NameKey, "server.http")
+		grouped := logger.WithGroup("g").With(NameKey, "server.http")
+
+		reg.Set("server.http", slog.LevelDebug)
+		httpLogger.Debug("http visible")
+		logger.Debug("root hidden")
+		grouped.Debug("grouped hidden")
+		reg.Set(Root, slog.LevelDebug)
+		logger.Debug("root visible")
+
+		out := buf.String()
+		if !strings.Contains(out, "http visible") || strings.Contains(out, "hidden") || !strings.Contains(out, "root visible") {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("package-level functions use default registry", func(t *testing.T) {
+		Set("pkg-test", slog.LevelError)
+		if Get("pkg-test").Level() != slog.LevelError || Default().Get("pkg-test").Level() != slog.LevelError {
+			t.Errorf("expected default registry to be updated")
+		}
+	})
+}
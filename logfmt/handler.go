@@ -0,0 +1,338 @@
+// Package logfmt provides a slog.Handler writing records in strict logfmt,
+// for pipelines whose parsers reject the looser output of slog.TextHandler.
+package logfmt
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding"
+	"fmt"
+	"io"
+	"log/slog"
+	"runtime"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// KeyOrder defines the order in which attribute keys are output.
+type KeyOrder string
+
+const (
+	// Sorted outputs attribute keys in lexical order.
+	Sorted = KeyOrder("sorted")
+	// Insertion outputs attribute keys in the order attributes were added.
+	Insertion = KeyOrder("insertion")
+)
+
+// Duplicates defines how attributes sharing a key are resolved.
+type Duplicates string
+
+const (
+	// KeepLast outputs the last of the values of a key.
+	KeepLast = Duplicates("last")
+	// KeepFirst outputs the first of the values of a key.
+	KeepFirst = Duplicates("first")
+	// KeepAll outputs every value of a key, in order.
+	KeepAll = Duplicates("all")
+)
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	mutex  sync.Mutex
+	writer io.Writer
+	config handlerOptions
+}
+
+// Handler is a slog.Handler writing each record as a line of logfmt: the
+// time, level, message and source, when enabled, followed by the
+// attributes, group members keyed by their dotted path. Keys are stripped
+// of the characters logfmt does not allow in them: spaces, '=', '"' and
+// control characters, replaced with '_'. Values are quoted when they are
+// empty or contain such characters, with quotes, backslashes and control
+// characters, newlines included, escaped, so every record takes exactly
+// one line. Output is deterministic: attribute keys are sorted by default
+// and attributes sharing a key are resolved by the duplicates policy, the
+// built-in keys taking precedence over attributes.
+type Handler struct {
+	scope scope.Scope
+	state *state
+}
+
+// NewHandler creates a handler writing to w.
+func NewHandler(w io.Writer, options ...Option) *Handler {
+	config := handlerOptions{
+		level:      slog.LevelInfo,
+		timeFormat: time.RFC3339Nano,
+		keyOrder:   Sorted,
+		duplicates: KeepLast,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{state: &state{writer: w, config: config}}
+}
+
+// Enabled reports whether the level is at or above the minimum level.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.state.config.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// field is a key and its rendered value.
+type field struct {
+	key   string
+	value string
+}
+
+// Handle writes the record as one line.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	config := h.state.config
+
+	var builtins []field
+	if config.timeFormat != "" && !r.Time.IsZero() {
+		builtins = append(builtins, field{slog.TimeKey, r.Time.Format(config.timeFormat)})
+	}
+	builtins = append(builtins, field{slog.LevelKey, r.Level.String()}, field{slog.MessageKey, r.Message})
+	if config.source && r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		builtins = append(builtins, field{slog.SourceKey, frame.File + ":" + strconv.Itoa(frame.Line)})
+	}
+
+	fields := h.appendAttrs(nil, "", h.scope.Attrs(r))
+	fields = resolve(builtins, fields, config.duplicates)
+	if config.keyOrder == Sorted {
+		slices.SortStableFunc(fields, func(a, b field) int { return cmp.Compare(a.key, b.key) })
+	}
+
+	var buf bytes.Buffer
+	for i, f := range append(builtins, fields...) {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		writeKey(&buf, f.key)
+		buf.WriteByte('=')
+		writeValue(&buf, f.value)
+	}
+	buf.WriteByte('\n')
+
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	_, err := h.state.writer.Write(buf.Bytes())
+	return err
+}
+
+// appendAttrs appends the attributes to fields, group members keyed by
+// their dotted path. Empty attributes and groups are skipped, groups with
+// an empty key are inlined.
+func (h *Handler) appendAttrs(fields []field, prefix string, attrs []slog.Attr) []field {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		if a.Value.Kind() == slog.KindGroup {
+			p := prefix
+			if a.Key != "" {
+				p += a.Key + "."
+			}
+			fields = h.appendAttrs(fields, p, a.Value.Group())
+			continue
+		}
+		fields = append(fields, field{prefix + a.Key, h.format(a.Value)})
+	}
+	return fields
+}
+
+func (h *Handler) format(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String()
+	case slog.KindTime:
+		layout := h.state.config.timeFormat
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		return v.Time().Format(layout)
+	case slog.KindAny:
+		switch x := v.Any().(type) {
+		case error:
+			return x.Error()
+		case encoding.TextMarshaler:
+			if b, err := x.MarshalText(); err == nil {
+				return string(b)
+			}
+		case []byte:
+			return string(x)
+		}
+		return fmt.Sprint(v.Any())
+	}
+	return v.String()
+}
+
+// resolve applies the duplicates policy to the attribute fields. Fields
+// sharing a key with a built-in field are dropped unless every value is
+// kept.
+func resolve(builtins, fields []field, d Duplicates) []field {
+	if d == KeepAll {
+		return fields
+	}
+	reserved := make(map[string]bool, len(builtins))
+	for _, f := range builtins {
+		reserved[f.key] = true
+	}
+	last := make(map[string]int, len(fields))
+	for i, f := range fields {
+		if _, ok := last[f.key]; !ok || d == KeepLast {
+			last[f.key] = i
+		}
+	}
+	out := fields[:0:0]
+	for i, f := range fields {
+		if !reserved[f.key] && last[f.key] == i {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func writeKey(buf *bytes.Buffer, key string) {
+	if key == "" {
+		buf.WriteByte('_')
+		return
+	}
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || r == 0x7f {
+			buf.WriteByte('_')
+			continue
+		}
+		buf.WriteRune(r)
+	}
+}
+
+func writeValue(buf *bytes.Buffer, s string) {
+	if !needsQuoting(s) {
+		buf.WriteString(s)
+		return
+	}
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < ' ' || r == 0x7f {
+				fmt.Fprintf(buf, `\u%04x`, r)
+				continue
+			}
+			buf.WriteRune(r)
+		}
+	}
+	buf.WriteByte('"')
+}
+
+func needsQuoting(s string) bool {
+	if s == "" {
+		return true
+	}
+	for _, r := range s {
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == utf8.RuneError || r == 0x7f {
+			return true
+		}
+	}
+	return false
+}
+
+type handlerOptions struct {
+	level      slog.Leveler
+	timeFormat string
+	keyOrder   KeyOrder
+	duplicates Duplicates
+	source     bool
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithTimeFormat sets the layout of the record time and of time values.
+// An empty layout omits the record time. Defaults to time.RFC3339Nano.
+func WithTimeFormat(layout string) Option {
+	return func(h *handlerOptions) {
+		h.timeFormat = layout
+	}
+}
+
+// WithKeyOrder sets the order in which attribute keys are output. Keys
+// are sorted by default; the built-in keys always come first.
+func WithKeyOrder(o KeyOrder) Option {
+	return func(h *handlerOptions) {
+		switch o {
+		case Sorted, Insertion:
+			h.keyOrder = o
+		default:
+			panic(fmt.Sprintf("slogging: unsupported key order %q", o))
+		}
+	}
+}
+
+// WithDuplicates sets how attributes sharing a key are resolved. Defaults
+// to KeepLast.
+func WithDuplicates(d Duplicates) Option {
+	return func(h *handlerOptions) {
+		switch d {
+		case KeepLast, KeepFirst, KeepAll:
+			h.duplicates = d
+		default:
+			panic(fmt.Sprintf("slogging: unsupported duplicates policy %q", d))
+		}
+	}
+}
+
+// WithSource sets whether the file and line of the logging call are
+// written under slog.SourceKey. It is disabled by default.
+func WithSource(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.source = true
+		for i := range x {
+			h.source = x[i]
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package logfmt
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"testing"
+	"time"
+)
+
+func Test_Handler(t *testing.T) {
+	at := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		options []Option
+		derive  func(slog.Handler) slog.Handler
+		msg     string
+		attrs   []any
+		want    string
+	}{
+		"sorted keys": {
+			attrs: []any{"z", 1, "a", true, slog.Group("g", "y", 1.5, "x", time.Second)},
+			want:  "time=2024-01-02T15:04:05Z level=INFO msg=hello a=true g.x=1s g.y=1.5 z=1\n",
+		},
+		"insertion order": {
+			options: []Option{WithKeyOrder(Insertion), WithTimeFormat("")},
+			derive: func(h slog.Handler) slog.Handler {
+				return h.WithAttrs([]slog.Attr{slog.String("svc", "api")}).WithGroup("req")
+			},
+			attrs: []any{"z", 1, "a", 2},
+			want:  "level=INFO msg=hello svc=api req.z=1 req.a=2\n",
+		},
+		"escaping": {
+			options: []Option{WithTimeFormat("")},
+			msg:     "multi\nline \"quoted\"",
+			attrs:   []any{"empty", "", "path", `C:\dir`, "ctl", "a\x00b\tc", "eq", "a=b", "bad key=\"", "utf8 ✓", "plain", "ok✓"},
+			want:    `level=INFO msg="multi\nline \"quoted\"" bad_key__="utf8 ✓" ctl="a\u0000b\tc" empty="" eq="a=b" path="C:\\dir" plain=ok✓` + "\n",
+		},
+		"duplicates keep last": {
+			options: []Option{WithTimeFormat(""), WithKeyOrder(Insertion)},
+			attrs:   []any{"a", 1, "b", 2, "a", 3, "msg", "spoofed"},
+			want:    "level=INFO msg=hello b=2 a=3\n",
+		},
+		"duplicates keep first": {
+			options: []Option{WithTimeFormat(""), WithKeyOrder(Insertion), WithDuplicates(KeepFirst)},
+			attrs:   []any{"a", 1, "b", 2, "a", 3},
+			want:    "level=INFO msg=hello a=1 b=2\n",
+		},
+		"duplicates keep all": {
+			options: []Option{WithTimeFormat(""), WithDuplicates(KeepAll)},
+			attrs:   []any{"a", 3, "b", 2, "a", 1},
+			want:    "level=INFO msg=hello a=3 a=1 b=2\n",
+		},
+		"values": {
+			options: []Option{WithTimeFormat(time.DateOnly)},
+			attrs:   []any{"err", errors.New("no such host"), "ip", net.IPv4(10, 0, 0, 1), "at", at, "bytes", []byte("raw")},
+			want:    "time=2024-01-02 level=INFO msg=hello at=2024-01-02 bytes=raw err=\"no such host\" ip=10.0.0.1\n",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			var h slog.Handler = NewHandler(buf, tc.options...)
+			if tc.derive != nil {
+				h = tc.derive(h)
+			}
+			msg := tc.msg
+			if msg == "" {
+				msg = "hello"
+			}
+			r := slog.NewRecord(at, slog.LevelInfo, msg, 0)
+			r.Add(tc.attrs...)
+			if err := h.Handle(context.Background(), r); err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != tc.want {
+				t.Errorf("unexpected output:\n got: %s\nwant: %s", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("level", func(t *testing.T) {
+		h := NewHandler(nil, WithLevel(slog.LevelWarn))
+		if h.Enabled(context.Background(), slog.LevelInfo) || !h.Enabled(context.Background(), slog.LevelWarn) {
+			t.Errorf("unexpected levels enabled")
+		}
+	})
+}
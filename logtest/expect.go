@@ -0,0 +1,287 @@
+package logtest
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// Matcher reports whether an attribute value is as expected.
+type Matcher func(v slog.Value) bool
+
+// Present returns a Matcher accepting any value.
+func Present() Matcher {
+	return func(slog.Value) bool { return true }
+}
+
+// Matches returns a Matcher accepting the values whose string form
+// matches the regular expression pattern.
+func Matches(pattern string) Matcher {
+	re := regexp.MustCompile(pattern)
+	return func(v slog.Value) bool { return re.MatchString(v.String()) }
+}
+
+type attrExpectation struct {
+	path  string
+	value any
+}
+
+// Expectation describes an expected entry. It is built by chaining
+// conditions on Expect.
+type Expectation struct {
+	level   slog.Level
+	pattern *regexp.Regexp
+	attrs   []attrExpectation
+	traced  *bool
+}
+
+// Expect returns an expectation of an entry at the given level whose
+// message matches the regular expression pattern; an empty pattern
+// matches any message.
+func Expect(level slog.Level, pattern string) *Expectation {
+	e := &Expectation{level: level}
+	if pattern != "" {
+		e.pattern = regexp.MustCompile(pattern)
+	}
+	return e
+}
+
+// Attr adds the expectation of an attribute at path, group names and key
+// joined with dots, equal to value, or accepted by value if it is a
+// Matcher.
+func (e *Expectation) Attr(path string, value any) *Expectation {
+	e.attrs = append(e.attrs, attrExpectation{path: path, value: value})
+	return e
+}
+
+// Traced adds the expectation that the entry was logged with a span in
+// its context, or without one if traced is false.
+func (e *Expectation) Traced(traced ...bool) *Expectation {
+	t := true
+	for i := range traced {
+		t = traced[i]
+	}
+	e.traced = &t
+	return e
+}
+
+// Match reports whether the entry meets the expectation.
+func (e *Expectation) Match(entry Entry) bool {
+	if entry.Level != e.level || e.pattern != nil && !e.pattern.MatchString(entry.Message) {
+		return false
+	}
+	if e.traced != nil && entry.SpanContext.IsValid() != *e.traced {
+		return false
+	}
+	for _, a := range e.attrs {
+		var m Matcher
+		switch v := a.value.(type) {
+		case Matcher:
+			m = v
+		case func(slog.Value) bool:
+			m = v
+		default:
+			if !entry.HasAttr(a.path, v) {
+				return false
+			}
+			continue
+		}
+		if v, ok := entry.Lookup(a.path); !ok || !m(v) {
+			return false
+		}
+	}
+	return true
+}
+
+// String describes the expectation, as in
+// `WARN /payment retried/ order.id=42 traced`.
+func (e *Expectation) String() string {
+	var b strings.Builder
+	b.WriteString(e.level.String())
+	if e.pattern != nil {
+		fmt.Fprintf(&b, " /%s/", e.pattern)
+	}
+	for _, a := range e.attrs {
+		switch a.value.(type) {
+		case Matcher, func(slog.Value) bool:
+			fmt.Fprintf(&b, " %s=<matcher>", a.path)
+		default:
+			fmt.Fprintf(&b, " %s=%v", a.path, a.value)
+		}
+	}
+	if e.traced != nil {
+		if *e.traced {
+			b.WriteString(" traced")
+		} else {
+			b.WriteString(" untraced")
+		}
+	}
+	return b.String()
+}
+
+// Mode is how expectations are matched against entries.
+type Mode int
+
+const (
+	// Ordered requires the expected entries to appear in the order of the
+	// expectations, other entries being allowed between them.
+	Ordered Mode = iota
+	// Unordered requires every expectation to match a distinct entry, in
+	// any order.
+	Unordered
+)
+
+// String returns "ordered" or "unordered".
+func (m Mode) String() string {
+	switch m {
+	case Ordered:
+		return "ordered"
+	case Unordered:
+		return "unordered"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Verify fails the test unless the captured entries meet the expectations
+// in the given mode, reporting a diff of the unmet expectations, prefixed
+// with "-", against the captured entries, those matched prefixed with the
+// number of their expectation. It returns whether they were met.
+func Verify(t testing.TB, h *Handler, mode Mode, expectations ...*Expectation) bool {
+	t.Helper()
+	entries := h.Entries()
+	var matched []int // Entry matched by each expectation, or -1
+	switch mode {
+	case Ordered:
+		matched = matchOrdered(entries, expectations)
+	case Unordered:
+		matched = matchUnordered(entries, expectations)
+	default:
+		panic("slogging: unsupported expectation mode")
+	}
+	if !slices.Contains(matched, -1) {
+		return true
+	}
+	t.Errorf("log expectations not met (%s):\n%s", mode, diff(entries, expectations, matched, nil))
+	return false
+}
+
+// AssertNoErrors fails the test if an entry at or above Error meets none
+// of the allowed expectations, and returns whether none did.
+func AssertNoErrors(t testing.TB, h *Handler, allowed ...*Expectation) bool {
+	t.Helper()
+	entries := h.Entries()
+	unexpected := make(map[int]bool)
+	for i, entry := range entries {
+		if entry.Level < slog.LevelError {
+			continue
+		}
+		ok := false
+		for _, e := range allowed {
+			if e.Match(entry) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			unexpected[i] = true
+		}
+	}
+	if len(unexpected) == 0 {
+		return true
+	}
+	t.Errorf("unexpected error records:\n%s", diff(entries, nil, nil, unexpected))
+	return false
+}
+
+// matchOrdered matches each expectation to the first matching entry after
+// the entry of the previous expectation.
+func matchOrdered(entries []Entry, expectations []*Expectation) []int {
+	matched := make([]int, len(expectations))
+	next := 0
+	for i, e := range expectations {
+		matched[i] = -1
+		for j := next; j < len(entries); j++ {
+			if e.Match(entries[j]) {
+				matched[i], next = j, j+1
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// matchUnordered matches as many expectations as possible to distinct
+// entries, with augmenting paths so that an expectation taking the entry
+// another one needs does not fail the match.
+func matchUnordered(entries []Entry, expectations []*Expectation) []int {
+	candidates := make([][]int, len(expectations))
+	for i, e := range expectations {
+		for j, entry := range entries {
+			if e.Match(entry) {
+				candidates[i] = append(candidates[i], j)
+			}
+		}
+	}
+	owner := make([]int, len(entries)) // Expectation matching each entry, or -1
+	for j := range owner {
+		owner[j] = -1
+	}
+	var augment func(i int, seen []bool) bool
+	augment = func(i int, seen []bool) bool {
+		for _, j := range candidates[i] {
+			if seen[j] {
+				continue
+			}
+			seen[j] = true
+			if owner[j] < 0 || augment(owner[j], seen) {
+				owner[j] = i
+				return true
+			}
+		}
+		return false
+	}
+	for i := range expectations {
+		augment(i, make([]bool, len(entries)))
+	}
+	matched := make([]int, len(expectations))
+	for i := range matched {
+		matched[i] = -1
+	}
+	for j, i := range owner {
+		if i >= 0 {
+			matched[i] = j
+		}
+	}
+	return matched
+}
+
+// diff lists the unmet expectations and the entries, marking those matched
+// by an expectation with its number and the unexpected ones with "+".
+func diff(entries []Entry, expectations []*Expectation, matched []int, unexpected map[int]bool) string {
+	var b strings.Builder
+	by := make(map[int]int)
+	for i, j := range matched {
+		if j < 0 {
+			fmt.Fprintf(&b, "-   #%d %s\n", i+1, expectations[i])
+		} else {
+			by[j] = i
+		}
+	}
+	if len(entries) == 0 {
+		b.WriteString("    (no entries)\n")
+	}
+	for j, entry := range entries {
+		switch i, ok := by[j]; {
+		case ok:
+			fmt.Fprintf(&b, "    #%d %s\n", i+1, entry)
+		case unexpected[j]:
+			fmt.Fprintf(&b, "+      %s\n", entry)
+		default:
+			fmt.Fprintf(&b, "       %s\n", entry)
+		}
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,70 @@
+package logtest
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+func Test_Expect(t *testing.T) {
+	h := New()
+	logger := slog.New(h)
+	traced := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{1},
+	}))
+	logger.InfoContext(traced, "request received", "path", "/pay")
+	logger.Warn("payment retried", "order", 42, "attempt", 1)
+	logger.Warn("payment retried", "order", 42, "attempt", 2)
+	logger.Error("cache unavailable", "err", "dial tcp: refused")
+
+	t.Run("ordered", func(t *testing.T) {
+		Verify(t, h, Ordered,
+			Expect(slog.LevelInfo, "^request").Attr("path", "/pay").Traced(),
+			Expect(slog.LevelWarn, "retried").Attr("attempt", 1).Traced(false),
+			Expect(slog.LevelWarn, "retried").Attr("attempt", Matches("^[0-9]+$")),
+		)
+
+		r := &recorder{TB: t}
+		ok := Verify(r, h, Ordered,
+			Expect(slog.LevelWarn, "retried").Attr("attempt", 2),
+			Expect(slog.LevelWarn, "retried").Attr("attempt", 1),
+		)
+		want := "log expectations not met (ordered):\n" +
+			"-   #2 WARN /retried/ attempt=1\n" +
+			"       level=INFO msg=\"request received\" path=/pay\n" +
+			"       level=WARN msg=\"payment retried\" order=42 attempt=1\n" +
+			"    #1 level=WARN msg=\"payment retried\" order=42 attempt=2\n" +
+			"       level=ERROR msg=\"cache unavailable\" err=\"dial tcp: refused\""
+		if ok || len(r.errors) != 1 || r.errors[0] != want {
+			t.Errorf("failures:\n%s", strings.Join(r.errors, "\n"))
+		}
+	})
+
+	t.Run("unordered", func(t *testing.T) {
+		// The first expectation could take the entry the second one needs.
+		Verify(t, h, Unordered,
+			Expect(slog.LevelWarn, "").Attr("attempt", Present()),
+			Expect(slog.LevelWarn, "").Attr("attempt", 1),
+			Expect(slog.LevelInfo, ""),
+		)
+
+		r := &recorder{TB: t}
+		if Verify(r, h, Unordered, Expect(slog.LevelInfo, ""), Expect(slog.LevelInfo, "")) || len(r.errors) != 1 {
+			t.Errorf("failures: %q", r.errors)
+		}
+	})
+
+	t.Run("no unexpected errors", func(t *testing.T) {
+		AssertNoErrors(t, h, Expect(slog.LevelError, "cache").Attr("err", Matches("refused")))
+
+		r := &recorder{TB: t}
+		if AssertNoErrors(r, h) || len(r.errors) != 1 ||
+			!strings.Contains(r.errors[0], "+      level=ERROR msg=\"cache unavailable\"") {
+			t.Errorf("failures: %q", r.errors)
+		}
+	})
+}
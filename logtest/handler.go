@@ -0,0 +1,263 @@
+// Package logtest provides a slog.Handler capturing records in memory for
+// assertions in tests, with attribute lookups resolving groups and
+// LogValuers, instead of matching text output:
+//
+//	h := logtest.New()
+//	service := NewService(slog.New(h))
+//	service.Charge(ctx, order)
+//	logtest.AssertLogged(t, h, slog.LevelWarn, "payment retried", "order.id", 42)
+//
+// Expectations declare several entries at once, matched in order or not,
+// and report a diff against the captured entries when they are not met:
+//
+//	logtest.Verify(t, h, logtest.Ordered,
+//		logtest.Expect(slog.LevelInfo, "^charging").Traced(),
+//		logtest.Expect(slog.LevelWarn, "retried").Attr("attempt", logtest.Present()),
+//	)
+//	logtest.AssertNoErrors(t, h)
+package logtest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging/filter"
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// Entry is a captured record. Its attributes include those added via
+// WithAttrs, nested under the groups opened via WithGroup.
+type Entry struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	PC      uintptr
+	Attrs   []slog.Attr
+
+	// SpanContext is the span context of the logging context, invalid if
+	// it had no span.
+	SpanContext trace.SpanContext
+}
+
+// Lookup returns the resolved value at path, group names and key joined
+// with dots such as "http.status". Of attributes sharing a key, the last
+// one wins.
+func (e Entry) Lookup(path string) (slog.Value, bool) {
+	return e.view().Lookup(path)
+}
+
+// HasAttr reports whether the entry has an attribute at path equal to
+// value. Numbers of different types are compared by value.
+func (e Entry) HasAttr(path string, value any) bool {
+	return filter.AttrEquals(path, value).Pass().Match(e.view())
+}
+
+// String returns the entry in the text format of slog.TextHandler, without
+// the time.
+func (e Entry) String() string {
+	var b strings.Builder
+	h := slog.NewTextHandler(&b, &slog.HandlerOptions{
+		Level: slog.LevelDebug - 100,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	_ = h.Handle(context.Background(), e.record())
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+func (e Entry) record() slog.Record {
+	r := slog.NewRecord(e.Time, e.Level, e.Message, e.PC)
+	r.AddAttrs(e.Attrs...)
+	return r
+}
+
+func (e Entry) view() *filter.Record {
+	return filter.NewRecord(e.record())
+}
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	mutex   sync.Mutex
+	entries []Entry
+}
+
+// Handler is a slog.Handler capturing records in memory. It is safe for
+// concurrent use; handlers derived with WithAttrs and WithGroup capture
+// into the same entries.
+type Handler struct {
+	state *state
+	scope scope.Scope
+	level slog.Leveler
+}
+
+// New returns a handler capturing records at or above Debug.
+func New(options ...Option) *Handler {
+	config := handlerOptions{level: slog.LevelDebug}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{state: new(state), level: config.level}
+}
+
+// Enabled reports whether level is at or above the level of the handler.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+// Handle captures the record.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	e := Entry{
+		Time:        r.Time,
+		Level:       r.Level,
+		Message:     r.Message,
+		PC:          r.PC,
+		Attrs:       h.scope.Attrs(r),
+		SpanContext: trace.SpanContextFromContext(ctx),
+	}
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	h.state.entries = append(h.state.entries, e)
+	return nil
+}
+
+// WithAttrs returns a new Handler with the attributes added to its scope.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler with the group opened in its scope.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// Entries returns the captured entries, in the order they were handled.
+func (h *Handler) Entries() []Entry {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	out := make([]Entry, len(h.state.entries))
+	copy(out, h.state.entries)
+	return out
+}
+
+// Filter returns the entries at the given level whose message contains
+// msgContains.
+func (h *Handler) Filter(level slog.Level, msgContains string) []Entry {
+	var out []Entry
+	for _, e := range h.Entries() {
+		if e.Level == level && strings.Contains(e.Message, msgContains) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// HasAttr reports whether any entry has an attribute at path equal to
+// value.
+func (h *Handler) HasAttr(path string, value any) bool {
+	for _, e := range h.Entries() {
+		if e.HasAttr(path, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find returns the entries at the given level whose message contains
+// msgContains and which have the attributes of args, alternating paths
+// and values.
+func (h *Handler) Find(level slog.Level, msgContains string, args ...any) []Entry {
+	var out []Entry
+	for _, e := range h.Filter(level, msgContains) {
+		if match(e, args) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+func match(e Entry, args []any) bool {
+	for i := 0; i+1 < len(args); i += 2 {
+		path, ok := args[i].(string)
+		if !ok || !e.HasAttr(path, args[i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Reset discards the captured entries.
+func (h *Handler) Reset() {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	h.state.entries = nil
+}
+
+// AssertLogged fails the test unless an entry at the given level has a
+// message containing msgContains and the attributes of args, alternating
+// paths and values. The captured entries are listed on failure.
+func AssertLogged(t testing.TB, h *Handler, level slog.Level, msgContains string, args ...any) {
+	t.Helper()
+	if len(h.Find(level, msgContains, args...)) == 0 {
+		t.Errorf("no %s record matching %q %v was logged%s", level, msgContains, args, h.dump())
+	}
+}
+
+// AssertNotLogged fails the test if an entry at the given level has a
+// message containing msgContains and the attributes of args.
+func AssertNotLogged(t testing.TB, h *Handler, level slog.Level, msgContains string, args ...any) {
+	t.Helper()
+	if found := h.Find(level, msgContains, args...); len(found) > 0 {
+		t.Errorf("unexpected %s record matching %q %v:\n\t%s", level, msgContains, args, found[0])
+	}
+}
+
+func (h *Handler) dump() string {
+	entries := h.Entries()
+	if len(entries) == 0 {
+		return ", nothing was"
+	}
+	var b strings.Builder
+	b.WriteString(", got:")
+	for _, e := range entries {
+		fmt.Fprintf(&b, "\n\t%s", e)
+	}
+	return b.String()
+}
+
+type handlerOptions struct {
+	level slog.Leveler
+}
+
+// Option configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the minimum level of captured records. Defaults to
+// Debug.
+func WithLevel(level slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = level
+	}
+}
@@ -0,0 +1,84 @@
+package logtest
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type user struct{ id int }
+
+func (u user) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("id", u.id))
+}
+
+// recorder captures the failures of assertions.
+type recorder struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func Test_Handler(t *testing.T) {
+	h := New(WithLevel(slog.LevelInfo))
+	logger := slog.New(h).With("svc", "billing")
+	logger.Debug("hidden")
+	logger.WithGroup("http").Info("request served", "status", 200, "user", user{7})
+	logger.Warn("payment retried", slog.Group("order", "id", int64(42)), "attempt", 2)
+
+	t.Run("entries", func(t *testing.T) {
+		entries := h.Entries()
+		if len(entries) != 2 {
+			t.Fatalf("got %d entries", len(entries))
+		}
+		if got := entries[0].String(); got != "level=INFO msg=\"request served\" svc=billing http.status=200 http.user.id=7" {
+			t.Errorf("String() = %s", got)
+		}
+		if v, ok := entries[0].Lookup("http.user.id"); !ok || v.Int64() != 7 {
+			t.Errorf("Lookup() = %v, %v", v, ok)
+		}
+	})
+
+	t.Run("queries", func(t *testing.T) {
+		if got := h.Filter(slog.LevelWarn, "retried"); len(got) != 1 || got[0].Message != "payment retried" {
+			t.Errorf("Filter() = %v", got)
+		}
+		if got := h.Filter(slog.LevelError, ""); len(got) != 0 {
+			t.Errorf("Filter() = %v", got)
+		}
+		if !h.HasAttr("order.id", 42) || !h.HasAttr("http.status", 200.0) || h.HasAttr("http.status", 500) {
+			t.Error("HasAttr() mismatch")
+		}
+		if got := h.Find(slog.LevelWarn, "", "svc", "billing", "attempt", 2); len(got) != 1 {
+			t.Errorf("Find() = %v", got)
+		}
+	})
+
+	t.Run("assertions", func(t *testing.T) {
+		AssertLogged(t, h, slog.LevelWarn, "payment", "order.id", 42)
+		AssertNotLogged(t, h, slog.LevelDebug, "hidden")
+
+		r := &recorder{TB: t}
+		AssertLogged(r, h, slog.LevelWarn, "payment", "order.id", 43)
+		AssertNotLogged(r, h, slog.LevelInfo, "served")
+		if len(r.errors) != 2 ||
+			!strings.Contains(r.errors[0], `no WARN record matching "payment" [order.id 43] was logged, got:`) ||
+			!strings.Contains(r.errors[0], "\tlevel=WARN msg=\"payment retried\"") ||
+			!strings.Contains(r.errors[1], "unexpected INFO record") {
+			t.Errorf("failures: %q", r.errors)
+		}
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		h.Reset()
+		if len(h.Entries()) != 0 {
+			t.Error("entries left after Reset()")
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package loki
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Encoding is the wire format of push requests.
+type Encoding int
+
+const (
+	// Protobuf sends snappy-compressed protobuf push requests, the format
+	// of Promtail and the Grafana Agent.
+	Protobuf Encoding = iota
+	// JSON sends JSON push requests.
+	JSON
+)
+
+type entry struct {
+	ts   int64 // Unix nanoseconds
+	line string
+}
+
+type stream struct {
+	labels  map[string]string
+	entries []entry
+}
+
+// labelString renders labels in the Prometheus text format Loki expects,
+// e.g. {app="api", level="error"}, with keys sorted.
+func labelString(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(labels[k]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// encode returns the body and content type of a push request.
+func encode(streams []*stream, enc Encoding) ([]byte, string, error) {
+	if enc == JSON {
+		type jsonStream struct {
+			Stream map[string]string `json:"stream"`
+			Values [][2]string       `json:"values"`
+		}
+		req := struct {
+			Streams []jsonStream `json:"streams"`
+		}{Streams: make([]jsonStream, len(streams))}
+		for i, s := range streams {
+			values := make([][2]string, len(s.entries))
+			for j, e := range s.entries {
+				values[j] = [2]string{strconv.FormatInt(e.ts, 10), e.line}
+			}
+			req.Streams[i] = jsonStream{Stream: s.labels, Values: values}
+		}
+		body, err := json.Marshal(req)
+		return body, "application/json", err
+	}
+
+	// logproto.PushRequest{Streams: []StreamAdapter{Labels, Entries: []EntryAdapter{Timestamp, Line}}}
+	var req []byte
+	for _, s := range streams {
+		var sb []byte
+		sb = protowire.AppendTag(sb, 1, protowire.BytesType)
+		sb = protowire.AppendString(sb, labelString(s.labels))
+		for _, e := range s.entries {
+			var ts []byte
+			ts = protowire.AppendTag(ts, 1, protowire.VarintType)
+			ts = protowire.AppendVarint(ts, uint64(e.ts/1e9))
+			ts = protowire.AppendTag(ts, 2, protowire.VarintType)
+			ts = protowire.AppendVarint(ts, uint64(e.ts%1e9))
+			var eb []byte
+			eb = protowire.AppendTag(eb, 1, protowire.BytesType)
+			eb = protowire.AppendBytes(eb, ts)
+			eb = protowire.AppendTag(eb, 2, protowire.BytesType)
+			eb = protowire.AppendString(eb, e.line)
+			sb = protowire.AppendTag(sb, 2, protowire.BytesType)
+			sb = protowire.AppendBytes(sb, eb)
+		}
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, sb)
+	}
+	return snappy.Encode(nil, req), "application/x-protobuf", nil
+}
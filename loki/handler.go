@@ -0,0 +1,448 @@
+// Package loki provides a slog.Handler pushing records to Grafana Loki.
+package loki
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/internal/jsonvalue"
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/internal/stats"
+)
+
+// ErrClosed is returned by Handle after the handler has been closed.
+var ErrClosed = errors.New("slogging: loki handler is closed")
+
+const (
+	defaultBatchSize  = 1000
+	defaultBatchWait  = time.Second
+	defaultMaxRetries = 5
+	defaultMinBackoff = 500 * time.Millisecond
+	defaultMaxBackoff = 30 * time.Second
+)
+
+// batch accumulates entries per stream. It is shared across WithAttrs and
+// WithGroup derivations and drained by a single delivery goroutine.
+type batch struct {
+	mutex   sync.Mutex
+	streams map[string]*stream
+	size    int
+	dropped uint64
+	closed  bool
+
+	sendLock chan struct{}      // Serializes pushes, held while it holds a value
+	ctx      context.Context    // Context of the delivery goroutine
+	cancel   context.CancelFunc // Cancels ctx when Close gives up
+	kick     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	stats    stats.Recorder
+}
+
+// Handler is a slog.Handler that batches records and pushes them to the
+// Loki push API. Top-level attributes selected with WithLabelKeys become
+// stream labels, together with the static labels of WithLabels; the
+// remaining attributes, the level and the message form the log line, a
+// JSON object of the attributes as added by slogging.PutAttrs, with the
+// "level" and "msg" keys taking precedence. Errors, text marshalers and
+// stringers are encoded as strings, as by slog.JSONHandler. The time of
+// the record is the timestamp of the entry.
+//
+// Batches are pushed when they reach the batch size or after the batch
+// wait, from a background goroutine. Failed pushes are retried with
+// exponential backoff on network errors, 429 and 5xx responses; batches
+// still failing are dropped and reported to the error handler. Close must
+// be called to push the pending records and stop the goroutine.
+type Handler struct {
+	scope  scope.Scope
+	config handlerOptions
+	batch  *batch
+}
+
+// NewHandler creates a handler pushing to endpoint, the URL of the push
+// API, e.g. "http://loki:3100/loki/api/v1/push", and starts its delivery
+// goroutine.
+func NewHandler(endpoint string, options ...Option) *Handler {
+	config := handlerOptions{
+		endpoint:   endpoint,
+		client:     http.DefaultClient,
+		level:      slog.LevelInfo,
+		batchSize:  defaultBatchSize,
+		batchWait:  defaultBatchWait,
+		maxRetries: defaultMaxRetries,
+		minBackoff: defaultMinBackoff,
+		maxBackoff: defaultMaxBackoff,
+		labelKeys:  map[string]bool{},
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &batch{
+		streams:  make(map[string]*stream),
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		sendLock: make(chan struct{}, 1),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	h := &Handler{config: config, batch: b}
+	go h.run()
+	return h
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.config.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// Handle adds the record to the batch of its stream.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	h.batch.stats.Handled(1)
+	labels := make(map[string]string, len(h.config.labels)+len(h.config.labelKeys))
+	for k, v := range h.config.labels {
+		labels[k] = v
+	}
+	line := make(map[string]any, r.NumAttrs()+2)
+	h.put(line, labels, h.scope.Attrs(r))
+	jsonvalue.Map(line)
+	if h.config.labelKeys[slog.LevelKey] {
+		labels[slog.LevelKey] = r.Level.String()
+		delete(line, slog.LevelKey)
+	} else {
+		line[slog.LevelKey] = r.Level
+	}
+	line[slog.MessageKey] = r.Message
+
+	b, err := json.Marshal(line)
+	if err != nil {
+		return fmt.Errorf("error when marshaling Loki line: %w", err)
+	}
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return h.add(labels, entry{ts: t.UnixNano(), line: string(b)})
+}
+
+// put adds attrs to m, moving top-level label keys to labels.
+func (h *Handler) put(m map[string]any, labels map[string]string, attrs []slog.Attr) {
+	for _, a := range attrs {
+		if h.config.labelKeys[a.Key] {
+			labels[labelName(a.Key)] = a.Value.Resolve().String()
+			continue
+		}
+		slogging.PutAttrs(m, a)
+	}
+}
+
+// labelName converts key to a valid label name, replacing characters other
+// than letters, digits and underscores with underscores.
+func labelName(key string) string {
+	b := []byte(key)
+	for i, c := range b {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func (h *Handler) add(labels map[string]string, e entry) error {
+	b := h.batch
+	key := labelString(labels)
+	b.mutex.Lock()
+	if b.closed {
+		b.mutex.Unlock()
+		return ErrClosed
+	}
+	s, ok := b.streams[key]
+	if !ok {
+		s = &stream{labels: labels}
+		b.streams[key] = s
+	}
+	s.entries = append(s.entries, e)
+	b.size++
+	full := b.size >= h.config.batchSize
+	b.mutex.Unlock()
+	if full {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+func (h *Handler) run() {
+	b := h.batch
+	defer close(b.done)
+	ticker := time.NewTicker(h.config.batchWait)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.kick:
+		case <-b.stop:
+			return
+		}
+		h.push(b.ctx)
+	}
+}
+
+// take removes and returns the pending streams.
+func (h *Handler) take() ([]*stream, int) {
+	b := h.batch
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	streams := make([]*stream, 0, len(b.streams))
+	for _, s := range b.streams {
+		streams = append(streams, s)
+	}
+	n := b.size
+	b.streams = make(map[string]*stream)
+	b.size = 0
+	return streams, n
+}
+
+// push sends the pending streams, retrying with backoff until ctx is done.
+func (h *Handler) push(ctx context.Context) error {
+	b := h.batch
+	select {
+	case b.sendLock <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-b.sendLock }()
+	streams, n := h.take()
+	if n == 0 {
+		return nil
+	}
+	err := h.send(ctx, streams)
+	if err != nil {
+		b.mutex.Lock()
+		b.dropped += uint64(n)
+		b.mutex.Unlock()
+		h.batch.stats.Error(err)
+		if h.config.onError != nil {
+			h.config.onError(err)
+		}
+	}
+	return err
+}
+
+func (h *Handler) send(ctx context.Context, streams []*stream) error {
+	body, contentType, err := encode(streams, h.config.encoding)
+	if err != nil {
+		return fmt.Errorf("error when encoding Loki push request: %w", err)
+	}
+	backoff := h.config.minBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := h.post(ctx, body, contentType)
+		if err == nil || !retry || attempt >= h.config.maxRetries {
+			return err
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w: %w", err, ctx.Err())
+		}
+		backoff = min(2*backoff, h.config.maxBackoff)
+	}
+}
+
+// post sends one push request and reports whether a failure is worth
+// retrying.
+func (h *Handler) post(ctx context.Context, body []byte, contentType string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.config.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("error when creating Loki push request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	if h.config.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", h.config.tenant)
+	}
+	resp, err := h.config.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("error when pushing to Loki: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("error when pushing to Loki: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// Flush pushes the pending records, retrying until ctx is done.
+func (h *Handler) Flush(ctx context.Context) error {
+	return h.push(ctx)
+}
+
+// Close stops accepting records, pushes the pending ones, retrying until
+// ctx is done, and stops the delivery goroutine.
+// When ctx is done first, the delivery in progress is cancelled, the
+// pending records are abandoned and Close returns ctx.Err().
+func (h *Handler) Close(ctx context.Context) error {
+	b := h.batch
+	b.mutex.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.stop)
+	}
+	b.mutex.Unlock()
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		b.cancel()
+		return ctx.Err()
+	}
+	return h.push(ctx)
+}
+
+// Dropped returns the number of records dropped after failed pushes.
+func (h *Handler) Dropped() uint64 {
+	h.batch.mutex.Lock()
+	defer h.batch.mutex.Unlock()
+	return h.batch.dropped
+}
+
+// Stats returns the counters of the handler. The queue depth is the number
+// of records waiting for the next delivery.
+func (h *Handler) Stats() slogging.Stats {
+	b := h.batch
+	b.mutex.Lock()
+	dropped, depth := b.dropped, b.size
+	b.mutex.Unlock()
+	return b.stats.Stats(dropped, depth)
+}
+
+type handlerOptions struct {
+	endpoint   string
+	client     *http.Client
+	level      slog.Leveler
+	labels     map[string]string
+	labelKeys  map[string]bool
+	encoding   Encoding
+	tenant     string
+	batchSize  int
+	batchWait  time.Duration
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+	onError    func(error)
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithLabels sets static labels of every stream, e.g. the application and
+// environment.
+func WithLabels(labels map[string]string) Option {
+	return func(h *handlerOptions) {
+		h.labels = labels
+	}
+}
+
+// WithLabelKeys promotes the top-level attributes with the given keys to
+// stream labels instead of the log line. slog.LevelKey promotes the level.
+// Labels should have few distinct values: every label set is a separate
+// stream in Loki.
+func WithLabelKeys(keys ...string) Option {
+	return func(h *handlerOptions) {
+		for _, k := range keys {
+			h.labelKeys[k] = true
+		}
+	}
+}
+
+// WithEncoding sets the wire format of push requests, Protobuf by default.
+func WithEncoding(e Encoding) Option {
+	return func(h *handlerOptions) {
+		h.encoding = e
+	}
+}
+
+// WithTenant sets the tenant ID sent as X-Scope-OrgID for multi-tenant Loki.
+func WithTenant(id string) Option {
+	return func(h *handlerOptions) {
+		h.tenant = id
+	}
+}
+
+// WithHTTPClient sets the client used to push, http.DefaultClient by
+// default.
+func WithHTTPClient(c *http.Client) Option {
+	return func(h *handlerOptions) {
+		h.client = c
+	}
+}
+
+// WithBatch sets the number of records triggering a push, 1000 by default,
+// and the maximum time records wait for a push, one second by default.
+func WithBatch(size int, wait time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.batchSize = max(size, 1)
+		h.batchWait = wait
+	}
+}
+
+// WithRetry sets the number of retries of a failed push, 5 by default, and
+// the bounds of the exponential backoff between them, 500ms and 30s by
+// default.
+func WithRetry(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.maxRetries = maxRetries
+		h.minBackoff = minBackoff
+		h.maxBackoff = maxBackoff
+	}
+}
+
+// WithErrorHandler sets a function called with push errors, after retries
+// are exhausted. It is called from the delivery goroutine, or from Flush
+// and Close.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
@@ -0,0 +1,214 @@
+package loki
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type pushServer struct {
+	mutex    sync.Mutex
+	requests []*http.Request
+	bodies   [][]byte
+	statuses []int
+}
+
+func (s *pushServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, body)
+	status := http.StatusNoContent
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (s *pushServer) count() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.requests)
+}
+
+type jsonPush struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("json push with labels", func(t *testing.T) {
+		srv := &pushServer{}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		h := NewHandler(ts.URL, WithEncoding(JSON), WithTenant("team-a"), WithBatch(2, time.Hour),
+			WithLabels(map[string]string{"app": "api"}), WithLabelKeys("service.name", slog.LevelKey))
+		logger := slog.New(h).With("service.name", "billing")
+
+		logger.WithGroup("g").Info("first", "k", "v")
+		logger.Warn("second")
+		deadline := time.Now().Add(5 * time.Second)
+		for srv.count() == 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if srv.count() != 1 {
+			t.Fatalf("expected one batched push, got %d", srv.count())
+		}
+		r := srv.requests[0]
+		if r.Header.Get("X-Scope-OrgID") != "team-a" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected headers: %v", r.Header)
+		}
+		var push jsonPush
+		if err := json.Unmarshal(srv.bodies[0], &push); err != nil {
+			t.Fatal(err)
+		}
+		lines := map[string]string{}
+		for _, s := range push.Streams {
+			if s.Stream["app"] != "api" || s.Stream["service_name"] != "billing" || len(s.Values) != 1 {
+				t.Errorf("unexpected stream: %+v", s)
+			}
+			lines[s.Stream["level"]] = s.Values[0][1]
+		}
+		if lines["INFO"] != `{"g":{"k":"v"},"msg":"first"}` || lines["WARN"] != `{"msg":"second"}` {
+			t.Errorf("unexpected lines: %v", lines)
+		}
+	})
+
+	t.Run("errors are encoded as messages", func(t *testing.T) {
+		srv := &pushServer{}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		h := NewHandler(ts.URL, WithEncoding(JSON), WithBatch(10, time.Hour))
+
+		slog.New(h).WithGroup("g").Error("failed", "err", errors.New("boom"))
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		var push jsonPush
+		if err := json.Unmarshal(srv.bodies[0], &push); err != nil {
+			t.Fatal(err)
+		}
+		want := `{"g":{"err":"boom"},"level":"ERROR","msg":"failed"}`
+		if got := push.Streams[0].Values[0][1]; got != want {
+			t.Errorf("expected %s, got %s", want, got)
+		}
+	})
+
+	t.Run("protobuf", func(t *testing.T) {
+		srv := &pushServer{}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		h := NewHandler(ts.URL, WithLabels(map[string]string{"app": "api"}))
+
+		slog.New(h).Info("test message")
+		if err := h.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		defer h.Close(context.Background())
+
+		if srv.requests[0].Header.Get("Content-Type") != "application/x-protobuf" {
+			t.Errorf("unexpected content type: %s", srv.requests[0].Header.Get("Content-Type"))
+		}
+		raw, err := snappy.Decode(nil, srv.bodies[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		stream := field(t, raw, 1)
+		if labels := string(field(t, stream, 1)); labels != `{app="api"}` {
+			t.Errorf("unexpected labels: %s", labels)
+		}
+		if line := string(field(t, field(t, stream, 2), 2)); line != `{"level":"INFO","msg":"test message"}` {
+			t.Errorf("unexpected line: %s", line)
+		}
+	})
+
+	t.Run("retries", func(t *testing.T) {
+		srv := &pushServer{statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		h := NewHandler(ts.URL, WithRetry(3, time.Millisecond, 2*time.Millisecond))
+
+		slog.New(h).Info("test message")
+		if err := h.Close(context.Background()); err != nil || srv.count() != 3 || h.Dropped() != 0 {
+			t.Errorf("expected delivery on third attempt, got %d attempts: %v", srv.count(), err)
+		}
+	})
+
+	t.Run("close gives up when the context is done", func(t *testing.T) {
+		srv := &pushServer{statuses: []int{503, 503, 503, 503, 503, 503}}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		h := NewHandler(ts.URL, WithBatch(1, time.Hour), WithRetry(5, time.Second, 2*time.Second))
+
+		slog.New(h).Info("test message")
+		deadline := time.Now().Add(5 * time.Second)
+		for srv.count() == 0 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		if err := h.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got %v", err)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("close took %s", d)
+		}
+	})
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		srv := &pushServer{statuses: []int{http.StatusBadRequest}}
+		ts := httptest.NewServer(srv)
+		defer ts.Close()
+		var reported error
+		h := NewHandler(ts.URL, WithRetry(3, time.Millisecond, time.Millisecond), WithErrorHandler(func(err error) { reported = err }))
+
+		slog.New(h).Info("test message")
+		err := h.Close(context.Background())
+		if err == nil || reported == nil || !strings.Contains(err.Error(), "400") || srv.count() != 1 || h.Dropped() != 1 {
+			t.Errorf("unexpected result after %d attempts: %v", srv.count(), err)
+		}
+		if err := h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "late", 0)); err != ErrClosed {
+			t.Errorf("expected ErrClosed, got %v", err)
+		}
+	})
+}
+
+// field returns the first length-delimited field with the given number.
+func field(t *testing.T, b []byte, num protowire.Number) []byte {
+	t.Helper()
+	for len(b) > 0 {
+		n, typ, l := protowire.ConsumeTag(b)
+		b = b[l:]
+		if typ == protowire.BytesType {
+			v, l := protowire.ConsumeBytes(b)
+			if n == num {
+				return v
+			}
+			b = b[l:]
+			continue
+		}
+		b = b[protowire.ConsumeFieldValue(n, typ, b):]
+	}
+	t.Fatalf("field %d not found", num)
+	return nil
+}
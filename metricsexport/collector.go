@@ -0,0 +1,170 @@
+// Package metricsexport exposes the Stats of logging handlers as Prometheus
+// metrics, to monitor the logging pipeline itself.
+package metricsexport
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mikluko/slogging"
+)
+
+// ContentType is the media type of the Prometheus text exposition format
+// written by a Collector.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Collector gathers the Stats of named handlers and writes them in the
+// Prometheus text exposition format, with the name of each handler as the
+// "handler" label:
+//
+//	slogging_records_handled_total        counter
+//	slogging_records_dropped_total        counter
+//	slogging_delivery_errors_total        counter
+//	slogging_queue_depth                  gauge
+//	slogging_last_error_timestamp_seconds gauge, 0 without errors
+//
+// It is an http.Handler serving the metrics, to be mounted next to the
+// metrics endpoint of the application, and it writes them with WriteTo to
+// be appended to the output of another registry. It does not depend on the
+// Prometheus client library.
+type Collector struct {
+	namespace string
+
+	mutex     sync.Mutex
+	reporters map[string]slogging.StatsReporter
+}
+
+// NewCollector creates an empty collector.
+func NewCollector(options ...Option) *Collector {
+	config := handlerOptions{namespace: "slogging"}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Collector{namespace: config.namespace, reporters: make(map[string]slogging.StatsReporter)}
+}
+
+// Register adds a handler under the given name, replacing the handler
+// registered under the same name, if any.
+func (c *Collector) Register(name string, r slogging.StatsReporter) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.reporters[name] = r
+}
+
+// Unregister removes the handler registered under the given name.
+func (c *Collector) Unregister(name string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.reporters, name)
+}
+
+// Gather returns the stats of the registered handlers by name.
+func (c *Collector) Gather() map[string]slogging.Stats {
+	c.mutex.Lock()
+	reporters := make(map[string]slogging.StatsReporter, len(c.reporters))
+	for name, r := range c.reporters {
+		reporters[name] = r
+	}
+	c.mutex.Unlock()
+	out := make(map[string]slogging.Stats, len(reporters))
+	for name, r := range reporters {
+		out[name] = r.Stats()
+	}
+	return out
+}
+
+type metric struct {
+	name  string
+	kind  string
+	help  string
+	value func(s slogging.Stats) float64
+}
+
+var metrics = []metric{
+	{"records_handled_total", "counter", "Records passed to the handler.", func(s slogging.Stats) float64 { return float64(s.Handled) }},
+	{"records_dropped_total", "counter", "Records discarded by the handler.", func(s slogging.Stats) float64 { return float64(s.Dropped) }},
+	{"delivery_errors_total", "counter", "Failed deliveries, after retries.", func(s slogging.Stats) float64 { return float64(s.Errors) }},
+	{"queue_depth", "gauge", "Records waiting to be delivered.", func(s slogging.Stats) float64 { return float64(s.QueueDepth) }},
+	{"last_error_timestamp_seconds", "gauge", "Time of the last failed delivery, 0 without errors.", func(s slogging.Stats) float64 {
+		if s.LastErrorTime.IsZero() {
+			return 0
+		}
+		return float64(s.LastErrorTime.UnixNano()) / 1e9
+	}},
+}
+
+// WriteTo writes the metrics of the registered handlers to w, sorted by
+// handler name.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	stats := c.Gather()
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	for _, m := range metrics {
+		name := m.name
+		if c.namespace != "" {
+			name = c.namespace + "_" + name
+		}
+		fmt.Fprintf(cw, "# HELP %s %s\n# TYPE %s %s\n", name, m.help, name, m.kind)
+		for _, n := range names {
+			fmt.Fprintf(cw, "%s{handler=\"%s\"} %g\n", name, escape(n), m.value(stats[n]))
+		}
+	}
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP writes the metrics of the registered handlers.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_, _ = c.WriteTo(w)
+}
+
+// escape escapes a label value as required by the text format.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	w.err = err
+	return n, err
+}
+
+type handlerOptions struct {
+	namespace string
+}
+
+// Option is a function that configures a Collector.
+type Option func(h *handlerOptions)
+
+// WithNamespace sets the prefix of the metric names, "slogging" by
+// default. An empty namespace leaves the names unprefixed.
+func WithNamespace(ns string) Option {
+	return func(h *handlerOptions) {
+		h.namespace = ns
+	}
+}
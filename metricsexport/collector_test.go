@@ -0,0 +1,80 @@
+package metricsexport
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/async"
+)
+
+type fixed slogging.Stats
+
+func (f fixed) Stats() slogging.Stats {
+	return slogging.Stats(f)
+}
+
+func Test_Collector(t *testing.T) {
+	t.Run("metrics of registered handlers", func(t *testing.T) {
+		c := NewCollector()
+		c.Register(`ship"er`, fixed{Handled: 10, Dropped: 2, Errors: 1, QueueDepth: 3, LastError: errors.New("boom"), LastErrorTime: time.Unix(1700000000, 0)})
+		c.Register("idle", fixed{})
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+			t.Errorf("content type = %q", ct)
+		}
+		out := rec.Body.String()
+		for _, want := range []string{
+			"# TYPE slogging_records_handled_total counter\n",
+			`slogging_records_handled_total{handler="idle"} 0` + "\n" + `slogging_records_handled_total{handler="ship\"er"} 10`,
+			`slogging_records_dropped_total{handler="ship\"er"} 2`,
+			`slogging_delivery_errors_total{handler="ship\"er"} 1`,
+			"# TYPE slogging_queue_depth gauge\n",
+			`slogging_queue_depth{handler="ship\"er"} 3`,
+			`slogging_last_error_timestamp_seconds{handler="ship\"er"} 1.7e+09`,
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("missing %q in:\n%s", want, out)
+			}
+		}
+		c.Unregister("idle")
+		if _, ok := c.Gather()["idle"]; ok {
+			t.Error("unregistered handler still gathered")
+		}
+	})
+
+	t.Run("async handler stats", func(t *testing.T) {
+		boom := errors.New("boom")
+		h := async.Wrap(failing{boom})
+		logger := slog.New(h)
+		logger.Info("one")
+		logger.Info("two")
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		c := NewCollector(WithNamespace("app_log"))
+		c.Register("async", h)
+		s := c.Gather()["async"]
+		if s.Handled != 2 || s.Errors != 2 || !errors.Is(s.LastError, boom) || s.QueueDepth != 0 {
+			t.Errorf("stats = %+v", s)
+		}
+		n, err := c.WriteTo(io.Discard)
+		if err != nil || n == 0 {
+			t.Errorf("WriteTo = %d, %v", n, err)
+		}
+	})
+}
+
+type failing struct{ err error }
+
+func (f failing) Enabled(context.Context, slog.Level) bool  { return true }
+func (f failing) Handle(context.Context, slog.Record) error { return f.err }
+func (f failing) WithAttrs([]slog.Attr) slog.Handler        { return f }
+func (f failing) WithGroup(string) slog.Handler             { return f }
@@ -0,0 +1,38 @@
+package slogging
+
+import (
+	"log/slog"
+)
+
+// Middleware wraps a slog.Handler, returning a handler that delegates to it.
+type Middleware func(slog.Handler) slog.Handler
+
+// Chain composes middlewares into one. The first middleware is the
+// outermost: Chain(a, b, c)(base) is equivalent to a(b(c(base))), so
+// records pass through a, then b, then c before reaching base.
+//
+// Loggers derived via With and WithGroup call WithAttrs and WithGroup on
+// the outermost handler only; every handler in this module propagates them
+// to the handler it wraps, which keeps group handling consistent along the
+// whole chain. Nil middlewares are skipped.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(handler slog.Handler) slog.Handler {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			if middlewares[i] != nil {
+				handler = middlewares[i](handler)
+			}
+		}
+		return handler
+	}
+}
+
+// Use adapts a wrapper constructor following the convention of this module,
+// func(slog.Handler, ...Option) *Handler, to a Middleware applying the given
+// options:
+//
+//	slogging.Chain(slogging.Use(otel.Wrap, otel.WithSampled()), slogging.Use(async.Wrap))(base)
+func Use[H slog.Handler, O any](wrap func(slog.Handler, ...O) H, options ...O) Middleware {
+	return func(handler slog.Handler) slog.Handler {
+		return wrap(handler, options...)
+	}
+}
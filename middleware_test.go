@@ -0,0 +1,79 @@
+package slogging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/mikluko/slogging/otel"
+)
+
+// tagHandler appends its tag to the "path" attribute of every record to
+// reveal the order in which handlers see records.
+type tagHandler struct {
+	slog.Handler
+	tag string
+}
+
+func (h tagHandler) Handle(ctx context.Context, r slog.Record) error {
+	path := ""
+	r2 := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == "path" {
+			path = a.Value.String()
+			return true
+		}
+		r2.AddAttrs(a)
+		return true
+	})
+	r2.AddAttrs(slog.String("path", path+h.tag))
+	return h.Handler.Handle(ctx, r2)
+}
+
+func tag(name string) Middleware {
+	return func(h slog.Handler) slog.Handler {
+		return tagHandler{h, name}
+	}
+}
+
+func Test_Chain(t *testing.T) {
+	t.Run("first middleware is outermost", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Chain(tag("a"), nil, tag("b"), tag("c"))(slog.NewTextHandler(buf, nil)))
+		logger.Info("hello")
+		if !strings.Contains(buf.String(), "path=abc") {
+			t.Errorf("unexpected order: %s", buf.String())
+		}
+	})
+
+	t.Run("empty chain returns base", func(t *testing.T) {
+		base := slog.NewTextHandler(new(bytes.Buffer), nil)
+		if Chain()(base) != slog.Handler(base) {
+			t.Errorf("expected base handler to be returned")
+		}
+	})
+
+	t.Run("module wrappers compose with groups", func(t *testing.T) {
+		tracer := sdktrace.NewTracerProvider().Tracer("test-tracer")
+		ctx, span := tracer.Start(context.Background(), "test-span")
+		defer span.End()
+
+		buf := new(bytes.Buffer)
+		handler := Chain(
+			Use(otel.Wrap, otel.WithSampled()),
+			tag("x"),
+		)(slog.NewTextHandler(buf, nil))
+		slog.New(handler).With("svc", "api").WithGroup("req").InfoContext(ctx, "hello", "id", 1)
+
+		out := buf.String()
+		for _, s := range []string{"otel.sampled=true", "svc=api", "req.id=1"} {
+			if !strings.Contains(out, s) {
+				t.Errorf("expected %q in output: %s", s, out)
+			}
+		}
+	})
+}
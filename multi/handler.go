@@ -0,0 +1,81 @@
+package multi
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+)
+
+// Handler is a slog.Handler that dispatches every record to all of its
+// child handlers. WithAttrs and WithGroup are propagated to every child,
+// and errors returned by children are aggregated with errors.Join.
+type Handler struct {
+	handlers []slog.Handler
+}
+
+// New creates a fan-out handler delivering records to all given handlers.
+// Nil handlers are ignored.
+func New(handlers ...slog.Handler) *Handler {
+	hs := make([]slog.Handler, 0, len(handlers))
+	for _, h := range handlers {
+		if h != nil {
+			hs = append(hs, h)
+		}
+	}
+	return &Handler{handlers: hs}
+}
+
+// Handlers returns the child handlers.
+func (h *Handler) Handlers() []slog.Handler {
+	return append([]slog.Handler(nil), h.handlers...)
+}
+
+// Enabled reports whether any child handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, c := range h.handlers {
+		if c.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle delivers the record to every child enabled at its level. Each
+// child receives its own clone of the record. All children are attempted
+// even if some of them fail.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	for _, c := range h.handlers {
+		if !c.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := c.Handle(ctx, r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs returns a new Handler whose children include the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	hs := make([]slog.Handler, len(h.handlers))
+	for i, c := range h.handlers {
+		hs[i] = c.WithAttrs(attrs)
+	}
+	return &Handler{handlers: hs}
+}
+
+// WithGroup returns a new Handler whose children start the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	hs := make([]slog.Handler, len(h.handlers))
+	for i, c := range h.handlers {
+		hs[i] = c.WithGroup(name)
+	}
+	return &Handler{handlers: hs}
+}
@@ -0,0 +1,76 @@
+package multi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type failingHandler struct {
+	slog.Handler
+	err error
+}
+
+func (h failingHandler) Handle(context.Context, slog.Record) error {
+	return h.err
+}
+
+func Test_Multi(t *testing.T) {
+	t.Run("records are delivered to all handlers", func(t *testing.T) {
+		text := new(bytes.Buffer)
+		json := new(bytes.Buffer)
+		logger := slog.New(New(slog.NewTextHandler(text, nil), slog.NewJSONHandler(json, nil)))
+
+		logger.With("svc", "api").WithGroup("req").Info("hello", "id", 7)
+
+		if !strings.Contains(text.String(), "svc=api req.id=7") {
+			t.Errorf("unexpected text output: %s", text.String())
+		}
+		if !strings.Contains(json.String(), `"svc":"api","req":{"id":7}`) {
+			t.Errorf("unexpected json output: %s", json.String())
+		}
+	})
+
+	t.Run("per-handler levels are respected", func(t *testing.T) {
+		debug := new(bytes.Buffer)
+		warn := new(bytes.Buffer)
+		handler := New(
+			slog.NewTextHandler(debug, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			slog.NewTextHandler(warn, &slog.HandlerOptions{Level: slog.LevelWarn}),
+		)
+		if !handler.Enabled(context.Background(), slog.LevelDebug) {
+			t.Fatalf("expected debug to be enabled")
+		}
+		slog.New(handler).Debug("details")
+		if debug.Len() == 0 {
+			t.Errorf("expected debug output")
+		}
+		if warn.Len() != 0 {
+			t.Errorf("unexpected warn output: %s", warn.String())
+		}
+	})
+
+	t.Run("errors are aggregated", func(t *testing.T) {
+		err1 := errors.New("first")
+		err2 := errors.New("second")
+		buf := new(bytes.Buffer)
+		handler := New(
+			failingHandler{slog.NewTextHandler(buf, nil), err1},
+			slog.NewTextHandler(buf, nil),
+			failingHandler{slog.NewTextHandler(buf, nil), err2},
+		)
+		err := handler.Handle(context.Background(), slog.NewRecord(testTime, slog.LevelInfo, "msg", 0))
+		if !errors.Is(err, err1) || !errors.Is(err, err2) {
+			t.Errorf("expected both errors, got: %v", err)
+		}
+		if buf.Len() == 0 {
+			t.Errorf("expected healthy handler to receive the record")
+		}
+	})
+}
+
+var testTime = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
@@ -0,0 +1,102 @@
+package slogging
+
+import (
+	"log/slog"
+)
+
+// NameKey is the attribute holding the name of a logger created with Named.
+const NameKey = "logger.name"
+
+// derivation is a WithAttrs or WithGroup call made on a named logger,
+// recorded so that it can be replayed when the logger is renamed.
+type derivation struct {
+	group string
+	attrs []slog.Attr
+}
+
+// applyDerivations replays derivations on handler.
+func applyDerivations(handler slog.Handler, derivations []derivation) slog.Handler {
+	for _, d := range derivations {
+		if d.group != "" {
+			handler = handler.WithGroup(d.group)
+		} else {
+			handler = handler.WithAttrs(d.attrs)
+		}
+	}
+	return handler
+}
+
+// namedHandler carries the name of a logger through With and WithGroup
+// derivations. The name attribute is added to the handler the logger was
+// created from before any group of the named logger is opened, so it is
+// rendered at the same level as the logger's other attributes regardless
+// of the groups opened later.
+type namedHandler struct {
+	slog.Handler
+	base        slog.Handler
+	name        string
+	derivations []derivation
+}
+
+// Named returns a logger whose records carry name as the "logger.name"
+// attribute, mirroring the named loggers of zap and logrus. Naming an
+// already named logger appends name to the existing one, separated by a
+// dot: Named(Named(l, "server"), "http") is named "server.http". Derived
+// loggers keep the name.
+func Named(logger *slog.Logger, name string) *slog.Logger {
+	base := logger.Handler()
+	var derivations []derivation
+	if h, ok := base.(*namedHandler); ok {
+		base, derivations = h.base, h.derivations
+		if h.name != "" && name != "" {
+			name = h.name + "." + name
+		} else if name == "" {
+			name = h.name
+		}
+	}
+	handler := applyDerivations(base.WithAttrs([]slog.Attr{slog.String(NameKey, name)}), derivations)
+	return slog.New(&namedHandler{
+		Handler:     handler,
+		base:        base,
+		name:        name,
+		derivations: derivations,
+	})
+}
+
+// Name returns the name of a logger created with Named or returned by
+// Registry.Get, or an empty string.
+func Name(logger *slog.Logger) string {
+	switch h := logger.Handler().(type) {
+	case *namedHandler:
+		return h.name
+	case *registryHandler:
+		return h.node.name
+	}
+	return ""
+}
+
+func (h *namedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.derive(h.Handler.WithAttrs(attrs), derivation{attrs: attrs})
+}
+
+func (h *namedHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.derive(h.Handler.WithGroup(name), derivation{group: name})
+}
+
+func (h *namedHandler) derive(handler slog.Handler, d derivation) *namedHandler {
+	derivations := make([]derivation, len(h.derivations)+1)
+	copy(derivations, h.derivations)
+	derivations[len(h.derivations)] = d
+	return &namedHandler{
+		Handler:     handler,
+		base:        h.base,
+		name:        h.name,
+		derivations: derivations,
+	}
+}
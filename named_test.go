@@ -0,0 +1,40 @@
+package slogging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_Named(t *testing.T) {
+	t.Run("name survives derivations", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := Named(slog.New(slog.NewTextHandler(buf, nil)), "server").With("k", "v").WithGroup("req")
+
+		logger.Info("test message", "id", 1)
+
+		if !strings.Contains(buf.String(), `logger.name=server k=v req.id=1`) {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("nested names are joined", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		server := Named(slog.New(slog.NewTextHandler(buf, nil)), "server").WithGroup("g").With("k", "v")
+		http := Named(server, "http")
+
+		http.Info("test message")
+
+		out := buf.String()
+		if strings.Count(out, NameKey) != 1 || !strings.Contains(out, `logger.name=server.http g.k=v`) {
+			t.Errorf("unexpected output: %s", out)
+		}
+		if Name(http) != "server.http" || Name(server) != "server" {
+			t.Errorf("unexpected names: %q, %q", Name(http), Name(server))
+		}
+		if Name(slog.Default()) != "" {
+			t.Errorf("expected unnamed default logger")
+		}
+	})
+}
@@ -0,0 +1,101 @@
+package slogging
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	// OccurrencesKey is the attribute counting the calls of WarnOnce,
+	// WarnEvery and Deprecated since the previous record of their key,
+	// including the one logged.
+	OccurrencesKey = "log.occurrences"
+
+	// DeprecatedKey is the attribute naming the deprecated feature in the
+	// records of Deprecated.
+	DeprecatedKey = "log.deprecated"
+)
+
+// onceEntry tracks the calls made with a key.
+type onceEntry struct {
+	mutex  sync.Mutex
+	count  uint64 // Calls since the last record
+	total  uint64
+	last   time.Time
+	logged bool
+}
+
+// onceEntries maps keys to their *onceEntry, process-wide.
+var onceEntries sync.Map
+
+// WarnOnce logs a warning the first time it is called with key in the
+// process, and only counts the later calls, to keep warnings of hot paths
+// from flooding the logs. Keys are shared by every logger.
+func WarnOnce(ctx context.Context, logger *slog.Logger, key, msg string, args ...any) {
+	logOnce(ctx, logger, 0, key, msg, args)
+}
+
+// WarnEvery logs a warning at most once per interval for key, with the
+// number of calls made since the previous warning of the key as the
+// "log.occurrences" attribute.
+func WarnEvery(ctx context.Context, logger *slog.Logger, interval time.Duration, key, msg string, args ...any) {
+	logOnce(ctx, logger, interval, key, msg, args)
+}
+
+// Deprecated logs a warning about the use of a deprecated feature once per
+// process, with the feature as the "log.deprecated" attribute:
+//
+//	slogging.Deprecated(ctx, logger, "config.v1", "config v1 is deprecated, migrate to v2")
+func Deprecated(ctx context.Context, logger *slog.Logger, feature, msg string, args ...any) {
+	logOnce(ctx, logger, 0, "deprecated:"+feature, msg, append([]any{slog.String(DeprecatedKey, feature)}, args...))
+}
+
+// Occurrences returns the number of calls made with key, or with the
+// feature of Deprecated prefixed with "deprecated:".
+func Occurrences(key string) uint64 {
+	v, ok := onceEntries.Load(key)
+	if !ok {
+		return 0
+	}
+	e := v.(*onceEntry)
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.total
+}
+
+// logOnce must be called directly by the exported functions, so that the
+// records carry the location of their callers.
+func logOnce(ctx context.Context, logger *slog.Logger, interval time.Duration, key, msg string, args []any) {
+	v, ok := onceEntries.Load(key)
+	if !ok {
+		v, _ = onceEntries.LoadOrStore(key, new(onceEntry))
+	}
+	e := v.(*onceEntry)
+	now := time.Now()
+	e.mutex.Lock()
+	e.count++
+	e.total++
+	if e.logged && (interval <= 0 || now.Sub(e.last) < interval) {
+		e.mutex.Unlock()
+		return
+	}
+	n := e.count
+	e.count, e.last, e.logged = 0, now, true
+	e.mutex.Unlock()
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if !logger.Enabled(ctx, slog.LevelWarn) {
+		return
+	}
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // Skip Callers, logOnce and the exported function
+	r := slog.NewRecord(now, slog.LevelWarn, msg, pcs[0])
+	r.Add(args...)
+	r.AddAttrs(slog.Uint64(OccurrencesKey, n))
+	_ = logger.Handler().Handle(ctx, r)
+}
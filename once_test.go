@@ -0,0 +1,61 @@
+package slogging
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_WarnOnce(t *testing.T) {
+	onceEntries.Clear()
+	ctx := context.Background()
+
+	t.Run("once per process", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(slog.NewTextHandler(buf, &slog.HandlerOptions{AddSource: true}))
+		for range 3 {
+			WarnOnce(ctx, logger, "test.once", "cache disabled", "reason", "no memory")
+		}
+		out := buf.String()
+		if strings.Count(out, "\n") != 1 || !strings.Contains(out, `msg="cache disabled" reason="no memory" log.occurrences=1`) {
+			t.Errorf("unexpected output: %s", out)
+		}
+		if !strings.Contains(out, "once_test.go") {
+			t.Errorf("source is not the caller: %s", out)
+		}
+		if n := Occurrences("test.once"); n != 3 {
+			t.Errorf("Occurrences() = %d, want 3", n)
+		}
+	})
+
+	t.Run("every interval", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(slog.NewTextHandler(buf, nil))
+		WarnEvery(ctx, logger, 20*time.Millisecond, "test.every", "slow")
+		WarnEvery(ctx, logger, 20*time.Millisecond, "test.every", "slow")
+		WarnEvery(ctx, logger, 20*time.Millisecond, "test.every", "slow")
+		time.Sleep(30 * time.Millisecond)
+		WarnEvery(ctx, logger, 20*time.Millisecond, "test.every", "slow")
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 || !strings.HasSuffix(lines[0], "log.occurrences=1") || !strings.HasSuffix(lines[1], "log.occurrences=3") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("deprecation", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(slog.NewTextHandler(buf, nil))
+		Deprecated(ctx, logger, "test.v1", "v1 API is deprecated", "replacement", "v2")
+		Deprecated(ctx, logger, "test.v1", "v1 API is deprecated", "replacement", "v2")
+		out := buf.String()
+		if strings.Count(out, "\n") != 1 || !strings.Contains(out, `level=WARN msg="v1 API is deprecated" log.deprecated=test.v1 replacement=v2 log.occurrences=1`) {
+			t.Errorf("unexpected output: %s", out)
+		}
+		if n := Occurrences("deprecated:test.v1"); n != 2 {
+			t.Errorf("Occurrences() = %d, want 2", n)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+package otel
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"strconv"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Convention defines the keys and formats of the trace attributes added by
+// the Handler. Empty keys omit the corresponding attribute; presets cover
+// common backends and the fields can be set freely for others.
+type Convention struct {
+	// Group is the group holding the attributes. If empty, they are added
+	// at the top level.
+	Group string
+
+	TraceID     string
+	SpanID      string
+	ServiceName string
+	TraceFlags  string // Added with WithTraceFlags
+	Sampled     string // Added with WithSampled
+
+	// FormatTraceID and FormatSpanID render the IDs. If nil, IDs are
+	// rendered as lowercase hex.
+	FormatTraceID func(trace.TraceID) string
+	FormatSpanID  func(trace.SpanID) string
+}
+
+var (
+	// ConventionOTel is the default convention: trace_id, span_id,
+	// service_name, trace_flags and sampled in an "otel" group.
+	ConventionOTel = Convention{
+		Group:       "otel",
+		TraceID:     "trace_id",
+		SpanID:      "span_id",
+		ServiceName: "service_name",
+		TraceFlags:  "trace_flags",
+		Sampled:     "sampled",
+	}
+
+	// ConventionECS follows the Elastic Common Schema: trace.id, span.id
+	// and service.name at the top level.
+	ConventionECS = Convention{
+		TraceID:     "trace.id",
+		SpanID:      "span.id",
+		ServiceName: "service.name",
+	}
+
+	// ConventionDatadog follows Datadog log correlation: dd.trace_id and
+	// dd.span_id at the top level, encoded as unsigned decimals of the
+	// lower 64 bits of the IDs, and dd.service.
+	ConventionDatadog = Convention{
+		TraceID:       "dd.trace_id",
+		SpanID:        "dd.span_id",
+		ServiceName:   "dd.service",
+		FormatTraceID: DatadogTraceID,
+		FormatSpanID:  DatadogSpanID,
+	}
+)
+
+// ConventionGCP returns the convention of Google Cloud Logging for the
+// given project: logging.googleapis.com/trace, prefixed with the project
+// as "projects/PROJECT_ID/traces/", logging.googleapis.com/spanId and
+// logging.googleapis.com/trace_sampled at the top level.
+func ConventionGCP(projectID string) Convention {
+	return Convention{
+		TraceID: "logging.googleapis.com/trace",
+		SpanID:  "logging.googleapis.com/spanId",
+		Sampled: "logging.googleapis.com/trace_sampled",
+		FormatTraceID: func(id trace.TraceID) string {
+			return fmt.Sprintf("projects/%s/traces/%s", projectID, id)
+		},
+	}
+}
+
+// DatadogTraceID renders the lower 64 bits of id as an unsigned decimal,
+// the format Datadog correlates logs with.
+func DatadogTraceID(id trace.TraceID) string {
+	return strconv.FormatUint(binary.BigEndian.Uint64(id[8:]), 10)
+}
+
+// DatadogSpanID renders id as an unsigned decimal.
+func DatadogSpanID(id trace.SpanID) string {
+	return strconv.FormatUint(binary.BigEndian.Uint64(id[:]), 10)
+}
+
+// attrs returns the trace attributes of sc under the keys of c, outside of
+// its group. The service name is omitted when empty, the trace flags and
+// the sampled flag unless requested.
+func (c *Convention) attrs(sc trace.SpanContext, service string, traceFlags, sampled bool) []slog.Attr {
+	attrs := make([]slog.Attr, 0, 5)
+	if c.TraceID != "" {
+		attrs = append(attrs, slog.String(c.TraceID, c.traceID(sc.TraceID())))
+	}
+	if c.SpanID != "" {
+		attrs = append(attrs, slog.String(c.SpanID, c.spanID(sc.SpanID())))
+	}
+	if service != "" && c.ServiceName != "" {
+		attrs = append(attrs, slog.String(c.ServiceName, service))
+	}
+	if traceFlags && c.TraceFlags != "" {
+		attrs = append(attrs, slog.String(c.TraceFlags, sc.TraceFlags().String()))
+	}
+	if sampled && c.Sampled != "" {
+		attrs = append(attrs, slog.Bool(c.Sampled, sc.IsSampled()))
+	}
+	return attrs
+}
+
+func (c *Convention) traceID(id trace.TraceID) string {
+	if c.FormatTraceID != nil {
+		return c.FormatTraceID(id)
+	}
+	return id.String()
+}
+
+func (c *Convention) spanID(id trace.SpanID) string {
+	if c.FormatSpanID != nil {
+		return c.FormatSpanID(id)
+	}
+	return id.String()
+}
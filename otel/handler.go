@@ -4,10 +4,13 @@ import (
 	"context"
 	"log/slog"
 	"strings"
+	"sync/atomic"
 
 	"go.opentelemetry.io/otel/sdk/resource"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging/groups"
 )
 
 // getServiceName extracts the service name from the span's resource.
@@ -37,136 +40,194 @@ func getServiceName(span trace.Span) string {
 // (trace_id, span_id, and service_name) to log records. It wraps another handler and
 // ensures trace attributes are always added at the root level in an "otel" group.
 type Handler struct {
-	handler     slog.Handler // Always the original base handler, never wrapped
-	preAttrs    []slog.Attr  // Attributes to prepend (including trace attrs)
-	groups      []string     // Current group path
-	groupedAttrs []slog.Attr // Attributes that should be placed in current group
+	handler      slog.Handler // Always the original base handler, never wrapped
+	plain        slog.Handler // Base handler with the attributes and groups, for records without span
+	preAttrs     []slog.Attr  // Attributes to prepend (including trace attrs)
+	groups       []string     // Current group path
+	groupedAttrs []slog.Attr  // Attributes that should be placed in current group
+	cache        *atomic.Pointer[traceCache]
+	config       handlerOptions
+}
+
+// traceCache holds the trace attributes of the span last logged with, so
+// that the records of a span share them instead of formatting the IDs
+// every time. The attributes are never modified.
+type traceCache struct {
+	sc      trace.SpanContext
+	service string
+	attrs   []slog.Attr
 }
 
 // Wrap creates a new OpenTelemetry-aware handler that wraps
 // the provided handler. When a valid span context is present in the
 // context passed to logging methods, it automatically adds trace_id,
 // span_id, and service_name attributes at the root level in an "otel" group.
-func Wrap(handler slog.Handler) *Handler {
-	return &Handler{
-		handler:      handler,
-		preAttrs:     nil,
-		groups:       nil,
-		groupedAttrs: nil,
+// Remote span contexts, as put into the context by propagators or by
+// ContextWithTraceparent, are honored when no SDK span was started.
+// Additional behavior can be enabled using Option functions.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{convention: ConventionOTel}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	h := &Handler{
+		handler: handler,
+		cache:   new(atomic.Pointer[traceCache]),
+		config:  config,
 	}
+	if config.groupPolicy == nil {
+		h.plain = handler
+	}
+	return h
 }
 
 // Enabled reports whether the handler handles records at the given level.
+// When trace-aware sampling is enabled, records below the sampling
+// threshold are reported as disabled unless the span in ctx is sampled.
 func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	if !h.sampledIn(ctx, level) {
+		return false
+	}
 	return h.handler.Enabled(ctx, level)
 }
 
+// sampledIn reports whether a record at the given level passes trace-aware
+// sampling. Records without a valid span context are treated as unsampled.
+func (h *Handler) sampledIn(ctx context.Context, level slog.Level) bool {
+	if h.config.samplingThreshold == nil || level >= h.config.samplingThreshold.Level() {
+		return true
+	}
+	return trace.SpanContextFromContext(ctx).IsSampled()
+}
+
 // Handle processes the Record by adding trace context if present,
 // then delegates to the wrapped handler.
+//
+// Records without span are delivered unchanged to the base handler derived
+// with the attributes and groups of h, without allocations. Records with a
+// span are rebuilt with the trace attributes at the root, allocating only
+// the slices of the groups holding their attributes.
 func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
-	// Check for span context
-	span := trace.SpanFromContext(ctx)
-	if !span.SpanContext().IsValid() && len(h.preAttrs) == 0 && len(h.groups) == 0 && len(h.groupedAttrs) == 0 {
-		// No trace context, no pre-attrs, no groups, no grouped attrs - safe to pass through
-		return h.handler.Handle(ctx, r)
+	if !h.sampledIn(ctx, r.Level) {
+		return nil
 	}
 
-	// We need to inject attributes at the root level
-	// Create a new record with our pre-attrs first
-	newRecord := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
-
-	// Add trace attributes if present
-	if span.SpanContext().IsValid() {
-		otelAttrs := []any{
-			slog.String("trace_id", span.SpanContext().TraceID().String()),
-			slog.String("span_id", span.SpanContext().SpanID().String()),
-		}
-
-		// Add service name if available
-		if serviceName := getServiceName(span); serviceName != "" {
-			otelAttrs = append(otelAttrs, slog.String("service_name", serviceName))
-		}
-
-		newRecord.AddAttrs(slog.Group("otel", otelAttrs...))
+	span := trace.SpanFromContext(ctx)
+	valid := span.SpanContext().IsValid()
+	if !valid && h.plain != nil {
+		return h.plain.Handle(ctx, r)
 	}
 
-	// Add any pre-attrs
-	newRecord.AddAttrs(h.preAttrs...)
+	// The attributes are added at once, so that the record grows its
+	// storage once when they do not fit in it.
+	var scratch [16]slog.Attr
+	attrs := scratch[:0]
+	if valid {
+		attrs = append(attrs, h.traceAttrs(span)...)
+	}
+	attrs = append(attrs, h.preAttrs...)
 
-	// Now we need to handle groups properly
-	// We'll rebuild the structure with groups
 	if len(h.groups) > 0 {
-		// Collect all attributes that should be in the group:
-		// 1. Attributes from the original record (r.Attrs)
-		// 2. Attributes added via WithAttrs (h.groupedAttrs)
-		var allGroupedAttrs []slog.Attr
-		
-		// Add grouped attributes first (from WithAttrs calls)
-		allGroupedAttrs = append(allGroupedAttrs, h.groupedAttrs...)
-		
-		// Add attributes from the record
+		// Attributes added via WithAttrs within groups come first, then
+		// those of the record, all in the innermost group.
+		grouped := make([]slog.Attr, 0, len(h.groupedAttrs)+r.NumAttrs())
+		grouped = append(grouped, h.groupedAttrs...)
 		r.Attrs(func(a slog.Attr) bool {
-			allGroupedAttrs = append(allGroupedAttrs, a)
+			grouped = append(grouped, a)
 			return true
 		})
-
-		// Build nested groups from inside out
-		// Convert attrs to any slice
-		anyAttrs := make([]any, len(allGroupedAttrs))
-		for i, a := range allGroupedAttrs {
-			anyAttrs[i] = a
-		}
-
-		current := slog.Group(h.groups[len(h.groups)-1], anyAttrs...)
+		// Build nested groups from inside out, the enclosing groups
+		// sharing one slice.
+		current := slog.Attr{Key: h.groups[len(h.groups)-1], Value: slog.GroupValue(grouped...)}
+		outer := make([]slog.Attr, len(h.groups)-1)
 		for i := len(h.groups) - 2; i >= 0; i-- {
-			current = slog.Group(h.groups[i], current)
+			outer[i] = current
+			current = slog.Attr{Key: h.groups[i], Value: slog.GroupValue(outer[i : i+1]...)}
 		}
-
-		newRecord.AddAttrs(current)
+		attrs = append(attrs, current)
 	} else {
-		// No groups, add grouped attrs and record attrs at root level
-		newRecord.AddAttrs(h.groupedAttrs...)
+		attrs = append(attrs, h.groupedAttrs...)
 		r.Attrs(func(a slog.Attr) bool {
-			newRecord.AddAttrs(a)
+			attrs = append(attrs, a)
 			return true
 		})
 	}
+	newRecord := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	newRecord.AddAttrs(attrs...)
+
+	if h.config.groupPolicy != nil {
+		var err error
+		if newRecord, err = resolveGroups(newRecord, *h.config.groupPolicy); err != nil {
+			return err
+		}
+	}
 
 	// Use the base handler (not the grouped one)
 	return h.handler.Handle(ctx, newRecord)
 }
 
+// traceAttrs returns the trace attributes of span following the configured
+// convention, from the cache if span is the span last logged with.
+func (h *Handler) traceAttrs(span trace.Span) []slog.Attr {
+	c := &h.config.convention
+	sc := span.SpanContext()
+	var service string
+	if c.ServiceName != "" {
+		service = getServiceName(span)
+	}
+	if tc := h.cache.Load(); tc != nil && tc.sc.Equal(sc) && tc.service == service {
+		return tc.attrs
+	}
+
+	attrs := c.attrs(sc, service, h.config.traceFlags, h.config.sampled)
+	if c.Group != "" {
+		attrs = []slog.Attr{{Key: c.Group, Value: slog.GroupValue(attrs...)}}
+	}
+	h.cache.Store(&traceCache{sc: sc, service: service, attrs: attrs})
+	return attrs
+}
+
+// resolveGroups returns a copy of r with duplicate groups, such as an "otel"
+// group provided by the caller, resolved according to p.
+func resolveGroups(r slog.Record, p groups.Policy) (slog.Record, error) {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	attrs, err := groups.Resolve(attrs, p)
+	if err != nil {
+		return r, err
+	}
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(attrs...)
+	return nr, nil
+}
+
 // WithAttrs returns a new Handler that includes the given attributes.
 func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
 	if len(attrs) == 0 {
 		return h
 	}
 
+	h2 := *h
 	if len(h.groups) == 0 {
 		// At root level, add to preAttrs
-		newPreAttrs := make([]slog.Attr, len(h.preAttrs)+len(attrs))
-		copy(newPreAttrs, h.preAttrs)
-		copy(newPreAttrs[len(h.preAttrs):], attrs)
-
-		return &Handler{
-			handler:      h.handler,
-			preAttrs:     newPreAttrs,
-			groups:       h.groups,
-			groupedAttrs: h.groupedAttrs,
-		}
+		h2.preAttrs = make([]slog.Attr, len(h.preAttrs)+len(attrs))
+		copy(h2.preAttrs, h.preAttrs)
+		copy(h2.preAttrs[len(h.preAttrs):], attrs)
+	} else {
+		// In a group, add to groupedAttrs to be processed during Handle
+		h2.groupedAttrs = make([]slog.Attr, len(h.groupedAttrs)+len(attrs))
+		copy(h2.groupedAttrs, h.groupedAttrs)
+		copy(h2.groupedAttrs[len(h.groupedAttrs):], attrs)
 	}
-
-	// In a group, add to groupedAttrs to be processed during Handle
-	newGroupedAttrs := make([]slog.Attr, len(h.groupedAttrs)+len(attrs))
-	copy(newGroupedAttrs, h.groupedAttrs)
-	copy(newGroupedAttrs[len(h.groupedAttrs):], attrs)
-
-	return &Handler{
-		handler:      h.handler, // Always keep the original base handler
-		preAttrs:     h.preAttrs,
-		groups:       h.groups,
-		groupedAttrs: newGroupedAttrs,
+	if h.plain != nil {
+		h2.plain = h.plain.WithAttrs(attrs)
 	}
+	return &h2
 }
 
 // WithGroup returns a new Handler that starts a group.
@@ -175,17 +236,98 @@ func (h *Handler) WithGroup(name string) slog.Handler {
 		return h
 	}
 
-	newGroups := make([]string, len(h.groups)+1)
-	copy(newGroups, h.groups)
-	newGroups[len(h.groups)] = name
+	// The base handler is never given the group: ALL grouping is done in
+	// Handle to ensure otel attributes stay at the absolute root level.
+	h2 := *h
+	h2.groups = make([]string, len(h.groups)+1)
+	copy(h2.groups, h.groups)
+	h2.groups[len(h.groups)] = name
+	if h.plain != nil {
+		if len(h.groupedAttrs) == 0 {
+			h2.plain = h.plain.WithGroup(name)
+		} else {
+			// Grouped attributes move to the innermost group, as in Handle.
+			h2.plain = h2.derivePlain()
+		}
+	}
+	return &h2
+}
+
+// derivePlain derives the base handler with the attributes and groups of h.
+func (h *Handler) derivePlain() slog.Handler {
+	plain := h.handler
+	if len(h.preAttrs) > 0 {
+		plain = plain.WithAttrs(h.preAttrs)
+	}
+	for _, g := range h.groups {
+		plain = plain.WithGroup(g)
+	}
+	if len(h.groupedAttrs) > 0 {
+		plain = plain.WithAttrs(h.groupedAttrs)
+	}
+	return plain
+}
+
+type handlerOptions struct {
+	traceFlags        bool
+	sampled           bool
+	samplingThreshold slog.Leveler
+	groupPolicy       *groups.Policy
+	convention        Convention
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithTraceFlags adds the W3C trace flags of the span context, hex-encoded,
+// as "trace_flags" to the "otel" group, or under the TraceFlags key of the
+// configured convention.
+func WithTraceFlags(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.traceFlags = true
+		for i := range x {
+			h.traceFlags = x[i]
+		}
+	}
+}
+
+// WithSampled adds a boolean "sampled" attribute to the "otel" group, or
+// under the Sampled key of the configured convention, reporting whether the
+// span context is sampled.
+func WithSampled(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.sampled = true
+		for i := range x {
+			h.sampled = x[i]
+		}
+	}
+}
+
+// WithTraceSampling enables trace-aware sampling: records below threshold are
+// only forwarded when the span in the logging context is sampled, while records
+// at or above threshold are always forwarded. Records logged without a valid
+// span context are treated as unsampled. A nil threshold disables sampling.
+func WithTraceSampling(threshold slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.samplingThreshold = threshold
+	}
+}
+
+// WithGroupConflict sets how a group sharing its name with another group at
+// the same depth, e.g. an "otel" group provided by the caller next to the
+// injected one, is resolved. See groups.Policy. By default duplicate groups
+// are emitted unchanged.
+func WithGroupConflict(p groups.Policy) Option {
+	return func(h *handlerOptions) {
+		h.groupPolicy = &p
+	}
+}
 
-	// ALWAYS keep the base handler - never call WithGroup on it
-	// We'll handle ALL grouping ourselves in Handle to ensure
-	// otel attributes stay at the absolute root level
-	return &Handler{
-		handler:      h.handler, // Always use the original base handler
-		preAttrs:     h.preAttrs,
-		groups:       newGroups,
-		groupedAttrs: h.groupedAttrs, // Carry forward any grouped attributes
+// WithConvention sets the keys and formats of the trace attributes, e.g.
+// ConventionECS, ConventionDatadog or ConventionGCP(projectID). The
+// default is ConventionOTel.
+func WithConvention(c Convention) Option {
+	return func(h *handlerOptions) {
+		h.convention = c
 	}
 }
@@ -211,4 +211,60 @@ func BenchmarkHandlerWithAttrs(b *testing.B) {
 			logger.Info(testMessage, testAttrs...)
 		}
 	})
+
+	b.Run("OtelHandler_NoSpan_WithAttrsAndGroups", func(b *testing.B) {
+		handler := Wrap(slog.NewJSONHandler(io.Discard, nil))
+		logger := slog.New(handler).With("service", "test").WithGroup("request").With("id", 42)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			logger.Info(testMessage, testAttrs...)
+		}
+	})
+
+	b.Run("OtelHandler_WithSpan_WithAttrsAndGroups", func(b *testing.B) {
+		handler := Wrap(slog.NewJSONHandler(io.Discard, nil))
+		logger := slog.New(handler).With("service", "test").WithGroup("request").With("id", 42)
+
+		ctx, span := tracer.Start(context.Background(), "bench-span")
+		defer span.End()
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			logger.InfoContext(ctx, testMessage, testAttrs...)
+		}
+	})
+
+	b.Run("BaselineJSONHandler_WithAttrsAndGroups", func(b *testing.B) {
+		logger := slog.New(slog.NewJSONHandler(io.Discard, nil)).With("service", "test").WithGroup("request").With("id", 42)
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			logger.Info(testMessage, testAttrs...)
+		}
+	})
+}
+
+func BenchmarkHandlerSpans(b *testing.B) {
+	testMessage := "test log message"
+	tracer := sdktrace.NewTracerProvider().Tracer("bench-tracer")
+
+	// Alternating spans miss the cache of trace attributes every time.
+	b.Run("OtelHandler_AlternatingSpans", func(b *testing.B) {
+		logger := slog.New(Wrap(slog.NewJSONHandler(io.Discard, nil)))
+		ctx1, span1 := tracer.Start(context.Background(), "bench-span-1")
+		defer span1.End()
+		ctx2, span2 := tracer.Start(context.Background(), "bench-span-2")
+		defer span2.End()
+		contexts := []context.Context{ctx1, ctx2}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			logger.InfoContext(contexts[i%2], testMessage, "key", "value")
+		}
+	})
 }
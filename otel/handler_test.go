@@ -3,14 +3,18 @@ package otel
 import (
 	"bytes"
 	"context"
+	"errors"
 	"log/slog"
 	"strings"
 	"testing"
+	"time"
 
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
 	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging/groups"
 )
 
 func Test_OtelHandler(t *testing.T) {
@@ -363,7 +367,7 @@ func Test_OtelHandler(t *testing.T) {
 		if err != nil {
 			t.Fatalf("failed to create resource: %v", err)
 		}
-		
+
 		tp := sdktrace.NewTracerProvider(
 			sdktrace.WithResource(res),
 		)
@@ -379,7 +383,7 @@ func Test_OtelHandler(t *testing.T) {
 
 		// Create nested groups: module.component
 		groupedLogger := logger.WithGroup("module").WithGroup("component")
-		
+
 		// Add attributes to the grouped logger (this should put them in module.component group)
 		loggerWithAttrs := groupedLogger.With(
 			slog.Int("counter", 42),
@@ -496,3 +500,329 @@ func Test_OtelHandler(t *testing.T) {
 		}
 	})
 }
+
+func Test_TraceFlags(t *testing.T) {
+	t.Run("sampled span should report flags and sampled indicator", func(t *testing.T) {
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample()))
+		tracer := tp.Tracer("test-tracer")
+
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), WithTraceFlags(), WithSampled()))
+
+		ctx, span := tracer.Start(context.Background(), "test-span")
+		defer span.End()
+
+		logger.InfoContext(ctx, "test message")
+
+		line := buf.String()
+		if !strings.Contains(line, `otel.trace_flags=01`) {
+			t.Errorf("expected otel.trace_flags=01 in output, got: %s", line)
+		}
+		if !strings.Contains(line, `otel.sampled=true`) {
+			t.Errorf("expected otel.sampled=true in output, got: %s", line)
+		}
+	})
+
+	t.Run("unsampled span should report sampled=false", func(t *testing.T) {
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample()))
+		tracer := tp.Tracer("test-tracer")
+
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), WithTraceFlags(), WithSampled()))
+
+		ctx, span := tracer.Start(context.Background(), "test-span")
+		defer span.End()
+
+		logger.WithGroup("g").InfoContext(ctx, "test message")
+
+		line := buf.String()
+		if !strings.Contains(line, `otel.trace_flags=00`) {
+			t.Errorf("expected otel.trace_flags=00 in output, got: %s", line)
+		}
+		if !strings.Contains(line, `otel.sampled=false`) {
+			t.Errorf("expected otel.sampled=false in output, got: %s", line)
+		}
+	})
+
+	t.Run("flags are omitted by default", func(t *testing.T) {
+		tp := sdktrace.NewTracerProvider()
+		tracer := tp.Tracer("test-tracer")
+
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), WithSampled(false)))
+
+		ctx, span := tracer.Start(context.Background(), "test-span")
+		defer span.End()
+
+		logger.InfoContext(ctx, "test message")
+
+		line := buf.String()
+		if strings.Contains(line, `otel.trace_flags=`) || strings.Contains(line, `otel.sampled=`) {
+			t.Errorf("unexpected trace flags in output: %s", line)
+		}
+	})
+}
+
+func Test_TraceSampling(t *testing.T) {
+	sampled := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.AlwaysSample())).Tracer("test-tracer")
+	unsampled := sdktrace.NewTracerProvider(sdktrace.WithSampler(sdktrace.NeverSample())).Tracer("test-tracer")
+
+	t.Run("sampled span forwards all levels", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}), WithTraceSampling(slog.LevelWarn)))
+
+		ctx, span := sampled.Start(context.Background(), "test-span")
+		defer span.End()
+
+		logger.DebugContext(ctx, "debug message")
+		logger.InfoContext(ctx, "info message")
+
+		if !strings.Contains(buf.String(), "debug message") || !strings.Contains(buf.String(), "info message") {
+			t.Errorf("expected debug and info records, got: %s", buf.String())
+		}
+	})
+
+	t.Run("unsampled span drops records below threshold", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}), WithTraceSampling(slog.LevelWarn)))
+
+		ctx, span := unsampled.Start(context.Background(), "test-span")
+		defer span.End()
+
+		logger.InfoContext(ctx, "info message")
+		logger.WarnContext(ctx, "warn message")
+		logger.ErrorContext(ctx, "error message")
+
+		if strings.Contains(buf.String(), "info message") {
+			t.Errorf("unexpected info record: %s", buf.String())
+		}
+		if !strings.Contains(buf.String(), "warn message") || !strings.Contains(buf.String(), "error message") {
+			t.Errorf("expected warn and error records, got: %s", buf.String())
+		}
+	})
+
+	t.Run("missing span is treated as unsampled", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		handler := Wrap(slog.NewTextHandler(buf, nil), WithTraceSampling(slog.LevelWarn))
+
+		if handler.Enabled(context.Background(), slog.LevelInfo) {
+			t.Errorf("expected info to be disabled without span")
+		}
+		if !handler.Enabled(context.Background(), slog.LevelError) {
+			t.Errorf("expected error to be enabled without span")
+		}
+	})
+}
+
+func Test_GroupConflict(t *testing.T) {
+	tracer := sdktrace.NewTracerProvider().Tracer("test-tracer")
+
+	t.Run("user otel group is merged into the injected one", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), WithGroupConflict(groups.Merge)))
+
+		ctx, span := tracer.Start(context.Background(), "test-span")
+		defer span.End()
+
+		logger.InfoContext(ctx, "test message", slog.Group("otel", "custom", "x"))
+
+		out := buf.String()
+		if strings.Count(out, "otel.span_id=") != 1 || !strings.Contains(out, "otel.custom=x") {
+			t.Errorf("expected merged otel group, got: %s", out)
+		}
+		if !strings.Contains(out, span.SpanContext().SpanID().String()+" otel.custom=x") {
+			t.Errorf("expected user attributes after injected ones, got: %s", out)
+		}
+	})
+
+	t.Run("error policy rejects the record", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		handler := Wrap(slog.NewTextHandler(buf, nil), WithGroupConflict(groups.Error))
+
+		ctx, span := tracer.Start(context.Background(), "test-span")
+		defer span.End()
+
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "test message", 0)
+		r.AddAttrs(slog.Group("otel", "custom", "x"))
+		if err := handler.Handle(ctx, r); !errors.Is(err, groups.ErrDuplicate) {
+			t.Errorf("expected ErrDuplicate, got: %v", err)
+		}
+		if buf.Len() != 0 {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+}
+
+func Test_RemoteSpanContext(t *testing.T) {
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	t.Run("remote span context without sdk span", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), WithSampled()))
+
+		ctx, err := ContextWithTraceparent(context.Background(), traceparent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger.InfoContext(ctx, "test message")
+
+		if !strings.Contains(buf.String(), "otel.trace_id=4bf92f3577b34da6a3ce929d0e0e4736 otel.span_id=00f067aa0ba902b7 otel.sampled=true") {
+			t.Errorf("expected remote trace context, got: %s", buf.String())
+		}
+	})
+
+	t.Run("traceparent attribute", func(t *testing.T) {
+		a, err := TraceparentAttr(ConventionOTel, traceparent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := a.String(); got != "otel=[trace_id=4bf92f3577b34da6a3ce929d0e0e4736 span_id=00f067aa0ba902b7]" {
+			t.Errorf("unexpected attribute: %s", got)
+		}
+
+		a, err = TraceparentAttr(ConventionECS, traceparent)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := new(bytes.Buffer)
+		slog.New(slog.NewTextHandler(buf, nil)).Info("test message", a)
+		if !strings.Contains(buf.String(), "msg=\"test message\" trace.id=4bf92f3577b34da6a3ce929d0e0e4736 span.id=00f067aa0ba902b7\n") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("invalid traceparent", func(t *testing.T) {
+		for _, tp := range []string{
+			"",
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		} {
+			if _, err := TraceparentAttr(ConventionOTel, tp); !errors.Is(err, ErrInvalidTraceparent) {
+				t.Errorf("%q: expected ErrInvalidTraceparent, got %v", tp, err)
+			}
+		}
+	})
+}
+
+func Test_Convention(t *testing.T) {
+	ctx, err := ContextWithTraceparent(context.Background(), "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		convention Convention
+		want       string
+	}{
+		"ecs": {
+			convention: ConventionECS,
+			want:       `msg="test message" trace.id=4bf92f3577b34da6a3ce929d0e0e4736 span.id=00f067aa0ba902b7 k=v`,
+		},
+		"datadog": {
+			convention: ConventionDatadog,
+			want:       `msg="test message" dd.trace_id=11803532876627986230 dd.span_id=67667974448284343 k=v`,
+		},
+		"gcp": {
+			convention: ConventionGCP("my-project"),
+			want:       `msg="test message" logging.googleapis.com/trace=projects/my-project/traces/4bf92f3577b34da6a3ce929d0e0e4736 logging.googleapis.com/spanId=00f067aa0ba902b7 logging.googleapis.com/trace_sampled=true k=v`,
+		},
+		"custom": {
+			convention: Convention{Group: "tracing", TraceID: "tid", TraceFlags: "flags"},
+			want:       `msg="test message" tracing.tid=4bf92f3577b34da6a3ce929d0e0e4736 tracing.flags=01 k=v`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), WithConvention(tc.convention), WithSampled(), WithTraceFlags()))
+
+			logger.InfoContext(ctx, "test message", "k", "v")
+
+			if !strings.Contains(buf.String(), tc.want) {
+				t.Errorf("expected %s, got: %s", tc.want, buf.String())
+			}
+		})
+	}
+}
+
+func Test_DatadogIDs(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("0123456789abcdefffffffffffffffff")
+	spanID, _ := trace.SpanIDFromHex("8000000000000000")
+
+	if got := DatadogTraceID(traceID); got != "18446744073709551615" {
+		t.Errorf("expected lower 64 bits as unsigned decimal, got: %s", got)
+	}
+	if got := DatadogSpanID(spanID); got != "9223372036854775808" {
+		t.Errorf("expected unsigned decimal, got: %s", got)
+	}
+}
+
+func Test_Allocations(t *testing.T) {
+	tracer := sdktrace.NewTracerProvider().Tracer("test-tracer")
+	ctx, span := tracer.Start(context.Background(), "test-span")
+	defer span.End()
+
+	t.Run("records without span match records with span", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}))).
+			With("svc", "api").WithGroup("a").With("x", 1).WithGroup("b").With("y", 2)
+		logger.Info("m", "k", "v")
+		logger.InfoContext(ctx, "m", "k", "v")
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		want := "level=INFO msg=m svc=api a.b.x=1 a.b.y=2 a.b.k=v"
+		if len(lines) != 2 || lines[0] != want || !strings.HasSuffix(lines[1], " svc=api a.b.x=1 a.b.y=2 a.b.k=v") {
+			t.Errorf("\nwant %s\ngot  %s", want, buf.String())
+		}
+	})
+
+	for name, tc := range map[string]struct {
+		ctx    context.Context
+		groups bool
+		args   []any
+		max    float64
+	}{
+		"no span":              {ctx: context.Background(), max: 0},
+		"no span, with groups": {ctx: context.Background(), groups: true, max: 0},
+		"span":                 {ctx: ctx, max: 0},
+		"span, with groups":    {ctx: ctx, groups: true, max: 2},
+		// Beyond the attributes stored inline by records.
+		"span, many attributes": {ctx: ctx, args: []any{"a", 1, "b", 2, "c", 3, "d", 4, "e", 5}, max: 1},
+	} {
+		t.Run(name, func(t *testing.T) {
+			logger := slog.New(Wrap(discardHandler{})).With("svc", "api")
+			if tc.groups {
+				logger = logger.WithGroup("service").WithGroup("component").With("x", 1)
+			}
+			args := tc.args
+			if args == nil {
+				args = []any{"k", "v"}
+			}
+			allocs := testing.AllocsPerRun(100, func() {
+				logger.InfoContext(tc.ctx, "m", args...)
+			})
+			if raceEnabled {
+				t.Skip("allocations are not representative with the race detector")
+			}
+			if allocs > tc.max {
+				t.Errorf("%v allocations per record, want at most %v", allocs, tc.max)
+			}
+		})
+	}
+}
+
+// discardHandler discards records without allocating, unlike handlers
+// deriving themselves with WithAttrs and WithGroup.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return true }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
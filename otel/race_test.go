@@ -0,0 +1,7 @@
+//go:build race
+
+package otel
+
+// raceEnabled reports whether the race detector, which makes allocations
+// of its own, is enabled.
+const raceEnabled = true
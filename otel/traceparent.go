@@ -0,0 +1,50 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrInvalidTraceparent is returned for traceparent values that do not
+// follow the W3C Trace Context format.
+var ErrInvalidTraceparent = errors.New("slogging: invalid traceparent")
+
+// ParseTraceparent parses a W3C traceparent header value into a remote
+// span context.
+func ParseTraceparent(traceparent string) (trace.SpanContext, error) {
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.MapCarrier{"traceparent": traceparent})
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return trace.SpanContext{}, ErrInvalidTraceparent
+	}
+	return sc, nil
+}
+
+// ContextWithTraceparent returns a copy of ctx carrying the remote span
+// context parsed from a W3C traceparent header value, so that the Handler
+// adds its trace_id and span_id to records logged with it.
+func ContextWithTraceparent(ctx context.Context, traceparent string) (context.Context, error) {
+	sc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return ctx, err
+	}
+	return trace.ContextWithRemoteSpanContext(ctx, sc), nil
+}
+
+// TraceparentAttr returns the trace ID and span ID of a W3C traceparent
+// header value under the keys of convention, as added by a Handler using
+// it, for code paths where only the header is available. Without a group
+// in the convention, the attribute is a group with an empty key, which
+// handlers inline.
+func TraceparentAttr(convention Convention, traceparent string) (slog.Attr, error) {
+	sc, err := ParseTraceparent(traceparent)
+	if err != nil {
+		return slog.Attr{}, err
+	}
+	attrs := convention.attrs(sc, "", false, false)
+	return slog.Attr{Key: convention.Group, Value: slog.GroupValue(attrs...)}, nil
+}
@@ -0,0 +1,304 @@
+// Package otelmetrics provides a slog.Handler wrapper counting records with
+// an OpenTelemetry metric counter, for error rate dashboards built without
+// parsing logs.
+package otelmetrics
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+
+	gootel "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/otel"
+)
+
+const (
+	// DefaultName is the default name of the counter.
+	DefaultName = "log.records"
+
+	// LevelKey and LoggerKey are the attributes of the counter holding
+	// the level of a record and the name of its logger.
+	LevelKey  = "level"
+	LoggerKey = "logger"
+
+	scopeName = "github.com/mikluko/slogging/otelmetrics"
+)
+
+type seriesKey struct {
+	level  slog.Level
+	logger string
+}
+
+// Handler is a slog.Handler that increments a counter, "log.records" by
+// default, for every record before delivering it to the wrapped handler.
+// The counter carries the level of the record as the "level" attribute
+// and the name of its logger, as set by slogging.Named, as the "logger"
+// attribute when there is one. Top-level attributes selected with WithKeys
+// are added to the counter attributes too; they should have few distinct
+// values, as every combination is a separate time series.
+//
+// Only the records passed to Handle are counted, that is those the wrapped
+// handler is enabled for.
+//
+// The logging context is passed to the counter, so that the OpenTelemetry
+// SDK records the trace and span IDs of the span in the context as an
+// exemplar of the measurement, linking error rate spikes on dashboards to
+// example traces; with the default exemplar filter of the SDK, only
+// sampled spans are recorded. Records logged without a span in their
+// context, such as records replayed from a spool, get the span of their
+// trace attributes, as added by the otel handler with ConventionOTel or the
+// convention set with WithConvention. The trace flags of the attributes
+// are used when present; otherwise the span is considered sampled.
+type Handler struct {
+	handler    slog.Handler
+	counter    metric.Int64Counter
+	exemplars  bool
+	convention *otel.Convention
+	keys       []string
+	series     *sync.Map   // seriesKey to metric.AddOption, without WithKeys
+	logger     string      // Name of the logger, from WithAttrs
+	attrs      []slog.Attr // Top-level attributes of WithKeys, from WithAttrs
+	grouped    bool
+}
+
+// Wrap creates a handler counting records delivered to handler. The
+// counter is created with the global MeterProvider unless another one is
+// set with WithMeterProvider.
+func Wrap(handler slog.Handler, options ...Option) (*Handler, error) {
+	config := handlerOptions{name: DefaultName, exemplars: true, convention: &otel.ConventionOTel}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	mp := config.provider
+	if mp == nil {
+		mp = gootel.GetMeterProvider()
+	}
+	counter, err := mp.Meter(scopeName).Int64Counter(config.name,
+		metric.WithDescription("Number of log records by level and logger."),
+		metric.WithUnit("{record}"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error when creating counter %s: %w", config.name, err)
+	}
+	return &Handler{
+		handler:    handler,
+		counter:    counter,
+		exemplars:  config.exemplars,
+		convention: config.convention,
+		keys:       config.keys,
+		series:     new(sync.Map),
+	}, nil
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle counts the record and delivers it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	h.counter.Add(h.exemplarContext(ctx, r), 1, h.attributes(r))
+	return h.handler.Handle(ctx, r)
+}
+
+// exemplarContext returns the context passed to the counter: ctx, ctx with
+// the span of the trace attributes of the record if ctx has none, or ctx
+// without span if exemplars are disabled.
+func (h *Handler) exemplarContext(ctx context.Context, r slog.Record) context.Context {
+	if !h.exemplars {
+		return trace.ContextWithSpanContext(ctx, trace.SpanContext{})
+	}
+	if h.convention == nil || trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	if sc := spanContext(r, *h.convention); sc.IsValid() {
+		return trace.ContextWithSpanContext(ctx, sc)
+	}
+	return ctx
+}
+
+// spanContext returns the span of the trace attributes of r following the
+// convention c, or an invalid span context if r has none.
+func spanContext(r slog.Record, c otel.Convention) trace.SpanContext {
+	var attrs []slog.Attr
+	r.Attrs(func(a slog.Attr) bool {
+		if c.Group == "" {
+			attrs = append(attrs, a)
+		} else if v := a.Value.Resolve(); a.Key == c.Group && v.Kind() == slog.KindGroup {
+			attrs = append(attrs, v.Group()...)
+		}
+		return true
+	})
+	var (
+		config  trace.SpanContextConfig
+		flagged bool
+	)
+	config.TraceFlags = trace.FlagsSampled
+	for _, a := range attrs {
+		if a.Key == "" {
+			continue
+		}
+		v := a.Value.Resolve()
+		switch a.Key {
+		case c.TraceID:
+			config.TraceID, _ = trace.TraceIDFromHex(v.String())
+		case c.SpanID:
+			config.SpanID, _ = trace.SpanIDFromHex(v.String())
+		case c.TraceFlags:
+			var b [1]byte
+			if _, err := fmt.Sscanf(v.String(), "%02x", &b[0]); err == nil {
+				config.TraceFlags, flagged = trace.TraceFlags(b[0]), true
+			}
+		case c.Sampled:
+			if v.Kind() == slog.KindBool && !flagged {
+				config.TraceFlags = config.TraceFlags.WithSampled(v.Bool())
+			}
+		}
+	}
+	return trace.NewSpanContext(config)
+}
+
+// attributes returns the counter attributes of the record.
+func (h *Handler) attributes(r slog.Record) metric.AddOption {
+	logger := h.logger
+	var extra []slog.Attr
+	if !h.grouped {
+		r.Attrs(func(a slog.Attr) bool {
+			switch {
+			case a.Key == slogging.NameKey:
+				logger = a.Value.Resolve().String()
+			case slices.Contains(h.keys, a.Key):
+				extra = append(extra, a)
+			}
+			return true
+		})
+	}
+	if len(h.keys) == 0 {
+		key := seriesKey{level: r.Level, logger: logger}
+		if opt, ok := h.series.Load(key); ok {
+			return opt.(metric.AddOption)
+		}
+		opt := metric.WithAttributeSet(attribute.NewSet(kvs(r.Level, logger, nil)...))
+		h.series.Store(key, opt)
+		return opt
+	}
+	// Attributes of the record override those of WithAttrs.
+	attrs := append(h.attrs[:len(h.attrs):len(h.attrs)], extra...)
+	return metric.WithAttributeSet(attribute.NewSet(kvs(r.Level, logger, attrs)...))
+}
+
+func kvs(level slog.Level, logger string, attrs []slog.Attr) []attribute.KeyValue {
+	out := make([]attribute.KeyValue, 0, len(attrs)+2)
+	out = append(out, attribute.String(LevelKey, level.String()))
+	if logger != "" {
+		out = append(out, attribute.String(LoggerKey, logger))
+	}
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		switch v.Kind() {
+		case slog.KindBool:
+			out = append(out, attribute.Bool(a.Key, v.Bool()))
+		case slog.KindInt64:
+			out = append(out, attribute.Int64(a.Key, v.Int64()))
+		default:
+			out = append(out, attribute.String(a.Key, v.String()))
+		}
+	}
+	return out
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given
+// attributes. The logger name and the attributes selected with WithKeys
+// are taken from them until a group is opened.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	if !h.grouped {
+		for _, a := range attrs {
+			switch {
+			case a.Key == slogging.NameKey:
+				h2.logger = a.Value.Resolve().String()
+			case slices.Contains(h.keys, a.Key):
+				h2.attrs = append(h2.attrs[:len(h2.attrs):len(h2.attrs)], a)
+			}
+		}
+	}
+	return &h2
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	h2.grouped = true
+	return &h2
+}
+
+type handlerOptions struct {
+	provider   metric.MeterProvider
+	name       string
+	keys       []string
+	exemplars  bool
+	convention *otel.Convention
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithMeterProvider sets the MeterProvider creating the counter. Defaults
+// to the global MeterProvider.
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(h *handlerOptions) {
+		h.provider = mp
+	}
+}
+
+// WithName sets the name of the counter. Defaults to DefaultName.
+func WithName(name string) Option {
+	return func(h *handlerOptions) {
+		h.name = name
+	}
+}
+
+// WithKeys sets top-level attributes added to the counter attributes, such
+// as "tenant" or "component".
+func WithKeys(keys ...string) Option {
+	return func(h *handlerOptions) {
+		h.keys = append(h.keys, keys...)
+	}
+}
+
+// WithExemplars sets whether measurements carry the span of the record,
+// letting the SDK record exemplars. Enabled by default.
+func WithExemplars(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.exemplars = true
+		for i := range x {
+			h.exemplars = x[i]
+		}
+	}
+}
+
+// WithConvention sets the convention of the trace attributes the span of
+// records logged without a span in their context is recovered from.
+// Defaults to otel.ConventionOTel; nil disables the recovery.
+func WithConvention(c *otel.Convention) Option {
+	return func(h *handlerOptions) {
+		h.convention = c
+	}
+}
@@ -0,0 +1,174 @@
+package otelmetrics
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging"
+)
+
+type recordingProvider struct {
+	noop.MeterProvider
+	counter *recordingCounter
+}
+
+func (p recordingProvider) Meter(string, ...metric.MeterOption) metric.Meter {
+	return recordingMeter{counter: p.counter}
+}
+
+type recordingMeter struct {
+	noop.Meter
+	counter *recordingCounter
+}
+
+func (m recordingMeter) Int64Counter(name string, _ ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	m.counter.name = name
+	return m.counter, nil
+}
+
+type recordingCounter struct {
+	noop.Int64Counter
+	mutex  sync.Mutex
+	name   string
+	counts map[string]int64
+}
+
+func (c *recordingCounter) Add(_ context.Context, n int64, options ...metric.AddOption) {
+	set := metric.NewAddConfig(options).Attributes()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[set.Encoded(attribute.DefaultEncoder())] += n
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("records are counted by level and logger", func(t *testing.T) {
+		counter := &recordingCounter{counts: map[string]int64{}}
+		h, err := Wrap(slog.NewTextHandler(io.Discard, nil), WithMeterProvider(recordingProvider{counter: counter}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger := slog.New(h)
+		api := slogging.Named(logger, "api")
+		logger.Info("a")
+		logger.Error("b")
+		api.Error("c")
+		api.WithGroup("g").Error("d", slogging.NameKey, "ignored")
+		logger.Debug("disabled")
+
+		if counter.name != DefaultName {
+			t.Errorf("counter name = %q", counter.name)
+		}
+		want := map[string]int64{
+			"level=INFO":             1,
+			"level=ERROR":            1,
+			"level=ERROR,logger=api": 2,
+		}
+		if len(counter.counts) != len(want) {
+			t.Errorf("counts = %v", counter.counts)
+		}
+		for k, n := range want {
+			if counter.counts[k] != n {
+				t.Errorf("count of %s = %d, want %d", k, counter.counts[k], n)
+			}
+		}
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		counter := &recordingCounter{counts: map[string]int64{}}
+		h, err := Wrap(slog.NewTextHandler(io.Discard, nil), WithMeterProvider(recordingProvider{counter: counter}), WithName("app.logs"), WithKeys("tenant", "retry"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger := slog.New(h).With("tenant", "acme", "user", "bob")
+		logger.Warn("a", "retry", true)
+		logger.Warn("b", "tenant", "other")
+		if counter.name != "app.logs" {
+			t.Errorf("counter name = %q", counter.name)
+		}
+		for _, k := range []string{"level=WARN,retry=true,tenant=acme", "level=WARN,tenant=other"} {
+			if counter.counts[k] != 1 {
+				t.Errorf("count of %s = %d, counts = %v", k, counter.counts[k], counter.counts)
+			}
+		}
+	})
+}
+
+func Test_Exemplars(t *testing.T) {
+	traceID, _ := trace.TraceIDFromHex("0af7651916cd43dd8448eb211c80319c")
+	spanID, _ := trace.SpanIDFromHex("b7ad6b7169203331")
+
+	collect := func(t *testing.T, options []Option, log func(*slog.Logger)) []metricdata.Exemplar[int64] {
+		t.Helper()
+		reader := sdkmetric.NewManualReader()
+		mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+		h, err := Wrap(slog.NewTextHandler(io.Discard, nil), append(options, WithMeterProvider(mp))...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		log(slog.New(h))
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatal(err)
+		}
+		var out []metricdata.Exemplar[int64]
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+					out = append(out, dp.Exemplars...)
+				}
+			}
+		}
+		return out
+	}
+	sampled := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	t.Run("span of the context", func(t *testing.T) {
+		got := collect(t, nil, func(l *slog.Logger) { l.ErrorContext(sampled, "failed") })
+		if len(got) != 1 || trace.TraceID(got[0].TraceID) != traceID || trace.SpanID(got[0].SpanID) != spanID {
+			t.Errorf("exemplars = %+v", got)
+		}
+	})
+
+	t.Run("span of the trace attributes", func(t *testing.T) {
+		got := collect(t, nil, func(l *slog.Logger) {
+			l.Error("failed", slog.Group("otel", "trace_id", traceID.String(), "span_id", spanID.String()))
+		})
+		if len(got) != 1 || trace.TraceID(got[0].TraceID) != traceID {
+			t.Errorf("exemplars = %+v", got)
+		}
+	})
+
+	t.Run("unsampled trace attributes", func(t *testing.T) {
+		got := collect(t, nil, func(l *slog.Logger) {
+			l.Error("failed", slog.Group("otel", "trace_id", traceID.String(), "span_id", spanID.String(), "sampled", false))
+		})
+		for _, e := range got {
+			if len(e.TraceID) > 0 {
+				t.Errorf("unexpected exemplar: %+v", e)
+			}
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		got := collect(t, []Option{WithExemplars(false)}, func(l *slog.Logger) { l.ErrorContext(sampled, "failed") })
+		for _, e := range got {
+			if len(e.TraceID) > 0 {
+				t.Errorf("unexpected exemplar: %+v", e)
+			}
+		}
+	})
+}
@@ -0,0 +1,333 @@
+package otlpjson
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/severity"
+)
+
+// Handler is a slog.Handler that writes every record as a single-line
+// OTLP/JSON ExportLogsServiceRequest, the format accepted by the
+// OpenTelemetry Collector otlpjsonfile receiver and by the filelog receiver
+// with a JSON parser. Groups are encoded as nested kvlistValue attributes,
+// and the trace context of the logging context populates traceId, spanId
+// and flags.
+type Handler struct {
+	scope scope.Scope
+
+	// Shared state across WithAttrs/WithGroup instances.
+	mutex *sync.Mutex
+
+	writer          io.Writer
+	level           slog.Leveler
+	resource        []keyValue
+	instrumentation instrumentationScope
+	table           *severity.Table
+	sorted          bool
+}
+
+// NewHandler creates a new Handler with the given options.
+func NewHandler(options ...Option) *Handler {
+	config := handlerOptions{
+		writer: io.Discard,
+		level:  slog.LevelInfo,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	var resource []keyValue
+	for _, a := range config.resource {
+		resource = appendAttr(resource, a)
+	}
+	return &Handler{
+		mutex:           &sync.Mutex{},
+		writer:          config.writer,
+		level:           config.level,
+		resource:        resource,
+		instrumentation: instrumentationScope{Name: config.scopeName, Version: config.scopeVersion},
+		table:           config.table,
+		sorted:          config.sorted,
+	}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	table := h.table
+	if table == nil {
+		table = severity.Default()
+	}
+	sev := table.Lookup(severity.OTLP, r.Level)
+
+	rec := logRecord{
+		ObservedTimeUnixNano: strconv.FormatInt(time.Now().UnixNano(), 10),
+		SeverityNumber:       sev.Code,
+		SeverityText:         sev.Name,
+		Body:                 anyValue{StringValue: &r.Message},
+	}
+	if !r.Time.IsZero() {
+		rec.TimeUnixNano = strconv.FormatInt(r.Time.UnixNano(), 10)
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		rec.TraceID = sc.TraceID().String()
+		rec.SpanID = sc.SpanID().String()
+		rec.Flags = uint32(sc.TraceFlags())
+	}
+
+	for _, a := range h.scope.Attrs(r) {
+		rec.Attributes = appendAttr(rec.Attributes, a)
+	}
+	if h.sorted {
+		sortKeyValues(rec.Attributes)
+	}
+
+	req := exportRequest{
+		ResourceLogs: []resourceLogs{{
+			Resource: resourceValue{Attributes: h.resource},
+			ScopeLogs: []scopeLogs{{
+				Scope:      h.instrumentation,
+				LogRecords: []logRecord{rec},
+			}},
+		}},
+	}
+
+	buf, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("error when marshaling OTLP record: %w", err)
+	}
+	buf = append(buf, '\n')
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	_, err = h.writer.Write(buf)
+	return err
+}
+
+// appendAttr converts a and appends it to kvs. Empty attributes and empty
+// groups are dropped, groups with an empty key are inlined.
+func appendAttr(kvs []keyValue, a slog.Attr) []keyValue {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return kvs
+	}
+	if a.Value.Kind() != slog.KindGroup {
+		return append(kvs, keyValue{Key: a.Key, Value: convertValue(a.Value)})
+	}
+	attrs := a.Value.Group()
+	if len(attrs) == 0 {
+		return kvs
+	}
+	if a.Key == "" {
+		for _, ga := range attrs {
+			kvs = appendAttr(kvs, ga)
+		}
+		return kvs
+	}
+	var inner []keyValue
+	for _, ga := range attrs {
+		inner = appendAttr(inner, ga)
+	}
+	return append(kvs, keyValue{Key: a.Key, Value: anyValue{KvlistValue: &kvList{Values: inner}}})
+}
+
+// sortKeyValues sorts attributes by key at every nesting level. The sort
+// is stable, so duplicate keys keep their insertion order.
+func sortKeyValues(kvs []keyValue) {
+	sort.SliceStable(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	for _, kv := range kvs {
+		if kv.Value.KvlistValue != nil {
+			sortKeyValues(kv.Value.KvlistValue.Values)
+		}
+	}
+}
+
+func convertValue(v slog.Value) anyValue {
+	switch v.Kind() {
+	case slog.KindString:
+		s := v.String()
+		return anyValue{StringValue: &s}
+	case slog.KindInt64:
+		s := strconv.FormatInt(v.Int64(), 10)
+		return anyValue{IntValue: &s}
+	case slog.KindUint64:
+		s := strconv.FormatUint(v.Uint64(), 10)
+		return anyValue{IntValue: &s}
+	case slog.KindFloat64:
+		f := v.Float64()
+		return anyValue{DoubleValue: &f}
+	case slog.KindBool:
+		b := v.Bool()
+		return anyValue{BoolValue: &b}
+	case slog.KindDuration:
+		s := strconv.FormatInt(int64(v.Duration()), 10)
+		return anyValue{IntValue: &s}
+	case slog.KindTime:
+		s := v.Time().Format(time.RFC3339Nano)
+		return anyValue{StringValue: &s}
+	}
+	switch x := v.Any().(type) {
+	case []byte:
+		s := base64.StdEncoding.EncodeToString(x)
+		return anyValue{BytesValue: &s}
+	case error:
+		s := x.Error()
+		return anyValue{StringValue: &s}
+	}
+	s := fmt.Sprint(v.Any())
+	return anyValue{StringValue: &s}
+}
+
+type exportRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resourceValue `json:"resource"`
+	ScopeLogs []scopeLogs   `json:"scopeLogs"`
+}
+
+type resourceValue struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+}
+
+type scopeLogs struct {
+	Scope      instrumentationScope `json:"scope"`
+	LogRecords []logRecord          `json:"logRecords"`
+}
+
+type instrumentationScope struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 anyValue   `json:"body"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+	Flags                uint32     `json:"flags,omitempty"`
+	TraceID              string     `json:"traceId,omitempty"`
+	SpanID               string     `json:"spanId,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type kvList struct {
+	Values []keyValue `json:"values"`
+}
+
+type anyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BytesValue  *string  `json:"bytesValue,omitempty"`
+	KvlistValue *kvList  `json:"kvlistValue,omitempty"`
+}
+
+type handlerOptions struct {
+	writer       io.Writer
+	level        slog.Leveler
+	resource     []slog.Attr
+	scopeName    string
+	scopeVersion string
+	table        *severity.Table
+	sorted       bool
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithWriter sets the writer where records will be written.
+// If writer is nil, output will be discarded.
+func WithWriter(writer io.Writer) Option {
+	return func(h *handlerOptions) {
+		if writer == nil {
+			writer = io.Discard
+		}
+		h.writer = writer
+	}
+}
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithResource sets the resource attributes, e.g. service.name, attached
+// to every exported record.
+func WithResource(attrs ...slog.Attr) Option {
+	return func(h *handlerOptions) {
+		h.resource = append(h.resource, attrs...)
+	}
+}
+
+// WithScope sets the instrumentation scope name and version.
+func WithScope(name, version string) Option {
+	return func(h *handlerOptions) {
+		h.scopeName = name
+		h.scopeVersion = version
+	}
+}
+
+// WithSeverityTable sets the table used to map levels to OTLP severities.
+// The default table from the severity package is used otherwise.
+func WithSeverityTable(t *severity.Table) Option {
+	return func(h *handlerOptions) {
+		h.table = t
+	}
+}
+
+// WithSortedKeys sorts attributes by key at every nesting level instead of
+// preserving the order in which they were added. Either order produces
+// byte-stable output for equal records.
+func WithSortedKeys(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.sorted = true
+		for i := range x {
+			h.sorted = x[i]
+		}
+	}
+}
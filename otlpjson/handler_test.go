@@ -0,0 +1,152 @@
+package otlpjson
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func decode(t *testing.T, line string) map[string]any {
+	t.Helper()
+	var v map[string]any
+	if err := json.Unmarshal([]byte(line), &v); err != nil {
+		t.Fatalf("failed to decode output %q: %v", line, err)
+	}
+	return v
+}
+
+func firstRecord(t *testing.T, v map[string]any) map[string]any {
+	t.Helper()
+	rl := v["resourceLogs"].([]any)[0].(map[string]any)
+	sl := rl["scopeLogs"].([]any)[0].(map[string]any)
+	return sl["logRecords"].([]any)[0].(map[string]any)
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("writes one export request per line", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(
+			WithWriter(buf),
+			WithResource(slog.String("service.name", "checkout")),
+			WithScope("app", "1.0.0"),
+		))
+
+		logger.Info("first", "count", 3)
+		logger.Warn("second")
+
+		lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+		if len(lines) != 2 {
+			t.Fatalf("expected 2 lines, got %d: %s", len(lines), buf.String())
+		}
+
+		v := decode(t, lines[0])
+		rl := v["resourceLogs"].([]any)[0].(map[string]any)
+		res := rl["resource"].(map[string]any)["attributes"].([]any)[0].(map[string]any)
+		if res["key"] != "service.name" || res["value"].(map[string]any)["stringValue"] != "checkout" {
+			t.Errorf("unexpected resource attributes: %v", res)
+		}
+
+		rec := firstRecord(t, v)
+		if rec["severityNumber"] != float64(9) || rec["severityText"] != "INFO" {
+			t.Errorf("unexpected severity: %v %v", rec["severityNumber"], rec["severityText"])
+		}
+		if rec["body"].(map[string]any)["stringValue"] != "first" {
+			t.Errorf("unexpected body: %v", rec["body"])
+		}
+		attr := rec["attributes"].([]any)[0].(map[string]any)
+		if attr["key"] != "count" || attr["value"].(map[string]any)["intValue"] != "3" {
+			t.Errorf("unexpected attribute: %v", attr)
+		}
+
+		rec = firstRecord(t, decode(t, lines[1]))
+		if rec["severityNumber"] != float64(13) {
+			t.Errorf("unexpected warn severity: %v", rec["severityNumber"])
+		}
+	})
+
+	t.Run("groups become nested kvlists", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf)))
+
+		logger.With("svc", "api").WithGroup("req").With("id", 1).Info("hello", "path", "/")
+
+		rec := firstRecord(t, decode(t, buf.String()))
+		attrs := rec["attributes"].([]any)
+		if len(attrs) != 2 {
+			t.Fatalf("expected 2 root attributes, got %v", attrs)
+		}
+		group := attrs[1].(map[string]any)
+		if group["key"] != "req" {
+			t.Fatalf("expected req group, got %v", group)
+		}
+		values := group["value"].(map[string]any)["kvlistValue"].(map[string]any)["values"].([]any)
+		if len(values) != 2 {
+			t.Errorf("expected id and path in group, got %v", values)
+		}
+	})
+
+	t.Run("empty trailing groups are omitted", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		slog.New(NewHandler(WithWriter(buf))).WithGroup("empty").Info("hello")
+
+		rec := firstRecord(t, decode(t, buf.String()))
+		if _, ok := rec["attributes"]; ok {
+			t.Errorf("unexpected attributes: %v", rec["attributes"])
+		}
+	})
+
+	t.Run("trace context is exported", func(t *testing.T) {
+		tracer := sdktrace.NewTracerProvider().Tracer("test-tracer")
+		ctx, span := tracer.Start(context.Background(), "test-span")
+		defer span.End()
+
+		buf := new(bytes.Buffer)
+		slog.New(NewHandler(WithWriter(buf))).InfoContext(ctx, "traced")
+
+		rec := firstRecord(t, decode(t, buf.String()))
+		if rec["traceId"] != span.SpanContext().TraceID().String() {
+			t.Errorf("unexpected traceId: %v", rec["traceId"])
+		}
+		if rec["spanId"] != span.SpanContext().SpanID().String() {
+			t.Errorf("unexpected spanId: %v", rec["spanId"])
+		}
+		if rec["flags"] != float64(1) {
+			t.Errorf("unexpected flags: %v", rec["flags"])
+		}
+	})
+}
+
+func Test_KeyOrder(t *testing.T) {
+	keys := func(t *testing.T, line string) []string {
+		rec := firstRecord(t, decode(t, line))
+		var out []string
+		for _, a := range rec["attributes"].([]any) {
+			out = append(out, a.(map[string]any)["key"].(string))
+		}
+		return out
+	}
+
+	t.Run("insertion order by default", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		slog.New(NewHandler(WithWriter(buf))).Info("msg", "b", 1, "a", 2, "c", 3)
+		if got := strings.Join(keys(t, buf.String()), ","); got != "b,a,c" {
+			t.Errorf("unexpected order: %s", got)
+		}
+	})
+
+	t.Run("sorted order", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		slog.New(NewHandler(WithWriter(buf), WithSortedKeys())).Info("msg", "b", 1, "a", 2, slog.Group("c", "z", 1, "y", 2))
+		if got := strings.Join(keys(t, buf.String()), ","); got != "a,b,c" {
+			t.Errorf("unexpected order: %s", got)
+		}
+		if !strings.Contains(buf.String(), `"values":[{"key":"y"`) {
+			t.Errorf("expected nested keys to be sorted: %s", buf.String())
+		}
+	})
+}
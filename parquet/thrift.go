@@ -0,0 +1,90 @@
+package parquet
+
+import (
+	"encoding/binary"
+)
+
+// Types of the Thrift compact protocol.
+const (
+	tTrue   = 1
+	tFalse  = 2
+	tI32    = 5
+	tI64    = 6
+	tBinary = 8
+	tList   = 9
+	tStruct = 12
+)
+
+// encoder writes the Thrift compact protocol, in which the Parquet file
+// metadata and page headers are serialized.
+type encoder struct {
+	buf  []byte
+	last []int16 // Last field ID of the enclosing structs
+}
+
+func (e *encoder) begin() {
+	e.last = append(e.last, 0)
+}
+
+func (e *encoder) end() {
+	e.buf = append(e.buf, 0) // STOP
+	e.last = e.last[:len(e.last)-1]
+}
+
+func (e *encoder) field(id int16, typ byte) {
+	last := &e.last[len(e.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		e.buf = append(e.buf, byte(delta)<<4|typ)
+	} else {
+		e.buf = append(e.buf, typ)
+		e.varint(int64(id))
+	}
+	*last = id
+}
+
+func (e *encoder) varint(v int64) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(v<<1^v>>63))
+}
+
+func (e *encoder) i32(id int16, v int32) {
+	e.field(id, tI32)
+	e.varint(int64(v))
+}
+
+func (e *encoder) i64(id int16, v int64) {
+	e.field(id, tI64)
+	e.varint(v)
+}
+
+func (e *encoder) bool(id int16, v bool) {
+	if v {
+		e.field(id, tTrue)
+	} else {
+		e.field(id, tFalse)
+	}
+}
+
+func (e *encoder) binary(id int16, s string) {
+	e.field(id, tBinary)
+	e.rawBinary(s)
+}
+
+func (e *encoder) rawBinary(s string) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) list(id int16, typ byte, n int) {
+	e.field(id, tList)
+	if n < 15 {
+		e.buf = append(e.buf, byte(n)<<4|typ)
+	} else {
+		e.buf = append(e.buf, 0xf0|typ)
+		e.buf = binary.AppendUvarint(e.buf, uint64(n))
+	}
+}
+
+func (e *encoder) structField(id int16) {
+	e.field(id, tStruct)
+	e.begin()
+}
@@ -0,0 +1,581 @@
+// Package parquet writes slog records into Apache Parquet files, one
+// column per configured attribute, for direct analytics with engines such
+// as DuckDB and Spark.
+package parquet
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/snappy"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/internal/jsonvalue"
+)
+
+// ErrClosed is returned by Write after the writer has been closed.
+var ErrClosed = errors.New("slogging: parquet writer is closed")
+
+// Type is the type of a column.
+type Type int
+
+const (
+	// String columns hold UTF-8 strings. Values of other kinds are
+	// rendered as JSON.
+	String Type = iota
+	// Int64 columns hold signed integers. Durations are stored as
+	// nanoseconds and unsigned integers are converted.
+	Int64
+	// Float64 columns hold double precision numbers.
+	Float64
+	// Bool columns hold booleans.
+	Bool
+	// Timestamp columns hold times as microseconds since the epoch, UTC.
+	Timestamp
+)
+
+func (t Type) String() string {
+	switch t {
+	case Int64:
+		return "INT64"
+	case Float64:
+		return "DOUBLE"
+	case Bool:
+		return "BOOLEAN"
+	case Timestamp:
+		return "TIMESTAMP"
+	}
+	return "STRING"
+}
+
+// Column maps an attribute to a column. Name is the path of the attribute
+// with groups joined by ".", e.g. "http.status", and is used as the column
+// name. slog.TimeKey, slog.LevelKey and slog.MessageKey select the time,
+// level and message of the record.
+type Column struct {
+	Name string
+	Type Type
+}
+
+// Columns returns the columns of the time, level and message of records,
+// followed by the given columns.
+func Columns(columns ...Column) []Column {
+	return append([]Column{
+		{Name: slog.TimeKey, Type: Timestamp},
+		{Name: slog.LevelKey, Type: String},
+		{Name: slog.MessageKey, Type: String},
+	}, columns...)
+}
+
+// Infer returns the columns of the time, level and message of records,
+// followed by one column per attribute path found in records, sorted by
+// name. The type of a column is that of the values of its attribute, or
+// String if they differ.
+func Infer(records ...slog.Record) []Column {
+	types := map[string]Type{}
+	var walk func(prefix string, m map[string]any)
+	walk = func(prefix string, m map[string]any) {
+		for k, v := range m {
+			if inner, ok := v.(map[string]any); ok {
+				walk(prefix+k+".", inner)
+				continue
+			}
+			name := prefix + k
+			t := typeOf(v)
+			if prev, ok := types[name]; ok && prev != t {
+				t = String
+			}
+			types[name] = t
+		}
+	}
+	for _, r := range records {
+		m := slogging.ToMap(r)
+		delete(m, slog.TimeKey)
+		delete(m, slog.LevelKey)
+		delete(m, slog.MessageKey)
+		walk("", m)
+	}
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	columns := make([]Column, len(names))
+	for i, name := range names {
+		columns[i] = Column{Name: name, Type: types[name]}
+	}
+	return Columns(columns...)
+}
+
+func typeOf(v any) Type {
+	switch v.(type) {
+	case int64, uint64, time.Duration:
+		return Int64
+	case float64:
+		return Float64
+	case bool:
+		return Bool
+	case time.Time:
+		return Timestamp
+	}
+	return String
+}
+
+// Compression is the compression codec of column chunks.
+type Compression int
+
+const (
+	Uncompressed Compression = 0
+	Snappy       Compression = 1
+)
+
+// Writer writes records into a Parquet file. Rows are buffered and written
+// as row groups; every column is optional, attributes missing from a
+// record are null. Attributes not mapped to a column are written as a JSON
+// object to the rest column, unless disabled with WithRest. Writer is not
+// safe for concurrent use.
+type Writer struct {
+	w       io.Writer
+	config  handlerOptions
+	columns []*column
+	mapped  int // Number of columns mapped to attributes
+	rows    int
+	offset  int64
+	groups  []rowGroup
+	closed  bool
+}
+
+type column struct {
+	Column
+	path []string
+	defs []bool // Definition level of every row
+	data []byte // Plain encoded values
+	bits []bool // Values of Bool columns, bit-packed when flushed
+}
+
+type rowGroup struct {
+	rows   int
+	size   int64
+	chunks []chunk
+}
+
+type chunk struct {
+	offset int64
+	values int
+	size   int64 // Uncompressed
+	csize  int64 // Compressed
+}
+
+const restColumn = "attrs"
+
+// NewWriter creates a writer of the given columns, as returned by Columns
+// or Infer, writing to w.
+func NewWriter(w io.Writer, columns []Column, options ...Option) *Writer {
+	config := handlerOptions{
+		rowGroupSize: 10000,
+		compression:  Snappy,
+		rest:         restColumn,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	pw := &Writer{w: w, config: config}
+	for _, c := range columns {
+		pw.columns = append(pw.columns, &column{Column: c, path: strings.Split(c.Name, ".")})
+	}
+	pw.mapped = len(pw.columns)
+	if config.rest != "" {
+		pw.columns = append(pw.columns, &column{Column: Column{Name: config.rest, Type: String}, path: []string{config.rest}})
+	}
+	return pw
+}
+
+// Write adds records to the file, writing a row group whenever the
+// configured number of rows is buffered.
+func (w *Writer) Write(records ...slog.Record) error {
+	if w.closed {
+		return ErrClosed
+	}
+	for _, r := range records {
+		w.add(r)
+		if w.rows >= w.config.rowGroupSize {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Writer) add(r slog.Record) {
+	m := slogging.ToMap(r)
+	for _, c := range w.columns[:w.mapped] {
+		v, ok := take(m, c.path)
+		c.append(v, ok)
+	}
+	if w.config.rest != "" {
+		rest := w.columns[w.mapped]
+		delete(m, slog.TimeKey)
+		delete(m, slog.LevelKey)
+		delete(m, slog.MessageKey)
+		rest.append(m, len(m) > 0)
+	}
+	w.rows++
+}
+
+// take removes and returns the value at path, removing groups left empty.
+func take(m map[string]any, path []string) (any, bool) {
+	v, ok := m[path[0]]
+	if !ok {
+		return nil, false
+	}
+	if len(path) == 1 {
+		delete(m, path[0])
+		return v, true
+	}
+	inner, ok := v.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	v, ok = take(inner, path[1:])
+	if len(inner) == 0 {
+		delete(m, path[0])
+	}
+	return v, ok
+}
+
+func (c *column) append(v any, ok bool) {
+	if ok {
+		ok = c.encode(v)
+	}
+	c.defs = append(c.defs, ok)
+}
+
+// encode appends v in the plain encoding of the column type and reports
+// whether v could be converted.
+func (c *column) encode(v any) bool {
+	switch c.Type {
+	case Int64:
+		var n int64
+		switch v := v.(type) {
+		case int64:
+			n = v
+		case uint64:
+			n = int64(v)
+		case time.Duration:
+			n = int64(v)
+		case float64:
+			n = int64(v)
+		default:
+			return false
+		}
+		c.data = binary.LittleEndian.AppendUint64(c.data, uint64(n))
+	case Float64:
+		var f float64
+		switch v := v.(type) {
+		case float64:
+			f = v
+		case int64:
+			f = float64(v)
+		case uint64:
+			f = float64(v)
+		default:
+			return false
+		}
+		c.data = binary.LittleEndian.AppendUint64(c.data, math.Float64bits(f))
+	case Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return false
+		}
+		c.bits = append(c.bits, b)
+	case Timestamp:
+		t, ok := v.(time.Time)
+		if !ok {
+			return false
+		}
+		c.data = binary.LittleEndian.AppendUint64(c.data, uint64(t.UnixMicro()))
+	default:
+		var s string
+		switch v := v.(type) {
+		case string:
+			s = v
+		case fmt.Stringer:
+			s = v.String()
+		case error:
+			s = v.Error()
+		default:
+			b, err := json.Marshal(jsonvalue.Convert(v))
+			if err != nil {
+				s = fmt.Sprint(v)
+			} else {
+				s = string(b)
+			}
+		}
+		c.data = binary.LittleEndian.AppendUint32(c.data, uint32(len(s)))
+		c.data = append(c.data, s...)
+	}
+	return true
+}
+
+// Flush writes the buffered rows as a row group.
+func (w *Writer) Flush() error {
+	if w.rows == 0 {
+		return nil
+	}
+	if w.offset == 0 {
+		if err := w.write([]byte(magic)); err != nil {
+			return err
+		}
+	}
+	g := rowGroup{rows: w.rows}
+	for _, c := range w.columns {
+		ch, err := w.writeChunk(c)
+		if err != nil {
+			return err
+		}
+		g.size += ch.size
+		g.chunks = append(g.chunks, ch)
+		c.defs, c.data, c.bits = c.defs[:0], c.data[:0], c.bits[:0]
+	}
+	w.groups = append(w.groups, g)
+	w.rows = 0
+	return nil
+}
+
+const magic = "PAR1"
+
+// Parquet enumerations.
+const (
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+
+	convertedUTF8            = 0
+	convertedTimestampMicros = 10
+
+	repetitionOptional = 1
+
+	encodingPlain = 0
+	encodingRLE   = 3
+
+	pageData = 0
+)
+
+func physical(t Type) int32 {
+	switch t {
+	case Int64, Timestamp:
+		return typeInt64
+	case Float64:
+		return typeDouble
+	case Bool:
+		return typeBoolean
+	}
+	return typeByteArray
+}
+
+// writeChunk writes the column chunk of c as a single data page.
+func (w *Writer) writeChunk(c *column) (chunk, error) {
+	levels := rleBools(c.defs)
+	page := binary.LittleEndian.AppendUint32(nil, uint32(len(levels)))
+	page = append(page, levels...)
+	if c.Type == Bool {
+		page = append(page, packBools(c.bits)...)
+	} else {
+		page = append(page, c.data...)
+	}
+	data := page
+	if w.config.compression == Snappy {
+		data = snappy.Encode(nil, page)
+	}
+
+	var e encoder
+	e.begin()
+	e.i32(1, pageData)
+	e.i32(2, int32(len(page)))
+	e.i32(3, int32(len(data)))
+	e.structField(5)
+	e.i32(1, int32(len(c.defs)))
+	e.i32(2, encodingPlain)
+	e.i32(3, encodingRLE)
+	e.i32(4, encodingRLE)
+	e.end()
+	e.end()
+
+	ch := chunk{
+		offset: w.offset,
+		values: len(c.defs),
+		size:   int64(len(e.buf) + len(page)),
+		csize:  int64(len(e.buf) + len(data)),
+	}
+	if err := w.write(e.buf); err != nil {
+		return chunk{}, err
+	}
+	return ch, w.write(data)
+}
+
+func (w *Writer) write(b []byte) error {
+	n, err := w.w.Write(b)
+	w.offset += int64(n)
+	if err != nil {
+		return fmt.Errorf("error when writing parquet file: %w", err)
+	}
+	return nil
+}
+
+// rleBools encodes definition levels of bit width 1 as runs of the RLE
+// hybrid encoding.
+func rleBools(defs []bool) []byte {
+	var out []byte
+	for i := 0; i < len(defs); {
+		j := i
+		for j < len(defs) && defs[j] == defs[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		if defs[i] {
+			out = append(out, 1)
+		} else {
+			out = append(out, 0)
+		}
+		i = j
+	}
+	return out
+}
+
+// packBools bit-packs values, least significant bit first.
+func packBools(bits []bool) []byte {
+	out := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+// Close writes the buffered rows and the file footer. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	w.closed = true
+	if w.offset == 0 {
+		if err := w.write([]byte(magic)); err != nil {
+			return err
+		}
+	}
+	meta := w.metadata()
+	footer := binary.LittleEndian.AppendUint32(meta, uint32(len(meta)))
+	return w.write(append(footer, magic...))
+}
+
+// metadata encodes the FileMetaData of the file.
+func (w *Writer) metadata() []byte {
+	var e encoder
+	e.begin()
+	e.i32(1, 1)
+
+	e.list(2, tStruct, len(w.columns)+1)
+	e.begin()
+	e.binary(4, "schema")
+	e.i32(5, int32(len(w.columns)))
+	e.end()
+	for _, c := range w.columns {
+		e.begin()
+		e.i32(1, physical(c.Type))
+		e.i32(3, repetitionOptional)
+		e.binary(4, c.Name)
+		switch c.Type {
+		case String:
+			e.i32(6, convertedUTF8)
+		case Timestamp:
+			e.i32(6, convertedTimestampMicros)
+		}
+		e.end()
+	}
+
+	var rows int64
+	for _, g := range w.groups {
+		rows += int64(g.rows)
+	}
+	e.i64(3, rows)
+
+	e.list(4, tStruct, len(w.groups))
+	for _, g := range w.groups {
+		e.begin()
+		e.list(1, tStruct, len(g.chunks))
+		for i, ch := range g.chunks {
+			c := w.columns[i]
+			e.begin()
+			e.i64(2, ch.offset)
+			e.structField(3)
+			e.i32(1, physical(c.Type))
+			e.list(2, tI32, 2)
+			e.varint(encodingPlain)
+			e.varint(encodingRLE)
+			e.list(3, tBinary, 1)
+			e.rawBinary(c.Name)
+			e.i32(4, int32(w.config.compression))
+			e.i64(5, int64(ch.values))
+			e.i64(6, ch.size)
+			e.i64(7, ch.csize)
+			e.i64(9, ch.offset)
+			e.end()
+			e.end()
+		}
+		e.i64(2, g.size)
+		e.i64(3, int64(g.rows))
+		e.end()
+	}
+	e.binary(6, "github.com/mikluko/slogging/parquet")
+	e.end()
+	return e.buf
+}
+
+type handlerOptions struct {
+	rowGroupSize int
+	compression  Compression
+	rest         string
+}
+
+// Option is a function that configures a Writer.
+type Option func(h *handlerOptions)
+
+// WithRowGroupSize sets the number of rows of row groups, 10000 by default.
+func WithRowGroupSize(n int) Option {
+	return func(h *handlerOptions) {
+		h.rowGroupSize = max(n, 1)
+	}
+}
+
+// WithCompression sets the compression codec, Snappy by default.
+func WithCompression(c Compression) Option {
+	return func(h *handlerOptions) {
+		h.compression = c
+	}
+}
+
+// WithRest sets the name of the column holding the attributes not mapped
+// to a column as a JSON object, "attrs" by default. An empty name drops
+// them.
+func WithRest(name string) Option {
+	return func(h *handlerOptions) {
+		h.rest = name
+	}
+}
@@ -0,0 +1,220 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+// decoder reads the Thrift compact protocol into generic values: structs
+// as map[int16]any, lists as []any, integers as int64, binaries as string.
+type decoder struct {
+	b []byte
+	t *testing.T
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.t.Fatalf("bad varint")
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	u := d.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (d *decoder) value(typ byte) any {
+	switch typ {
+	case tTrue:
+		return true
+	case tFalse:
+		return false
+	case tI32, tI64:
+		return d.varint()
+	case tBinary:
+		n := d.uvarint()
+		s := string(d.b[:n])
+		d.b = d.b[n:]
+		return s
+	case tList:
+		h := d.b[0]
+		d.b = d.b[1:]
+		n, et := int(h>>4), h&0x0f
+		if n == 15 {
+			n = int(d.uvarint())
+		}
+		l := make([]any, n)
+		for i := range l {
+			if et == tTrue { // Booleans in lists are one byte
+				l[i] = d.b[0] == 1
+				d.b = d.b[1:]
+				continue
+			}
+			l[i] = d.value(et)
+		}
+		return l
+	case tStruct:
+		return d.structValue()
+	}
+	d.t.Fatalf("unexpected type %d", typ)
+	return nil
+}
+
+func (d *decoder) structValue() map[int16]any {
+	m := map[int16]any{}
+	var last int16
+	for {
+		h := d.b[0]
+		d.b = d.b[1:]
+		if h == 0 {
+			return m
+		}
+		typ := h & 0x0f
+		if delta := int16(h >> 4); delta != 0 {
+			last += delta
+		} else {
+			last = int16(d.varint())
+		}
+		m[last] = d.value(typ)
+	}
+}
+
+type file struct {
+	data []byte
+	meta map[int16]any
+}
+
+func parse(t *testing.T, data []byte) file {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte(magic)) || !bytes.HasSuffix(data, []byte(magic)) {
+		t.Fatalf("missing magic")
+	}
+	n := binary.LittleEndian.Uint32(data[len(data)-8:])
+	d := &decoder{b: data[len(data)-8-int(n) : len(data)-8], t: t}
+	return file{data: data, meta: d.structValue()}
+}
+
+// column decodes the definition levels and the values of the chunk of
+// column i of row group g.
+func (f file) column(t *testing.T, g, i int) ([]bool, []byte) {
+	t.Helper()
+	group := f.meta[4].([]any)[g].(map[int16]any)
+	md := group[1].([]any)[i].(map[int16]any)[3].(map[int16]any)
+	d := &decoder{b: f.data[md[9].(int64):], t: t}
+	header := d.structValue()
+	page := d.b[:header[3].(int64)]
+	if md[4].(int64) == int64(Snappy) {
+		var err error
+		if page, err = snappy.Decode(nil, page); err != nil {
+			t.Fatal(err)
+		}
+	}
+	n := int(header[5].(map[int16]any)[1].(int64))
+	size := binary.LittleEndian.Uint32(page)
+	levels, values := &decoder{b: page[4 : 4+size], t: t}, page[4+size:]
+	var defs []bool
+	for len(defs) < n {
+		run := levels.uvarint() >> 1
+		v := levels.b[0] == 1
+		levels.b = levels.b[1:]
+		for range run {
+			defs = append(defs, v)
+		}
+	}
+	return defs, values
+}
+
+func strs(values []byte) []string {
+	var out []string
+	for len(values) > 0 {
+		n := binary.LittleEndian.Uint32(values)
+		out = append(out, string(values[4:4+n]))
+		values = values[4+n:]
+	}
+	return out
+}
+
+func Test_Writer(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	records := make([]slog.Record, 3)
+	for i := range records {
+		records[i] = slog.NewRecord(ts.Add(time.Duration(i)*time.Second), slog.LevelInfo, "request", 0)
+	}
+	records[0].AddAttrs(slog.Group("http", slog.Int("status", 200), slog.Duration("took", time.Millisecond)), slog.Bool("ok", true))
+	records[1].AddAttrs(slog.Group("http", slog.Int("status", 500)), slog.Bool("ok", false), slog.Any("extra", errors.New("boom")))
+	records[2].Level = slog.LevelWarn
+	records[2].AddAttrs(slog.Float64("ratio", 0.5))
+
+	t.Run("schema is inferred from records", func(t *testing.T) {
+		columns := Infer(records...)
+		var got []string
+		for _, c := range columns {
+			got = append(got, c.Name+":"+c.Type.String())
+		}
+		want := "time:TIMESTAMP level:STRING msg:STRING extra:STRING http.status:INT64 http.took:INT64 ok:BOOLEAN ratio:DOUBLE"
+		if strings.Join(got, " ") != want {
+			t.Errorf("unexpected columns: %s", got)
+		}
+	})
+
+	for name, compression := range map[string]Compression{"uncompressed": Uncompressed, "snappy": Snappy} {
+		t.Run(name+" file round trip", func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			columns := Columns(Column{Name: "http.status", Type: Int64}, Column{Name: "ok", Type: Bool}, Column{Name: "ratio", Type: Float64})
+			w := NewWriter(buf, columns, WithRowGroupSize(2), WithCompression(compression))
+			if err := w.Write(records...); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Write(records[0]); err != ErrClosed {
+				t.Errorf("expected ErrClosed, got %v", err)
+			}
+
+			f := parse(t, buf.Bytes())
+			if f.meta[3].(int64) != 3 || len(f.meta[4].([]any)) != 2 {
+				t.Fatalf("unexpected rows and groups: %v", f.meta)
+			}
+			schema := f.meta[2].([]any)
+			if len(schema) != 8 || schema[4].(map[int16]any)[4] != "http.status" || schema[7].(map[int16]any)[4] != "attrs" {
+				t.Errorf("unexpected schema: %v", schema)
+			}
+
+			defs, values := f.column(t, 0, 0)
+			if len(defs) != 2 || int64(binary.LittleEndian.Uint64(values)) != ts.UnixMicro() {
+				t.Errorf("unexpected time column: %v %v", defs, values)
+			}
+			if _, values = f.column(t, 1, 1); strings.Join(strs(values), ",") != "WARN" {
+				t.Errorf("unexpected level column: %q", strs(values))
+			}
+			if defs, values = f.column(t, 0, 3); !defs[0] || !defs[1] || binary.LittleEndian.Uint64(values[8:]) != 500 {
+				t.Errorf("unexpected status column: %v %v", defs, values)
+			}
+			if defs, values = f.column(t, 0, 4); len(values) != 1 || values[0] != 1 {
+				t.Errorf("unexpected ok column: %v %v", defs, values)
+			}
+			if defs, values = f.column(t, 1, 5); !defs[0] || math.Float64frombits(binary.LittleEndian.Uint64(values)) != 0.5 {
+				t.Errorf("unexpected ratio column: %v %v", defs, values)
+			}
+			defs, values = f.column(t, 0, 6)
+			if got := strings.Join(strs(values), ","); got != `{"http":{"took":1000000}},{"extra":"boom"}` || !defs[0] || !defs[1] {
+				t.Errorf("unexpected rest column: %s", got)
+			}
+			if defs, _ = f.column(t, 1, 6); defs[0] {
+				t.Errorf("expected null rest column")
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+// Package policy checks the construction of handler pipelines against
+// compliance policies, such as redaction of secrets and PII before any
+// record can reach a network or disk sink, failing fast on
+// misconfiguration instead of leaking data at runtime.
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// ErrUnredacted is returned when a sink can be reached without passing
+// through a redaction stage.
+var ErrUnredacted = errors.New("slogging: sink reachable without redaction")
+
+// Class is the role of a stage of a pipeline.
+type Class int
+
+const (
+	// PassThrough stages are traversed to the handlers and writers they
+	// deliver to.
+	PassThrough Class = iota
+	// Redaction stages remove secrets and PII from the records delivered
+	// to the stages after them.
+	Redaction
+	// Sink stages persist records to disk or send them over the network.
+	Sink
+)
+
+const module = "github.com/mikluko/slogging/"
+
+// defaultClasses classifies the packages of this module by import path.
+var defaultClasses = map[string]Class{
+	module + "redact":     Redaction,
+	module + "alert":      Sink,
+	module + "bigquery":   Sink,
+	module + "cloudwatch": Sink,
+	module + "gelf":       Sink,
+	module + "journald":   Sink,
+	module + "kafka":      Sink,
+	module + "loki":       Sink,
+	module + "parquet":    Sink,
+	module + "rotate":     Sink,
+	module + "sentry":     Sink,
+	module + "sink":       Sink,
+	module + "spool":      Sink,
+	module + "syslog":     Sink,
+}
+
+var (
+	handlerType = reflect.TypeFor[slog.Handler]()
+	writerType  = reflect.TypeFor[io.Writer]()
+	connType    = reflect.TypeFor[net.Conn]()
+	fileType    = reflect.TypeFor[*os.File]()
+)
+
+// Violation is a path from the checked handler to a sink not preceded by
+// a redaction stage.
+type Violation struct {
+	Path []string // Types of the stages from the checked handler to the sink
+}
+
+// Error returns the path as "slogging: sink reachable without redaction:
+// *async.Handler > *multi.Handler > *loki.Handler".
+func (v *Violation) Error() string {
+	return ErrUnredacted.Error() + ": " + strings.Join(v.Path, " > ")
+}
+
+// Unwrap returns ErrUnredacted.
+func (v *Violation) Unwrap() error {
+	return ErrUnredacted
+}
+
+// Check verifies that every sink reachable from handler, along every
+// branch of fan-out and routing handlers, is preceded by a redaction
+// stage, returning the violations joined with errors.Join.
+//
+// The pipeline is inspected with reflection: every handler and writer held
+// by a stage, directly or in slices, maps and structs, is a stage after
+// it. The packages of this module are classified by their role: redact is
+// a redaction stage; loki, cloudwatch, kafka, bigquery, gelf, syslog,
+// journald, alert, sentry, rotate, spool, parquet and sink are sinks.
+// Files, including standard output, and network connections are sinks
+// too. Other stages are traversed; stages hidden behind functions or
+// atomic pointers cannot be seen. Use WithClass to classify the types of
+// other packages and WithExempt to exempt given sinks.
+func Check(handler slog.Handler, options ...Option) error {
+	config := handlerOptions{types: make(map[reflect.Type]Class), exempt: make(map[uintptr]bool)}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	c := &checker{config: config, seen: make(map[visit]bool)}
+	c.walk(reflect.ValueOf(handler), nil, false)
+	return errors.Join(c.errs...)
+}
+
+// Must returns handler if Check succeeds and panics otherwise, to fail
+// fast when building a pipeline:
+//
+//	logger := slog.New(policy.Must(redact.Wrap(loki.Wrap(...))))
+func Must(handler slog.Handler, options ...Option) slog.Handler {
+	if err := Check(handler, options...); err != nil {
+		panic(err)
+	}
+	return handler
+}
+
+type visit struct {
+	ptr      uintptr
+	typ      reflect.Type
+	redacted bool
+}
+
+type checker struct {
+	config handlerOptions
+	seen   map[visit]bool
+	errs   []error
+}
+
+func (c *checker) class(v reflect.Value) Class {
+	if v.Kind() == reflect.Pointer && c.config.exempt[v.Pointer()] {
+		return PassThrough
+	}
+	t := v.Type()
+	if cl, ok := c.config.types[t]; ok {
+		return cl
+	}
+	if t == fileType || t.Implements(connType) {
+		return Sink
+	}
+	pt := t
+	if pt.Kind() == reflect.Pointer {
+		pt = pt.Elem()
+	}
+	return defaultClasses[pt.PkgPath()]
+}
+
+// walk inspects v, a stage if it is a handler or writer.
+func (c *checker) walk(v reflect.Value, path []string, redacted bool) {
+	if v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return
+	}
+	t := v.Type()
+	if t.Implements(handlerType) || t.Implements(writerType) {
+		if v.Kind() == reflect.Pointer && v.IsNil() {
+			return
+		}
+		path = append(path[:len(path):len(path)], t.String())
+		switch c.class(v) {
+		case Redaction:
+			redacted = true
+		case Sink:
+			if !redacted {
+				c.errs = append(c.errs, &Violation{Path: path})
+				// Report the first sink of the path only, not the file
+				// of a rotating writer or the handler after sentry.
+				redacted = true
+			}
+		}
+	}
+	c.descend(v, path, redacted)
+}
+
+// descend walks the values held by v.
+func (c *checker) descend(v reflect.Value, path []string, redacted bool) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		key := visit{ptr: v.Pointer(), typ: v.Type(), redacted: redacted}
+		if c.seen[key] {
+			return
+		}
+		c.seen[key] = true
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		c.descendElem(v.Elem(), path, redacted)
+	case reflect.Struct:
+		for i := range v.NumField() {
+			c.walk(v.Field(i), path, redacted)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := range v.Len() {
+			c.walk(v.Index(i), path, redacted)
+		}
+	case reflect.Map:
+		for it := v.MapRange(); it.Next(); {
+			c.walk(it.Value(), path, redacted)
+		}
+	case reflect.Interface:
+		c.walk(v, path, redacted)
+	}
+}
+
+// descendElem walks the value pointed to by a stage without taking it for
+// another stage, as types embedding a handler would be.
+func (c *checker) descendElem(v reflect.Value, path []string, redacted bool) {
+	if v.Kind() == reflect.Struct {
+		c.descend(v, path, redacted)
+		return
+	}
+	c.walk(v, path, redacted)
+}
+
+type handlerOptions struct {
+	types  map[reflect.Type]Class
+	exempt map[uintptr]bool
+}
+
+// Option is a function that configures Check.
+type Option func(h *handlerOptions)
+
+// WithClass classifies the types of the given values, such as a custom
+// redaction handler or a writer shipping logs to a third party:
+//
+//	policy.WithClass(policy.Sink, (*vendor.Writer)(nil))
+//
+// It also overrides the classification of the packages of this module.
+func WithClass(cl Class, values ...any) Option {
+	return func(h *handlerOptions) {
+		for _, v := range values {
+			h.types[reflect.TypeOf(v)] = cl
+		}
+	}
+}
+
+// WithExempt treats the given pointers, such as os.Stdout in development,
+// as pass-through stages whatever their type.
+func WithExempt(values ...any) Option {
+	return func(h *handlerOptions) {
+		for _, v := range values {
+			if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer {
+				h.exempt[rv.Pointer()] = true
+			}
+		}
+	}
+}
+
+// String returns the name of the class.
+func (cl Class) String() string {
+	switch cl {
+	case PassThrough:
+		return "pass-through"
+	case Redaction:
+		return "redaction"
+	case Sink:
+		return "sink"
+	}
+	return fmt.Sprintf("Class(%d)", int(cl))
+}
@@ -0,0 +1,104 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/slogging/async"
+	"github.com/mikluko/slogging/multi"
+	"github.com/mikluko/slogging/redact"
+	"github.com/mikluko/slogging/rotate"
+	"github.com/mikluko/slogging/route"
+)
+
+type scrubber struct{ slog.Handler }
+
+func Test_Check(t *testing.T) {
+	w, err := rotate.Open(filepath.Join(t.TempDir(), "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	conn, peer := net.Pipe()
+	defer conn.Close()
+	defer peer.Close()
+
+	file := slog.NewJSONHandler(w, nil)
+	stdout := slog.NewJSONHandler(os.Stdout, nil)
+	network := slog.NewJSONHandler(conn, nil)
+	memory := slog.NewJSONHandler(new(bytes.Buffer), nil)
+
+	for name, tc := range map[string]struct {
+		handler slog.Handler
+		options []Option
+		paths   []string
+	}{
+		"redacted sinks": {
+			handler: redact.Wrap(multi.New(file, network)),
+		},
+		"in-memory writer": {
+			handler: memory,
+		},
+		"unredacted file": {
+			handler: file,
+			paths:   []string{"*slog.JSONHandler > *rotate.Writer"},
+		},
+		"unredacted branch": {
+			handler: multi.New(redact.Wrap(file), route.New(memory, route.WithDestination("net", network))),
+			paths:   []string{"*multi.Handler > *route.Handler > *slog.JSONHandler > *net.pipe"},
+		},
+		"redaction after a buffering stage": {
+			handler: async.Wrap(redact.Wrap(network)),
+		},
+		"redaction after the sink": {
+			handler: stdout,
+			paths:   []string{"*slog.JSONHandler > *os.File"},
+		},
+		"exempt standard output": {
+			handler: multi.New(stdout, redact.Wrap(file)),
+			options: []Option{WithExempt(os.Stdout)},
+		},
+		"custom redaction stage": {
+			handler: scrubber{file},
+			options: []Option{WithClass(Redaction, scrubber{})},
+		},
+		"custom sink": {
+			handler: redact.Wrap(memory),
+			options: []Option{WithClass(Sink, (*bytes.Buffer)(nil)), WithClass(PassThrough, (*redact.Handler)(nil))},
+			paths:   []string{"*redact.Handler > *slog.JSONHandler > *bytes.Buffer"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := Check(tc.handler, tc.options...)
+			if len(tc.paths) == 0 {
+				if err != nil {
+					t.Errorf("Check() = %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrUnredacted) {
+				t.Fatalf("Check() = %v, want ErrUnredacted", err)
+			}
+			for _, p := range tc.paths {
+				if !strings.Contains(err.Error(), p) {
+					t.Errorf("Check() = %v, want path %s", err, p)
+				}
+			}
+		})
+	}
+
+	t.Run("must", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("Must did not panic")
+			}
+		}()
+		Must(stdout)
+	})
+}
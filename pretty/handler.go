@@ -45,6 +45,17 @@ const (
 	defaultEncoder = JSON
 )
 
+// KeyOrder defines the order in which attribute keys are output.
+type KeyOrder string
+
+const (
+	// Sorted outputs keys of every object in lexical order.
+	Sorted = KeyOrder("sorted")
+	// Insertion outputs keys in the order attributes were added.
+	Insertion       = KeyOrder("insertion")
+	defaultKeyOrder = Sorted
+)
+
 func colorizer(colorCode int, v string) string {
 	return fmt.Sprintf("\033[%sm%s%s", strconv.Itoa(colorCode), v, reset)
 }
@@ -66,6 +77,7 @@ type Handler struct {
 	colorize         bool
 	outputEmptyAttrs bool
 	encoder          Encoder
+	keyOrder         KeyOrder
 }
 
 func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
@@ -82,6 +94,7 @@ func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
 		writer:           h.writer,
 		colorize:         h.colorize,
 		outputEmptyAttrs: h.outputEmptyAttrs,
+		keyOrder:         h.keyOrder,
 	}
 }
 
@@ -95,25 +108,37 @@ func (h *Handler) WithGroup(name string) slog.Handler {
 		writer:           h.writer,
 		colorize:         h.colorize,
 		outputEmptyAttrs: h.outputEmptyAttrs,
+		keyOrder:         h.keyOrder,
 	}
 }
 
-func (h *Handler) computeAttrs(ctx context.Context, r slog.Record) (map[string]any, error) {
+// computeAttrs renders the record attributes with the inner handler and
+// decodes them. The result holds the attributes either in a map, which
+// encoders output in sorted key order, or in an orderedMap.
+func (h *Handler) computeAttrs(ctx context.Context, r slog.Record) (any, int, error) {
 	h.mutex.Lock()
 	defer func() {
 		h.buffer.Reset()
 		h.mutex.Unlock()
 	}()
 	if err := h.handler.Handle(ctx, r); err != nil {
-		return nil, fmt.Errorf("error when calling inner handler's Handle: %w", err)
+		return nil, 0, fmt.Errorf("error when calling inner handler's Handle: %w", err)
+	}
+
+	if h.keyOrder == Insertion {
+		attrs, err := decodeOrdered(h.buffer.Bytes())
+		if err != nil {
+			return nil, 0, fmt.Errorf("error when unmarshaling inner handler's Handle result: %w", err)
+		}
+		return attrs, len(attrs), nil
 	}
 
 	var attrs map[string]any
 	err := json.Unmarshal(h.buffer.Bytes(), &attrs)
 	if err != nil {
-		return nil, fmt.Errorf("error when unmarshaling inner handler's Handle result: %w", err)
+		return nil, 0, fmt.Errorf("error when unmarshaling inner handler's Handle result: %w", err)
 	}
-	return attrs, nil
+	return attrs, len(attrs), nil
 }
 
 func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
@@ -175,13 +200,13 @@ func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
 		msg = colorize(white, msgAttr.Value.String())
 	}
 
-	attrs, err := h.computeAttrs(ctx, r)
+	attrs, numAttrs, err := h.computeAttrs(ctx, r)
 	if err != nil {
 		return err
 	}
 
 	var attrsAsBytes []byte
-	if h.outputEmptyAttrs || len(attrs) > 0 {
+	if h.outputEmptyAttrs || numAttrs > 0 {
 		switch h.encoder {
 		case JSON:
 			attrsAsBytes, err = json.MarshalIndent(attrs, "", "  ")
@@ -242,6 +267,7 @@ type handlerOptions struct {
 	slog.HandlerOptions
 	writer           io.Writer
 	encoder          Encoder
+	keyOrder         KeyOrder
 	colorize         bool
 	outputEmptyAttrs bool
 }
@@ -251,8 +277,9 @@ type handlerOptions struct {
 // Option functions.
 func NewHandler(options ...Option) *Handler {
 	config := handlerOptions{
-		writer:  io.Discard,
-		encoder: defaultEncoder,
+		writer:   io.Discard,
+		encoder:  defaultEncoder,
+		keyOrder: defaultKeyOrder,
 	}
 	for _, opt := range options {
 		if opt != nil {
@@ -267,6 +294,7 @@ func NewHandler(options ...Option) *Handler {
 		encoder:          config.encoder,
 		colorize:         config.colorize,
 		outputEmptyAttrs: config.outputEmptyAttrs,
+		keyOrder:         config.keyOrder,
 		handler: slog.NewJSONHandler(buf, &slog.HandlerOptions{
 			Level:       config.Level,
 			AddSource:   config.AddSource,
@@ -322,6 +350,20 @@ func WithEncoder(e Encoder) Option {
 	}
 }
 
+// WithKeyOrder sets the order in which attribute keys are output.
+// Keys are sorted by default; Insertion preserves the order in which
+// attributes were added. Both orders produce byte-stable output.
+func WithKeyOrder(o KeyOrder) Option {
+	return func(h *handlerOptions) {
+		switch o {
+		case Sorted, Insertion:
+			h.keyOrder = o
+		default:
+			panic(fmt.Sprintf("slogging: unsupported key order %q", o))
+		}
+	}
+}
+
 // WithLevel sets the minimum log level for the handler.
 func WithLevel(lvl slog.Leveler) Option {
 	return func(h *handlerOptions) {
@@ -180,3 +180,56 @@ func Test_Encoder(t *testing.T) {
 		}
 	})
 }
+
+func Test_KeyOrder(t *testing.T) {
+	t.Run("sorted json by default", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		logger := slog.New(NewHandler(WithWriter(buf)))
+
+		logger.Info("testing logger", "b", 1, "a", 2)
+		lines := strings.Split(buf.String(), "\n")
+		if lines[1] != `  "a": 2,` || lines[2] != `  "b": 1` {
+			t.Errorf("expected sorted keys, got: %s", buf.String())
+		}
+	})
+
+	t.Run("insertion json", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		logger := slog.New(NewHandler(WithWriter(buf), WithKeyOrder(Insertion)))
+
+		logger.With("z", "first").WithGroup("g").Info("testing logger", "b", 1, "a", []int{1, 2})
+		want := `{
+  "z": "first",
+  "g": {
+    "b": 1,
+    "a": [
+      1,
+      2
+    ]
+  }
+}`
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected insertion order, got: %s", buf.String())
+		}
+	})
+
+	t.Run("insertion yaml", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		logger := slog.New(NewHandler(WithWriter(buf), WithEncoder(YAML), WithKeyOrder(Insertion)))
+
+		logger.Info("testing logger", "key2", "value2", slog.Group("key1", "b", 1, "a", true))
+		lines := strings.Split(buf.String(), "\n")
+		if lines[1] != "key2: value2" || lines[2] != "key1:" || lines[3] != "    b: 1" || lines[4] != "    a: true" {
+			t.Errorf("expected insertion order, got: %s", buf.String())
+		}
+	})
+
+	t.Run("unsupported order panics", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected panic")
+			}
+		}()
+		NewHandler(WithKeyOrder("random"))
+	})
+}
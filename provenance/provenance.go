@@ -0,0 +1,240 @@
+// Package provenance annotates records with the origin of each of their
+// attributes, to troubleshoot where a field comes from, or why it holds
+// the wrong value, in complex handler chains.
+package provenance
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// Key is the group holding the origins of the attributes of a record.
+const Key = "_provenance"
+
+// Origins of the attributes present before the first stage.
+const (
+	OriginRecord    = "record"
+	OriginWithAttrs = "with_attrs"
+)
+
+// Stage is a middleware of a chain, such as otel.Wrap or ctxattr.Wrap,
+// with the origin reported for the attributes it adds, such as "otel" or
+// "context".
+type Stage struct {
+	Origin     string
+	Middleware slogging.Middleware
+}
+
+// Chain composes the middlewares of stages as slogging.Chain does. When
+// debug is set, the composed handler also tracks the origin of every leaf
+// attribute of a record and adds them to the record in a top-level
+// "_provenance" group, mapping the dotted path of each attribute to its
+// origin:
+//
+//   - "record" for the attributes of the logging call;
+//   - "with_attrs" for the attributes of With calls;
+//   - the origin of the stage for the attributes added by a stage.
+//
+// When a stage changes the value of an attribute, its origin is appended
+// after a '>', as in "record>redact". Attributes removed by a stage are
+// not listed. Tracking materializes the attributes of every record and is
+// meant for debugging only.
+func Chain(debug bool, stages ...Stage) slogging.Middleware {
+	middlewares := make([]slogging.Middleware, 0, 2*len(stages)+2)
+	if debug {
+		middlewares = append(middlewares, func(h slog.Handler) slog.Handler {
+			return &start{handler: h}
+		})
+	}
+	for _, s := range stages {
+		middlewares = append(middlewares, s.Middleware)
+		if debug && s.Middleware != nil {
+			origin := s.Origin
+			middlewares = append(middlewares, func(h slog.Handler) slog.Handler {
+				return &mark{handler: h, origin: origin}
+			})
+		}
+	}
+	if debug {
+		middlewares = append(middlewares, func(h slog.Handler) slog.Handler {
+			return &annotate{handler: h, root: h}
+		})
+	}
+	return slogging.Chain(middlewares...)
+}
+
+type contextKey struct{}
+
+// origin is the origin and value of an attribute seen by a stage.
+type origin struct {
+	name  string
+	value string
+}
+
+// tracker holds the origins of the attributes of a record along the chain.
+type tracker struct {
+	origins map[string]origin
+}
+
+// observe records the origin of the leaves of attrs whose origin is
+// unknown or whose value changed.
+func (t *tracker) observe(prefix []string, attrs []slog.Attr, name string) {
+	leaves(prefix, attrs, func(path, value string) {
+		o, ok := t.origins[path]
+		switch {
+		case !ok:
+			t.origins[path] = origin{name: name, value: value}
+		case o.value != value:
+			t.origins[path] = origin{name: o.name + ">" + name, value: value}
+		}
+	})
+}
+
+// leaves calls fn with the dotted path and value of the leaves of attrs.
+func leaves(prefix []string, attrs []slog.Attr, fn func(path, value string)) {
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		if v.Kind() == slog.KindGroup {
+			p := prefix
+			if a.Key != "" {
+				p = append(prefix[:len(prefix):len(prefix)], a.Key)
+			}
+			leaves(p, v.Group(), fn)
+			continue
+		}
+		if a.Key == "" {
+			continue
+		}
+		fn(strings.Join(append(prefix[:len(prefix):len(prefix)], a.Key), "."), v.String())
+	}
+}
+
+func recordAttrs(r slog.Record) []slog.Attr {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+	return attrs
+}
+
+// start is the outermost handler of a chain: it records the origins of the
+// attributes of the record and of WithAttrs calls.
+type start struct {
+	handler slog.Handler
+	scope   scope.Scope
+}
+
+func (h *start) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *start) Handle(ctx context.Context, r slog.Record) error {
+	t := &tracker{origins: make(map[string]origin)}
+	t.observe(nil, h.scope.Nest(nil), OriginWithAttrs)
+	t.observe(h.scope.Groups(), recordAttrs(r), OriginRecord)
+	return h.handler.Handle(context.WithValue(ctx, contextKey{}, t), r)
+}
+
+func (h *start) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &start{handler: h.handler.WithAttrs(attrs), scope: h.scope.WithAttrs(attrs)}
+}
+
+func (h *start) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &start{handler: h.handler.WithGroup(name), scope: h.scope.WithGroup(name)}
+}
+
+// mark follows a stage: it attributes the attributes added or changed by
+// the stage to its origin, including those of the WithAttrs calls the stage
+// passed on. Its scope is the one the stage built on it, so that the paths
+// of the attributes of records rebuilt by the stage on the handler it
+// wraps are resolved correctly.
+type mark struct {
+	handler slog.Handler
+	scope   scope.Scope
+	origin  string
+}
+
+func (h *mark) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *mark) Handle(ctx context.Context, r slog.Record) error {
+	if t, ok := ctx.Value(contextKey{}).(*tracker); ok {
+		t.observe(nil, h.scope.Nest(nil), h.origin)
+		t.observe(h.scope.Groups(), recordAttrs(r), h.origin)
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+func (h *mark) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &mark{handler: h.handler.WithAttrs(attrs), scope: h.scope.WithAttrs(attrs), origin: h.origin}
+}
+
+func (h *mark) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &mark{handler: h.handler.WithGroup(name), scope: h.scope.WithGroup(name), origin: h.origin}
+}
+
+// annotate is the innermost handler of a chain: it adds the origins of the
+// attributes of the record, as materialized, at the top level.
+type annotate struct {
+	handler slog.Handler // Wrapped handler with derivations applied
+	root    slog.Handler // Wrapped handler without derivations
+	scope   scope.Scope
+}
+
+func (h *annotate) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *annotate) Handle(ctx context.Context, r slog.Record) error {
+	t, ok := ctx.Value(contextKey{}).(*tracker)
+	if !ok {
+		return h.handler.Handle(ctx, r)
+	}
+	attrs := h.scope.Attrs(r)
+	var annotations []slog.Attr
+	leaves(nil, attrs, func(path, _ string) {
+		name := t.origins[path].name
+		if name == "" {
+			name = "unknown"
+		}
+		annotations = append(annotations, slog.String(path, name))
+	})
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(attrs...)
+	if len(annotations) > 0 {
+		nr.AddAttrs(slog.Attr{Key: Key, Value: slog.GroupValue(annotations...)})
+	}
+	return h.root.Handle(ctx, nr)
+}
+
+func (h *annotate) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &annotate{handler: h.handler.WithAttrs(attrs), root: h.root, scope: h.scope.WithAttrs(attrs)}
+}
+
+func (h *annotate) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &annotate{handler: h.handler.WithGroup(name), root: h.root, scope: h.scope.WithGroup(name)}
+}
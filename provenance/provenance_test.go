@@ -0,0 +1,84 @@
+package provenance
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/ctxattr"
+	"github.com/mikluko/slogging/redact"
+)
+
+// hostname adds a top-level "host" attribute to every record.
+type hostname struct{ slog.Handler }
+
+func (h hostname) Handle(ctx context.Context, r slog.Record) error {
+	r = r.Clone()
+	r.AddAttrs(slog.String("host", "web-1"))
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h hostname) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return hostname{h.Handler.WithAttrs(attrs)}
+}
+
+func (h hostname) WithGroup(name string) slog.Handler {
+	return hostname{h.Handler.WithGroup(name)}
+}
+
+func Test_Chain(t *testing.T) {
+	stages := []Stage{
+		{Origin: "context", Middleware: func(h slog.Handler) slog.Handler { return ctxattr.Wrap(h) }},
+		{Origin: "redact", Middleware: slogging.Use(redact.Wrap, redact.WithKeys("password"))},
+		{Origin: "enrichment", Middleware: func(h slog.Handler) slog.Handler { return hostname{h} }},
+	}
+
+	t.Run("origins of attributes", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Chain(true, stages...)(slog.NewJSONHandler(buf, nil)))
+		ctx := ctxattr.With(context.Background(), slog.String("tenant", "acme"))
+		logger.With("svc", "api", "password", "hunter2").InfoContext(ctx, "login", slog.Group("user", "id", 7))
+
+		var m map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		want := map[string]any{
+			"svc":      "with_attrs",
+			"password": "with_attrs>redact",
+			"tenant":   "context",
+			"user.id":  "record",
+			"host":     "enrichment",
+		}
+		got := m[Key].(map[string]any)
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("origin of %s = %v, want %v (all: %v)", k, got[k], v, got)
+			}
+		}
+	})
+
+	t.Run("groups stay in place", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Chain(true, stages...)(slog.NewJSONHandler(buf, nil)))
+		logger.WithGroup("req").Info("m", "id", 1)
+		var m map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		if m["req"].(map[string]any)["id"] != 1.0 || m[Key].(map[string]any)["req.id"] != "record" {
+			t.Errorf("unexpected record: %v", m)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		slog.New(Chain(false, stages...)(slog.NewJSONHandler(buf, nil))).Info("m", "a", 1)
+		if bytes.Contains(buf.Bytes(), []byte(Key)) {
+			t.Errorf("unexpected annotations: %s", buf.String())
+		}
+	})
+}
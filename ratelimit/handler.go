@@ -0,0 +1,299 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mikluko/slogging/internal/fingerprint"
+)
+
+const (
+	defaultLimit  = 10
+	defaultWindow = time.Second
+)
+
+// Fingerprint computes the identity of a record for rate limiting purposes.
+// Records with equal fingerprints share a limit.
+type Fingerprint func(r slog.Record) uint64
+
+// window tracks a fingerprint during one rate limiting window.
+type window struct {
+	start      time.Time
+	count      int
+	suppressed int
+	timer      *time.Timer
+
+	// Summary delivery parameters, captured from the first suppressed record.
+	ctx     context.Context
+	handler slog.Handler
+	level   slog.Level
+	message string
+}
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	mutex     sync.Mutex
+	windows   map[uint64]*window
+	nextSweep time.Time
+	limit     int
+	period    time.Duration
+	onError   func(error)
+	now       func() time.Time
+}
+
+// Handler is a slog.Handler that lets through at most a given number of
+// records per fingerprint in every time window. When a window in which
+// records were suppressed closes, a summary record reporting the number of
+// suppressed records is delivered in their place.
+type Handler struct {
+	handler     slog.Handler
+	state       *state
+	fingerprint Fingerprint
+}
+
+// Wrap creates a rate limiting handler delivering to handler.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{
+		limit:  defaultLimit,
+		window: defaultWindow,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	fp := config.fingerprint
+	if fp == nil {
+		fp = Fields(config.similar, config.keys...)
+	}
+	return &Handler{
+		handler:     handler,
+		fingerprint: fp,
+		state: &state{
+			windows: make(map[uint64]*window),
+			limit:   config.limit,
+			period:  config.window,
+			onError: config.onError,
+			now:     time.Now,
+		},
+	}
+}
+
+// Fields returns a Fingerprint over the record level, message and the
+// values of the given top-level attribute keys. When similar is set,
+// digit sequences in the message are ignored, so "retry 1 of 5" and
+// "retry 2 of 5" share a fingerprint.
+func Fields(similar bool, keys ...string) Fingerprint {
+	return func(r slog.Record) uint64 {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(strconv.Itoa(int(r.Level))))
+		_, _ = h.Write([]byte{0})
+		_, _ = h.Write([]byte(fingerprint.Fields(r, similar, keys...)))
+		return h.Sum64()
+	}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle delivers the record unless its fingerprint exceeded the limit in
+// the current window.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	fp := h.fingerprint(r)
+	s := h.state
+
+	s.mutex.Lock()
+	now := s.now()
+	s.sweep(now)
+	w, ok := s.windows[fp]
+	var expired *window
+	var summary slog.Record
+	if !ok || now.Sub(w.start) >= s.period {
+		if ok && w.timer != nil && w.timer.Stop() {
+			// The summary has not fired yet; deliver it once the lock is released.
+			if sr, closed := s.close(fp, w); closed {
+				expired, summary = w, sr
+			}
+		}
+		w = &window{start: now}
+		s.windows[fp] = w
+	}
+	w.count++
+	if w.count <= s.limit {
+		s.mutex.Unlock()
+		if expired != nil {
+			s.deliver(expired, summary)
+		}
+		return h.handler.Handle(ctx, r)
+	}
+
+	w.suppressed++
+	if w.timer == nil {
+		w.ctx = context.WithoutCancel(ctx)
+		w.handler = h.handler
+		w.level = r.Level
+		w.message = r.Message
+		remaining := s.period - now.Sub(w.start)
+		w.timer = time.AfterFunc(remaining, func() { s.summarize(fp, w) })
+	}
+	s.mutex.Unlock()
+	if expired != nil {
+		s.deliver(expired, summary)
+	}
+	return nil
+}
+
+// sweep discards windows that ended without suppressing records. Must be
+// called with the mutex held.
+func (s *state) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	for fp, w := range s.windows {
+		if w.timer == nil && now.Sub(w.start) >= s.period {
+			delete(s.windows, fp)
+		}
+	}
+	s.nextSweep = now.Add(s.period)
+}
+
+// summarize delivers the summary record of a closed window.
+func (s *state) summarize(fp uint64, w *window) {
+	s.mutex.Lock()
+	r, ok := s.close(fp, w)
+	s.mutex.Unlock()
+	if ok {
+		s.deliver(w, r)
+	}
+}
+
+// close removes the window and builds its summary record, reporting false
+// when no records were suppressed. Must be called with the mutex held.
+func (s *state) close(fp uint64, w *window) (slog.Record, bool) {
+	n := w.suppressed
+	w.suppressed = 0
+	if s.windows[fp] == w {
+		delete(s.windows, fp)
+	}
+	if n == 0 {
+		return slog.Record{}, false
+	}
+	r := slog.NewRecord(s.now(), w.level, fmt.Sprintf("suppressed %d similar messages", n), 0)
+	r.AddAttrs(
+		slog.String("original_message", w.message),
+		slog.Int("suppressed", n),
+		slog.Duration("window", s.period),
+	)
+	return r, true
+}
+
+// deliver hands the summary record of w to the handler of its first
+// suppressed record. Must be called without the mutex held.
+func (s *state) deliver(w *window, r slog.Record) {
+	if !w.handler.Enabled(w.ctx, r.Level) {
+		return
+	}
+	if err := w.handler.Handle(w.ctx, r); err != nil && s.onError != nil {
+		s.onError(err)
+	}
+}
+
+// Flush delivers the summaries of all windows with suppressed records
+// without waiting for the windows to close.
+func (h *Handler) Flush() {
+	s := h.state
+	s.mutex.Lock()
+	pending := make(map[uint64]*window)
+	for fp, w := range s.windows {
+		if w.timer != nil && w.timer.Stop() {
+			pending[fp] = w
+		}
+	}
+	s.mutex.Unlock()
+	for fp, w := range pending {
+		s.summarize(fp, w)
+	}
+}
+
+// WithAttrs returns a new Handler sharing the limits whose wrapped handler
+// includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler sharing the limits whose wrapped handler
+// starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	limit       int
+	window      time.Duration
+	keys        []string
+	similar     bool
+	fingerprint Fingerprint
+	onError     func(error)
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLimit lets through at most n records per fingerprint in every window.
+func WithLimit(n int, window time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.limit = max(n, 0)
+		h.window = window
+	}
+}
+
+// WithKeys includes the values of the given top-level attributes in the
+// default fingerprint.
+func WithKeys(keys ...string) Option {
+	return func(h *handlerOptions) {
+		h.keys = append(h.keys, keys...)
+	}
+}
+
+// WithSimilar ignores digit sequences in messages when fingerprinting, so
+// messages differing only in numbers share a limit.
+func WithSimilar(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.similar = true
+		for i := range x {
+			h.similar = x[i]
+		}
+	}
+}
+
+// WithFingerprint replaces the default fingerprint function.
+func WithFingerprint(fn Fingerprint) Option {
+	return func(h *handlerOptions) {
+		h.fingerprint = fn
+	}
+}
+
+// WithErrorHandler sets a function called with errors returned by the
+// wrapped handler when delivering summary records.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func Test_RateLimit(t *testing.T) {
+	t.Run("records above the limit are suppressed and summarized", func(t *testing.T) {
+		buf := &syncBuffer{}
+		h := Wrap(slog.NewTextHandler(buf, nil), WithLimit(2, 50*time.Millisecond))
+		logger := slog.New(h).With("svc", "api")
+
+		for i := 0; i < 10; i++ {
+			logger.Error("connection refused")
+		}
+		logger.Info("other message")
+
+		out := buf.String()
+		if n := strings.Count(out, "connection refused"); n != 2 {
+			t.Errorf("expected 2 delivered records, got %d: %s", n, out)
+		}
+		if !strings.Contains(out, "other message") {
+			t.Errorf("unrelated records should not be limited: %s", out)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for !strings.Contains(buf.String(), "suppressed 8 similar messages") && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		out = buf.String()
+		if !strings.Contains(out, `msg="suppressed 8 similar messages" svc=api original_message="connection refused" suppressed=8`) {
+			t.Errorf("expected summary record, got: %s", out)
+		}
+	})
+
+	t.Run("new window resets the limit", func(t *testing.T) {
+		buf := &syncBuffer{}
+		h := Wrap(slog.NewTextHandler(buf, nil), WithLimit(1, time.Minute))
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		h.state.now = func() time.Time { return now }
+		logger := slog.New(h)
+
+		logger.Info("tick")
+		logger.Info("tick")
+		now = now.Add(time.Minute)
+		logger.Info("tick")
+
+		out := buf.String()
+		if n := strings.Count(out, "msg=tick"); n != 2 {
+			t.Errorf("expected 2 delivered records, got %d: %s", n, out)
+		}
+		if !strings.Contains(out, "suppressed 1 similar messages") {
+			t.Errorf("expected summary of previous window: %s", out)
+		}
+	})
+
+	t.Run("similar messages and keys", func(t *testing.T) {
+		buf := &syncBuffer{}
+		h := Wrap(slog.NewTextHandler(buf, nil), WithLimit(1, time.Minute), WithSimilar(), WithKeys("host"))
+		logger := slog.New(h)
+
+		logger.Warn("retry 1 of 5", "host", "a")
+		logger.Warn("retry 2 of 5", "host", "a")
+		logger.Warn("retry 3 of 5", "host", "b")
+		h.Flush()
+
+		out := buf.String()
+		if strings.Contains(out, `msg="retry 2`) || !strings.Contains(out, `msg="retry 3`) {
+			t.Errorf("unexpected output: %s", out)
+		}
+		if !strings.Contains(out, `original_message="retry 2 of 5" suppressed=1`) {
+			t.Errorf("expected flushed summary: %s", out)
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mikluko/slogging"
+)
+
+// quota tracks the records enabled for a key in the current window.
+type quota struct {
+	start time.Time
+	count int
+}
+
+// Quota returns a gate for slogging.Gated enabling at most limit records
+// below Error per window for each key returned by key from the logging
+// context, such as a tenant ID, so that one noisy tenant cannot exhaust
+// the logging budget of the others. Records at or above Error, and records
+// whose context has no key, are not limited. Records beyond the quota are
+// rejected in Enabled, before their arguments are evaluated.
+func Quota(key func(context.Context) string, limit int, window time.Duration) slogging.Gate {
+	var (
+		mutex     sync.Mutex
+		quotas    = make(map[string]*quota)
+		nextSweep time.Time
+	)
+	return func(ctx context.Context, level slog.Level) bool {
+		if level >= slog.LevelError {
+			return true
+		}
+		k := key(ctx)
+		if k == "" {
+			return true
+		}
+		now := time.Now()
+		mutex.Lock()
+		defer mutex.Unlock()
+		if !now.Before(nextSweep) {
+			for k, q := range quotas {
+				if now.Sub(q.start) >= window {
+					delete(quotas, k)
+				}
+			}
+			nextSweep = now.Add(window)
+		}
+		q, ok := quotas[k]
+		if !ok || now.Sub(q.start) >= window {
+			q = &quota{start: now}
+			quotas[k] = q
+		}
+		if q.count >= limit {
+			return false
+		}
+		q.count++
+		return true
+	}
+}
@@ -0,0 +1,41 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/slogging"
+)
+
+type tenantKey struct{}
+
+func Test_Quota(t *testing.T) {
+	buf := new(bytes.Buffer)
+	tenant := func(ctx context.Context) string {
+		s, _ := ctx.Value(tenantKey{}).(string)
+		return s
+	}
+	logger := slog.New(slogging.Gated(slog.NewTextHandler(buf, nil), Quota(tenant, 2, 50*time.Millisecond)))
+	noisy := context.WithValue(context.Background(), tenantKey{}, "noisy")
+	quiet := context.WithValue(context.Background(), tenantKey{}, "quiet")
+
+	for range 5 {
+		logger.InfoContext(noisy, "noisy")
+	}
+	logger.ErrorContext(noisy, "failure")
+	logger.InfoContext(quiet, "quiet")
+	logger.Info("untenanted")
+	time.Sleep(60 * time.Millisecond)
+	logger.InfoContext(noisy, "noisy")
+
+	out := buf.String()
+	for msg, want := range map[string]int{"msg=noisy": 3, "msg=failure": 1, "msg=quiet": 1, "msg=untenanted": 1} {
+		if got := strings.Count(out, msg); got != want {
+			t.Errorf("%d records with %s, want %d:\n%s", got, msg, want, out)
+		}
+	}
+}
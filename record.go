@@ -0,0 +1,44 @@
+package slogging
+
+import (
+	"log/slog"
+)
+
+// CloneRecord returns a deep copy of r that shares no mutable state with it.
+// Unlike slog.Record.Clone, attribute values are resolved, so LogValuers are
+// evaluated once, at the time of the call, and group attribute slices are
+// copied recursively. Byte slices are copied; other values held by
+// slog.KindAny attributes are copied by assignment.
+//
+// CloneRecord is meant for handlers that retain records beyond the Handle
+// call, such as queues and buffers.
+func CloneRecord(r slog.Record) slog.Record {
+	c := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, CloneAttr(a))
+		return true
+	})
+	c.AddAttrs(attrs...)
+	return c
+}
+
+// CloneAttr returns a deep copy of a with its value resolved, following
+// the same rules as CloneRecord.
+func CloneAttr(a slog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindGroup:
+		group := v.Group()
+		attrs := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			attrs[i] = CloneAttr(ga)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(attrs...)}
+	case slog.KindAny:
+		if b, ok := v.Any().([]byte); ok {
+			return slog.Attr{Key: a.Key, Value: slog.AnyValue(append([]byte(nil), b...))}
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
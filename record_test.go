@@ -0,0 +1,77 @@
+package slogging
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type counter struct {
+	n int
+}
+
+func (c *counter) LogValue() slog.Value {
+	c.n++
+	return slog.IntValue(c.n)
+}
+
+func Test_CloneRecord(t *testing.T) {
+	t.Run("log valuers are resolved once", func(t *testing.T) {
+		c := &counter{}
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0)
+		r.AddAttrs(slog.Any("count", c))
+
+		clone := CloneRecord(r)
+		c.n = 100
+
+		var got slog.Value
+		clone.Attrs(func(a slog.Attr) bool {
+			got = a.Value
+			return false
+		})
+		if got.Kind() != slog.KindInt64 || got.Int64() != 1 {
+			t.Errorf("expected resolved value 1, got %v", got)
+		}
+	})
+
+	t.Run("group slices are not shared", func(t *testing.T) {
+		group := []slog.Attr{slog.String("a", "1"), slog.Group("inner", slog.String("b", "2"))}
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0)
+		r.AddAttrs(slog.Attr{Key: "g", Value: slog.GroupValue(group...)})
+
+		clone := CloneRecord(r)
+		group[0] = slog.String("a", "changed")
+
+		clone.Attrs(func(a slog.Attr) bool {
+			if v := a.Value.Group()[0].Value.String(); v != "1" {
+				t.Errorf("clone shares group slice, got %q", v)
+			}
+			return true
+		})
+	})
+
+	t.Run("byte slices are copied", func(t *testing.T) {
+		b := []byte("abc")
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0)
+		r.AddAttrs(slog.Any("raw", b))
+
+		clone := CloneRecord(r)
+		b[0] = 'x'
+
+		clone.Attrs(func(a slog.Attr) bool {
+			if got := string(a.Value.Any().([]byte)); got != "abc" {
+				t.Errorf("clone shares byte slice, got %q", got)
+			}
+			return true
+		})
+	})
+
+	t.Run("record fields are preserved", func(t *testing.T) {
+		now := time.Now()
+		r := slog.NewRecord(now, slog.LevelWarn, "hello", 42)
+		clone := CloneRecord(r)
+		if !clone.Time.Equal(now) || clone.Level != slog.LevelWarn || clone.Message != "hello" || clone.PC != 42 {
+			t.Errorf("unexpected clone: %+v", clone)
+		}
+	})
+}
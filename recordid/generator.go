@@ -0,0 +1,86 @@
+package recordid
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// Generator returns a new unique ID for a record logged at t. Generators
+// must be safe for concurrent use.
+type Generator func(t time.Time) string
+
+// UUIDv7 returns a version 7 UUID as defined by RFC 9562: the Unix time of
+// t in milliseconds followed by random bits, in the canonical textual form.
+// IDs sort by time at millisecond precision.
+func UUIDv7(t time.Time) string {
+	var b [16]byte
+	_, _ = rand.Read(b[6:])
+	ms := uint64(t.UnixMilli())
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	b[6] = b[6]&0x0f | 0x70
+	b[8] = b[8]&0x3f | 0x80
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID returns a ULID: the Unix time of t in milliseconds followed by 80
+// random bits, as 26 characters of Crockford's base32. IDs sort by time at
+// millisecond precision.
+func ULID(t time.Time) string {
+	var b [16]byte
+	_, _ = rand.Read(b[6:])
+	ms := uint64(t.UnixMilli())
+	b[0], b[1], b[2], b[3], b[4], b[5] = byte(ms>>40), byte(ms>>32), byte(ms>>24), byte(ms>>16), byte(ms>>8), byte(ms)
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var s [26]byte
+	// 128 bits in 26 characters of 5 bits, the first holding 3 bits.
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:])
+}
+
+const (
+	base62      = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	ksuidEpoch  = 1400000000
+	ksuidLength = 27
+)
+
+// KSUID returns a KSUID: the time of t in seconds since the KSUID epoch
+// followed by 128 random bits, as 27 base62 characters. IDs sort by time
+// at second precision.
+func KSUID(t time.Time) string {
+	var b [20]byte
+	binary.BigEndian.PutUint32(b[:4], uint32(t.Unix()-ksuidEpoch))
+	_, _ = rand.Read(b[4:])
+	n := new(big.Int).SetBytes(b[:])
+	s := n.Text(62)
+	// big.Int uses 0-9a-zA-Z for base 62; KSUID uses 0-9A-Za-z.
+	s = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z':
+			return r - 'A' + 'a'
+		}
+		return r
+	}, s)
+	return strings.Repeat("0", ksuidLength-len(s)) + s
+}
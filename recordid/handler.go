@@ -0,0 +1,129 @@
+// Package recordid provides a slog.Handler wrapper stamping every record
+// with a unique ID, so that downstream consumers can process records
+// exactly once and correlate the copies of a record delivered to several
+// sinks.
+package recordid
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// Key is the attribute holding the ID of a record.
+const Key = "log.id"
+
+// Handler is a slog.Handler that adds a unique ID, generated by UUIDv7 by
+// default, as a top-level attribute to every record before delivering it
+// to the wrapped handler. Records already carrying the attribute at the
+// top level keep their ID, so that records replayed or forwarded through
+// several wrappers are not stamped twice. Placed in front of handlers
+// fanning records out, such as multi or route, it gives every copy of a
+// record the same ID.
+type Handler struct {
+	handler slog.Handler
+	scope   scope.Scope
+	config  handlerOptions
+}
+
+// Wrap creates a handler stamping records delivered to handler with IDs.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{
+		key:       Key,
+		generator: UUIDv7,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	if config.generator == nil {
+		config.generator = UUIDv7
+	}
+	return &Handler{handler: handler, config: config}
+}
+
+// ID returns the ID of a record stamped by a Handler using the default key.
+func ID(r slog.Record) (id string, ok bool) {
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == Key {
+			id, ok = a.Value.Resolve().String(), true
+			return false
+		}
+		return true
+	})
+	return id, ok
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// Handle stamps the record with an ID, unless it has one, and delivers it.
+// The attributes of WithAttrs and WithGroup calls are applied to the
+// record, so that the ID stays at the top level.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	grouped := len(h.scope.Groups()) > 0
+	stamped := false
+	r.Attrs(func(a slog.Attr) bool {
+		stamped = !grouped && a.Key == h.config.key
+		return !stamped
+	})
+	if stamped && h.scope.Empty() {
+		return h.handler.Handle(ctx, r)
+	}
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	if !stamped {
+		t := r.Time
+		if t.IsZero() {
+			t = time.Now()
+		}
+		nr.AddAttrs(slog.String(h.config.key, h.config.generator(t)))
+	}
+	nr.AddAttrs(h.scope.Attrs(r)...)
+	return h.handler.Handle(ctx, nr)
+}
+
+type handlerOptions struct {
+	key       string
+	generator Generator
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithGenerator sets the function generating IDs, e.g. ULID or KSUID.
+// Defaults to UUIDv7.
+func WithGenerator(g Generator) Option {
+	return func(h *handlerOptions) {
+		h.generator = g
+	}
+}
+
+// WithKey sets the attribute holding the ID. Defaults to Key.
+func WithKey(key string) Option {
+	return func(h *handlerOptions) {
+		h.key = key
+	}
+}
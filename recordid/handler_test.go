@@ -0,0 +1,93 @@
+package recordid
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"sort"
+	"testing"
+	"time"
+)
+
+func Test_Generators(t *testing.T) {
+	t0 := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		gen     Generator
+		pattern string
+		prefix  string
+	}{
+		"uuidv7": {UUIDv7, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, "018ccab4-0688-7"},
+		"ulid":   {ULID, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`, "01HK5B81M8"},
+		"ksuid":  {KSUID, `^[0-9A-Za-z]{27}$`, ""},
+	} {
+		t.Run(name, func(t *testing.T) {
+			seen := map[string]bool{}
+			var ids []string
+			for i := range 100 {
+				id := tc.gen(t0.Add(time.Duration(i) * time.Second))
+				if !regexp.MustCompile(tc.pattern).MatchString(id) {
+					t.Fatalf("malformed ID %q", id)
+				}
+				if seen[id] {
+					t.Fatalf("duplicate ID %q", id)
+				}
+				seen[id] = true
+				ids = append(ids, id)
+			}
+			if !sort.StringsAreSorted(ids) {
+				t.Errorf("IDs do not sort by time: %v", ids[:3])
+			}
+			if tc.prefix != "" && ids[0][:len(tc.prefix)] != tc.prefix {
+				t.Errorf("unexpected time component: %s", ids[0])
+			}
+		})
+	}
+
+	// Reference value: the KSUID of the maximum timestamp and payload.
+	if got := KSUID(time.Unix(ksuidEpoch+0xffffffff, 0)); got[:5] != "aWgEP" {
+		t.Errorf("unexpected KSUID encoding: %s", got)
+	}
+}
+
+func Test_Handler(t *testing.T) {
+	t.Run("records are stamped at the top level", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewJSONHandler(buf, nil), WithGenerator(func(time.Time) string { return "id-1" }))
+		slog.New(h).With("a", 1).WithGroup("g").Info("msg", "b", 2)
+		var m map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		if m[Key] != "id-1" || m["a"] != 1.0 || m["g"].(map[string]any)["b"] != 2.0 {
+			t.Errorf("unexpected record: %s", buf.String())
+		}
+	})
+
+	t.Run("stamped records keep their ID", func(t *testing.T) {
+		var ids []string
+		capture := handlerFunc(func(r slog.Record) {
+			id, _ := ID(r)
+			ids = append(ids, id)
+		})
+		h := Wrap(Wrap(capture))
+		_ = h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "msg", 0))
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "replayed", 0)
+		r.AddAttrs(slog.String(Key, "original"))
+		_ = h.Handle(context.Background(), r)
+		if len(ids) != 2 || len(ids[0]) != 36 || ids[1] != "original" {
+			t.Errorf("unexpected IDs: %v", ids)
+		}
+	})
+}
+
+type handlerFunc func(r slog.Record)
+
+func (f handlerFunc) Enabled(context.Context, slog.Level) bool { return true }
+func (f handlerFunc) WithAttrs([]slog.Attr) slog.Handler       { return f }
+func (f handlerFunc) WithGroup(string) slog.Handler            { return f }
+func (f handlerFunc) Handle(_ context.Context, r slog.Record) error {
+	f(r)
+	return nil
+}
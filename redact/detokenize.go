@@ -0,0 +1,170 @@
+package redact
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrUnauthorized is returned by detokenizers refusing to resolve tokens
+// for lack of credentials or permissions.
+var ErrUnauthorized = errors.New("slogging: not authorized to detokenize")
+
+// Detokenizer resolves tokens issued by a Tokenizer back to the original
+// values, typically a client of the tokenization service holding the
+// credentials of an authorized investigator. Field is the dotted path of
+// the attribute.
+type Detokenizer interface {
+	Detokenize(ctx context.Context, field, token string) (string, error)
+}
+
+// DetokenizerFunc adapts a function to the Detokenizer interface.
+type DetokenizerFunc func(ctx context.Context, field, token string) (string, error)
+
+// Detokenize calls f(ctx, field, token).
+func (f DetokenizerFunc) Detokenize(ctx context.Context, field, token string) (string, error) {
+	return f(ctx, field, token)
+}
+
+// Reveal resolves the tokens of a record archived as a JSON object, such
+// as a line written by slog.JSONHandler, at the fields matching any of the
+// patterns, which follow the rules of WithKeys. Keys keep their order;
+// the object is returned compacted. Values masked because tokenization
+// failed, DefaultReplacement unless set with WithRevealReplacement, are
+// left as they are. The first error of the detokenizer is returned,
+// leaving the record unchanged.
+func Reveal(ctx context.Context, d Detokenizer, record []byte, patterns []string, options ...RevealOption) ([]byte, error) {
+	return newRevealer(d, patterns, options).object(ctx, nil, record)
+}
+
+// RevealStream copies the records of r, one JSON object per line, to w
+// with their tokens resolved as by Reveal. Lines that are not JSON objects
+// are copied as they are. Tokens are resolved once per stream.
+func RevealStream(ctx context.Context, d Detokenizer, r io.Reader, w io.Writer, patterns []string, options ...RevealOption) error {
+	rv := newRevealer(d, patterns, options)
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			out := line
+			if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 && trimmed[0] == '{' && json.Valid(trimmed) {
+				revealed, rerr := rv.object(ctx, nil, trimmed)
+				if rerr != nil {
+					return rerr
+				}
+				out = append(revealed, '\n')
+			}
+			if _, werr := w.Write(out); werr != nil {
+				return fmt.Errorf("error when writing revealed records: %w", werr)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error when reading archived records: %w", err)
+		}
+	}
+}
+
+// RevealOption is a function that configures Reveal and RevealStream.
+type RevealOption func(rv *revealer)
+
+// WithRevealReplacement sets the string values were masked with when
+// tokenization failed, DefaultReplacement by default. It should match the
+// WithReplacement option of the handler that wrote the records.
+func WithRevealReplacement(s string) RevealOption {
+	return func(rv *revealer) {
+		rv.replacement = s
+	}
+}
+
+type revealer struct {
+	d           Detokenizer
+	patterns    []string
+	replacement string
+	cache       map[tokenKey]string
+}
+
+func newRevealer(d Detokenizer, patterns []string, options []RevealOption) *revealer {
+	rv := &revealer{
+		d:           d,
+		patterns:    lower(patterns),
+		replacement: DefaultReplacement,
+		cache:       make(map[tokenKey]string),
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(rv)
+		}
+	}
+	return rv
+}
+
+// object rewrites the JSON object data found at path.
+func (rv *revealer) object(ctx context.Context, path []string, data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return nil, fmt.Errorf("error when decoding archived record: %w", err)
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("error when decoding archived record: %w", err)
+		}
+		key, _ := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, fmt.Errorf("error when decoding archived record: %w", err)
+		}
+		inner := append(path[:len(path):len(path)], key)
+		if value, err = rv.value(ctx, inner, value); err != nil {
+			return nil, err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (rv *revealer) value(ctx context.Context, path []string, value json.RawMessage) (json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return rv.object(ctx, path, trimmed)
+	}
+	field := strings.Join(path, ".")
+	if len(trimmed) == 0 || trimmed[0] != '"' ||
+		!matchAny(rv.patterns, strings.ToLower(path[len(path)-1]), strings.ToLower(field)) {
+		return trimmed, nil
+	}
+	var token string
+	if err := json.Unmarshal(trimmed, &token); err != nil {
+		return nil, fmt.Errorf("error when decoding archived record: %w", err)
+	}
+	if token == rv.replacement {
+		return trimmed, nil // Masked when tokenization failed
+	}
+	key := tokenKey{field: field, value: token}
+	plain, ok := rv.cache[key]
+	if !ok {
+		var err error
+		if plain, err = rv.d.Detokenize(ctx, field, token); err != nil {
+			return nil, fmt.Errorf("error when detokenizing %s: %w", field, err)
+		}
+		rv.cache[key] = plain
+	}
+	return json.Marshal(plain)
+}
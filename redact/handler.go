@@ -0,0 +1,353 @@
+package redact
+
+import (
+	"context"
+	"encoding"
+	"fmt"
+	"log/slog"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// DefaultReplacement is the string substituted for redacted values.
+const DefaultReplacement = "[REDACTED]"
+
+var (
+	// CreditCard matches 13 to 19 digit card numbers, optionally separated
+	// by spaces or dashes.
+	CreditCard = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	// Email matches e-mail addresses.
+	Email = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+)
+
+// Redactor is a custom redaction function. It receives the group path of
+// the attribute and the attribute with its value resolved, and returns the
+// attribute to log in its place. Returning false removes the attribute.
+type Redactor func(groups []string, a slog.Attr) (slog.Attr, bool)
+
+// config is shared, read-only, across WithAttrs/WithGroup derivations.
+type config struct {
+	mask          []string
+	remove        []string
+	values        []*regexp.Regexp
+	redactors     []Redactor
+	replacement   string
+	redactMessage bool
+	tokenize      []string
+	tokens        *tokens
+}
+
+// Handler is a slog.Handler that masks or removes sensitive attributes
+// before delegating to the wrapped handler. Attributes are matched by key
+// pattern and their string values, or the text of errors, stringers and
+// text marshalers, by regular expression, recursing into groups and
+// resolved LogValuer results. Attributes added via WithAttrs
+// are redacted once, when they are added.
+//
+// Attributes matching the patterns of WithTokenizer have their values
+// replaced with tokens. Tokenization fails closed: values whose token
+// cannot be obtained within the timeout are masked.
+type Handler struct {
+	handler slog.Handler
+	config  *config
+	groups  []string
+}
+
+// Wrap creates a redacting handler delegating to handler.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	c := handlerOptions{
+		replacement:    DefaultReplacement,
+		tokenTimeout:   defaultTokenTimeout,
+		tokenCacheSize: defaultTokenCacheSize,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&c)
+		}
+	}
+	cfg := &config{
+		mask:          lower(c.mask),
+		remove:        lower(c.remove),
+		values:        c.values,
+		redactors:     c.redactors,
+		replacement:   c.replacement,
+		redactMessage: c.redactMessage,
+	}
+	if c.tokenizer != nil {
+		cfg.tokenize = lower(c.tokenize)
+		cfg.tokens = &tokens{
+			tokenizer: c.tokenizer,
+			timeout:   c.tokenTimeout,
+			size:      c.tokenCacheSize,
+			onError:   c.onError,
+			cache:     make(map[tokenKey]string),
+		}
+	}
+	return &Handler{handler: handler, config: cfg}
+}
+
+func lower(patterns []string) []string {
+	out := make([]string, len(patterns))
+	for i, p := range patterns {
+		out[i] = strings.ToLower(p)
+	}
+	return out
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle redacts the record attributes and delegates to the wrapped handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	msg := r.Message
+	if h.config.redactMessage {
+		msg = h.config.redactString(msg)
+	}
+	r2 := slog.NewRecord(r.Time, r.Level, msg, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		if a, ok := h.config.redact(ctx, h.groups, a); ok {
+			r2.AddAttrs(a)
+		}
+		return true
+	})
+	return h.handler.Handle(ctx, r2)
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given
+// attributes, redacted.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a, ok := h.config.redact(context.Background(), h.groups, a); ok {
+			redacted = append(redacted, a)
+		}
+	}
+	return &Handler{
+		handler: h.handler.WithAttrs(redacted),
+		config:  h.config,
+		groups:  h.groups,
+	}
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := make([]string, len(h.groups)+1)
+	copy(groups, h.groups)
+	groups[len(h.groups)] = name
+	return &Handler{
+		handler: h.handler.WithGroup(name),
+		config:  h.config,
+		groups:  groups,
+	}
+}
+
+func (c *config) redact(ctx context.Context, groups []string, a slog.Attr) (slog.Attr, bool) {
+	a.Value = a.Value.Resolve()
+	if a.Key != "" {
+		key := strings.ToLower(a.Key)
+		field := strings.Join(append(groups[:len(groups):len(groups)], a.Key), ".")
+		full := strings.ToLower(field)
+		if matchAny(c.remove, key, full) {
+			return slog.Attr{}, false
+		}
+		if matchAny(c.tokenize, key, full) {
+			return c.tokenized(ctx, field, a), true
+		}
+		if matchAny(c.mask, key, full) {
+			return slog.String(a.Key, c.replacement), true
+		}
+	}
+	for _, fn := range c.redactors {
+		var ok bool
+		if a, ok = fn(groups, a); !ok {
+			return slog.Attr{}, false
+		}
+		a.Value = a.Value.Resolve()
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindGroup:
+		inner := groups
+		if a.Key != "" {
+			inner = append(groups[:len(groups):len(groups)], a.Key)
+		}
+		attrs := a.Value.Group()
+		redacted := make([]slog.Attr, 0, len(attrs))
+		for _, ga := range attrs {
+			if ga, ok := c.redact(ctx, inner, ga); ok {
+				redacted = append(redacted, ga)
+			}
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}, true
+	case slog.KindString:
+		if len(c.values) > 0 {
+			return slog.String(a.Key, c.redactString(a.Value.String())), true
+		}
+	case slog.KindAny:
+		if len(c.values) > 0 {
+			if s, ok := text(a.Value.Any()); ok {
+				if r := c.redactString(s); r != s {
+					return slog.String(a.Key, r), true
+				}
+			}
+		}
+	}
+	return a, true
+}
+
+// text returns the text form of errors, encoding.TextMarshaler and
+// fmt.Stringer values, in this order of precedence.
+func text(v any) (string, bool) {
+	switch v := v.(type) {
+	case error:
+		return v.Error(), true
+	case encoding.TextMarshaler:
+		b, err := v.MarshalText()
+		return string(b), err == nil
+	case fmt.Stringer:
+		return v.String(), true
+	}
+	return "", false
+}
+
+func (c *config) redactString(s string) string {
+	for _, re := range c.values {
+		s = re.ReplaceAllLiteralString(s, c.replacement)
+	}
+	return s
+}
+
+// matchAny reports whether any pattern matches. Patterns containing a dot
+// are matched against the full dotted path of the attribute, other patterns
+// against its key at any depth.
+func matchAny(patterns []string, key, full string) bool {
+	for _, p := range patterns {
+		subject := key
+		if strings.Contains(p, ".") {
+			subject = full
+		}
+		if ok, _ := path.Match(p, subject); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type handlerOptions struct {
+	mask          []string
+	remove        []string
+	values        []*regexp.Regexp
+	redactors     []Redactor
+	replacement   string
+	redactMessage bool
+
+	tokenize       []string
+	tokenizer      Tokenizer
+	tokenTimeout   time.Duration
+	tokenCacheSize int
+	onError        func(error)
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithKeys masks the values of attributes matching any of the patterns.
+// Patterns are case-insensitive shell globs as understood by path.Match.
+// A pattern without a dot, e.g. "password", matches the attribute key at
+// any depth; a pattern with a dot, e.g. "*.token" or "http.headers.cookie",
+// matches the dotted group path of the attribute.
+func WithKeys(patterns ...string) Option {
+	return func(h *handlerOptions) {
+		h.mask = append(h.mask, patterns...)
+	}
+}
+
+// WithRemoveKeys removes attributes matching any of the patterns. Patterns
+// follow the rules of WithKeys.
+func WithRemoveKeys(patterns ...string) Option {
+	return func(h *handlerOptions) {
+		h.remove = append(h.remove, patterns...)
+	}
+}
+
+// WithValuePatterns replaces substrings of string values matching any of
+// the regular expressions, e.g. CreditCard or Email. Errors, text
+// marshalers and stringers are matched by their text form, and replaced
+// with the redacted string when a pattern matches.
+func WithValuePatterns(patterns ...*regexp.Regexp) Option {
+	return func(h *handlerOptions) {
+		h.values = append(h.values, patterns...)
+	}
+}
+
+// WithRedactor registers a custom redaction function. Redactors run after
+// key patterns, in the order they were registered, and before recursion
+// into groups and value patterns.
+func WithRedactor(fn Redactor) Option {
+	return func(h *handlerOptions) {
+		h.redactors = append(h.redactors, fn)
+	}
+}
+
+// WithReplacement sets the string substituted for redacted values.
+func WithReplacement(s string) Option {
+	return func(h *handlerOptions) {
+		h.replacement = s
+	}
+}
+
+// WithMessage applies value patterns to record messages as well.
+func WithMessage(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.redactMessage = true
+		for i := range x {
+			h.redactMessage = x[i]
+		}
+	}
+}
+
+// WithTokenizer replaces the values of attributes matching any of the
+// patterns with tokens obtained from t, such as a client of a
+// tokenization service. Patterns follow the rules of WithKeys; values of
+// other kinds than strings are tokenized in their text form and groups
+// are masked.
+func WithTokenizer(t Tokenizer, patterns ...string) Option {
+	return func(h *handlerOptions) {
+		h.tokenizer = t
+		h.tokenize = append(h.tokenize, patterns...)
+	}
+}
+
+// WithTokenTimeout sets how long the tokenizer is waited for before the
+// value is masked instead. Defaults to 100ms.
+func WithTokenTimeout(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.tokenTimeout = d
+	}
+}
+
+// WithTokenCache sets the number of tokens kept to spare calls to the
+// tokenizer for recurring values. Defaults to 10000.
+func WithTokenCache(size int) Option {
+	return func(h *handlerOptions) {
+		h.tokenCacheSize = max(size, 1)
+	}
+}
+
+// WithErrorHandler sets a function called with the errors of the
+// tokenizer. It is called from the logging goroutine.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
@@ -0,0 +1,246 @@
+package redact
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type credentials struct {
+	user     string
+	password string
+}
+
+func (c credentials) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("user", c.user), slog.String("password", c.password))
+}
+
+func Test_Redact(t *testing.T) {
+	setup := func(options ...Option) (*bytes.Buffer, *slog.Logger) {
+		buf := new(bytes.Buffer)
+		return buf, slog.New(Wrap(slog.NewTextHandler(buf, nil), options...))
+	}
+
+	t.Run("keys are masked at any depth", func(t *testing.T) {
+		buf, logger := setup(WithKeys("password", "Authorization"))
+		logger.Info("login",
+			"password", "hunter2",
+			slog.Group("headers", slog.String("authorization", "Bearer abc")),
+			"creds", credentials{"bob", "s3cret"},
+		)
+		out := buf.String()
+		for _, secret := range []string{"hunter2", "Bearer", "s3cret"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("secret %q leaked: %s", secret, out)
+			}
+		}
+		if !strings.Contains(out, "creds.user=bob") || !strings.Contains(out, "password=[REDACTED]") {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("dotted patterns match group paths", func(t *testing.T) {
+		buf, logger := setup(WithKeys("*.token"))
+		logger.WithGroup("oauth").Info("refresh", "token", "t1")
+		logger.Info("root", "token", "t2")
+		out := buf.String()
+		if strings.Contains(out, "t1") || !strings.Contains(out, "token=t2") {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("attributes can be removed", func(t *testing.T) {
+		buf, logger := setup(WithRemoveKeys("ssn"))
+		logger.With("ssn", "123-45-6789").Info("user", "name", "bob")
+		if strings.Contains(buf.String(), "ssn") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("values are matched by pattern", func(t *testing.T) {
+		buf, logger := setup(WithValuePatterns(CreditCard, Email), WithReplacement("***"), WithMessage())
+		logger.Info("charge for bob@example.com", "card", "4111 1111 1111 1111", "note", "contact alice@example.org")
+		out := buf.String()
+		if strings.Contains(out, "4111") || strings.Contains(out, "example") {
+			t.Errorf("unexpected output: %s", out)
+		}
+		if !strings.Contains(out, `note="contact ***"`) || !strings.Contains(out, `msg="charge for ***"`) {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("errors are matched by their message", func(t *testing.T) {
+		buf, logger := setup(WithValuePatterns(CreditCard, Email))
+		logger.Info("charge failed", "err", fmt.Errorf("card 4111 1111 1111 1111 declined"), "other", errors.New("timeout"))
+		out := buf.String()
+		if !strings.Contains(out, `err="card [REDACTED] declined"`) || !strings.Contains(out, "other=timeout") {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("stringers and text marshalers are matched by their text", func(t *testing.T) {
+		buf, logger := setup(WithValuePatterns(Email))
+		addr, _ := mail.ParseAddress("Bob <bob@example.com>")
+		logger.Info("sent", "to", addr, "ip", netip.MustParseAddr("10.0.0.1"), "from", textEmail("alice@example.org"))
+		out := buf.String()
+		if strings.Contains(out, "example") || !strings.Contains(out, "ip=10.0.0.1") {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("custom redactors", func(t *testing.T) {
+		buf, logger := setup(WithRedactor(func(groups []string, a slog.Attr) (slog.Attr, bool) {
+			if a.Key == "ip" {
+				parts := strings.Split(a.Value.String(), ".")
+				return slog.String(a.Key, parts[0]+".x.x.x"), true
+			}
+			if len(groups) > 0 && groups[0] == "internal" {
+				return slog.Attr{}, false
+			}
+			return a, true
+		}))
+		logger.Info("req", "ip", "10.1.2.3")
+		logger.WithGroup("internal").Info("debug", "state", "x")
+		out := buf.String()
+		if !strings.Contains(out, "ip=10.x.x.x") || strings.Contains(out, "state") {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("values are tokenized", func(t *testing.T) {
+		var calls atomic.Int32
+		vault := TokenizerFunc(func(_ context.Context, field, value string) (string, error) {
+			calls.Add(1)
+			return "tok(" + field + ":" + value + ")", nil
+		})
+		buf, logger := setup(WithTokenizer(vault, "email", "card.*"), WithKeys("password"))
+		logger.With("email", "a@example.com").Info("signup", "email", "a@example.com",
+			slog.Group("card", "number", 4111, "cvv", "123"), "password", "x")
+		out := buf.String()
+		for _, want := range []string{
+			`email=tok(email:a@example.com) email=tok(email:a@example.com)`,
+			`card.number=tok(card.number:4111) card.cvv=tok(card.cvv:123)`,
+			`password=[REDACTED]`,
+		} {
+			if !strings.Contains(out, want) {
+				t.Errorf("output %s lacks %s", out, want)
+			}
+		}
+		if n := calls.Load(); n != 3 {
+			t.Errorf("tokenizer called %d times, want 3 thanks to the cache", n)
+		}
+	})
+
+	t.Run("tokenization fails closed", func(t *testing.T) {
+		var errs []error
+		slow := TokenizerFunc(func(ctx context.Context, _, _ string) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		})
+		buf, logger := setup(WithTokenizer(slow, "ssn"), WithTokenTimeout(time.Millisecond),
+			WithErrorHandler(func(err error) { errs = append(errs, err) }))
+		logger.Info("m", "ssn", "078-05-1120")
+		if out := buf.String(); !strings.Contains(out, "ssn=[REDACTED]") {
+			t.Errorf("unexpected output: %s", out)
+		}
+		if len(errs) != 1 || !errors.Is(errs[0], context.DeadlineExceeded) {
+			t.Errorf("errors = %v", errs)
+		}
+	})
+
+	t.Run("tokenizers ignoring the context are not waited for", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		stuck := TokenizerFunc(func(context.Context, string, string) (string, error) {
+			<-release
+			return "tok", nil
+		})
+		buf, logger := setup(WithTokenizer(stuck, "ssn"), WithTokenTimeout(10*time.Millisecond))
+		start := time.Now()
+		logger.Info("m", "ssn", "078-05-1120")
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("tokenizer waited for %s", d)
+		}
+		if out := buf.String(); !strings.Contains(out, "ssn=[REDACTED]") {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("hash tokenizer", func(t *testing.T) {
+		buf, logger := setup(WithTokenizer(Hash([]byte("k")), "user"))
+		logger.Info("a", "user", "bob")
+		logger.Info("b", "user", "bob")
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 2 || !strings.Contains(lines[0], "user=tok_") || strings.Contains(lines[0], "bob") {
+			t.Fatalf("unexpected output: %s", buf.String())
+		}
+		if lines[0][strings.Index(lines[0], "user="):] != lines[1][strings.Index(lines[1], "user="):] {
+			t.Errorf("tokens differ: %s", buf.String())
+		}
+	})
+
+	t.Run("tokens are revealed on the read path", func(t *testing.T) {
+		vault := map[string]string{}
+		tokenizer := TokenizerFunc(func(_ context.Context, _, value string) (string, error) {
+			token := fmt.Sprintf("tok_%d", len(vault))
+			vault[token] = value
+			return token, nil
+		})
+		var authorized bool
+		detokenizer := DetokenizerFunc(func(_ context.Context, _, token string) (string, error) {
+			if !authorized {
+				return "", ErrUnauthorized
+			}
+			return vault[token], nil
+		})
+
+		archive := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewJSONHandler(archive, &slog.HandlerOptions{ReplaceAttr: dropTime}),
+			WithTokenizer(tokenizer, "email", "card.number")))
+		logger.Info("signup", "email", "a@example.com", slog.Group("card", "number", "4111", "brand", "visa"))
+		archive.WriteString("not json\n")
+
+		if _, err := Reveal(context.Background(), detokenizer, archive.Bytes()[:bytes.IndexByte(archive.Bytes(), '\n')], []string{"email"}); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("Reveal() error = %v, want ErrUnauthorized", err)
+		}
+		authorized = true
+		out := new(bytes.Buffer)
+		if err := RevealStream(context.Background(), detokenizer, archive, out, []string{"email", "card.number"}); err != nil {
+			t.Fatal(err)
+		}
+		want := `{"level":"INFO","msg":"signup","email":"a@example.com","card":{"number":"4111","brand":"visa"}}` + "\nnot json\n"
+		if out.String() != want {
+			t.Errorf("\nwant %s\ngot  %s", want, out.String())
+		}
+	})
+
+	t.Run("masked values are not revealed", func(t *testing.T) {
+		detokenizer := DetokenizerFunc(func(_ context.Context, _, token string) (string, error) {
+			return "", fmt.Errorf("unexpected token %q", token)
+		})
+		record := []byte(`{"msg":"signup","email":"***"}`)
+		got, err := Reveal(context.Background(), detokenizer, record, []string{"email"}, WithRevealReplacement("***"))
+		if err != nil || string(got) != string(record) {
+			t.Errorf("Reveal() = %s, %v", got, err)
+		}
+	})
+}
+
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
+
+type textEmail string
+
+func (e textEmail) MarshalText() ([]byte, error) { return []byte("<" + e + ">"), nil }
@@ -0,0 +1,124 @@
+package redact
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTokenTimeout   = 100 * time.Millisecond
+	defaultTokenCacheSize = 10000
+)
+
+// Tokenizer replaces sensitive values with tokens, typically reversible
+// ones issued by a tokenization service that authorized parties can
+// exchange for the original values. Field is the dotted path of the
+// attribute. Implementations must be safe for concurrent use.
+type Tokenizer interface {
+	Tokenize(ctx context.Context, field, value string) (string, error)
+}
+
+// TokenizerFunc adapts a function to the Tokenizer interface.
+type TokenizerFunc func(ctx context.Context, field, value string) (string, error)
+
+// Tokenize calls f(ctx, field, value).
+func (f TokenizerFunc) Tokenize(ctx context.Context, field, value string) (string, error) {
+	return f(ctx, field, value)
+}
+
+// Hash returns a Tokenizer replacing values with the hex-encoded first 16
+// bytes of their HMAC-SHA256 under key, prefixed with "tok_". The tokens
+// are not reversible but still let records about the same value be
+// correlated without revealing it.
+func Hash(key []byte) Tokenizer {
+	return TokenizerFunc(func(_ context.Context, _, value string) (string, error) {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(value))
+		return "tok_" + hex.EncodeToString(mac.Sum(nil)[:16]), nil
+	})
+}
+
+type tokenKey struct {
+	field string
+	value string
+}
+
+// tokens calls a Tokenizer with a timeout, caching the tokens it returns.
+// Values whose token is not returned within the timeout are masked, even
+// when the tokenizer ignores its context.
+type tokens struct {
+	tokenizer Tokenizer
+	timeout   time.Duration
+	size      int
+	onError   func(error)
+
+	mutex sync.Mutex
+	cache map[tokenKey]string
+}
+
+// token returns the token of value and whether it could be obtained.
+func (t *tokens) token(ctx context.Context, field, value string) (string, bool) {
+	key := tokenKey{field: field, value: value}
+	t.mutex.Lock()
+	token, ok := t.cache[key]
+	t.mutex.Unlock()
+	if ok {
+		return token, true
+	}
+
+	// The tokenizer may ignore ctx, so it is not waited for past the
+	// timeout. It then completes in the background and its token is lost.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), t.timeout)
+	defer cancel()
+	type result struct {
+		token string
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		token, err := t.tokenizer.Tokenize(ctx, field, value)
+		done <- result{token, err}
+	}()
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+	var res result
+	select {
+	case res = <-done:
+	case <-timer.C:
+		res.err = context.DeadlineExceeded
+	}
+	token, err := res.token, res.err
+	if err != nil {
+		if t.onError != nil {
+			t.onError(fmt.Errorf("error when tokenizing %s: %w", field, err))
+		}
+		return "", false
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.cache) >= t.size {
+		clear(t.cache)
+	}
+	t.cache[key] = token
+	return token, true
+}
+
+// tokenized replaces the value of a with its token, or with replacement if
+// the tokenizer fails, so that values are never logged in the clear.
+func (c *config) tokenized(ctx context.Context, field string, a slog.Attr) slog.Attr {
+	if a.Value.Kind() == slog.KindGroup {
+		return slog.String(a.Key, c.replacement)
+	}
+	token, ok := c.tokens.token(ctx, field, a.Value.String())
+	if !ok {
+		return slog.String(a.Key, c.replacement)
+	}
+	return slog.String(a.Key, token)
+}
@@ -0,0 +1,329 @@
+package slogging
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// LoggerConfig configures the loggers of a name and of the names below it
+// in a Registry.
+type LoggerConfig struct {
+	// Level is the minimum level of records, inherited from the nearest
+	// configured ancestor if nil, slog.LevelInfo at the root. A
+	// slog.LevelVar or levels.Component keeps it adjustable without
+	// reconfiguring.
+	Level slog.Leveler
+
+	// Handler receives the records, inherited from the nearest configured
+	// ancestor if nil.
+	Handler slog.Handler
+
+	// Middleware wraps the handler. The middlewares of every configured
+	// ancestor apply too, the root one being the outermost.
+	Middleware Middleware
+
+	// Attrs are added to every record, after those of the ancestors.
+	Attrs []slog.Attr
+}
+
+// Registry resolves named loggers from a tree of configurations, as the
+// logger hierarchies of log4j and logback do: the configuration of
+// "app.db" applies to the loggers "app.db" and "app.db.pool" unless
+// overridden below, and the root configuration, of the empty name,
+// applies to every logger. Loggers follow reconfigurations made after they
+// were returned. Registry is safe for concurrent use.
+type Registry struct {
+	mutex   sync.Mutex
+	configs map[string]LoggerConfig
+	nodes   map[string]*registryNode
+	gen     uint64
+	root    slog.Handler
+}
+
+// registryNode holds the resolved configuration of a name handed out by
+// Get.
+type registryNode struct {
+	name     string
+	logger   *slog.Logger
+	resolved atomic.Pointer[resolvedConfig]
+}
+
+type resolvedConfig struct {
+	gen     uint64
+	level   slog.Leveler
+	handler slog.Handler
+	// wrappers are the handlers created by the middlewares, outermost
+	// first, closed when the configuration is replaced.
+	wrappers []slog.Handler
+}
+
+// NewRegistry creates a registry whose loggers use handler until another
+// one is configured.
+func NewRegistry(handler slog.Handler) *Registry {
+	return &Registry{
+		configs: make(map[string]LoggerConfig),
+		nodes:   make(map[string]*registryNode),
+		root:    handler,
+	}
+}
+
+// Get returns the logger of the given dotted name, such as "app.db.pool",
+// whose records carry the name as the "logger.name" attribute, like those
+// of Named. Get returns the same logger for the same name.
+func (r *Registry) Get(name string) *slog.Logger {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if n, ok := r.nodes[name]; ok {
+		return n.logger
+	}
+	n := &registryNode{name: name}
+	n.resolved.Store(r.resolve(name))
+	n.logger = slog.New(&registryHandler{node: n})
+	r.nodes[name] = n
+	return n.logger
+}
+
+// Configure sets the configuration of name, the root one if name is
+// empty, and reconfigures the loggers below it.
+//
+// Reconfiguring instantiates the middlewares of the loggers again; the
+// handlers the previous instances returned are closed as by CloseAll,
+// outermost first, once the loggers use the new ones, so that an
+// async.Handler delivers its queue and stops its goroutine. Configure
+// returns after they are closed.
+func (r *Registry) Configure(name string, config LoggerConfig) {
+	r.mutex.Lock()
+	r.configs[name] = config
+	replaced := r.reconfigure()
+	r.mutex.Unlock()
+	_ = CloseAll(context.Background(), replaced...)
+}
+
+// Remove removes the configuration of name, whose loggers inherit that of
+// its ancestors again. The replaced middlewares are closed as by Configure.
+func (r *Registry) Remove(name string) {
+	r.mutex.Lock()
+	delete(r.configs, name)
+	replaced := r.reconfigure()
+	r.mutex.Unlock()
+	_ = CloseAll(context.Background(), replaced...)
+}
+
+// Replace replaces the whole configuration tree at once. The replaced
+// middlewares are closed as by Configure.
+func (r *Registry) Replace(configs map[string]LoggerConfig) {
+	r.mutex.Lock()
+	r.configs = make(map[string]LoggerConfig, len(configs))
+	for name, c := range configs {
+		r.configs[name] = c
+	}
+	replaced := r.reconfigure()
+	r.mutex.Unlock()
+	_ = CloseAll(context.Background(), replaced...)
+}
+
+// reconfigure resolves the configuration of every logger again and returns
+// the handlers created by the middlewares of the replaced configurations.
+// Must be called with the mutex held.
+func (r *Registry) reconfigure() []any {
+	r.gen++
+	var replaced []any
+	for name, n := range r.nodes {
+		old := n.resolved.Swap(r.resolve(name))
+		for _, w := range old.wrappers {
+			replaced = append(replaced, w)
+		}
+	}
+	return replaced
+}
+
+// resolve merges the configurations from the root down to name. Must be
+// called with the mutex held.
+func (r *Registry) resolve(name string) *resolvedConfig {
+	var (
+		level       slog.Leveler = slog.LevelInfo
+		handler     slog.Handler
+		middlewares []Middleware
+		attrs       []slog.Attr
+	)
+	if name != "" {
+		attrs = append(attrs, slog.String(NameKey, name))
+	}
+	for _, path := range ancestors(name) {
+		c, ok := r.configs[path]
+		if !ok {
+			continue
+		}
+		if c.Level != nil {
+			level = c.Level
+		}
+		if c.Handler != nil {
+			handler = c.Handler
+		}
+		if c.Middleware != nil {
+			middlewares = append(middlewares, c.Middleware)
+		}
+		attrs = append(attrs, c.Attrs...)
+	}
+	if handler == nil {
+		handler = r.root
+	}
+	var wrappers []slog.Handler
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		if wrapped := middlewares[i](handler); wrapped != handler {
+			wrappers = append(wrappers, wrapped)
+			handler = wrapped
+		}
+	}
+	slices.Reverse(wrappers)
+	return &resolvedConfig{gen: r.gen, level: level, handler: handler.WithAttrs(attrs), wrappers: wrappers}
+}
+
+// ancestors returns the root and the dotted prefixes of name, down to
+// name itself.
+func ancestors(name string) []string {
+	paths := []string{""}
+	for i := 0; i < len(name); i++ {
+		if name[i] == '.' {
+			paths = append(paths, name[:i])
+		}
+	}
+	if name != "" {
+		paths = append(paths, name)
+	}
+	return paths
+}
+
+// registryHandler delivers the records of a registry logger to the
+// resolved handler of its name, replaying the WithAttrs and WithGroup
+// calls of derived loggers whenever the registry is reconfigured.
+type registryHandler struct {
+	node        *registryNode
+	derivations []derivation
+	cache       atomic.Pointer[resolvedConfig]
+}
+
+func (h *registryHandler) current() *resolvedConfig {
+	res := h.node.resolved.Load()
+	if len(h.derivations) == 0 {
+		return res
+	}
+	if c := h.cache.Load(); c != nil && c.gen == res.gen {
+		return c
+	}
+	handler := applyDerivations(res.handler, h.derivations)
+	c := &resolvedConfig{gen: res.gen, level: res.level, handler: handler}
+	h.cache.Store(c)
+	return c
+}
+
+func (h *registryHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	c := h.current()
+	return level >= minLevel(ctx, c.level) && c.handler.Enabled(ctx, level)
+}
+
+func (h *registryHandler) Handle(ctx context.Context, r slog.Record) error {
+	c := h.current()
+	if r.Level < minLevel(ctx, c.level) {
+		return nil
+	}
+	return c.handler.Handle(ctx, r)
+}
+
+// minLevel returns the level of the context, if set, or the given one.
+func minLevel(ctx context.Context, level slog.Leveler) slog.Level {
+	if l, ok := ContextLevel(ctx); ok {
+		return l
+	}
+	return level.Level()
+}
+
+func (h *registryHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.derive(derivation{attrs: attrs})
+}
+
+func (h *registryHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.derive(derivation{group: name})
+}
+
+func (h *registryHandler) derive(d derivation) *registryHandler {
+	derivations := make([]derivation, len(h.derivations)+1)
+	copy(derivations, h.derivations)
+	derivations[len(h.derivations)] = d
+	return &registryHandler{node: h.node, derivations: derivations}
+}
+
+// defaultHandler delivers records to the handler of slog.Default() at the
+// time they are logged, replaying the WithAttrs and WithGroup calls made
+// on it.
+type defaultHandler struct {
+	derivations []derivation
+}
+
+func (h *defaultHandler) handler() slog.Handler {
+	return applyDerivations(slog.Default().Handler(), h.derivations)
+}
+
+func (h *defaultHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler().Enabled(ctx, level)
+}
+
+func (h *defaultHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+func (h *defaultHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return h.derive(derivation{attrs: attrs})
+}
+
+func (h *defaultHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return h.derive(derivation{group: name})
+}
+
+func (h *defaultHandler) derive(d derivation) *defaultHandler {
+	derivations := make([]derivation, len(h.derivations)+1)
+	copy(derivations, h.derivations)
+	derivations[len(h.derivations)] = d
+	return &defaultHandler{derivations: derivations}
+}
+
+// defaultRegistry uses the handler of the default logger until a root
+// handler is configured.
+var defaultRegistry = NewRegistry(&defaultHandler{})
+
+// DefaultRegistry returns the process-wide registry used by Get and
+// Configure. Until a root handler is configured, its loggers use the
+// handler of slog.Default() at the time records are logged, so loggers
+// obtained during package initialization follow a later slog.SetDefault.
+// A logger of the registry must then not be made the default one with
+// slog.SetDefault, whose records would come back to it.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Get returns the named logger of the default registry:
+//
+//	var log = slogging.Get("app.db.pool")
+func Get(name string) *slog.Logger {
+	return defaultRegistry.Get(name)
+}
+
+// Configure sets the configuration of name in the default registry.
+func Configure(name string, config LoggerConfig) {
+	defaultRegistry.Configure(name, config)
+}
@@ -0,0 +1,121 @@
+package slogging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_Registry(t *testing.T) {
+	t.Run("configuration is inherited down the tree", func(t *testing.T) {
+		root, db := new(bytes.Buffer), new(bytes.Buffer)
+		r := NewRegistry(slog.NewTextHandler(root, nil))
+		r.Configure("", LoggerConfig{Attrs: []slog.Attr{slog.String("svc", "api")}})
+		r.Configure("app.db", LoggerConfig{
+			Level:   slog.LevelDebug,
+			Handler: slog.NewTextHandler(db, &slog.HandlerOptions{Level: slog.LevelDebug}),
+			Attrs:   []slog.Attr{slog.String("component", "db")},
+		})
+
+		r.Get("app.http").Debug("hidden")
+		r.Get("app.http").Info("request")
+		r.Get("app.db.pool").Debug("acquired")
+
+		if got := root.String(); strings.Contains(got, "hidden") || !strings.Contains(got, `msg=request logger.name=app.http svc=api`) {
+			t.Errorf("root output: %s", got)
+		}
+		if got := db.String(); !strings.Contains(got, `msg=acquired logger.name=app.db.pool svc=api component=db`) {
+			t.Errorf("db output: %s", got)
+		}
+		if r.Get("app.db.pool") != r.Get("app.db.pool") || Name(r.Get("app.db.pool")) != "app.db.pool" {
+			t.Error("Get did not return the same named logger")
+		}
+	})
+
+	t.Run("middlewares wrap from the root down", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		r := NewRegistry(slog.NewTextHandler(buf, nil))
+		tag := func(v string) Middleware {
+			return func(h slog.Handler) slog.Handler { return h.WithAttrs([]slog.Attr{slog.String("mw", v)}) }
+		}
+		r.Configure("", LoggerConfig{Middleware: tag("root")})
+		r.Configure("a", LoggerConfig{Middleware: tag("a")})
+		r.Get("a.b").Info("m")
+		if got := buf.String(); !strings.Contains(got, `mw=a mw=root logger.name=a.b`) {
+			t.Errorf("output: %s", got)
+		}
+	})
+
+	t.Run("derived loggers follow reconfigurations", func(t *testing.T) {
+		before, after := new(bytes.Buffer), new(bytes.Buffer)
+		r := NewRegistry(slog.NewTextHandler(before, nil))
+		logger := r.Get("app").With("k", "v").WithGroup("req")
+		logger.Info("one", "id", 1)
+		r.Configure("app", LoggerConfig{Handler: slog.NewTextHandler(after, nil), Level: slog.LevelWarn})
+		logger.Info("two", "id", 2)
+		logger.Warn("three", "id", 3)
+		r.Remove("app")
+		logger.Info("four", "id", 4)
+
+		if got := before.String(); !strings.Contains(got, `msg=one logger.name=app k=v req.id=1`) || !strings.Contains(got, "msg=four") {
+			t.Errorf("before output: %s", got)
+		}
+		if got := after.String(); strings.Contains(got, "msg=two") || !strings.Contains(got, `msg=three logger.name=app k=v req.id=3`) {
+			t.Errorf("after output: %s", got)
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		r := NewRegistry(slog.NewTextHandler(buf, nil))
+		logger := r.Get("x")
+		r.Replace(map[string]LoggerConfig{"": {Level: slog.LevelError}})
+		logger.Warn("hidden")
+		if buf.Len() != 0 {
+			t.Errorf("output: %s", buf.String())
+		}
+	})
+
+	t.Run("replaced middlewares are closed", func(t *testing.T) {
+		var closed []string
+		closing := func(v string) Middleware {
+			return func(h slog.Handler) slog.Handler { return &closingHandler{Handler: h, name: v, closed: &closed} }
+		}
+		r := NewRegistry(slog.NewTextHandler(new(bytes.Buffer), nil))
+		r.Configure("", LoggerConfig{Middleware: closing("root")})
+		r.Configure("a", LoggerConfig{Middleware: closing("a")})
+		r.Get("a")
+		if len(closed) != 0 {
+			t.Fatalf("expected no closed middleware, got %v", closed)
+		}
+		r.Configure("a", LoggerConfig{Level: slog.LevelWarn})
+		if strings.Join(closed, ",") != "root,a" {
+			t.Errorf("expected root,a closed outermost first, got %v", closed)
+		}
+	})
+
+	t.Run("default handler is resolved when logging", func(t *testing.T) {
+		defer slog.SetDefault(slog.Default())
+		r := NewRegistry(&defaultHandler{})
+		logger := r.Get("app").WithGroup("req")
+
+		buf := new(bytes.Buffer)
+		slog.SetDefault(slog.New(slog.NewTextHandler(buf, nil)))
+		logger.Info("m", "id", 1)
+		if got := buf.String(); !strings.Contains(got, `msg=m logger.name=app req.id=1`) {
+			t.Errorf("output: %s", got)
+		}
+	})
+}
+
+type closingHandler struct {
+	slog.Handler
+	name   string
+	closed *[]string
+}
+
+func (h *closingHandler) Close() error {
+	*h.closed = append(*h.closed, h.name)
+	return nil
+}
@@ -0,0 +1,165 @@
+// Package remap provides a slog.Handler wrapper changing the level of
+// records by logger name or group, such as demoting the Info records of
+// a noisy access logger to Debug, with rules that can be changed at
+// runtime.
+package remap
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mikluko/slogging"
+)
+
+// Rule changes the level of the records of a logger or group from one
+// level to another.
+type Rule struct {
+	// Name is the name of a logger, as set by slogging.Named, or the path
+	// of groups opened via WithGroup, joined with dots. It also matches
+	// the names and paths it is a dotted prefix of: "http" matches
+	// "http.access". An empty name matches every record.
+	Name string
+	From slog.Level
+	To   slog.Level
+}
+
+// String returns the rule in the format read by ParseRules, such as
+// "http.access:INFO=DEBUG".
+func (rl Rule) String() string {
+	return rl.Name + ":" + rl.From.String() + "=" + rl.To.String()
+}
+
+func (rl Rule) matches(name string) bool {
+	return rl.Name == "" || name == rl.Name || strings.HasPrefix(name, rl.Name+".")
+}
+
+// ParseRules parses a comma-separated list of rules such as
+// "http.access:INFO=DEBUG,payments:WARN=ERROR", as read from an
+// environment variable or a flag. Levels use the slog.Level text format.
+func ParseRules(s string) ([]Rule, error) {
+	var rules []Rule
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, levels, ok := strings.Cut(item, ":")
+		from, to, ok2 := strings.Cut(levels, "=")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid remap rule %q", item)
+		}
+		rl := Rule{Name: name}
+		if err := rl.From.UnmarshalText([]byte(from)); err != nil {
+			return nil, fmt.Errorf("invalid remap rule %q: %w", item, err)
+		}
+		if err := rl.To.UnmarshalText([]byte(to)); err != nil {
+			return nil, fmt.Errorf("invalid remap rule %q: %w", item, err)
+		}
+		rules = append(rules, rl)
+	}
+	return rules, nil
+}
+
+// Table holds the rules of handlers, which follow the changes made with
+// Set. Table is safe for concurrent use.
+type Table struct {
+	rules atomic.Pointer[[]Rule]
+}
+
+// NewTable creates a table of the given rules.
+func NewTable(rules ...Rule) *Table {
+	t := new(Table)
+	t.Set(rules...)
+	return t
+}
+
+// Set replaces the rules of the table.
+func (t *Table) Set(rules ...Rule) {
+	rules = slices.Clone(rules)
+	t.rules.Store(&rules)
+}
+
+// Rules returns the rules of the table.
+func (t *Table) Rules() []Rule {
+	return slices.Clone(*t.rules.Load())
+}
+
+// Remap returns the level of the records at level of the logger or group
+// path: the To level of the first rule matching either of them and level,
+// or level itself.
+func (t *Table) Remap(name, path string, level slog.Level) slog.Level {
+	for _, rl := range *t.rules.Load() {
+		if rl.From == level && (rl.matches(name) || path != "" && rl.matches(path)) {
+			return rl.To
+		}
+	}
+	return level
+}
+
+// Handler is a slog.Handler changing the level of records according to the
+// rules of a Table before delivering them to the wrapped handler. Enabled
+// checks the remapped level, so that demoted records are not even built
+// when the wrapped handler discards them. The logger name is taken from
+// the "logger.name" attribute added before any group is opened, as
+// slogging.Named does.
+type Handler struct {
+	handler slog.Handler
+	table   *Table
+	name    string
+	path    string
+	grouped bool
+}
+
+// Wrap creates a handler remapping levels according to table.
+func Wrap(handler slog.Handler, table *Table) *Handler {
+	return &Handler{handler: handler, table: table}
+}
+
+// Enabled reports whether the wrapped handler handles records at the
+// remapped level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, h.table.Remap(h.name, h.path, level))
+}
+
+// Handle delivers the record at its remapped level.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	r.Level = h.table.Remap(h.name, h.path, r.Level)
+	return h.handler.Handle(ctx, r)
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	if !h.grouped {
+		for _, a := range attrs {
+			if a.Key == slogging.NameKey {
+				h2.name = a.Value.String()
+			}
+		}
+	}
+	return &h2
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	h2.grouped = true
+	if h.path == "" {
+		h2.path = name
+	} else {
+		h2.path = h.path + "." + name
+	}
+	return &h2
+}
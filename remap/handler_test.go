@@ -0,0 +1,76 @@
+package remap
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/slogging"
+)
+
+func Test_Handler(t *testing.T) {
+	rules, err := ParseRules("http.access:INFO=DEBUG, payments:WARN=ERROR,db:INFO=WARN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	table := NewTable(rules...)
+	buf := new(bytes.Buffer)
+	logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), table))
+
+	t.Run("demote by logger name", func(t *testing.T) {
+		buf.Reset()
+		l := slogging.Named(logger, "http")
+		slogging.Named(l, "access").Info("GET /")
+		l.Info("listening")
+		if got := buf.String(); strings.Contains(got, "GET /") || !strings.Contains(got, "level=INFO msg=listening") {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("promote by logger name", func(t *testing.T) {
+		buf.Reset()
+		slogging.Named(logger, "payments").Warn("declined")
+		if got := buf.String(); !strings.Contains(got, "level=ERROR msg=declined") {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("by group", func(t *testing.T) {
+		buf.Reset()
+		logger.WithGroup("db").Info("query")
+		if got := buf.String(); !strings.Contains(got, "level=WARN msg=query") {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("enabled checks the remapped level", func(t *testing.T) {
+		h := slogging.Named(logger, "http.access").Handler()
+		if h.Enabled(context.Background(), slog.LevelInfo) {
+			t.Error("demoted level enabled")
+		}
+	})
+
+	t.Run("runtime change", func(t *testing.T) {
+		buf.Reset()
+		l := slogging.Named(logger, "http.access")
+		table.Set(Rule{Name: "http", From: slog.LevelInfo, To: slog.LevelWarn})
+		defer table.Set(rules...)
+		l.Info("GET /")
+		if got := buf.String(); !strings.Contains(got, "level=WARN msg=\"GET /\"") {
+			t.Errorf("got %q", got)
+		}
+		if got := table.Rules()[0].String(); got != "http:INFO=WARN" {
+			t.Errorf("Rule.String() = %q", got)
+		}
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		for _, s := range []string{"http", "http:INFO", "http:LOUD=DEBUG", "http:INFO=QUIET"} {
+			if _, err := ParseRules(s); err == nil {
+				t.Errorf("ParseRules(%q) succeeded", s)
+			}
+		}
+	})
+}
@@ -0,0 +1,419 @@
+// Package remoteconfig updates levels, sampling and routing rules of a
+// fleet of processes from a central config service, polled periodically
+// or pushing configs to a webhook, with signature verification and staged
+// rollouts.
+//
+// A config is a JSON document:
+//
+//	{
+//	  "version": 42,
+//	  "rollout": 25,
+//	  "levels": {"root": "INFO", "db": "DEBUG"},
+//	  "sampling": {"DEBUG": 0.01, "INFO": 0.5},
+//	  "routes": [{"destination": "audit", "when": "log.event == \"login\""}]
+//	}
+//
+// Levels are set on a levels.Registry. Sampling rates apply to records at
+// or above the listed levels through the sample.Sampler returned by
+// Sampler, and routes select destinations of a route.Handler through the
+// matchers returned by Matcher, their conditions being filter expressions.
+package remoteconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mikluko/slogging/filter"
+	"github.com/mikluko/slogging/levels"
+	"github.com/mikluko/slogging/route"
+	"github.com/mikluko/slogging/sample"
+)
+
+const (
+	defaultInterval = 30 * time.Second
+	maxConfigSize   = 1 << 20
+)
+
+// Config is the document served by the config service.
+type Config struct {
+	// Version orders configs. A config is only applied if its version is
+	// greater than the one of the config in effect, so that replayed
+	// configs are ignored.
+	Version int64 `json:"version"`
+
+	// Rollout is the percentage of instances applying the config, all of
+	// them if nil. Instances are assigned stable buckets from their
+	// identity, so raising the percentage of a version only adds
+	// instances.
+	Rollout *int `json:"rollout,omitempty"`
+
+	// Levels maps the names of levels.Registry components to levels in
+	// the slog.Level text format. Components dropped from a later config
+	// get back the level they had before the first config changed them.
+	Levels map[string]string `json:"levels,omitempty"`
+
+	// Sampling maps levels to the probability of keeping the records at or
+	// above them, up to the next listed level. Records below every listed
+	// level are kept.
+	Sampling map[string]float64 `json:"sampling,omitempty"`
+
+	// Routes are evaluated in order; the first one whose condition matches
+	// a record selects its destination.
+	Routes []Route `json:"routes,omitempty"`
+}
+
+// Route selects the destination of the records matching a filter
+// expression.
+type Route struct {
+	Destination string `json:"destination"`
+	When        string `json:"when"`
+}
+
+type rate struct {
+	level slog.Level
+	p     float64
+}
+
+type rule struct {
+	destination string
+	rule        filter.Rule
+}
+
+// compiled is a validated config ready to be evaluated.
+type compiled struct {
+	config   Config
+	levels   map[string]slog.Level
+	sampling []rate // Highest level first
+	routes   []rule
+}
+
+func compile(c Config) (*compiled, error) {
+	if c.Rollout != nil && (*c.Rollout < 0 || *c.Rollout > 100) {
+		return nil, fmt.Errorf("invalid rollout percentage %d", *c.Rollout)
+	}
+	out := &compiled{config: c, levels: make(map[string]slog.Level, len(c.Levels))}
+	for name, text := range c.Levels {
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(text)); err != nil {
+			return nil, fmt.Errorf("invalid level for %q: %w", name, err)
+		}
+		out.levels[name] = lvl
+	}
+	for text, p := range c.Sampling {
+		var lvl slog.Level
+		if err := lvl.UnmarshalText([]byte(text)); err != nil {
+			return nil, fmt.Errorf("invalid sampling level: %w", err)
+		}
+		out.sampling = append(out.sampling, rate{level: lvl, p: p})
+	}
+	slices.SortFunc(out.sampling, func(a, b rate) int { return int(b.level - a.level) })
+	for i, rt := range c.Routes {
+		b, err := filter.Parse(rt.When)
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition of route %d: %w", i, err)
+		}
+		out.routes = append(out.routes, rule{destination: rt.Destination, rule: b.Pass()})
+	}
+	return out, nil
+}
+
+// Remote holds the config in effect and applies new ones, received by
+// Poll, ServeHTTP or Apply. It is safe for concurrent use.
+type Remote struct {
+	config  handlerOptions
+	current atomic.Pointer[compiled]
+
+	mutex sync.Mutex            // Serializes Apply
+	base  map[string]slog.Level // Levels of the components before being set
+	etag  string
+}
+
+// New creates a Remote with no config in effect: the sampler keeps every
+// record and the matchers match none.
+func New(options ...Option) *Remote {
+	config := handlerOptions{
+		registry: levels.Default(),
+		client:   http.DefaultClient,
+		interval: defaultInterval,
+	}
+	config.instance, _ = os.Hostname()
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Remote{config: config, base: make(map[string]slog.Level)}
+}
+
+// Apply verifies, decodes and applies a config document, signature being
+// the value of the signature header. It reports whether the config was
+// applied: configs whose version is not greater than the one in effect,
+// and configs not rolled out to this instance yet, are ignored. Without a
+// verifier set with WithVerifier, configs are accepted unsigned; ServeHTTP
+// refuses them.
+func (r *Remote) Apply(body []byte, signature string) (bool, error) {
+	if r.config.verifier != nil {
+		if err := r.config.verifier.Verify(body, signature); err != nil {
+			return false, err
+		}
+	}
+	var c Config
+	if err := json.Unmarshal(body, &c); err != nil {
+		return false, fmt.Errorf("error when decoding config: %w", err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if cur := r.current.Load(); cur != nil && c.Version <= cur.config.Version {
+		return false, nil
+	}
+	if c.Rollout != nil && !Selected(r.config.instance, *c.Rollout) {
+		return false, nil
+	}
+	next, err := compile(c)
+	if err != nil {
+		return false, err
+	}
+	for name, lvl := range next.levels {
+		if _, ok := r.base[name]; !ok {
+			r.base[name] = r.config.registry.Get(name).Level()
+		}
+		r.config.registry.Set(name, lvl)
+	}
+	for name, lvl := range r.base {
+		if _, ok := next.levels[name]; !ok {
+			r.config.registry.Set(name, lvl)
+			delete(r.base, name)
+		}
+	}
+	r.current.Store(next)
+	return true, nil
+}
+
+// Selected reports whether the instance of the given identity belongs to
+// a rollout of the given percentage.
+func Selected(instance string, percent int) bool {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(instance))
+	return int(h.Sum32()%100) < percent
+}
+
+// Config returns the config in effect, if any.
+func (r *Remote) Config() (Config, bool) {
+	cur := r.current.Load()
+	if cur == nil {
+		return Config{}, false
+	}
+	return cur.config, true
+}
+
+// Sampler returns a sampler applying the sampling rates of the config in
+// effect.
+func (r *Remote) Sampler() sample.Sampler {
+	return sample.SamplerFunc(func(_ context.Context, rec slog.Record) bool {
+		cur := r.current.Load()
+		if cur == nil {
+			return true
+		}
+		for _, rt := range cur.sampling {
+			if rec.Level >= rt.level {
+				return rt.p >= 1 || rand.Float64() < rt.p
+			}
+		}
+		return true
+	})
+}
+
+// Matcher returns a matcher for the rule of destination in a route.Handler,
+// matching the records whose first matching route of the config in effect
+// selects destination:
+//
+//	route.New(def,
+//		route.WithDestination("audit", audit),
+//		route.WithRule("audit", remote.Matcher("audit")))
+//
+// Conditions see the attributes of the record, not those added via
+// WithAttrs.
+func (r *Remote) Matcher(destination string) route.Matcher {
+	return func(_ context.Context, rec slog.Record) bool {
+		cur := r.current.Load()
+		if cur == nil {
+			return false
+		}
+		fr := filter.NewRecord(rec)
+		for _, rt := range cur.routes {
+			if rt.rule.Match(fr) {
+				return rt.destination == destination
+			}
+		}
+		return false
+	}
+}
+
+// Poll fetches the config at url immediately and then at the interval set
+// with WithInterval, until ctx is done. Errors are reported to the
+// function set with WithErrorHandler. It returns the error of ctx.
+func (r *Remote) Poll(ctx context.Context, url string) error {
+	ticker := time.NewTicker(r.config.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := r.Fetch(ctx, url); err != nil && ctx.Err() == nil && r.config.onError != nil {
+			r.config.onError(err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Fetch fetches the config at url once and applies it, reporting whether
+// it was applied. The entity tag of the last response is sent along, so
+// that the service can answer 304 Not Modified.
+func (r *Remote) Fetch(ctx context.Context, url string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false, fmt.Errorf("error when creating config request: %w", err)
+	}
+	r.mutex.Lock()
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+	r.mutex.Unlock()
+	resp, err := r.config.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("error when fetching config: %w", err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		return false, nil
+	case resp.StatusCode/100 != 2:
+		return false, fmt.Errorf("error when fetching config: %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxConfigSize))
+	if err != nil {
+		return false, fmt.Errorf("error when reading config: %w", err)
+	}
+	ok, err := r.Apply(body, resp.Header.Get(SignatureHeader))
+	if err != nil {
+		return false, err
+	}
+	r.mutex.Lock()
+	r.etag = resp.Header.Get("ETag")
+	r.mutex.Unlock()
+	return ok, nil
+}
+
+// ServeHTTP receives configs pushed by the config service. POST and PUT
+// apply the config in the request body, responding 204 No Content, 403
+// Forbidden if the signature does not verify and 400 Bad Request if the
+// config is invalid. Pushes are refused with 403 Forbidden unless a
+// verifier is set with WithVerifier, as anyone reaching the endpoint could
+// change the levels otherwise. GET responds with the config in effect, or
+// 404 Not Found if there is none.
+func (r *Remote) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if (req.Method == http.MethodPost || req.Method == http.MethodPut) && r.config.verifier == nil {
+		http.Error(w, "slogging: config pushes require a verifier", http.StatusForbidden)
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+		c, ok := r.Config()
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(c)
+	case http.MethodPost, http.MethodPut:
+		body, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxConfigSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if _, err := r.Apply(body, req.Header.Get(SignatureHeader)); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrSignature) {
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+type handlerOptions struct {
+	registry *levels.Registry
+	verifier Verifier
+	instance string
+	client   *http.Client
+	interval time.Duration
+	onError  func(error)
+}
+
+// Option is a function that configures a Remote.
+type Option func(h *handlerOptions)
+
+// WithRegistry sets the registry whose levels are set by configs.
+// Defaults to levels.Default().
+func WithRegistry(r *levels.Registry) Option {
+	return func(h *handlerOptions) {
+		h.registry = r
+	}
+}
+
+// WithVerifier sets the verifier of config signatures. Configs failing
+// verification are rejected with ErrSignature.
+func WithVerifier(v Verifier) Option {
+	return func(h *handlerOptions) {
+		h.verifier = v
+	}
+}
+
+// WithInstance sets the identity of the instance selecting it in staged
+// rollouts. Defaults to the hostname.
+func WithInstance(id string) Option {
+	return func(h *handlerOptions) {
+		h.instance = id
+	}
+}
+
+// WithHTTPClient sets the client fetching configs, http.DefaultClient by
+// default.
+func WithHTTPClient(c *http.Client) Option {
+	return func(h *handlerOptions) {
+		h.client = c
+	}
+}
+
+// WithInterval sets the interval between polls. Defaults to 30 seconds.
+func WithInterval(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.interval = d
+	}
+}
+
+// WithErrorHandler sets a function called with the errors of polls.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
@@ -0,0 +1,248 @@
+package remoteconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/slogging/levels"
+	"github.com/mikluko/slogging/route"
+)
+
+func sign(key []byte, body string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func Test_Remote(t *testing.T) {
+	t.Run("levels are set and restored", func(t *testing.T) {
+		reg := levels.NewRegistry(slog.LevelInfo)
+		r := New(WithRegistry(reg))
+		if ok, err := r.Apply([]byte(`{"version":1,"levels":{"db":"DEBUG","http":"WARN"}}`), ""); !ok || err != nil {
+			t.Fatalf("Apply() = %v, %v", ok, err)
+		}
+		if got := reg.Get("db").Level(); got != slog.LevelDebug {
+			t.Errorf("db level = %v, want DEBUG", got)
+		}
+		if ok, err := r.Apply([]byte(`{"version":2,"levels":{"db":"ERROR"}}`), ""); !ok || err != nil {
+			t.Fatalf("Apply() = %v, %v", ok, err)
+		}
+		if got := reg.Get("db").Level(); got != slog.LevelError {
+			t.Errorf("db level = %v, want ERROR", got)
+		}
+		if got := reg.Get("http").Level(); got != slog.LevelInfo {
+			t.Errorf("http level = %v, want INFO restored", got)
+		}
+	})
+
+	t.Run("older versions are ignored", func(t *testing.T) {
+		reg := levels.NewRegistry(slog.LevelInfo)
+		r := New(WithRegistry(reg))
+		_, _ = r.Apply([]byte(`{"version":5,"levels":{"db":"DEBUG"}}`), "")
+		if ok, err := r.Apply([]byte(`{"version":5,"levels":{"db":"ERROR"}}`), ""); ok || err != nil {
+			t.Errorf("Apply() = %v, %v, want ignored", ok, err)
+		}
+		if c, _ := r.Config(); c.Version != 5 || reg.Get("db").Level() != slog.LevelDebug {
+			t.Errorf("config version %d, db level %v", c.Version, reg.Get("db").Level())
+		}
+	})
+
+	t.Run("invalid configs are rejected", func(t *testing.T) {
+		r := New(WithRegistry(levels.NewRegistry(slog.LevelInfo)))
+		for _, body := range []string{
+			`{"version":1,"levels":{"db":"LOUD"}}`,
+			`{"version":1,"sampling":{"LOUD":0.1}}`,
+			`{"version":1,"routes":[{"destination":"a","when":"x =="}]}`,
+			`{"version":1,"rollout":101}`,
+			`not json`,
+		} {
+			if _, err := r.Apply([]byte(body), ""); err == nil {
+				t.Errorf("Apply(%s) succeeded", body)
+			}
+		}
+		if _, ok := r.Config(); ok {
+			t.Error("a config is in effect")
+		}
+	})
+
+	t.Run("signatures", func(t *testing.T) {
+		key := []byte("secret")
+		body := `{"version":1}`
+		r := New(WithRegistry(levels.NewRegistry(slog.LevelInfo)), WithVerifier(HMAC(key)))
+		if _, err := r.Apply([]byte(body), sign([]byte("other"), body)); !errors.Is(err, ErrSignature) {
+			t.Errorf("Apply() error = %v, want ErrSignature", err)
+		}
+		if _, err := r.Apply([]byte(body), ""); !errors.Is(err, ErrSignature) {
+			t.Errorf("Apply() error = %v, want ErrSignature", err)
+		}
+		if ok, err := r.Apply([]byte(body), sign(key, body)); !ok || err != nil {
+			t.Errorf("Apply() = %v, %v", ok, err)
+		}
+
+		pub, priv, _ := ed25519.GenerateKey(nil)
+		v := Ed25519(pub)
+		sig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(body)))
+		if err := v.Verify([]byte(body), sig); err != nil {
+			t.Errorf("Verify() = %v", err)
+		}
+		if err := v.Verify([]byte(`{"version":2}`), sig); !errors.Is(err, ErrSignature) {
+			t.Errorf("Verify() = %v, want ErrSignature", err)
+		}
+	})
+
+	t.Run("staged rollout", func(t *testing.T) {
+		selected := 0
+		for i := range 1000 {
+			if Selected(fmt.Sprintf("host-%d", i), 25) {
+				selected++
+			}
+		}
+		if selected < 150 || selected > 350 {
+			t.Errorf("%d of 1000 instances selected at 25%%", selected)
+		}
+
+		var instance string
+		for i := 0; ; i++ {
+			if instance = fmt.Sprintf("host-%d", i); !Selected(instance, 10) && Selected(instance, 90) {
+				break
+			}
+		}
+		r := New(WithRegistry(levels.NewRegistry(slog.LevelInfo)), WithInstance(instance))
+		if ok, _ := r.Apply([]byte(`{"version":1,"rollout":10}`), ""); ok {
+			t.Error("config applied outside of the rollout")
+		}
+		if ok, _ := r.Apply([]byte(`{"version":1,"rollout":90}`), ""); !ok {
+			t.Error("config not applied once rolled out")
+		}
+	})
+
+	t.Run("sampling and routing", func(t *testing.T) {
+		r := New(WithRegistry(levels.NewRegistry(slog.LevelInfo)))
+		sampler := r.Sampler()
+		debug := slog.NewRecord(time.Now(), slog.LevelDebug, "m", 0)
+		if !sampler.Sample(context.Background(), debug) {
+			t.Error("record dropped without config")
+		}
+		_, err := r.Apply([]byte(`{"version":1,
+			"sampling":{"DEBUG":0,"WARN":1},
+			"routes":[{"destination":"audit","when":"kind == \"audit\""},{"destination":"other","when":"kind"}]}`), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sampler.Sample(context.Background(), debug) {
+			t.Error("debug record kept at rate 0")
+		}
+		if !sampler.Sample(context.Background(), slog.NewRecord(time.Now(), slog.LevelError, "m", 0)) {
+			t.Error("error record dropped at rate 1")
+		}
+
+		var def, audit bytes.Buffer
+		h := route.New(slog.NewTextHandler(&def, nil),
+			route.WithDestination("audit", slog.NewTextHandler(&audit, nil)),
+			route.WithRule("audit", r.Matcher("audit")))
+		logger := slog.New(h)
+		logger.Info("login", "kind", "audit")
+		logger.Info("other", "kind", "misc")
+		if !strings.Contains(audit.String(), "msg=login") || strings.Contains(audit.String(), "msg=other") {
+			t.Errorf("audit destination got %q", audit.String())
+		}
+		if !strings.Contains(def.String(), "msg=other") {
+			t.Errorf("default destination got %q", def.String())
+		}
+	})
+
+	t.Run("poll", func(t *testing.T) {
+		key := []byte("secret")
+		body := `{"version":3,"levels":{"db":"WARN"}}`
+		requests := make(chan string, 10)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			requests <- req.Header.Get("If-None-Match")
+			if req.Header.Get("If-None-Match") == `"v3"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v3"`)
+			w.Header().Set(SignatureHeader, sign(key, body))
+			_, _ = w.Write([]byte(body))
+		}))
+		defer srv.Close()
+
+		reg := levels.NewRegistry(slog.LevelInfo)
+		r := New(WithRegistry(reg), WithVerifier(HMAC(key)), WithInterval(10*time.Millisecond),
+			WithErrorHandler(func(err error) { t.Error(err) }))
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- r.Poll(ctx, srv.URL) }()
+		if etag := <-requests; etag != "" {
+			t.Errorf("first poll sent If-None-Match %q", etag)
+		}
+		if etag := <-requests; etag != `"v3"` {
+			t.Errorf("second poll sent If-None-Match %q", etag)
+		}
+		cancel()
+		if err := <-done; !errors.Is(err, context.Canceled) {
+			t.Errorf("Poll() = %v", err)
+		}
+		if got := reg.Get("db").Level(); got != slog.LevelWarn {
+			t.Errorf("db level = %v, want WARN", got)
+		}
+	})
+
+	t.Run("webhook", func(t *testing.T) {
+		key := []byte("secret")
+		r := New(WithRegistry(levels.NewRegistry(slog.LevelInfo)), WithVerifier(HMAC(key)))
+		push := func(body, sig string) int {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			req.Header.Set(SignatureHeader, sig)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			return w.Code
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("GET status = %d, want 404", w.Code)
+		}
+		if code := push(`{"version":1}`, "sha256=00"); code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", code)
+		}
+		if code := push(`{"version":`, sign(key, `{"version":`)); code != http.StatusBadRequest {
+			t.Errorf("status = %d, want 400", code)
+		}
+		if code := push(`{"version":1}`, sign(key, `{"version":1}`)); code != http.StatusNoContent {
+			t.Errorf("status = %d, want 204", code)
+		}
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		if !strings.Contains(w.Body.String(), `"version":1`) {
+			t.Errorf("GET body = %s", w.Body.String())
+		}
+	})
+	t.Run("webhook without a verifier", func(t *testing.T) {
+		r := New(WithRegistry(levels.NewRegistry(slog.LevelInfo)))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPut, "/", strings.NewReader(`{"version":1}`)))
+		if w.Code != http.StatusForbidden {
+			t.Errorf("status = %d, want 403", w.Code)
+		}
+		if _, ok := r.Config(); ok {
+			t.Error("expected the pushed config to be refused")
+		}
+		if ok, err := r.Apply([]byte(`{"version":1}`), ""); !ok || err != nil {
+			t.Errorf("Apply() = %v, %v", ok, err)
+		}
+	})
+}
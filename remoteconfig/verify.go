@@ -0,0 +1,65 @@
+package remoteconfig
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ErrSignature is returned when the signature of a config is missing or
+// does not match its content.
+var ErrSignature = errors.New("slogging: invalid config signature")
+
+// SignatureHeader is the HTTP header carrying the signature of a config,
+// in responses to polls and in pushed requests.
+const SignatureHeader = "X-Slogging-Signature"
+
+// Verifier checks the signature of a config document.
+type Verifier interface {
+	// Verify returns ErrSignature unless signature, the value of the
+	// signature header, signs body.
+	Verify(body []byte, signature string) error
+}
+
+// VerifierFunc adapts a function to the Verifier interface.
+type VerifierFunc func(body []byte, signature string) error
+
+// Verify calls f(body, signature).
+func (f VerifierFunc) Verify(body []byte, signature string) error {
+	return f(body, signature)
+}
+
+// HMAC returns a Verifier of hex-encoded HMAC-SHA256 signatures made with
+// a shared key, optionally prefixed with "sha256=" as sent by most webhook
+// providers.
+func HMAC(key []byte) Verifier {
+	return VerifierFunc(func(body []byte, signature string) error {
+		want, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+		if err != nil || len(want) != sha256.Size {
+			return ErrSignature
+		}
+		mac := hmac.New(sha256.New, key)
+		mac.Write(body)
+		if !hmac.Equal(mac.Sum(nil), want) {
+			return ErrSignature
+		}
+		return nil
+	})
+}
+
+// Ed25519 returns a Verifier of base64-encoded Ed25519 signatures, for
+// config services holding the private key while instances only know the
+// public one.
+func Ed25519(key ed25519.PublicKey) Verifier {
+	return VerifierFunc(func(body []byte, signature string) error {
+		sig, err := base64.StdEncoding.DecodeString(signature)
+		if err != nil || !ed25519.Verify(key, body, sig) {
+			return ErrSignature
+		}
+		return nil
+	})
+}
@@ -0,0 +1,204 @@
+// Package rename provides a slog.Handler wrapper renaming and moving
+// attributes by key path, to migrate field names gradually without
+// touching every call site.
+package rename
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// rule renames the attribute at path old to path new, both split on dots.
+type rule struct {
+	old []string
+	new []string
+}
+
+// Handler is a slog.Handler that renames attributes before delivering
+// records to the wrapped handler. Rules map the path of an attribute,
+// group names and key joined with dots such as "http.status", to its new
+// path, such as "http.status_code" or "status". An attribute renamed within
+// its group keeps its position; an attribute moved to another group is
+// appended to that group, which is created if needed, and groups left
+// empty are removed. Every attribute matching a path is renamed. Values
+// are resolved along the way, so attributes inside LogValuers are renamed
+// too. Keys containing dots cannot be matched by their path.
+//
+// Groups and attributes added via WithAttrs and WithGroup are materialized
+// into every record, so that they are renamed too.
+type Handler struct {
+	handler slog.Handler
+	scope   scope.Scope
+	rules   []rule
+	keepOld bool
+}
+
+// Wrap creates a handler renaming attributes according to renames, a map
+// of old paths to new paths, before delivering records to handler. Rules
+// are applied in the order of their old paths.
+func Wrap(handler slog.Handler, renames map[string]string, options ...Option) *Handler {
+	config := handlerOptions{}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	h := &Handler{handler: handler, keepOld: config.keepOld}
+	for _, old := range slices.Sorted(maps.Keys(renames)) {
+		if old == "" || renames[old] == "" || old == renames[old] {
+			continue
+		}
+		h.rules = append(h.rules, rule{old: strings.Split(old, "."), new: strings.Split(renames[old], ".")})
+	}
+	return h
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle renames the attributes of the record and delivers it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if len(h.rules) == 0 && h.scope.Empty() {
+		return h.handler.Handle(ctx, r)
+	}
+	attrs := h.scope.Attrs(r)
+	for _, rl := range h.rules {
+		attrs = h.apply(attrs, rl)
+	}
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(attrs...)
+	return h.handler.Handle(ctx, nr)
+}
+
+func (h *Handler) apply(attrs []slog.Attr, rl rule) []slog.Attr {
+	if slices.Equal(rl.old[:len(rl.old)-1], rl.new[:len(rl.new)-1]) {
+		return renameIn(attrs, rl.old, rl.new[len(rl.new)-1], h.keepOld)
+	}
+	attrs, moved := extract(attrs, rl.old, h.keepOld)
+	for _, a := range moved {
+		attrs = insert(attrs, rl.new, a.Value)
+	}
+	return attrs
+}
+
+// renameIn renames the attributes at path to key, in place.
+func renameIn(attrs []slog.Attr, path []string, key string, keepOld bool) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a.Key != path[0] {
+			out = append(out, a)
+			continue
+		}
+		if len(path) == 1 {
+			if keepOld {
+				out = append(out, a)
+			}
+			out = append(out, slog.Attr{Key: key, Value: a.Value})
+			continue
+		}
+		v := a.Value.Resolve()
+		if v.Kind() != slog.KindGroup {
+			out = append(out, a)
+			continue
+		}
+		out = append(out, slog.Attr{Key: a.Key, Value: slog.GroupValue(renameIn(v.Group(), path[1:], key, keepOld)...)})
+	}
+	return out
+}
+
+// extract removes the attributes at path and returns them, removing the
+// groups left empty. With keepOld, the attributes are returned but kept.
+func extract(attrs []slog.Attr, path []string, keepOld bool) (rest, found []slog.Attr) {
+	rest = make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a.Key != path[0] {
+			rest = append(rest, a)
+			continue
+		}
+		if len(path) == 1 {
+			found = append(found, a)
+			if keepOld {
+				rest = append(rest, a)
+			}
+			continue
+		}
+		v := a.Value.Resolve()
+		if v.Kind() != slog.KindGroup {
+			rest = append(rest, a)
+			continue
+		}
+		inner, f := extract(v.Group(), path[1:], keepOld)
+		found = append(found, f...)
+		if len(inner) > 0 {
+			rest = append(rest, slog.Attr{Key: a.Key, Value: slog.GroupValue(inner...)})
+		}
+	}
+	return rest, found
+}
+
+// insert adds v at path, appending it to the last group of the path
+// present in attrs and creating the missing ones.
+func insert(attrs []slog.Attr, path []string, v slog.Value) []slog.Attr {
+	if len(path) == 1 {
+		return append(attrs, slog.Attr{Key: path[0], Value: v})
+	}
+	for i := len(attrs) - 1; i >= 0; i-- {
+		if attrs[i].Key != path[0] {
+			continue
+		}
+		gv := attrs[i].Value.Resolve()
+		if gv.Kind() != slog.KindGroup {
+			break
+		}
+		out := slices.Clone(attrs)
+		out[i] = slog.Attr{Key: path[0], Value: slog.GroupValue(insert(gv.Group(), path[1:], v)...)}
+		return out
+	}
+	return append(attrs, slog.Attr{Key: path[0], Value: slog.GroupValue(insert(nil, path[1:], v)...)})
+}
+
+// WithAttrs returns a new Handler with the attributes added to its scope.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler with the group opened in its scope.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	keepOld bool
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithKeepOld keeps the attributes under their old paths next to the
+// renamed ones, so that consumers can move to the new names before the
+// old ones disappear.
+func WithKeepOld(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.keepOld = true
+		for i := range x {
+			h.keepOld = x[i]
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package rename
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+type user struct{ id int }
+
+func (u user) LogValue() slog.Value {
+	return slog.GroupValue(slog.Int("uid", u.id))
+}
+
+func Test_Handler(t *testing.T) {
+	for name, tc := range map[string]struct {
+		renames map[string]string
+		options []Option
+		want    string
+	}{
+		"top-level key keeps its position": {
+			renames: map[string]string{"uid": "user_id"},
+			want:    `msg=m a=1 user_id=7 http.status=200 http.method=GET user.uid=9 svc.k=v`,
+		},
+		"key within a group": {
+			renames: map[string]string{"http.status": "http.status_code"},
+			want:    `msg=m a=1 uid=7 http.status_code=200 http.method=GET user.uid=9 svc.k=v`,
+		},
+		"move out of a group": {
+			renames: map[string]string{"http.status": "status", "http.method": "method"},
+			want:    `msg=m a=1 uid=7 user.uid=9 svc.k=v method=GET status=200`,
+		},
+		"move into a group": {
+			renames: map[string]string{"a": "http.a", "uid": "user.id"},
+			want:    `msg=m http.status=200 http.method=GET http.a=1 user.uid=9 user.id=7 svc.k=v`,
+		},
+		"inside LogValuers and nested groups": {
+			renames: map[string]string{"user.uid": "user.id", "svc.k": "svc.key"},
+			want:    `msg=m a=1 uid=7 http.status=200 http.method=GET user.id=9 svc.key=v`,
+		},
+		"keep old": {
+			renames: map[string]string{"uid": "user_id", "http.method": "method"},
+			options: []Option{WithKeepOld()},
+			want:    `msg=m a=1 uid=7 user_id=7 http.status=200 http.method=GET user.uid=9 svc.k=v method=GET`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			h := Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}), tc.renames, tc.options...)
+			slog.New(h).With("a", 1, "uid", 7).Info("m", slog.Group("http", "status", 200, "method", "GET"), "user", user{9}, slog.Group("svc", "k", "v"))
+			if got := strings.TrimSpace(buf.String()); got != "level=INFO "+tc.want {
+				t.Errorf("\nwant level=INFO %s\ngot  %s", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("records of derived handlers", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}), map[string]string{"req.id": "request_id"})
+		slog.New(h).WithGroup("req").Info("m", "id", 1, "path", "/")
+		if got := strings.TrimSpace(buf.String()); got != "level=INFO msg=m req.path=/ request_id=1" {
+			t.Errorf("got %s", got)
+		}
+	})
+}
+
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
@@ -0,0 +1,185 @@
+package reserved
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// DefaultNamespaces are the key namespaces protected by default: "otel" is
+// populated by the otel handler, "log" holds control attributes such as
+// "log.route" and "log.event", and "error" holds structured error details.
+var DefaultNamespaces = []string{"otel", "log", "error"}
+
+// DefaultAllow are the control keys of this module in the "log" namespace,
+// let through by default: route.Key, events.Key, sequence.Key, recordid.Key,
+// schema.Key, slogging.OccurrencesKey and slogging.DeprecatedKey.
+var DefaultAllow = []string{
+	"log.route",
+	"log.event",
+	"log.seq_in_request",
+	"log.id",
+	"log.schema_violations",
+	"log.occurrences",
+	"log.deprecated",
+}
+
+// DefaultRenamePrefix is prepended to the keys of conflicting attributes
+// by the Rename action.
+const DefaultRenamePrefix = "user_"
+
+// Action defines what happens to an application attribute whose key falls
+// into a reserved namespace.
+type Action int
+
+const (
+	// Rename moves the attribute out of the namespace by prefixing its key.
+	Rename Action = iota
+	// Drop discards the attribute.
+	Drop
+)
+
+// Handler is a slog.Handler that protects reserved key namespaces from
+// being overwritten by application attributes. A top-level key is in the
+// namespace ns when it equals ns or starts with ns followed by a dot, which
+// covers both an "otel" group and a literal "otel.trace_id" key. Groups
+// opened with WithGroup count as top-level keys too. Conflicting attributes
+// are renamed or dropped and counted.
+//
+// The handler must be placed between the application and the handlers
+// populating the namespaces, e.g. otel.Wrap(handler) should wrap the
+// handler rather than the other way round.
+type Handler struct {
+	handler slog.Handler
+	scope   scope.Scope
+	config  *handlerOptions
+	count   *atomic.Uint64
+}
+
+// Wrap creates a handler protecting the configured namespaces,
+// DefaultNamespaces unless WithNamespaces is used, before delivering to
+// handler.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := &handlerOptions{
+		namespaces: DefaultNamespaces,
+		allow:      slices.Clone(DefaultAllow),
+		prefix:     DefaultRenamePrefix,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(config)
+		}
+	}
+	return &Handler{handler: handler, config: config, count: new(atomic.Uint64)}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle renames or drops the conflicting attributes of the record and
+// delivers it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := h.scope.Attrs(r)
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if !h.conflicts(a.Key) {
+			out = append(out, a)
+			continue
+		}
+		h.count.Add(1)
+		if h.config.action == Rename {
+			a.Key = h.config.prefix + a.Key
+			out = append(out, a)
+		}
+	}
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(out...)
+	return h.handler.Handle(ctx, nr)
+}
+
+func (h *Handler) conflicts(key string) bool {
+	for _, k := range h.config.allow {
+		if key == k {
+			return false
+		}
+	}
+	for _, ns := range h.config.namespaces {
+		if key == ns || strings.HasPrefix(key, ns+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Conflicts returns the number of attributes renamed or dropped so far,
+// across all handlers derived from the same Wrap call. An attribute added
+// via WithAttrs is counted for every record it is attached to.
+func (h *Handler) Conflicts() uint64 {
+	return h.count.Load()
+}
+
+// WithAttrs returns a new Handler with the attributes added to its scope.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler with the group opened in its scope.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	namespaces []string
+	allow      []string
+	action     Action
+	prefix     string
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithNamespaces replaces the protected namespaces.
+func WithNamespaces(namespaces ...string) Option {
+	return func(h *handlerOptions) {
+		h.namespaces = namespaces
+	}
+}
+
+// WithAllow lets attributes with the given exact keys through even though
+// they fall into a protected namespace, in addition to DefaultAllow.
+func WithAllow(keys ...string) Option {
+	return func(h *handlerOptions) {
+		h.allow = append(h.allow, keys...)
+	}
+}
+
+// WithAction sets what happens to conflicting attributes.
+func WithAction(a Action) Option {
+	return func(h *handlerOptions) {
+		h.action = a
+	}
+}
+
+// WithRenamePrefix sets the prefix prepended to the keys of conflicting
+// attributes by the Rename action.
+func WithRenamePrefix(prefix string) Option {
+	return func(h *handlerOptions) {
+		h.prefix = prefix
+	}
+}
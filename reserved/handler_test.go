@@ -0,0 +1,112 @@
+package reserved
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/events"
+	"github.com/mikluko/slogging/otel"
+	"github.com/mikluko/slogging/recordid"
+	"github.com/mikluko/slogging/route"
+	"github.com/mikluko/slogging/schema"
+	"github.com/mikluko/slogging/sequence"
+)
+
+func Test_Reserved(t *testing.T) {
+	t.Run("conflicting keys are renamed", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil))
+		logger := slog.New(h).With("log.level", "x")
+
+		logger.Info("test message", slog.Group("otel", "trace_id", "fake"), "error", "boom", "errors", 1, "logger", "a")
+
+		out := buf.String()
+		want := `user_log.level=x user_otel.trace_id=fake user_error=boom errors=1 logger=a`
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %s, got: %s", want, out)
+		}
+		if n := h.Conflicts(); n != 3 {
+			t.Errorf("expected 3 conflicts, got %d", n)
+		}
+	})
+
+	t.Run("conflicting keys are dropped", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil), WithAction(Drop), WithNamespaces("internal"))
+		logger := slog.New(h).WithGroup("internal")
+
+		logger.Info("test message", "k", "v")
+		slog.New(h).Info("other", "otel", 1)
+
+		out := buf.String()
+		if strings.Contains(out, "k=v") || !strings.Contains(out, "otel=1") {
+			t.Errorf("unexpected output: %s", out)
+		}
+		if n := h.Conflicts(); n != 1 {
+			t.Errorf("expected 1 conflict, got %d", n)
+		}
+	})
+
+	t.Run("allowed keys pass", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil), WithAllow("log.tenant"))
+
+		slog.New(h).Info("test message", "log.tenant", "acme")
+
+		if !strings.Contains(buf.String(), "log.tenant=acme") || h.Conflicts() != 0 {
+			t.Errorf("expected allowed key, got: %s", buf.String())
+		}
+	})
+
+	t.Run("control keys of the module pass by default", func(t *testing.T) {
+		keys := []string{
+			route.Key,
+			events.Key,
+			sequence.Key,
+			recordid.Key,
+			schema.Key,
+			slogging.OccurrencesKey,
+			slogging.DeprecatedKey,
+		}
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil))
+
+		for _, k := range keys {
+			slog.New(h).Info("test message", k, "x")
+		}
+
+		out := buf.String()
+		for _, k := range keys {
+			if !strings.Contains(out, " "+k+"=x") {
+				t.Errorf("expected %s to pass, got: %s", k, out)
+			}
+		}
+		if n := h.Conflicts(); n != 0 {
+			t.Errorf("expected no conflicts, got %d", n)
+		}
+	})
+
+	t.Run("injected otel group is preserved", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(otel.Wrap(slog.NewTextHandler(buf, nil))))
+
+		ctx, span := sdktrace.NewTracerProvider().Tracer("test-tracer").Start(context.Background(), "test-span")
+		defer span.End()
+
+		logger.InfoContext(ctx, "test message", slog.Group("otel", "trace_id", "fake"))
+
+		out := buf.String()
+		if !strings.Contains(out, "otel.trace_id="+span.SpanContext().TraceID().String()) {
+			t.Errorf("expected injected trace id, got: %s", out)
+		}
+		if !strings.Contains(out, "user_otel.trace_id=fake") {
+			t.Errorf("expected renamed user attribute, got: %s", out)
+		}
+	})
+}
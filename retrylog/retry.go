@@ -0,0 +1,198 @@
+// Package retrylog makes retry loops visible in logs.
+package retrylog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// Key is the correlation attribute shared by the records of one retry loop.
+const Key = "retry_id"
+
+const (
+	defaultMaxAttempts = 3
+	defaultBackoff     = 100 * time.Millisecond
+)
+
+type attempt struct {
+	err   error
+	delay time.Duration
+}
+
+// Tracker logs the attempts of a retry loop. Every record carries the
+// operation name and a "retry_id" correlating the attempts. Failed attempts
+// are logged at Warn with the attempt number, the error and the delay
+// before the next attempt; the final outcome is logged at Info on success
+// and at Error on failure, with the number of attempts and the total
+// duration. In canonical mode, see WithCanonical, nothing is logged until
+// the loop finishes, and the outcome record summarizes every attempt.
+//
+// A Tracker is meant to be used by the goroutine running the loop.
+type Tracker struct {
+	ctx       context.Context
+	logger    *slog.Logger
+	start     time.Time
+	attempts  []attempt
+	canonical bool
+	now       func() time.Time
+}
+
+// NewTracker starts tracking a retry loop for operation.
+func NewTracker(ctx context.Context, logger *slog.Logger, operation string, options ...Option) *Tracker {
+	config := newOptions(options)
+	return &Tracker{
+		ctx:       ctx,
+		logger:    logger.With(slog.String("operation", operation), slog.String(Key, newID())),
+		start:     time.Now(),
+		canonical: config.canonical,
+		now:       time.Now,
+	}
+}
+
+// Failed records a failed attempt to be retried after delay.
+func (t *Tracker) Failed(err error, delay time.Duration) {
+	t.attempts = append(t.attempts, attempt{err: err, delay: delay})
+	if t.canonical {
+		return
+	}
+	t.logger.LogAttrs(t.ctx, slog.LevelWarn, "attempt failed",
+		slog.Int("attempt", len(t.attempts)),
+		slog.Any("error", err),
+		slog.Duration("delay", delay),
+	)
+}
+
+// Finish records the final attempt and logs the outcome of the loop: a
+// nil err means the last attempt succeeded.
+func (t *Tracker) Finish(err error) {
+	t.attempts = append(t.attempts, attempt{err: err})
+	attrs := []slog.Attr{
+		slog.Int("attempts", len(t.attempts)),
+		slog.Duration("duration", t.now().Sub(t.start)),
+	}
+	level, msg := slog.LevelInfo, "retry succeeded"
+	if err != nil {
+		level, msg = slog.LevelError, "retry failed"
+		attrs = append(attrs, slog.Any("error", err))
+	}
+	if t.canonical {
+		history := make([]slog.Attr, len(t.attempts))
+		for i, a := range t.attempts {
+			var group []slog.Attr
+			if a.err != nil {
+				group = append(group, slog.String("error", a.err.Error()))
+			}
+			if i < len(t.attempts)-1 {
+				group = append(group, slog.Duration("delay", a.delay))
+			}
+			history[i] = slog.Attr{Key: strconv.Itoa(i + 1), Value: slog.GroupValue(group...)}
+		}
+		attrs = append(attrs, slog.Attr{Key: "history", Value: slog.GroupValue(history...)})
+	}
+	t.logger.LogAttrs(t.ctx, level, msg, attrs...)
+}
+
+// Do runs fn until it succeeds, returns an error that is not retryable,
+// the maximum number of attempts is reached or ctx is done, logging the
+// attempts with a Tracker. The attempt number passed to fn starts at 1.
+// It returns the error of the last attempt.
+func Do(ctx context.Context, logger *slog.Logger, operation string, fn func(ctx context.Context, attempt int) error, options ...Option) error {
+	config := newOptions(options)
+	t := NewTracker(ctx, logger, operation, options...)
+	for n := 1; ; n++ {
+		err := fn(ctx, n)
+		if err == nil || n >= config.maxAttempts || !config.retryable(err) {
+			t.Finish(err)
+			return err
+		}
+		delay := config.backoff(n)
+		t.Failed(err, delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			err = context.Cause(ctx)
+			t.Finish(err)
+			return err
+		}
+	}
+}
+
+func newID() string {
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+type handlerOptions struct {
+	canonical   bool
+	maxAttempts int
+	backoff     func(attempt int) time.Duration
+	retryable   func(err error) bool
+}
+
+func newOptions(options []Option) handlerOptions {
+	config := handlerOptions{
+		maxAttempts: defaultMaxAttempts,
+		backoff:     Exponential(defaultBackoff),
+		retryable:   func(error) bool { return true },
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return config
+}
+
+// Exponential returns a backoff doubling base with every attempt.
+func Exponential(base time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		return base << min(attempt-1, 30)
+	}
+}
+
+// Option is a function that configures a Tracker and Do.
+type Option func(h *handlerOptions)
+
+// WithCanonical enables canonical-line mode: instead of one record per
+// failed attempt, a single record summarizing the loop is logged when it
+// finishes, with the error and delay of every attempt in a "history" group
+// keyed by attempt number.
+func WithCanonical(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.canonical = true
+		for i := range x {
+			h.canonical = x[i]
+		}
+	}
+}
+
+// WithMaxAttempts sets the maximum number of attempts made by Do, 3 by
+// default. Values below 1 are treated as 1.
+func WithMaxAttempts(n int) Option {
+	return func(h *handlerOptions) {
+		h.maxAttempts = max(n, 1)
+	}
+}
+
+// WithBackoff sets the function returning the delay after the given failed
+// attempt in Do. The default is Exponential(100 * time.Millisecond).
+func WithBackoff(fn func(attempt int) time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.backoff = fn
+	}
+}
+
+// WithRetryable sets the function deciding whether Do retries after an
+// error. By default every error is retried.
+func WithRetryable(fn func(err error) bool) Option {
+	return func(h *handlerOptions) {
+		h.retryable = fn
+	}
+}
@@ -0,0 +1,104 @@
+package retrylog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Do(t *testing.T) {
+	noDelay := WithBackoff(func(int) time.Duration { return 0 })
+
+	t.Run("attempts share a correlation key", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(slog.NewTextHandler(buf, nil))
+
+		err := Do(context.Background(), logger, "fetch", func(_ context.Context, attempt int) error {
+			if attempt < 3 {
+				return errors.New("unavailable")
+			}
+			return nil
+		}, WithBackoff(func(n int) time.Duration { return time.Duration(n) * time.Millisecond }))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 records, got: %s", buf.String())
+		}
+		id := regexp.MustCompile(`retry_id=(\w+)`).FindStringSubmatch(lines[0])
+		if id == nil || strings.Count(buf.String(), id[0]) != 3 {
+			t.Errorf("expected shared retry_id, got: %s", buf.String())
+		}
+		if !strings.Contains(lines[0], `level=WARN msg="attempt failed" operation=fetch`) || !strings.HasSuffix(lines[0], "attempt=1 error=unavailable delay=1ms") {
+			t.Errorf("unexpected attempt record: %s", lines[0])
+		}
+		if !strings.HasSuffix(lines[1], "attempt=2 error=unavailable delay=2ms") {
+			t.Errorf("unexpected attempt record: %s", lines[1])
+		}
+		if !strings.Contains(lines[2], `level=INFO msg="retry succeeded"`) || !strings.Contains(lines[2], "attempts=3 duration=") {
+			t.Errorf("unexpected outcome record: %s", lines[2])
+		}
+	})
+
+	t.Run("canonical line", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(slog.NewTextHandler(buf, nil))
+
+		err := Do(context.Background(), logger, "fetch", func(context.Context, int) error {
+			return errors.New("unavailable")
+		}, noDelay, WithCanonical(), WithMaxAttempts(2))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 1 {
+			t.Fatalf("expected 1 record, got: %s", buf.String())
+		}
+		if !strings.Contains(lines[0], `level=ERROR msg="retry failed"`) || !strings.Contains(lines[0], "attempts=2") ||
+			!strings.HasSuffix(lines[0], "error=unavailable history.1.error=unavailable history.1.delay=0s history.2.error=unavailable") {
+			t.Errorf("unexpected summary record: %s", lines[0])
+		}
+	})
+
+	t.Run("non-retryable error stops", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		calls := 0
+		errFatal := errors.New("bad request")
+		err := Do(context.Background(), slog.New(slog.NewTextHandler(buf, nil)), "fetch", func(context.Context, int) error {
+			calls++
+			return errFatal
+		}, noDelay, WithRetryable(func(err error) bool { return !errors.Is(err, errFatal) }))
+
+		if !errors.Is(err, errFatal) || calls != 1 || !strings.Contains(buf.String(), "attempts=1") {
+			t.Errorf("unexpected result: %v, %d calls, %s", err, calls, buf.String())
+		}
+	})
+
+	t.Run("cancelled context stops waiting", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		ctx, cancel := context.WithCancelCause(context.Background())
+		err := Do(ctx, slog.New(slog.NewTextHandler(buf, nil)), "fetch", func(context.Context, int) error {
+			cancel(errors.New("shutting down"))
+			return errors.New("unavailable")
+		}, WithBackoff(func(int) time.Duration { return time.Hour }))
+
+		if err == nil || err.Error() != "shutting down" || !strings.Contains(buf.String(), `msg="retry failed"`) {
+			t.Errorf("unexpected result: %v, %s", err, buf.String())
+		}
+	})
+}
+
+func Test_Exponential(t *testing.T) {
+	b := Exponential(100 * time.Millisecond)
+	if b(1) != 100*time.Millisecond || b(3) != 400*time.Millisecond {
+		t.Errorf("unexpected backoff: %s, %s", b(1), b(3))
+	}
+}
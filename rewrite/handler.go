@@ -0,0 +1,255 @@
+// Package rewrite provides a slog.Handler wrapper applying transforms to
+// the attributes of records before delivering them to any handler: renaming
+// keys, flattening and nesting groups, truncating strings, converting
+// values and dropping empty ones.
+package rewrite
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// Transform rewrites the attributes found at one depth of a record, the
+// names of the groups enclosing them given by groups, returning the
+// attributes replacing them. Transforms must not modify attrs in place.
+type Transform func(groups []string, attrs []slog.Attr) []slog.Attr
+
+// Each returns a Transform applying fn to every attribute, like the
+// ReplaceAttr function of slog.HandlerOptions: fn returns the attribute
+// replacing a, or false to drop it.
+func Each(fn func(groups []string, a slog.Attr) (slog.Attr, bool)) Transform {
+	return func(groups []string, attrs []slog.Attr) []slog.Attr {
+		out := make([]slog.Attr, 0, len(attrs))
+		for _, a := range attrs {
+			if a, ok := fn(groups, a); ok {
+				out = append(out, a)
+			}
+		}
+		return out
+	}
+}
+
+// Rename returns a Transform renaming the attributes at the given paths,
+// group names and key joined with dots such as "http.status", to the given
+// keys, in place. Moving attributes to other groups is done by the rename
+// package.
+func Rename(renames map[string]string) Transform {
+	return Each(func(groups []string, a slog.Attr) (slog.Attr, bool) {
+		if key, ok := renames[path(groups, a.Key)]; ok {
+			a.Key = key
+		}
+		return a, true
+	})
+}
+
+// Flatten returns a Transform replacing the groups at the given paths, or
+// every group if none is given, with their attributes, prefixing their keys
+// with the group name and sep: {"http": {"status": 200}} becomes
+// {"http_status": 200} with sep "_".
+func Flatten(sep string, paths ...string) Transform {
+	return func(groups []string, attrs []slog.Attr) []slog.Attr {
+		out := make([]slog.Attr, 0, len(attrs))
+		for _, a := range attrs {
+			v := a.Value.Resolve()
+			if v.Kind() != slog.KindGroup || a.Key == "" ||
+				len(paths) > 0 && !slices.Contains(paths, path(groups, a.Key)) {
+				out = append(out, a)
+				continue
+			}
+			out = append(out, flatten(a.Key, sep, v.Group(), len(paths) == 0)...)
+		}
+		return out
+	}
+}
+
+func flatten(prefix, sep string, attrs []slog.Attr, deep bool) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		key := prefix + sep + a.Key
+		if a.Key == "" {
+			key = prefix
+		}
+		if v.Kind() == slog.KindGroup && (deep || a.Key == "") {
+			out = append(out, flatten(key, sep, v.Group(), deep)...)
+			continue
+		}
+		out = append(out, slog.Attr{Key: key, Value: v})
+	}
+	return out
+}
+
+// Nest returns a Transform moving the attributes whose key starts with
+// prefix into the group of the given name, with the prefix removed:
+// {"http_status": 200} becomes {"http": {"status": 200}} with prefix
+// "http_" and group "http". The group takes the place of the first moved
+// attribute, or receives them if it already exists.
+func Nest(prefix, group string) Transform {
+	return func(_ []string, attrs []slog.Attr) []slog.Attr {
+		var moved []slog.Attr
+		at := -1
+		out := make([]slog.Attr, 0, len(attrs))
+		for _, a := range attrs {
+			if len(a.Key) > len(prefix) && strings.HasPrefix(a.Key, prefix) {
+				if at < 0 {
+					at = len(out)
+				}
+				moved = append(moved, slog.Attr{Key: a.Key[len(prefix):], Value: a.Value})
+				continue
+			}
+			out = append(out, a)
+		}
+		if len(moved) == 0 {
+			return attrs
+		}
+		for i, a := range out {
+			if v := a.Value.Resolve(); a.Key == group && v.Kind() == slog.KindGroup {
+				out[i] = slog.Attr{Key: group, Value: slog.GroupValue(append(slices.Clip(v.Group()), moved...)...)}
+				return out
+			}
+		}
+		return slices.Insert(out, at, slog.Attr{Key: group, Value: slog.GroupValue(moved...)})
+	}
+}
+
+// Truncate returns a Transform shortening string values longer than n
+// runes to n runes followed by an ellipsis.
+func Truncate(n int) Transform {
+	return Each(func(_ []string, a slog.Attr) (slog.Attr, bool) {
+		v := a.Value.Resolve()
+		if v.Kind() != slog.KindString || utf8.RuneCountInString(v.String()) <= n {
+			return a, true
+		}
+		s := v.String()
+		i := 0
+		for range n {
+			_, size := utf8.DecodeRuneInString(s[i:])
+			i += size
+		}
+		return slog.String(a.Key, s[:i]+"…"), true
+	})
+}
+
+// Convert returns a Transform replacing the values of the given kind with
+// the result of fn, e.g. durations with their number of milliseconds.
+func Convert(kind slog.Kind, fn func(slog.Value) slog.Value) Transform {
+	return Each(func(_ []string, a slog.Attr) (slog.Attr, bool) {
+		if v := a.Value.Resolve(); v.Kind() == kind {
+			a.Value = fn(v)
+		}
+		return a, true
+	})
+}
+
+// DropEmpty returns a Transform dropping attributes holding empty strings,
+// nil values or groups without non-empty attributes.
+func DropEmpty() Transform {
+	return Each(func(_ []string, a slog.Attr) (slog.Attr, bool) {
+		return a, !empty(a.Value)
+	})
+}
+
+func empty(v slog.Value) bool {
+	v = v.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return v.String() == ""
+	case slog.KindAny:
+		return v.Any() == nil
+	case slog.KindGroup:
+		for _, a := range v.Group() {
+			if !empty(a.Value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func path(groups []string, key string) string {
+	if len(groups) == 0 {
+		return key
+	}
+	return strings.Join(groups, ".") + "." + key
+}
+
+// Handler is a slog.Handler applying transforms to the attributes of
+// records before delivering them to the wrapped handler. The transforms
+// are applied in order to the top-level attributes, then to the attributes
+// of each resulting group, and so on down the groups. Groups and
+// attributes added via WithAttrs and WithGroup are materialized into every
+// record, so that they are transformed too.
+type Handler struct {
+	handler    slog.Handler
+	scope      scope.Scope
+	transforms []Transform
+}
+
+// Wrap creates a handler applying transforms before delivering records to
+// handler.
+func Wrap(handler slog.Handler, transforms ...Transform) *Handler {
+	var ts []Transform
+	for _, t := range transforms {
+		if t != nil {
+			ts = append(ts, t)
+		}
+	}
+	return &Handler{handler: handler, transforms: ts}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle transforms the attributes of the record and delivers it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(h.apply(nil, h.scope.Attrs(r))...)
+	return h.handler.Handle(ctx, nr)
+}
+
+func (h *Handler) apply(groups []string, attrs []slog.Attr) []slog.Attr {
+	for _, t := range h.transforms {
+		attrs = t(groups, attrs)
+	}
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		v := a.Value.Resolve()
+		if v.Kind() == slog.KindGroup {
+			inner := groups
+			if a.Key != "" {
+				inner = append(slices.Clip(groups), a.Key)
+			}
+			a = slog.Attr{Key: a.Key, Value: slog.GroupValue(h.apply(inner, v.Group())...)}
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// WithAttrs returns a new Handler with the attributes added to its scope.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler with the group opened in its scope.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
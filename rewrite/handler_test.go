@@ -0,0 +1,100 @@
+package rewrite
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
+
+func Test_Handler(t *testing.T) {
+	for name, tc := range map[string]struct {
+		transforms []Transform
+		want       string
+	}{
+		"no transforms": {
+			want: `msg=m svc=api http.status=200 http.path=/x note="" d=1.5s`,
+		},
+		"rename by path": {
+			transforms: []Transform{Rename(map[string]string{"svc": "service", "http.status": "code"})},
+			want:       `msg=m service=api http.code=200 http.path=/x note="" d=1.5s`,
+		},
+		"flatten all groups": {
+			transforms: []Transform{Flatten("_")},
+			want:       `msg=m svc=api http_status=200 http_path=/x note="" d=1.5s`,
+		},
+		"flatten then nest": {
+			transforms: []Transform{Flatten("_"), Nest("http_", "req")},
+			want:       `msg=m svc=api req.status=200 req.path=/x note="" d=1.5s`,
+		},
+		"truncate": {
+			transforms: []Transform{Truncate(2)},
+			want:       `msg=m svc=ap… http.status=200 http.path=/x note="" d=1.5s`,
+		},
+		"convert durations": {
+			transforms: []Transform{Convert(slog.KindDuration, func(v slog.Value) slog.Value {
+				return slog.Int64Value(v.Duration().Milliseconds())
+			})},
+			want: `msg=m svc=api http.status=200 http.path=/x note="" d=1500`,
+		},
+		"drop empty": {
+			transforms: []Transform{DropEmpty()},
+			want:       `msg=m svc=api http.status=200 http.path=/x d=1.5s`,
+		},
+		"each": {
+			transforms: []Transform{Each(func(groups []string, a slog.Attr) (slog.Attr, bool) {
+				return a, len(groups) == 0
+			})},
+			want: `msg=m svc=api note="" d=1.5s`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			h := Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}), tc.transforms...)
+			slog.New(h).With("svc", "api").Info("m",
+				slog.Group("http", "status", 200, "path", "/x"), "note", "", "d", 1500*time.Millisecond)
+			if got := strings.TrimSpace(buf.String()); got != "level=INFO "+tc.want {
+				t.Errorf("\nwant level=INFO %s\ngot  %s", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("flatten selected groups", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}), Flatten(".", "a.b"))
+		slog.New(h).Info("m", slog.Group("a", slog.Group("b", slog.Group("c", "k", 1))), slog.Group("b", "k", 2))
+		want := `level=INFO msg=m a.b.c.k=1 b.k=2`
+		if got := strings.TrimSpace(buf.String()); got != want {
+			t.Errorf("\nwant %s\ngot  %s", want, got)
+		}
+	})
+
+	t.Run("nest into an existing group", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}), Nest("db_", "db"))
+		slog.New(h).Info("m", "db_host", "h", slog.Group("db", "name", "n"), "db_port", 5432)
+		want := `level=INFO msg=m db.name=n db.host=h db.port=5432`
+		if got := strings.TrimSpace(buf.String()); got != want {
+			t.Errorf("\nwant %s\ngot  %s", want, got)
+		}
+	})
+
+	t.Run("attributes of derived handlers are transformed", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}),
+			Rename(map[string]string{"req.id": "request_id"}), DropEmpty())
+		slog.New(h).WithGroup("req").With("id", 7, "empty", "").WithGroup("inner").Info("m")
+		want := `level=INFO msg=m req.request_id=7`
+		if got := strings.TrimSpace(buf.String()); got != want {
+			t.Errorf("\nwant %s\ngot  %s", want, got)
+		}
+	})
+}
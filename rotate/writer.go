@@ -0,0 +1,400 @@
+// Package rotate provides an io.Writer writing to a file rotated by size
+// and age, keeping a bounded number of optionally compressed backups. Use
+// it as the destination of any handler:
+//
+//	w, err := rotate.Open("/var/log/app.log", rotate.WithMaxSize(100<<20), rotate.WithCompress())
+//	logger := slog.New(slog.NewJSONHandler(w, nil))
+package rotate
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// backupTimeFormat is the layout of the timestamp in backup file names.
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+const compressSuffix = ".gz"
+
+// ErrClosed is returned by Write after the writer has been closed.
+var ErrClosed = errors.New("slogging: rotating writer is closed")
+
+// Writer is an io.Writer appending to a file, rotated once it would grow
+// beyond the maximum size or once it is older than the rotation interval.
+// Rotated files are renamed to backups named after the file with the
+// rotation time inserted before the extension, e.g.
+// app-2024-01-02T15-04-05.000.log, then compressed and pruned from a
+// background goroutine. Writes are serialized, so a Writer may be shared
+// by several handlers.
+type Writer struct {
+	path   string
+	config handlerOptions
+
+	mutex   sync.Mutex
+	file    *os.File
+	size    int64
+	opened  time.Time
+	closed  bool
+	now     func() time.Time
+	mill    chan struct{}
+	signals chan os.Signal
+	done    sync.WaitGroup
+}
+
+// Open opens the file at path for appending, creating it and its directory
+// if needed, and starts the goroutine maintaining the backups. Close must
+// be called to stop it.
+func Open(path string, options ...Option) (*Writer, error) {
+	config := handlerOptions{
+		mode: 0o644,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	w := &Writer{
+		path:   path,
+		config: config,
+		now:    time.Now,
+		mill:   make(chan struct{}, 1),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	w.done.Add(1)
+	go w.runMill()
+	if len(config.signals) > 0 {
+		w.signals = make(chan os.Signal, 1)
+		signal.Notify(w.signals, config.signals...)
+		w.done.Add(1)
+		go w.runSignals()
+	}
+	return w, nil
+}
+
+// open opens the file at the path for appending. Must be called with the
+// mutex held.
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0o755); err != nil {
+		return fmt.Errorf("error when creating log directory: %w", err)
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, w.config.mode)
+	if err != nil {
+		return fmt.Errorf("error when opening log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("error when opening log file: %w", err)
+	}
+	w.file, w.size, w.opened = f, info.Size(), w.now()
+	return nil
+}
+
+// Write appends p to the file, rotating it first if p would take it beyond
+// the maximum size, or if it is older than the rotation interval. A single
+// write larger than the maximum size goes to a file of its own.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return 0, ErrClosed
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	full := w.config.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.config.maxSize
+	old := w.config.interval > 0 && w.now().Sub(w.opened) >= w.config.interval
+	if full || old {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate renames the current file to a backup and opens a new one.
+func (w *Writer) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	return w.rotate()
+}
+
+// rotate must be called with the mutex held.
+func (w *Writer) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("error when closing log file: %w", err)
+		}
+		w.file = nil
+	}
+	if err := os.Rename(w.path, w.backupName(w.now())); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error when renaming log file: %w", err)
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	select {
+	case w.mill <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Reopen closes the file and opens the file at the path again, without
+// renaming it. It lets external tools such as logrotate move the file
+// away; see WithReopenOn.
+func (w *Writer) Reopen() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	if w.file != nil {
+		_ = w.file.Close()
+		w.file = nil
+	}
+	return w.open()
+}
+
+// Sync commits the contents of the file to stable storage.
+func (w *Writer) Sync() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close closes the file and waits for the backups to be compressed and
+// pruned.
+func (w *Writer) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	close(w.mill)
+	if w.signals != nil {
+		signal.Stop(w.signals)
+		close(w.signals)
+	}
+	w.mutex.Unlock()
+	w.done.Wait()
+	return err
+}
+
+func (w *Writer) runSignals() {
+	defer w.done.Done()
+	for range w.signals {
+		if err := w.Reopen(); err != nil && w.config.onError != nil {
+			w.config.onError(err)
+		}
+	}
+}
+
+func (w *Writer) runMill() {
+	defer w.done.Done()
+	for range w.mill {
+		if err := w.maintain(); err != nil && w.config.onError != nil {
+			w.config.onError(err)
+		}
+	}
+}
+
+func (w *Writer) backupName(t time.Time) string {
+	dir, base := filepath.Split(w.path)
+	ext := filepath.Ext(base)
+	return filepath.Join(dir, strings.TrimSuffix(base, ext)+"-"+t.UTC().Format(backupTimeFormat)+ext)
+}
+
+type backup struct {
+	path string
+	time time.Time
+}
+
+// backups returns the backups of the file, newest first.
+func (w *Writer) backups() ([]backup, error) {
+	dir, base := filepath.Split(w.path)
+	ext := filepath.Ext(base)
+	prefix := strings.TrimSuffix(base, ext) + "-"
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, fmt.Errorf("error when listing log backups: %w", err)
+	}
+	var out []backup
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), compressSuffix)
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		t, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		out = append(out, backup{path: filepath.Join(dir, e.Name()), time: t})
+	}
+	slices.SortFunc(out, func(a, b backup) int { return b.time.Compare(a.time) })
+	return out, nil
+}
+
+// maintain removes the backups beyond the retention count and compresses
+// the remaining ones.
+func (w *Writer) maintain() error {
+	backups, err := w.backups()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	if n := w.config.maxBackups; n > 0 && len(backups) > n {
+		for _, b := range backups[n:] {
+			if err := os.Remove(b.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("error when removing log backup: %w", err))
+			}
+		}
+		backups = backups[:n]
+	}
+	if w.config.compress {
+		for _, b := range backups {
+			if !strings.HasSuffix(b.path, compressSuffix) {
+				errs = append(errs, compress(b.path))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// compress replaces the file at path with its gzip compressed copy.
+func compress(path string) (err error) {
+	defer func() {
+		if err != nil {
+			err = fmt.Errorf("error when compressing log backup: %w", err)
+		}
+	}()
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(path+compressSuffix, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode())
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path + compressSuffix)
+		return err
+	}
+	return os.Remove(path)
+}
+
+type handlerOptions struct {
+	maxSize    int64
+	interval   time.Duration
+	maxBackups int
+	compress   bool
+	mode       os.FileMode
+	signals    []os.Signal
+	onError    func(error)
+}
+
+// Option is a function that configures a Writer.
+type Option func(h *handlerOptions)
+
+// WithMaxSize rotates the file before it grows beyond n bytes. Values
+// below 1 disable rotation by size.
+func WithMaxSize(n int64) Option {
+	return func(h *handlerOptions) {
+		h.maxSize = n
+	}
+}
+
+// WithInterval rotates the file once it has been open for d, e.g. 24 hours
+// for daily files. Values below 1 disable rotation by age.
+func WithInterval(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.interval = d
+	}
+}
+
+// WithMaxBackups keeps at most n backups, removing the oldest. Values
+// below 1 keep every backup.
+func WithMaxBackups(n int) Option {
+	return func(h *handlerOptions) {
+		h.maxBackups = n
+	}
+}
+
+// WithCompress sets whether backups are compressed with gzip. It is
+// disabled by default.
+func WithCompress(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.compress = true
+		for i := range x {
+			h.compress = x[i]
+		}
+	}
+}
+
+// WithMode sets the permissions of created files. Defaults to 0644.
+func WithMode(mode os.FileMode) Option {
+	return func(h *handlerOptions) {
+		h.mode = mode
+	}
+}
+
+// WithReopenOn reopens the file when the process receives one of the
+// given signals, syscall.SIGHUP when none is given, as expected by
+// logrotate and similar tools moving the file away.
+func WithReopenOn(signals ...os.Signal) Option {
+	return func(h *handlerOptions) {
+		if len(signals) == 0 {
+			signals = []os.Signal{syscall.SIGHUP}
+		}
+		h.signals = append(h.signals, signals...)
+	}
+}
+
+// WithErrorHandler sets a function called with errors maintaining the
+// backups and reopening the file on signals. It is called from background
+// goroutines.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
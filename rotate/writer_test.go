@@ -0,0 +1,154 @@
+package rotate
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func files(t *testing.T, dir string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func Test_Writer(t *testing.T) {
+	start := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	t.Run("rotation by size with retention", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "logs", "app.log")
+		w, err := Open(path, WithMaxSize(10), WithMaxBackups(2))
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := start
+		w.now = func() time.Time { return now }
+		for _, s := range []string{"aaaa\n", "bbbb\n", "cccc\n", "dddd\n", "eeee\n", "ffff\n", "gggggggggggg\n"} {
+			if _, err := w.Write([]byte(s)); err != nil {
+				t.Fatal(err)
+			}
+			now = now.Add(time.Second)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"app-2024-01-02T15-04-09.000.log", "app-2024-01-02T15-04-11.000.log", "app.log"}
+		if got := files(t, filepath.Join(dir, "logs")); !slices.Equal(got, want) {
+			t.Fatalf("unexpected files: %v", got)
+		}
+		if got := read(t, filepath.Join(dir, "logs", want[0])); got != "cccc\ndddd\n" {
+			t.Errorf("unexpected backup contents: %q", got)
+		}
+		if got := read(t, filepath.Join(dir, "logs", want[1])); got != "eeee\nffff\n" {
+			t.Errorf("unexpected backup contents: %q", got)
+		}
+		if got := read(t, path); got != "gggggggggggg\n" {
+			t.Errorf("unexpected file contents: %q", got)
+		}
+	})
+
+	t.Run("rotation by age with compression", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+		if err := os.WriteFile(path, []byte("old\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		w, err := Open(path, WithInterval(time.Hour), WithCompress())
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		w.now = func() time.Time { return now }
+		_, _ = w.Write([]byte("first\n"))
+		now = now.Add(time.Hour)
+		_, _ = w.Write([]byte("second\n"))
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		got := files(t, dir)
+		if len(got) != 2 || !strings.HasSuffix(got[0], ".log.gz") {
+			t.Fatalf("unexpected files: %v", got)
+		}
+		f, err := os.Open(filepath.Join(dir, got[0]))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := io.ReadAll(zr); string(b) != "old\nfirst\n" {
+			t.Errorf("unexpected backup contents: %q", b)
+		}
+		if got := read(t, path); got != "second\n" {
+			t.Errorf("unexpected file contents: %q", got)
+		}
+	})
+
+	t.Run("reopen on signal", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "app.log")
+		w, err := Open(path, WithReopenOn(syscall.SIGHUP))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close()
+		_, _ = w.Write([]byte("before\n"))
+		if err := os.Rename(path, path+".1"); err != nil {
+			t.Fatal(err)
+		}
+		p, _ := os.FindProcess(os.Getpid())
+		if err := p.Signal(syscall.SIGHUP); err != nil {
+			t.Skip("signals not supported:", err)
+		}
+		deadline := time.Now().Add(time.Second)
+		for time.Now().Before(deadline) {
+			if _, err := os.Stat(path); err == nil {
+				break
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		_, _ = w.Write([]byte("after\n"))
+		if got := read(t, path); got != "after\n" {
+			t.Errorf("unexpected file contents: %q", got)
+		}
+		if got := read(t, path+".1"); got != "before\n" {
+			t.Errorf("unexpected moved file contents: %q", got)
+		}
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		w, err := Open(filepath.Join(t.TempDir(), "app.log"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = w.Close()
+		if _, err := w.Write([]byte("x")); err != ErrClosed {
+			t.Errorf("expected ErrClosed, got %v", err)
+		}
+	})
+}
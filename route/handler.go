@@ -0,0 +1,187 @@
+package route
+
+import (
+	"context"
+	"log/slog"
+)
+
+const (
+	// Key is the reserved attribute that forces a record to the destination
+	// with the given name, e.g. slog.String(route.Key, "audit").
+	Key = "log.route"
+
+	// Default is the name under which the fallback destination is registered.
+	Default = "default"
+)
+
+// Matcher reports whether a record should be sent to the destination of the rule.
+type Matcher func(ctx context.Context, r slog.Record) bool
+
+type rule struct {
+	destination string
+	match       Matcher
+}
+
+// Handler is a slog.Handler that delivers every record to exactly one of
+// several named destinations. The destination is selected by, in order of
+// precedence: the reserved route attribute on the record, the route attribute
+// added via WithAttrs, the first matching rule, and finally the default
+// destination. The reserved attribute is never forwarded to destinations.
+type Handler struct {
+	destinations map[string]slog.Handler
+	rules        []rule
+	key          string
+	route        string // Route pinned via WithAttrs
+}
+
+// New creates a routing handler delivering to def unless a rule or the
+// reserved route attribute selects another destination.
+func New(def slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{
+		destinations: map[string]slog.Handler{Default: def},
+		key:          Key,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{
+		destinations: config.destinations,
+		rules:        config.rules,
+		key:          config.key,
+	}
+}
+
+// Enabled reports whether any destination handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, d := range h.destinations {
+		if d.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle selects the destination for the record and delegates to it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	name := h.route
+	override := false
+	r.Attrs(func(a slog.Attr) bool {
+		if a.Key == h.key {
+			name = a.Value.Resolve().String()
+			override = true
+			return false
+		}
+		return true
+	})
+
+	if override {
+		stripped := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		r.Attrs(func(a slog.Attr) bool {
+			if a.Key != h.key {
+				stripped.AddAttrs(a)
+			}
+			return true
+		})
+		r = stripped
+	}
+
+	if name == "" {
+		for _, rl := range h.rules {
+			if rl.match(ctx, r) {
+				name = rl.destination
+				break
+			}
+		}
+	}
+
+	d, ok := h.destinations[name]
+	if !ok {
+		d = h.destinations[Default]
+	}
+	if !d.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return d.Handle(ctx, r)
+}
+
+// WithAttrs returns a new Handler whose destinations include the given
+// attributes. A reserved route attribute pins the destination for all
+// records logged through the returned handler.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	route := h.route
+	filtered := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		if a.Key == h.key {
+			route = a.Value.Resolve().String()
+			continue
+		}
+		filtered = append(filtered, a)
+	}
+	destinations := make(map[string]slog.Handler, len(h.destinations))
+	for name, d := range h.destinations {
+		if len(filtered) > 0 {
+			d = d.WithAttrs(filtered)
+		}
+		destinations[name] = d
+	}
+	return &Handler{
+		destinations: destinations,
+		rules:        h.rules,
+		key:          h.key,
+		route:        route,
+	}
+}
+
+// WithGroup returns a new Handler that starts a group on every destination.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	destinations := make(map[string]slog.Handler, len(h.destinations))
+	for n, d := range h.destinations {
+		destinations[n] = d.WithGroup(name)
+	}
+	return &Handler{
+		destinations: destinations,
+		rules:        h.rules,
+		key:          h.key,
+		route:        h.route,
+	}
+}
+
+type handlerOptions struct {
+	destinations map[string]slog.Handler
+	rules        []rule
+	key          string
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithDestination registers a named destination. Registering Default
+// replaces the fallback destination.
+func WithDestination(name string, handler slog.Handler) Option {
+	return func(h *handlerOptions) {
+		h.destinations[name] = handler
+	}
+}
+
+// WithRule sends records matched by match to the named destination.
+// Rules are evaluated in the order they were added.
+func WithRule(destination string, match Matcher) Option {
+	return func(h *handlerOptions) {
+		h.rules = append(h.rules, rule{destination: destination, match: match})
+	}
+}
+
+// WithKey changes the reserved attribute key honored for per-record overrides.
+func WithKey(key string) Option {
+	return func(h *handlerOptions) {
+		h.key = key
+	}
+}
@@ -0,0 +1,88 @@
+package route
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func Test_Route(t *testing.T) {
+	setup := func(options ...Option) (*bytes.Buffer, *bytes.Buffer, *slog.Logger) {
+		def := new(bytes.Buffer)
+		audit := new(bytes.Buffer)
+		opts := append([]Option{WithDestination("audit", slog.NewTextHandler(audit, nil))}, options...)
+		return def, audit, slog.New(New(slog.NewTextHandler(def, nil), opts...))
+	}
+
+	t.Run("records go to default destination", func(t *testing.T) {
+		def, audit, logger := setup()
+		logger.Info("hello")
+		if !strings.Contains(def.String(), "msg=hello") {
+			t.Errorf("expected record in default destination, got: %s", def.String())
+		}
+		if audit.Len() != 0 {
+			t.Errorf("unexpected output in audit destination: %s", audit.String())
+		}
+	})
+
+	t.Run("reserved attribute overrides destination and is stripped", func(t *testing.T) {
+		def, audit, logger := setup()
+		logger.WithGroup("g").Info("login", Key, "audit", "user", "bob")
+		if def.Len() != 0 {
+			t.Errorf("unexpected output in default destination: %s", def.String())
+		}
+		line := audit.String()
+		if !strings.Contains(line, "g.user=bob") {
+			t.Errorf("expected grouped attribute in audit destination, got: %s", line)
+		}
+		if strings.Contains(line, Key) {
+			t.Errorf("reserved attribute should be stripped, got: %s", line)
+		}
+	})
+
+	t.Run("reserved attribute via WithAttrs pins destination", func(t *testing.T) {
+		def, audit, logger := setup()
+		logger.With(Key, "audit", "svc", "api").Info("pinned")
+		if def.Len() != 0 {
+			t.Errorf("unexpected output in default destination: %s", def.String())
+		}
+		if !strings.Contains(audit.String(), "svc=api") {
+			t.Errorf("expected record in audit destination, got: %s", audit.String())
+		}
+	})
+
+	t.Run("rules select destination", func(t *testing.T) {
+		def, audit, logger := setup(WithRule("audit", func(_ context.Context, r slog.Record) bool {
+			return r.Level >= slog.LevelError
+		}))
+		logger.Info("info")
+		logger.Error("error")
+		if !strings.Contains(def.String(), "msg=info") || strings.Contains(def.String(), "msg=error") {
+			t.Errorf("unexpected default output: %s", def.String())
+		}
+		if !strings.Contains(audit.String(), "msg=error") {
+			t.Errorf("expected error in audit destination, got: %s", audit.String())
+		}
+	})
+
+	t.Run("record override wins over rules", func(t *testing.T) {
+		def, audit, logger := setup(WithRule("audit", func(context.Context, slog.Record) bool { return true }))
+		logger.Info("forced", Key, Default)
+		if !strings.Contains(def.String(), "msg=forced") {
+			t.Errorf("expected record in default destination, got: %s", def.String())
+		}
+		if audit.Len() != 0 {
+			t.Errorf("unexpected output in audit destination: %s", audit.String())
+		}
+	})
+
+	t.Run("unknown route falls back to default", func(t *testing.T) {
+		def, _, logger := setup()
+		logger.Info("lost", Key, "nowhere")
+		if !strings.Contains(def.String(), "msg=lost") {
+			t.Errorf("expected record in default destination, got: %s", def.String())
+		}
+	})
+}
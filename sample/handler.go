@@ -0,0 +1,139 @@
+package sample
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"sync/atomic"
+)
+
+// Sampler decides whether a record is kept. Implementations must be safe
+// for concurrent use.
+type Sampler interface {
+	Sample(ctx context.Context, r slog.Record) bool
+}
+
+// SamplerFunc adapts a function to the Sampler interface.
+type SamplerFunc func(ctx context.Context, r slog.Record) bool
+
+// Sample calls f(ctx, r).
+func (f SamplerFunc) Sample(ctx context.Context, r slog.Record) bool {
+	return f(ctx, r)
+}
+
+// Probability returns a Sampler keeping each record with probability p,
+// e.g. 0.01 keeps 1% of the records. Values of p at or above 1 keep every
+// record, values at or below 0 none.
+func Probability(p float64) Sampler {
+	return SamplerFunc(func(context.Context, slog.Record) bool {
+		return p >= 1 || rand.Float64() < p
+	})
+}
+
+// EveryNth returns a Sampler deterministically keeping the first record
+// and every n-th one after it. Values of n below 2 keep every record.
+func EveryNth(n uint64) Sampler {
+	var counter atomic.Uint64
+	return SamplerFunc(func(context.Context, slog.Record) bool {
+		if n < 2 {
+			return true
+		}
+		return (counter.Add(1)-1)%n == 0
+	})
+}
+
+// ByLevel returns a Sampler applying to each record the sampler registered
+// for the highest level not above the record level. Records below every
+// registered level are kept.
+func ByLevel(samplers map[slog.Level]Sampler) Sampler {
+	return SamplerFunc(func(ctx context.Context, r slog.Record) bool {
+		var (
+			best  Sampler
+			level slog.Level
+		)
+		for l, s := range samplers {
+			if l <= r.Level && (best == nil || l > level) {
+				best, level = s, l
+			}
+		}
+		return best == nil || best.Sample(ctx, r)
+	})
+}
+
+// Handler is a slog.Handler forwarding only the records kept by a Sampler.
+// Records at or above the threshold set with WithAlwaysAbove, Warn by
+// default, are never sampled.
+type Handler struct {
+	handler slog.Handler
+	sampler Sampler
+	config  handlerOptions
+	dropped *atomic.Uint64
+}
+
+// Wrap creates a sampling handler delivering the records kept by sampler
+// to handler.
+func Wrap(handler slog.Handler, sampler Sampler, options ...Option) *Handler {
+	config := handlerOptions{threshold: slog.LevelWarn}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{handler: handler, sampler: sampler, config: config, dropped: new(atomic.Uint64)}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle forwards the record if it is at or above the threshold or the
+// sampler keeps it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if (h.config.threshold == nil || r.Level < h.config.threshold.Level()) && !h.sampler.Sample(ctx, r) {
+		h.dropped.Add(1)
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// Dropped returns the number of records discarded by the sampler across all
+// handlers derived from the same Wrap call.
+func (h *Handler) Dropped() uint64 {
+	return h.dropped.Load()
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	threshold slog.Leveler
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithAlwaysAbove sets the level at and above which records bypass the
+// sampler. A nil threshold subjects every record to sampling.
+func WithAlwaysAbove(threshold slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.threshold = threshold
+	}
+}
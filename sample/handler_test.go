@@ -0,0 +1,108 @@
+package sample
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Sample(t *testing.T) {
+	t.Run("every nth", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, nil), EveryNth(3))
+		logger := slog.New(h).With("k", "v")
+
+		for i := 0; i < 7; i++ {
+			logger.Info("tick", "i", i)
+		}
+
+		out := buf.String()
+		for _, want := range []string{"i=0", "i=3", "i=6"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("expected %s, got: %s", want, out)
+			}
+		}
+		if n := strings.Count(out, "tick"); n != 3 {
+			t.Errorf("expected 3 records, got %d: %s", n, out)
+		}
+		if h.Dropped() != 4 {
+			t.Errorf("expected 4 dropped, got %d", h.Dropped())
+		}
+	})
+
+	t.Run("warn and above are never sampled", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), Probability(0)))
+
+		logger.Info("info message")
+		logger.Warn("warn message")
+		logger.Error("error message")
+
+		out := buf.String()
+		if strings.Contains(out, "info message") || !strings.Contains(out, "warn message") || !strings.Contains(out, "error message") {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("threshold can be disabled", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), Probability(0), WithAlwaysAbove(nil)))
+
+		logger.Error("error message")
+
+		if buf.Len() != 0 {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("probability", func(t *testing.T) {
+		s := Probability(0.1)
+		r := slog.NewRecord(time.Now(), slog.LevelInfo, "m", 0)
+		kept := 0
+		for i := 0; i < 10000; i++ {
+			if s.Sample(context.Background(), r) {
+				kept++
+			}
+		}
+		if kept < 700 || kept > 1300 {
+			t.Errorf("expected about 1000 kept records, got %d", kept)
+		}
+	})
+
+	t.Run("by level", func(t *testing.T) {
+		s := ByLevel(map[slog.Level]Sampler{
+			slog.LevelDebug: Probability(0),
+			slog.LevelInfo:  Probability(1),
+		})
+		for level, want := range map[slog.Level]bool{
+			slog.LevelDebug - 4: true,
+			slog.LevelDebug:     false,
+			slog.LevelInfo - 1:  false,
+			slog.LevelInfo:      true,
+			slog.LevelError:     true,
+		} {
+			r := slog.NewRecord(time.Now(), level, "m", 0)
+			if got := s.Sample(context.Background(), r); got != want {
+				t.Errorf("level %s: expected %v, got %v", level, want, got)
+			}
+		}
+	})
+
+	t.Run("custom sampler", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		keepAudit := SamplerFunc(func(_ context.Context, r slog.Record) bool {
+			return strings.HasPrefix(r.Message, "audit")
+		})
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), keepAudit))
+
+		logger.Info("audit: login")
+		logger.Info("noise")
+
+		if !strings.Contains(buf.String(), "audit: login") || strings.Contains(buf.String(), "noise") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+}
@@ -0,0 +1,314 @@
+// Package schema provides a slog.Handler wrapper validating records
+// against a declared schema of required keys, allowed kinds and maximum
+// string lengths, amending, dropping or diverting the nonconforming ones,
+// to keep the structure of logs consistent across teams.
+package schema
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// Key is the attribute listing the violations of amended and diverted
+// records.
+const Key = "log.schema_violations"
+
+// Field declares an attribute of the schema.
+type Field struct {
+	// Path is the path of the attribute, group names and key joined with
+	// dots such as "http.status".
+	Path string
+
+	// Required fields must be present in every record.
+	Required bool
+
+	// Kinds are the allowed kinds of the value, any kind if empty.
+	Kinds []slog.Kind
+
+	// MaxLen is the maximum length of string values in runes, unlimited
+	// if zero.
+	MaxLen int
+
+	// Default is the value added by Amend when a required field is
+	// missing. Missing fields without a default are only reported.
+	Default slog.Value
+}
+
+// Violation is a difference between a record and the schema.
+type Violation struct {
+	Path    string
+	Problem string
+}
+
+// String returns the violation as "path: problem".
+func (v Violation) String() string {
+	return v.Path + ": " + v.Problem
+}
+
+// Action is what happens to nonconforming records.
+type Action int
+
+const (
+	// Amend fixes the record where possible and delivers it with its
+	// violations listed under Key: missing required fields get their
+	// default, long strings are truncated, values of kinds not allowed are
+	// converted to strings if strings are allowed and removed otherwise,
+	// and undeclared attributes of strict schemas are removed.
+	Amend Action = iota
+	// Drop discards the record.
+	Drop
+	// Divert delivers the record unchanged, with its violations listed
+	// under Key, to the side channel set with WithDivert instead.
+	Divert
+)
+
+// Handler is a slog.Handler validating records against a schema before
+// delivering them to the wrapped handler. Paths are matched against the
+// attributes of the record and those added via WithAttrs, nested under
+// the groups opened via WithGroup, which are materialized into every
+// record. Values are resolved, so attributes of LogValuers are validated
+// too. The attributes of a group declared as a field are not validated.
+type Handler struct {
+	handler slog.Handler
+	scope   scope.Scope
+	fields  map[string]Field
+	order   []string // Paths of the required fields in declaration order
+	config  handlerOptions
+	count   *atomic.Uint64
+}
+
+// Wrap creates a handler validating records against fields before
+// delivering them to handler.
+func Wrap(handler slog.Handler, fields []Field, options ...Option) *Handler {
+	config := handlerOptions{action: Amend}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	h := &Handler{handler: handler, fields: make(map[string]Field, len(fields)), config: config, count: new(atomic.Uint64)}
+	for _, f := range fields {
+		h.fields[f.Path] = f
+		if f.Required {
+			h.order = append(h.order, f.Path)
+		}
+	}
+	return h
+}
+
+// Enabled reports whether the wrapped handler, or the side channel records
+// may be diverted to, handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level) ||
+		h.config.action == Divert && h.config.divert.Enabled(ctx, level)
+}
+
+// Handle validates the record and delivers it, amended, diverted or not at
+// all if it does not conform.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	attrs := h.scope.Attrs(r)
+	amended, violations := h.check(attrs)
+	if len(violations) == 0 {
+		if !h.handler.Enabled(ctx, r.Level) {
+			return nil
+		}
+		return h.handler.Handle(ctx, record(r, attrs, nil))
+	}
+	h.count.Add(1)
+	switch h.config.action {
+	case Drop:
+		return nil
+	case Divert:
+		if !h.config.divert.Enabled(ctx, r.Level) {
+			return nil
+		}
+		return h.config.divert.Handle(ctx, record(r, attrs, violations))
+	}
+	if !h.handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.handler.Handle(ctx, record(r, amended, violations))
+}
+
+func record(r slog.Record, attrs []slog.Attr, violations []Violation) slog.Record {
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(attrs...)
+	if len(violations) > 0 {
+		list := make([]string, len(violations))
+		for i, v := range violations {
+			list[i] = v.String()
+		}
+		nr.AddAttrs(slog.Any(Key, list))
+	}
+	return nr
+}
+
+// Check returns the violations of a record logged through the handler.
+func (h *Handler) Check(r slog.Record) []Violation {
+	_, violations := h.check(h.scope.Attrs(r))
+	return violations
+}
+
+// Nonconforming returns the number of records with violations across all
+// handlers derived from the same Wrap call.
+func (h *Handler) Nonconforming() uint64 {
+	return h.count.Load()
+}
+
+// check validates attrs, returning them amended and the violations found.
+func (h *Handler) check(attrs []slog.Attr) ([]slog.Attr, []Violation) {
+	var violations []Violation
+	seen := make(map[string]bool)
+	out := h.walk("", attrs, seen, &violations)
+	for _, path := range h.order {
+		if seen[path] {
+			continue
+		}
+		violations = append(violations, Violation{Path: path, Problem: "missing"})
+		if f := h.fields[path]; !f.Default.Equal(slog.Value{}) {
+			out = insert(out, strings.Split(path, "."), f.Default)
+		}
+	}
+	return out, violations
+}
+
+func (h *Handler) walk(prefix string, attrs []slog.Attr, seen map[string]bool, violations *[]Violation) []slog.Attr {
+	out := make([]slog.Attr, 0, len(attrs))
+	for _, a := range attrs {
+		path := a.Key
+		if prefix != "" {
+			path = prefix + "." + a.Key
+		}
+		v := a.Value.Resolve()
+		f, declared := h.fields[path]
+		if !declared {
+			if v.Kind() == slog.KindGroup {
+				if inner := h.walk(path, v.Group(), seen, violations); len(inner) > 0 {
+					out = append(out, slog.Attr{Key: a.Key, Value: slog.GroupValue(inner...)})
+				}
+				continue
+			}
+			if h.config.strict {
+				*violations = append(*violations, Violation{Path: path, Problem: "undeclared"})
+				continue
+			}
+			out = append(out, slog.Attr{Key: a.Key, Value: v})
+			continue
+		}
+		seen[path] = true
+		if len(f.Kinds) > 0 && !slices.Contains(f.Kinds, v.Kind()) {
+			*violations = append(*violations, Violation{Path: path, Problem: fmt.Sprintf("kind %s not allowed", v.Kind())})
+			if !slices.Contains(f.Kinds, slog.KindString) {
+				continue
+			}
+			v = slog.StringValue(v.String())
+		}
+		if f.MaxLen > 0 && v.Kind() == slog.KindString {
+			if n := utf8.RuneCountInString(v.String()); n > f.MaxLen {
+				*violations = append(*violations, Violation{Path: path, Problem: fmt.Sprintf("length %d above %d", n, f.MaxLen)})
+				v = slog.StringValue(truncate(v.String(), f.MaxLen))
+			}
+		}
+		out = append(out, slog.Attr{Key: a.Key, Value: v})
+	}
+	return out
+}
+
+// truncate returns the first n runes of s.
+func truncate(s string, n int) string {
+	i := 0
+	for range n {
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+	}
+	return s[:i]
+}
+
+// insert adds v at path, appending it to the last group of the path
+// present in attrs and creating the missing ones.
+func insert(attrs []slog.Attr, path []string, v slog.Value) []slog.Attr {
+	if len(path) == 1 {
+		return append(attrs, slog.Attr{Key: path[0], Value: v})
+	}
+	for i := len(attrs) - 1; i >= 0; i-- {
+		if attrs[i].Key != path[0] {
+			continue
+		}
+		gv := attrs[i].Value.Resolve()
+		if gv.Kind() != slog.KindGroup {
+			break
+		}
+		out := slices.Clone(attrs)
+		out[i] = slog.Attr{Key: path[0], Value: slog.GroupValue(insert(gv.Group(), path[1:], v)...)}
+		return out
+	}
+	return append(attrs, slog.Attr{Key: path[0], Value: slog.GroupValue(insert(nil, path[1:], v)...)})
+}
+
+// WithAttrs returns a new Handler with the attributes added to its scope.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler with the group opened in its scope.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	action Action
+	divert slog.Handler
+	strict bool
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithAction sets what happens to nonconforming records, Amend by
+// default. Divert is set with WithDivert.
+func WithAction(a Action) Option {
+	return func(h *handlerOptions) {
+		switch a {
+		case Amend, Drop:
+			h.action = a
+		default:
+			panic("slogging: unsupported schema action")
+		}
+	}
+}
+
+// WithDivert diverts nonconforming records to handler, such as a separate
+// file or topic reviewed by the owners of the schema.
+func WithDivert(handler slog.Handler) Option {
+	return func(h *handlerOptions) {
+		h.action = Divert
+		h.divert = handler
+	}
+}
+
+// WithStrict reports attributes not declared in the schema as violations.
+func WithStrict(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.strict = true
+		for i := range x {
+			h.strict = x[i]
+		}
+	}
+}
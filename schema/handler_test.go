@@ -0,0 +1,100 @@
+package schema
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
+
+var fields = []Field{
+	{Path: "service", Required: true, Kinds: []slog.Kind{slog.KindString}, Default: slog.StringValue("unknown")},
+	{Path: "http.status", Kinds: []slog.Kind{slog.KindInt64}},
+	{Path: "user.id", Required: true, Kinds: []slog.Kind{slog.KindInt64, slog.KindString}},
+	{Path: "note", MaxLen: 5},
+}
+
+func Test_Handler(t *testing.T) {
+	for name, tc := range map[string]struct {
+		options []Option
+		args    []any
+		want    string
+	}{
+		"conforming": {
+			args: []any{"service", "api", slog.Group("user", "id", 7), "note", "short", "extra", true},
+			want: `msg=m service=api user.id=7 note=short extra=true`,
+		},
+		"amend missing, kind and length": {
+			args: []any{slog.Group("user", "id", 7.5), slog.Group("http", "status", "200"), "note", "too long"},
+			want: `msg=m user.id=7.5 note="too l" service=unknown log.schema_violations="[user.id: kind Float64 not allowed http.status: kind String not allowed note: length 8 above 5 service: missing]"`,
+		},
+		"strict removes undeclared": {
+			options: []Option{WithStrict()},
+			args:    []any{"service", "api", slog.Group("user", "id", 7), "extra", true},
+			want:    `msg=m service=api user.id=7 log.schema_violations="[extra: undeclared]"`,
+		},
+		"drop": {
+			options: []Option{WithAction(Drop)},
+			args:    []any{"service", "api"},
+			want:    ``,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := new(bytes.Buffer)
+			h := Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}), fields, tc.options...)
+			slog.New(h).Info("m", tc.args...)
+			want := tc.want
+			if want != "" {
+				want = "level=INFO " + want
+			}
+			if got := strings.TrimSpace(buf.String()); got != want {
+				t.Errorf("\nwant %s\ngot  %s", want, got)
+			}
+		})
+	}
+
+	t.Run("divert", func(t *testing.T) {
+		var main, side bytes.Buffer
+		h := Wrap(slog.NewTextHandler(&main, &slog.HandlerOptions{ReplaceAttr: dropTime}), fields,
+			WithDivert(slog.NewTextHandler(&side, &slog.HandlerOptions{ReplaceAttr: dropTime})))
+		logger := slog.New(h)
+		logger.Info("ok", "service", "api", "user", slog.GroupValue(slog.Int("id", 1)))
+		logger.Info("bad", "note", "too long")
+		if !strings.Contains(main.String(), "msg=ok") || strings.Contains(main.String(), "msg=bad") {
+			t.Errorf("main got %q", main.String())
+		}
+		want := `level=INFO msg=bad note="too long" log.schema_violations="[note: length 8 above 5 service: missing user.id: missing]"`
+		if got := strings.TrimSpace(side.String()); got != want {
+			t.Errorf("\nwant %s\ngot  %s", want, got)
+		}
+		if n := h.Nonconforming(); n != 1 {
+			t.Errorf("Nonconforming() = %d, want 1", n)
+		}
+	})
+
+	t.Run("attributes of derived handlers are validated", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		h := Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}), fields)
+		slog.New(h).With("service", "api").WithGroup("user").With("id", 7).Info("m")
+		want := `level=INFO msg=m service=api user.id=7`
+		if got := strings.TrimSpace(buf.String()); got != want {
+			t.Errorf("\nwant %s\ngot  %s", want, got)
+		}
+	})
+
+	t.Run("unsupported action", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("WithAction(Divert) did not panic")
+			}
+		}()
+		WithAction(Divert)(&handlerOptions{})
+	})
+}
@@ -0,0 +1,139 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// avroReader decodes the primitives used by RecordSchema.
+type avroReader struct {
+	buf []byte
+}
+
+func (r *avroReader) long() int64 {
+	v, n := binary.Varint(r.buf)
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *avroReader) string() string {
+	n := r.long()
+	s := string(r.buf[:n])
+	r.buf = r.buf[n:]
+	return s
+}
+
+func Test_AvroSerializer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("records are encoded in wire format", func(t *testing.T) {
+		srv := httptest.NewServer(&fakeRegistry{schemas: map[string]int{"other": 1}, compatible: true, hasLatest: true})
+		defer srv.Close()
+
+		s, err := NewAvroSerializer(ctx, NewClient(srv.URL), "logs-value")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if s.ID() != 2 {
+			t.Errorf("unexpected schema id %d", s.ID())
+		}
+
+		now := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+		r := slog.NewRecord(now, slog.LevelWarn, "disk full", 0)
+		r.AddAttrs(slog.Int("pct", 99), slog.Group("host", slog.String("name", "db1")))
+
+		data, err := s.Serialize(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if data[0] != 0 || binary.BigEndian.Uint32(data[1:5]) != 2 {
+			t.Fatalf("unexpected header: %v", data[:5])
+		}
+		rd := &avroReader{buf: data[5:]}
+		if got := rd.long(); got != now.UnixMicro() {
+			t.Errorf("unexpected time %d", got)
+		}
+		if got := rd.string(); got != "WARN" {
+			t.Errorf("unexpected level %q", got)
+		}
+		if got := rd.string(); got != "disk full" {
+			t.Errorf("unexpected message %q", got)
+		}
+		if n := rd.long(); n != 2 {
+			t.Fatalf("unexpected map block size %d", n)
+		}
+		attrs := map[string]string{}
+		for i := 0; i < 2; i++ {
+			k := rd.string()
+			attrs[k] = rd.string()
+		}
+		if attrs["pct"] != "99" || attrs["host.name"] != "db1" {
+			t.Errorf("unexpected attributes %v", attrs)
+		}
+		if rd.long() != 0 || len(rd.buf) != 0 {
+			t.Errorf("unexpected trailing data")
+		}
+	})
+
+	t.Run("incompatible schema fails fast", func(t *testing.T) {
+		srv := httptest.NewServer(&fakeRegistry{schemas: map[string]int{}, hasLatest: true})
+		defer srv.Close()
+
+		_, err := NewAvroSerializer(ctx, NewClient(srv.URL), "logs-value")
+		if !errors.Is(err, ErrIncompatible) {
+			t.Errorf("expected ErrIncompatible, got %v", err)
+		}
+	})
+
+	t.Run("protobuf records are encoded in wire format", func(t *testing.T) {
+		srv := httptest.NewServer(&fakeRegistry{schemas: map[string]int{}, compatible: true})
+		defer srv.Close()
+
+		s, err := NewProtobufSerializer(ctx, NewClient(srv.URL), "logs-value")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		now := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+		r := slog.NewRecord(now, slog.LevelWarn, "disk full", 0)
+		r.AddAttrs(slog.Int("pct", 99), slog.Group("host", slog.String("name", "db1")))
+
+		data, err := s.Serialize(r)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if data[0] != 0 || binary.BigEndian.Uint32(data[1:5]) != uint32(s.ID()) || data[5] != 0 {
+			t.Fatalf("unexpected header: %v", data[:6])
+		}
+		fields := map[protowire.Number][]any{}
+		for b := data[6:]; len(b) > 0; {
+			num, typ, n := protowire.ConsumeTag(b)
+			b = b[n:]
+			switch typ {
+			case protowire.VarintType:
+				v, n := protowire.ConsumeVarint(b)
+				fields[num] = append(fields[num], int64(v))
+				b = b[n:]
+			case protowire.BytesType:
+				v, n := protowire.ConsumeBytes(b)
+				fields[num] = append(fields[num], string(v))
+				b = b[n:]
+			default:
+				t.Fatalf("unexpected wire type %d", typ)
+			}
+		}
+		if fields[1][0] != now.UnixMicro() || fields[2][0] != "WARN" || fields[3][0] != "disk full" || len(fields[4]) != 2 {
+			t.Errorf("unexpected fields: %v", fields)
+		}
+		// Map entries are messages of a key and a value.
+		if entry := fields[4][0].(string); entry != "\n\x09host.name\x12\x03db1" {
+			t.Errorf("unexpected first entry %q", entry)
+		}
+	})
+}
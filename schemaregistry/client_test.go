@@ -0,0 +1,97 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeRegistry implements the subset of the Schema Registry API used by Client.
+type fakeRegistry struct {
+	schemas    map[string]int
+	compatible bool
+	hasLatest  bool
+	username   string
+}
+
+func (f *fakeRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if u, _, _ := r.BasicAuth(); u != f.username {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	var req schemaRequest
+	_ = json.NewDecoder(r.Body).Decode(&req)
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/compatibility/"):
+		if !f.hasLatest {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40401,"message":"Subject not found"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]bool{"is_compatible": f.compatible})
+	case strings.HasSuffix(r.URL.Path, "/versions"):
+		if !f.compatible && f.hasLatest {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error_code":409,"message":"Schema being registered is incompatible"}`))
+			return
+		}
+		id, ok := f.schemas[req.Schema]
+		if !ok {
+			id = len(f.schemas) + 1
+			f.schemas[req.Schema] = id
+		}
+		_ = json.NewEncoder(w).Encode(map[string]int{"id": id})
+	default:
+		id, ok := f.schemas[req.Schema]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]int{"id": id})
+	}
+}
+
+func Test_Client(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("register and lookup", func(t *testing.T) {
+		srv := httptest.NewServer(&fakeRegistry{schemas: map[string]int{}, compatible: true, username: "u"})
+		defer srv.Close()
+		c := NewClient(srv.URL+"/", WithBasicAuth("u", "p"))
+
+		if _, err := c.Lookup(ctx, "logs-value", RecordSchema, Avro); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected ErrNotFound, got %v", err)
+		}
+		id, err := c.Register(ctx, "logs-value", RecordSchema, Avro)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		got, err := c.Lookup(ctx, "logs-value", RecordSchema, Avro)
+		if err != nil || got != id {
+			t.Errorf("expected id %d, got %d (%v)", id, got, err)
+		}
+	})
+
+	t.Run("subject without versions is compatible", func(t *testing.T) {
+		srv := httptest.NewServer(&fakeRegistry{schemas: map[string]int{}})
+		defer srv.Close()
+		ok, err := NewClient(srv.URL).Compatible(ctx, "new-subject", RecordSchema, Avro)
+		if err != nil || !ok {
+			t.Errorf("expected compatible, got %v (%v)", ok, err)
+		}
+	})
+
+	t.Run("incompatible registration is reported", func(t *testing.T) {
+		srv := httptest.NewServer(&fakeRegistry{schemas: map[string]int{}, hasLatest: true})
+		defer srv.Close()
+		_, err := NewClient(srv.URL).Register(ctx, "logs-value", RecordSchema, Avro)
+		if !errors.Is(err, ErrIncompatible) {
+			t.Errorf("expected ErrIncompatible, got %v", err)
+		}
+	})
+}
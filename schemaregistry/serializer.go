@@ -0,0 +1,71 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+// magicByte prefixes every message in the Confluent wire format.
+const magicByte = 0
+
+// register checks schema against the latest version registered under
+// subject and registers it, returning its ID.
+func register(ctx context.Context, client *Client, subject, schema string, typ SchemaType) (int, error) {
+	ok, err := client.Compatible(ctx, subject, schema, typ)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, fmt.Errorf("subject %q: %w", subject, ErrIncompatible)
+	}
+	return client.Register(ctx, subject, schema, typ)
+}
+
+// header returns the wire format header of messages encoded with the
+// schema of the given ID.
+func header(id int) []byte {
+	buf := make([]byte, 5, 128)
+	buf[0] = magicByte
+	binary.BigEndian.PutUint32(buf[1:], uint32(id))
+	return buf
+}
+
+// attributes returns the attributes of r flattened to dotted keys, with
+// their values rendered as strings, sorted by key.
+func attributes(r slog.Record) [][2]string {
+	m := make(map[string]string, r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		flatten(m, "", a)
+		return true
+	})
+	kvs := make([][2]string, 0, len(m))
+	for k, v := range m {
+		kvs = append(kvs, [2]string{k, v})
+	}
+	sort.Slice(kvs, func(i, j int) bool { return kvs[i][0] < kvs[j][0] })
+	return kvs
+}
+
+func flatten(out map[string]string, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	key := a.Key
+	if prefix != "" && key != "" {
+		key = prefix + "." + key
+	} else if key == "" {
+		key = prefix
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			flatten(out, key, ga)
+		}
+		return
+	}
+	out[strings.TrimPrefix(key, ".")] = a.Value.String()
+}
@@ -0,0 +1,200 @@
+package sentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// Event is the payload of a Sentry event, in the format of the event
+// ingestion API.
+type Event struct {
+	EventID     string              `json:"event_id"`
+	Timestamp   time.Time           `json:"timestamp"`
+	Level       string              `json:"level"`
+	Platform    string              `json:"platform"`
+	Logger      string              `json:"logger,omitempty"`
+	ServerName  string              `json:"server_name,omitempty"`
+	Environment string              `json:"environment,omitempty"`
+	Release     string              `json:"release,omitempty"`
+	Message     *Message            `json:"message,omitempty"`
+	Tags        map[string]string   `json:"tags,omitempty"`
+	Extra       map[string]any      `json:"extra,omitempty"`
+	Fingerprint []string            `json:"fingerprint,omitempty"`
+	Contexts    map[string]any      `json:"contexts,omitempty"`
+	Exception   *Values[Exception]  `json:"exception,omitempty"`
+	Threads     *Values[Thread]     `json:"threads,omitempty"`
+	Breadcrumbs *Values[Breadcrumb] `json:"breadcrumbs,omitempty"`
+}
+
+// Values wraps the list interfaces of events.
+type Values[T any] struct {
+	Values []T `json:"values"`
+}
+
+// Message is the message interface of events.
+type Message struct {
+	Formatted string `json:"formatted"`
+}
+
+// Exception is an error of the exception interface of events.
+type Exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *Stacktrace `json:"stacktrace,omitempty"`
+}
+
+// Thread carries the stack trace of the logging call site of events
+// without an error stack trace.
+type Thread struct {
+	Current    bool        `json:"current"`
+	Stacktrace *Stacktrace `json:"stacktrace"`
+}
+
+// Stacktrace holds frames, oldest first.
+type Stacktrace struct {
+	Frames []Frame `json:"frames"`
+}
+
+// Frame is a frame of a stack trace.
+type Frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// Breadcrumb is a record logged before an event.
+type Breadcrumb struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Level     string         `json:"level"`
+	Category  string         `json:"category"`
+	Message   string         `json:"message"`
+	Data      map[string]any `json:"data,omitempty"`
+}
+
+// stacktrace converts program counters, innermost first, into a stack
+// trace.
+func stacktrace(pcs []uintptr) *Stacktrace {
+	if len(pcs) == 0 {
+		return nil
+	}
+	var frames []Frame
+	it := runtime.CallersFrames(pcs)
+	for {
+		f, more := it.Next()
+		module, function := splitFunction(f.Function)
+		frames = append(frames, Frame{
+			Function: function,
+			Module:   module,
+			Filename: path.Base(f.File),
+			AbsPath:  f.File,
+			Lineno:   f.Line,
+			InApp:    inApp(module),
+		})
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return &Stacktrace{Frames: frames}
+}
+
+// splitFunction splits a qualified function name such as
+// "example.com/pkg.(*T).Method" into its package and function.
+func splitFunction(name string) (string, string) {
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:slash+1+dot], name[slash+2+dot:]
+}
+
+// inApp reports whether a package belongs to the application rather than
+// to the standard library.
+func inApp(module string) bool {
+	first, _, _ := strings.Cut(module, "/")
+	return strings.Contains(first, ".") || module == "main"
+}
+
+// dsn holds the parts of a DSN needed to send events.
+type dsn struct {
+	raw      string
+	endpoint string
+	auth     string
+}
+
+func parseDSN(raw string) (dsn, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return dsn{}, fmt.Errorf("error when parsing DSN: %w", err)
+	}
+	project := path.Base(u.Path)
+	if u.User == nil || u.User.Username() == "" || project == "" || project == "/" || project == "." {
+		return dsn{}, errors.New("slogging: invalid sentry DSN")
+	}
+	prefix := strings.TrimSuffix(path.Dir(u.Path), "/")
+	return dsn{
+		raw:      raw,
+		endpoint: fmt.Sprintf("%s://%s%s/api/%s/envelope/", u.Scheme, u.Host, prefix, project),
+		auth:     "Sentry sentry_version=7, sentry_client=slogging/1, sentry_key=" + u.User.Username(),
+	}, nil
+}
+
+// envelope encodes the event as an envelope of one item.
+func envelope(d dsn, e *Event) ([]byte, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, fmt.Errorf("error when marshaling Sentry event: %w", err)
+	}
+	header, _ := json.Marshal(map[string]any{"event_id": e.EventID, "dsn": d.raw, "sent_at": time.Now().UTC()})
+	item, _ := json.Marshal(map[string]any{"type": "event", "length": len(payload)})
+	var buf bytes.Buffer
+	buf.Write(header)
+	buf.WriteByte('\n')
+	buf.Write(item)
+	buf.WriteByte('\n')
+	buf.Write(payload)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// send posts the event and reports whether a failure is worth retrying.
+func (h *Handler) send(ctx context.Context, e *Event) (bool, error) {
+	body, err := envelope(h.state.dsn, e)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.state.dsn.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("error when creating Sentry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-sentry-envelope")
+	req.Header.Set("X-Sentry-Auth", h.state.dsn.auth)
+	resp, err := h.state.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("error when sending to Sentry: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return false, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("error when sending to Sentry: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
@@ -0,0 +1,657 @@
+// Package sentry provides a slog.Handler wrapper reporting records at or
+// above a level to Sentry as events, with the lower-level records logged
+// before them as breadcrumbs.
+package sentry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/errattr"
+	"github.com/mikluko/slogging/internal/jsonvalue"
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/internal/stats"
+	"github.com/mikluko/slogging/severity"
+)
+
+const (
+	defaultQueueSize      = 100
+	defaultMaxBreadcrumbs = 100
+	defaultTTL            = 5 * time.Minute
+	defaultMaxRetries     = 3
+	defaultMinBackoff     = 500 * time.Millisecond
+	defaultMaxBackoff     = 10 * time.Second
+	maxStackDepth         = 64
+)
+
+// KeyFunc extracts the key breadcrumbs are collected under, such as a
+// trace or request ID. An empty key means the record is not kept as a
+// breadcrumb.
+type KeyFunc func(ctx context.Context) string
+
+// TraceKey keys breadcrumbs by the trace ID of the span in the logging
+// context.
+func TraceKey(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// FingerprintFunc returns the fingerprint grouping the event of a record
+// into an issue, or nil to leave the decision to the next function or to
+// Sentry.
+type FingerprintFunc func(r slog.Record) []string
+
+// ByKeys returns a FingerprintFunc grouping the events of records carrying
+// every given top-level attribute by their message and the values of the
+// attributes.
+func ByKeys(keys ...string) FingerprintFunc {
+	return func(r slog.Record) []string {
+		values := make(map[string]string, len(keys))
+		r.Attrs(func(a slog.Attr) bool {
+			values[a.Key] = a.Value.Resolve().String()
+			return true
+		})
+		fp := []string{r.Message}
+		for _, k := range keys {
+			v, ok := values[k]
+			if !ok {
+				return nil
+			}
+			fp = append(fp, v)
+		}
+		return fp
+	}
+}
+
+type bucket struct {
+	crumbs  []Breadcrumb
+	expires time.Time
+}
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	dsn    dsn
+	client *http.Client
+	config handlerOptions
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	nextSweep time.Time
+	queue     chan *Event
+	pending   int
+	idle      *sync.Cond
+	dropped   uint64
+	closed    bool
+	done      chan struct{}
+	now       func() time.Time
+	stats     stats.Recorder
+}
+
+// Handler is a slog.Handler that delivers records to the wrapped handler
+// and reports records at or above the event level, slog.LevelWarn by
+// default, to Sentry as events. The message and level of the record,
+// mapped with the Sentry sink of the severity table, become those of the
+// event. The first top-level attribute holding an error becomes the
+// exception of the event, with the wrapped errors and the stack trace of
+// errors implementing errattr.StackTracer; events without one carry the
+// stack trace of the logging call site. Top-level attributes selected
+// with WithTags become tags, the others extra data. The trace and span
+// IDs of the logging context fill the trace context of the event, linking
+// it to the trace as recorded by the otel handler.
+//
+// Records below the event level, down to the breadcrumb level, are kept
+// as breadcrumbs of the key of their context, the trace ID by default,
+// and attached to the events logged later with the same key.
+//
+// Events are sent from a background goroutine; Close must be called to
+// send the queued events and stop it.
+type Handler struct {
+	handler slog.Handler
+	scope   scope.Scope
+	state   *state
+}
+
+// Wrap creates a handler delivering records to handler and reporting
+// events to the project of the given DSN.
+func Wrap(handler slog.Handler, dsn string, options ...Option) (*Handler, error) {
+	hostname, _ := os.Hostname()
+	config := handlerOptions{
+		client:          http.DefaultClient,
+		eventLevel:      slog.LevelWarn,
+		breadcrumbLevel: slog.LevelInfo,
+		key:             TraceKey,
+		maxBreadcrumbs:  defaultMaxBreadcrumbs,
+		ttl:             defaultTTL,
+		serverName:      hostname,
+		queueSize:       defaultQueueSize,
+		maxRetries:      defaultMaxRetries,
+		minBackoff:      defaultMinBackoff,
+		maxBackoff:      defaultMaxBackoff,
+		tags:            map[string]bool{},
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	if config.table == nil {
+		config.table = severity.Default()
+	}
+	d, err := parseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	s := &state{
+		dsn:     d,
+		client:  config.client,
+		config:  config,
+		buckets: make(map[string]*bucket),
+		queue:   make(chan *Event, config.queueSize),
+		done:    make(chan struct{}),
+		now:     time.Now,
+	}
+	s.idle = sync.NewCond(&s.mutex)
+	h := &Handler{handler: handler, state: s}
+	go h.run()
+	return h, nil
+}
+
+// Enabled reports whether the wrapped handler handles records at the given
+// level, or whether they are reported as events or breadcrumbs.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= h.state.config.breadcrumbLevel.Level() || h.handler.Enabled(ctx, level)
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// Handle reports the record as an event or keeps it as a breadcrumb, then
+// delivers it to the wrapped handler.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	config := h.state.config
+	switch {
+	case r.Level >= config.eventLevel.Level():
+		h.enqueue(h.event(ctx, r))
+	case r.Level >= config.breadcrumbLevel.Level():
+		if key := config.key(ctx); key != "" {
+			h.breadcrumb(key, r)
+		}
+	}
+	if !h.handler.Enabled(ctx, r.Level) {
+		return nil
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// attrs returns the attributes of the record and of WithAttrs calls,
+// nested under the groups of WithGroup calls.
+func (h *Handler) attrs(r slog.Record) map[string]any {
+	top := make(map[string]any, r.NumAttrs())
+	slogging.PutAttrs(top, h.scope.Attrs(r)...)
+	return top
+}
+
+func (h *Handler) breadcrumb(key string, r slog.Record) {
+	data := h.attrs(r)
+	jsonvalue.Map(data)
+	crumb := Breadcrumb{
+		Timestamp: r.Time,
+		Level:     h.state.config.table.Lookup(severity.Sentry, r.Level).Name,
+		Category:  "log",
+		Message:   r.Message,
+		Data:      data,
+	}
+	s := h.state
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := s.now()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{}
+		s.buckets[key] = b
+	}
+	b.crumbs = append(b.crumbs, crumb)
+	if over := len(b.crumbs) - s.config.maxBreadcrumbs; over > 0 {
+		b.crumbs = append(b.crumbs[:0], b.crumbs[over:]...)
+	}
+	b.expires = now.Add(s.config.ttl)
+}
+
+// sweep discards expired buckets. Must be called with the mutex held.
+func (s *state) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	for k, b := range s.buckets {
+		if !now.Before(b.expires) {
+			delete(s.buckets, k)
+		}
+	}
+	s.nextSweep = now.Add(s.config.ttl)
+}
+
+// event converts the record into an event.
+func (h *Handler) event(ctx context.Context, r slog.Record) *Event {
+	config := h.state.config
+	e := &Event{
+		EventID:     eventID(),
+		Timestamp:   r.Time,
+		Level:       config.table.Lookup(severity.Sentry, r.Level).Name,
+		Platform:    "go",
+		Logger:      config.logger,
+		ServerName:  config.serverName,
+		Environment: config.environment,
+		Release:     config.release,
+		Message:     &Message{Formatted: r.Message},
+	}
+	if e.Timestamp.IsZero() {
+		e.Timestamp = h.state.now()
+	}
+
+	extra := h.attrs(r)
+	var err error
+	for k, v := range extra {
+		if config.tags[k] {
+			if e.Tags == nil {
+				e.Tags = make(map[string]string)
+			}
+			e.Tags[k] = fmt.Sprint(v)
+			delete(extra, k)
+		}
+	}
+	if key, v := h.topError(r); v != nil {
+		err = v
+		delete(extra, key)
+	}
+	if len(extra) > 0 {
+		jsonvalue.Map(extra)
+		e.Extra = extra
+	}
+
+	if err != nil {
+		e.Exception = &Values[Exception]{Values: exceptions(err)}
+	} else if r.PC != 0 {
+		e.Threads = &Values[Thread]{Values: []Thread{{Current: true, Stacktrace: stacktrace(callers(r.PC))}}}
+	}
+
+	for _, fn := range config.fingerprints {
+		if fp := fn(r); fp != nil {
+			e.Fingerprint = fp
+			break
+		}
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		e.Contexts = map[string]any{"trace": map[string]string{
+			"trace_id": sc.TraceID().String(),
+			"span_id":  sc.SpanID().String(),
+		}}
+	}
+	if key := config.key(ctx); key != "" {
+		s := h.state
+		s.mutex.Lock()
+		if b, ok := s.buckets[key]; ok && len(b.crumbs) > 0 {
+			e.Breadcrumbs = &Values[Breadcrumb]{Values: append([]Breadcrumb(nil), b.crumbs...)}
+		}
+		s.mutex.Unlock()
+	}
+	return e
+}
+
+// topError returns the first top-level attribute holding an error, looking
+// at the attributes of the record first.
+func (h *Handler) topError(r slog.Record) (key string, err error) {
+	if len(h.scope.Groups()) == 0 {
+		r.Attrs(func(a slog.Attr) bool {
+			key, err = attrError(a)
+			return err == nil
+		})
+		if err != nil {
+			return key, err
+		}
+	}
+	for _, a := range h.scope.Nest(nil) {
+		if key, err = attrError(a); err != nil {
+			return key, err
+		}
+	}
+	return "", nil
+}
+
+func attrError(a slog.Attr) (string, error) {
+	if err, ok := a.Value.Resolve().Any().(error); ok && err != nil {
+		return a.Key, err
+	}
+	return "", nil
+}
+
+// exceptions returns err and the errors it wraps, innermost first as
+// expected by Sentry, with the stack trace of the innermost error
+// implementing errattr.StackTracer on that error.
+func exceptions(err error) []Exception {
+	var chain []error
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		chain = append(chain, e)
+	}
+	out := make([]Exception, len(chain))
+	traced := false
+	for i := len(chain) - 1; i >= 0; i-- {
+		e := chain[i]
+		x := Exception{Type: fmt.Sprintf("%T", e), Value: e.Error()}
+		if st, ok := e.(errattr.StackTracer); ok && !traced {
+			x.Stacktrace = stacktrace(st.StackTrace())
+			traced = true
+		}
+		out[len(chain)-1-i] = x
+	}
+	return out
+}
+
+// callers returns the stack of the calling goroutine from the logging call
+// site given by pc, omitting the frames of the logger and handler chain.
+func callers(pc uintptr) []uintptr {
+	pcs := make([]uintptr, maxStackDepth+64)
+	pcs = pcs[:runtime.Callers(3, pcs)]
+	for i := range pcs {
+		if pcs[i] == pc {
+			pcs = pcs[i:]
+			break
+		}
+	}
+	return pcs[:min(len(pcs), maxStackDepth)]
+}
+
+func eventID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func (h *Handler) enqueue(e *Event) {
+	s := h.state
+	s.stats.Handled(1)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		s.dropped++
+		return
+	}
+	select {
+	case s.queue <- e:
+		s.pending++
+	default:
+		s.dropped++
+	}
+}
+
+func (h *Handler) run() {
+	s := h.state
+	defer close(s.done)
+	for e := range s.queue {
+		err := h.deliver(e)
+		s.stats.Error(err)
+		if err != nil && s.config.onError != nil {
+			s.config.onError(err)
+		}
+		s.mutex.Lock()
+		if err != nil {
+			s.dropped++
+		}
+		s.pending--
+		if s.pending == 0 {
+			s.idle.Broadcast()
+		}
+		s.mutex.Unlock()
+	}
+}
+
+// deliver sends the event, retrying with backoff on network errors, 429
+// and 5xx responses.
+func (h *Handler) deliver(e *Event) error {
+	config := h.state.config
+	backoff := config.minBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := h.send(context.Background(), e)
+		if err == nil || !retry || attempt >= config.maxRetries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff = min(2*backoff, config.maxBackoff)
+	}
+}
+
+// Flush blocks until the queued events have been sent, or ctx is done.
+func (h *Handler) Flush(ctx context.Context) error {
+	s := h.state
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		for s.pending > 0 && ctx.Err() == nil {
+			s.idle.Wait()
+		}
+	}()
+	select {
+	case <-done:
+		return ctx.Err()
+	case <-ctx.Done():
+		s.mutex.Lock()
+		s.idle.Broadcast()
+		s.mutex.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// Close stops reporting events, sends the queued ones and stops the
+// delivery goroutine, or gives up when ctx is done. It does not close the
+// wrapped handler.
+func (h *Handler) Close(ctx context.Context) error {
+	s := h.state
+	s.mutex.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mutex.Unlock()
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full,
+// the handler was closed or sending failed.
+func (h *Handler) Dropped() uint64 {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	return h.state.dropped
+}
+
+// Stats returns the counters of the handler. Handled counts the events
+// queued for sending rather than the records passed to the handler, and
+// the queue depth is the number of events not sent yet.
+func (h *Handler) Stats() slogging.Stats {
+	s := h.state
+	s.mutex.Lock()
+	dropped, depth := s.dropped, s.pending
+	s.mutex.Unlock()
+	return s.stats.Stats(dropped, depth)
+}
+
+type handlerOptions struct {
+	client          *http.Client
+	table           *severity.Table
+	eventLevel      slog.Leveler
+	breadcrumbLevel slog.Leveler
+	key             KeyFunc
+	maxBreadcrumbs  int
+	ttl             time.Duration
+	tags            map[string]bool
+	fingerprints    []FingerprintFunc
+	logger          string
+	serverName      string
+	environment     string
+	release         string
+	queueSize       int
+	maxRetries      int
+	minBackoff      time.Duration
+	maxBackoff      time.Duration
+	onError         func(error)
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithEventLevel sets the level at which records are reported as events.
+// Defaults to slog.LevelWarn.
+func WithEventLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.eventLevel = lvl
+	}
+}
+
+// WithBreadcrumbLevel sets the level at which records are kept as
+// breadcrumbs. Defaults to slog.LevelInfo.
+func WithBreadcrumbLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.breadcrumbLevel = lvl
+	}
+}
+
+// WithKey sets the function extracting the breadcrumb key from the
+// logging context.
+func WithKey(fn KeyFunc) Option {
+	return func(h *handlerOptions) {
+		h.key = fn
+	}
+}
+
+// WithBreadcrumbs sets the number of breadcrumbs kept per key, 100 by
+// default, and how long they are kept without new records, five minutes
+// by default.
+func WithBreadcrumbs(n int, ttl time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.maxBreadcrumbs = max(n, 1)
+		h.ttl = ttl
+	}
+}
+
+// WithTags sets the top-level attributes reported as tags instead of
+// extra data, e.g. "tenant" or "region".
+func WithTags(keys ...string) Option {
+	return func(h *handlerOptions) {
+		for _, k := range keys {
+			h.tags[k] = true
+		}
+	}
+}
+
+// WithFingerprint sets functions computing the fingerprint of events. The
+// first function returning a fingerprint wins.
+func WithFingerprint(fns ...FingerprintFunc) Option {
+	return func(h *handlerOptions) {
+		h.fingerprints = append(h.fingerprints, fns...)
+	}
+}
+
+// WithSeverityTable sets the table mapping levels to Sentry levels.
+// Defaults to severity.Default().
+func WithSeverityTable(t *severity.Table) Option {
+	return func(h *handlerOptions) {
+		h.table = t
+	}
+}
+
+// WithRelease sets the environment and release of events.
+func WithRelease(environment, release string) Option {
+	return func(h *handlerOptions) {
+		h.environment = environment
+		h.release = release
+	}
+}
+
+// WithLogger sets the logger name of events.
+func WithLogger(name string) Option {
+	return func(h *handlerOptions) {
+		h.logger = name
+	}
+}
+
+// WithServerName sets the server name of events, the hostname by default.
+func WithServerName(name string) Option {
+	return func(h *handlerOptions) {
+		h.serverName = name
+	}
+}
+
+// WithHTTPClient sets the client sending events, http.DefaultClient by
+// default.
+func WithHTTPClient(c *http.Client) Option {
+	return func(h *handlerOptions) {
+		h.client = c
+	}
+}
+
+// WithQueueSize sets the number of events waiting to be sent, 100 by
+// default. Events are dropped while the queue is full.
+func WithQueueSize(n int) Option {
+	return func(h *handlerOptions) {
+		h.queueSize = max(n, 1)
+	}
+}
+
+// WithRetry sets the number of retries of a failed send, 3 by default, and
+// the bounds of the exponential backoff between them, 500ms and 10s by
+// default.
+func WithRetry(maxRetries int, minBackoff, maxBackoff time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.maxRetries = maxRetries
+		h.minBackoff = minBackoff
+		h.maxBackoff = maxBackoff
+	}
+}
+
+// WithErrorHandler sets a function called with send errors, after retries
+// are exhausted. It is called from the delivery goroutine.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
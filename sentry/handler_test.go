@@ -0,0 +1,202 @@
+package sentry
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type collector struct {
+	mutex  sync.Mutex
+	auth   string
+	path   string
+	events []map[string]any
+	fail   int // Number of requests answered with 503
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.fail > 0 {
+		c.fail--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	c.auth, c.path = r.Header.Get("X-Sentry-Auth"), r.URL.Path
+	sc := bufio.NewScanner(r.Body)
+	sc.Buffer(nil, 1<<20)
+	var lines []string
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	var event map[string]any
+	if len(lines) != 3 || json.Unmarshal([]byte(lines[2]), &event) != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.events = append(c.events, event)
+}
+
+type tracedError struct {
+	pcs []uintptr
+}
+
+func (e *tracedError) Error() string         { return "disk full" }
+func (e *tracedError) StackTrace() []uintptr { return e.pcs }
+
+type stringer string
+
+func (s stringer) String() string { return "stringer " + string(s) }
+
+func newTracedError() error {
+	pcs := make([]uintptr, 16)
+	return &tracedError{pcs: pcs[:runtime.Callers(1, pcs)]}
+}
+
+func setup(t *testing.T, options ...Option) (*collector, *Handler, *bytes.Buffer) {
+	t.Helper()
+	c := &collector{}
+	srv := httptest.NewServer(c)
+	t.Cleanup(srv.Close)
+	buf := new(bytes.Buffer)
+	dsn := strings.Replace(srv.URL, "http://", "http://public@", 1) + "/42"
+	h, err := Wrap(slog.NewTextHandler(buf, nil), dsn, append([]Option{WithServerName("host")}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, h, buf
+}
+
+func Test_Handler(t *testing.T) {
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1},
+		SpanID:     trace.SpanID{2},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+
+	t.Run("errors become events with breadcrumbs", func(t *testing.T) {
+		c, h, buf := setup(t, WithTags("tenant"), WithRelease("prod", "1.2.3"), WithFingerprint(ByKeys("missing"), ByKeys("op")))
+		logger := slog.New(h).With("tenant", "acme")
+		logger.DebugContext(ctx, "ignored")
+		logger.InfoContext(ctx, "loading", "file", "a.txt")
+		logger.ErrorContext(ctx, "save failed", "op", "save", "error", fmt.Errorf("saving: %w", newTracedError()), slog.Group("g", "n", 1))
+		logger.Info("other trace")
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "save failed") || !strings.Contains(buf.String(), "other trace") {
+			t.Errorf("records not delivered to the wrapped handler: %s", buf.String())
+		}
+		if len(c.events) != 1 {
+			t.Fatalf("expected one event, got %d", len(c.events))
+		}
+		if c.path != "/api/42/envelope/" || !strings.Contains(c.auth, "sentry_key=public") {
+			t.Errorf("unexpected request: %s %s", c.path, c.auth)
+		}
+		e := c.events[0]
+		if e["level"] != "error" || e["message"].(map[string]any)["formatted"] != "save failed" || e["environment"] != "prod" || e["server_name"] != "host" {
+			t.Errorf("unexpected event: %v", e)
+		}
+		if e["tags"].(map[string]any)["tenant"] != "acme" || e["extra"].(map[string]any)["g"].(map[string]any)["n"] != 1.0 || e["extra"].(map[string]any)["error"] != nil {
+			t.Errorf("unexpected tags and extra: %v %v", e["tags"], e["extra"])
+		}
+		if fp := e["fingerprint"].([]any); len(fp) != 2 || fp[1] != "save" {
+			t.Errorf("unexpected fingerprint: %v", fp)
+		}
+		if tc := e["contexts"].(map[string]any)["trace"].(map[string]any); tc["trace_id"] != sc.TraceID().String() || tc["span_id"] != sc.SpanID().String() {
+			t.Errorf("unexpected trace context: %v", tc)
+		}
+		crumbs := e["breadcrumbs"].(map[string]any)["values"].([]any)
+		if len(crumbs) != 1 || crumbs[0].(map[string]any)["message"] != "loading" || crumbs[0].(map[string]any)["data"].(map[string]any)["file"] != "a.txt" {
+			t.Errorf("unexpected breadcrumbs: %v", crumbs)
+		}
+		values := e["exception"].(map[string]any)["values"].([]any)
+		inner, outer := values[0].(map[string]any), values[1].(map[string]any)
+		if len(values) != 2 || inner["value"] != "disk full" || outer["value"] != "saving: disk full" || inner["stacktrace"] == nil {
+			t.Fatalf("unexpected exceptions: %v", values)
+		}
+		frames := inner["stacktrace"].(map[string]any)["frames"].([]any)
+		last := frames[len(frames)-1].(map[string]any)
+		if last["function"] != "newTracedError" || last["module"] != "github.com/mikluko/slogging/sentry" || last["in_app"] != true {
+			t.Errorf("unexpected innermost frame: %v", last)
+		}
+	})
+
+	t.Run("errors and stringers are sent as strings", func(t *testing.T) {
+		c, h, _ := setup(t)
+		logger := slog.New(h)
+		logger.InfoContext(ctx, "retrying", "cause", errors.New("timeout"))
+		logger.ErrorContext(ctx, "failed", "addr", netip.MustParseAddr("10.0.0.1"), "d", stringer("peer"))
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		e := c.events[0]
+		if extra := e["extra"].(map[string]any); extra["addr"] != "10.0.0.1" || extra["d"] != "stringer peer" {
+			t.Errorf("unexpected extra: %v", extra)
+		}
+		crumbs := e["breadcrumbs"].(map[string]any)["values"].([]any)
+		if data := crumbs[0].(map[string]any)["data"].(map[string]any); data["cause"] != "timeout" {
+			t.Errorf("unexpected breadcrumb data: %v", data)
+		}
+	})
+
+	t.Run("events without error carry the call site", func(t *testing.T) {
+		c, h, _ := setup(t, WithEventLevel(slog.LevelWarn))
+		slog.New(h).Warn("slow")
+		if err := h.Close(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		threads := c.events[0]["threads"].(map[string]any)["values"].([]any)
+		frames := threads[0].(map[string]any)["stacktrace"].(map[string]any)["frames"].([]any)
+		if last := frames[len(frames)-1].(map[string]any); !strings.HasPrefix(last["function"].(string), "Test_Handler") || c.events[0]["level"] != "warning" {
+			t.Errorf("unexpected call site: %v", last)
+		}
+	})
+
+	t.Run("failed sends are retried", func(t *testing.T) {
+		c, h, _ := setup(t, WithRetry(2, time.Millisecond, time.Millisecond))
+		c.fail = 2
+		slog.New(h).Error("retried")
+		if err := h.Flush(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if len(c.events) != 1 || h.Dropped() != 0 {
+			t.Errorf("expected delivery after retries, got %d events", len(c.events))
+		}
+		_ = h.Close(context.Background())
+	})
+
+	t.Run("invalid DSN", func(t *testing.T) {
+		if _, err := Wrap(slog.NewTextHandler(io.Discard, nil), "https://sentry.io/"); err == nil {
+			t.Errorf("expected an error")
+		}
+	})
+}
+
+func Test_splitFunction(t *testing.T) {
+	for name, want := range map[string][2]string{
+		"github.com/a/b.(*T).Method": {"github.com/a/b", "(*T).Method"},
+		"main.main":                  {"main", "main"},
+		"runtime.goexit":             {"runtime", "goexit"},
+	} {
+		if m, f := splitFunction(name); m != want[0] || f != want[1] {
+			t.Errorf("splitFunction(%q) = %q, %q", name, m, f)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package sequence provides per-context sequence numbers stamped on
+// records, so that the exact order of the records of a request can be
+// reconstructed even when sinks or clocks reorder them.
+package sequence
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// Key is the attribute holding the sequence number of a record.
+const Key = "log.seq_in_request"
+
+type contextKey struct{}
+
+// Start returns a copy of ctx carrying a new sequence, typically called
+// when a request starts. Records logged with ctx, or contexts derived from
+// it, are numbered from 1 upwards. Starting a sequence on a context already
+// carrying one replaces it for the returned context.
+func Start(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, new(atomic.Uint64))
+}
+
+// Next returns the next number of the sequence carried by ctx, or false
+// if ctx carries none. Numbers are issued atomically, so records logged
+// concurrently within a request get distinct numbers.
+func Next(ctx context.Context) (uint64, bool) {
+	counter, ok := ctx.Value(contextKey{}).(*atomic.Uint64)
+	if !ok {
+		return 0, false
+	}
+	return counter.Add(1), true
+}
+
+// Handler is a slog.Handler that adds the next number of the sequence of
+// the logging context as a top-level attribute to every record before
+// delivering it to the wrapped handler. Records logged with a context
+// without a sequence are delivered as they are. Numbers are issued when
+// the record reaches the handler, so gaps show records dropped by
+// handlers placed in front of it, and records delivered out of order by
+// handlers placed after it, such as async, keep their logging order.
+type Handler struct {
+	handler slog.Handler // Wrapped handler with derivations applied
+	root    slog.Handler // Wrapped handler without derivations
+	scope   scope.Scope
+	key     string
+}
+
+// Wrap creates a handler numbering records delivered to handler.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{key: Key}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{handler: handler, root: handler, key: config.key}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle stamps the record with the next sequence number and delivers it.
+// The attributes of WithAttrs and WithGroup calls are applied to the
+// record, so that the number stays at the top level.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	n, ok := Next(ctx)
+	if !ok {
+		return h.handler.Handle(ctx, r)
+	}
+	if h.scope.Empty() {
+		r = r.Clone()
+		r.AddAttrs(slog.Uint64(h.key, n))
+		return h.handler.Handle(ctx, r)
+	}
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(slog.Uint64(h.key, n))
+	nr.AddAttrs(h.scope.Attrs(r)...)
+	return h.root.Handle(ctx, nr)
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	key string
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithKey sets the attribute holding the sequence number. Defaults to Key.
+func WithKey(key string) Option {
+	return func(h *handlerOptions) {
+		h.key = key
+	}
+}
@@ -0,0 +1,66 @@
+package sequence
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_Handler(t *testing.T) {
+	t.Run("records of a request are numbered", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil)))
+		ctx := Start(context.Background())
+		logger.InfoContext(ctx, "one")
+		logger.InfoContext(ctx, "two")
+		logger.InfoContext(Start(context.Background()), "other")
+		logger.Info("none")
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		for i, want := range []string{"msg=one log.seq_in_request=1", "msg=two log.seq_in_request=2", "msg=other log.seq_in_request=1"} {
+			if !strings.Contains(lines[i], want) {
+				t.Errorf("line %d = %q, want %q", i, lines[i], want)
+			}
+		}
+		if strings.Contains(lines[3], Key) {
+			t.Errorf("record without sequence stamped: %q", lines[3])
+		}
+	})
+
+	t.Run("number stays at the top level", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewJSONHandler(buf, nil), WithKey("seq")))
+		logger.With("a", 1).WithGroup("g").InfoContext(Start(context.Background()), "msg", "b", 2)
+		var m map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &m); err != nil {
+			t.Fatal(err)
+		}
+		if m["seq"] != 1.0 || m["a"] != 1.0 || m["g"].(map[string]any)["b"] != 2.0 {
+			t.Errorf("unexpected record: %v", m)
+		}
+	})
+
+	t.Run("concurrent records get distinct numbers", func(t *testing.T) {
+		ctx := Start(context.Background())
+		var wg sync.WaitGroup
+		seen := make([]uint64, 100)
+		for i := range seen {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				seen[i], _ = Next(ctx)
+			}()
+		}
+		wg.Wait()
+		got := map[uint64]bool{}
+		for _, n := range seen {
+			if n < 1 || n > 100 || got[n] {
+				t.Fatalf("unexpected number %d", n)
+			}
+			got[n] = true
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package slogging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mikluko/slogging/console"
+)
+
+// Setup is a one-call bootstrap for services configured through the
+// environment. It builds a handler from the following variables and
+// installs it as Install does, returning the same shutdown function:
+//
+//   - LOG_LEVEL is the minimum level, such as "debug" or "warn", "info"
+//     by default;
+//   - LOG_FORMAT is "json" (default), "text" or "console";
+//   - LOG_OUTPUT is "stderr" (default), "stdout" or the path of a file
+//     records are appended to, closed by the shutdown function;
+//   - OTEL_SERVICE_NAME and OTEL_RESOURCE_ATTRIBUTES, as read by the
+//     OpenTelemetry SDK, become attributes of every record, such as
+//     service.name;
+//   - OTEL_SDK_DISABLED set to true leaves records without trace context.
+//
+// Unlike Install, Setup always wraps the handler with otel.Wrap, unless
+// disabled, so that records logged after a tracer provider is registered
+// carry trace context too. Options apply as for Install; LOG_LEVEL also
+// sets the level of records produced by the standard log package.
+func Setup(options ...InstallOption) (shutdown func(context.Context) error, err error) {
+	lvl := slog.LevelInfo
+	if v := os.Getenv("LOG_LEVEL"); v != "" {
+		if err := lvl.UnmarshalText([]byte(v)); err != nil {
+			return nil, fmt.Errorf("invalid LOG_LEVEL %q: %w", v, err)
+		}
+	}
+
+	var (
+		w       io.Writer = os.Stderr
+		closers []any
+	)
+	switch v := os.Getenv("LOG_OUTPUT"); v {
+	case "", "stderr":
+	case "stdout":
+		w = os.Stdout
+	default:
+		f, err := os.OpenFile(v, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("error when opening LOG_OUTPUT: %w", err)
+		}
+		w, closers = f, []any{f}
+	}
+
+	var handler slog.Handler
+	switch v := os.Getenv("LOG_FORMAT"); v {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl})
+	case "text":
+		handler = slog.NewTextHandler(w, &slog.HandlerOptions{Level: lvl})
+	case "console":
+		handler = console.NewHandler(w, console.WithLevel(lvl))
+	default:
+		_ = CloseAll(context.Background(), closers...)
+		return nil, fmt.Errorf("invalid LOG_FORMAT %q", v)
+	}
+	if attrs := resourceAttrs(); len(attrs) > 0 {
+		handler = handler.WithAttrs(attrs)
+	}
+
+	disabled, _ := strconv.ParseBool(os.Getenv("OTEL_SDK_DISABLED"))
+	wrapOtel := !disabled
+	setup := func(o *installOptions) {
+		o.handler = handler
+		o.logLevel = lvl
+		o.wrapOtel = &wrapOtel
+		o.closers = closers
+	}
+	return Install(append([]InstallOption{setup}, options...)...), nil
+}
+
+// resourceAttrs returns the attributes of OTEL_RESOURCE_ATTRIBUTES, a list
+// of percent-encoded key=value pairs, and OTEL_SERVICE_NAME, which takes
+// precedence over the service.name resource attribute.
+func resourceAttrs() []slog.Attr {
+	var attrs []slog.Attr
+	service := os.Getenv("OTEL_SERVICE_NAME")
+	for _, item := range strings.Split(os.Getenv("OTEL_RESOURCE_ATTRIBUTES"), ",") {
+		k, v, ok := strings.Cut(strings.TrimSpace(item), "=")
+		if !ok || k == "" {
+			continue
+		}
+		if uv, err := url.PathUnescape(v); err == nil {
+			v = uv
+		}
+		if k == "service.name" && service != "" {
+			continue
+		}
+		attrs = append(attrs, slog.String(k, v))
+	}
+	if service != "" {
+		attrs = append([]slog.Attr{slog.String("service.name", service)}, attrs...)
+	}
+	return attrs
+}
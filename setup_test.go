@@ -0,0 +1,71 @@
+package slogging
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mikluko/slogging/console"
+	"github.com/mikluko/slogging/otel"
+)
+
+func Test_Setup(t *testing.T) {
+	defaultLogger := slog.Default()
+	defer slog.SetDefault(defaultLogger)
+	defer slog.SetLogLoggerLevel(slog.LevelInfo)
+
+	t.Run("from the environment", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "app.log")
+		t.Setenv("LOG_LEVEL", "warn")
+		t.Setenv("LOG_FORMAT", "text")
+		t.Setenv("LOG_OUTPUT", path)
+		t.Setenv("OTEL_SERVICE_NAME", "checkout")
+		t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "service.name=ignored,deployment.environment=prod%20eu")
+
+		shutdown, err := Setup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := slog.Default().Handler().(*otel.Handler); !ok {
+			t.Errorf("expected otel handler, got %T", slog.Default().Handler())
+		}
+		slog.Info("hidden")
+		slog.Warn("shown")
+		if err := shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		out, _ := os.ReadFile(path)
+		if strings.Contains(string(out), "hidden") ||
+			!strings.Contains(string(out), `msg=shown service.name=checkout deployment.environment="prod eu"`) {
+			t.Errorf("unexpected output: %s", out)
+		}
+	})
+
+	t.Run("console without trace context", func(t *testing.T) {
+		t.Setenv("LOG_FORMAT", "console")
+		t.Setenv("OTEL_SDK_DISABLED", "true")
+		shutdown, err := Setup()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer shutdown(context.Background())
+		if _, ok := slog.Default().Handler().(*console.Handler); !ok {
+			t.Errorf("expected console handler, got %T", slog.Default().Handler())
+		}
+	})
+
+	t.Run("invalid environment", func(t *testing.T) {
+		for k, v := range map[string]string{"LOG_LEVEL": "loud", "LOG_FORMAT": "xml", "LOG_OUTPUT": t.TempDir()} {
+			t.Run(k, func(t *testing.T) {
+				t.Setenv(k, v)
+				if _, err := Setup(); err == nil {
+					t.Errorf("Setup() succeeded with %s=%s", k, v)
+				}
+			})
+		}
+	})
+}
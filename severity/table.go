@@ -0,0 +1,193 @@
+package severity
+
+import (
+	"log/slog"
+	"sort"
+	"sync"
+)
+
+// Sink identifies a family of log destinations sharing a severity vocabulary.
+type Sink string
+
+const (
+	Syslog     = Sink("syslog")
+	GCP        = Sink("gcp")
+	GELF       = Sink("gelf")
+	Sentry     = Sink("sentry")
+	CloudWatch = Sink("cloudwatch")
+	OTLP       = Sink("otlp")
+)
+
+// Severity is the sink-specific representation of a slog.Level.
+// Code is the numeric value (e.g. syslog priority or GCP severity number),
+// Name is the textual value as expected by the sink.
+type Severity struct {
+	Code int
+	Name string
+}
+
+// Mapping binds a minimal slog.Level to a Severity. Records at or above
+// Level, and below the next mapping, are reported with Severity.
+type Mapping struct {
+	Level    slog.Level
+	Severity Severity
+}
+
+// Table holds level→severity mappings for every sink. Lookups pick the
+// mapping with the highest Level not exceeding the record level, so custom
+// levels (TRACE, NOTICE, AUDIT) fall into the nearest standard bucket unless
+// they are defined explicitly. Table is safe for concurrent use.
+type Table struct {
+	mutex sync.RWMutex
+	sinks map[Sink][]Mapping
+}
+
+// NewTable creates a Table populated with the default mappings.
+func NewTable() *Table {
+	t := &Table{sinks: make(map[Sink][]Mapping, len(defaults))}
+	for sink, mappings := range defaults {
+		t.sinks[sink] = append([]Mapping(nil), mappings...)
+	}
+	return t
+}
+
+// Set maps level to sev for the given sink, replacing any mapping
+// previously registered for exactly that level.
+func (t *Table) Set(sink Sink, level slog.Level, sev Severity) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.set(sink, level, sev)
+}
+
+// Define registers a custom level for several sinks at once. Sinks not
+// present in sevs keep resolving the level to the nearest lower mapping.
+func (t *Table) Define(level slog.Level, sevs map[Sink]Severity) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for sink, sev := range sevs {
+		t.set(sink, level, sev)
+	}
+}
+
+func (t *Table) set(sink Sink, level slog.Level, sev Severity) {
+	mappings := t.sinks[sink]
+	i := sort.Search(len(mappings), func(i int) bool { return mappings[i].Level >= level })
+	if i < len(mappings) && mappings[i].Level == level {
+		mappings[i].Severity = sev
+		return
+	}
+	mappings = append(mappings, Mapping{})
+	copy(mappings[i+1:], mappings[i:])
+	mappings[i] = Mapping{Level: level, Severity: sev}
+	t.sinks[sink] = mappings
+}
+
+// Lookup returns the severity of level for the given sink. Levels below
+// the lowest mapping resolve to the lowest mapping. An unknown sink yields
+// the zero Severity.
+func (t *Table) Lookup(sink Sink, level slog.Level) Severity {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	mappings := t.sinks[sink]
+	if len(mappings) == 0 {
+		return Severity{}
+	}
+	i := sort.Search(len(mappings), func(i int) bool { return mappings[i].Level > level })
+	if i == 0 {
+		return mappings[0].Severity
+	}
+	return mappings[i-1].Severity
+}
+
+// Mappings returns a copy of the mappings registered for the given sink,
+// ordered by level.
+func (t *Table) Mappings(sink Sink) []Mapping {
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	return append([]Mapping(nil), t.sinks[sink]...)
+}
+
+var (
+	defaultMutex sync.RWMutex
+	defaultTable = NewTable()
+)
+
+// Default returns the process-wide table used by sinks that were not
+// given a table explicitly.
+func Default() *Table {
+	defaultMutex.RLock()
+	defer defaultMutex.RUnlock()
+	return defaultTable
+}
+
+// SetDefault replaces the process-wide table. A nil table restores the
+// default mappings.
+func SetDefault(t *Table) {
+	if t == nil {
+		t = NewTable()
+	}
+	defaultMutex.Lock()
+	defer defaultMutex.Unlock()
+	defaultTable = t
+}
+
+// Lookup resolves level for sink using the default table.
+func Lookup(sink Sink, level slog.Level) Severity {
+	return Default().Lookup(sink, level)
+}
+
+var defaults = map[Sink][]Mapping{
+	// RFC 5424 section 6.2.1 numerical severities.
+	Syslog: {
+		{slog.LevelDebug, Severity{7, "debug"}},
+		{slog.LevelInfo, Severity{6, "info"}},
+		{slog.LevelWarn, Severity{4, "warning"}},
+		{slog.LevelError, Severity{3, "err"}},
+		{slog.LevelError + 4, Severity{2, "crit"}},
+		{slog.LevelError + 8, Severity{1, "alert"}},
+		{slog.LevelError + 12, Severity{0, "emerg"}},
+	},
+	// https://cloud.google.com/logging/docs/reference/v2/rest/v2/LogEntry#LogSeverity
+	GCP: {
+		{slog.LevelDebug, Severity{100, "DEBUG"}},
+		{slog.LevelInfo, Severity{200, "INFO"}},
+		{slog.LevelWarn, Severity{400, "WARNING"}},
+		{slog.LevelError, Severity{500, "ERROR"}},
+		{slog.LevelError + 4, Severity{600, "CRITICAL"}},
+		{slog.LevelError + 8, Severity{700, "ALERT"}},
+		{slog.LevelError + 12, Severity{800, "EMERGENCY"}},
+	},
+	// GELF reuses syslog numerical severities.
+	GELF: {
+		{slog.LevelDebug, Severity{7, "debug"}},
+		{slog.LevelInfo, Severity{6, "info"}},
+		{slog.LevelWarn, Severity{4, "warning"}},
+		{slog.LevelError, Severity{3, "error"}},
+		{slog.LevelError + 4, Severity{2, "critical"}},
+		{slog.LevelError + 8, Severity{1, "alert"}},
+		{slog.LevelError + 12, Severity{0, "emergency"}},
+	},
+	Sentry: {
+		{slog.LevelDebug, Severity{0, "debug"}},
+		{slog.LevelInfo, Severity{1, "info"}},
+		{slog.LevelWarn, Severity{2, "warning"}},
+		{slog.LevelError, Severity{3, "error"}},
+		{slog.LevelError + 4, Severity{4, "fatal"}},
+	},
+	CloudWatch: {
+		{slog.LevelDebug, Severity{0, "DEBUG"}},
+		{slog.LevelInfo, Severity{1, "INFO"}},
+		{slog.LevelWarn, Severity{2, "WARN"}},
+		{slog.LevelError, Severity{3, "ERROR"}},
+		{slog.LevelError + 4, Severity{4, "FATAL"}},
+	},
+	// https://opentelemetry.io/docs/specs/otel/logs/data-model/#field-severitynumber
+	OTLP: {
+		{slog.LevelDebug - 4, Severity{1, "TRACE"}},
+		{slog.LevelDebug, Severity{5, "DEBUG"}},
+		{slog.LevelInfo, Severity{9, "INFO"}},
+		{slog.LevelWarn, Severity{13, "WARN"}},
+		{slog.LevelError, Severity{17, "ERROR"}},
+		{slog.LevelError + 4, Severity{21, "FATAL"}},
+	},
+}
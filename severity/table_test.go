@@ -0,0 +1,91 @@
+package severity
+
+import (
+	"log/slog"
+	"testing"
+)
+
+const (
+	levelTrace  = slog.Level(-8)
+	levelNotice = slog.Level(2)
+	levelAudit  = slog.Level(10)
+)
+
+func Test_Table(t *testing.T) {
+	t.Run("standard levels map to sink severities", func(t *testing.T) {
+		tbl := NewTable()
+		cases := []struct {
+			sink  Sink
+			level slog.Level
+			want  Severity
+		}{
+			{Syslog, slog.LevelInfo, Severity{6, "info"}},
+			{Syslog, slog.LevelError, Severity{3, "err"}},
+			{GCP, slog.LevelWarn, Severity{400, "WARNING"}},
+			{GELF, slog.LevelDebug, Severity{7, "debug"}},
+			{Sentry, slog.LevelError, Severity{3, "error"}},
+			{CloudWatch, slog.LevelWarn, Severity{2, "WARN"}},
+		}
+		for _, c := range cases {
+			if got := tbl.Lookup(c.sink, c.level); got != c.want {
+				t.Errorf("Lookup(%s, %s) = %v, want %v", c.sink, c.level, got, c.want)
+			}
+		}
+	})
+
+	t.Run("custom levels fall back to nearest lower mapping", func(t *testing.T) {
+		tbl := NewTable()
+		if got := tbl.Lookup(GCP, levelNotice); got.Name != "INFO" {
+			t.Errorf("expected NOTICE to resolve to INFO, got %v", got)
+		}
+		if got := tbl.Lookup(Syslog, levelTrace); got.Name != "debug" {
+			t.Errorf("expected TRACE to resolve to the lowest mapping, got %v", got)
+		}
+	})
+
+	t.Run("defined levels apply to every listed sink", func(t *testing.T) {
+		tbl := NewTable()
+		tbl.Define(levelNotice, map[Sink]Severity{
+			Syslog: {5, "notice"},
+			GCP:    {300, "NOTICE"},
+		})
+		if got := tbl.Lookup(Syslog, levelNotice); got != (Severity{5, "notice"}) {
+			t.Errorf("unexpected syslog severity for NOTICE: %v", got)
+		}
+		if got := tbl.Lookup(GCP, levelNotice+1); got != (Severity{300, "NOTICE"}) {
+			t.Errorf("unexpected gcp severity above NOTICE: %v", got)
+		}
+		if got := tbl.Lookup(GCP, slog.LevelWarn); got.Name != "WARNING" {
+			t.Errorf("NOTICE mapping should not shadow WARNING, got %v", got)
+		}
+		if got := tbl.Lookup(Sentry, levelNotice); got.Name != "info" {
+			t.Errorf("unlisted sink should keep default mapping, got %v", got)
+		}
+	})
+
+	t.Run("set replaces exact level", func(t *testing.T) {
+		tbl := NewTable()
+		tbl.Set(CloudWatch, levelAudit, Severity{9, "AUDIT"})
+		tbl.Set(CloudWatch, levelAudit, Severity{10, "AUDIT"})
+		if got := tbl.Lookup(CloudWatch, levelAudit); got.Code != 10 {
+			t.Errorf("expected replaced mapping, got %v", got)
+		}
+		if n := len(tbl.Mappings(CloudWatch)); n != 6 {
+			t.Errorf("expected 6 mappings, got %d", n)
+		}
+	})
+
+	t.Run("tables are independent of defaults", func(t *testing.T) {
+		tbl := NewTable()
+		tbl.Set(Syslog, slog.LevelInfo, Severity{5, "notice"})
+		if got := Lookup(Syslog, slog.LevelInfo); got.Code != 6 {
+			t.Errorf("default table was modified: %v", got)
+		}
+	})
+
+	t.Run("unknown sink yields zero severity", func(t *testing.T) {
+		if got := NewTable().Lookup(Sink("nope"), slog.LevelInfo); got != (Severity{}) {
+			t.Errorf("expected zero severity, got %v", got)
+		}
+	})
+}
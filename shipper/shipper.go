@@ -0,0 +1,181 @@
+package shipper
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Format is the on-disk encoding of log records.
+type Format string
+
+const (
+	JSON     = Format("json")
+	Logfmt   = Format("logfmt")
+	OTLPJSON = Format("otlpjson")
+)
+
+// Source describes a log file written by the application, as configured
+// in the logging pipeline, from which shipper configuration is generated.
+type Source struct {
+	// Name identifies the source in the generated configuration. It must
+	// consist of letters, digits and underscores.
+	Name string
+	// Path is the file path or glob pattern of the log files.
+	Path string
+	// Format is the encoding of records in the files.
+	Format Format
+	// TimeKey, LevelKey and MessageKey are the record keys holding the
+	// timestamp, level and message. They default to the slog keys.
+	TimeKey    string
+	LevelKey   string
+	MessageKey string
+}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+func (s Source) normalize() (Source, error) {
+	if !validName.MatchString(s.Name) {
+		return s, fmt.Errorf("invalid source name %q", s.Name)
+	}
+	if s.Path == "" {
+		return s, fmt.Errorf("source %q: path is required", s.Name)
+	}
+	switch s.Format {
+	case "":
+		s.Format = JSON
+	case JSON, Logfmt, OTLPJSON:
+	default:
+		return s, fmt.Errorf("source %q: unsupported format %q", s.Name, s.Format)
+	}
+	if s.TimeKey == "" {
+		s.TimeKey = slog.TimeKey
+	}
+	if s.LevelKey == "" {
+		s.LevelKey = slog.LevelKey
+	}
+	if s.MessageKey == "" {
+		s.MessageKey = slog.MessageKey
+	}
+	return s, nil
+}
+
+// Vector writes a Vector TOML configuration snippet with a file source and
+// a remap transform named "<name>_parse" for every source. The transform
+// parses records and promotes time, level and message to Vector's
+// standard fields.
+func Vector(w io.Writer, sources ...Source) error {
+	var b strings.Builder
+	for i, src := range sources {
+		src, err := src.normalize()
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, "[sources.%s]\n", src.Name)
+		b.WriteString("type = \"file\"\n")
+		fmt.Fprintf(&b, "include = [%s]\n", strconv.Quote(src.Path))
+		b.WriteString("\n")
+		fmt.Fprintf(&b, "[transforms.%s_parse]\n", src.Name)
+		b.WriteString("type = \"remap\"\n")
+		fmt.Fprintf(&b, "inputs = [%s]\n", strconv.Quote(src.Name))
+		b.WriteString("source = '''\n")
+		switch src.Format {
+		case JSON:
+			b.WriteString(". = parse_json!(string!(.message))\n")
+		case Logfmt:
+			b.WriteString(". = parse_logfmt!(string!(.message))\n")
+		case OTLPJSON:
+			b.WriteString("record = parse_json!(string!(.message)).resourceLogs[0].scopeLogs[0].logRecords[0]\n")
+			b.WriteString(". = record.attributes\n")
+			b.WriteString(".timestamp = from_unix_timestamp!(to_int!(record.timeUnixNano), unit: \"nanoseconds\")\n")
+			b.WriteString(".level = record.severityText\n")
+			b.WriteString(".message = record.body.stringValue\n")
+			b.WriteString("'''\n")
+			continue
+		}
+		fmt.Fprintf(&b, ".timestamp = parse_timestamp!(del(.%s), format: \"%%+\")\n", vrlPath(src.TimeKey))
+		if src.LevelKey != "level" {
+			fmt.Fprintf(&b, ".level = del(.%s)\n", vrlPath(src.LevelKey))
+		}
+		if src.MessageKey != "message" {
+			fmt.Fprintf(&b, ".message = del(.%s)\n", vrlPath(src.MessageKey))
+		}
+		b.WriteString("'''\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// vrlPath quotes a key for use as a VRL path segment when needed.
+func vrlPath(key string) string {
+	if validName.MatchString(key) {
+		return key
+	}
+	return strconv.Quote(key)
+}
+
+// FluentBit writes Fluent Bit [INPUT] sections tailing every source with
+// the parser named "<name>_<format>". The parsers themselves are written
+// by FluentBitParsers and belong in the file referenced by Parsers_File.
+func FluentBit(w io.Writer, sources ...Source) error {
+	var b strings.Builder
+	for i, src := range sources {
+		src, err := src.normalize()
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("[INPUT]\n")
+		b.WriteString("    Name   tail\n")
+		fmt.Fprintf(&b, "    Path   %s\n", src.Path)
+		fmt.Fprintf(&b, "    Tag    %s\n", src.Name)
+		fmt.Fprintf(&b, "    Parser %s\n", parserName(src))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// FluentBitParsers writes the Fluent Bit [PARSER] sections referenced by
+// the inputs generated by FluentBit.
+func FluentBitParsers(w io.Writer, sources ...Source) error {
+	var b strings.Builder
+	for i, src := range sources {
+		src, err := src.normalize()
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("[PARSER]\n")
+		fmt.Fprintf(&b, "    Name        %s\n", parserName(src))
+		switch src.Format {
+		case Logfmt:
+			b.WriteString("    Format      logfmt\n")
+		default:
+			b.WriteString("    Format      json\n")
+		}
+		if src.Format == OTLPJSON {
+			// Timestamps are nested inside the export request and cannot
+			// be extracted by the parser.
+			continue
+		}
+		fmt.Fprintf(&b, "    Time_Key    %s\n", src.TimeKey)
+		b.WriteString("    Time_Format %Y-%m-%dT%H:%M:%S.%L%z\n")
+		b.WriteString("    Time_Keep   On\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func parserName(src Source) string {
+	return src.Name + "_" + string(src.Format)
+}
@@ -0,0 +1,95 @@
+package shipper
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_Vector(t *testing.T) {
+	t.Run("json source", func(t *testing.T) {
+		var b strings.Builder
+		err := Vector(&b, Source{Name: "app", Path: "/var/log/app.log", MessageKey: "msg"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := `[sources.app]
+type = "file"
+include = ["/var/log/app.log"]
+
+[transforms.app_parse]
+type = "remap"
+inputs = ["app"]
+source = '''
+. = parse_json!(string!(.message))
+.timestamp = parse_timestamp!(del(.time), format: "%+")
+.message = del(.msg)
+'''
+`
+		if b.String() != want {
+			t.Errorf("unexpected output:\n%s\nwant:\n%s", b.String(), want)
+		}
+	})
+
+	t.Run("logfmt source with custom keys", func(t *testing.T) {
+		var b strings.Builder
+		err := Vector(&b, Source{Name: "audit", Path: "/var/log/audit/*.log", Format: Logfmt, TimeKey: "@ts", LevelKey: "severity"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		for _, s := range []string{`parse_logfmt!`, `del(."@ts")`, `.level = del(.severity)`} {
+			if !strings.Contains(b.String(), s) {
+				t.Errorf("expected %q in output:\n%s", s, b.String())
+			}
+		}
+	})
+
+	t.Run("invalid source", func(t *testing.T) {
+		var b strings.Builder
+		if err := Vector(&b, Source{Name: "bad name", Path: "/x"}); err == nil {
+			t.Errorf("expected error for invalid name")
+		}
+		if err := Vector(&b, Source{Name: "app"}); err == nil {
+			t.Errorf("expected error for missing path")
+		}
+		if err := Vector(&b, Source{Name: "app", Path: "/x", Format: "xml"}); err == nil {
+			t.Errorf("expected error for unsupported format")
+		}
+	})
+}
+
+func Test_FluentBit(t *testing.T) {
+	sources := []Source{
+		{Name: "app", Path: "/var/log/app.log"},
+		{Name: "audit", Path: "/var/log/audit.log", Format: Logfmt},
+	}
+
+	var inputs strings.Builder
+	if err := FluentBit(&inputs, sources...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(inputs.String(), "Parser app_json") || !strings.Contains(inputs.String(), "Parser audit_logfmt") {
+		t.Errorf("unexpected inputs:\n%s", inputs.String())
+	}
+
+	var parsers strings.Builder
+	if err := FluentBitParsers(&parsers, sources...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `[PARSER]
+    Name        app_json
+    Format      json
+    Time_Key    time
+    Time_Format %Y-%m-%dT%H:%M:%S.%L%z
+    Time_Keep   On
+
+[PARSER]
+    Name        audit_logfmt
+    Format      logfmt
+    Time_Key    time
+    Time_Format %Y-%m-%dT%H:%M:%S.%L%z
+    Time_Keep   On
+`
+	if parsers.String() != want {
+		t.Errorf("unexpected parsers:\n%s\nwant:\n%s", parsers.String(), want)
+	}
+}
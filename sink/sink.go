@@ -0,0 +1,137 @@
+// Package sink provides a generic slog.Handler converting records into
+// values of a user-defined type before delivering them, for strongly-typed
+// consumers such as analytics or audit pipelines that should not parse
+// generic attributes again.
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/internal/jsonvalue"
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// ErrSkip is returned by a Mapper to discard a record without error.
+var ErrSkip = errors.New("slogging: skip record")
+
+// Mapper converts a record into a value of type T. The attributes of the
+// record include those added via WithAttrs, nested under the groups opened
+// via WithGroup. Returning ErrSkip discards the record.
+type Mapper[T any] func(ctx context.Context, r slog.Record) (T, error)
+
+// Consumer receives the values converted from records.
+type Consumer[T any] func(ctx context.Context, v T) error
+
+// JSON returns a Mapper decoding the map of a record, as returned by
+// slogging.ToMap, into T with encoding/json, so that the fields of T are
+// filled by their json tags: "time", "level" and "msg" for the built-in
+// fields, group names for nested structs.
+func JSON[T any]() Mapper[T] {
+	return func(_ context.Context, r slog.Record) (T, error) {
+		var v T
+		m := slogging.ToMap(r)
+		jsonvalue.Map(m)
+		b, err := json.Marshal(m)
+		if err != nil {
+			return v, err
+		}
+		return v, json.Unmarshal(b, &v)
+	}
+}
+
+// Channel returns a Consumer sending values to ch, blocking until ch
+// accepts the value or the context of the record is done.
+func Channel[T any](ch chan<- T) Consumer[T] {
+	return func(ctx context.Context, v T) error {
+		select {
+		case ch <- v:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Sink is a slog.Handler converting the records at or above its level,
+// slog.LevelInfo by default, into values of type T with a Mapper and
+// delivering them to a Consumer. Errors of both are returned by Handle.
+type Sink[T any] struct {
+	mapper   Mapper[T]
+	consumer Consumer[T]
+	level    slog.Leveler
+	scope    scope.Scope
+}
+
+// New creates a sink converting records with mapper and delivering the
+// values to consumer.
+func New[T any](mapper Mapper[T], consumer Consumer[T], options ...Option) *Sink[T] {
+	config := handlerOptions{level: slog.LevelInfo}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Sink[T]{mapper: mapper, consumer: consumer, level: config.level}
+}
+
+// Enabled reports whether records at the given level are delivered.
+func (s *Sink[T]) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= s.level.Level()
+}
+
+// Handle converts the record and delivers the value.
+func (s *Sink[T]) Handle(ctx context.Context, r slog.Record) error {
+	if !s.scope.Empty() {
+		nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+		nr.AddAttrs(s.scope.Attrs(r)...)
+		r = nr
+	}
+	v, err := s.mapper(ctx, r)
+	if errors.Is(err, ErrSkip) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error when mapping record: %w", err)
+	}
+	return s.consumer(ctx, v)
+}
+
+// WithAttrs returns a new Sink with the attributes added to its scope.
+func (s *Sink[T]) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return s
+	}
+	s2 := *s
+	s2.scope = s.scope.WithAttrs(attrs)
+	return &s2
+}
+
+// WithGroup returns a new Sink with the group opened in its scope.
+func (s *Sink[T]) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return s
+	}
+	s2 := *s
+	s2.scope = s.scope.WithGroup(name)
+	return &s2
+}
+
+type handlerOptions struct {
+	level slog.Leveler
+}
+
+// Option is a function that configures a Sink.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the minimum level of delivered records. Defaults to
+// slog.LevelInfo.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
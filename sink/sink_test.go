@@ -0,0 +1,82 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+type auditEntry struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"msg"`
+	Actor  string    `json:"actor"`
+	Target struct {
+		Kind string `json:"kind"`
+		ID   int    `json:"id"`
+	} `json:"target"`
+	Err string `json:"error"`
+}
+
+// user is logged by its String method.
+type user struct{ name string }
+
+func (u user) String() string { return u.name }
+
+func Test_Sink(t *testing.T) {
+	t.Run("json mapper", func(t *testing.T) {
+		var got []auditEntry
+		s := New(JSON[auditEntry](), func(_ context.Context, e auditEntry) error {
+			got = append(got, e)
+			return nil
+		})
+		logger := slog.New(s).With("actor", user{"alice"}).WithGroup("target")
+		logger.Info("delete", "kind", "repo", "id", 42)
+		logger.Debug("below level")
+		slog.New(s).Warn("denied", "error", errors.New("forbidden"))
+
+		if len(got) != 2 {
+			t.Fatalf("got %d entries: %+v", len(got), got)
+		}
+		e := got[0]
+		if e.Action != "delete" || e.Actor != "alice" || e.Target.Kind != "repo" || e.Target.ID != 42 || e.Time.IsZero() {
+			t.Errorf("unexpected entry: %+v", e)
+		}
+		if got[1].Err != "forbidden" {
+			t.Errorf("unexpected entry: %+v", got[1])
+		}
+	})
+
+	t.Run("custom mapper and channel consumer", func(t *testing.T) {
+		type event struct {
+			Name  string
+			Level slog.Level
+		}
+		ch := make(chan event, 2)
+		s := New(func(_ context.Context, r slog.Record) (event, error) {
+			if r.Message == "skip" {
+				return event{}, ErrSkip
+			}
+			if r.Message == "bad" {
+				return event{}, errors.New("unmappable")
+			}
+			return event{Name: r.Message, Level: r.Level}, nil
+		}, Channel(ch), WithLevel(slog.LevelDebug))
+
+		logger := slog.New(s)
+		logger.Debug("signup")
+		logger.Info("skip")
+		if err := s.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "bad", 0)); err == nil {
+			t.Error("expected mapping error")
+		}
+		close(ch)
+		var got []event
+		for e := range ch {
+			got = append(got, e)
+		}
+		if len(got) != 1 || got[0] != (event{Name: "signup", Level: slog.LevelDebug}) {
+			t.Errorf("unexpected events: %+v", got)
+		}
+	})
+}
@@ -0,0 +1,344 @@
+// Package soak generates synthetic logging load and checks what a sink
+// delivered, for qualifying new sink implementations before production use.
+package soak
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SeqKey and WorkerKey are the attributes identifying generated
+	// records: the sequence number of the record within its worker, from 0,
+	// and the worker index.
+	SeqKey    = "soak_seq"
+	WorkerKey = "soak_worker"
+
+	defaultCount = 1000
+)
+
+// ErrDelivery is returned by Tally.Check when records were lost,
+// duplicated or reordered.
+var ErrDelivery = errors.New("slogging: soak delivery check failed")
+
+// Shape builds the level, message and attributes of the n-th record of a
+// worker. SeqKey and WorkerKey are added by the generator.
+type Shape func(n uint64) (slog.Level, string, []slog.Attr)
+
+// Flat returns a Shape of Info records with the given number of string
+// attributes "k0", "k1" and so on.
+func Flat(attrs int) Shape {
+	return func(uint64) (slog.Level, string, []slog.Attr) {
+		out := make([]slog.Attr, attrs)
+		for i := range out {
+			out[i] = slog.String("k"+strconv.Itoa(i), "value")
+		}
+		return slog.LevelInfo, "soak record", out
+	}
+}
+
+// Nested returns a Shape of Info records with attrs attributes nested
+// depth groups deep.
+func Nested(depth, attrs int) Shape {
+	flat := Flat(attrs)
+	return func(n uint64) (slog.Level, string, []slog.Attr) {
+		level, msg, out := flat(n)
+		for d := depth - 1; d >= 0; d-- {
+			out = []slog.Attr{{Key: "g" + strconv.Itoa(d), Value: slog.GroupValue(out...)}}
+		}
+		return level, msg, out
+	}
+}
+
+// Payload returns a Shape of Info records carrying a "payload" string of
+// the given size in bytes.
+func Payload(size int) Shape {
+	payload := strings.Repeat("x", size)
+	return func(uint64) (slog.Level, string, []slog.Attr) {
+		return slog.LevelInfo, "soak record", []slog.Attr{slog.String("payload", payload)}
+	}
+}
+
+// Mix returns a Shape cycling through shapes, record by record.
+func Mix(shapes ...Shape) Shape {
+	return func(n uint64) (slog.Level, string, []slog.Attr) {
+		return shapes[n%uint64(len(shapes))](n)
+	}
+}
+
+// Result summarizes a Generate run.
+type Result struct {
+	// Sent is the number of records passed to the handler by each worker.
+	Sent []uint64
+	// Errors is the number of records for which Handle returned an error.
+	// They are counted in Sent and expected by Tally.Check nonetheless.
+	Errors  uint64
+	Elapsed time.Duration
+}
+
+// Total returns the number of records sent by all workers.
+func (r Result) Total() uint64 {
+	var n uint64
+	for _, s := range r.Sent {
+		n += s
+	}
+	return n
+}
+
+// Generate logs records to handler from concurrent workers until each
+// worker has sent the configured count, the configured duration has
+// elapsed or ctx is done. Without WithCount and WithDuration every worker
+// sends 1000 records.
+func Generate(ctx context.Context, handler slog.Handler, options ...Option) Result {
+	config := handlerOptions{
+		shape:   Flat(4),
+		workers: 1,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	if config.count == 0 && config.duration == 0 {
+		config.count = defaultCount
+	}
+	if config.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, config.duration)
+		defer cancel()
+	}
+
+	result := Result{Sent: make([]uint64, config.workers)}
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+		start = time.Now()
+	)
+	for w := range config.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sent, errs := work(ctx, handler, w, config)
+			mutex.Lock()
+			result.Sent[w] = sent
+			result.Errors += errs
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+	result.Elapsed = time.Since(start)
+	return result
+}
+
+func work(ctx context.Context, handler slog.Handler, worker int, config handlerOptions) (sent, errs uint64) {
+	var interval time.Duration
+	if config.rate > 0 {
+		interval = time.Duration(float64(time.Second) * float64(config.workers) / config.rate)
+	}
+	start := time.Now()
+	for n := uint64(0); config.count == 0 || n < config.count; n++ {
+		if config.burstSize > 0 && n > 0 && n%uint64(config.burstSize) == 0 {
+			if !sleep(ctx, config.burstPause) {
+				return n, errs
+			}
+		} else if interval > 0 {
+			if !sleep(ctx, time.Until(start.Add(time.Duration(n)*interval))) {
+				return n, errs
+			}
+		}
+		if ctx.Err() != nil {
+			return n, errs
+		}
+		level, msg, attrs := config.shape(n)
+		r := slog.NewRecord(time.Now(), level, msg, 0)
+		r.AddAttrs(slog.Uint64(SeqKey, n), slog.Int(WorkerKey, worker))
+		r.AddAttrs(attrs...)
+		if err := handler.Handle(ctx, r); err != nil {
+			errs++
+		}
+	}
+	return config.count, errs
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Tally records the generated records delivered by a sink. It is a
+// slog.Handler, to be placed at the end of an in-process pipeline under
+// test; records read back from an external sink are reported with Observe.
+// Tally is safe for concurrent use.
+type Tally struct {
+	mutex sync.Mutex
+	seen  map[int][]uint64
+}
+
+// NewTally creates an empty Tally.
+func NewTally() *Tally {
+	return &Tally{seen: make(map[int][]uint64)}
+}
+
+// Observe records the delivery of the record with the given worker and
+// sequence number.
+func (t *Tally) Observe(worker int, seq uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.seen[worker] = append(t.seen[worker], seq)
+}
+
+// Enabled reports true for every level.
+func (t *Tally) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+// Handle observes records carrying SeqKey and WorkerKey at the top level
+// and ignores other records.
+func (t *Tally) Handle(_ context.Context, r slog.Record) error {
+	var (
+		seq, worker       slog.Value
+		hasSeq, hasWorker bool
+	)
+	r.Attrs(func(a slog.Attr) bool {
+		switch a.Key {
+		case SeqKey:
+			seq, hasSeq = a.Value, true
+		case WorkerKey:
+			worker, hasWorker = a.Value, true
+		}
+		return !hasSeq || !hasWorker
+	})
+	if hasSeq && hasWorker {
+		t.Observe(int(worker.Int64()), seq.Uint64())
+	}
+	return nil
+}
+
+// WithAttrs returns t.
+func (t *Tally) WithAttrs([]slog.Attr) slog.Handler {
+	return t
+}
+
+// WithGroup returns t.
+func (t *Tally) WithGroup(string) slog.Handler {
+	return t
+}
+
+// Report counts delivery anomalies found by Tally.Check.
+type Report struct {
+	Delivered  uint64
+	Missing    uint64
+	Duplicated uint64
+	Reordered  uint64 // Records delivered after a later record of their worker
+}
+
+// Check compares the delivered records with result. It returns an error
+// wrapping ErrDelivery if records are missing or duplicated, or, when
+// ordered is true, delivered out of order within a worker.
+func (t *Tally) Check(result Result, ordered bool) (Report, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	var rep Report
+	for w, sent := range result.Sent {
+		seen := t.seen[w]
+		counts := make(map[uint64]int, len(seen))
+		var last uint64
+		for i, seq := range seen {
+			counts[seq]++
+			if i > 0 && seq < last {
+				rep.Reordered++
+			}
+			last = max(last, seq)
+		}
+		for seq := uint64(0); seq < sent; seq++ {
+			switch c := counts[seq]; {
+			case c == 0:
+				rep.Missing++
+			case c > 1:
+				rep.Duplicated += uint64(c - 1)
+			}
+		}
+		rep.Delivered += uint64(len(seen))
+	}
+	if rep.Missing > 0 || rep.Duplicated > 0 || (ordered && rep.Reordered > 0) {
+		return rep, fmt.Errorf("%w: %d of %d delivered, %d missing, %d duplicated, %d reordered",
+			ErrDelivery, rep.Delivered, result.Total(), rep.Missing, rep.Duplicated, rep.Reordered)
+	}
+	return rep, nil
+}
+
+type handlerOptions struct {
+	shape      Shape
+	workers    int
+	count      uint64
+	duration   time.Duration
+	rate       float64
+	burstSize  int
+	burstPause time.Duration
+}
+
+// Option is a function that configures Generate.
+type Option func(h *handlerOptions)
+
+// WithShape sets the shape of generated records, Flat(4) by default.
+func WithShape(s Shape) Option {
+	return func(h *handlerOptions) {
+		h.shape = s
+	}
+}
+
+// WithWorkers sets the number of concurrent workers, 1 by default. Values
+// below 1 are treated as 1.
+func WithWorkers(n int) Option {
+	return func(h *handlerOptions) {
+		h.workers = max(n, 1)
+	}
+}
+
+// WithCount sets the number of records sent by each worker.
+func WithCount(n uint64) Option {
+	return func(h *handlerOptions) {
+		h.count = n
+	}
+}
+
+// WithDuration stops generation after d.
+func WithDuration(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.duration = d
+	}
+}
+
+// WithRate limits the total rate to perSecond records, spread evenly
+// across workers. Without it, records are sent as fast as the handler
+// accepts them.
+func WithRate(perSecond float64) Option {
+	return func(h *handlerOptions) {
+		h.rate = perSecond
+	}
+}
+
+// WithBursts sends records in bursts of size records, as fast as the
+// handler accepts them, separated by pause. It takes precedence over
+// WithRate.
+func WithBursts(size int, pause time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.burstSize = size
+		h.burstPause = pause
+	}
+}
@@ -0,0 +1,88 @@
+package soak
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lossyHandler drops every 10th record and delivers the records it holds
+// back in reverse order on flush.
+type lossyHandler struct {
+	slog.Handler
+	mutex sync.Mutex
+	n     int
+	held  []slog.Record
+}
+
+func (h *lossyHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.n++
+	switch {
+	case h.n%10 == 0:
+		return errors.New("dropped")
+	case h.n%10 == 5:
+		h.held = append(h.held, r)
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *lossyHandler) flush() {
+	for _, r := range h.held {
+		_ = h.Handler.Handle(context.Background(), r)
+	}
+}
+
+func Test_Generate(t *testing.T) {
+	t.Run("reliable sink", func(t *testing.T) {
+		tally := NewTally()
+		result := Generate(context.Background(), tally, WithWorkers(4), WithCount(250), WithShape(Mix(Flat(2), Nested(2, 1), Payload(64))))
+
+		rep, err := tally.Check(result, true)
+		if err != nil || result.Total() != 1000 || rep.Delivered != 1000 || result.Errors != 0 {
+			t.Errorf("unexpected result: %+v, %+v, %v", result, rep, err)
+		}
+	})
+
+	t.Run("lossy sink", func(t *testing.T) {
+		tally := NewTally()
+		h := &lossyHandler{Handler: tally}
+		result := Generate(context.Background(), h, WithCount(100))
+		h.flush()
+
+		rep, err := tally.Check(result, true)
+		if !errors.Is(err, ErrDelivery) || rep.Missing != 10 || rep.Reordered != 10 || rep.Duplicated != 0 || result.Errors != 10 {
+			t.Errorf("unexpected result: %+v, %v", rep, err)
+		}
+		if _, err := tally.Check(result, false); err == nil {
+			t.Errorf("expected missing records to fail unordered check")
+		}
+	})
+
+	t.Run("pacing", func(t *testing.T) {
+		result := Generate(context.Background(), NewTally(), WithCount(30), WithBursts(10, 20*time.Millisecond))
+		if result.Elapsed < 40*time.Millisecond {
+			t.Errorf("expected bursts to be paced, took %s", result.Elapsed)
+		}
+
+		result = Generate(context.Background(), NewTally(), WithRate(1000), WithDuration(50*time.Millisecond))
+		if total := result.Total(); total < 10 || total > 60 {
+			t.Errorf("expected about 50 records, got %d", total)
+		}
+	})
+
+	t.Run("shapes", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		Generate(context.Background(), slog.NewTextHandler(buf, nil), WithCount(1), WithShape(Nested(2, 1)))
+		if !strings.Contains(buf.String(), `msg="soak record" soak_seq=0 soak_worker=0 g0.g1.k0=value`) {
+			t.Errorf("unexpected record: %s", buf.String())
+		}
+	})
+}
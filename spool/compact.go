@@ -0,0 +1,181 @@
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+const (
+	// CompactedCountKey is the attribute of compacted records holding the
+	// number of records they stand for.
+	CompactedCountKey = "compacted_count"
+	// CompactedUntilKey is the attribute of compacted records holding the
+	// time of the last record they stand for; the time of the record is
+	// that of the first one.
+	CompactedUntilKey = "compacted_until"
+)
+
+// summary is a compacted record under construction.
+type summary struct {
+	m     map[string]any
+	count int64
+	until any
+}
+
+// compact compacts the bands below the compaction level, lowest first,
+// while the spool is beyond the compaction threshold. Must be called with
+// the mutex held.
+func (s *state) compact() {
+	c := s.config
+	if c.compaction <= 0 || c.capacity <= 0 {
+		return
+	}
+	for _, b := range s.bands {
+		if float64(s.size) <= c.compaction*float64(c.capacity) || b.level >= c.compactBelow {
+			return
+		}
+		if err := s.compactBand(b); err != nil {
+			s.report(fmt.Errorf("error when compacting spool: %w", err))
+		}
+	}
+}
+
+// compactBand replaces the sealed segments of the band, other than the one
+// being forwarded, with a single compacted segment, merging the summaries
+// of earlier compactions. The active segment is left to fill up, so that
+// compaction runs at most once per segment. Must be called with the mutex
+// held.
+func (s *state) compactBand(b *band) error {
+	var (
+		segments []*segment
+		position = -1
+		fresh    bool
+	)
+	for i, seg := range b.segments {
+		if seg.file != nil || seg == s.forwarding {
+			continue
+		}
+		if position < 0 {
+			position = i
+		}
+		segments = append(segments, seg)
+		fresh = fresh || !seg.compacted
+	}
+	if !fresh {
+		return nil
+	}
+
+	var (
+		summaries []*summary
+		index     = map[string]*summary{}
+		records   int
+	)
+	for _, seg := range segments {
+		data, err := os.ReadFile(seg.path)
+		if err != nil {
+			return err
+		}
+		reader := bufio.NewReader(bytes.NewReader(data))
+		for i := 0; ; i++ {
+			line, err := reader.ReadBytes('\n')
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if i < seg.delivered {
+				continue
+			}
+			d := json.NewDecoder(bytes.NewReader(line))
+			d.UseNumber()
+			var m map[string]any
+			if err := d.Decode(&m); err != nil {
+				s.report(fmt.Errorf("error when decoding spooled record: %w", err))
+				continue
+			}
+			records++
+			count := int64(1)
+			if n, ok := m[CompactedCountKey].(json.Number); ok {
+				if v, err := n.Int64(); err == nil {
+					count = v
+				}
+			}
+			until := m[CompactedUntilKey]
+			if until == nil {
+				until = m[slog.TimeKey]
+			}
+			fp := s.fingerprint(m)
+			if sum, ok := index[fp]; ok {
+				sum.count += count
+				sum.until = until
+				continue
+			}
+			sum := &summary{m: m, count: count, until: until}
+			index[fp] = sum
+			summaries = append(summaries, sum)
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, sum := range summaries {
+		if sum.count > 1 {
+			sum.m[CompactedCountKey] = sum.count
+			sum.m[CompactedUntilKey] = sum.until
+		}
+		line, err := json.Marshal(sum.m)
+		if err != nil {
+			return err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	first := segments[0]
+	path := filepath.Join(b.dir, fmt.Sprintf("%020d%s%s", first.seq, compactedSuffix, segmentSuffix))
+	if err := os.WriteFile(path+".tmp", buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		_ = os.Remove(path + ".tmp")
+		return err
+	}
+	compacted := &segment{
+		seq:       first.seq,
+		path:      path,
+		size:      int64(buf.Len()),
+		records:   len(summaries),
+		modified:  segments[len(segments)-1].modified,
+		compacted: true,
+	}
+	for _, seg := range segments {
+		if seg.path != path {
+			s.remove(b, seg)
+		} else {
+			// Replaced by the rename above.
+			b.segments = slices.DeleteFunc(b.segments, func(x *segment) bool { return x == seg })
+			b.size -= seg.size
+			s.size -= seg.size
+		}
+	}
+	b.segments = slices.Insert(b.segments, min(position, len(b.segments)), compacted)
+	b.size += compacted.size
+	s.size += compacted.size
+	s.compacted += uint64(records - len(summaries))
+	return nil
+}
+
+// fingerprint identifies the records compacted together.
+func (s *state) fingerprint(m map[string]any) string {
+	var b strings.Builder
+	fmt.Fprint(&b, m[slog.LevelKey], "\x00", m[slog.MessageKey])
+	for _, k := range s.config.compactKeys {
+		fmt.Fprint(&b, "\x00", k, "=", m[k])
+	}
+	return b.String()
+}
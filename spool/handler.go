@@ -0,0 +1,661 @@
+// Package spool provides a slog.Handler storing records on disk until they
+// are forwarded to another handler, for programs that reach their log
+// destination only intermittently.
+package spool
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikluko/slogging"
+	"github.com/mikluko/slogging/internal/jsonvalue"
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+const (
+	defaultCapacity    = 64 << 20
+	defaultSegmentSize = 1 << 20
+	segmentSuffix      = ".jsonl"
+	compactedSuffix    = ".c"
+)
+
+// ErrClosed is returned by Handle and Forward after the spool has been closed.
+var ErrClosed = errors.New("slogging: spool is closed")
+
+// defaultBands are the levels of the priority bands every spool has.
+var defaultBands = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// Retention bounds the records of a priority band kept on disk. Records
+// are discarded a segment at a time, so segments should be much smaller
+// than MaxBytes.
+type Retention struct {
+	// MaxAge discards records written longer ago. Zero keeps records
+	// regardless of their age.
+	MaxAge time.Duration
+	// MaxBytes discards the oldest records of the band beyond this size.
+	// Zero bounds the band by the capacity of the spool only.
+	MaxBytes int64
+}
+
+// segment is a file of JSON lines holding records of one band.
+type segment struct {
+	seq       uint64
+	path      string
+	size      int64
+	records   int
+	delivered int // Records at the start of the segment already forwarded
+	modified  time.Time
+	file      *os.File // Open for appending while the segment is active
+	compacted bool     // Holds the summaries written by compaction
+}
+
+// band holds the segments of the records from its level up to the level of
+// the next band, oldest first. The last segment is active if its file is
+// open.
+type band struct {
+	level     slog.Level
+	retention Retention
+	dir       string
+	segments  []*segment
+	size      int64
+}
+
+func (b *band) active() *segment {
+	if n := len(b.segments); n > 0 && b.segments[n-1].file != nil {
+		return b.segments[n-1]
+	}
+	return nil
+}
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	dir    string
+	config handlerOptions
+
+	forwardMutex sync.Mutex // Serializes Forward calls
+
+	mutex      sync.Mutex
+	bands      []*band // By level, lowest first
+	seq        uint64
+	size       int64
+	dropped    uint64
+	compacted  uint64
+	forwarding *segment // Segment being read by Forward, left alone by compaction
+	closed     bool
+	now        func() time.Time
+}
+
+// Handler is a slog.Handler appending records as JSON lines to segment
+// files under a directory, until Forward delivers them to another handler.
+// Records are kept in priority bands by level, debug, info, warn and
+// error by default, each in a directory of its own. Forward delivers the
+// highest band first, so the most valuable records get through first when
+// a connection comes back, and the spool discards the lowest band first
+// when it reaches its capacity. Bands can be given a retention of their
+// own with WithRetention.
+//
+// Records survive restarts. Delivery is at least once: records forwarded
+// from a segment that was not completely forwarded before a restart are
+// forwarded again. Attribute values are stored as JSON, so they are
+// forwarded as strings, numbers, booleans and groups.
+type Handler struct {
+	scope scope.Scope
+	state *state
+}
+
+// Open opens the spool in dir, creating it if needed, and loads the
+// records left in it.
+func Open(dir string, options ...Option) (*Handler, error) {
+	config := handlerOptions{
+		level:       slog.LevelInfo,
+		capacity:    defaultCapacity,
+		segmentSize: defaultSegmentSize,
+		retention:   map[slog.Level]Retention{},
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	s := &state{dir: dir, config: config, now: time.Now}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return &Handler{state: s}, nil
+}
+
+// load creates the band directories and indexes the segments found in
+// them, including those of bands no longer configured.
+func (s *state) load() error {
+	levels := slices.Clone(defaultBands)
+	for level := range s.config.retention {
+		levels = append(levels, level)
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("error when opening spool: %w", err)
+	}
+	for _, e := range entries {
+		var level slog.Level
+		if e.IsDir() && level.UnmarshalText([]byte(e.Name())) == nil {
+			levels = append(levels, level)
+		}
+	}
+	slices.Sort(levels)
+	for _, level := range slices.Compact(levels) {
+		b := &band{
+			level:     level,
+			retention: s.config.retention[level],
+			dir:       filepath.Join(s.dir, level.String()),
+		}
+		if err := os.MkdirAll(b.dir, 0o755); err != nil {
+			return fmt.Errorf("error when creating spool: %w", err)
+		}
+		if err := s.loadBand(b); err != nil {
+			return err
+		}
+		s.bands = append(s.bands, b)
+	}
+	return nil
+}
+
+func (s *state) loadBand(b *band) error {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return fmt.Errorf("error when opening spool: %w", err)
+	}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), segmentSuffix)
+		compacted := strings.HasSuffix(name, compactedSuffix)
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, compactedSuffix), 10, 64)
+		if e.IsDir() || !strings.HasSuffix(e.Name(), segmentSuffix) || err != nil {
+			continue
+		}
+		path := filepath.Join(b.dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("error when opening spool: %w", err)
+		}
+		info, err := e.Info()
+		if err != nil {
+			return fmt.Errorf("error when opening spool: %w", err)
+		}
+		b.segments = append(b.segments, &segment{
+			seq:       seq,
+			path:      path,
+			compacted: compacted,
+			size:      int64(len(data)),
+			records:   bytes.Count(data, []byte{'\n'}),
+			modified:  info.ModTime(),
+		})
+		b.size += int64(len(data))
+		s.size += int64(len(data))
+		s.seq = max(s.seq, seq)
+	}
+	slices.SortFunc(b.segments, func(x, y *segment) int { return cmp.Compare(x.seq, y.seq) })
+	return nil
+}
+
+// Enabled reports whether the level is at or above the minimum level.
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.state.config.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+// Handle appends the record to the active segment of its band, then
+// discards the records beyond the retention of the bands and the capacity
+// of the spool.
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	m := h.toMap(r)
+	jsonvalue.Map(m)
+	line, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("error when encoding spooled record: %w", err)
+	}
+	line = append(line, '\n')
+	return h.state.append(r.Level, line)
+}
+
+// toMap returns the record as a map holding its attributes and those of
+// WithAttrs calls, nested under the groups of WithGroup calls, and the
+// built-in keys as read by slogging.FromMap.
+func (h *Handler) toMap(r slog.Record) map[string]any {
+	top := make(map[string]any, r.NumAttrs()+3)
+	slogging.PutAttrs(top, h.scope.Attrs(r)...)
+	delete(top, slog.TimeKey)
+	if !r.Time.IsZero() {
+		top[slog.TimeKey] = r.Time
+	}
+	top[slog.LevelKey] = r.Level
+	top[slog.MessageKey] = r.Message
+	return top
+}
+
+func (s *state) append(level slog.Level, line []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return ErrClosed
+	}
+	now := s.now()
+	b := s.band(level)
+	seg := b.active()
+	if seg == nil {
+		var err error
+		if seg, err = s.create(b); err != nil {
+			return err
+		}
+	}
+	n, err := seg.file.Write(line)
+	seg.size += int64(n)
+	b.size += int64(n)
+	s.size += int64(n)
+	seg.records++
+	seg.modified = now
+	if err != nil {
+		return fmt.Errorf("error when writing to spool: %w", err)
+	}
+	if seg.size >= s.config.segmentSize {
+		s.seal(seg)
+	}
+	s.enforce(now)
+	return nil
+}
+
+// band returns the band of records at the given level. Must be called with
+// the mutex held.
+func (s *state) band(level slog.Level) *band {
+	for i := len(s.bands) - 1; i > 0; i-- {
+		if level >= s.bands[i].level {
+			return s.bands[i]
+		}
+	}
+	return s.bands[0]
+}
+
+// create opens a new active segment in the band. Must be called with the
+// mutex held.
+func (s *state) create(b *band) (*segment, error) {
+	s.seq++
+	path := filepath.Join(b.dir, fmt.Sprintf("%020d%s", s.seq, segmentSuffix))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("error when creating spool segment: %w", err)
+	}
+	seg := &segment{seq: s.seq, path: path, file: f, modified: s.now()}
+	b.segments = append(b.segments, seg)
+	return seg, nil
+}
+
+// seal closes the file of an active segment. Must be called with the mutex
+// held.
+func (s *state) seal(seg *segment) {
+	if seg.file == nil {
+		return
+	}
+	if err := seg.file.Close(); err != nil {
+		s.report(fmt.Errorf("error when closing spool segment: %w", err))
+	}
+	seg.file = nil
+}
+
+// enforce discards the segments beyond the retention of their band, then
+// compacts and discards records beyond the capacity of the spool, the
+// lowest band first. Must be called with the mutex held.
+func (s *state) enforce(now time.Time) {
+	for _, b := range s.bands {
+		if age := b.retention.MaxAge; age > 0 {
+			for len(b.segments) > 0 && now.Sub(b.segments[0].modified) > age {
+				s.drop(b)
+			}
+		}
+		if limit := b.retention.MaxBytes; limit > 0 {
+			for len(b.segments) > 0 && b.size > limit {
+				s.drop(b)
+			}
+		}
+	}
+	s.compact()
+	for _, b := range s.bands {
+		for s.config.capacity > 0 && s.size > s.config.capacity && len(b.segments) > 0 {
+			s.drop(b)
+		}
+	}
+}
+
+// drop discards the oldest segment of the band. Must be called with the
+// mutex held.
+func (s *state) drop(b *band) {
+	seg := b.segments[0]
+	s.seal(seg)
+	s.dropped += uint64(seg.records - seg.delivered)
+	s.remove(b, seg)
+}
+
+// remove deletes the segment from the band and the disk. Must be called
+// with the mutex held.
+func (s *state) remove(b *band, seg *segment) {
+	i := slices.Index(b.segments, seg)
+	if i < 0 {
+		return
+	}
+	b.segments = slices.Delete(b.segments, i, i+1)
+	b.size -= seg.size
+	s.size -= seg.size
+	if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.report(fmt.Errorf("error when removing spool segment: %w", err))
+	}
+}
+
+func (s *state) report(err error) {
+	if s.config.onError != nil {
+		s.config.onError(err)
+	}
+}
+
+// Forward delivers the spooled records to target, the highest band first
+// and oldest first within a band, and removes them from the spool. Records
+// spooled while Forward runs are left for the next call. It stops at the
+// first error returned by target, or when ctx is done, and returns the
+// error; the next call resumes with the record that failed. Records
+// target is not enabled for are discarded.
+func (h *Handler) Forward(ctx context.Context, target slog.Handler) error {
+	s := h.state
+	s.forwardMutex.Lock()
+	defer s.forwardMutex.Unlock()
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return ErrClosed
+	}
+	for _, b := range s.bands {
+		if seg := b.active(); seg != nil {
+			s.seal(seg)
+		}
+	}
+	s.enforce(s.now())
+	limit := s.seq
+	s.mutex.Unlock()
+	defer func() {
+		s.mutex.Lock()
+		s.forwarding = nil
+		s.mutex.Unlock()
+	}()
+
+	for {
+		b, seg, skip := s.next(limit)
+		if seg == nil {
+			return nil
+		}
+		if err := s.forward(ctx, target, b, seg, skip); err != nil {
+			return err
+		}
+	}
+}
+
+// next returns the next segment to forward, up to the given sequence
+// number, and the number of its records already forwarded.
+func (s *state) next(limit uint64) (*band, *segment, int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.forwarding = nil
+	for i := len(s.bands) - 1; i >= 0; i-- {
+		b := s.bands[i]
+		if len(b.segments) > 0 && b.segments[0].seq <= limit && b.segments[0].file == nil {
+			s.forwarding = b.segments[0]
+			return b, b.segments[0], b.segments[0].delivered
+		}
+	}
+	return nil, nil, 0
+}
+
+func (s *state) forward(ctx context.Context, target slog.Handler, b *band, seg *segment, skip int) error {
+	f, err := os.Open(seg.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.mutex.Lock()
+		s.remove(b, seg)
+		s.mutex.Unlock()
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error when reading spool segment: %w", err)
+	}
+	defer f.Close()
+	reader := bufio.NewReader(f)
+	for i := 0; ; i++ {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("error when reading spool segment: %w", err)
+		}
+		if i < skip {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			s.progress(seg, i)
+			return err
+		}
+		r, err := decode(line)
+		if err != nil {
+			s.report(fmt.Errorf("error when decoding spooled record: %w", err))
+			continue
+		}
+		if !target.Enabled(ctx, r.Level) {
+			continue
+		}
+		if err := target.Handle(ctx, r); err != nil {
+			s.progress(seg, i)
+			return fmt.Errorf("error when forwarding spooled record: %w", err)
+		}
+	}
+	s.mutex.Lock()
+	s.remove(b, seg)
+	s.mutex.Unlock()
+	return nil
+}
+
+func (s *state) progress(seg *segment, delivered int) {
+	s.mutex.Lock()
+	seg.delivered = delivered
+	s.mutex.Unlock()
+}
+
+// decode reads a record from a spooled line. Integers are restored as
+// int64, other numbers as float64.
+func decode(line []byte) (slog.Record, error) {
+	d := json.NewDecoder(bytes.NewReader(line))
+	d.UseNumber()
+	var m map[string]any
+	if err := d.Decode(&m); err != nil {
+		return slog.Record{}, err
+	}
+	return slogging.FromMap(numbers(m)), nil
+}
+
+func numbers(m map[string]any) map[string]any {
+	for k, v := range m {
+		switch v := v.(type) {
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				m[k] = n
+			} else if f, err := v.Float64(); err == nil {
+				m[k] = f
+			}
+		case map[string]any:
+			numbers(v)
+		}
+	}
+	return m
+}
+
+// Pending returns the number of records waiting to be forwarded.
+func (h *Handler) Pending() int {
+	s := h.state
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := 0
+	for _, b := range s.bands {
+		for _, seg := range b.segments {
+			n += seg.records - seg.delivered
+		}
+	}
+	return n
+}
+
+// Compacted returns the number of records removed by compaction, not
+// counting the records left in their place.
+func (h *Handler) Compacted() uint64 {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	return h.state.compacted
+}
+
+// Size returns the number of bytes the spooled records occupy on disk.
+func (h *Handler) Size() int64 {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	return h.state.size
+}
+
+// Dropped returns the number of records discarded before they were
+// forwarded, for exceeding the retention of their band or the capacity of
+// the spool.
+func (h *Handler) Dropped() uint64 {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	return h.state.dropped
+}
+
+// Close closes the active segments. The records stay on disk for the next
+// Open.
+func (h *Handler) Close() error {
+	s := h.state
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	var errs []error
+	for _, b := range s.bands {
+		if seg := b.active(); seg != nil {
+			errs = append(errs, seg.file.Close())
+			seg.file = nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+type handlerOptions struct {
+	level        slog.Leveler
+	capacity     int64
+	segmentSize  int64
+	retention    map[slog.Level]Retention
+	compaction   float64
+	compactBelow slog.Level
+	compactKeys  []string
+	onError      func(error)
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the minimum level of spooled records. Defaults to
+// slog.LevelInfo.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithCapacity bounds the bytes the spool occupies on disk. Beyond it the
+// oldest records of the lowest band are discarded. Values below 1 disable
+// the bound. Defaults to 64 MiB.
+func WithCapacity(n int64) Option {
+	return func(h *handlerOptions) {
+		h.capacity = n
+	}
+}
+
+// WithSegmentSize sets the size at which segments are closed and a new one
+// started. Records are discarded and forwarded a segment at a time.
+// Defaults to 1 MiB.
+func WithSegmentSize(n int64) Option {
+	return func(h *handlerOptions) {
+		h.segmentSize = max(n, 1)
+	}
+}
+
+// WithRetention sets the retention of the band starting at level, adding
+// the band if it is not one of the defaults.
+func WithRetention(level slog.Level, r Retention) Option {
+	return func(h *handlerOptions) {
+		h.retention[level] = r
+	}
+}
+
+// WithCompaction compacts the records of the bands below the given level
+// once the spool grows beyond threshold times its capacity, e.g. 0.8, the
+// lowest band first, before any record is discarded for lack of space.
+// Records sharing a fingerprint, their level, message and the values of
+// the keys set with WithCompactionKeys, are replaced with the first of
+// them carrying CompactedCountKey and CompactedUntilKey, so that what
+// was logged survives space pressure in summarized form while records at
+// or above the level are kept verbatim. Compaction is disabled by default.
+func WithCompaction(threshold float64, below slog.Level) Option {
+	return func(h *handlerOptions) {
+		h.compaction = threshold
+		h.compactBelow = below
+	}
+}
+
+// WithCompactionKeys includes the values of the given top-level attributes
+// in the fingerprint of compacted records.
+func WithCompactionKeys(keys ...string) Option {
+	return func(h *handlerOptions) {
+		h.compactKeys = append(h.compactKeys, keys...)
+	}
+}
+
+// WithErrorHandler sets a function called with errors that do not fail the
+// operation under way, such as records that cannot be decoded and segments
+// that cannot be removed.
+func WithErrorHandler(fn func(error)) Option {
+	return func(h *handlerOptions) {
+		h.onError = fn
+	}
+}
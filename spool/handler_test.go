@@ -0,0 +1,226 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikluko/slogging"
+)
+
+// collector records forwarded records, failing once fail records have
+// been collected.
+type collector struct {
+	records []slog.Record
+	fail    int
+}
+
+func (c *collector) Enabled(context.Context, slog.Level) bool { return true }
+func (c *collector) WithAttrs([]slog.Attr) slog.Handler       { return c }
+func (c *collector) WithGroup(string) slog.Handler            { return c }
+
+func (c *collector) Handle(_ context.Context, r slog.Record) error {
+	if c.fail > 0 && len(c.records) >= c.fail {
+		return errors.New("offline")
+	}
+	c.records = append(c.records, r)
+	return nil
+}
+
+func (c *collector) messages() []string {
+	var out []string
+	for _, r := range c.records {
+		out = append(out, r.Message)
+	}
+	return out
+}
+
+type stringer string
+
+func (s stringer) String() string { return "stringer " + string(s) }
+
+func Test_Handler(t *testing.T) {
+	t.Run("records are forwarded by priority", func(t *testing.T) {
+		h, err := Open(t.TempDir(), WithLevel(slog.LevelDebug))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer h.Close()
+		logger := slog.New(h)
+		logger.Debug("d1")
+		logger.Info("i1")
+		logger.Error("e1")
+		logger.Warn("w1")
+		logger.Info("i2")
+		logger.Log(context.Background(), slog.LevelError+4, "e2")
+		logger.With("svc", "api").WithGroup("req").Info("attrs", "n", 42, "f", 1.5, "err", errors.New("boom"), "s", stringer("x"), slog.Group("g", "ok", true))
+
+		c := &collector{}
+		if err := h.Forward(context.Background(), c); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := c.messages(), []string{"e1", "e2", "w1", "i1", "i2", "attrs", "d1"}; !slices.Equal(got, want) {
+			t.Errorf("unexpected order: %v", got)
+		}
+		got := slogging.ToMap(c.records[5])
+		if got["svc"] != "api" {
+			t.Errorf("unexpected attributes: %v", got)
+		}
+		req := got["req"].(map[string]any)
+		if req["n"] != int64(42) || req["f"] != 1.5 || req["err"] != "boom" || req["s"] != "stringer x" || req["g"].(map[string]any)["ok"] != true {
+			t.Errorf("unexpected attributes: %v", got)
+		}
+		if h.Pending() != 0 || h.Size() != 0 {
+			t.Errorf("expected an empty spool, got %d records in %d bytes", h.Pending(), h.Size())
+		}
+	})
+
+	t.Run("forwarding resumes after errors and restarts", func(t *testing.T) {
+		dir := t.TempDir()
+		h, err := Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range 5 {
+			slog.New(h).Info("msg", "i", i)
+		}
+		c := &collector{fail: 2}
+		if err := h.Forward(context.Background(), c); err == nil || !strings.Contains(err.Error(), "offline") {
+			t.Fatalf("expected forwarding error, got %v", err)
+		}
+		if h.Pending() != 3 {
+			t.Errorf("expected 3 pending records, got %d", h.Pending())
+		}
+		c.fail = 0
+		if err := h.Forward(context.Background(), c); err != nil {
+			t.Fatal(err)
+		}
+		slog.New(h).Info("after restart")
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := h.Forward(context.Background(), c); !errors.Is(err, ErrClosed) {
+			t.Errorf("expected ErrClosed, got %v", err)
+		}
+
+		h, err = Open(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer h.Close()
+		if h.Pending() != 1 {
+			t.Fatalf("expected one pending record after reopening, got %d", h.Pending())
+		}
+		if err := h.Forward(context.Background(), c); err != nil {
+			t.Fatal(err)
+		}
+		if len(c.records) != 6 || c.records[4].Message != "msg" || c.records[5].Message != "after restart" {
+			t.Errorf("unexpected records: %v", c.messages())
+		}
+	})
+
+	t.Run("capacity discards the lowest band first", func(t *testing.T) {
+		h, err := Open(t.TempDir(), WithLevel(slog.LevelDebug), WithCapacity(1000), WithSegmentSize(100))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer h.Close()
+		logger := slog.New(h)
+		for range 10 {
+			logger.Warn("warning")
+		}
+		for range 20 {
+			logger.Debug("debug")
+		}
+		if h.Size() > 1000 || h.Dropped() == 0 {
+			t.Fatalf("expected the spool within capacity, got %d bytes, %d dropped", h.Size(), h.Dropped())
+		}
+		c := &collector{}
+		_ = h.Forward(context.Background(), c)
+		if n := strings.Count(strings.Join(c.messages(), " "), "warning"); n != 10 {
+			t.Errorf("expected every warning to survive, got %d", n)
+		}
+		if uint64(len(c.records))+h.Dropped() != 30 {
+			t.Errorf("expected every record forwarded or dropped, got %d and %d", len(c.records), h.Dropped())
+		}
+	})
+
+	t.Run("retention by band", func(t *testing.T) {
+		h, err := Open(t.TempDir(), WithSegmentSize(1), WithRetention(slog.LevelInfo, Retention{MaxAge: time.Hour}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer h.Close()
+		now := time.Now()
+		h.state.now = func() time.Time { return now }
+		logger := slog.New(h)
+		logger.Info("old")
+		logger.Warn("old warning")
+		now = now.Add(2 * time.Hour)
+		logger.Info("new")
+		c := &collector{}
+		_ = h.Forward(context.Background(), c)
+		if got := c.messages(); !slices.Equal(got, []string{"old warning", "new"}) || h.Dropped() != 1 {
+			t.Errorf("unexpected records: %v, %d dropped", got, h.Dropped())
+		}
+	})
+
+	t.Run("compaction keeps warnings verbatim", func(t *testing.T) {
+		dir := t.TempDir()
+		h, err := Open(dir, WithLevel(slog.LevelDebug), WithCapacity(4000), WithSegmentSize(300), WithCompaction(0.5, slog.LevelWarn), WithCompactionKeys("sensor"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger := slog.New(h)
+		for i := range 60 {
+			logger.Debug("poll", "sensor", i%2, "i", i)
+			if i%20 == 0 {
+				logger.Warn("threshold", "i", i)
+			}
+		}
+		if h.Dropped() != 0 || h.Compacted() == 0 {
+			t.Fatalf("expected compaction without drops, got %d dropped, %d compacted", h.Dropped(), h.Compacted())
+		}
+		if err := h.Close(); err != nil {
+			t.Fatal(err)
+		}
+		h, err = Open(dir, WithLevel(slog.LevelDebug))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer h.Close()
+		c := &collector{}
+		if err := h.Forward(context.Background(), c); err != nil {
+			t.Fatal(err)
+		}
+		var warnings, polls int64
+		for _, r := range c.records {
+			m := slogging.ToMap(r)
+			switch r.Message {
+			case "threshold":
+				warnings++
+				if _, ok := m[CompactedCountKey]; ok {
+					t.Errorf("warnings must not be compacted: %v", m)
+				}
+			case "poll":
+				n, ok := m[CompactedCountKey].(int64)
+				if !ok {
+					n = 1
+				} else if _, ok := m[CompactedUntilKey].(string); !ok {
+					t.Errorf("expected the time of the last compacted record: %v", m)
+				}
+				polls += n
+			}
+		}
+		if warnings != 3 || polls != 60 {
+			t.Errorf("expected every record accounted for, got %d warnings and %d polls", warnings, polls)
+		}
+		if len(c.records) >= 63 {
+			t.Errorf("expected fewer records after compaction, got %d", len(c.records))
+		}
+	})
+}
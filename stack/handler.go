@@ -0,0 +1,197 @@
+package stack
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Key is the attribute holding the stack trace.
+const Key = "stack"
+
+const defaultDepth = 32
+
+// Format defines how a stack trace is rendered.
+type Format int
+
+const (
+	// String renders the stack trace as a single string in the format of
+	// runtime/debug.Stack: the function on one line, followed by the
+	// indented file and line on the next.
+	String Format = iota
+	// Frames renders the stack trace as a group with one group per frame,
+	// keyed by frame index, holding "function", "file" and "line".
+	Frames
+)
+
+// Value renders the stack trace given by program counters, as returned by
+// runtime.Callers, in the given format.
+func Value(pcs []uintptr, f Format) slog.Value {
+	return render(frames(pcs, false), f)
+}
+
+func frames(pcs []uintptr, skipRuntime bool) []runtime.Frame {
+	var out []runtime.Frame
+	it := runtime.CallersFrames(pcs)
+	for {
+		frame, more := it.Next()
+		if !skipRuntime || !strings.HasPrefix(frame.Function, "runtime.") {
+			out = append(out, frame)
+		}
+		if !more {
+			return out
+		}
+	}
+}
+
+func render(frames []runtime.Frame, f Format) slog.Value {
+	if f == Frames {
+		attrs := make([]slog.Attr, len(frames))
+		for i, frame := range frames {
+			attrs[i] = slog.Group(strconv.Itoa(i),
+				slog.String("function", frame.Function),
+				slog.String("file", frame.File),
+				slog.Int("line", frame.Line),
+			)
+		}
+		return slog.GroupValue(attrs...)
+	}
+	var b strings.Builder
+	for _, frame := range frames {
+		fmt.Fprintf(&b, "%s\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	return slog.StringValue(b.String())
+}
+
+// Handler is a slog.Handler that attaches the stack trace of the logging
+// goroutine as the "stack" attribute to records at or above a level.
+// Records already holding a "stack" attribute are left unchanged.
+type Handler struct {
+	handler slog.Handler
+	config  handlerOptions
+}
+
+// Wrap creates a handler attaching stack traces to records at or above
+// Error, unless configured otherwise, before delivering them to handler.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{
+		level:        slog.LevelError,
+		depth:        defaultDepth,
+		skipInternal: true,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{handler: handler, config: config}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle attaches the stack trace to the record if its level is at or
+// above the configured one and delivers it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < h.config.level.Level() || hasStack(r) {
+		return h.handler.Handle(ctx, r)
+	}
+	r = r.Clone()
+	r.AddAttrs(slog.Attr{Key: Key, Value: render(h.capture(r.PC), h.config.format)})
+	return h.handler.Handle(ctx, r)
+}
+
+// capture returns the frames of the calling goroutine. With internal frames
+// skipped, the stack starts at the call site of the record, given by pc,
+// so that the frames of the logger and the handler chain are omitted, and
+// runtime frames are dropped.
+func (h *Handler) capture(pc uintptr) []runtime.Frame {
+	// Overcapture to make room for the frames of the handler chain.
+	pcs := make([]uintptr, h.config.depth+64)
+	n := runtime.Callers(3, pcs)
+	pcs = pcs[:n]
+	if h.config.skipInternal && pc != 0 {
+		for i := range pcs {
+			if pcs[i] == pc {
+				pcs = pcs[i:]
+				break
+			}
+		}
+	}
+	out := frames(pcs, h.config.skipInternal)
+	if len(out) > h.config.depth {
+		out = out[:h.config.depth]
+	}
+	return out
+}
+
+func hasStack(r slog.Record) (found bool) {
+	r.Attrs(func(a slog.Attr) bool {
+		found = a.Key == Key
+		return !found
+	})
+	return found
+}
+
+// WithAttrs returns a new Handler whose wrapped handler includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &Handler{handler: h.handler.WithAttrs(attrs), config: h.config}
+}
+
+// WithGroup returns a new Handler whose wrapped handler starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &Handler{handler: h.handler.WithGroup(name), config: h.config}
+}
+
+type handlerOptions struct {
+	level        slog.Leveler
+	depth        int
+	skipInternal bool
+	format       Format
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithLevel sets the level at and above which stack traces are attached.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithDepth sets the maximum number of frames. Values below 1 are treated as 1.
+func WithDepth(n int) Option {
+	return func(h *handlerOptions) {
+		h.depth = max(n, 1)
+	}
+}
+
+// WithSkipInternal sets whether the frames of the logger, the handler chain
+// and the runtime are omitted. It is enabled by default.
+func WithSkipInternal(x ...bool) Option {
+	return func(h *handlerOptions) {
+		h.skipInternal = true
+		for i := range x {
+			h.skipInternal = x[i]
+		}
+	}
+}
+
+// WithFormat sets how stack traces are rendered.
+func WithFormat(f Format) Option {
+	return func(h *handlerOptions) {
+		h.format = f
+	}
+}
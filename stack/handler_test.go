@@ -0,0 +1,97 @@
+package stack
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func logError(logger *slog.Logger) {
+	logger.Error("failed")
+}
+
+func Test_Stack(t *testing.T) {
+	t.Run("string stack starts at call site", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewJSONHandler(buf, nil)))
+
+		logError(logger)
+		logger.Info("done")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+			t.Fatal(err)
+		}
+		s, _ := rec[Key].(string)
+		if !strings.HasPrefix(s, "github.com/mikluko/slogging/stack.logError\n\t") {
+			t.Errorf("expected stack to start at call site, got: %s", s)
+		}
+		if strings.Contains(s, "log/slog") || strings.Contains(s, "runtime.") || strings.Contains(s, "Handle") {
+			t.Errorf("expected internal frames to be skipped, got: %s", s)
+		}
+		if strings.Contains(lines[1], Key) {
+			t.Errorf("unexpected stack on info record: %s", lines[1])
+		}
+	})
+
+	t.Run("structured frames with depth", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewJSONHandler(buf, nil), WithFormat(Frames), WithDepth(2), WithLevel(slog.LevelWarn)))
+
+		logger.WithGroup("g").Warn("slow")
+
+		var rec struct {
+			G struct {
+				Stack map[string]struct {
+					Function string
+					File     string
+					Line     int
+				}
+			}
+		}
+		if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if len(rec.G.Stack) != 2 {
+			t.Fatalf("expected 2 frames, got: %s", buf.String())
+		}
+		if f := rec.G.Stack["0"]; f.Function != "github.com/mikluko/slogging/stack.Test_Stack.func2" || !strings.HasSuffix(f.File, "handler_test.go") || f.Line == 0 {
+			t.Errorf("unexpected frame: %+v", f)
+		}
+	})
+
+	t.Run("internal frames kept on request", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil), WithSkipInternal(false)))
+
+		logger.Error("failed")
+
+		if !strings.Contains(buf.String(), "log/slog.(*Logger).log") {
+			t.Errorf("expected slog frames, got: %s", buf.String())
+		}
+	})
+
+	t.Run("existing stack is kept", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(Wrap(slog.NewTextHandler(buf, nil)))
+
+		logger.Error("failed", Key, "custom")
+
+		if strings.Count(buf.String(), "stack=") != 1 || !strings.Contains(buf.String(), "stack=custom") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("value renders program counters", func(t *testing.T) {
+		pcs := make([]uintptr, 1)
+		runtime.Callers(1, pcs)
+		v := Value(pcs, String)
+		if !strings.HasPrefix(v.String(), "github.com/mikluko/slogging/stack.Test_Stack.func5") {
+			t.Errorf("unexpected value: %s", v)
+		}
+	})
+}
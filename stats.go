@@ -0,0 +1,22 @@
+package slogging
+
+import "time"
+
+// Stats is a snapshot of the health of a handler buffering or shipping
+// records, as returned by StatsReporter.
+type Stats struct {
+	Handled       uint64    // Records passed to the handler
+	Dropped       uint64    // Records discarded, see the Dropped method of the handler
+	Errors        uint64    // Failed deliveries, after retries
+	QueueDepth    int       // Records waiting to be delivered
+	LastError     error     // Error of the last failed delivery
+	LastErrorTime time.Time // Time of the last failed delivery
+}
+
+// StatsReporter is implemented by handlers that buffer, batch or ship
+// records, such as async, loki, cloudwatch, kafka, bigquery, sentry and
+// alert handlers, to monitor the logging pipeline itself. The counters
+// are shared by the handlers derived with WithAttrs and WithGroup.
+type StatsReporter interface {
+	Stats() Stats
+}
@@ -0,0 +1,85 @@
+package suppress
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/mikluko/slogging/filter"
+	"github.com/mikluko/slogging/internal/scope"
+)
+
+// Handler is a slog.Handler dropping the records matched by the rules of a
+// list, counting them per rule, and delivering the others to the wrapped
+// handler. Expressions see the attributes added via WithAttrs and
+// WithGroup too.
+type Handler struct {
+	handler slog.Handler
+	list    *List
+	scope   scope.Scope
+}
+
+// Wrap creates a handler muting the records matched by list.
+func Wrap(handler slog.Handler, list *List) *Handler {
+	return &Handler{handler: handler, list: list}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle drops the record if a rule matches it, or delivers it.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	entries := *h.list.entries.Load()
+	if len(entries) == 0 {
+		return h.handler.Handle(ctx, r)
+	}
+	now := h.list.now()
+	var fr *filter.Record
+	for _, e := range entries {
+		if !e.rule.Expires.After(now) {
+			continue
+		}
+		if e.where != nil && fr == nil {
+			fr = h.view(r)
+		}
+		if e.match(r, fr) {
+			e.suppressed.Add(1)
+			return nil
+		}
+	}
+	return h.handler.Handle(ctx, r)
+}
+
+// view returns the record expressions are evaluated on, with the
+// attributes of the scope.
+func (h *Handler) view(r slog.Record) *filter.Record {
+	if h.scope.Empty() {
+		return filter.NewRecord(r)
+	}
+	nr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	nr.AddAttrs(h.scope.Attrs(r)...)
+	return filter.NewRecord(nr)
+}
+
+// WithAttrs returns a new Handler whose wrapped handler has the attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler whose wrapped handler has the group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
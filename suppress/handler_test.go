@@ -0,0 +1,104 @@
+package suppress
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Handler(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	setup := func() (*List, *slog.Logger, *bytes.Buffer) {
+		list := NewList()
+		list.now = func() time.Time { return now }
+		buf := new(bytes.Buffer)
+		return list, slog.New(Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{ReplaceAttr: dropTime}), list)), buf
+	}
+
+	t.Run("fingerprint", func(t *testing.T) {
+		list, logger, buf := setup()
+		rule, err := list.Mute("connection to 10.0.0.1:5432 refused", time.Minute, "db failover")
+		if err != nil {
+			t.Fatal(err)
+		}
+		logger.Error("connection to 10.0.0.2:5432 refused")
+		logger.Error("connection to 10.0.0.3:5432 refused")
+		logger.Error("query failed")
+		if got := strings.TrimSpace(buf.String()); got != "level=ERROR msg=\"query failed\"" {
+			t.Errorf("got %s", got)
+		}
+		rules := list.Rules()
+		if len(rules) != 1 || rules[0].ID != rule.ID || rules[0].Suppressed != 2 || rules[0].Reason != "db failover" {
+			t.Errorf("Rules() = %+v", rules)
+		}
+	})
+
+	t.Run("pattern and expression", func(t *testing.T) {
+		list, logger, buf := setup()
+		if _, err := list.Add(Rule{Pattern: "^cache", Where: `level < ERROR && db.host == "replica-3"`, Expires: now.Add(time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+		logger.WithGroup("db").With("host", "replica-3").Warn("cache miss")
+		logger.WithGroup("db").With("host", "replica-1").Warn("cache miss")
+		logger.WithGroup("db").With("host", "replica-3").Error("cache miss")
+		want := "level=WARN msg=\"cache miss\" db.host=replica-1\nlevel=ERROR msg=\"cache miss\" db.host=replica-3"
+		if got := strings.TrimSpace(buf.String()); got != want {
+			t.Errorf("\nwant %s\ngot  %s", want, got)
+		}
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		list, logger, buf := setup()
+		if _, err := list.Mute("noisy", time.Minute, ""); err != nil {
+			t.Fatal(err)
+		}
+		logger.Info("noisy")
+		now = now.Add(time.Minute)
+		logger.Info("noisy")
+		if got := strings.TrimSpace(buf.String()); got != "level=INFO msg=noisy" {
+			t.Errorf("got %s", got)
+		}
+		if rules := list.Rules(); len(rules) != 0 {
+			t.Errorf("Rules() = %+v", rules)
+		}
+	})
+
+	t.Run("replace and remove", func(t *testing.T) {
+		list, _, _ := setup()
+		_, _ = list.Add(Rule{ID: "a", Pattern: "x", Expires: now.Add(time.Minute)})
+		_, _ = list.Add(Rule{ID: "a", Pattern: "y", Expires: now.Add(time.Minute)})
+		if rules := list.Rules(); len(rules) != 1 || rules[0].Pattern != "y" {
+			t.Errorf("Rules() = %+v", rules)
+		}
+		if _, ok := list.Remove("a"); !ok {
+			t.Error("Remove() = false")
+		}
+		if _, ok := list.Remove("a"); ok {
+			t.Error("Remove() twice = true")
+		}
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		list, _, _ := setup()
+		for _, rule := range []Rule{
+			{Expires: now.Add(time.Minute)},
+			{Pattern: "x", Expires: now},
+			{Pattern: "(", Expires: now.Add(time.Minute)},
+			{Where: "level <", Expires: now.Add(time.Minute)},
+		} {
+			if _, err := list.Add(rule); !errors.Is(err, ErrInvalid) {
+				t.Errorf("Add(%+v) = %v, want ErrInvalid", rule, err)
+			}
+		}
+	})
+}
+
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
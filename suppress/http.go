@@ -0,0 +1,69 @@
+package suppress
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// AdminHandler returns an http.Handler exposing the rules of the list.
+//
+// GET responds with a JSON array of the active rules with the number of
+// records they muted. POST accepts a rule as a JSON object; instead of
+// "expires", a "for" duration such as "30m" may be given. DELETE removes
+// the rule of the "id" query parameter. POST and DELETE respond with the
+// rule added or removed.
+//
+// The handler performs no authentication; mount it behind appropriate
+// access control.
+func AdminHandler(l *List) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var out any
+		switch req.Method {
+		case http.MethodGet:
+			out = l.Rules()
+		case http.MethodPost:
+			rule, err := parseRule(l, req)
+			if err == nil {
+				rule, err = l.Add(rule)
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			out = Status{Rule: rule}
+		case http.MethodDelete:
+			s, ok := l.Remove(req.URL.Query().Get("id"))
+			if !ok {
+				http.Error(w, "no such rule", http.StatusNotFound)
+				return
+			}
+			out = s
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(out)
+	})
+}
+
+func parseRule(l *List, req *http.Request) (Rule, error) {
+	var body struct {
+		Rule
+		For string `json:"for"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		return Rule{}, fmt.Errorf("error when decoding request body: %w", err)
+	}
+	if body.For != "" {
+		d, err := time.ParseDuration(body.For)
+		if err != nil {
+			return Rule{}, fmt.Errorf("invalid duration %q: %w", body.For, err)
+		}
+		body.Expires = l.now().Add(d)
+	}
+	return body.Rule, nil
+}
@@ -0,0 +1,60 @@
+package suppress
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_AdminHandler(t *testing.T) {
+	list := NewList()
+	handler := AdminHandler(list)
+
+	t.Run("post adds a rule", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"id":"storm","fingerprint":"timeout after #ms","for":"15m"}`)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+		}
+		rules := list.Rules()
+		if len(rules) != 1 || rules[0].ID != "storm" || time.Until(rules[0].Expires) < 14*time.Minute {
+			t.Errorf("Rules() = %+v", rules)
+		}
+	})
+
+	t.Run("get lists rules", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		var out []Status
+		if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		if len(out) != 1 || out[0].Fingerprint != "timeout after #ms" {
+			t.Errorf("unexpected rules: %+v", out)
+		}
+	})
+
+	t.Run("invalid rule is rejected", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"pattern":"x"}`)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("unexpected status %d", rec.Code)
+		}
+	})
+
+	t.Run("delete removes a rule", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/?id=storm", nil))
+		if rec.Code != http.StatusOK || len(list.Rules()) != 0 {
+			t.Errorf("rule was not removed: %d %+v", rec.Code, list.Rules())
+		}
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/?id=storm", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("unexpected status %d", rec.Code)
+		}
+	})
+}
@@ -0,0 +1,184 @@
+// Package suppress provides a slog.Handler wrapper muting the records
+// matched by a list of rules operators change at runtime, each expiring on
+// its own. It is meant for incident storms, when a known noisy error
+// floods every other signal:
+//
+//	list := suppress.NewList()
+//	handler := suppress.Wrap(jsonHandler, list)
+//	http.Handle("/debug/suppress", suppress.AdminHandler(list))
+//
+//	list.Add(suppress.Rule{Fingerprint: suppress.Fingerprint("connection to # refused"), Expires: time.Now().Add(time.Hour)})
+package suppress
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mikluko/slogging/filter"
+	"github.com/mikluko/slogging/internal/fingerprint"
+)
+
+// ErrInvalid is returned when adding a rule matching nothing, matching
+// with an invalid pattern or expression, or already expired.
+var ErrInvalid = errors.New("slogging: invalid suppression rule")
+
+// Rule mutes the records matching all of its conditions until it expires.
+// At least one condition must be set.
+type Rule struct {
+	// ID identifies the rule. Adding a rule with the ID of another
+	// replaces it; an ID is generated if empty.
+	ID string `json:"id"`
+	// Fingerprint matches the records whose message has this fingerprint,
+	// see the Fingerprint function.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Pattern is a regular expression matching messages.
+	Pattern string `json:"pattern,omitempty"`
+	// Where is a filter expression, see filter.Parse, such as
+	// `level < ERROR && db.host == "replica-3"`.
+	Where string `json:"where,omitempty"`
+	// Expires is when the rule stops muting records.
+	Expires time.Time `json:"expires"`
+	// Reason is a note for other operators.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Status is a rule of a list with the number of records it muted.
+type Status struct {
+	Rule
+	Suppressed uint64 `json:"suppressed"`
+}
+
+// Fingerprint returns the fingerprint of a message: the message with every
+// digit sequence replaced by '#', so "retry 1 of 5" and "retry 2 of 5"
+// share a fingerprint.
+func Fingerprint(msg string) string {
+	return fingerprint.Message(msg)
+}
+
+// entry is a compiled rule.
+type entry struct {
+	rule       Rule
+	pattern    *regexp.Regexp
+	where      *filter.Rule
+	suppressed atomic.Uint64
+}
+
+func (e *entry) match(r slog.Record, fr *filter.Record) bool {
+	if e.rule.Fingerprint != "" && Fingerprint(r.Message) != e.rule.Fingerprint {
+		return false
+	}
+	if e.pattern != nil && !e.pattern.MatchString(r.Message) {
+		return false
+	}
+	return e.where == nil || e.where.Match(fr)
+}
+
+// List is a set of suppression rules safe for concurrent use. Checking
+// records against it does not take locks.
+type List struct {
+	mutex   sync.Mutex // Serializes changes
+	entries atomic.Pointer[[]*entry]
+	nextID  int
+	now     func() time.Time
+}
+
+// NewList returns an empty list.
+func NewList() *List {
+	l := &List{now: time.Now}
+	l.entries.Store(new([]*entry))
+	return l
+}
+
+// Add adds a rule, replacing the rule of the same ID, and returns the rule
+// as added.
+func (l *List) Add(rule Rule) (Rule, error) {
+	if rule.Fingerprint == "" && rule.Pattern == "" && rule.Where == "" {
+		return Rule{}, fmt.Errorf("%w: no fingerprint, pattern or expression", ErrInvalid)
+	}
+	if !rule.Expires.After(l.now()) {
+		return Rule{}, fmt.Errorf("%w: expiry %s not in the future", ErrInvalid, rule.Expires.Format(time.RFC3339))
+	}
+	e := &entry{rule: rule}
+	if rule.Pattern != "" {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return Rule{}, fmt.Errorf("%w: %w", ErrInvalid, err)
+		}
+		e.pattern = re
+	}
+	if rule.Where != "" {
+		b, err := filter.Parse(rule.Where)
+		if err != nil {
+			return Rule{}, fmt.Errorf("%w: %w", ErrInvalid, err)
+		}
+		where := b.Drop()
+		e.where = &where
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if e.rule.ID == "" {
+		l.nextID++
+		e.rule.ID = strconv.Itoa(l.nextID)
+	}
+	entries := l.live()
+	entries = slices.DeleteFunc(entries, func(o *entry) bool { return o.rule.ID == e.rule.ID })
+	entries = append(entries, e)
+	l.entries.Store(&entries)
+	return e.rule, nil
+}
+
+// Mute adds a rule muting the records whose message has the fingerprint of
+// msg for the given duration.
+func (l *List) Mute(msg string, d time.Duration, reason string) (Rule, error) {
+	return l.Add(Rule{Fingerprint: Fingerprint(msg), Expires: l.now().Add(d), Reason: reason})
+}
+
+// Remove removes the rule of the given ID, returning its status, and
+// reports whether it was in the list.
+func (l *List) Remove(id string) (Status, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	entries := l.live()
+	i := slices.IndexFunc(entries, func(e *entry) bool { return e.rule.ID == id })
+	if i < 0 {
+		return Status{}, false
+	}
+	s := Status{Rule: entries[i].rule, Suppressed: entries[i].suppressed.Load()}
+	entries = slices.Delete(entries, i, i+1)
+	l.entries.Store(&entries)
+	return s, true
+}
+
+// Rules returns the rules which have not expired, in the order they were
+// added.
+func (l *List) Rules() []Status {
+	now := l.now()
+	var out []Status
+	for _, e := range *l.entries.Load() {
+		if e.rule.Expires.After(now) {
+			out = append(out, Status{Rule: e.rule, Suppressed: e.suppressed.Load()})
+		}
+	}
+	return out
+}
+
+// live returns a copy of the entries which have not expired. Must be
+// called with the mutex held.
+func (l *List) live() []*entry {
+	now := l.now()
+	var out []*entry
+	for _, e := range *l.entries.Load() {
+		if e.rule.Expires.After(now) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
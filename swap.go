@@ -0,0 +1,111 @@
+package slogging
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+)
+
+// swapped is a handler stored in a Swappable, numbered by the Store call
+// that stored it.
+type swapped struct {
+	gen     uint64
+	handler slog.Handler
+}
+
+// swapState is shared by a Swappable and the handlers derived from it.
+type swapState struct {
+	mutex   sync.Mutex // Serializes Store calls
+	current atomic.Pointer[swapped]
+}
+
+// Swappable is a slog.Handler delivering records to a handler which can be
+// replaced at any time, so that sinks, levels or formats change without
+// recreating the loggers using it. Handlers derived with WithAttrs and
+// WithGroup follow the replacements: their calls are replayed on the new
+// handler the first time they are used after it is stored.
+type Swappable struct {
+	state       *swapState
+	derivations []derivation
+	cache       atomic.Pointer[swapped]
+}
+
+// NewSwappable returns a Swappable delivering records to handler.
+func NewSwappable(handler slog.Handler) *Swappable {
+	s := &Swappable{state: new(swapState)}
+	s.state.current.Store(&swapped{handler: handler})
+	return s
+}
+
+// Store replaces the handler records are delivered to, for s and every
+// handler derived from the Swappable it was derived from, and returns the
+// previous one, e.g. to close it.
+func (s *Swappable) Store(handler slog.Handler) slog.Handler {
+	s.state.mutex.Lock()
+	defer s.state.mutex.Unlock()
+	old := s.state.current.Load()
+	s.state.current.Store(&swapped{gen: old.gen + 1, handler: handler})
+	return old.handler
+}
+
+// Load returns the handler last stored, without the attributes and groups
+// of s.
+func (s *Swappable) Load() slog.Handler {
+	return s.state.current.Load().handler
+}
+
+func (s *Swappable) handler() slog.Handler {
+	cur := s.state.current.Load()
+	if len(s.derivations) == 0 {
+		return cur.handler
+	}
+	if c := s.cache.Load(); c != nil && c.gen == cur.gen {
+		return c.handler
+	}
+	handler := cur.handler
+	for _, d := range s.derivations {
+		if d.group != "" {
+			handler = handler.WithGroup(d.group)
+		} else {
+			handler = handler.WithAttrs(d.attrs)
+		}
+	}
+	s.cache.Store(&swapped{gen: cur.gen, handler: handler})
+	return handler
+}
+
+// Enabled reports whether the current handler handles records at the given level.
+func (s *Swappable) Enabled(ctx context.Context, level slog.Level) bool {
+	return s.handler().Enabled(ctx, level)
+}
+
+// Handle delivers the record to the current handler.
+func (s *Swappable) Handle(ctx context.Context, r slog.Record) error {
+	return s.handler().Handle(ctx, r)
+}
+
+// WithAttrs returns a new Swappable adding the attributes to every handler
+// stored.
+func (s *Swappable) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return s
+	}
+	return s.derive(derivation{attrs: attrs})
+}
+
+// WithGroup returns a new Swappable opening the group in every handler
+// stored.
+func (s *Swappable) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return s
+	}
+	return s.derive(derivation{group: name})
+}
+
+func (s *Swappable) derive(d derivation) *Swappable {
+	derivations := make([]derivation, len(s.derivations)+1)
+	copy(derivations, s.derivations)
+	derivations[len(s.derivations)] = d
+	return &Swappable{state: s.state, derivations: derivations}
+}
@@ -0,0 +1,65 @@
+package slogging
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func Test_Swappable(t *testing.T) {
+	t.Run("derived loggers follow swaps", func(t *testing.T) {
+		json, text := new(bytes.Buffer), new(bytes.Buffer)
+		first := slog.NewJSONHandler(json, &slog.HandlerOptions{ReplaceAttr: dropTime})
+		s := NewSwappable(first)
+		logger := slog.New(s).WithGroup("req").With("id", 7)
+		logger.Info("before")
+
+		second := slog.NewTextHandler(text, &slog.HandlerOptions{Level: slog.LevelWarn, ReplaceAttr: dropTime})
+		if old := s.Store(second); old != first {
+			t.Errorf("Store() = %v, want the first handler", old)
+		}
+		if s.Load() != second {
+			t.Error("Load() is not the stored handler")
+		}
+		logger.Info("hidden")
+		logger.Warn("after")
+
+		if got := strings.TrimSpace(json.String()); got != `{"level":"INFO","msg":"before","req":{"id":7}}` {
+			t.Errorf("first handler got %s", got)
+		}
+		if got := strings.TrimSpace(text.String()); got != "level=WARN msg=after req.id=7" {
+			t.Errorf("second handler got %s", got)
+		}
+	})
+
+	t.Run("concurrent swaps", func(t *testing.T) {
+		s := NewSwappable(slog.NewTextHandler(new(bytes.Buffer), nil))
+		logger := slog.New(s).With("a", 1)
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				for range 100 {
+					s.Store(slog.NewTextHandler(new(bytes.Buffer), nil))
+				}
+			}()
+			go func() {
+				defer wg.Done()
+				for range 100 {
+					logger.Info("m")
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func dropTime(groups []string, a slog.Attr) slog.Attr {
+	if len(groups) == 0 && a.Key == slog.TimeKey {
+		return slog.Attr{}
+	}
+	return a
+}
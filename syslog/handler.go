@@ -0,0 +1,417 @@
+// Package syslog provides a slog.Handler writing RFC 5424 syslog messages,
+// with attributes carried as structured data, to a local syslog daemon or
+// a remote collector over UDP, TCP or TLS.
+package syslog
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikluko/slogging/internal/scope"
+	"github.com/mikluko/slogging/severity"
+)
+
+// Facility is the syslog facility of messages.
+type Facility int
+
+const (
+	Kern Facility = iota
+	User
+	Mail
+	Daemon
+	Auth
+	Syslog
+	LPR
+	News
+	UUCP
+	Cron
+	AuthPriv
+	FTP
+	Local0 Facility = iota + 4
+	Local1
+	Local2
+	Local3
+	Local4
+	Local5
+	Local6
+	Local7
+)
+
+const (
+	// MsgIDKey is the key of a top-level attribute used as the MSGID of the
+	// message instead of being added to the structured data.
+	MsgIDKey = "msgid"
+
+	// DefaultEnterpriseID is the private enterprise number of the SD-IDs,
+	// 32473, reserved for documentation by RFC 5612. Set the number of the
+	// organization with WithEnterpriseID.
+	DefaultEnterpriseID = 32473
+
+	// attrsElement is the name of the element holding top-level attributes.
+	attrsElement = "attrs"
+
+	timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// Handler is a slog.Handler that writes every record as an RFC 5424
+// message. The level is mapped to a syslog severity and combined with the
+// facility into the priority. Attributes become structured data: every
+// top-level group is an SD element named after the group, with nested keys
+// joined with ".", and the other top-level attributes form an "attrs"
+// element. Element names are qualified with the enterprise number, e.g.
+// "http@32473".
+//
+// Messages are sent to the local syslog socket, or to a collector over UDP,
+// TCP or TLS, through a connection redialed when a write fails. Close must
+// be called to release the connection.
+type Handler struct {
+	scope scope.Scope
+
+	// Shared state across WithAttrs/WithGroup instances.
+	transport transport
+
+	level        slog.Leveler
+	facility     Facility
+	hostname     string
+	appName      string
+	procID       string
+	enterpriseID string
+	table        *severity.Table
+}
+
+// NewHandler creates a new Handler with the given options. Connections are
+// established on first use, so dial errors are returned by Handle.
+func NewHandler(options ...Option) *Handler {
+	config := handlerOptions{
+		writer:       io.Discard,
+		level:        slog.LevelInfo,
+		facility:     User,
+		appName:      filepath.Base(os.Args[0]),
+		enterpriseID: DefaultEnterpriseID,
+		writeTimeout: writeTimeout,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	if config.hostname == "" {
+		config.hostname, _ = os.Hostname()
+	}
+	var t transport
+	if config.network != "" {
+		t = &connTransport{network: config.network, addr: config.addr, tlsConfig: config.tlsConfig, timeout: config.writeTimeout}
+	} else {
+		t = &writerTransport{writer: config.writer}
+	}
+	return &Handler{
+		transport:    t,
+		level:        config.level,
+		facility:     config.facility,
+		hostname:     headerField(config.hostname, 255),
+		appName:      headerField(config.appName, 48),
+		procID:       strconv.Itoa(os.Getpid()),
+		enterpriseID: strconv.Itoa(config.enterpriseID),
+		table:        config.table,
+	}
+}
+
+func (h *Handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithAttrs(attrs)
+	return &h2
+}
+
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.scope = h.scope.WithGroup(name)
+	return &h2
+}
+
+func (h *Handler) Handle(_ context.Context, r slog.Record) error {
+	table := h.table
+	if table == nil {
+		table = severity.Default()
+	}
+	sev := table.Lookup(severity.Syslog, r.Level)
+
+	sd := &structuredData{}
+	for _, a := range h.scope.Attrs(r) {
+		sd.add(nil, a)
+	}
+
+	timestamp := "-"
+	if !r.Time.IsZero() {
+		timestamp = r.Time.Format(timeFormat)
+	}
+	msgID := "-"
+	if sd.msgID != "" {
+		msgID = headerField(sd.msgID, 32)
+	}
+	msg := fmt.Appendf(nil, "<%d>1 %s %s %s %s %s ",
+		int(h.facility)*8+sev.Code, timestamp, h.hostname, h.appName, h.procID, msgID)
+	msg = sd.appendTo(msg, h.enterpriseID)
+	if r.Message != "" {
+		msg = append(msg, ' ')
+		msg = append(msg, r.Message...)
+	}
+	return h.transport.send(msg)
+}
+
+// Close closes the connection to the syslog endpoint, or the writer set
+// with WithWriter if it is an io.Closer.
+func (h *Handler) Close() error {
+	return h.transport.close()
+}
+
+type element struct {
+	name   string
+	params [][2]string
+}
+
+// structuredData collects SD elements in order of first appearance.
+type structuredData struct {
+	elements []*element
+	msgID    string
+}
+
+func (sd *structuredData) add(path []string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			path = append(path[:len(path):len(path)], a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			sd.add(path, ga)
+		}
+		return
+	}
+	if len(path) == 0 && a.Key == MsgIDKey {
+		sd.msgID = a.Value.String()
+		return
+	}
+	name, param := attrsElement, a.Key
+	if len(path) > 0 {
+		name = path[0]
+		param = strings.Join(append(path[1:len(path):len(path)], a.Key), ".")
+	}
+	var e *element
+	for _, x := range sd.elements {
+		if x.name == name {
+			e = x
+			break
+		}
+	}
+	if e == nil {
+		e = &element{name: name}
+		sd.elements = append(sd.elements, e)
+	}
+	e.params = append(e.params, [2]string{param, a.Value.String()})
+}
+
+func (sd *structuredData) appendTo(b []byte, enterpriseID string) []byte {
+	if len(sd.elements) == 0 {
+		return append(b, '-')
+	}
+	suffix := "@" + enterpriseID
+	for _, e := range sd.elements {
+		b = append(b, '[')
+		b = append(b, sdName(e.name, 32-len(suffix))...)
+		b = append(b, suffix...)
+		for _, p := range e.params {
+			b = append(b, ' ')
+			b = append(b, sdName(p[0], 32)...)
+			b = append(b, '=', '"')
+			b = appendParamValue(b, p[1])
+			b = append(b, '"')
+		}
+		b = append(b, ']')
+	}
+	return b
+}
+
+// sdName returns s with the characters not allowed in SD names replaced
+// with "_", truncated to n bytes.
+func sdName(s string, n int) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c <= ' ' || c > '~' || c == '=' || c == ']' || c == '"' || c == '@' {
+			b[i] = '_'
+		}
+	}
+	if len(b) > n {
+		b = b[:n]
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
+
+func appendParamValue(b []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"', '\\', ']':
+			b = append(b, '\\', c)
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+// headerField returns s with the characters not allowed in header fields
+// replaced with "_", truncated to n bytes, or "-" if s is empty.
+func headerField(s string, n int) string {
+	if s == "" {
+		return "-"
+	}
+	b := []byte(s)
+	for i, c := range b {
+		if c <= ' ' || c > '~' {
+			b[i] = '_'
+		}
+	}
+	if len(b) > n {
+		b = b[:n]
+	}
+	return string(b)
+}
+
+type handlerOptions struct {
+	writer       io.Writer
+	network      string
+	addr         string
+	tlsConfig    *tls.Config
+	writeTimeout time.Duration
+	level        slog.Leveler
+	facility     Facility
+	hostname     string
+	appName      string
+	enterpriseID int
+	table        *severity.Table
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithUnix sends messages to the local syslog daemon listening on the unix
+// socket at path, usually "/dev/log". Datagram sockets are tried first,
+// stream sockets receive newline-terminated messages.
+func WithUnix(path string) Option {
+	return func(h *handlerOptions) {
+		h.network, h.addr, h.tlsConfig = "unix", path, nil
+	}
+}
+
+// WithUDP sends messages as UDP datagrams to addr, e.g. "collector:514".
+func WithUDP(addr string) Option {
+	return func(h *handlerOptions) {
+		h.network, h.addr, h.tlsConfig = "udp", addr, nil
+	}
+}
+
+// WithTCP sends messages over TCP to addr, framed with octet counting as
+// described in RFC 6587.
+func WithTCP(addr string) Option {
+	return func(h *handlerOptions) {
+		h.network, h.addr, h.tlsConfig = "tcp", addr, nil
+	}
+}
+
+// WithTLS sends messages over TLS to addr, e.g. "collector:6514", as
+// described in RFC 5425. A nil config uses the defaults of crypto/tls.
+func WithTLS(addr string, config *tls.Config) Option {
+	return func(h *handlerOptions) {
+		if config == nil {
+			config = &tls.Config{}
+		}
+		h.network, h.addr, h.tlsConfig = "tcp", addr, config
+	}
+}
+
+// WithWriteTimeout sets how long a write to a socket may block, 5 seconds
+// by default. A message whose write times out is dropped and the connection
+// redialed on the next message. Zero disables the timeout.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.writeTimeout = d
+	}
+}
+
+// WithWriter writes newline-delimited messages to writer instead of a
+// socket. If writer is nil, output will be discarded.
+func WithWriter(writer io.Writer) Option {
+	return func(h *handlerOptions) {
+		if writer == nil {
+			writer = io.Discard
+		}
+		h.network, h.writer = "", writer
+	}
+}
+
+// WithLevel sets the minimum log level for the handler.
+func WithLevel(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.level = lvl
+	}
+}
+
+// WithFacility sets the facility of messages, User by default.
+func WithFacility(f Facility) Option {
+	return func(h *handlerOptions) {
+		h.facility = f
+	}
+}
+
+// WithHostname sets the HOSTNAME field. It defaults to the hostname.
+func WithHostname(name string) Option {
+	return func(h *handlerOptions) {
+		h.hostname = name
+	}
+}
+
+// WithAppName sets the APP-NAME field. It defaults to the base name of the
+// executable.
+func WithAppName(name string) Option {
+	return func(h *handlerOptions) {
+		h.appName = name
+	}
+}
+
+// WithEnterpriseID sets the private enterprise number qualifying SD-IDs,
+// DefaultEnterpriseID by default.
+func WithEnterpriseID(n int) Option {
+	return func(h *handlerOptions) {
+		h.enterpriseID = n
+	}
+}
+
+// WithSeverityTable sets the table used to map levels to syslog severities.
+// The default table from the severity package is used otherwise.
+func WithSeverityTable(t *severity.Table) Option {
+	return func(h *handlerOptions) {
+		h.table = t
+	}
+}
@@ -0,0 +1,224 @@
+package syslog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_Handler(t *testing.T) {
+	t.Run("message format", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf), WithFacility(Local0), WithHostname("web-1"), WithAppName("app")))
+
+		logger.With("user", "bob").WithGroup("http").Warn("request failed",
+			"status", 503, slog.Group("req", "method", "GET"), slog.Group("", "path", `/a"b]c\d`))
+
+		want := regexp.MustCompile(`^<132>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}\S+ web-1 app \d+ - ` +
+			regexp.QuoteMeta(`[attrs@32473 user="bob"][http@32473 status="503" req.method="GET" path="/a\"b\]c\\d"] request failed`) + "\n$")
+		if !want.MatchString(buf.String()) {
+			t.Errorf("unexpected message: %s", buf.String())
+		}
+	})
+
+	t.Run("msgid and names", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		logger := slog.New(NewHandler(WithWriter(buf), WithEnterpriseID(1234), WithHostname("h"), WithAppName("a")))
+
+		logger.Error("", MsgIDKey, "LOGIN", slog.Group("bad group", "k=v", 1))
+
+		if !strings.HasSuffix(buf.String(), ` LOGIN [bad_group@1234 k_v="1"]`+"\n") || !strings.HasPrefix(buf.String(), "<11>1 ") {
+			t.Errorf("unexpected message: %s", buf.String())
+		}
+
+		buf.Reset()
+		logger.Info("no attrs")
+		if !strings.HasSuffix(buf.String(), " - - no attrs\n") {
+			t.Errorf("expected nil MSGID and SD, got: %s", buf.String())
+		}
+	})
+
+	t.Run("udp", func(t *testing.T) {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer pc.Close()
+		h := NewHandler(WithUDP(pc.LocalAddr().String()))
+		defer h.Close()
+
+		slog.New(h).Info("test message")
+
+		b := make([]byte, 1024)
+		_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, _, err := pc.ReadFrom(b)
+		if err != nil || !strings.HasPrefix(string(b[:n]), "<14>1 ") || !strings.HasSuffix(string(b[:n]), " - test message") {
+			t.Errorf("unexpected datagram %q: %v", b[:n], err)
+		}
+	})
+
+	t.Run("unix datagram", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "log.sock")
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		h := NewHandler(WithUnix(path))
+		defer h.Close()
+
+		slog.New(h).Info("test message")
+
+		b := make([]byte, 1024)
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		n, err := conn.Read(b)
+		if err != nil || !strings.HasSuffix(string(b[:n]), " - test message") {
+			t.Errorf("unexpected datagram %q: %v", b[:n], err)
+		}
+	})
+
+	t.Run("tcp reconnect", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		received := serve(ln)
+
+		h := NewHandler(WithTCP(ln.Addr().String()))
+		defer h.Close()
+		logger := slog.New(h)
+		logger.Info("first")
+		// Break the connection from the client side; the next write fails
+		// and the handler redials.
+		_ = h.transport.(*connTransport).conn.Close()
+		logger.Info("second")
+
+		expect(t, received, "first", "second")
+	})
+
+	t.Run("tls", func(t *testing.T) {
+		s := httptest.NewUnstartedServer(nil)
+		s.StartTLS()
+		serverConfig := &tls.Config{Certificates: s.TLS.Certificates}
+		roots := x509.NewCertPool()
+		roots.AddCert(s.Certificate())
+		s.Close()
+
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", serverConfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		received := serve(ln)
+
+		h := NewHandler(WithTLS(ln.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "example.com"}))
+		defer h.Close()
+		slog.New(h).Info("secure")
+
+		expect(t, received, "secure")
+	})
+
+	t.Run("tcp write timeout", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		// Accept connections but never read from them, so writes block once
+		// the socket buffers are full.
+		accepted := make(chan net.Conn, 1)
+		go func() {
+			conn, err := ln.Accept()
+			if err == nil {
+				accepted <- conn
+			}
+		}()
+
+		h := NewHandler(WithTCP(ln.Addr().String()), WithWriteTimeout(50*time.Millisecond))
+		defer h.Close()
+
+		start := time.Now()
+		err = h.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, strings.Repeat("x", 32<<20), 0))
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("expected deadline exceeded, got: %v", err)
+		}
+		if d := time.Since(start); d > 5*time.Second {
+			t.Errorf("write blocked for %s", d)
+		}
+		if h.transport.(*connTransport).conn != nil {
+			t.Error("expected the connection to be closed")
+		}
+		select {
+		case conn := <-accepted:
+			_ = conn.Close()
+		default:
+		}
+	})
+}
+
+// serve accepts connections on ln and reads octet-counted messages.
+func serve(ln net.Listener) <-chan string {
+	received := make(chan string, 8)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				r := bufio.NewReader(conn)
+				for {
+					prefix, err := r.ReadString(' ')
+					if err != nil {
+						return
+					}
+					n, err := strconv.Atoi(strings.TrimSpace(prefix))
+					if err != nil {
+						received <- "bad frame: " + prefix
+						return
+					}
+					msg := make([]byte, n)
+					if _, err := io.ReadFull(r, msg); err != nil {
+						return
+					}
+					received <- string(msg)
+				}
+			}()
+		}
+	}()
+	return received
+}
+
+func expect(t *testing.T, received <-chan string, messages ...string) {
+	t.Helper()
+	var got []string
+	for len(got) < len(messages) {
+		select {
+		case msg := <-received:
+			got = append(got, msg)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for messages, got: %q", got)
+		}
+	}
+	all := strings.Join(got, "\n")
+	for _, m := range messages {
+		if !strings.Contains(all, " - "+m) {
+			t.Errorf("expected %s, got: %q", m, got)
+		}
+	}
+}
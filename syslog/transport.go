@@ -0,0 +1,131 @@
+package syslog
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	dialTimeout  = 5 * time.Second
+	writeTimeout = 5 * time.Second
+)
+
+type transport interface {
+	send(msg []byte) error
+	close() error
+}
+
+// writerTransport writes newline-delimited messages to an io.Writer.
+type writerTransport struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+func (t *writerTransport) send(msg []byte) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	_, err := t.writer.Write(append(msg, '\n'))
+	return err
+}
+
+func (t *writerTransport) close() error {
+	if c, ok := t.writer.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// connTransport sends messages over a connection dialed on first use and
+// redialed when a write fails. TCP and TLS messages are framed with octet
+// counting (RFC 6587, RFC 5425), unix stream messages are terminated with
+// a newline, and datagrams carry one message each. A write that times out
+// drops the message and closes the connection, as the endpoint may have
+// received part of it.
+type connTransport struct {
+	mutex     sync.Mutex
+	network   string
+	addr      string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+	conn      net.Conn
+	framing   string // Network of conn
+}
+
+func (t *connTransport) dial() (net.Conn, string, error) {
+	if t.tlsConfig != nil {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: dialTimeout}, "tcp", t.addr, t.tlsConfig)
+		return conn, "tcp", err
+	}
+	if t.network == "unix" {
+		// Local syslog daemons usually listen on a datagram socket.
+		if conn, err := net.DialTimeout("unixgram", t.addr, dialTimeout); err == nil {
+			return conn, "unixgram", nil
+		}
+	}
+	conn, err := net.DialTimeout(t.network, t.addr, dialTimeout)
+	return conn, t.network, err
+}
+
+func frame(msg []byte, network string) []byte {
+	switch network {
+	case "tcp":
+		return fmt.Appendf(nil, "%d %s", len(msg), msg)
+	case "unix":
+		return append(msg, '\n')
+	}
+	return msg
+}
+
+func (t *connTransport) send(msg []byte) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.conn != nil {
+		err := t.write(t.conn, frame(msg, t.framing))
+		if err == nil {
+			return nil
+		}
+		_ = t.conn.Close()
+		t.conn = nil
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return fmt.Errorf("error when writing syslog message: %w", err)
+		}
+	}
+	conn, network, err := t.dial()
+	if err != nil {
+		return fmt.Errorf("error when dialing syslog endpoint: %w", err)
+	}
+	if err := t.write(conn, frame(msg, network)); err != nil {
+		_ = conn.Close()
+		return fmt.Errorf("error when writing syslog message: %w", err)
+	}
+	t.conn, t.framing = conn, network
+	return nil
+}
+
+// write writes msg to conn within the write timeout, if any.
+func (t *connTransport) write(conn net.Conn, msg []byte) error {
+	if t.timeout > 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(t.timeout)); err != nil {
+			return err
+		}
+	}
+	_, err := conn.Write(msg)
+	return err
+}
+
+func (t *connTransport) close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.conn = nil
+	return err
+}
@@ -0,0 +1,270 @@
+package tail
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/mikluko/slogging"
+)
+
+const (
+	defaultTTL        = time.Minute
+	defaultMaxRecords = 1000
+)
+
+// KeyFunc extracts the key records are buffered under, such as a trace or
+// request ID. An empty key means the record is not buffered.
+type KeyFunc func(ctx context.Context) string
+
+// TraceKey keys records by the trace ID of the span in the logging context.
+func TraceKey(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+type entry struct {
+	ctx     context.Context
+	handler slog.Handler
+	record  slog.Record
+}
+
+type bucket struct {
+	entries   []entry
+	triggered bool // Records are forwarded directly once triggered
+	expires   time.Time
+}
+
+// state is shared across WithAttrs/WithGroup derivations.
+type state struct {
+	mutex      sync.Mutex
+	buckets    map[string]*bucket
+	nextSweep  time.Time
+	ttl        time.Duration
+	maxRecords int
+	now        func() time.Time
+}
+
+// sweep discards expired buckets. Must be called with the mutex held.
+func (s *state) sweep(now time.Time) {
+	if now.Before(s.nextSweep) {
+		return
+	}
+	for k, b := range s.buckets {
+		if !now.Before(b.expires) {
+			delete(s.buckets, k)
+		}
+	}
+	s.nextSweep = now.Add(s.ttl)
+}
+
+// Handler is a slog.Handler that holds back low-level records logged with
+// a key (the trace ID by default) and delivers them only if a record at the
+// trigger level is logged with the same key before the TTL expires.
+// Afterwards records for that key are delivered directly until the TTL
+// expires. Unkeyed records and records at or above the buffering threshold
+// are delivered immediately.
+type Handler struct {
+	handler slog.Handler
+	state   *state
+	key     KeyFunc
+	below   slog.Leveler
+	trigger slog.Leveler
+}
+
+// Wrap creates a tail buffering handler delivering to handler.
+func Wrap(handler slog.Handler, options ...Option) *Handler {
+	config := handlerOptions{
+		key:        TraceKey,
+		below:      slog.LevelWarn,
+		trigger:    slog.LevelError,
+		ttl:        defaultTTL,
+		maxRecords: defaultMaxRecords,
+	}
+	for _, opt := range options {
+		if opt != nil {
+			opt(&config)
+		}
+	}
+	return &Handler{
+		handler: handler,
+		key:     config.key,
+		below:   config.below,
+		trigger: config.trigger,
+		state: &state{
+			buckets:    make(map[string]*bucket),
+			ttl:        config.ttl,
+			maxRecords: config.maxRecords,
+			now:        time.Now,
+		},
+	}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *Handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler.Enabled(ctx, level)
+}
+
+// Handle buffers, delivers or flushes records depending on their level and key.
+func (h *Handler) Handle(ctx context.Context, r slog.Record) error {
+	key := h.key(ctx)
+	if key == "" {
+		return h.handler.Handle(ctx, r)
+	}
+
+	s := h.state
+	s.mutex.Lock()
+	now := s.now()
+	s.sweep(now)
+	b := s.buckets[key]
+	if b != nil && !now.Before(b.expires) {
+		delete(s.buckets, key)
+		b = nil
+	}
+
+	if r.Level >= h.trigger.Level() {
+		var pending []entry
+		if b == nil {
+			b = &bucket{}
+			s.buckets[key] = b
+		}
+		pending, b.entries = b.entries, nil
+		b.triggered = true
+		b.expires = now.Add(s.ttl)
+		s.mutex.Unlock()
+
+		var errs []error
+		for _, e := range pending {
+			if err := e.handler.Handle(e.ctx, e.record); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		if err := h.handler.Handle(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	}
+
+	if r.Level >= h.below.Level() || (b != nil && b.triggered) {
+		s.mutex.Unlock()
+		return h.handler.Handle(ctx, r)
+	}
+
+	if b == nil {
+		b = &bucket{expires: now.Add(s.ttl)}
+		s.buckets[key] = b
+	}
+	if len(b.entries) >= s.maxRecords {
+		copy(b.entries, b.entries[1:])
+		b.entries = b.entries[:len(b.entries)-1]
+	}
+	b.entries = append(b.entries, entry{
+		ctx:     context.WithoutCancel(ctx),
+		handler: h.handler,
+		record:  slogging.CloneRecord(r),
+	})
+	s.mutex.Unlock()
+	return nil
+}
+
+// Complete discards the records buffered for the key of ctx, typically
+// when the request finished without errors.
+func (h *Handler) Complete(ctx context.Context) {
+	key := h.key(ctx)
+	if key == "" {
+		return
+	}
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	delete(h.state.buckets, key)
+}
+
+// Pending returns the number of records currently buffered.
+func (h *Handler) Pending() int {
+	h.state.mutex.Lock()
+	defer h.state.mutex.Unlock()
+	n := 0
+	for _, b := range h.state.buckets {
+		n += len(b.entries)
+	}
+	return n
+}
+
+// WithAttrs returns a new Handler sharing the buffers whose wrapped handler
+// includes the given attributes.
+func (h *Handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithAttrs(attrs)
+	return &h2
+}
+
+// WithGroup returns a new Handler sharing the buffers whose wrapped handler
+// starts the given group.
+func (h *Handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.handler = h.handler.WithGroup(name)
+	return &h2
+}
+
+type handlerOptions struct {
+	key        KeyFunc
+	below      slog.Leveler
+	trigger    slog.Leveler
+	ttl        time.Duration
+	maxRecords int
+}
+
+// Option is a function that configures a Handler.
+type Option func(h *handlerOptions)
+
+// WithKey sets the function extracting the buffering key from the logging
+// context. TraceKey is used by default.
+func WithKey(fn KeyFunc) Option {
+	return func(h *handlerOptions) {
+		h.key = fn
+	}
+}
+
+// WithBufferBelow sets the level below which keyed records are buffered.
+// Defaults to slog.LevelWarn.
+func WithBufferBelow(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.below = lvl
+	}
+}
+
+// WithTrigger sets the level at which buffered records are flushed.
+// Defaults to slog.LevelError.
+func WithTrigger(lvl slog.Leveler) Option {
+	return func(h *handlerOptions) {
+		h.trigger = lvl
+	}
+}
+
+// WithTTL sets how long records are kept for a key without a trigger.
+func WithTTL(d time.Duration) Option {
+	return func(h *handlerOptions) {
+		h.ttl = d
+	}
+}
+
+// WithMaxRecords caps the number of records buffered per key. The oldest
+// records are discarded first. Values below 1 are treated as 1.
+func WithMaxRecords(n int) Option {
+	return func(h *handlerOptions) {
+		h.maxRecords = max(n, 1)
+	}
+}
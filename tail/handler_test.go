@@ -0,0 +1,113 @@
+package tail
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type ctxKey struct{}
+
+func requestKey(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+func Test_Tail(t *testing.T) {
+	setup := func(options ...Option) (*bytes.Buffer, *Handler, *time.Time) {
+		buf := new(bytes.Buffer)
+		now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+		opts := append([]Option{WithKey(requestKey)}, options...)
+		h := Wrap(slog.NewTextHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}), opts...)
+		h.state.now = func() time.Time { return now }
+		return buf, h, &now
+	}
+	req := func(id string) context.Context {
+		return context.WithValue(context.Background(), ctxKey{}, id)
+	}
+
+	t.Run("buffered records are flushed on error", func(t *testing.T) {
+		buf, h, _ := setup()
+		logger := slog.New(h).With("svc", "api")
+
+		logger.DebugContext(req("a"), "step one")
+		logger.InfoContext(req("b"), "other request")
+		logger.InfoContext(req("a"), "step two")
+		if buf.Len() != 0 {
+			t.Fatalf("expected records to be buffered, got: %s", buf.String())
+		}
+
+		logger.ErrorContext(req("a"), "failed")
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 lines, got: %s", buf.String())
+		}
+		for i, msg := range []string{`"step one"`, `"step two"`, `failed`} {
+			if !strings.Contains(lines[i], `msg=`+msg+` svc=api`) {
+				t.Errorf("line %d: expected %q, got %s", i, msg, lines[i])
+			}
+		}
+		if h.Pending() != 1 {
+			t.Errorf("expected other request to stay buffered, got %d", h.Pending())
+		}
+
+		logger.DebugContext(req("a"), "after failure")
+		if !strings.Contains(buf.String(), "after failure") {
+			t.Errorf("expected records after trigger to be delivered directly")
+		}
+	})
+
+	t.Run("warnings and unkeyed records pass through", func(t *testing.T) {
+		buf, h, _ := setup()
+		logger := slog.New(h)
+
+		logger.WarnContext(req("a"), "warned")
+		logger.Debug("unkeyed")
+		if !strings.Contains(buf.String(), "warned") || !strings.Contains(buf.String(), "unkeyed") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+
+	t.Run("expired buffers are discarded", func(t *testing.T) {
+		buf, h, now := setup(WithTTL(time.Second))
+		logger := slog.New(h)
+
+		logger.InfoContext(req("a"), "stale")
+		*now = now.Add(2 * time.Second)
+		logger.ErrorContext(req("a"), "failed")
+
+		if strings.Contains(buf.String(), "stale") {
+			t.Errorf("expired record should be discarded: %s", buf.String())
+		}
+	})
+
+	t.Run("complete discards buffer", func(t *testing.T) {
+		buf, h, _ := setup()
+		logger := slog.New(h)
+
+		logger.InfoContext(req("a"), "fine")
+		h.Complete(req("a"))
+		logger.ErrorContext(req("a"), "failed")
+
+		if strings.Contains(buf.String(), "fine") {
+			t.Errorf("completed record should be discarded: %s", buf.String())
+		}
+	})
+
+	t.Run("buffer size is capped", func(t *testing.T) {
+		buf, h, _ := setup(WithMaxRecords(2))
+		logger := slog.New(h)
+
+		logger.InfoContext(req("a"), "one")
+		logger.InfoContext(req("a"), "two")
+		logger.InfoContext(req("a"), "three")
+		logger.ErrorContext(req("a"), "failed")
+
+		if strings.Contains(buf.String(), "msg=one") || !strings.Contains(buf.String(), "msg=three") {
+			t.Errorf("unexpected output: %s", buf.String())
+		}
+	})
+}